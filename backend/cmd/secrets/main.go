@@ -0,0 +1,124 @@
+// Command secrets 管理凭证加密主密钥
+//
+// 用法：
+//
+//	go run ./cmd/secrets [-config config/config.yaml] [-batch 200] <status|rotate|reencrypt|retire>
+//
+//	status     查看当前主密钥版本及各加密字段使用的版本分布
+//	rotate     生成新的主密钥版本，并用新版本重新加密所有数据
+//	reencrypt  用当前主密钥重新加密旧版本或旧格式的数据（上次重新加密中断时使用）
+//	retire     所有数据都使用当前版本后，停用旧版本主密钥
+//
+// 重新加密在服务运行期间执行，逐条写回且不覆盖并发修改的记录。
+package main
+
+import (
+	"bastion/config"
+	"bastion/services"
+	"bastion/utils"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+func main() {
+	configPath := flag.String("config", "config/config.yaml", "配置文件路径")
+	batchSize := flag.Int("batch", 200, "重新加密每批处理的记录数")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <status|rotate|reencrypt|retire>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := config.LoadConfig(*configPath); err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := utils.InitDatabase(); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer utils.CloseDatabase()
+	if err := utils.InitSecretStore(); err != nil {
+		log.Fatalf("Failed to initialize secret store: %v", err)
+	}
+
+	service := services.NewSecretRotationService(utils.GetDB())
+	switch flag.Arg(0) {
+	case "status":
+		printStatus(service)
+	case "rotate":
+		version, err := service.RotateMasterKey()
+		if err != nil {
+			log.Fatalf("Failed to rotate master key: %v", err)
+		}
+		fmt.Printf("master key rotated, current version: %d\n", version)
+		reencrypt(service, *batchSize)
+	case "reencrypt":
+		reencrypt(service, *batchSize)
+	case "retire":
+		version, err := service.RetireOldVersions()
+		if err != nil {
+			log.Fatalf("Failed to retire master keys: %v", err)
+		}
+		fmt.Printf("master key versions below %d retired\n", version)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// printStatus 输出主密钥版本分布
+func printStatus(service *services.SecretRotationService) {
+	current, stats, err := service.Status()
+	if err != nil {
+		log.Fatalf("Failed to get status: %v", err)
+	}
+
+	fmt.Printf("current master key version: %d\n", current)
+	for _, field := range stats {
+		versions := make([]int, 0, len(field.Versions))
+		for version := range field.Versions {
+			versions = append(versions, version)
+		}
+		sort.Ints(versions)
+
+		fmt.Printf("%s.%s:", field.Table, field.Column)
+		if len(versions) == 0 {
+			fmt.Print(" no data")
+		}
+		for _, version := range versions {
+			label := fmt.Sprintf("v%d", version)
+			if version == 0 {
+				label = "legacy"
+			}
+			fmt.Printf(" %s=%d", label, field.Versions[version])
+		}
+		fmt.Println()
+	}
+}
+
+// reencrypt 重新加密并输出结果，有失败记录时以非零状态退出
+func reencrypt(service *services.SecretRotationService, batchSize int) {
+	results, err := service.Reencrypt(batchSize)
+	if err != nil {
+		log.Fatalf("Failed to reencrypt secrets: %v", err)
+	}
+
+	failed := 0
+	for _, stats := range results {
+		fmt.Printf("%s.%s: scanned=%d reencrypted=%d skipped=%d failed=%d\n",
+			stats.Table, stats.Column, stats.Scanned, stats.Reencrypted, stats.Skipped, stats.Failed)
+		for _, message := range stats.Errors {
+			fmt.Printf("  %s\n", message)
+		}
+		failed += stats.Failed
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -12,8 +12,14 @@ type Config struct {
 	Database  DatabaseConfig    `mapstructure:"database"`
 	Redis     RedisConfig       `mapstructure:"redis"`
 	JWT       JWTConfig         `mapstructure:"jwt"`
+	LDAP      LDAPConfig        `mapstructure:"ldap"`
+	OIDC      OIDCConfig        `mapstructure:"oidc"`
 	Log       LogConfig         `mapstructure:"log"`
 	SSH       SSHConfig         `mapstructure:"ssh"`
+	Telnet    TelnetConfig      `mapstructure:"telnet"`
+	SSHGateway SSHGatewayConfig `mapstructure:"sshGateway"`
+	PortForward PortForwardConfig `mapstructure:"portForward"`
+	DatabaseProxy DatabaseProxyConfig `mapstructure:"databaseProxy"`
 	Session   SessionConfig     `mapstructure:"session"`
 	Security  SecurityConfig    `mapstructure:"security"`
 	Upload    UploadConfig      `mapstructure:"upload"`
@@ -21,6 +27,10 @@ type Config struct {
 	Audit     AuditConfig       `mapstructure:"audit"`
 	WebSocket WebSocketConfig   `mapstructure:"websocket"`
 	Monitor   MonitorConfig     `mapstructure:"monitor"`
+	AccessRequest AccessRequestConfig `mapstructure:"accessRequest"`
+	CredentialRotation CredentialRotationConfig `mapstructure:"credentialRotation"`
+	CredentialCheckout CredentialCheckoutConfig `mapstructure:"credentialCheckout"`
+	SecretStore SecretStoreConfig `mapstructure:"secretStore"`
 }
 
 // AppConfig 应用程序配置
@@ -65,6 +75,51 @@ type JWTConfig struct {
 	Issuer string `mapstructure:"issuer"`
 }
 
+// LDAPConfig LDAP/Active Directory认证配置
+type LDAPConfig struct {
+	Enable             bool               `mapstructure:"enable"`
+	URL                string             `mapstructure:"url"` // ldap://host:389 或 ldaps://host:636
+	StartTLS           bool               `mapstructure:"startTLS"`
+	InsecureSkipVerify bool               `mapstructure:"insecureSkipVerify"`
+	Timeout            int                `mapstructure:"timeout"` // 连接与查询超时，秒
+	BindDN             string             `mapstructure:"bindDN"`  // 查询用户使用的服务账号，为空时匿名查询
+	BindPassword       string             `mapstructure:"bindPassword"`
+	BaseDN             string             `mapstructure:"baseDN"`
+	UserFilter         string             `mapstructure:"userFilter"` // %s 替换为转义后的用户名
+	UsernameAttr       string             `mapstructure:"usernameAttr"`
+	EmailAttr          string             `mapstructure:"emailAttr"`
+	PhoneAttr          string             `mapstructure:"phoneAttr"`
+	GroupAttr          string             `mapstructure:"groupAttr"`   // 用户条目上的组属性，如 memberOf
+	GroupBaseDN        string             `mapstructure:"groupBaseDN"` // 配置后按 groupFilter 查询用户所属组
+	GroupFilter        string             `mapstructure:"groupFilter"` // %s 替换为转义后的用户DN
+	GroupMappings      []GroupRoleMapping `mapstructure:"groupMappings"`
+	DefaultRoles       []string           `mapstructure:"defaultRoles"` // 未匹配任何组映射时分配的角色
+	SyncInterval       int                `mapstructure:"syncInterval"` // 定时同步间隔，秒，0 表示不同步
+}
+
+// OIDCConfig OpenID Connect单点登录配置
+type OIDCConfig struct {
+	Enable        bool               `mapstructure:"enable"`
+	Issuer        string             `mapstructure:"issuer"` // 需提供 /.well-known/openid-configuration
+	ClientID      string             `mapstructure:"clientID"`
+	ClientSecret  string             `mapstructure:"clientSecret"` // 公共客户端可为空，仅依靠PKCE
+	RedirectURL   string             `mapstructure:"redirectURL"`  // 指向 /api/v1/auth/oidc/callback
+	FrontendURL   string             `mapstructure:"frontendURL"`  // 登录完成后跳转的前端地址，令牌放在URL片段中；为空时回调直接返回JSON
+	Scopes        []string           `mapstructure:"scopes"`
+	UsernameClaim string             `mapstructure:"usernameClaim"`
+	EmailClaim    string             `mapstructure:"emailClaim"`
+	GroupsClaim   string             `mapstructure:"groupsClaim"`
+	GroupMappings []GroupRoleMapping `mapstructure:"groupMappings"`
+	DefaultRoles  []string           `mapstructure:"defaultRoles"` // 未匹配任何组映射时分配的角色
+	StateExpire   int                `mapstructure:"stateExpire"`  // 登录状态有效期，秒
+}
+
+// GroupRoleMapping 外部认证源中的组到角色的映射
+type GroupRoleMapping struct {
+	Group string `mapstructure:"group"` // LDAP可写组DN或组CN；OIDC为groups声明中的值。不区分大小写
+	Role  string `mapstructure:"role"`
+}
+
 // LogConfig 日志配置
 type LogConfig struct {
 	Level  string  `mapstructure:"level"`
@@ -84,9 +139,63 @@ type LogFile struct {
 
 // SSHConfig SSH配置
 type SSHConfig struct {
-	Timeout     int `mapstructure:"timeout"`
-	Keepalive   int `mapstructure:"keepalive"`
-	MaxSessions int `mapstructure:"maxSessions"`
+	Timeout               int  `mapstructure:"timeout"`
+	Keepalive             int  `mapstructure:"keepalive"`
+	MaxSessions           int  `mapstructure:"maxSessions"`
+	StrictHostKeyChecking bool `mapstructure:"strictHostKeyChecking"` // 严格校验主机密钥：未登记密钥的资产拒绝连接，默认首次连接时自动信任
+	CertValidity          int  `mapstructure:"certValidity"`          // SSH CA为会话签发的用户证书有效期，分钟
+	MaxGatewayHops        int  `mapstructure:"maxGatewayHops"`        // 经网关（跳板机）连接资产时允许的最大网关级数
+}
+
+// TelnetConfig Telnet会话配置，自动登录时按提示符匹配输入凭证中的用户名和密码
+type TelnetConfig struct {
+	TerminalType   string `mapstructure:"terminalType"`   // 协商时上报的终端类型
+	LoginTimeout   int    `mapstructure:"loginTimeout"`   // 自动登录等待提示符的超时时间，秒
+	UsernamePrompt string `mapstructure:"usernamePrompt"` // 用户名提示符正则
+	PasswordPrompt string `mapstructure:"passwordPrompt"` // 密码提示符正则
+	FailurePattern string `mapstructure:"failurePattern"` // 登录失败提示正则
+	ShellPrompt    string `mapstructure:"shellPrompt"`    // 登录成功后的命令提示符正则
+}
+
+// SSHGatewayConfig SSH网关配置（原生SSH客户端接入）
+type SSHGatewayConfig struct {
+	Enable       bool   `mapstructure:"enable"`
+	Host         string `mapstructure:"host"`
+	Port         int    `mapstructure:"port"`
+	HostKeyPath  string `mapstructure:"hostKeyPath"`  // 网关主机密钥路径，不存在时自动生成
+	PasswordAuth bool   `mapstructure:"passwordAuth"` // 是否允许堡垒机账号密码登录
+	MaxAuthTries int    `mapstructure:"maxAuthTries"`
+	Banner       string `mapstructure:"banner"`
+}
+
+// PortForwardConfig 端口转发（隧道）配置
+type PortForwardConfig struct {
+	Enable        bool `mapstructure:"enable"`        // 是否允许SSH网关端口转发（ssh -L/-D）与Web隧道
+	DialTimeout   int  `mapstructure:"dialTimeout"`   // 连接目标端口超时时间，秒
+	MaxWebTunnels int  `mapstructure:"maxWebTunnels"` // 每个Web会话最多可打开的隧道数
+}
+
+// DatabaseProxyConfig 数据库协议代理配置
+type DatabaseProxyConfig struct {
+	Enable         bool   `mapstructure:"enable"`
+	Host           string `mapstructure:"host"`
+	AdvertiseHost  string `mapstructure:"advertiseHost"` // 提供给数据库客户端的代理地址，为空时使用 host
+	MySQLPort      int    `mapstructure:"mysqlPort"`
+	PostgreSQLPort int    `mapstructure:"postgresqlPort"`
+	RedisPort      int    `mapstructure:"redisPort"`
+	TokenTTL       int    `mapstructure:"tokenTTL"`      // 连接令牌有效期，秒
+	DialTimeout    int    `mapstructure:"dialTimeout"`   // 连接数据库超时时间，秒
+	ServerVersion  string `mapstructure:"serverVersion"` // MySQL代理握手时报告的服务器版本
+
+	RedisRedact []RedisRedactRule `mapstructure:"redisRedact"` // Redis命令审计的参数脱敏规则，为空时使用内置规则
+}
+
+// RedisRedactRule Redis命令审计的参数脱敏规则
+type RedisRedactRule struct {
+	Command string `mapstructure:"command"` // 命令名，带子命令时用空格分隔，如 "CONFIG SET"
+	From    int    `mapstructure:"from"`    // 从命令名之后第几个参数开始脱敏，从1计数
+	Step    int    `mapstructure:"step"`    // 脱敏参数的间隔，如MSET的值为2，为0时连续
+	Count   int    `mapstructure:"count"`   // 脱敏的参数个数，为0时脱敏之后的全部参数
 }
 
 // SessionConfig 会话配置
@@ -98,9 +207,21 @@ type SessionConfig struct {
 
 // SecurityConfig 安全配置
 type SecurityConfig struct {
-	EnableRateLimit bool      `mapstructure:"enableRateLimit"`
-	RateLimit       RateLimit `mapstructure:"rateLimit"`
-	CORS            CORS      `mapstructure:"cors"`
+	EnableRateLimit bool               `mapstructure:"enableRateLimit"`
+	RateLimit       RateLimit          `mapstructure:"rateLimit"`
+	CORS            CORS               `mapstructure:"cors"`
+	MFA             MFAConfig          `mapstructure:"mfa"`
+	LoginLockout    LoginLockoutConfig `mapstructure:"loginLockout"`
+	LoginSession    LoginSessionConfig `mapstructure:"loginSession"`
+
+	TrustedProxies []string `mapstructure:"trustedProxies"` // 可信反向代理的地址或网段，仅对来自这些地址的请求采用 X-Forwarded-For/X-Real-IP
+}
+
+// MFAConfig 多因素认证配置（是否强制由角色的 mfa_required 决定）
+type MFAConfig struct {
+	Issuer        string `mapstructure:"issuer"`        // 认证器App中显示的发行方名称
+	PreAuthExpire int    `mapstructure:"preAuthExpire"` // 预认证令牌有效期，秒
+	MaxAttempts   int    `mapstructure:"maxAttempts"`   // 单个预认证令牌允许的验证失败次数
 }
 
 // RateLimit 限流配置
@@ -109,6 +230,22 @@ type RateLimit struct {
 	Burst    int `mapstructure:"burst"`
 }
 
+// LoginLockoutConfig 登录失败锁定配置
+type LoginLockoutConfig struct {
+	Enable        bool `mapstructure:"enable"`
+	MaxAttempts   int  `mapstructure:"maxAttempts"`   // 同一用户名在窗口内的失败次数上限，达到后锁定账号
+	IPMaxAttempts int  `mapstructure:"ipMaxAttempts"` // 同一IP在窗口内的失败次数上限，达到后锁定该IP
+	Window        int  `mapstructure:"window"`        // 失败计数窗口，秒
+	LockDuration  int  `mapstructure:"lockDuration"`  // 锁定时长，秒
+	DelayAfter    int  `mapstructure:"delayAfter"`    // 连续失败超过该次数后，每次失败的等待时间翻倍
+	MaxDelay      int  `mapstructure:"maxDelay"`      // 最长等待时间，秒
+}
+
+// LoginSessionConfig Web登录会话配置
+type LoginSessionConfig struct {
+	MaxConcurrent int `mapstructure:"maxConcurrent"` // 每个用户同时有效的登录会话数上限，超出时踢出最早的会话，0 表示不限制
+}
+
 // CORS 跨域配置
 type CORS struct {
 	AllowOrigins     []string `mapstructure:"allowOrigins"`
@@ -142,6 +279,48 @@ type AuditConfig struct {
 	DangerousCommands   []string `mapstructure:"dangerousCommands"`
 }
 
+// AccessRequestConfig 临时访问申请配置
+type AccessRequestConfig struct {
+	ApproverRoles     []string `mapstructure:"approverRoles"`     // 可以审批申请的角色
+	RequiredApprovals int      `mapstructure:"requiredApprovals"` // 申请生效需要的批准人数
+	MaxDuration       int      `mapstructure:"maxDuration"`       // 单次申请的最长访问时长，秒
+	CheckInterval     int      `mapstructure:"checkInterval"`     // 到期检查间隔，秒
+}
+
+// CredentialRotationConfig 凭证自动改密配置
+type CredentialRotationConfig struct {
+	CheckInterval  int `mapstructure:"checkInterval"`  // 到期策略检查间隔，秒
+	PasswordLength int `mapstructure:"passwordLength"` // 策略未指定时的新密码长度
+	CommandTimeout int `mapstructure:"commandTimeout"` // 单台资产登录及改密命令的超时时间，秒
+}
+
+// CredentialCheckoutConfig 凭证借出配置
+type CredentialCheckoutConfig struct {
+	RequireApproval bool     `mapstructure:"requireApproval"` // 借出是否需要审批
+	ApproverRoles   []string `mapstructure:"approverRoles"`   // 可以审批借出申请的角色
+	DefaultDuration int      `mapstructure:"defaultDuration"` // 申请未指定时的借出时长，秒
+	MaxDuration     int      `mapstructure:"maxDuration"`     // 单次借出的最长时长，秒
+	ApprovalTimeout int      `mapstructure:"approvalTimeout"` // 申请超过该时长未审批则过期，秒
+	CheckInterval   int      `mapstructure:"checkInterval"`   // 到期检查间隔，秒
+}
+
+// SecretStoreConfig 凭证加密主密钥后端配置
+type SecretStoreConfig struct {
+	Backend string            `mapstructure:"backend"` // file / vault
+	KeyFile string            `mapstructure:"keyFile"` // file 后端的主密钥文件，不存在时自动生成
+	Vault   VaultSecretConfig `mapstructure:"vault"`
+}
+
+// VaultSecretConfig Vault Transit 后端配置
+type VaultSecretConfig struct {
+	Address   string `mapstructure:"address"`   // Vault 地址
+	Token     string `mapstructure:"token"`     // 访问令牌，为空时读取环境变量 VAULT_TOKEN
+	Namespace string `mapstructure:"namespace"` // 企业版命名空间，可选
+	Mount     string `mapstructure:"mount"`     // Transit 引擎挂载路径
+	KeyName   string `mapstructure:"keyName"`   // Transit 密钥名称
+	Timeout   int    `mapstructure:"timeout"`   // 请求超时，秒
+}
+
 // WebSocketConfig WebSocket配置
 type WebSocketConfig struct {
 	Enable            bool `mapstructure:"enable"`
@@ -197,6 +376,16 @@ func (c *RedisConfig) GetRedisAddr() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
 
+// GetListenAddr 获取SSH网关监听地址
+func (c *SSHGatewayConfig) GetListenAddr() string {
+	return fmt.Sprintf("%s:%d", c.Host, c.Port)
+}
+
+// GetListenAddr 获取数据库代理的监听地址
+func (c *DatabaseProxyConfig) GetListenAddr(port int) string {
+	return fmt.Sprintf("%s:%d", c.Host, port)
+}
+
 // GetServerAddr 获取服务器监听地址
 func (c *AppConfig) GetServerAddr() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
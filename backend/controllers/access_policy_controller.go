@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessPolicyController 访问策略控制器
+type AccessPolicyController struct {
+	policyService *services.AccessPolicyService
+}
+
+// NewAccessPolicyController 创建访问策略控制器实例
+func NewAccessPolicyController(policyService *services.AccessPolicyService) *AccessPolicyController {
+	return &AccessPolicyController{policyService: policyService}
+}
+
+// CreatePolicy 创建访问策略
+// @Summary      创建访问策略
+// @Description  按星期、时间段和客户端地址段允许或拒绝登录与建立会话，可关联用户、角色和资产
+// @Tags         访问策略
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.AccessPolicyCreateRequest true "访问策略创建请求"
+// @Success      200  {object}  map[string]interface{}  "创建成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误"
+// @Failure      409  {object}  map[string]interface{}  "策略名称已存在"
+// @Router       /access-policies [post]
+func (pc *AccessPolicyController) CreatePolicy(c *gin.Context) {
+	var request models.AccessPolicyCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	policy, err := pc.policyService.CreatePolicy(&request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "访问策略")
+		return
+	}
+
+	utils.RespondWithData(c, policy)
+}
+
+// GetPolicies 获取访问策略列表
+// @Summary      获取访问策略列表
+// @Tags         访问策略
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page      query  int     false  "页码"
+// @Param        page_size query  int     false  "每页大小"
+// @Param        keyword   query  string  false  "搜索关键词"
+// @Param        scope     query  string  false  "作用范围"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /access-policies [get]
+func (pc *AccessPolicyController) GetPolicies(c *gin.Context) {
+	var request models.AccessPolicyListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid query parameters")
+		return
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 10
+	}
+
+	policies, total, err := pc.policyService.GetPolicies(&request)
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithPagination(c, policies, request.Page, request.PageSize, total)
+}
+
+// GetPolicy 获取访问策略详情
+// @Summary      获取访问策略详情
+// @Tags         访问策略
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "策略ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "策略不存在"
+// @Router       /access-policies/{id} [get]
+func (pc *AccessPolicyController) GetPolicy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid policy ID")
+		return
+	}
+
+	policy, err := pc.policyService.GetPolicy(uint(id))
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "访问策略")
+		return
+	}
+
+	utils.RespondWithData(c, policy)
+}
+
+// UpdatePolicy 更新访问策略
+// @Summary      更新访问策略
+// @Description  更新访问策略，未提供的条件和关联保持不变
+// @Tags         访问策略
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                               true  "策略ID"
+// @Param        request  body  models.AccessPolicyUpdateRequest  true  "访问策略更新请求"
+// @Success      200  {object}  map[string]interface{}  "更新成功"
+// @Router       /access-policies/{id} [put]
+func (pc *AccessPolicyController) UpdatePolicy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid policy ID")
+		return
+	}
+
+	var request models.AccessPolicyUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	policy, err := pc.policyService.UpdatePolicy(uint(id), &request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "访问策略")
+		return
+	}
+
+	utils.RespondWithData(c, policy)
+}
+
+// DeletePolicy 删除访问策略
+// @Summary      删除访问策略
+// @Tags         访问策略
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "策略ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Router       /access-policies/{id} [delete]
+func (pc *AccessPolicyController) DeletePolicy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid policy ID")
+		return
+	}
+
+	if err := pc.policyService.DeletePolicy(uint(id)); err != nil {
+		utils.RespondWithServiceError(c, err, "访问策略")
+		return
+	}
+
+	utils.RespondWithSuccess(c, "Access policy deleted successfully")
+}
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessRequestController 临时访问申请控制器
+type AccessRequestController struct {
+	requestService *services.AccessRequestService
+}
+
+// NewAccessRequestController 创建临时访问申请控制器实例
+func NewAccessRequestController(requestService *services.AccessRequestService) *AccessRequestController {
+	return &AccessRequestController{requestService: requestService}
+}
+
+// CreateRequest 提交临时访问申请
+// @Summary      提交临时访问申请
+// @Description  申请在指定时间窗口内访问资产或资产分组，审批通过后自动授权，到期自动收回
+// @Tags         临时访问申请
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.AccessRequestCreateRequest true "申请内容"
+// @Success      200  {object}  map[string]interface{}  "提交成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误"
+// @Router       /access-requests [post]
+func (ac *AccessRequestController) CreateRequest(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var request models.AccessRequestCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	result, err := ac.requestService.CreateRequest(user, &request, c.ClientIP())
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "临时访问申请")
+		return
+	}
+
+	utils.RespondWithData(c, result)
+}
+
+// GetMyRequests 获取我的临时访问申请
+// @Summary      获取我的临时访问申请
+// @Tags         临时访问申请
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page      query  int     false  "页码"
+// @Param        page_size query  int     false  "每页大小"
+// @Param        status    query  string  false  "状态"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /access-requests [get]
+func (ac *AccessRequestController) GetMyRequests(c *gin.Context) {
+	request, ok := bindAccessRequestList(c)
+	if !ok {
+		return
+	}
+
+	requests, total, err := ac.requestService.GetMyRequests(c.GetUint("user_id"), request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "临时访问申请")
+		return
+	}
+
+	utils.RespondWithPagination(c, requests, request.Page, request.PageSize, total)
+}
+
+// GetApprovalRequests 审批人查看临时访问申请
+// @Summary      获取待审批的临时访问申请
+// @Description  仅审批角色可用，可按状态和申请人过滤
+// @Tags         临时访问申请
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page      query  int     false  "页码"
+// @Param        page_size query  int     false  "每页大小"
+// @Param        status    query  string  false  "状态"
+// @Param        user_id   query  int     false  "申请人ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      403  {object}  map[string]interface{}  "不是审批人"
+// @Router       /access-requests/approvals [get]
+func (ac *AccessRequestController) GetApprovalRequests(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	request, ok := bindAccessRequestList(c)
+	if !ok {
+		return
+	}
+
+	requests, total, err := ac.requestService.GetApprovalRequests(user, request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "临时访问申请")
+		return
+	}
+
+	utils.RespondWithPagination(c, requests, request.Page, request.PageSize, total)
+}
+
+// GetRequest 获取临时访问申请详情
+// @Summary      获取临时访问申请详情
+// @Tags         临时访问申请
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "申请ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "申请不存在"
+// @Router       /access-requests/{id} [get]
+func (ac *AccessRequestController) GetRequest(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid request ID")
+		return
+	}
+
+	result, err := ac.requestService.GetRequest(user, uint(id))
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "临时访问申请")
+		return
+	}
+
+	utils.RespondWithData(c, result)
+}
+
+// ApproveRequest 批准临时访问申请
+// @Summary      批准临时访问申请
+// @Description  批准人数达到要求后生成临时授权，申请人不能审批自己的申请
+// @Tags         临时访问申请
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                                  true   "申请ID"
+// @Param        request  body  models.AccessRequestDecisionRequest  false  "审批意见"
+// @Success      200  {object}  map[string]interface{}  "批准成功"
+// @Failure      400  {object}  map[string]interface{}  "申请已结束"
+// @Failure      403  {object}  map[string]interface{}  "不是审批人"
+// @Failure      409  {object}  map[string]interface{}  "已审批过该申请"
+// @Router       /access-requests/{id}/approve [post]
+func (ac *AccessRequestController) ApproveRequest(c *gin.Context) {
+	ac.decide(c, ac.requestService.ApproveRequest)
+}
+
+// RejectRequest 拒绝临时访问申请
+// @Summary      拒绝临时访问申请
+// @Tags         临时访问申请
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                                  true   "申请ID"
+// @Param        request  body  models.AccessRequestDecisionRequest  false  "审批意见"
+// @Success      200  {object}  map[string]interface{}  "拒绝成功"
+// @Failure      400  {object}  map[string]interface{}  "申请已结束"
+// @Failure      403  {object}  map[string]interface{}  "不是审批人"
+// @Router       /access-requests/{id}/reject [post]
+func (ac *AccessRequestController) RejectRequest(c *gin.Context) {
+	ac.decide(c, ac.requestService.RejectRequest)
+}
+
+// CancelRequest 撤回临时访问申请
+// @Summary      撤回临时访问申请
+// @Description  已生效的授权立即收回，相关会话被关闭
+// @Tags         临时访问申请
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "申请ID"
+// @Success      200  {object}  map[string]interface{}  "撤回成功"
+// @Failure      400  {object}  map[string]interface{}  "申请已结束"
+// @Failure      404  {object}  map[string]interface{}  "申请不存在"
+// @Router       /access-requests/{id}/cancel [post]
+func (ac *AccessRequestController) CancelRequest(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid request ID")
+		return
+	}
+
+	result, err := ac.requestService.CancelRequest(user, uint(id), c.ClientIP())
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "临时访问申请")
+		return
+	}
+
+	utils.RespondWithData(c, result)
+}
+
+// decide 处理批准或拒绝
+func (ac *AccessRequestController) decide(c *gin.Context, action func(*models.User, uint, string, string) (*models.AccessRequestResponse, error)) {
+	user := c.MustGet("user").(*models.User)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid request ID")
+		return
+	}
+
+	var request models.AccessRequestDecisionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			utils.RespondWithValidationError(c, "Invalid request format")
+			return
+		}
+	}
+
+	result, err := action(user, uint(id), request.Comment, c.ClientIP())
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "临时访问申请")
+		return
+	}
+
+	utils.RespondWithData(c, result)
+}
+
+
+// bindAccessRequestList 解析列表查询参数
+func bindAccessRequestList(c *gin.Context) (*models.AccessRequestListRequest, bool) {
+	var request models.AccessRequestListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid query parameters")
+		return nil, false
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 10
+	}
+	return &request, true
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APITokenController 个人API令牌控制器
+type APITokenController struct {
+	tokenService *services.APITokenService
+}
+
+// NewAPITokenController 创建个人API令牌控制器实例
+func NewAPITokenController(tokenService *services.APITokenService) *APITokenController {
+	return &APITokenController{tokenService: tokenService}
+}
+
+// GetTokens 获取当前用户的API令牌
+// @Summary      获取API令牌列表
+// @Description  获取当前用户的个人API令牌，包含最后使用时间和IP
+// @Tags         认证
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /profile/api-tokens [get]
+func (tc *APITokenController) GetTokens(c *gin.Context) {
+	tokens, err := tc.tokenService.GetTokens(c.GetUint("user_id"))
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, tokens)
+}
+
+// CreateToken 创建API令牌
+// @Summary      创建API令牌
+// @Description  创建用于脚本和CI的个人API令牌，权限范围为当前用户权限的子集，明文令牌只返回一次
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.APITokenCreateRequest true "令牌信息"
+// @Success      200  {object}  models.APITokenCreateResponse  "创建成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误"
+// @Failure      403  {object}  map[string]interface{}  "不能使用API令牌创建API令牌"
+// @Router       /profile/api-tokens [post]
+func (tc *APITokenController) CreateToken(c *gin.Context) {
+	var request models.APITokenCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	user, _ := c.Get("user")
+	token, err := tc.tokenService.CreateToken(user.(*models.User), &request)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidParam) {
+			utils.RespondWithValidationError(c, err.Error())
+			return
+		}
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, token)
+}
+
+// RevokeToken 吊销当前用户的API令牌
+// @Summary      吊销API令牌
+// @Tags         认证
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "令牌ID"
+// @Success      200  {object}  map[string]interface{}  "吊销成功"
+// @Failure      404  {object}  map[string]interface{}  "令牌不存在"
+// @Router       /profile/api-tokens/{id} [delete]
+func (tc *APITokenController) RevokeToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid token ID")
+		return
+	}
+
+	tc.revoke(c, c.GetUint("user_id"), uint(id))
+}
+
+// GetUserTokens 管理员查看用户的API令牌
+// @Summary      获取用户API令牌
+// @Tags         用户管理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "用户ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /users/{id}/api-tokens [get]
+func (tc *APITokenController) GetUserTokens(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid user ID")
+		return
+	}
+
+	tokens, err := tc.tokenService.GetTokens(uint(userID))
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, tokens)
+}
+
+// RevokeUserToken 管理员吊销用户的API令牌，用于令牌泄露等情况
+// @Summary      吊销用户API令牌
+// @Tags         用户管理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path  int  true  "用户ID"
+// @Param        token_id  path  int  true  "令牌ID"
+// @Success      200  {object}  map[string]interface{}  "吊销成功"
+// @Failure      404  {object}  map[string]interface{}  "令牌不存在"
+// @Router       /users/{id}/api-tokens/{token_id} [delete]
+func (tc *APITokenController) RevokeUserToken(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid user ID")
+		return
+	}
+	tokenID, err := strconv.ParseUint(c.Param("token_id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid token ID")
+		return
+	}
+
+	tc.revoke(c, uint(userID), uint(tokenID))
+}
+
+// revoke 吊销令牌并返回结果
+func (tc *APITokenController) revoke(c *gin.Context, userID, tokenID uint) {
+	if err := tc.tokenService.RevokeToken(userID, tokenID); err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithNotFound(c, "API令牌")
+			return
+		}
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithSuccess(c, "API token revoked successfully")
+}
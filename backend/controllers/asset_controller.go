@@ -4,6 +4,7 @@ import (
 	"bastion/models"
 	"bastion/services"
 	"bastion/utils"
+	"errors"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -48,6 +49,10 @@ func (ac *AssetController) CreateAsset(c *gin.Context) {
 			utils.RespondWithConflict(c, err.Error())
 			return
 		}
+		if errors.Is(err, utils.ErrInvalidParam) {
+			utils.RespondWithValidationError(c, err.Error())
+			return
+		}
 		utils.RespondWithInternalError(c, "Failed to create asset")
 		return
 	}
@@ -88,8 +93,16 @@ func (ac *AssetController) GetAssets(c *gin.Context) {
 		request.PageSize = 10
 	}
 
-	// 调用资产服务
-	assets, total, err := ac.assetService.GetAssets(&request)
+	// 获取当前用户
+	userInterface, exists := c.Get("user")
+	if !exists {
+		utils.RespondWithUnauthorized(c, "User not found")
+		return
+	}
+	user := userInterface.(*models.User)
+
+	// 调用资产服务（按资产授权过滤）
+	assets, total, err := ac.assetService.GetAssets(user.ID, &request)
 	if err != nil {
 		utils.RespondWithInternalError(c, "Failed to get assets")
 		return
@@ -122,13 +135,25 @@ func (ac *AssetController) GetAsset(c *gin.Context) {
 		return
 	}
 
-	// 调用资产服务
-	asset, err := ac.assetService.GetAsset(uint(id))
+	// 获取当前用户
+	userInterface, exists := c.Get("user")
+	if !exists {
+		utils.RespondWithUnauthorized(c, "User not found")
+		return
+	}
+	user := userInterface.(*models.User)
+
+	// 调用资产服务（校验资产授权）
+	asset, err := ac.assetService.GetAsset(user.ID, uint(id))
 	if err != nil {
 		if err.Error() == "asset not found" {
 			utils.RespondWithNotFound(c, err.Error())
 			return
 		}
+		if errors.Is(err, utils.ErrPermissionDenied) {
+			utils.RespondWithForbidden(c, "Asset is not authorized")
+			return
+		}
 		utils.RespondWithInternalError(c, "Failed to get asset")
 		return
 	}
@@ -176,6 +201,10 @@ func (ac *AssetController) UpdateAsset(c *gin.Context) {
 		case "asset name already exists":
 			utils.RespondWithConflict(c, err.Error())
 		default:
+			if errors.Is(err, utils.ErrInvalidParam) {
+				utils.RespondWithValidationError(c, err.Error())
+				return
+			}
 			utils.RespondWithInternalError(c, "Failed to update asset")
 		}
 		return
@@ -197,6 +226,7 @@ func (ac *AssetController) UpdateAsset(c *gin.Context) {
 // @Failure      401  {object}  map[string]interface{}  "未授权"
 // @Failure      403  {object}  map[string]interface{}  "权限不足"
 // @Failure      404  {object}  map[string]interface{}  "资产不存在"
+// @Failure      409  {object}  map[string]interface{}  "资产仍被用作网关"
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /assets/{id} [delete]
 func (ac *AssetController) DeleteAsset(c *gin.Context) {
@@ -214,6 +244,10 @@ func (ac *AssetController) DeleteAsset(c *gin.Context) {
 			utils.RespondWithNotFound(c, err.Error())
 			return
 		}
+		if err.Error() == "asset is used as a gateway" {
+			utils.RespondWithConflict(c, err.Error())
+			return
+		}
 		utils.RespondWithInternalError(c, "Failed to delete asset")
 		return
 	}
@@ -441,9 +475,21 @@ func (ac *AssetController) TestConnection(c *gin.Context) {
 		return
 	}
 
-	// 调用资产服务
-	result, err := ac.assetService.TestConnection(&request)
+	// 获取当前用户
+	userInterface, exists := c.Get("user")
+	if !exists {
+		utils.RespondWithUnauthorized(c, "User not found")
+		return
+	}
+	user := userInterface.(*models.User)
+
+	// 调用资产服务（校验资产与凭证授权）
+	result, err := ac.assetService.TestConnection(user.ID, &request)
 	if err != nil {
+		if errors.Is(err, utils.ErrPermissionDenied) {
+			utils.RespondWithForbidden(c, "Asset or credential is not authorized")
+			return
+		}
 		switch err.Error() {
 		case "asset not found", "credential not found":
 			utils.RespondWithNotFound(c, err.Error())
@@ -658,8 +704,16 @@ func (ac *AssetController) GetAssetGroupsWithHosts(c *gin.Context) {
 		return
 	}
 
-	// 调用资产服务
-	groups, err := ac.assetService.GetAssetGroupsWithHosts(assetType)
+	// 获取当前用户
+	userInterface, exists := c.Get("user")
+	if !exists {
+		utils.RespondWithUnauthorized(c, "User not found")
+		return
+	}
+	user := userInterface.(*models.User)
+
+	// 调用资产服务（按资产授权过滤）
+	groups, err := ac.assetService.GetAssetGroupsWithHosts(user.ID, assetType)
 	if err != nil {
 		utils.RespondWithInternalError(c, "获取资产分组列表失败")
 		return
@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AssetPermissionController 资产授权控制器
+type AssetPermissionController struct {
+	permissionService *services.AssetPermissionService
+}
+
+// NewAssetPermissionController 创建资产授权控制器实例
+func NewAssetPermissionController(permissionService *services.AssetPermissionService) *AssetPermissionController {
+	return &AssetPermissionController{permissionService: permissionService}
+}
+
+// ======================== 用户组 ========================
+
+// CreateUserGroup 创建用户组
+// @Summary      创建用户组
+// @Description  创建用户组并设置成员
+// @Tags         资产授权
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.UserGroupCreateRequest true "用户组创建请求"
+// @Success      200  {object}  map[string]interface{}  "创建成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误"
+// @Failure      409  {object}  map[string]interface{}  "用户组名称已存在"
+// @Router       /user-groups [post]
+func (pc *AssetPermissionController) CreateUserGroup(c *gin.Context) {
+	var request models.UserGroupCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	group, err := pc.permissionService.CreateUserGroup(&request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "用户组")
+		return
+	}
+
+	utils.RespondWithData(c, group)
+}
+
+// GetUserGroups 获取用户组列表
+// @Summary      获取用户组列表
+// @Description  获取用户组列表，支持分页和搜索
+// @Tags         资产授权
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page      query  int     false  "页码"
+// @Param        page_size query  int     false  "每页大小"
+// @Param        keyword   query  string  false  "搜索关键词"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /user-groups [get]
+func (pc *AssetPermissionController) GetUserGroups(c *gin.Context) {
+	var request models.UserGroupListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid query parameters")
+		return
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 10
+	}
+
+	groups, total, err := pc.permissionService.GetUserGroups(&request)
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithPagination(c, groups, request.Page, request.PageSize, total)
+}
+
+// GetUserGroup 获取用户组详情
+// @Summary      获取用户组详情
+// @Tags         资产授权
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "用户组ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "用户组不存在"
+// @Router       /user-groups/{id} [get]
+func (pc *AssetPermissionController) GetUserGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid user group ID")
+		return
+	}
+
+	group, err := pc.permissionService.GetUserGroup(uint(id))
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "用户组")
+		return
+	}
+
+	utils.RespondWithData(c, group)
+}
+
+// UpdateUserGroup 更新用户组
+// @Summary      更新用户组
+// @Tags         资产授权
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                           true  "用户组ID"
+// @Param        request  body  models.UserGroupUpdateRequest true  "用户组更新请求"
+// @Success      200  {object}  map[string]interface{}  "更新成功"
+// @Router       /user-groups/{id} [put]
+func (pc *AssetPermissionController) UpdateUserGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid user group ID")
+		return
+	}
+
+	var request models.UserGroupUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	group, err := pc.permissionService.UpdateUserGroup(uint(id), &request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "用户组")
+		return
+	}
+
+	utils.RespondWithData(c, group)
+}
+
+// DeleteUserGroup 删除用户组
+// @Summary      删除用户组
+// @Tags         资产授权
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "用户组ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Router       /user-groups/{id} [delete]
+func (pc *AssetPermissionController) DeleteUserGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid user group ID")
+		return
+	}
+
+	if err := pc.permissionService.DeleteUserGroup(uint(id)); err != nil {
+		utils.RespondWithServiceError(c, err, "用户组")
+		return
+	}
+
+	utils.RespondWithSuccess(c, "User group deleted successfully")
+}
+
+// ======================== 资产授权规则 ========================
+
+// CreateAssetPermission 创建资产授权规则
+// @Summary      创建资产授权规则
+// @Description  将资产或资产分组及可用凭证授权给用户、角色或用户组
+// @Tags         资产授权
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.AssetPermissionCreateRequest true "授权规则创建请求"
+// @Success      200  {object}  map[string]interface{}  "创建成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误"
+// @Failure      409  {object}  map[string]interface{}  "授权规则名称已存在"
+// @Router       /asset-permissions [post]
+func (pc *AssetPermissionController) CreateAssetPermission(c *gin.Context) {
+	var request models.AssetPermissionCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	permission, err := pc.permissionService.CreatePermission(&request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "授权规则")
+		return
+	}
+
+	utils.RespondWithData(c, permission)
+}
+
+// GetAssetPermissions 获取资产授权规则列表
+// @Summary      获取资产授权规则列表
+// @Tags         资产授权
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page      query  int     false  "页码"
+// @Param        page_size query  int     false  "每页大小"
+// @Param        keyword   query  string  false  "搜索关键词"
+// @Param        enabled   query  bool    false  "是否启用"
+// @Param        user_id   query  int     false  "直接授权的用户ID"
+// @Param        asset_id  query  int     false  "资产ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /asset-permissions [get]
+func (pc *AssetPermissionController) GetAssetPermissions(c *gin.Context) {
+	var request models.AssetPermissionListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid query parameters")
+		return
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 10
+	}
+
+	permissions, total, err := pc.permissionService.GetPermissions(&request)
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithPagination(c, permissions, request.Page, request.PageSize, total)
+}
+
+// GetAssetPermission 获取资产授权规则详情
+// @Summary      获取资产授权规则详情
+// @Tags         资产授权
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "授权规则ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "授权规则不存在"
+// @Router       /asset-permissions/{id} [get]
+func (pc *AssetPermissionController) GetAssetPermission(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid permission ID")
+		return
+	}
+
+	permission, err := pc.permissionService.GetPermission(uint(id))
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "授权规则")
+		return
+	}
+
+	utils.RespondWithData(c, permission)
+}
+
+// UpdateAssetPermission 更新资产授权规则
+// @Summary      更新资产授权规则
+// @Description  更新授权规则，未提供的关联保持不变
+// @Tags         资产授权
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                                 true  "授权规则ID"
+// @Param        request  body  models.AssetPermissionUpdateRequest true  "授权规则更新请求"
+// @Success      200  {object}  map[string]interface{}  "更新成功"
+// @Router       /asset-permissions/{id} [put]
+func (pc *AssetPermissionController) UpdateAssetPermission(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid permission ID")
+		return
+	}
+
+	var request models.AssetPermissionUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	permission, err := pc.permissionService.UpdatePermission(uint(id), &request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "授权规则")
+		return
+	}
+
+	utils.RespondWithData(c, permission)
+}
+
+// DeleteAssetPermission 删除资产授权规则
+// @Summary      删除资产授权规则
+// @Tags         资产授权
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "授权规则ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Router       /asset-permissions/{id} [delete]
+func (pc *AssetPermissionController) DeleteAssetPermission(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid permission ID")
+		return
+	}
+
+	if err := pc.permissionService.DeletePermission(uint(id)); err != nil {
+		utils.RespondWithServiceError(c, err, "授权规则")
+		return
+	}
+
+	utils.RespondWithSuccess(c, "Asset permission deleted successfully")
+}
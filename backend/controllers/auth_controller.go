@@ -4,6 +4,10 @@ import (
 	"bastion/models"
 	"bastion/services"
 	"bastion/utils"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -30,9 +34,10 @@ func NewAuthController(authService *services.AuthService) *AuthController {
 // @Accept       json
 // @Produce      json
 // @Param        request body models.UserLoginRequest true "登录请求"
-// @Success      200  {object}  models.DataResponse  "登录成功，返回用户信息和token"
+// @Success      200  {object}  models.DataResponse  "登录成功，返回token；需要二次验证时返回预认证令牌"
 // @Failure      400  {object}  models.ErrorResponse  "请求格式错误"
 // @Failure      401  {object}  models.ErrorResponse  "用户名或密码错误"
+// @Failure      429  {object}  models.ErrorResponse  "失败次数过多，账号或IP已锁定"
 // @Router       /auth/login [post]
 func (ac *AuthController) Login(c *gin.Context) {
 	var request models.UserLoginRequest
@@ -41,44 +46,67 @@ func (ac *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	// 获取客户端信息
-	clientIP := c.ClientIP()
+	// 获取客户端信息，访问策略按该地址匹配
+	clientIP := utils.GetClientIP(c.Request)
 	userAgent := c.GetHeader("User-Agent")
 
 	// 调用认证服务
-	token, err := ac.authService.Login(&request)
+	result, err := ac.authService.Login(&request, clientIP, userAgent)
 	if err != nil {
-		// 记录登录失败日志
+		// 记录登录失败日志，被锁定、限速或被访问策略拒绝时记录具体原因
 		go ac.auditService.RecordLoginLog(
 			0, // 登录失败时没有用户ID
 			request.Username,
 			clientIP,
 			userAgent,
 			"web",
-			"failed",
+			services.LoginFailureStatus(err),
 			err.Error(),
 		)
 
+		var blocked *services.LoginBlockedError
+		if errors.As(err, &blocked) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+			utils.RespondWithError(c, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		var violation *services.AccessPolicyViolation
+		if errors.As(err, &violation) {
+			utils.RespondWithForbidden(c, err.Error())
+			return
+		}
 		utils.RespondWithUnauthorized(c, err.Error())
 		return
 	}
 
-	// 获取用户信息
-	var user models.User
-	if err := utils.GetDB().Where("username = ?", request.Username).First(&user).Error; err == nil {
-		// 记录登录成功日志
+	// 需要二次验证时返回预认证令牌
+	if result.Challenge != nil {
 		go ac.auditService.RecordLoginLog(
-			user.ID,
-			user.Username,
+			result.User.ID,
+			result.User.Username,
 			clientIP,
 			userAgent,
 			"web",
-			"success",
-			"Login successful",
+			models.LoginStatusMFAChallenge,
+			"Password verified, waiting for MFA",
 		)
+
+		utils.RespondWithData(c, result.Challenge)
+		return
 	}
 
-	utils.RespondWithData(c, token)
+	// 记录登录成功日志
+	go ac.auditService.RecordLoginLog(
+		result.User.ID,
+		result.User.Username,
+		clientIP,
+		userAgent,
+		"web",
+		"success",
+		"Login successful",
+	)
+
+	utils.RespondWithData(c, result.Token)
 }
 
 // Logout 用户登出
@@ -231,6 +259,7 @@ func (ac *AuthController) UpdateProfile(c *gin.Context) {
 }
 
 // ChangePassword 修改密码
+// 密码过期的用户使用受限令牌修改成功后，返回新的访问令牌
 func (ac *AuthController) ChangePassword(c *gin.Context) {
 	// 从上下文获取用户ID
 	userID, exists := c.Get("user_id")
@@ -251,6 +280,17 @@ func (ac *AuthController) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	// 密码过期登录时使用的是受限令牌，修改成功后换发正常的访问令牌
+	if c.GetString("token_purpose") == utils.TokenPurposePasswordChange {
+		token, err := ac.authService.ExchangePasswordChangeToken(c.GetString("token"), utils.GetClientIP(c.Request), c.GetHeader("User-Agent"))
+		if err != nil {
+			utils.RespondWithInternalError(c, err.Error())
+			return
+		}
+		utils.RespondWithData(c, token)
+		return
+	}
+
 	utils.RespondWithSuccess(c, "Password changed successfully")
 }
 
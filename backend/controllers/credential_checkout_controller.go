@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CredentialCheckoutController 凭证借出控制器
+type CredentialCheckoutController struct {
+	checkoutService *services.CredentialCheckoutService
+}
+
+// NewCredentialCheckoutController 创建凭证借出控制器实例
+func NewCredentialCheckoutController(checkoutService *services.CredentialCheckoutService) *CredentialCheckoutController {
+	return &CredentialCheckoutController{checkoutService: checkoutService}
+}
+
+// CreateCheckout 申请借出凭证
+// @Summary      申请借出凭证
+// @Description  借出后可在有效期内查看凭证明文，按配置需要审批；仅支持可自动改密的凭证，归还或到期后自动改密
+// @Tags         凭证借出
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.CredentialCheckoutCreateRequest true "申请内容"
+// @Success      200  {object}  map[string]interface{}  "提交成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误或凭证不支持借出"
+// @Failure      403  {object}  map[string]interface{}  "无权使用该凭证"
+// @Failure      409  {object}  map[string]interface{}  "已有进行中的借出或凭证已借出给其他用户"
+// @Router       /credential-checkouts [post]
+func (cc *CredentialCheckoutController) CreateCheckout(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	var request models.CredentialCheckoutCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	result, err := cc.checkoutService.CreateCheckout(user, &request, c.ClientIP())
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "凭证借出记录")
+		return
+	}
+
+	utils.RespondWithData(c, result)
+}
+
+// GetMyCheckouts 获取我的凭证借出记录
+// @Summary      获取我的凭证借出记录
+// @Tags         凭证借出
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page           query  int     false  "页码"
+// @Param        page_size      query  int     false  "每页大小"
+// @Param        status         query  string  false  "状态"
+// @Param        credential_id  query  int     false  "凭证ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /credential-checkouts [get]
+func (cc *CredentialCheckoutController) GetMyCheckouts(c *gin.Context) {
+	request, ok := bindCredentialCheckoutList(c)
+	if !ok {
+		return
+	}
+
+	checkouts, total, err := cc.checkoutService.GetMyCheckouts(c.GetUint("user_id"), request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "凭证借出记录")
+		return
+	}
+
+	utils.RespondWithPagination(c, checkouts, request.Page, request.PageSize, total)
+}
+
+// GetApprovalCheckouts 审批人查看凭证借出记录
+// @Summary      获取待审批的凭证借出申请
+// @Description  仅审批角色可用，可按状态、凭证和借出人过滤
+// @Tags         凭证借出
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page           query  int     false  "页码"
+// @Param        page_size      query  int     false  "每页大小"
+// @Param        status         query  string  false  "状态"
+// @Param        credential_id  query  int     false  "凭证ID"
+// @Param        user_id        query  int     false  "借出人ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      403  {object}  map[string]interface{}  "不是审批人"
+// @Router       /credential-checkouts/approvals [get]
+func (cc *CredentialCheckoutController) GetApprovalCheckouts(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	request, ok := bindCredentialCheckoutList(c)
+	if !ok {
+		return
+	}
+
+	checkouts, total, err := cc.checkoutService.GetApprovalCheckouts(user, request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "凭证借出记录")
+		return
+	}
+
+	utils.RespondWithPagination(c, checkouts, request.Page, request.PageSize, total)
+}
+
+// GetCheckout 获取凭证借出详情
+// @Summary      获取凭证借出详情
+// @Tags         凭证借出
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "借出ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "借出记录不存在"
+// @Router       /credential-checkouts/{id} [get]
+func (cc *CredentialCheckoutController) GetCheckout(c *gin.Context) {
+	cc.handle(c, func(user *models.User, id uint) (interface{}, error) {
+		return cc.checkoutService.GetCheckout(user, id)
+	})
+}
+
+// ApproveCheckout 批准凭证借出
+// @Summary      批准凭证借出
+// @Description  批准后立即借出，申请人不能审批自己的申请；凭证已借出给其他用户时需等待归还
+// @Tags         凭证借出
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                                       true   "借出ID"
+// @Param        request  body  models.CredentialCheckoutDecisionRequest  false  "审批意见"
+// @Success      200  {object}  map[string]interface{}  "批准成功"
+// @Failure      400  {object}  map[string]interface{}  "申请已结束"
+// @Failure      403  {object}  map[string]interface{}  "不是审批人"
+// @Failure      409  {object}  map[string]interface{}  "凭证已借出给其他用户"
+// @Router       /credential-checkouts/{id}/approve [post]
+func (cc *CredentialCheckoutController) ApproveCheckout(c *gin.Context) {
+	cc.decide(c, cc.checkoutService.ApproveCheckout)
+}
+
+// RejectCheckout 拒绝凭证借出
+// @Summary      拒绝凭证借出
+// @Tags         凭证借出
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                                       true   "借出ID"
+// @Param        request  body  models.CredentialCheckoutDecisionRequest  false  "审批意见"
+// @Success      200  {object}  map[string]interface{}  "拒绝成功"
+// @Failure      400  {object}  map[string]interface{}  "申请已结束"
+// @Failure      403  {object}  map[string]interface{}  "不是审批人"
+// @Router       /credential-checkouts/{id}/reject [post]
+func (cc *CredentialCheckoutController) RejectCheckout(c *gin.Context) {
+	cc.decide(c, cc.checkoutService.RejectCheckout)
+}
+
+// CancelCheckout 撤回凭证借出申请
+// @Summary      撤回凭证借出申请
+// @Description  只能撤回待审批的申请，已借出的凭证需要归还
+// @Tags         凭证借出
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "借出ID"
+// @Success      200  {object}  map[string]interface{}  "撤回成功"
+// @Failure      400  {object}  map[string]interface{}  "申请不是待审批状态"
+// @Failure      404  {object}  map[string]interface{}  "借出记录不存在"
+// @Router       /credential-checkouts/{id}/cancel [post]
+func (cc *CredentialCheckoutController) CancelCheckout(c *gin.Context) {
+	cc.handle(c, func(user *models.User, id uint) (interface{}, error) {
+		return cc.checkoutService.CancelCheckout(user, id, c.ClientIP())
+	})
+}
+
+// CheckIn 归还凭证
+// @Summary      归还凭证
+// @Description  借出人或审批人归还凭证，借出期间查看过明文时在后台自动改密，结果见 rotation_status
+// @Tags         凭证借出
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "借出ID"
+// @Success      200  {object}  map[string]interface{}  "归还成功"
+// @Failure      400  {object}  map[string]interface{}  "凭证未处于借出状态"
+// @Failure      404  {object}  map[string]interface{}  "借出记录不存在"
+// @Router       /credential-checkouts/{id}/checkin [post]
+func (cc *CredentialCheckoutController) CheckIn(c *gin.Context) {
+	cc.handle(c, func(user *models.User, id uint) (interface{}, error) {
+		return cc.checkoutService.CheckIn(user, id, c.ClientIP())
+	})
+}
+
+// RevealSecret 查看借出凭证的明文
+// @Summary      查看借出凭证的明文
+// @Description  仅借出人在有效期内可以查看，每次查看都写入审计日志，审计日志写入失败时拒绝查看
+// @Tags         凭证借出
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "借出ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      400  {object}  map[string]interface{}  "凭证未借出或已到期"
+// @Failure      403  {object}  map[string]interface{}  "不是借出人"
+// @Failure      404  {object}  map[string]interface{}  "借出记录不存在"
+// @Router       /credential-checkouts/{id}/reveal [post]
+func (cc *CredentialCheckoutController) RevealSecret(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	cc.handle(c, func(user *models.User, id uint) (interface{}, error) {
+		return cc.checkoutService.RevealSecret(user, id, c.ClientIP())
+	})
+}
+
+// handle 解析借出ID并调用服务
+func (cc *CredentialCheckoutController) handle(c *gin.Context, action func(*models.User, uint) (interface{}, error)) {
+	user := c.MustGet("user").(*models.User)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid checkout ID")
+		return
+	}
+
+	result, err := action(user, uint(id))
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "凭证借出记录")
+		return
+	}
+
+	utils.RespondWithData(c, result)
+}
+
+// decide 处理批准或拒绝
+func (cc *CredentialCheckoutController) decide(c *gin.Context, action func(*models.User, uint, string, string) (*models.CredentialCheckoutResponse, error)) {
+	var request models.CredentialCheckoutDecisionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			utils.RespondWithValidationError(c, "Invalid request format")
+			return
+		}
+	}
+
+	cc.handle(c, func(user *models.User, id uint) (interface{}, error) {
+		return action(user, id, request.Comment, c.ClientIP())
+	})
+}
+
+
+// bindCredentialCheckoutList 解析列表查询参数
+func bindCredentialCheckoutList(c *gin.Context) (*models.CredentialCheckoutListRequest, bool) {
+	var request models.CredentialCheckoutListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid query parameters")
+		return nil, false
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 10
+	}
+	return &request, true
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CredentialRotationController 凭证自动改密控制器
+type CredentialRotationController struct {
+	rotationService *services.CredentialRotationService
+}
+
+// NewCredentialRotationController 创建凭证改密控制器实例
+func NewCredentialRotationController(rotationService *services.CredentialRotationService) *CredentialRotationController {
+	return &CredentialRotationController{rotationService: rotationService}
+}
+
+// GetPolicy 获取凭证改密策略
+// @Summary      获取凭证改密策略
+// @Tags         凭证改密
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "凭证ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "未设置改密策略"
+// @Router       /credentials/{id}/rotation-policy [get]
+func (rc *CredentialRotationController) GetPolicy(c *gin.Context) {
+	id, ok := parseCredentialID(c)
+	if !ok {
+		return
+	}
+
+	policy, err := rc.rotationService.GetPolicy(id)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "改密策略")
+		return
+	}
+
+	utils.RespondWithData(c, policy)
+}
+
+// SetPolicy 设置凭证改密策略
+// @Summary      设置凭证改密策略
+// @Description  仅支持关联资产均为Linux SSH主机的密码或密钥凭证，下次改密时间从上次改密（或现在）起算
+// @Tags         凭证改密
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                                     true  "凭证ID"
+// @Param        request  body  models.CredentialRotationPolicyRequest  true  "改密策略"
+// @Success      200  {object}  map[string]interface{}  "设置成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误或凭证不支持自动改密"
+// @Failure      404  {object}  map[string]interface{}  "凭证不存在"
+// @Router       /credentials/{id}/rotation-policy [put]
+func (rc *CredentialRotationController) SetPolicy(c *gin.Context) {
+	id, ok := parseCredentialID(c)
+	if !ok {
+		return
+	}
+
+	var request models.CredentialRotationPolicyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	policy, err := rc.rotationService.SetPolicy(id, &request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "凭证")
+		return
+	}
+
+	utils.RespondWithData(c, policy)
+}
+
+// DeletePolicy 删除凭证改密策略
+// @Summary      删除凭证改密策略
+// @Tags         凭证改密
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "凭证ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Failure      404  {object}  map[string]interface{}  "未设置改密策略"
+// @Router       /credentials/{id}/rotation-policy [delete]
+func (rc *CredentialRotationController) DeletePolicy(c *gin.Context) {
+	id, ok := parseCredentialID(c)
+	if !ok {
+		return
+	}
+
+	if err := rc.rotationService.DeletePolicy(id); err != nil {
+		utils.RespondWithServiceError(c, err, "改密策略")
+		return
+	}
+
+	utils.RespondWithSuccess(c, "Rotation policy deleted successfully")
+}
+
+// RotateNow 立即改密
+// @Summary      立即改密
+// @Description  登录凭证关联的所有资产修改密码，或把新公钥追加到 authorized_keys，新凭证验证通过后更新凭证；失败时已修改的资产恢复原密码或移除新公钥。
+// @Description  密钥凭证更新后删除各资产上的旧公钥，删除失败时 status 为 partial。改密已执行时返回改密记录，通过 status 和 results 判断结果
+// @Tags         凭证改密
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "凭证ID"
+// @Success      200  {object}  map[string]interface{}  "改密记录"
+// @Failure      400  {object}  map[string]interface{}  "凭证不支持自动改密"
+// @Failure      404  {object}  map[string]interface{}  "凭证不存在"
+// @Failure      409  {object}  map[string]interface{}  "凭证正在改密"
+// @Router       /credentials/{id}/rotate [post]
+func (rc *CredentialRotationController) RotateNow(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	id, ok := parseCredentialID(c)
+	if !ok {
+		return
+	}
+
+	history, err := rc.rotationService.RotateNow(id, user, c.ClientIP())
+	if history == nil {
+		utils.RespondWithServiceError(c, err, "凭证")
+		return
+	}
+
+	utils.RespondWithData(c, history)
+}
+
+// GetHistory 获取凭证改密记录
+// @Summary      获取凭证改密记录
+// @Tags         凭证改密
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id        path   int     true   "凭证ID"
+// @Param        page      query  int     false  "页码"
+// @Param        page_size query  int     false  "每页大小"
+// @Param        status    query  string  false  "结果"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /credentials/{id}/rotation-history [get]
+func (rc *CredentialRotationController) GetHistory(c *gin.Context) {
+	id, ok := parseCredentialID(c)
+	if !ok {
+		return
+	}
+
+	var request models.CredentialRotationHistoryRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid query parameters")
+		return
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 10
+	}
+
+	histories, total, err := rc.rotationService.GetHistory(id, &request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "凭证")
+		return
+	}
+
+	utils.RespondWithPagination(c, histories, request.Page, request.PageSize, total)
+}
+
+
+// parseCredentialID 解析路径中的凭证ID
+func parseCredentialID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid credential ID")
+		return 0, false
+	}
+	return uint(id), true
+}
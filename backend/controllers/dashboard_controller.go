@@ -34,7 +34,7 @@ func NewDashboardController(dashboardService *services.DashboardService) *Dashbo
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /dashboard/stats [get]
 func (dc *DashboardController) GetDashboardStats(c *gin.Context) {
-	userID := c.GetUint("userID")
+	userID := c.GetUint("user_id")
 	
 	// 获取用户信息判断是否管理员
 	userInterface, exists := c.Get("user")
@@ -67,7 +67,7 @@ func (dc *DashboardController) GetDashboardStats(c *gin.Context) {
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /dashboard/recent-logins [get]
 func (dc *DashboardController) GetRecentLogins(c *gin.Context) {
-	userID := c.GetUint("userID")
+	userID := c.GetUint("user_id")
 	
 	// 获取用户信息判断是否管理员
 	userInterface, exists := c.Get("user")
@@ -107,7 +107,7 @@ func (dc *DashboardController) GetRecentLogins(c *gin.Context) {
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /dashboard/host-distribution [get]
 func (dc *DashboardController) GetHostDistribution(c *gin.Context) {
-	userID := c.GetUint("userID")
+	userID := c.GetUint("user_id")
 	
 	// 获取用户信息判断是否管理员
 	userInterface, exists := c.Get("user")
@@ -203,7 +203,7 @@ func (dc *DashboardController) GetAuditSummary(c *gin.Context) {
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /dashboard/quick-access [get]
 func (dc *DashboardController) GetQuickAccess(c *gin.Context) {
-	userID := c.GetUint("userID")
+	userID := c.GetUint("user_id")
 	
 	limit := 5
 	if l := c.Query("limit"); l != "" {
@@ -233,7 +233,7 @@ func (dc *DashboardController) GetQuickAccess(c *gin.Context) {
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /dashboard [get]
 func (dc *DashboardController) GetCompleteDashboard(c *gin.Context) {
-	userID := c.GetUint("userID")
+	userID := c.GetUint("user_id")
 	
 	// 获取用户信息判断是否管理员
 	userInterface, exists := c.Get("user")
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DatabaseProxyController 数据库代理控制器
+type DatabaseProxyController struct {
+	proxyService  *services.DatabaseProxyService
+	filterService *services.SQLFilterService
+}
+
+// NewDatabaseProxyController 创建数据库代理控制器实例
+func NewDatabaseProxyController(proxyService *services.DatabaseProxyService, filterService *services.SQLFilterService) *DatabaseProxyController {
+	return &DatabaseProxyController{
+		proxyService:  proxyService,
+		filterService: filterService,
+	}
+}
+
+// IssueToken 申请数据库连接令牌
+// @Summary      申请数据库连接令牌
+// @Description  校验用户对资产和凭证的授权及访问策略后签发短期令牌。数据库客户端连接返回的代理地址，用户名为堡垒机用户名，密码为令牌；代理使用凭证登录数据库，客户端不会获得数据库账号密码
+// @Tags         数据库代理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  models.DatabaseTokenRequest  true  "目标资产与凭证"
+// @Success      200  {object}  map[string]interface{}  "签发成功"
+// @Failure      400  {object}  map[string]interface{}  "资产协议不支持代理或凭证未关联资产"
+// @Failure      403  {object}  map[string]interface{}  "没有使用该凭证访问此资产的权限"
+// @Failure      404  {object}  map[string]interface{}  "资产或凭证不存在"
+// @Router       /database/tokens [post]
+func (dc *DatabaseProxyController) IssueToken(c *gin.Context) {
+	var request models.DatabaseTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	token, err := dc.proxyService.IssueToken(c.GetUint("user_id"), c.ClientIP(), &request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "资产或凭证")
+		return
+	}
+
+	utils.RespondWithData(c, token)
+}
+
+// CreateFilterRule 创建SQL过滤规则
+// @Summary      创建SQL过滤规则
+// @Description  规则按优先级（数字越小越优先）匹配，命中的第一条规则决定动作：deny 拒绝执行，alert 放行并告警，allow 放行。match_type 为 statement 时 pattern 为逗号分隔的语句类型（如 DROP,TRUNCATE），为 no_where 时匹配不带WHERE条件的指定类型语句（如 DELETE,UPDATE），为 regex 时忽略大小写匹配整条语句
+// @Tags         数据库代理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  models.SQLFilterRuleCreateRequest  true  "SQL过滤规则"
+// @Success      200  {object}  map[string]interface{}  "创建成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误"
+// @Failure      409  {object}  map[string]interface{}  "规则名称已存在"
+// @Router       /sql-filters [post]
+func (dc *DatabaseProxyController) CreateFilterRule(c *gin.Context) {
+	var request models.SQLFilterRuleCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	rule, err := dc.filterService.CreateRule(&request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "SQL过滤规则")
+		return
+	}
+
+	utils.RespondWithData(c, rule)
+}
+
+// GetFilterRules 获取SQL过滤规则列表
+// @Summary      获取SQL过滤规则列表
+// @Tags         数据库代理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page       query  int     false  "页码"
+// @Param        page_size  query  int     false  "每页大小"
+// @Param        name       query  string  false  "规则名称"
+// @Param        action     query  string  false  "动作(deny/alert/allow)"
+// @Param        enabled    query  bool    false  "是否启用"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /sql-filters [get]
+func (dc *DatabaseProxyController) GetFilterRules(c *gin.Context) {
+	var request models.SQLFilterRuleListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid query parameters")
+		return
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 10
+	}
+
+	rules, total, err := dc.filterService.GetRules(&request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "SQL过滤规则")
+		return
+	}
+
+	utils.RespondWithPagination(c, rules, request.Page, request.PageSize, total)
+}
+
+// GetFilterRule 获取SQL过滤规则详情
+// @Summary      获取SQL过滤规则详情
+// @Tags         数据库代理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "规则ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "规则不存在"
+// @Router       /sql-filters/{id} [get]
+func (dc *DatabaseProxyController) GetFilterRule(c *gin.Context) {
+	id, ok := parseSQLFilterRuleID(c)
+	if !ok {
+		return
+	}
+
+	rule, err := dc.filterService.GetRule(id)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "SQL过滤规则")
+		return
+	}
+
+	utils.RespondWithData(c, rule)
+}
+
+// UpdateFilterRule 更新SQL过滤规则
+// @Summary      更新SQL过滤规则
+// @Description  asset_ids 传空数组表示规则对所有数据库资产生效
+// @Tags         数据库代理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                                true  "规则ID"
+// @Param        request  body  models.SQLFilterRuleUpdateRequest  true  "SQL过滤规则"
+// @Success      200  {object}  map[string]interface{}  "更新成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误"
+// @Failure      404  {object}  map[string]interface{}  "规则不存在"
+// @Failure      409  {object}  map[string]interface{}  "规则名称已存在"
+// @Router       /sql-filters/{id} [put]
+func (dc *DatabaseProxyController) UpdateFilterRule(c *gin.Context) {
+	id, ok := parseSQLFilterRuleID(c)
+	if !ok {
+		return
+	}
+
+	var request models.SQLFilterRuleUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	rule, err := dc.filterService.UpdateRule(id, &request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "SQL过滤规则")
+		return
+	}
+
+	utils.RespondWithData(c, rule)
+}
+
+// DeleteFilterRule 删除SQL过滤规则
+// @Summary      删除SQL过滤规则
+// @Tags         数据库代理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "规则ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Failure      404  {object}  map[string]interface{}  "规则不存在"
+// @Router       /sql-filters/{id} [delete]
+func (dc *DatabaseProxyController) DeleteFilterRule(c *gin.Context) {
+	id, ok := parseSQLFilterRuleID(c)
+	if !ok {
+		return
+	}
+
+	if err := dc.filterService.DeleteRule(id); err != nil {
+		utils.RespondWithServiceError(c, err, "SQL过滤规则")
+		return
+	}
+
+	utils.RespondWithSuccess(c, "SQL filter rule deleted successfully")
+}
+
+// GetSQLAuditLogs 获取SQL审计日志
+// @Summary      获取SQL审计日志
+// @Description  获取数据库代理上执行或被拒绝的语句记录，可按会话ID关联会话记录
+// @Tags         审计管理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page        query  int     false  "页码"
+// @Param        page_size   query  int     false  "每页数量"
+// @Param        session_id  query  string  false  "会话ID"
+// @Param        username    query  string  false  "用户名"
+// @Param        asset_id    query  uint    false  "资产ID"
+// @Param        protocol    query  string  false  "数据库协议"
+// @Param        action      query  string  false  "过滤动作(allow/alert/deny)"
+// @Param        status      query  string  false  "执行状态(success/error/denied)"
+// @Param        keyword     query  string  false  "语句关键字"
+// @Param        start_time  query  string  false  "开始时间"
+// @Param        end_time    query  string  false  "结束时间"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /audit/sql-logs [get]
+func (dc *DatabaseProxyController) GetSQLAuditLogs(c *gin.Context) {
+	var request models.SQLAuditLogListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid query parameters")
+		return
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 20
+	}
+
+	logs, total, err := dc.filterService.GetSQLAuditLogs(&request)
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithPagination(c, logs, request.Page, request.PageSize, total)
+}
+
+
+// parseSQLFilterRuleID 解析路径中的规则ID
+func parseSQLFilterRuleID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid rule ID")
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FileTransferController 文件传输控制器
+type FileTransferController struct {
+	transferService *services.FileTransferService
+}
+
+// NewFileTransferController 创建文件传输控制器实例
+func NewFileTransferController(transferService *services.FileTransferService) *FileTransferController {
+	return &FileTransferController{transferService: transferService}
+}
+
+// ListFiles 浏览会话所连资产的目录
+// @Summary      浏览资产目录
+// @Description  列出当前Web会话所连资产上的目录内容，未指定路径时使用登录账号家目录
+// @Tags         文件传输
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path   string  true   "会话ID"
+// @Param        path  query  string  false  "目录路径"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "会话或目录不存在"
+// @Router       /ssh/sessions/{id}/files [get]
+func (fc *FileTransferController) ListFiles(c *gin.Context) {
+	files, err := fc.transferService.ListFiles(c.GetUint("user_id"), c.Param("id"), c.Query("path"))
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "")
+		return
+	}
+
+	utils.RespondWithData(c, files)
+}
+
+// UploadFile 上传文件到会话所连资产
+// @Summary      上传文件
+// @Description  上传文件到当前Web会话所连资产，受授权规则和上传配置（大小、类型）限制
+// @Tags         文件传输
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id    path      string  true   "会话ID"
+// @Param        file  formData  file    true   "上传文件"
+// @Param        path  formData  string  false  "目标目录"
+// @Success      200  {object}  map[string]interface{}  "上传成功"
+// @Failure      400  {object}  map[string]interface{}  "文件类型或大小不符合要求"
+// @Failure      403  {object}  map[string]interface{}  "禁止上传"
+// @Router       /ssh/sessions/{id}/files/upload [post]
+func (fc *FileTransferController) UploadFile(c *gin.Context) {
+	// 限制请求体大小，预留1MB给表单其他字段
+	if maxSize := config.GlobalConfig.Upload.MaxSize; maxSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxSize+1)<<20)
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		utils.RespondWithValidationError(c, "File is required or exceeds the upload size limit")
+		return
+	}
+
+	result, err := fc.transferService.UploadFile(c.GetUint("user_id"), c.Param("id"), c.PostForm("path"), file)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "")
+		return
+	}
+
+	utils.RespondWithData(c, result)
+}
+
+// DownloadFile 从会话所连资产下载文件
+// @Summary      下载文件
+// @Description  从当前Web会话所连资产下载文件，受授权规则限制
+// @Tags         文件传输
+// @Produce      octet-stream
+// @Security     BearerAuth
+// @Param        id    path   string  true  "会话ID"
+// @Param        path  query  string  true  "文件路径"
+// @Success      200  {file}    file                    "文件内容"
+// @Failure      403  {object}  map[string]interface{}  "禁止下载"
+// @Failure      404  {object}  map[string]interface{}  "文件不存在"
+// @Router       /ssh/sessions/{id}/files/download [get]
+func (fc *FileTransferController) DownloadFile(c *gin.Context) {
+	download, err := fc.transferService.OpenDownload(c.GetUint("user_id"), c.Param("id"), c.Query("path"))
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "")
+		return
+	}
+	defer download.Close()
+
+	c.DataFromReader(http.StatusOK, download.Size, "application/octet-stream", download, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(download.Name)),
+	})
+}
+
+// GetTransferLogs 获取文件传输日志
+// @Summary      获取文件传输日志
+// @Description  获取SFTP与Web会话的文件上传下载记录，支持分页和条件过滤
+// @Tags         审计管理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page        query  int     false  "页码"
+// @Param        page_size   query  int     false  "每页数量"
+// @Param        session_id  query  string  false  "会话ID"
+// @Param        username    query  string  false  "用户名"
+// @Param        asset_id    query  uint    false  "资产ID"
+// @Param        direction   query  string  false  "传输方向(upload/download)"
+// @Param        channel     query  string  false  "传输通道(sftp/web)"
+// @Param        status      query  string  false  "传输状态(success/failed/denied)"
+// @Param        filename    query  string  false  "文件名"
+// @Param        start_time  query  string  false  "开始时间"
+// @Param        end_time    query  string  false  "结束时间"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /audit/file-transfers [get]
+func (fc *FileTransferController) GetTransferLogs(c *gin.Context) {
+	var request models.FileTransferLogListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid query parameters")
+		return
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 20
+	}
+
+	logs, total, err := fc.transferService.GetTransferLogs(&request)
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithPagination(c, logs, request.Page, request.PageSize, total)
+}
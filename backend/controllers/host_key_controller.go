@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HostKeyController 资产主机密钥控制器
+type HostKeyController struct {
+	hostKeyService *services.HostKeyService
+}
+
+// NewHostKeyController 创建主机密钥控制器实例
+func NewHostKeyController(hostKeyService *services.HostKeyService) *HostKeyController {
+	return &HostKeyController{hostKeyService: hostKeyService}
+}
+
+// GetHostKeys 获取资产主机密钥
+// @Summary      获取资产主机密钥
+// @Description  包括已信任的密钥和连接时出现的待确认密钥
+// @Tags         资产主机密钥
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "资产ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "资产不存在"
+// @Router       /assets/{id}/host-keys [get]
+func (hc *HostKeyController) GetHostKeys(c *gin.Context) {
+	assetID, ok := parseHostKeyAssetID(c)
+	if !ok {
+		return
+	}
+
+	keys, err := hc.hostKeyService.GetHostKeys(assetID)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "主机密钥")
+		return
+	}
+
+	utils.RespondWithData(c, keys)
+}
+
+// SeedHostKeys 预置资产主机密钥
+// @Summary      预置资产主机密钥
+// @Description  登记资产的主机公钥，同类型已信任的密钥会被替换
+// @Tags         资产主机密钥
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                             true  "资产ID"
+// @Param        request  body  models.AssetHostKeySeedRequest  true  "主机公钥"
+// @Success      200  {object}  map[string]interface{}  "预置成功"
+// @Failure      400  {object}  map[string]interface{}  "公钥格式错误"
+// @Router       /assets/{id}/host-keys [post]
+func (hc *HostKeyController) SeedHostKeys(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	assetID, ok := parseHostKeyAssetID(c)
+	if !ok {
+		return
+	}
+
+	var request models.AssetHostKeySeedRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	keys, err := hc.hostKeyService.SeedHostKeys(assetID, request.PublicKeys, user, c.ClientIP())
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "主机密钥")
+		return
+	}
+
+	utils.RespondWithData(c, keys)
+}
+
+// AcceptHostKey 确认待处理的主机密钥
+// @Summary      确认主机密钥
+// @Description  信任连接时出现的待确认密钥，替换同类型的已信任密钥
+// @Tags         资产主机密钥
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id      path  int  true  "资产ID"
+// @Param        key_id  path  int  true  "主机密钥ID"
+// @Success      200  {object}  map[string]interface{}  "确认成功"
+// @Failure      400  {object}  map[string]interface{}  "密钥已信任"
+// @Failure      404  {object}  map[string]interface{}  "主机密钥不存在"
+// @Router       /assets/{id}/host-keys/{key_id}/accept [post]
+func (hc *HostKeyController) AcceptHostKey(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	assetID, ok := parseHostKeyAssetID(c)
+	if !ok {
+		return
+	}
+	keyID, err := strconv.ParseUint(c.Param("key_id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid host key ID")
+		return
+	}
+
+	key, err := hc.hostKeyService.AcceptHostKey(assetID, uint(keyID), user, c.ClientIP())
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "主机密钥")
+		return
+	}
+
+	utils.RespondWithData(c, key)
+}
+
+// ResetHostKeys 重置资产主机密钥
+// @Summary      重置资产主机密钥
+// @Description  清除资产的全部主机密钥，下次连接时重新信任；严格模式下需再次预置或确认
+// @Tags         资产主机密钥
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "资产ID"
+// @Success      200  {object}  map[string]interface{}  "重置成功"
+// @Failure      404  {object}  map[string]interface{}  "资产不存在"
+// @Router       /assets/{id}/host-keys [delete]
+func (hc *HostKeyController) ResetHostKeys(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	assetID, ok := parseHostKeyAssetID(c)
+	if !ok {
+		return
+	}
+
+	if err := hc.hostKeyService.ResetHostKeys(assetID, user, c.ClientIP()); err != nil {
+		utils.RespondWithServiceError(c, err, "主机密钥")
+		return
+	}
+
+	utils.RespondWithSuccess(c, "Host keys reset successfully")
+}
+
+
+// parseHostKeyAssetID 解析路径中的资产ID
+func parseHostKeyAssetID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid asset ID")
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"bastion/services"
+	"bastion/utils"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LDAPController LDAP认证管理控制器
+type LDAPController struct {
+	ldapBackend *services.LDAPAuthBackend
+}
+
+// NewLDAPController 创建LDAP认证管理控制器实例
+func NewLDAPController(ldapBackend *services.LDAPAuthBackend) *LDAPController {
+	return &LDAPController{ldapBackend: ldapBackend}
+}
+
+// SyncUsers 立即同步LDAP用户
+// @Summary      同步LDAP用户
+// @Description  检查所有启用中的LDAP用户，禁用目录中已删除或已禁用的用户
+// @Tags         用户管理
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "同步结果"
+// @Failure      400  {object}  map[string]interface{}  "未启用LDAP认证"
+// @Failure      500  {object}  map[string]interface{}  "目录查询失败"
+// @Router       /admin/ldap/sync [post]
+func (lc *LDAPController) SyncUsers(c *gin.Context) {
+	result, err := lc.ldapBackend.SyncUsers()
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidParam) {
+			utils.RespondWithValidationError(c, err.Error())
+			return
+		}
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, result)
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginLockController 登录锁定管理控制器
+type LoginLockController struct {
+	loginGuard   *services.LoginGuardService
+	auditService *services.AuditService
+}
+
+// NewLoginLockController 创建登录锁定管理控制器实例
+func NewLoginLockController(loginGuard *services.LoginGuardService, auditService *services.AuditService) *LoginLockController {
+	return &LoginLockController{
+		loginGuard:   loginGuard,
+		auditService: auditService,
+	}
+}
+
+// GetLocks 获取当前锁定列表
+// @Summary      获取登录锁定列表
+// @Description  列出因登录失败次数过多而被临时锁定的用户名和IP
+// @Tags         用户管理
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /admin/login-locks [get]
+func (lc *LoginLockController) GetLocks(c *gin.Context) {
+	locks, err := lc.loginGuard.ListLocks()
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, locks)
+}
+
+// Unlock 解除用户名或IP的锁定
+// @Summary      解除登录锁定
+// @Description  按用户名和/或IP解除锁定，同时清零失败计数
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.LoginUnlockRequest true "解锁请求"
+// @Success      200  {object}  map[string]interface{}  "解锁成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误"
+// @Router       /admin/login-locks/unlock [post]
+func (lc *LoginLockController) Unlock(c *gin.Context) {
+	var request models.LoginUnlockRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+	if request.Username == "" && request.IP == "" {
+		utils.RespondWithValidationError(c, "username or ip is required")
+		return
+	}
+
+	if request.Username != "" {
+		if err := lc.loginGuard.UnlockUser(request.Username); err != nil {
+			utils.RespondWithInternalError(c, err.Error())
+			return
+		}
+		lc.recordUnlock(c, 0, request.Username, "")
+	}
+	if request.IP != "" {
+		if err := lc.loginGuard.UnlockIP(request.IP); err != nil {
+			utils.RespondWithInternalError(c, err.Error())
+			return
+		}
+		lc.recordUnlock(c, 0, "", request.IP)
+	}
+
+	utils.RespondWithSuccess(c, "Unlocked successfully")
+}
+
+// UnlockUser 解除用户锁定
+// @Summary      解除用户登录锁定
+// @Tags         用户管理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "用户ID"
+// @Success      200  {object}  map[string]interface{}  "解锁成功"
+// @Failure      404  {object}  map[string]interface{}  "用户不存在"
+// @Router       /users/{id}/unlock [post]
+func (lc *LoginLockController) UnlockUser(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid user ID")
+		return
+	}
+
+	user, err := lc.loginGuard.UnlockUserByID(uint(userID))
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithNotFound(c, "用户")
+			return
+		}
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	lc.recordUnlock(c, user.ID, user.Username, "")
+	utils.RespondWithSuccess(c, "User unlocked successfully")
+}
+
+// recordUnlock 解锁记录到登录日志，IP解锁时登录日志的IP字段为被解锁的IP
+func (lc *LoginLockController) recordUnlock(c *gin.Context, userID uint, username, ip string) {
+	if ip == "" {
+		ip = c.ClientIP()
+	}
+	go lc.auditService.RecordLoginLog(userID, username, ip, c.GetHeader("User-Agent"), "web",
+		models.LoginStatusUnlocked, "Login lock removed by "+c.GetString("username"))
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginSessionController Web登录会话控制器
+type LoginSessionController struct {
+	sessionService *services.LoginSessionService
+	auditService   *services.AuditService
+}
+
+// NewLoginSessionController 创建登录会话控制器实例
+func NewLoginSessionController(sessionService *services.LoginSessionService, auditService *services.AuditService) *LoginSessionController {
+	return &LoginSessionController{
+		sessionService: sessionService,
+		auditService:   auditService,
+	}
+}
+
+// GetMySessions 获取当前用户的登录会话
+// @Summary      获取我的登录会话
+// @Description  列出当前用户所有有效的Web登录，current 标记当前请求所用的登录
+// @Tags         认证
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /profile/login-sessions [get]
+func (sc *LoginSessionController) GetMySessions(c *gin.Context) {
+	sessions, err := sc.sessionService.GetSessions(c.GetUint("user_id"), c.GetString("token_id"))
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, sessions)
+}
+
+// RevokeMySession 注销当前用户的指定登录会话
+// @Summary      注销我的登录会话
+// @Description  使其它设备上的登录立即失效
+// @Tags         认证
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  string  true  "会话ID"
+// @Success      200  {object}  map[string]interface{}  "注销成功"
+// @Failure      404  {object}  map[string]interface{}  "会话不存在"
+// @Router       /profile/login-sessions/{id} [delete]
+func (sc *LoginSessionController) RevokeMySession(c *gin.Context) {
+	sc.revokeSession(c, c.GetUint("user_id"), c.Param("id"))
+}
+
+// GetUserSessions 管理员查看用户的登录会话
+// @Summary      获取用户登录会话
+// @Tags         用户管理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "用户ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /users/{id}/login-sessions [get]
+func (sc *LoginSessionController) GetUserSessions(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid user ID")
+		return
+	}
+
+	sessions, err := sc.sessionService.GetSessions(uint(userID), c.GetString("token_id"))
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, sessions)
+}
+
+// RevokeUserSession 管理员注销用户的指定登录会话
+// @Summary      注销用户登录会话
+// @Tags         用户管理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id          path  int     true  "用户ID"
+// @Param        session_id  path  string  true  "会话ID"
+// @Success      200  {object}  map[string]interface{}  "注销成功"
+// @Failure      404  {object}  map[string]interface{}  "会话不存在"
+// @Router       /users/{id}/login-sessions/{session_id} [delete]
+func (sc *LoginSessionController) RevokeUserSession(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid user ID")
+		return
+	}
+
+	sc.revokeSession(c, uint(userID), c.Param("session_id"))
+}
+
+// RevokeAllUserSessions 管理员强制用户下线
+// @Summary      强制用户下线
+// @Description  注销用户的全部Web登录，包括尚未使用过的令牌
+// @Tags         用户管理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "用户ID"
+// @Success      200  {object}  map[string]interface{}  "注销成功"
+// @Failure      404  {object}  map[string]interface{}  "用户不存在"
+// @Router       /users/{id}/login-sessions [delete]
+func (sc *LoginSessionController) RevokeAllUserSessions(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid user ID")
+		return
+	}
+
+	user, count, err := sc.sessionService.RevokeUserSessions(uint(userID))
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithNotFound(c, "用户")
+			return
+		}
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	sc.recordRevoke(c, user.ID, user.Username, fmt.Sprintf("All %d login sessions revoked by %s", count, c.GetString("username")))
+	utils.RespondWithSuccess(c, "Login sessions revoked successfully")
+}
+
+// revokeSession 注销指定会话并记录登录日志
+func (sc *LoginSessionController) revokeSession(c *gin.Context, userID uint, sessionID string) {
+	session, err := sc.sessionService.RevokeSession(userID, sessionID)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithNotFound(c, "登录会话")
+			return
+		}
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	sc.recordRevoke(c, session.UserID, session.Username,
+		fmt.Sprintf("Login session from %s revoked by %s", session.IP, c.GetString("username")))
+	utils.RespondWithSuccess(c, "Login session revoked successfully")
+}
+
+// recordRevoke 会话注销记录到登录日志
+func (sc *LoginSessionController) recordRevoke(c *gin.Context, userID uint, username, message string) {
+	go sc.auditService.RecordLoginLog(userID, username, c.ClientIP(), c.GetHeader("User-Agent"), "web",
+		models.LoginStatusSessionRevoked, message)
+}
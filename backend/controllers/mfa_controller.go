@@ -0,0 +1,296 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MFAController 多因素认证控制器
+type MFAController struct {
+	authService  *services.AuthService
+	mfaService   *services.MFAService
+	auditService *services.AuditService
+}
+
+// NewMFAController 创建多因素认证控制器实例
+func NewMFAController(authService *services.AuthService, mfaService *services.MFAService, auditService *services.AuditService) *MFAController {
+	return &MFAController{
+		authService:  authService,
+		mfaService:   mfaService,
+		auditService: auditService,
+	}
+}
+
+// VerifyLogin 登录二次验证
+// @Summary      登录二次验证
+// @Description  使用登录返回的预认证令牌和认证器验证码（或恢复码）换取访问令牌
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Param        request body models.MFAVerifyRequest true "二次验证请求"
+// @Success      200  {object}  map[string]interface{}  "验证成功，返回token"
+// @Failure      401  {object}  map[string]interface{}  "验证码错误或预认证令牌失效"
+// @Router       /auth/mfa/verify [post]
+func (mc *MFAController) VerifyLogin(c *gin.Context) {
+	var request models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	result, err := mc.authService.VerifyMFA(&request, utils.GetClientIP(c.Request), c.GetHeader("User-Agent"))
+	if err != nil {
+		mc.recordLoginFailure(c, result, err)
+		utils.RespondWithUnauthorized(c, err.Error())
+		return
+	}
+
+	status, message := models.LoginStatusMFASuccess, "MFA verification successful"
+	if result.UsedRecoveryCode {
+		status, message = models.LoginStatusMFARecovery, "MFA verification successful with recovery code"
+	}
+	mc.recordLog(c, result.User, status, message)
+
+	utils.RespondWithData(c, result.Token)
+}
+
+// SetupLogin 登录时绑定认证器
+// @Summary      登录时绑定认证器
+// @Description  所属角色强制MFA但尚未绑定时，使用预认证令牌获取TOTP密钥和二维码
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Param        request body models.MFATokenRequest true "预认证令牌"
+// @Success      200  {object}  map[string]interface{}  "返回密钥和二维码"
+// @Failure      401  {object}  map[string]interface{}  "预认证令牌失效"
+// @Router       /auth/mfa/setup [post]
+func (mc *MFAController) SetupLogin(c *gin.Context) {
+	var request models.MFATokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	setup, err := mc.authService.BeginMFAEnrollment(request.MFAToken)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidParam) {
+			utils.RespondWithValidationError(c, err.Error())
+			return
+		}
+		utils.RespondWithUnauthorized(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, setup)
+}
+
+// EnableLogin 登录时完成认证器绑定
+// @Summary      登录时完成认证器绑定
+// @Description  提交认证器生成的验证码完成绑定，返回访问令牌和恢复码（恢复码只显示一次）
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Param        request body models.MFAVerifyRequest true "绑定请求"
+// @Success      200  {object}  map[string]interface{}  "绑定成功，返回token和恢复码"
+// @Failure      401  {object}  map[string]interface{}  "验证码错误或预认证令牌失效"
+// @Router       /auth/mfa/enable [post]
+func (mc *MFAController) EnableLogin(c *gin.Context) {
+	var request models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	result, err := mc.authService.CompleteMFAEnrollment(&request, utils.GetClientIP(c.Request), c.GetHeader("User-Agent"))
+	if err != nil {
+		mc.recordLoginFailure(c, result, err)
+		utils.RespondWithUnauthorized(c, err.Error())
+		return
+	}
+
+	mc.recordLog(c, result.User, models.LoginStatusMFAEnrolled, "MFA enrolled during login")
+
+	utils.RespondWithData(c, gin.H{
+		"token":          result.Token,
+		"recovery_codes": result.RecoveryCodes,
+	})
+}
+
+// GetStatus 获取当前用户MFA状态
+// @Summary      获取MFA状态
+// @Tags         认证
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /profile/mfa [get]
+func (mc *MFAController) GetStatus(c *gin.Context) {
+	status, err := mc.mfaService.GetStatus(mc.currentUser(c))
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, status)
+}
+
+// Setup 开始绑定认证器
+// @Summary      开始绑定认证器
+// @Description  生成新的TOTP密钥，返回otpauth地址和二维码，需调用启用接口确认
+// @Tags         认证
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "返回密钥和二维码"
+// @Failure      400  {object}  map[string]interface{}  "已绑定认证器"
+// @Router       /profile/mfa/setup [post]
+func (mc *MFAController) Setup(c *gin.Context) {
+	setup, err := mc.mfaService.BeginSetup(mc.currentUser(c))
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "")
+		return
+	}
+
+	utils.RespondWithData(c, setup)
+}
+
+// Enable 启用MFA
+// @Summary      启用MFA
+// @Description  提交认证器生成的验证码完成绑定，返回恢复码（恢复码只显示一次）
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.MFACodeRequest true "验证码"
+// @Success      200  {object}  map[string]interface{}  "启用成功，返回恢复码"
+// @Failure      400  {object}  map[string]interface{}  "验证码错误"
+// @Router       /profile/mfa/enable [post]
+func (mc *MFAController) Enable(c *gin.Context) {
+	var request models.MFACodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	user := mc.currentUser(c)
+	codes, err := mc.mfaService.Enable(user.ID, request.Code)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "")
+		return
+	}
+
+	mc.recordLog(c, user, models.LoginStatusMFAEnrolled, "MFA enabled")
+	utils.RespondWithData(c, codes)
+}
+
+// Disable 解绑认证器
+// @Summary      解绑认证器
+// @Description  使用验证码或恢复码解绑认证器，所属角色强制MFA时不允许解绑
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.MFACodeRequest true "验证码"
+// @Success      200  {object}  map[string]interface{}  "解绑成功"
+// @Failure      400  {object}  map[string]interface{}  "验证码错误"
+// @Failure      403  {object}  map[string]interface{}  "角色强制MFA"
+// @Router       /profile/mfa/disable [post]
+func (mc *MFAController) Disable(c *gin.Context) {
+	var request models.MFACodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	user := mc.currentUser(c)
+	if err := mc.mfaService.Disable(user, request.Code); err != nil {
+		utils.RespondWithServiceError(c, err, "")
+		return
+	}
+
+	mc.recordLog(c, user, models.LoginStatusMFADisabled, "MFA disabled by user")
+	utils.RespondWithSuccess(c, "MFA disabled successfully")
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary      重新生成恢复码
+// @Description  使用验证码重新生成恢复码，旧恢复码全部失效
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.MFACodeRequest true "验证码"
+// @Success      200  {object}  map[string]interface{}  "返回新的恢复码"
+// @Failure      400  {object}  map[string]interface{}  "验证码错误"
+// @Router       /profile/mfa/recovery-codes [post]
+func (mc *MFAController) RegenerateRecoveryCodes(c *gin.Context) {
+	var request models.MFACodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	user := mc.currentUser(c)
+	codes, err := mc.mfaService.RegenerateRecoveryCodes(user.ID, request.Code)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "")
+		return
+	}
+
+	mc.recordLog(c, user, models.LoginStatusMFACodesRegen, "MFA recovery codes regenerated")
+	utils.RespondWithData(c, codes)
+}
+
+// ResetUserMFA 管理员重置用户MFA
+// @Summary      重置用户MFA
+// @Description  清除用户绑定的认证器和恢复码，用于用户丢失认证器的情况
+// @Tags         用户管理
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "用户ID"
+// @Success      200  {object}  map[string]interface{}  "重置成功"
+// @Failure      404  {object}  map[string]interface{}  "用户不存在"
+// @Router       /users/{id}/reset-mfa [post]
+func (mc *MFAController) ResetUserMFA(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid user ID")
+		return
+	}
+
+	user, err := mc.mfaService.Reset(uint(userID))
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithNotFound(c, "用户")
+			return
+		}
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	mc.recordLog(c, user, models.LoginStatusMFAReset, "MFA reset by "+c.GetString("username"))
+	utils.RespondWithSuccess(c, "MFA reset successfully")
+}
+
+// currentUser 获取当前登录用户
+func (mc *MFAController) currentUser(c *gin.Context) *models.User {
+	user, _ := c.Get("user")
+	return user.(*models.User)
+}
+
+// recordLog 记录MFA事件到登录日志
+func (mc *MFAController) recordLog(c *gin.Context, user *models.User, status, message string) {
+	go mc.auditService.RecordLoginLog(user.ID, user.Username, c.ClientIP(), c.GetHeader("User-Agent"), "web", status, message)
+}
+
+// recordLoginFailure 记录登录二次验证失败，预认证令牌无效时没有用户信息
+func (mc *MFAController) recordLoginFailure(c *gin.Context, result *services.LoginResult, err error) {
+	if result == nil || result.User == nil {
+		go mc.auditService.RecordLoginLog(0, "", c.ClientIP(), c.GetHeader("User-Agent"), "web", models.LoginStatusMFAFailed, err.Error())
+		return
+	}
+	mc.recordLog(c, result.User, models.LoginStatusMFAFailed, err.Error())
+}
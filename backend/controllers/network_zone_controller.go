@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NetworkZoneController 网络区域控制器
+type NetworkZoneController struct {
+	zoneService *services.NetworkZoneService
+}
+
+// NewNetworkZoneController 创建网络区域控制器实例
+func NewNetworkZoneController(zoneService *services.NetworkZoneService) *NetworkZoneController {
+	return &NetworkZoneController{zoneService: zoneService}
+}
+
+// CreateZone 创建网络区域
+// @Summary      创建网络区域
+// @Description  区域内的资产默认经由区域网关（跳板机）连接，网关必须是启用的SSH资产，凭证必须与网关关联
+// @Tags         网络区域
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.NetworkZoneCreateRequest true "网络区域"
+// @Success      200  {object}  map[string]interface{}  "创建成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误或网关不可用"
+// @Failure      409  {object}  map[string]interface{}  "区域名称已存在"
+// @Router       /network-zones [post]
+func (zc *NetworkZoneController) CreateZone(c *gin.Context) {
+	var request models.NetworkZoneCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	zone, err := zc.zoneService.CreateZone(&request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "网络区域")
+		return
+	}
+
+	utils.RespondWithData(c, zone)
+}
+
+// GetZones 获取网络区域列表
+// @Summary      获取网络区域列表
+// @Tags         网络区域
+// @Produce      json
+// @Security     BearerAuth
+// @Param        page       query  int     false  "页码"
+// @Param        page_size  query  int     false  "每页大小"
+// @Param        keyword    query  string  false  "关键字"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /network-zones [get]
+func (zc *NetworkZoneController) GetZones(c *gin.Context) {
+	var request models.NetworkZoneListRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid query parameters")
+		return
+	}
+	if request.Page <= 0 {
+		request.Page = 1
+	}
+	if request.PageSize <= 0 {
+		request.PageSize = 10
+	}
+
+	zones, total, err := zc.zoneService.GetZones(&request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "网络区域")
+		return
+	}
+
+	utils.RespondWithPagination(c, zones, request.Page, request.PageSize, total)
+}
+
+// GetZone 获取网络区域详情
+// @Summary      获取网络区域详情
+// @Tags         网络区域
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "区域ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "网络区域不存在"
+// @Router       /network-zones/{id} [get]
+func (zc *NetworkZoneController) GetZone(c *gin.Context) {
+	id, ok := parseNetworkZoneID(c)
+	if !ok {
+		return
+	}
+
+	zone, err := zc.zoneService.GetZone(id)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "网络区域")
+		return
+	}
+
+	utils.RespondWithData(c, zone)
+}
+
+// UpdateZone 更新网络区域
+// @Summary      更新网络区域
+// @Description  gateway_id 传0表示区域内的资产改为直连
+// @Tags         网络区域
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  int                              true  "区域ID"
+// @Param        request  body  models.NetworkZoneUpdateRequest  true  "网络区域"
+// @Success      200  {object}  map[string]interface{}  "更新成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误或网关不可用"
+// @Failure      404  {object}  map[string]interface{}  "网络区域不存在"
+// @Failure      409  {object}  map[string]interface{}  "区域名称已存在"
+// @Router       /network-zones/{id} [put]
+func (zc *NetworkZoneController) UpdateZone(c *gin.Context) {
+	id, ok := parseNetworkZoneID(c)
+	if !ok {
+		return
+	}
+
+	var request models.NetworkZoneUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	zone, err := zc.zoneService.UpdateZone(id, &request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "网络区域")
+		return
+	}
+
+	utils.RespondWithData(c, zone)
+}
+
+// DeleteZone 删除网络区域
+// @Summary      删除网络区域
+// @Description  区域内的资产移出区域，未配置资产级网关的资产改为直连
+// @Tags         网络区域
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "区域ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Failure      404  {object}  map[string]interface{}  "网络区域不存在"
+// @Router       /network-zones/{id} [delete]
+func (zc *NetworkZoneController) DeleteZone(c *gin.Context) {
+	id, ok := parseNetworkZoneID(c)
+	if !ok {
+		return
+	}
+
+	if err := zc.zoneService.DeleteZone(id); err != nil {
+		utils.RespondWithServiceError(c, err, "网络区域")
+		return
+	}
+
+	utils.RespondWithSuccess(c, "Network zone deleted successfully")
+}
+
+
+// parseNetworkZoneID 解析路径中的区域ID
+func parseNetworkZoneID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid zone ID")
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OIDCController OIDC单点登录控制器
+type OIDCController struct {
+	oidcService  *services.OIDCService
+	auditService *services.AuditService
+}
+
+// NewOIDCController 创建OIDC单点登录控制器实例
+func NewOIDCController(oidcService *services.OIDCService, auditService *services.AuditService) *OIDCController {
+	return &OIDCController{
+		oidcService:  oidcService,
+		auditService: auditService,
+	}
+}
+
+// Login 发起单点登录
+// @Summary      发起单点登录
+// @Description  生成state、nonce和PKCE校验码后跳转到OIDC提供方的授权页面
+// @Tags         认证
+// @Success      302  {string}  string  "跳转到提供方授权页面"
+// @Failure      400  {object}  map[string]interface{}  "未启用单点登录"
+// @Failure      500  {object}  map[string]interface{}  "提供方不可用"
+// @Router       /auth/oidc/login [get]
+func (oc *OIDCController) Login(c *gin.Context) {
+	authURL, err := oc.oidcService.AuthCodeURL()
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidParam) {
+			utils.RespondWithValidationError(c, err.Error())
+			return
+		}
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback 单点登录回调
+// @Summary      单点登录回调
+// @Description  校验授权码和ID Token后签发堡垒机令牌。配置了前端地址时跳转到前端并通过URL片段传递令牌，否则直接返回JSON
+// @Tags         认证
+// @Produce      json
+// @Param        code   query  string  true  "授权码"
+// @Param        state  query  string  true  "登录状态"
+// @Success      200  {object}  map[string]interface{}  "登录成功，返回token；需要二次验证时返回预认证令牌"
+// @Success      302  {string}  string  "跳转到前端"
+// @Failure      401  {object}  map[string]interface{}  "登录失败"
+// @Router       /auth/oidc/callback [get]
+func (oc *OIDCController) Callback(c *gin.Context) {
+	// 用户在提供方拒绝授权或提供方返回错误
+	if errCode := c.Query("error"); errCode != "" {
+		message := errCode
+		if description := c.Query("error_description"); description != "" {
+			message += ": " + description
+		}
+		oc.recordLog(c, nil, "failed", message)
+		oc.respondWithError(c, message)
+		return
+	}
+
+	result, err := oc.oidcService.Callback(c.Query("code"), c.Query("state"), utils.GetClientIP(c.Request), c.GetHeader("User-Agent"))
+	if err != nil {
+		var user *models.User
+		if result != nil {
+			user = result.User
+		}
+		oc.recordLog(c, user, services.LoginFailureStatus(err), err.Error())
+		oc.respondWithError(c, err.Error())
+		return
+	}
+
+	fragment := url.Values{}
+	if result.Challenge != nil {
+		oc.recordLog(c, result.User, models.LoginStatusMFAChallenge, "SSO verified, waiting for MFA")
+		if oc.oidcService.FrontendURL() == "" {
+			utils.RespondWithData(c, result.Challenge)
+			return
+		}
+		fragment.Set("mfa_required", "true")
+		fragment.Set("enroll_required", strconv.FormatBool(result.Challenge.EnrollRequired))
+		fragment.Set("mfa_token", result.Challenge.MFAToken)
+		fragment.Set("expires_in", strconv.Itoa(result.Challenge.ExpiresIn))
+	} else {
+		oc.recordLog(c, result.User, "success", "SSO login successful")
+		if oc.oidcService.FrontendURL() == "" {
+			utils.RespondWithData(c, result.Token)
+			return
+		}
+		fragment.Set("access_token", result.Token.AccessToken)
+		fragment.Set("token_type", result.Token.TokenType)
+		fragment.Set("expires_in", strconv.Itoa(result.Token.ExpiresIn))
+	}
+
+	// 令牌放在URL片段中，不会发送到前端服务器或出现在访问日志里
+	c.Redirect(http.StatusFound, oc.oidcService.FrontendURL()+"#"+fragment.Encode())
+}
+
+// recordLog 记录单点登录日志，提供方返回错误时没有用户信息
+func (oc *OIDCController) recordLog(c *gin.Context, user *models.User, status, message string) {
+	var userID uint
+	var username string
+	if user != nil {
+		userID, username = user.ID, user.Username
+	}
+	go oc.auditService.RecordLoginLog(userID, username, c.ClientIP(), c.GetHeader("User-Agent"), "oidc", status, message)
+}
+
+// respondWithError 返回登录失败，配置了前端地址时跳转到前端显示错误
+func (oc *OIDCController) respondWithError(c *gin.Context, message string) {
+	if oc.oidcService.FrontendURL() == "" {
+		utils.RespondWithUnauthorized(c, message)
+		return
+	}
+
+	fragment := url.Values{}
+	fragment.Set("error", message)
+	c.Redirect(http.StatusFound, oc.oidcService.FrontendURL()+"#"+fragment.Encode())
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PasswordPolicyController 密码策略控制器
+type PasswordPolicyController struct {
+	passwordPolicyService *services.PasswordPolicyService
+}
+
+// NewPasswordPolicyController 创建密码策略控制器实例
+func NewPasswordPolicyController(passwordPolicyService *services.PasswordPolicyService) *PasswordPolicyController {
+	return &PasswordPolicyController{passwordPolicyService: passwordPolicyService}
+}
+
+// GetPolicy 获取密码策略
+// @Summary      获取密码策略
+// @Description  获取当前的密码复杂度、历史密码和有效期策略，未配置时返回默认策略
+// @Tags         用户管理
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  models.PasswordPolicy  "获取成功"
+// @Router       /admin/password-policy [get]
+func (pc *PasswordPolicyController) GetPolicy(c *gin.Context) {
+	policy, err := pc.passwordPolicyService.GetPolicy()
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, policy)
+}
+
+// UpdatePolicy 更新密码策略
+// @Summary      更新密码策略
+// @Description  更新密码策略，只对之后设置的密码生效；有效期变更会影响下次登录时的过期判断
+// @Tags         用户管理
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.PasswordPolicyUpdateRequest true "密码策略"
+// @Success      200  {object}  models.PasswordPolicy  "更新成功"
+// @Failure      400  {object}  map[string]interface{}  "请求参数错误"
+// @Router       /admin/password-policy [put]
+func (pc *PasswordPolicyController) UpdatePolicy(c *gin.Context) {
+	var request models.PasswordPolicyUpdateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	policy, err := pc.passwordPolicyService.UpdatePolicy(&request, c.GetString("username"))
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidParam) {
+			utils.RespondWithValidationError(c, err.Error())
+			return
+		}
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, policy)
+}
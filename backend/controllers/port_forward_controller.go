@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PortForwardController 端口转发（隧道）控制器
+type PortForwardController struct {
+	portForwardService *services.PortForwardService
+}
+
+// NewPortForwardController 创建端口转发控制器实例
+func NewPortForwardController(portForwardService *services.PortForwardService) *PortForwardController {
+	return &PortForwardController{portForwardService: portForwardService}
+}
+
+// OpenTunnel 在Web会话上打开隧道
+// @Summary      打开隧道
+// @Description  在堡垒机回环地址上监听一个端口，连接将经由网关链路转发到目标资产的指定端口；目标资产与端口须在授权规则的转发端口内，会话关闭时隧道随之关闭
+// @Tags         端口转发
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id       path  string                          true  "会话ID"
+// @Param        request  body  models.PortTunnelCreateRequest  true  "隧道目标"
+// @Success      200  {object}  map[string]interface{}  "打开成功"
+// @Failure      403  {object}  map[string]interface{}  "没有转发到该端口的权限"
+// @Failure      404  {object}  map[string]interface{}  "会话或资产不存在"
+// @Router       /ssh/sessions/{id}/tunnels [post]
+func (pc *PortForwardController) OpenTunnel(c *gin.Context) {
+	var request models.PortTunnelCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	tunnel, err := pc.portForwardService.OpenWebTunnel(c.GetUint("user_id"), c.Param("id"), &request)
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "会话或隧道")
+		return
+	}
+
+	utils.RespondWithData(c, tunnel)
+}
+
+// GetTunnels 获取Web会话上的隧道
+// @Summary      获取会话隧道列表
+// @Tags         端口转发
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  string  true  "会话ID"
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Failure      404  {object}  map[string]interface{}  "会话不存在"
+// @Router       /ssh/sessions/{id}/tunnels [get]
+func (pc *PortForwardController) GetTunnels(c *gin.Context) {
+	tunnels, err := pc.portForwardService.GetSessionTunnels(c.GetUint("user_id"), c.Param("id"))
+	if err != nil {
+		utils.RespondWithServiceError(c, err, "会话或隧道")
+		return
+	}
+
+	utils.RespondWithData(c, tunnels)
+}
+
+// CloseTunnel 关闭Web会话上的隧道
+// @Summary      关闭隧道
+// @Tags         端口转发
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id         path  string  true  "会话ID"
+// @Param        tunnel_id  path  string  true  "隧道ID"
+// @Success      200  {object}  map[string]interface{}  "关闭成功"
+// @Failure      404  {object}  map[string]interface{}  "会话或隧道不存在"
+// @Router       /ssh/sessions/{id}/tunnels/{tunnel_id} [delete]
+func (pc *PortForwardController) CloseTunnel(c *gin.Context) {
+	if err := pc.portForwardService.CloseWebTunnel(c.GetUint("user_id"), c.Param("id"), c.Param("tunnel_id")); err != nil {
+		utils.RespondWithServiceError(c, err, "会话或隧道")
+		return
+	}
+
+	utils.RespondWithSuccess(c, "Tunnel closed successfully")
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SSHCAController SSH证书颁发机构控制器
+type SSHCAController struct {
+	caService *services.SSHCAService
+}
+
+// NewSSHCAController 创建SSH CA控制器实例
+func NewSSHCAController(caService *services.SSHCAService) *SSHCAController {
+	return &SSHCAController{caService: caService}
+}
+
+// GetTrustedPublicKeys 导出CA公钥
+// @Summary      导出SSH CA公钥
+// @Description  返回纯文本，每行一个CA公钥，可直接保存为目标机 sshd 的 TrustedUserCAKeys 文件；轮换后的旧CA在删除前仍会导出
+// @Tags         SSH证书颁发机构
+// @Produce      plain
+// @Success      200  {string}  string  "CA公钥列表"
+// @Router       /ssh-ca/public-key [get]
+func (cc *SSHCAController) GetTrustedPublicKeys(c *gin.Context) {
+	keys, err := cc.caService.TrustedPublicKeys()
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	c.String(http.StatusOK, keys)
+}
+
+// GetAuthorities 获取SSH CA列表
+// @Summary      获取SSH CA列表
+// @Description  当前使用的CA排在最前，尚未创建时自动生成
+// @Tags         SSH证书颁发机构
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /ssh-ca [get]
+func (cc *SSHCAController) GetAuthorities(c *gin.Context) {
+	authorities, err := cc.caService.GetAuthorities()
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, authorities)
+}
+
+// Rotate 轮换SSH CA
+// @Summary      轮换SSH CA
+// @Description  生成新的CA用于签发证书，旧CA保留在导出的公钥列表中，目标机全部更新后再删除
+// @Tags         SSH证书颁发机构
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "轮换成功，返回新的CA"
+// @Router       /ssh-ca/rotate [post]
+func (cc *SSHCAController) Rotate(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	ca, err := cc.caService.Rotate(user, c.ClientIP())
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, ca)
+}
+
+// DeleteAuthority 删除已轮换的SSH CA
+// @Summary      删除已轮换的SSH CA
+// @Tags         SSH证书颁发机构
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "CA ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Failure      400  {object}  map[string]interface{}  "不能删除当前使用的CA"
+// @Failure      404  {object}  map[string]interface{}  "CA不存在"
+// @Router       /ssh-ca/{id} [delete]
+func (cc *SSHCAController) DeleteAuthority(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid certificate authority ID")
+		return
+	}
+
+	if err := cc.caService.DeleteAuthority(uint(id), user, c.ClientIP()); err != nil {
+		switch {
+		case errors.Is(err, utils.ErrNotFound):
+			utils.RespondWithNotFound(c, "SSH证书颁发机构")
+		case errors.Is(err, utils.ErrInvalidParam):
+			utils.RespondWithValidationError(c, err.Error())
+		default:
+			utils.RespondWithInternalError(c, err.Error())
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(c, "Certificate authority deleted successfully")
+}
@@ -6,6 +6,7 @@ import (
 	"bastion/utils"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -74,7 +75,7 @@ func NewSSHController(sshService *services.SSHService) *SSHController {
 
 // CreateSession 创建SSH会话
 // @Summary      创建SSH会话
-// @Description  创建新的SSH会话连接
+// @Description  创建新的SSH会话连接，protocol为telnet时创建Telnet会话并使用凭证自动登录
 // @Tags         SSH管理
 // @Accept       json
 // @Produce      json
@@ -102,12 +103,31 @@ func (sc *SSHController) CreateSession(c *gin.Context) {
 	}
 
 	user := userInterface.(*models.User)
+	request.ClientIP = utils.GetClientIP(c.Request)
 
 	// 创建SSH会话
 	log.Printf("Creating SSH session for user %d to asset %d", user.ID, request.AssetID)
 	sessionResp, err := sc.sshService.CreateSession(user.ID, &request)
 	if err != nil {
 		log.Printf("Failed to create SSH session: %v", err)
+		var violation *services.AccessPolicyViolation
+		if errors.As(err, &violation) {
+			utils.RespondWithForbidden(c, err.Error())
+			return
+		}
+		var hostKeyErr *services.HostKeyError
+		if errors.As(err, &hostKeyErr) {
+			utils.RespondWithForbidden(c, hostKeyErr.Error())
+			return
+		}
+		if errors.Is(err, utils.ErrPermissionDenied) {
+			utils.RespondWithForbidden(c, "No permission to access this asset with the selected credential")
+			return
+		}
+		if errors.Is(err, utils.ErrInvalidParam) {
+			utils.RespondWithValidationError(c, err.Error())
+			return
+		}
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create SSH session: " + err.Error())
 		return
 	}
@@ -659,58 +679,8 @@ func (sc *SSHController) handleWebSocketInput(ctx context.Context, wsConn *WebSo
 					log.Printf("[DEBUG] Command buffer for session %s: '%s'", wsConn.sessionID, command)
 					
 					if command != "" {
-						// 创建命令匹配服务实例来检查命令
-						commandFilterService := services.NewCommandFilterService(utils.GetDB())
-						commandMatcherService := services.NewCommandMatcherService(utils.GetDB(), commandFilterService)
-						// 检查命令是否被禁止
-						session, _ := sc.sshService.GetSession(wsConn.sessionID)
-						
-						// 获取凭证信息以获取账号
-						var sessionRecord models.SessionRecord
-						var credential models.Credential
-						var account string = "unknown"
-						
-						if err := utils.GetDB().Where("session_id = ?", wsConn.sessionID).First(&sessionRecord).Error; err == nil {
-							if err := utils.GetDB().First(&credential, sessionRecord.CredentialID).Error; err == nil {
-								account = credential.Username
-							}
-						}
-						
-						matchReq := &models.CommandMatchRequest{
-							Command:  command,
-							UserID:   wsConn.userID,
-							AssetID:  session.AssetID,
-							Account:  account,
-						}
-						log.Printf("[DEBUG] Checking command: '%s', UserID: %d, AssetID: %d, Account: %s", command, wsConn.userID, session.AssetID, account)
-						matchResult, err := commandMatcherService.MatchCommand(matchReq)
-						if err != nil {
-							log.Printf("Command match error: %v", err)
-						}
-						
-						allowed := (err == nil) && (!matchResult.Matched || matchResult.Action == "allow")
-						
-						if !allowed {
-							// 命令被拦截 - 只记录被阻断的命令到审计日志
-							startTime := time.Now()
-							action := "deny" // 被阻断的命令动作为 deny
-							if matchResult.Matched && matchResult.Action != "" {
-								action = matchResult.Action // 使用匹配规则的动作
-							}
-							
-							// 记录被阻断的命令到审计日志（异步执行，不阻塞命令处理）
-							go sc.sshService.RecordCommand(
-								wsConn.sessionID,
-								command,
-								"Command blocked by filter rule", // output 记录阻断原因
-								1,  // exitCode 设为1表示失败
-								action,
-								startTime,
-								nil, // endTime 暂时为nil
-							)
-							
-							log.Printf("[AUDIT] Recorded blocked command: session=%s, command=%s, action=%s", wsConn.sessionID, command, action)
-							
+						// 检查命令是否被过滤规则禁止（被拦截的命令由服务层记录审计日志）
+						if !sc.sshService.CheckCommand(wsConn.sessionID, command) {
 							// 命令被拦截
 							blockedMessage := fmt.Sprintf("\r\n\033[31m命令 `%s` 是被禁止的 ...\033[0m\r\n", command)
 							
@@ -881,13 +851,21 @@ func (sc *SSHController) ResizeSession(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Param        key_type  query  string  false  "密钥类型 rsa/ed25519，默认rsa"
 // @Success      200  {object}  map[string]interface{}  "生成成功"
+// @Failure      400  {object}  map[string]interface{}  "不支持的密钥类型"
 // @Failure      401  {object}  map[string]interface{}  "未授权"
 // @Failure      500  {object}  map[string]interface{}  "服务器错误"
 // @Router       /ssh/keypair [post]
 func (sc *SSHController) GenerateKeyPair(c *gin.Context) {
+	keyType := c.DefaultQuery("key_type", utils.SSHKeyTypeRSA)
+	if keyType != utils.SSHKeyTypeRSA && keyType != utils.SSHKeyTypeED25519 {
+		utils.RespondWithValidationError(c, "Unsupported key type")
+		return
+	}
+
 	// 生成SSH密钥对
-	privateKey, publicKey, err := sc.sshService.GenerateSSHKeyPair()
+	privateKey, publicKey, err := sc.sshService.GenerateSSHKeyPair(keyType)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to generate SSH key pair")
 		return
@@ -1088,35 +1066,7 @@ func (sc *SSHController) broadcastToMonitorClients(message services.WSMessage) {
 	if services.GlobalWebSocketService == nil {
 		return
 	}
-	
-	// 获取所有连接的监控客户端
-	manager := services.GlobalWebSocketService.GetManager()
-	if manager == nil {
-		return
-	}
-	
-	manager.Mutex.RLock()
-	defer manager.Mutex.RUnlock()
-	
-	// 序列化消息
-	data, err := json.Marshal(message)
-	if err != nil {
-		log.Printf("Failed to marshal monitor message: %v", err)
-		return
-	}
-	
-	// 遍历所有客户端，发送给监控权限的客户端
-	for _, client := range manager.Clients {
-		// 检查客户端是否有监控权限（非SSH终端客户端）
-		if client.Role != "ssh_terminal" {
-			select {
-			case client.Send <- data:
-				log.Printf("Terminal output sent to monitor client %s", client.ID)
-			default:
-				log.Printf("Monitor client %s send buffer full, skipping", client.ID)
-			}
-		}
-	}
+	services.GlobalWebSocketService.BroadcastToMonitorClients(message)
 }
 
 // 🆕 会话超时管理控制器方法
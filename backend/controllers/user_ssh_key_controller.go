@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"bastion/models"
+	"bastion/services"
+	"bastion/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserSSHKeyController 用户SSH公钥控制器
+type UserSSHKeyController struct {
+	keyService *services.UserSSHKeyService
+}
+
+// NewUserSSHKeyController 创建用户SSH公钥控制器实例
+func NewUserSSHKeyController(keyService *services.UserSSHKeyService) *UserSSHKeyController {
+	return &UserSSHKeyController{keyService: keyService}
+}
+
+// GetKeys 获取当前用户的SSH公钥
+// @Summary      获取SSH公钥列表
+// @Description  获取当前用户登记的SSH网关登录公钥
+// @Tags         认证
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  map[string]interface{}  "获取成功"
+// @Router       /profile/ssh-keys [get]
+func (kc *UserSSHKeyController) GetKeys(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	keys, err := kc.keyService.GetKeys(userID)
+	if err != nil {
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithData(c, keys)
+}
+
+// AddKey 添加SSH公钥
+// @Summary      添加SSH公钥
+// @Description  为当前用户登记SSH网关登录公钥（authorized_keys格式）
+// @Tags         认证
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body models.UserSSHKeyCreateRequest true "公钥信息"
+// @Success      200  {object}  map[string]interface{}  "添加成功"
+// @Failure      400  {object}  map[string]interface{}  "公钥格式错误"
+// @Failure      409  {object}  map[string]interface{}  "公钥已存在"
+// @Router       /profile/ssh-keys [post]
+func (kc *UserSSHKeyController) AddKey(c *gin.Context) {
+	var request models.UserSSHKeyCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.RespondWithValidationError(c, "Invalid request format")
+		return
+	}
+
+	key, err := kc.keyService.AddKey(c.GetUint("user_id"), &request)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrInvalidParam):
+			utils.RespondWithValidationError(c, err.Error())
+		case err.Error() == "ssh key already exists":
+			utils.RespondWithConflict(c, err.Error())
+		default:
+			utils.RespondWithInternalError(c, err.Error())
+		}
+		return
+	}
+
+	utils.RespondWithData(c, key)
+}
+
+// DeleteKey 删除SSH公钥
+// @Summary      删除SSH公钥
+// @Tags         认证
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "公钥ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Failure      404  {object}  map[string]interface{}  "公钥不存在"
+// @Router       /profile/ssh-keys/{id} [delete]
+func (kc *UserSSHKeyController) DeleteKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.RespondWithValidationError(c, "Invalid key ID")
+		return
+	}
+
+	if err := kc.keyService.DeleteKey(c.GetUint("user_id"), uint(id)); err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			utils.RespondWithNotFound(c, "SSH公钥")
+			return
+		}
+		utils.RespondWithInternalError(c, err.Error())
+		return
+	}
+
+	utils.RespondWithSuccess(c, "SSH key deleted successfully")
+}
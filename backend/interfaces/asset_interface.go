@@ -107,6 +107,7 @@ type SessionManager interface {
 	// 批量操作
 	CloseUserSessions(ctx context.Context, userID uint) error
 	CloseAssetSessions(ctx context.Context, assetID uint) error
+	CloseUserAssetSessions(ctx context.Context, userID uint, assetIDs []uint, reason string) (int, error)
 	GetSessionCount(ctx context.Context, userID *uint) (int64, error)
 	
 	// 会话状态管理
@@ -191,6 +192,9 @@ type NotificationSender interface {
 	// 系统通知
 	NotifySystemMaintenance(ctx context.Context, message string, affectedUsers []uint) error
 	NotifyAssetUnavailable(ctx context.Context, assetID uint, reason string) error
+
+	// 用户通知（如审批待办）
+	NotifyUsers(ctx context.Context, userIDs []uint, eventType string, details interface{}) error
 }
 
 // ServiceRegistry 服务注册表接口 - 管理所有服务实例
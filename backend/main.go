@@ -55,6 +55,11 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// 初始化凭证加密主密钥后端
+	if err := utils.InitSecretStore(); err != nil {
+		log.Fatalf("Failed to initialize secret store: %v", err)
+	}
+
 	// 设置日志级别
 	switch config.GlobalConfig.Log.Level {
 	case "debug":
@@ -89,6 +94,24 @@ func main() {
 	// 初始化WebSocket服务
 	services.InitWebSocketService()
 
+	// 注册通知发送器（登录锁定等安全事件推送给监控客户端）
+	services.GlobalServiceRegistry.RegisterNotificationSender(services.NewNotificationService())
+
+	// 注册会话管理器（授权收回或用户禁用时断开终端、数据库代理会话和端口转发隧道）
+	services.GlobalServiceRegistry.RegisterSessionManager(services.NewSessionManagerAdapter(
+		services.NewUnifiedSessionService(utils.GetRedis(), utils.GetDB())))
+
+	// 注册审计日志器（凭证明文查看等敏感操作的审计）
+	services.GlobalServiceRegistry.RegisterAuditLogger(services.NewAuditLoggerService(utils.GetDB()))
+
+	// 注册权限检查器（连接测试按资产授权规则校验资产和凭证）
+	services.GlobalServiceRegistry.RegisterPermissionChecker(services.NewAssetPermissionService(utils.GetDB()))
+
+	// 为缺少提示的凭证生成非敏感提示（历史凭证的提示在迁移中已清除）
+	if err := services.NewAssetService(utils.GetDB()).BackfillSecretHints(); err != nil {
+		logrus.WithError(err).Error("生成凭证提示失败")
+	}
+
 	// 初始化命令过滤服务并验证配置
 	logrus.Info("初始化命令过滤服务...")
 	commandFilterService := services.NewCommandFilterService(utils.GetDB())
@@ -135,6 +158,36 @@ func main() {
 	sshService := services.NewSSHService(utils.GetDB())
 	go sshService.StartSessionCleanup(ctx)
 
+	// 启动LDAP用户定时同步（禁用目录中已删除的用户）
+	if config.GlobalConfig.LDAP.Enable {
+		go services.NewLDAPAuthBackend(utils.GetDB()).StartSync(ctx)
+	}
+
+	// 启动临时访问申请到期检查（收回授权并关闭相关会话）
+	go services.NewAccessRequestService(utils.GetDB()).StartExpiryCheck(ctx)
+
+	// 启动凭证定时改密
+	go services.NewCredentialRotationService(utils.GetDB()).StartScheduler(ctx)
+
+	// 启动凭证借出到期检查（到期收回并改密）
+	go services.NewCredentialCheckoutService(utils.GetDB()).StartExpiryCheck(ctx)
+
+	// 启动SSH网关（原生SSH客户端接入）
+	var sshGateway *services.SSHGatewayService
+	if config.GlobalConfig.SSHGateway.Enable {
+		sshGateway = services.NewSSHGatewayService(utils.GetDB(), services.GlobalSSHService)
+		if err := sshGateway.Start(); err != nil {
+			logrus.Fatalf("Failed to start SSH gateway: %v", err)
+		}
+	}
+
+	// 启动数据库协议代理（原生数据库客户端接入）
+	if config.GlobalConfig.DatabaseProxy.Enable {
+		if err := services.GlobalDatabaseProxyService.Start(); err != nil {
+			logrus.Fatalf("Failed to start database proxy: %v", err)
+		}
+	}
+
 	// 启动监控服务的定时任务
 	monitorService := services.NewMonitorService(utils.GetDB())
 	go monitorService.StartMonitoringTasks()
@@ -156,6 +209,20 @@ func main() {
 	// 优雅关闭服务
 	logrus.Info("Shutting down services...")
 	
+	// 关闭SSH网关
+	if sshGateway != nil {
+		if err := sshGateway.Stop(); err != nil {
+			logrus.Errorf("Failed to stop SSH gateway: %v", err)
+		}
+	}
+
+	// 关闭数据库代理
+	if config.GlobalConfig.DatabaseProxy.Enable {
+		if err := services.GlobalDatabaseProxyService.Stop(); err != nil {
+			logrus.Errorf("Failed to stop database proxy: %v", err)
+		}
+	}
+
 	// 关闭超时管理服务
 	if services.GlobalSessionTimeoutService != nil {
 		if err := services.GlobalSessionTimeoutService.Stop(); err != nil {
@@ -2,7 +2,9 @@ package middleware
 
 import (
 	"bastion/models"
+	"bastion/services"
 	"bastion/utils"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -18,7 +20,8 @@ func isWebSocketRequest(c *gin.Context) bool {
 }
 
 // AuthMiddleware JWT认证中间件
-func AuthMiddleware() gin.HandlerFunc {
+// allowedPurposes 为该路由额外接受的受限令牌用途，默认只接受访问令牌
+func AuthMiddleware(allowedPurposes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var tokenString string
 		
@@ -47,6 +50,43 @@ func AuthMiddleware() gin.HandlerFunc {
 			}
 		}
 
+		// 个人API令牌，用户权限被限制在令牌的权限范围内
+		if utils.IsAPIToken(tokenString) {
+			user, apiToken, err := utils.GetUserByAPIToken(tokenString, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Invalid token: " + err.Error(),
+				})
+				c.Abort()
+				return
+			}
+
+			// 密码已过期的用户登录时只能拿到修改密码令牌，API令牌同样不能继续使用
+			expired, err := services.NewPasswordPolicyService(utils.GetDB()).IsExpired(user)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to check password expiry",
+				})
+				c.Abort()
+				return
+			}
+			if expired {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Password has expired, please login and change your password",
+				})
+				c.Abort()
+				return
+			}
+
+			c.Set("user", user)
+			c.Set("user_id", user.ID)
+			c.Set("username", user.Username)
+			c.Set("api_token_id", apiToken.ID)
+
+			c.Next()
+			return
+		}
+
 		// 检查token是否在黑名单中
 		if utils.IsTokenBlacklisted(tokenString) {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -57,7 +97,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// 验证token
-		claims, err := utils.ValidateToken(tokenString)
+		claims, err := utils.ValidateTokenFor(tokenString, allowedPurposes...)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Invalid token: " + err.Error(),
@@ -67,7 +107,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// 获取用户信息
-		user, err := utils.GetUserFromToken(tokenString)
+		user, err := utils.GetUserByClaims(claims)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "User not found: " + err.Error(),
@@ -76,10 +116,41 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 更新访问令牌对应的登录会话，已被注销或无法确认会话状态时不能继续使用
+		if claims.Purpose == "" {
+			if err := utils.TrackLoginSession(claims, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+				c.JSON(loginSessionErrorStatus(err), gin.H{
+					"error": "Invalid token: " + err.Error(),
+				})
+				c.Abort()
+				return
+			}
+		}
+
 		// 将用户信息存储到上下文中
 		c.Set("user", user)
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("token", tokenString)
+		c.Set("token_id", claims.ID)
+		c.Set("token_purpose", claims.Purpose)
+
+		c.Next()
+	}
+}
+
+// RejectAPIToken 拒绝个人API令牌的中间件
+// 用于登录凭据、多因素认证和登录会话等账号安全路由以及查看凭证明文，这些操作只能由交互式登录的用户完成，
+// 避免权限受限的令牌借此登记网关公钥、关闭二次验证、取回凭证等，扩大为账号的全部权限
+func RejectAPIToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("api_token_id"); ok {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "API tokens cannot access this endpoint",
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -295,6 +366,19 @@ func WebSocketAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 已被注销或无法确认会话状态的登录会话不能建立WebSocket连接
+		if err := utils.TrackLoginSession(claims, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+			if isWebSocketRequest(c) {
+				c.AbortWithStatus(loginSessionErrorStatus(err))
+			} else {
+				c.JSON(loginSessionErrorStatus(err), gin.H{
+					"error": "Invalid token: " + err.Error(),
+				})
+				c.Abort()
+			}
+			return
+		}
+
 		// 将用户信息存储到上下文中
 		c.Set("user", user)
 		c.Set("user_id", claims.UserID)
@@ -303,3 +387,11 @@ func WebSocketAuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// loginSessionErrorStatus 登录会话已被注销时返回401，会话存储不可用时返回503
+func loginSessionErrorStatus(err error) int {
+	if errors.Is(err, utils.ErrLoginSessionRevoked) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusServiceUnavailable
+}
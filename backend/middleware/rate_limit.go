@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"bastion/config"
+	"bastion/utils"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// rateLimitScript Redis令牌桶，多个实例共享同一客户端的配额
+// KEYS[1] 桶键；ARGV: 每毫秒补充的令牌数、桶容量、当前毫秒时间戳
+// 返回 {是否放行, 需要等待的毫秒数}
+var rateLimitScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate) + 1000)
+return {allowed, wait}
+`)
+
+// RateLimitMiddleware 按客户端IP限流，配额来自 security.rateLimit
+// requests 为每分钟补充的请求数，burst 为允许的突发请求数；未启用或Redis不可用时放行
+func RateLimitMiddleware() gin.HandlerFunc {
+	cfg := config.GlobalConfig.Security
+	if !cfg.EnableRateLimit || cfg.RateLimit.Requests <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	rate := float64(cfg.RateLimit.Requests) / float64(time.Minute.Milliseconds())
+	burst := cfg.RateLimit.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return func(c *gin.Context) {
+		// WebSocket长连接只在建立时计数一次，不做限制
+		if isWebSocketRequest(c) || utils.GetRedis() == nil {
+			c.Next()
+			return
+		}
+
+		result, err := rateLimitScript.Run(c.Request.Context(), utils.GetRedis(),
+			[]string{"ratelimit:" + c.ClientIP()},
+			rate, burst, time.Now().UnixMilli(),
+		).Int64Slice()
+		if err != nil {
+			logrus.WithError(err).Warn("限流检查失败，放行请求")
+			c.Next()
+			return
+		}
+
+		if result[0] == 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(float64(result[1])/1000))))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"error":   "Too many requests, please slow down",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
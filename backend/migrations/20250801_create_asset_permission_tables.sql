@@ -0,0 +1,125 @@
+-- ========================================
+-- 资产授权功能表结构创建脚本
+-- 创建时间：2025-08-01
+-- 功能：创建用户组及资产授权规则相关表
+-- ========================================
+
+USE bastion;
+
+-- ========================================
+-- 1. 用户组表 (user_groups)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `user_groups` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `name` varchar(100) NOT NULL COMMENT '用户组名称',
+    `description` varchar(500) DEFAULT NULL COMMENT '描述',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    `deleted_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_name` (`name`),
+    KEY `idx_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户组表';
+
+-- ========================================
+-- 2. 用户组成员表 (user_group_members)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `user_group_members` (
+    `user_group_id` bigint unsigned NOT NULL COMMENT '用户组ID',
+    `user_id` bigint unsigned NOT NULL COMMENT '用户ID',
+    PRIMARY KEY (`user_group_id`, `user_id`),
+    KEY `idx_user_id` (`user_id`),
+    CONSTRAINT `fk_ugm_group` FOREIGN KEY (`user_group_id`) REFERENCES `user_groups`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_ugm_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户组成员表';
+
+-- ========================================
+-- 3. 资产授权规则表 (asset_permissions)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `asset_permissions` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `name` varchar(100) NOT NULL COMMENT '授权规则名称',
+    `enabled` tinyint(1) DEFAULT 1 COMMENT '是否启用',
+    `valid_from` timestamp NULL DEFAULT NULL COMMENT '生效时间',
+    `valid_to` timestamp NULL DEFAULT NULL COMMENT '失效时间',
+    `remark` varchar(500) DEFAULT NULL COMMENT '备注',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    `deleted_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_name` (`name`),
+    KEY `idx_enabled` (`enabled`),
+    KEY `idx_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='资产授权规则表';
+
+-- ========================================
+-- 4. 授权规则用户关联表 (asset_permission_users)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `asset_permission_users` (
+    `permission_id` bigint unsigned NOT NULL COMMENT '授权规则ID',
+    `user_id` bigint unsigned NOT NULL COMMENT '用户ID',
+    PRIMARY KEY (`permission_id`, `user_id`),
+    KEY `idx_user_id` (`user_id`),
+    CONSTRAINT `fk_apu_permission` FOREIGN KEY (`permission_id`) REFERENCES `asset_permissions`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_apu_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='授权规则用户关联表';
+
+-- ========================================
+-- 5. 授权规则角色关联表 (asset_permission_roles)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `asset_permission_roles` (
+    `permission_id` bigint unsigned NOT NULL COMMENT '授权规则ID',
+    `role_id` bigint unsigned NOT NULL COMMENT '角色ID',
+    PRIMARY KEY (`permission_id`, `role_id`),
+    KEY `idx_role_id` (`role_id`),
+    CONSTRAINT `fk_apr_permission` FOREIGN KEY (`permission_id`) REFERENCES `asset_permissions`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_apr_role` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='授权规则角色关联表';
+
+-- ========================================
+-- 6. 授权规则用户组关联表 (asset_permission_user_groups)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `asset_permission_user_groups` (
+    `permission_id` bigint unsigned NOT NULL COMMENT '授权规则ID',
+    `user_group_id` bigint unsigned NOT NULL COMMENT '用户组ID',
+    PRIMARY KEY (`permission_id`, `user_group_id`),
+    KEY `idx_user_group_id` (`user_group_id`),
+    CONSTRAINT `fk_apug_permission` FOREIGN KEY (`permission_id`) REFERENCES `asset_permissions`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_apug_group` FOREIGN KEY (`user_group_id`) REFERENCES `user_groups`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='授权规则用户组关联表';
+
+-- ========================================
+-- 7. 授权规则资产关联表 (asset_permission_assets)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `asset_permission_assets` (
+    `permission_id` bigint unsigned NOT NULL COMMENT '授权规则ID',
+    `asset_id` bigint unsigned NOT NULL COMMENT '资产ID',
+    PRIMARY KEY (`permission_id`, `asset_id`),
+    KEY `idx_asset_id` (`asset_id`),
+    CONSTRAINT `fk_apa_permission` FOREIGN KEY (`permission_id`) REFERENCES `asset_permissions`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_apa_asset` FOREIGN KEY (`asset_id`) REFERENCES `assets`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='授权规则资产关联表';
+
+-- ========================================
+-- 8. 授权规则资产分组关联表 (asset_permission_asset_groups)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `asset_permission_asset_groups` (
+    `permission_id` bigint unsigned NOT NULL COMMENT '授权规则ID',
+    `asset_group_id` bigint unsigned NOT NULL COMMENT '资产分组ID',
+    PRIMARY KEY (`permission_id`, `asset_group_id`),
+    KEY `idx_asset_group_id` (`asset_group_id`),
+    CONSTRAINT `fk_apag_permission` FOREIGN KEY (`permission_id`) REFERENCES `asset_permissions`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_apag_group` FOREIGN KEY (`asset_group_id`) REFERENCES `asset_groups`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='授权规则资产分组关联表';
+
+-- ========================================
+-- 9. 授权规则凭证关联表 (asset_permission_credentials)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `asset_permission_credentials` (
+    `permission_id` bigint unsigned NOT NULL COMMENT '授权规则ID',
+    `credential_id` bigint unsigned NOT NULL COMMENT '凭证ID',
+    PRIMARY KEY (`permission_id`, `credential_id`),
+    KEY `idx_credential_id` (`credential_id`),
+    CONSTRAINT `fk_apc_permission` FOREIGN KEY (`permission_id`) REFERENCES `asset_permissions`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_apc_credential` FOREIGN KEY (`credential_id`) REFERENCES `credentials`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='授权规则凭证关联表';
@@ -0,0 +1,23 @@
+-- ========================================
+-- SSH网关登录公钥表创建脚本
+-- 创建时间：2025-08-02
+-- 功能：保存用户登录SSH网关使用的公钥
+-- ========================================
+
+USE bastion;
+
+CREATE TABLE IF NOT EXISTS `user_ssh_keys` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL COMMENT '用户ID',
+    `name` varchar(100) NOT NULL COMMENT '公钥名称',
+    `key_type` varchar(50) NOT NULL COMMENT '密钥类型',
+    `public_key` text NOT NULL COMMENT '公钥内容(authorized_keys格式)',
+    `fingerprint` varchar(100) NOT NULL COMMENT 'SHA256指纹',
+    `last_used_at` timestamp NULL DEFAULT NULL COMMENT '最后使用时间',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_fingerprint` (`fingerprint`),
+    KEY `idx_user_id` (`user_id`),
+    CONSTRAINT `fk_usk_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户SSH公钥表';
@@ -0,0 +1,47 @@
+-- ========================================
+-- 文件传输审计表结构创建脚本
+-- 创建时间：2025-08-03
+-- 功能：记录SFTP与Web会话的文件上传下载，并为授权规则增加上传/下载控制
+-- ========================================
+
+USE bastion;
+
+-- ========================================
+-- 1. 授权规则增加文件传输控制字段
+-- ========================================
+ALTER TABLE `asset_permissions`
+    ADD COLUMN `allow_upload` tinyint(1) NOT NULL DEFAULT 1 COMMENT '是否允许上传文件' AFTER `enabled`,
+    ADD COLUMN `allow_download` tinyint(1) NOT NULL DEFAULT 1 COMMENT '是否允许下载文件' AFTER `allow_upload`;
+
+-- ========================================
+-- 2. 文件传输日志表 (file_transfer_logs)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `file_transfer_logs` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `session_id` varchar(100) NOT NULL COMMENT '会话ID',
+    `user_id` bigint unsigned NOT NULL COMMENT '用户ID',
+    `username` varchar(50) NOT NULL COMMENT '用户名',
+    `asset_id` bigint unsigned NOT NULL COMMENT '资产ID',
+    `asset_name` varchar(100) NOT NULL COMMENT '资产名称',
+    `credential_id` bigint unsigned DEFAULT NULL COMMENT '凭证ID',
+    `channel` varchar(10) NOT NULL COMMENT '传输通道(sftp/web)',
+    `direction` varchar(10) NOT NULL COMMENT '传输方向(upload/download)',
+    `filename` varchar(255) NOT NULL COMMENT '文件名',
+    `remote_path` varchar(1024) NOT NULL COMMENT '资产上的文件路径',
+    `size` bigint DEFAULT 0 COMMENT '传输字节数',
+    `sha256` varchar(64) DEFAULT NULL COMMENT '文件SHA-256摘要',
+    `status` varchar(20) NOT NULL COMMENT '传输状态(success/failed/denied)',
+    `message` varchar(500) DEFAULT NULL COMMENT '说明',
+    `client_ip` varchar(45) DEFAULT NULL COMMENT '客户端IP',
+    `start_time` timestamp NULL DEFAULT NULL COMMENT '开始时间',
+    `end_time` timestamp NULL DEFAULT NULL COMMENT '结束时间',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_session_id` (`session_id`),
+    KEY `idx_user_id` (`user_id`),
+    KEY `idx_asset_id` (`asset_id`),
+    KEY `idx_direction` (`direction`),
+    KEY `idx_status` (`status`),
+    KEY `idx_start_time` (`start_time`),
+    CONSTRAINT `fk_ftl_session` FOREIGN KEY (`session_id`) REFERENCES `session_records`(`session_id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='文件传输日志表';
@@ -0,0 +1,45 @@
+-- ========================================
+-- 多因素认证表结构创建脚本
+-- 创建时间：2025-08-04
+-- 功能：TOTP认证器绑定、一次性恢复码，以及按角色强制MFA
+-- ========================================
+
+USE bastion;
+
+-- ========================================
+-- 1. 角色增加强制MFA字段
+-- ========================================
+ALTER TABLE `roles`
+    ADD COLUMN `mfa_required` tinyint(1) NOT NULL DEFAULT 0 COMMENT '该角色用户登录是否强制MFA' AFTER `description`;
+
+-- ========================================
+-- 2. 用户MFA配置表 (user_mfa)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `user_mfa` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL COMMENT '用户ID',
+    `secret` varchar(255) NOT NULL COMMENT 'TOTP密钥(加密存储)',
+    `enabled` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否已完成绑定',
+    `enabled_at` timestamp NULL DEFAULT NULL COMMENT '绑定时间',
+    `last_used_step` bigint NOT NULL DEFAULT 0 COMMENT '最近一次通过验证的时间步，防止验证码重放',
+    `last_used_at` timestamp NULL DEFAULT NULL COMMENT '最后验证时间',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_user_id` (`user_id`),
+    CONSTRAINT `fk_user_mfa_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户MFA配置表';
+
+-- ========================================
+-- 3. MFA恢复码表 (user_mfa_recovery_codes)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `user_mfa_recovery_codes` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL COMMENT '用户ID',
+    `code_hash` varchar(64) NOT NULL COMMENT '恢复码SHA-256摘要',
+    `used_at` timestamp NULL DEFAULT NULL COMMENT '使用时间',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_user_id` (`user_id`),
+    CONSTRAINT `fk_mfa_recovery_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='MFA恢复码表';
@@ -0,0 +1,12 @@
+-- ========================================
+-- 用户认证来源字段添加脚本
+-- 创建时间：2025-08-05
+-- 功能：区分本地账号与LDAP/AD账号，记录目录中的用户DN
+-- ========================================
+
+USE bastion;
+
+ALTER TABLE `users`
+    ADD COLUMN `auth_source` varchar(20) NOT NULL DEFAULT 'local' COMMENT '认证来源(local/ldap)' AFTER `status`,
+    ADD COLUMN `external_id` varchar(255) DEFAULT NULL COMMENT '外部目录中的用户标识，如LDAP DN' AFTER `auth_source`,
+    ADD KEY `idx_auth_source` (`auth_source`);
@@ -0,0 +1,52 @@
+-- ========================================
+-- 密码策略表结构创建脚本
+-- 创建时间：2025-08-06
+-- 功能：可配置的密码复杂度、禁用词、历史密码与有效期，过期后登录只能修改密码
+-- ========================================
+
+USE bastion;
+
+-- ========================================
+-- 1. 用户表增加密码修改时间
+-- ========================================
+ALTER TABLE `users`
+    ADD COLUMN `password_changed_at` timestamp NULL DEFAULT NULL COMMENT '最后修改密码时间，为空时以创建时间计算有效期' AFTER `password`;
+
+-- ========================================
+-- 2. 密码策略表 (password_policies)，全局只有一条记录
+-- ========================================
+CREATE TABLE IF NOT EXISTS `password_policies` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `min_length` int NOT NULL DEFAULT 6 COMMENT '最小长度',
+    `require_uppercase` tinyint(1) NOT NULL DEFAULT 0 COMMENT '必须包含大写字母',
+    `require_lowercase` tinyint(1) NOT NULL DEFAULT 0 COMMENT '必须包含小写字母',
+    `require_digit` tinyint(1) NOT NULL DEFAULT 1 COMMENT '必须包含数字',
+    `require_special` tinyint(1) NOT NULL DEFAULT 0 COMMENT '必须包含特殊字符',
+    `min_classes` int NOT NULL DEFAULT 2 COMMENT '至少包含的字符类别数(大写/小写/数字/特殊字符)',
+    `forbid_username` tinyint(1) NOT NULL DEFAULT 1 COMMENT '不能包含用户名',
+    `forbid_common` tinyint(1) NOT NULL DEFAULT 1 COMMENT '不能使用常见弱密码',
+    `dictionary_words` text COMMENT '自定义禁用词，逗号分隔，如公司名',
+    `history_count` int NOT NULL DEFAULT 0 COMMENT '不能与最近N次使用过的密码相同',
+    `max_age_days` int NOT NULL DEFAULT 0 COMMENT '密码有效期(天)，0表示永不过期',
+    `updated_by` varchar(50) DEFAULT NULL COMMENT '最后修改人',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='密码策略表';
+
+-- 默认策略与原有的密码强度要求一致：至少6位，包含字母和数字
+INSERT IGNORE INTO `password_policies` (`id`, `min_length`, `require_digit`, `min_classes`, `forbid_username`, `forbid_common`)
+VALUES (1, 6, 1, 2, 1, 1);
+
+-- ========================================
+-- 3. 历史密码表 (password_histories)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `password_histories` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL COMMENT '用户ID',
+    `password_hash` varchar(255) NOT NULL COMMENT '历史密码哈希',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_user_id` (`user_id`),
+    CONSTRAINT `fk_ph_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='历史密码表';
@@ -0,0 +1,26 @@
+-- ========================================
+-- 个人API令牌表创建脚本
+-- 创建时间：2025-08-07
+-- 功能：保存用于脚本和CI的个人API令牌，只保存令牌摘要
+-- ========================================
+
+USE bastion;
+
+CREATE TABLE IF NOT EXISTS `api_tokens` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL COMMENT '用户ID',
+    `name` varchar(100) NOT NULL COMMENT '令牌名称',
+    `token_prefix` varchar(20) NOT NULL COMMENT '令牌前缀，用于识别',
+    `token_hash` varchar(64) NOT NULL COMMENT '令牌SHA-256摘要',
+    `permissions` text NOT NULL COMMENT '权限范围，逗号分隔，all表示拥有者全部权限',
+    `expires_at` timestamp NULL DEFAULT NULL COMMENT '过期时间，为空表示永不过期',
+    `last_used_at` timestamp NULL DEFAULT NULL COMMENT '最后使用时间',
+    `last_used_ip` varchar(45) DEFAULT NULL COMMENT '最后使用IP',
+    `revoked_at` timestamp NULL DEFAULT NULL COMMENT '吊销时间',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_token_hash` (`token_hash`),
+    KEY `idx_user_id` (`user_id`),
+    CONSTRAINT `fk_at_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='个人API令牌表';
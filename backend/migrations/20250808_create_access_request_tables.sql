@@ -0,0 +1,42 @@
+-- ========================================
+-- 临时访问申请表创建脚本
+-- 创建时间：2025-08-08
+-- 功能：用户申请限定时间窗口的资产访问，审批通过后生成临时资产授权规则
+-- ========================================
+
+USE bastion;
+
+CREATE TABLE IF NOT EXISTS `access_requests` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `user_id` bigint unsigned NOT NULL COMMENT '申请人ID',
+    `asset_id` bigint unsigned DEFAULT NULL COMMENT '申请访问的资产ID',
+    `asset_group_id` bigint unsigned DEFAULT NULL COMMENT '申请访问的资产分组ID',
+    `reason` text NOT NULL COMMENT '申请理由',
+    `start_at` timestamp NOT NULL COMMENT '访问开始时间',
+    `end_at` timestamp NOT NULL COMMENT '访问结束时间',
+    `status` varchar(20) NOT NULL COMMENT '状态：pending/approved/rejected/cancelled/expired',
+    `required_approvals` int NOT NULL DEFAULT 1 COMMENT '需要的批准人数',
+    `permission_id` bigint unsigned DEFAULT NULL COMMENT '审批通过后生成的资产授权规则ID',
+    `closed_at` timestamp NULL DEFAULT NULL COMMENT '拒绝、撤回或过期的时间',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_user_id` (`user_id`),
+    KEY `idx_asset_id` (`asset_id`),
+    KEY `idx_asset_group_id` (`asset_group_id`),
+    KEY `idx_status_end_at` (`status`, `end_at`),
+    CONSTRAINT `fk_ar_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='临时访问申请表';
+
+CREATE TABLE IF NOT EXISTS `access_request_approvals` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `request_id` bigint unsigned NOT NULL COMMENT '申请ID',
+    `approver_id` bigint unsigned NOT NULL COMMENT '审批人ID',
+    `approver_name` varchar(50) NOT NULL COMMENT '审批人用户名',
+    `decision` varchar(20) NOT NULL COMMENT '审批决定：approve/reject',
+    `comment` varchar(500) DEFAULT NULL COMMENT '审批意见',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_request_approver` (`request_id`, `approver_id`),
+    CONSTRAINT `fk_ara_request` FOREIGN KEY (`request_id`) REFERENCES `access_requests`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='临时访问申请审批记录表';
@@ -0,0 +1,67 @@
+-- ========================================
+-- 访问策略表创建脚本
+-- 创建时间：2025-08-09
+-- 功能：按星期、时间段和客户端地址段允许或拒绝登录与建立会话
+-- ========================================
+
+USE bastion;
+
+-- ========================================
+-- 1. 访问策略表 (access_policies)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `access_policies` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `name` varchar(100) NOT NULL COMMENT '策略名称',
+    `description` varchar(500) DEFAULT NULL COMMENT '描述',
+    `enabled` tinyint(1) DEFAULT 1 COMMENT '是否启用',
+    `action` varchar(10) NOT NULL COMMENT '动作：allow/deny',
+    `scope` varchar(20) NOT NULL COMMENT '作用范围：login/session/all',
+    `weekdays` varchar(20) DEFAULT NULL COMMENT '星期，逗号分隔，0为周日，为空表示每天',
+    `start_time` varchar(5) DEFAULT NULL COMMENT '开始时间 HH:MM，为空表示全天',
+    `end_time` varchar(5) DEFAULT NULL COMMENT '结束时间 HH:MM，早于开始时间表示跨天',
+    `time_zone` varchar(64) DEFAULT NULL COMMENT '时区，为空表示服务器时区',
+    `cidrs` text COMMENT '客户端地址段，逗号分隔，为空表示任意地址',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    `deleted_at` timestamp NULL DEFAULT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_name` (`name`),
+    KEY `idx_enabled` (`enabled`),
+    KEY `idx_deleted_at` (`deleted_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='访问策略表';
+
+-- ========================================
+-- 2. 访问策略用户关联表 (access_policy_users)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `access_policy_users` (
+    `policy_id` bigint unsigned NOT NULL COMMENT '访问策略ID',
+    `user_id` bigint unsigned NOT NULL COMMENT '用户ID',
+    PRIMARY KEY (`policy_id`, `user_id`),
+    KEY `idx_user_id` (`user_id`),
+    CONSTRAINT `fk_apolu_policy` FOREIGN KEY (`policy_id`) REFERENCES `access_policies`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_apolu_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='访问策略用户关联表';
+
+-- ========================================
+-- 3. 访问策略角色关联表 (access_policy_roles)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `access_policy_roles` (
+    `policy_id` bigint unsigned NOT NULL COMMENT '访问策略ID',
+    `role_id` bigint unsigned NOT NULL COMMENT '角色ID',
+    PRIMARY KEY (`policy_id`, `role_id`),
+    KEY `idx_role_id` (`role_id`),
+    CONSTRAINT `fk_apolr_policy` FOREIGN KEY (`policy_id`) REFERENCES `access_policies`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_apolr_role` FOREIGN KEY (`role_id`) REFERENCES `roles`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='访问策略角色关联表';
+
+-- ========================================
+-- 4. 访问策略资产关联表 (access_policy_assets)
+-- ========================================
+CREATE TABLE IF NOT EXISTS `access_policy_assets` (
+    `policy_id` bigint unsigned NOT NULL COMMENT '访问策略ID',
+    `asset_id` bigint unsigned NOT NULL COMMENT '资产ID',
+    PRIMARY KEY (`policy_id`, `asset_id`),
+    KEY `idx_asset_id` (`asset_id`),
+    CONSTRAINT `fk_apola_policy` FOREIGN KEY (`policy_id`) REFERENCES `access_policies`(`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_apola_asset` FOREIGN KEY (`asset_id`) REFERENCES `assets`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='访问策略资产关联表';
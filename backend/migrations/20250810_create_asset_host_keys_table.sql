@@ -0,0 +1,27 @@
+-- ========================================
+-- 资产主机密钥表创建脚本
+-- 创建时间：2025-08-10
+-- 功能：登记资产SSH主机密钥，连接时校验以防止中间人攻击
+-- ========================================
+
+USE bastion;
+
+CREATE TABLE IF NOT EXISTS `asset_host_keys` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `asset_id` bigint unsigned NOT NULL COMMENT '资产ID',
+    `key_type` varchar(50) NOT NULL COMMENT '密钥类型',
+    `public_key` text NOT NULL COMMENT '公钥，authorized_keys格式',
+    `fingerprint` varchar(100) NOT NULL COMMENT 'SHA256指纹',
+    `status` varchar(20) NOT NULL COMMENT '状态：trusted/pending',
+    `source` varchar(20) DEFAULT NULL COMMENT '来源：first_use/manual/accepted',
+    `accepted_by` bigint unsigned DEFAULT NULL COMMENT '预置或确认的管理员ID',
+    `accepted_by_name` varchar(50) DEFAULT NULL COMMENT '预置或确认的管理员用户名',
+    `first_seen_at` timestamp NULL DEFAULT NULL COMMENT '首次出现时间',
+    `last_seen_at` timestamp NULL DEFAULT NULL COMMENT '最近一次连接时出现的时间',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_asset_fingerprint` (`asset_id`, `fingerprint`),
+    KEY `idx_status` (`status`),
+    CONSTRAINT `fk_ahk_asset` FOREIGN KEY (`asset_id`) REFERENCES `assets`(`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='资产SSH主机密钥表';
@@ -0,0 +1,22 @@
+-- ========================================
+-- SSH证书颁发机构表创建脚本
+-- 创建时间：2025-08-11
+-- 功能：堡垒机作为SSH CA为cert类型凭证签发短期用户证书
+-- ========================================
+
+USE bastion;
+
+CREATE TABLE IF NOT EXISTS `ssh_certificate_authorities` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `key_type` varchar(50) NOT NULL COMMENT '密钥类型',
+    `public_key` text NOT NULL COMMENT 'CA公钥，authorized_keys格式',
+    `private_key` text NOT NULL COMMENT 'CA私钥，加密存储',
+    `fingerprint` varchar(100) NOT NULL COMMENT 'SHA256指纹',
+    `status` varchar(20) NOT NULL COMMENT '状态：active/retired',
+    `retired_at` timestamp NULL DEFAULT NULL COMMENT '轮换时间',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_fingerprint` (`fingerprint`),
+    KEY `idx_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='SSH证书颁发机构表';
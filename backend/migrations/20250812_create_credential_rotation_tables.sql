@@ -0,0 +1,43 @@
+-- ========================================
+-- 凭证自动改密表创建脚本
+-- 创建时间：2025-08-12
+-- 功能：按策略定时或立即修改凭证关联Linux资产的账号密码，并记录改密结果
+-- ========================================
+
+USE bastion;
+
+-- 改密策略表，每个凭证最多一条
+CREATE TABLE IF NOT EXISTS `credential_rotation_policies` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `credential_id` bigint unsigned NOT NULL COMMENT '凭证ID',
+    `enabled` tinyint(1) DEFAULT 1 COMMENT '是否启用',
+    `interval_days` int NOT NULL COMMENT '改密周期，天',
+    `password_length` int NOT NULL COMMENT '新密码长度',
+    `next_run_at` timestamp NULL DEFAULT NULL COMMENT '下次改密时间',
+    `last_run_at` timestamp NULL DEFAULT NULL COMMENT '上次改密时间',
+    `last_status` varchar(20) DEFAULT NULL COMMENT '上次改密结果',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_credential_id` (`credential_id`),
+    KEY `idx_next_run_at` (`next_run_at`),
+    CONSTRAINT `fk_rotation_policies_credential` FOREIGN KEY (`credential_id`) REFERENCES `credentials` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='凭证改密策略表';
+
+-- 改密记录表
+CREATE TABLE IF NOT EXISTS `credential_rotation_histories` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `credential_id` bigint unsigned NOT NULL COMMENT '凭证ID',
+    `trigger` varchar(20) NOT NULL COMMENT '触发方式：manual/scheduled',
+    `status` varchar(20) NOT NULL COMMENT '结果：success/failed/rolled_back/rollback_failed',
+    `message` text COMMENT '结果说明',
+    `asset_ids` varchar(500) DEFAULT NULL COMMENT '改密的资产ID，逗号分隔',
+    `operator_id` bigint unsigned DEFAULT NULL COMMENT '操作人ID，定时改密为空',
+    `operator_name` varchar(50) DEFAULT NULL COMMENT '操作人用户名',
+    `started_at` timestamp NOT NULL COMMENT '开始时间',
+    `finished_at` timestamp NULL DEFAULT NULL COMMENT '结束时间',
+    PRIMARY KEY (`id`),
+    KEY `idx_credential_id` (`credential_id`),
+    KEY `idx_status` (`status`),
+    CONSTRAINT `fk_rotation_histories_credential` FOREIGN KEY (`credential_id`) REFERENCES `credentials` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='凭证改密记录表';
@@ -0,0 +1,13 @@
+-- ========================================
+-- 密钥凭证轮换字段
+-- 创建时间：2025-08-13
+-- 功能：改密策略支持指定新密钥类型，改密记录保存各资产的执行结果
+-- ========================================
+
+USE bastion;
+
+ALTER TABLE `credential_rotation_policies`
+    ADD COLUMN `key_type` varchar(20) DEFAULT NULL COMMENT '密钥凭证生成的新密钥类型：rsa/ed25519，为空时与当前密钥相同' AFTER `password_length`;
+
+ALTER TABLE `credential_rotation_histories`
+    ADD COLUMN `results` json DEFAULT NULL COMMENT '各资产改密结果' AFTER `asset_ids`;
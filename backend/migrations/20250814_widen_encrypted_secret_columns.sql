@@ -0,0 +1,14 @@
+-- ========================================
+-- 加密字段扩容脚本
+-- 创建时间：2025-08-14
+-- 功能：信封加密的密文包含被主密钥包装的数据密钥，长度大于旧格式，扩大相关字段
+-- 执行后使用 go run ./cmd/secrets reencrypt 将旧格式数据及明文私钥转为信封加密
+-- ========================================
+
+USE bastion;
+
+ALTER TABLE `credentials`
+    MODIFY COLUMN `password` varchar(512) DEFAULT NULL;
+
+ALTER TABLE `user_mfa`
+    MODIFY COLUMN `secret` varchar(512) NOT NULL COMMENT 'TOTP密钥(加密存储)';
@@ -0,0 +1,35 @@
+-- ========================================
+-- 凭证借出表创建脚本
+-- 创建时间：2025-08-15
+-- 功能：凭证借出申请、审批、限时查看明文，归还或到期后自动改密
+-- ========================================
+
+USE bastion;
+
+CREATE TABLE IF NOT EXISTS `credential_checkouts` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `credential_id` bigint unsigned NOT NULL COMMENT '凭证ID',
+    `user_id` bigint unsigned NOT NULL COMMENT '借出人ID',
+    `reason` text NOT NULL COMMENT '借出理由',
+    `duration` int NOT NULL COMMENT '借出时长，秒',
+    `status` varchar(20) NOT NULL COMMENT '状态',
+    `approver_id` bigint unsigned DEFAULT NULL COMMENT '审批人ID',
+    `approver_name` varchar(50) DEFAULT NULL COMMENT '审批人用户名',
+    `approval_comment` varchar(500) DEFAULT NULL COMMENT '审批意见',
+    `checked_out_at` timestamp NULL DEFAULT NULL COMMENT '借出时间',
+    `expires_at` timestamp NULL DEFAULT NULL COMMENT '借出到期时间',
+    `closed_at` timestamp NULL DEFAULT NULL COMMENT '拒绝、撤回、归还或过期的时间',
+    `reveal_count` int NOT NULL DEFAULT 0 COMMENT '查看明文次数',
+    `last_revealed_at` timestamp NULL DEFAULT NULL COMMENT '最近一次查看明文的时间',
+    `rotation_status` varchar(20) DEFAULT NULL COMMENT '归还后的改密结果',
+    `rotation_history_id` bigint unsigned DEFAULT NULL COMMENT '归还后的改密记录ID',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_credential_id` (`credential_id`),
+    KEY `idx_user_id` (`user_id`),
+    KEY `idx_status` (`status`),
+    KEY `idx_expires_at` (`expires_at`),
+    CONSTRAINT `fk_credential_checkouts_credential` FOREIGN KEY (`credential_id`) REFERENCES `credentials` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `fk_credential_checkouts_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='凭证借出表';
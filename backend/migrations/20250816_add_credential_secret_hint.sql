@@ -0,0 +1,12 @@
+-- ========================================
+-- 凭证提示字段
+-- 创建时间：2025-08-16
+-- 功能：凭证的密码和私钥不再出现在任何API响应中，响应改为返回非敏感提示：
+--       密钥凭证为公钥SHA256指纹，密码凭证为掩码后的密码。
+--       已有凭证在下次修改或改密时生成提示。
+-- ========================================
+
+USE bastion;
+
+ALTER TABLE `credentials`
+    ADD COLUMN `secret_hint` varchar(100) DEFAULT NULL COMMENT '密钥指纹或掩码后的密码提示' AFTER `private_key`;
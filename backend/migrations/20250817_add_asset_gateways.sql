@@ -0,0 +1,31 @@
+-- ========================================
+-- 资产网关（跳板机）与网络区域
+-- 创建时间：2025-08-17
+-- 功能：资产可以指定经由哪台网关资产（及登录网关的凭证）连接，未指定时使用所在网络区域的网关；
+--       网关本身也可以经由其他网关连接，形成多级链路。会话记录保存连接实际经过的网关链路。
+-- ========================================
+
+USE bastion;
+
+CREATE TABLE IF NOT EXISTS `network_zones` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `name` varchar(50) NOT NULL COMMENT '区域名称',
+    `description` text COMMENT '描述',
+    `gateway_id` bigint unsigned DEFAULT NULL COMMENT '区域网关资产ID，为空表示直连',
+    `gateway_credential_id` bigint unsigned DEFAULT NULL COMMENT '登录区域网关使用的凭证ID',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_network_zones_name` (`name`),
+    KEY `idx_network_zones_gateway_id` (`gateway_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='网络区域表';
+
+ALTER TABLE `assets`
+    ADD COLUMN `zone_id` bigint unsigned DEFAULT NULL COMMENT '网络区域ID' AFTER `group_id`,
+    ADD COLUMN `gateway_id` bigint unsigned DEFAULT NULL COMMENT '网关资产ID' AFTER `zone_id`,
+    ADD COLUMN `gateway_credential_id` bigint unsigned DEFAULT NULL COMMENT '登录网关使用的凭证ID' AFTER `gateway_id`,
+    ADD KEY `idx_assets_zone_id` (`zone_id`),
+    ADD KEY `idx_assets_gateway_id` (`gateway_id`);
+
+ALTER TABLE `session_records`
+    ADD COLUMN `gateway_chain` text DEFAULT NULL COMMENT '连接经过的网关链路，直连时为空' AFTER `close_reason`;
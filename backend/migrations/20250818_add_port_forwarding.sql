@@ -0,0 +1,11 @@
+-- ========================================
+-- 端口转发（隧道）
+-- 创建时间：2025-08-18
+-- 功能：授权规则增加允许端口转发的端口列表（如 80,443,8000-8100，为空表示禁止转发）。
+--       SSH网关的 direct-tcpip 转发与Web会话隧道以 tunnel 协议写入会话记录。
+-- ========================================
+
+USE bastion;
+
+ALTER TABLE `asset_permissions`
+    ADD COLUMN `forward_ports` varchar(500) NOT NULL DEFAULT '' COMMENT '允许端口转发的端口，如 80,443,8000-8100，为空表示禁止转发' AFTER `allow_download`;
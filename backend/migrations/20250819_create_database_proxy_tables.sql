@@ -0,0 +1,70 @@
+-- ========================================
+-- 数据库协议代理（MySQL）与SQL审计
+-- 创建时间：2025-08-19
+-- 功能：原生数据库客户端使用堡垒机用户名和连接令牌登录代理，代理以资产凭证登录数据库。
+--       每个连接以数据库协议写入会话记录；每条语句经SQL过滤规则检查后写入SQL审计日志。
+-- ========================================
+
+USE bastion;
+
+CREATE TABLE IF NOT EXISTS `sql_filter_rules` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `name` varchar(100) NOT NULL COMMENT '规则名称',
+    `priority` int NOT NULL DEFAULT 50 COMMENT '优先级，1-100，数字越小优先级越高',
+    `enabled` tinyint(1) NOT NULL DEFAULT 1 COMMENT '是否启用',
+    `protocol` varchar(20) NOT NULL DEFAULT 'all' COMMENT '适用的数据库协议，all表示全部',
+    `match_type` varchar(20) NOT NULL COMMENT '匹配方式: statement-语句类型, regex-正则表达式, no_where-不带WHERE条件',
+    `pattern` varchar(500) NOT NULL COMMENT '语句类型列表或正则表达式',
+    `action` varchar(20) NOT NULL COMMENT '动作: deny-拒绝, alert-告警, allow-放行',
+    `remark` varchar(500) DEFAULT NULL COMMENT '备注',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` timestamp DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_sql_filter_rules_name` (`name`),
+    KEY `idx_sql_filter_rules_priority` (`priority`),
+    KEY `idx_sql_filter_rules_enabled` (`enabled`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='SQL过滤规则表';
+
+CREATE TABLE IF NOT EXISTS `sql_filter_rule_assets` (
+    `rule_id` bigint unsigned NOT NULL,
+    `asset_id` bigint unsigned NOT NULL,
+    PRIMARY KEY (`rule_id`, `asset_id`),
+    KEY `idx_sql_filter_rule_assets_asset_id` (`asset_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='SQL过滤规则关联资产表，无关联时对所有资产生效';
+
+CREATE TABLE IF NOT EXISTS `sql_audit_logs` (
+    `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+    `session_id` varchar(100) NOT NULL COMMENT '会话ID',
+    `user_id` bigint unsigned NOT NULL COMMENT '用户ID',
+    `username` varchar(50) NOT NULL COMMENT '用户名',
+    `asset_id` bigint unsigned NOT NULL COMMENT '资产ID',
+    `asset_name` varchar(100) NOT NULL COMMENT '资产名称',
+    `account` varchar(100) DEFAULT NULL COMMENT '数据库账号',
+    `protocol` varchar(20) NOT NULL COMMENT '数据库协议',
+    `database_name` varchar(100) DEFAULT NULL COMMENT '当前数据库',
+    `statement` mediumtext NOT NULL COMMENT 'SQL语句',
+    `statement_type` varchar(30) DEFAULT NULL COMMENT '语句类型',
+    `action` varchar(20) NOT NULL COMMENT '过滤动作(allow/alert/deny)',
+    `rule_id` bigint unsigned DEFAULT NULL COMMENT '命中的过滤规则ID',
+    `rule_name` varchar(100) DEFAULT NULL COMMENT '命中的过滤规则名称',
+    `status` varchar(20) NOT NULL COMMENT '执行状态(success/error/denied)',
+    `affected_rows` bigint NOT NULL DEFAULT 0 COMMENT '影响行数',
+    `message` varchar(500) DEFAULT NULL COMMENT '错误信息',
+    `client_ip` varchar(45) DEFAULT NULL COMMENT '客户端IP',
+    `duration` bigint NOT NULL DEFAULT 0 COMMENT '执行耗时，毫秒',
+    `created_at` timestamp DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (`id`),
+    KEY `idx_sql_audit_logs_session_id` (`session_id`),
+    KEY `idx_sql_audit_logs_user_id` (`user_id`),
+    KEY `idx_sql_audit_logs_asset_id` (`asset_id`),
+    KEY `idx_sql_audit_logs_statement_type` (`statement_type`),
+    KEY `idx_sql_audit_logs_action` (`action`),
+    KEY `idx_sql_audit_logs_status` (`status`),
+    KEY `idx_sql_audit_logs_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='SQL审计日志表';
+
+-- 默认规则：拒绝删除表/清空表与不带条件的删除，不带条件的更新告警
+INSERT IGNORE INTO `sql_filter_rules` (`name`, `priority`, `enabled`, `protocol`, `match_type`, `pattern`, `action`, `remark`) VALUES
+('禁止删除或清空表', 10, 1, 'all', 'statement', 'DROP,TRUNCATE', 'deny', '系统默认规则'),
+('禁止无条件删除', 20, 1, 'all', 'no_where', 'DELETE', 'deny', '系统默认规则'),
+('无条件更新告警', 30, 1, 'all', 'no_where', 'UPDATE', 'alert', '系统默认规则');
@@ -0,0 +1,29 @@
+-- ========================================
+-- Redis协议代理的高危命令过滤
+-- 创建时间：2025-08-20
+-- 功能：Redis命令与SSH会话共用命令组和命令过滤规则（命令名统一为大写，参数以空格分隔，
+--       如 "KEYS *"、"CONFIG SET maxmemory ***"）。新增高危命令组并默认拒绝。
+-- ========================================
+
+USE bastion;
+
+INSERT IGNORE INTO `command_groups` (`name`, `remark`) VALUES
+('Redis高危命令', '系统默认命令组：清空数据、全量遍历键与修改服务器配置');
+
+INSERT INTO `command_group_items` (`command_group_id`, `type`, `content`, `ignore_case`, `sort_order`)
+SELECT g.`id`, i.`type`, i.`content`, 1, i.`sort_order`
+FROM `command_groups` g
+JOIN (
+    SELECT 'command' AS `type`, 'FLUSHALL' AS `content`, 1 AS `sort_order`
+    UNION ALL SELECT 'command', 'FLUSHDB', 2
+    UNION ALL SELECT 'regex', '^KEYS\\s+\\*$', 3
+    UNION ALL SELECT 'regex', '^CONFIG\\s+SET\\b', 4
+) i
+WHERE g.`name` = 'Redis高危命令'
+  AND NOT EXISTS (SELECT 1 FROM `command_group_items` x WHERE x.`command_group_id` = g.`id`);
+
+INSERT INTO `command_filters` (`name`, `priority`, `enabled`, `user_type`, `asset_type`, `account_type`, `command_group_id`, `action`, `remark`)
+SELECT '禁止Redis高危命令', 10, 1, 'all', 'all', 'all', g.`id`, 'deny', '系统默认规则'
+FROM `command_groups` g
+WHERE g.`name` = 'Redis高危命令'
+  AND NOT EXISTS (SELECT 1 FROM `command_filters` f WHERE f.`command_group_id` = g.`id`);
@@ -0,0 +1,15 @@
+-- ========================================
+-- 清除泄露密码字符的凭证提示
+-- 创建时间：2025-08-21
+-- 功能：旧的密码凭证提示保留了密码末尾两个字符，改为只给出密码长度区间（如 "****(12-15)"）。
+--       这里清除所有密码凭证的旧提示，服务启动时解密凭证重新生成提示。
+-- ========================================
+
+USE bastion;
+
+UPDATE `credentials`
+SET `secret_hint` = NULL
+WHERE `type` = 'password';
+
+ALTER TABLE `credentials`
+    MODIFY COLUMN `secret_hint` varchar(100) DEFAULT NULL COMMENT '密钥指纹或密码长度区间提示';
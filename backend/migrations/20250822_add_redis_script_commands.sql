@@ -0,0 +1,33 @@
+-- ========================================
+-- Redis高危命令组补充脚本类命令
+-- 创建时间：2025-08-22
+-- 功能：EVAL/FCALL 等命令在服务端执行脚本，脚本内可调用 FLUSHALL 等任意命令，
+--       代理无法逐条检查脚本内部的调用，因此默认与其他高危命令一同拒绝；
+--       SCRIPT、FUNCTION 用于加载脚本和函数，一并拒绝。
+-- ========================================
+
+USE bastion;
+
+INSERT INTO `command_group_items` (`command_group_id`, `type`, `content`, `ignore_case`, `sort_order`)
+SELECT g.`id`, i.`type`, i.`content`, 1, i.`sort_order`
+FROM `command_groups` g
+JOIN (
+    SELECT 'command' AS `type`, 'EVAL' AS `content`, 5 AS `sort_order`
+    UNION ALL SELECT 'command', 'EVALSHA', 6
+    UNION ALL SELECT 'command', 'EVAL_RO', 7
+    UNION ALL SELECT 'command', 'EVALSHA_RO', 8
+    UNION ALL SELECT 'command', 'FCALL', 9
+    UNION ALL SELECT 'command', 'FCALL_RO', 10
+    UNION ALL SELECT 'command', 'SCRIPT', 11
+    UNION ALL SELECT 'command', 'FUNCTION', 12
+) i
+WHERE g.`name` = 'Redis高危命令'
+  AND NOT EXISTS (
+      SELECT 1 FROM `command_group_items` x
+      WHERE x.`command_group_id` = g.`id` AND x.`content` = i.`content`
+  );
+
+UPDATE `command_groups`
+SET `remark` = '系统默认命令组：清空数据、全量遍历键、修改服务器配置与执行脚本'
+WHERE `name` = 'Redis高危命令'
+  AND `remark` = '系统默认命令组：清空数据、全量遍历键与修改服务器配置';
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 访问策略相关的登录日志状态
+const (
+	LoginStatusPolicyDenied = "policy_denied" // 登录时间或来源地址不符合访问策略
+)
+
+// 访问策略动作
+const (
+	AccessPolicyActionAllow = "allow" // 仅允许在策略条件内访问
+	AccessPolicyActionDeny  = "deny"  // 禁止在策略条件内访问
+)
+
+// 访问策略作用范围
+const (
+	AccessPolicyScopeLogin   = "login"   // 登录堡垒机（Web、单点登录、SSH网关）
+	AccessPolicyScopeSession = "session" // 建立资产会话
+	AccessPolicyScopeAll     = "all"
+)
+
+// AccessPolicy 登录与会话访问策略
+// 按星期、时间段（指定时区）和客户端CIDR限制登录和建立会话，可关联用户、角色和资产。
+// 未关联用户和角色的策略对所有用户生效；未关联资产的会话策略对所有资产生效；
+// 只关联了资产的策略不限制登录。
+// 判定规则：命中任意拒绝策略即拒绝；存在适用的允许策略时，必须命中其中之一。
+type AccessPolicy struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"size:100;not null;uniqueIndex;comment:策略名称"`
+	Description string         `json:"description" gorm:"size:500;comment:描述"`
+	Enabled     bool           `json:"enabled" gorm:"default:true;index;comment:是否启用"`
+	Action      string         `json:"action" gorm:"size:10;not null;comment:动作 allow/deny"`
+	Scope       string         `json:"scope" gorm:"size:20;not null;comment:作用范围 login/session/all"`
+	Weekdays    string         `json:"weekdays" gorm:"size:20;comment:星期，逗号分隔，0为周日，为空表示每天"`
+	StartTime   string         `json:"start_time" gorm:"size:5;comment:开始时间 HH:MM，为空表示全天"`
+	EndTime     string         `json:"end_time" gorm:"size:5;comment:结束时间 HH:MM，早于开始时间表示跨天"`
+	TimeZone    string         `json:"time_zone" gorm:"size:64;comment:时区，为空表示服务器时区"`
+	CIDRs       string         `json:"cidrs" gorm:"column:cidrs;type:text;comment:客户端地址段，逗号分隔，为空表示任意地址"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 关联对象
+	Users  []User  `json:"users,omitempty" gorm:"many2many:access_policy_users;joinForeignKey:policy_id;joinReferences:user_id;"`
+	Roles  []Role  `json:"roles,omitempty" gorm:"many2many:access_policy_roles;joinForeignKey:policy_id;joinReferences:role_id;"`
+	Assets []Asset `json:"assets,omitempty" gorm:"many2many:access_policy_assets;joinForeignKey:policy_id;joinReferences:asset_id;"`
+}
+
+// TableName 指定表名
+func (AccessPolicy) TableName() string {
+	return "access_policies"
+}
+
+// AccessPolicyListRequest 访问策略列表请求
+type AccessPolicyListRequest struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Keyword  string `form:"keyword" binding:"omitempty,max=50"`
+	Scope    string `form:"scope" binding:"omitempty,oneof=login session all"`
+}
+
+// AccessPolicyCreateRequest 创建访问策略请求
+type AccessPolicyCreateRequest struct {
+	Name        string   `json:"name" binding:"required,min=1,max=100"`
+	Description string   `json:"description" binding:"omitempty,max=500"`
+	Enabled     *bool    `json:"enabled"`
+	Action      string   `json:"action" binding:"required,oneof=allow deny"`
+	Scope       string   `json:"scope" binding:"required,oneof=login session all"`
+	Weekdays    []int    `json:"weekdays" binding:"omitempty,dive,min=0,max=6"`
+	StartTime   string   `json:"start_time" binding:"omitempty"`
+	EndTime     string   `json:"end_time" binding:"omitempty"`
+	TimeZone    string   `json:"time_zone" binding:"omitempty,max=64"`
+	CIDRs       []string `json:"cidrs" binding:"omitempty"`
+	UserIDs     []uint   `json:"user_ids" binding:"omitempty"`
+	RoleIDs     []uint   `json:"role_ids" binding:"omitempty"`
+	AssetIDs    []uint   `json:"asset_ids" binding:"omitempty"`
+}
+
+// AccessPolicyUpdateRequest 更新访问策略请求
+type AccessPolicyUpdateRequest struct {
+	Name        string    `json:"name" binding:"omitempty,min=1,max=100"`
+	Description *string   `json:"description" binding:"omitempty,max=500"`
+	Enabled     *bool     `json:"enabled"`
+	Action      string    `json:"action" binding:"omitempty,oneof=allow deny"`
+	Scope       string    `json:"scope" binding:"omitempty,oneof=login session all"`
+	Weekdays    *[]int    `json:"weekdays" binding:"omitempty,dive,min=0,max=6"`
+	StartTime   *string   `json:"start_time"`
+	EndTime     *string   `json:"end_time"`
+	TimeZone    *string   `json:"time_zone" binding:"omitempty,max=64"`
+	CIDRs       *[]string `json:"cidrs"`
+	UserIDs     *[]uint   `json:"user_ids" binding:"omitempty"`
+	RoleIDs     *[]uint   `json:"role_ids" binding:"omitempty"`
+	AssetIDs    *[]uint   `json:"asset_ids" binding:"omitempty"`
+}
+
+// AccessPolicyResponse 访问策略响应
+type AccessPolicyResponse struct {
+	ID          uint                       `json:"id"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Enabled     bool                       `json:"enabled"`
+	Action      string                     `json:"action"`
+	Scope       string                     `json:"scope"`
+	Weekdays    string                     `json:"weekdays"`
+	StartTime   string                     `json:"start_time"`
+	EndTime     string                     `json:"end_time"`
+	TimeZone    string                     `json:"time_zone"`
+	CIDRs       string                     `json:"cidrs"`
+	Users       []PermissionTargetResponse `json:"users"`
+	Roles       []PermissionTargetResponse `json:"roles"`
+	Assets      []PermissionTargetResponse `json:"assets"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	UpdatedAt   time.Time                  `json:"updated_at"`
+}
+
+// ToResponse 转换为响应格式
+func (p *AccessPolicy) ToResponse() *AccessPolicyResponse {
+	resp := &AccessPolicyResponse{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Enabled:     p.Enabled,
+		Action:      p.Action,
+		Scope:       p.Scope,
+		Weekdays:    p.Weekdays,
+		StartTime:   p.StartTime,
+		EndTime:     p.EndTime,
+		TimeZone:    p.TimeZone,
+		CIDRs:       p.CIDRs,
+		Users:       make([]PermissionTargetResponse, len(p.Users)),
+		Roles:       make([]PermissionTargetResponse, len(p.Roles)),
+		Assets:      make([]PermissionTargetResponse, len(p.Assets)),
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+	}
+	for i, u := range p.Users {
+		resp.Users[i] = PermissionTargetResponse{ID: u.ID, Name: u.Username}
+	}
+	for i, r := range p.Roles {
+		resp.Roles[i] = PermissionTargetResponse{ID: r.ID, Name: r.Name}
+	}
+	for i, a := range p.Assets {
+		resp.Assets[i] = PermissionTargetResponse{ID: a.ID, Name: a.Name}
+	}
+	return resp
+}
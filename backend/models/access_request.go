@@ -0,0 +1,142 @@
+package models
+
+import "time"
+
+// 临时访问申请状态
+const (
+	AccessRequestStatusPending   = "pending"   // 待审批
+	AccessRequestStatusApproved  = "approved"  // 已批准，授权在申请时间窗口内生效
+	AccessRequestStatusRejected  = "rejected"  // 已拒绝
+	AccessRequestStatusCancelled = "cancelled" // 申请人撤回
+	AccessRequestStatusExpired   = "expired"   // 时间窗口结束
+)
+
+// 审批决定
+const (
+	AccessRequestDecisionApprove = "approve"
+	AccessRequestDecisionReject  = "reject"
+)
+
+// AccessRequest 临时访问申请
+// 用户申请在一段时间内访问资产或资产分组，审批通过后生成临时的资产授权规则，
+// 时间窗口结束时授权规则被删除，用户在相关资产上的会话被关闭。
+type AccessRequest struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	UserID            uint       `json:"user_id" gorm:"not null;index;comment:申请人ID"`
+	AssetID           *uint      `json:"asset_id" gorm:"index;comment:申请访问的资产ID"`
+	AssetGroupID      *uint      `json:"asset_group_id" gorm:"index;comment:申请访问的资产分组ID"`
+	Reason            string     `json:"reason" gorm:"type:text;not null;comment:申请理由"`
+	StartAt           time.Time  `json:"start_at" gorm:"not null;comment:访问开始时间"`
+	EndAt             time.Time  `json:"end_at" gorm:"not null;index;comment:访问结束时间"`
+	Status            string     `json:"status" gorm:"size:20;not null;index;comment:状态"`
+	RequiredApprovals int        `json:"required_approvals" gorm:"not null;default:1;comment:需要的批准人数"`
+	PermissionID      *uint      `json:"permission_id" gorm:"comment:审批通过后生成的资产授权规则ID"`
+	ClosedAt          *time.Time `json:"closed_at" gorm:"comment:拒绝、撤回或过期的时间"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+
+	// 关联关系
+	User       User                    `json:"-" gorm:"foreignKey:UserID"`
+	Asset      *Asset                  `json:"-" gorm:"foreignKey:AssetID"`
+	AssetGroup *AssetGroup             `json:"-" gorm:"foreignKey:AssetGroupID"`
+	Approvals  []AccessRequestApproval `json:"-" gorm:"foreignKey:RequestID"`
+}
+
+// AccessRequestApproval 临时访问申请的审批记录，每个审批人只能审批一次
+type AccessRequestApproval struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	RequestID    uint      `json:"request_id" gorm:"not null;uniqueIndex:uk_request_approver;comment:申请ID"`
+	ApproverID   uint      `json:"approver_id" gorm:"not null;uniqueIndex:uk_request_approver;comment:审批人ID"`
+	ApproverName string    `json:"approver_name" gorm:"size:50;not null;comment:审批人用户名"`
+	Decision     string    `json:"decision" gorm:"size:20;not null;comment:审批决定"`
+	Comment      string    `json:"comment" gorm:"size:500;comment:审批意见"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (AccessRequest) TableName() string {
+	return "access_requests"
+}
+
+func (AccessRequestApproval) TableName() string {
+	return "access_request_approvals"
+}
+
+// IsClosed 申请是否已结束，结束后不能再审批或撤回
+func (r *AccessRequest) IsClosed() bool {
+	return r.Status != AccessRequestStatusPending && r.Status != AccessRequestStatusApproved
+}
+
+// AccessRequestCreateRequest 创建临时访问申请请求，资产与资产分组二选一
+type AccessRequestCreateRequest struct {
+	AssetID      uint       `json:"asset_id" binding:"omitempty"`
+	AssetGroupID uint       `json:"asset_group_id" binding:"omitempty"`
+	Reason       string     `json:"reason" binding:"required,min=1,max=1000"`
+	StartAt      *time.Time `json:"start_at"` // 为空表示审批通过后立即生效
+	EndAt        time.Time  `json:"end_at" binding:"required"`
+}
+
+// AccessRequestListRequest 临时访问申请列表请求
+type AccessRequestListRequest struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Status   string `form:"status" binding:"omitempty,oneof=pending approved rejected cancelled expired"`
+	UserID   uint   `form:"user_id" binding:"omitempty"`
+}
+
+// AccessRequestDecisionRequest 审批请求
+type AccessRequestDecisionRequest struct {
+	Comment string `json:"comment" binding:"omitempty,max=500"`
+}
+
+// AccessRequestResponse 临时访问申请响应
+type AccessRequestResponse struct {
+	ID                uint                    `json:"id"`
+	UserID            uint                    `json:"user_id"`
+	Username          string                  `json:"username"`
+	AssetID           *uint                   `json:"asset_id"`
+	AssetName         string                  `json:"asset_name,omitempty"`
+	AssetGroupID      *uint                   `json:"asset_group_id"`
+	AssetGroupName    string                  `json:"asset_group_name,omitempty"`
+	Reason            string                  `json:"reason"`
+	StartAt           time.Time               `json:"start_at"`
+	EndAt             time.Time               `json:"end_at"`
+	Status            string                  `json:"status"`
+	RequiredApprovals int                     `json:"required_approvals"`
+	PermissionID      *uint                   `json:"permission_id"`
+	Approvals         []AccessRequestApproval `json:"approvals"`
+	ClosedAt          *time.Time              `json:"closed_at"`
+	CreatedAt         time.Time               `json:"created_at"`
+	UpdatedAt         time.Time               `json:"updated_at"`
+}
+
+// ToResponse 转换为响应格式
+func (r *AccessRequest) ToResponse() *AccessRequestResponse {
+	resp := &AccessRequestResponse{
+		ID:                r.ID,
+		UserID:            r.UserID,
+		Username:          r.User.Username,
+		AssetID:           r.AssetID,
+		AssetGroupID:      r.AssetGroupID,
+		Reason:            r.Reason,
+		StartAt:           r.StartAt,
+		EndAt:             r.EndAt,
+		Status:            r.Status,
+		RequiredApprovals: r.RequiredApprovals,
+		PermissionID:      r.PermissionID,
+		Approvals:         r.Approvals,
+		ClosedAt:          r.ClosedAt,
+		CreatedAt:         r.CreatedAt,
+		UpdatedAt:         r.UpdatedAt,
+	}
+	if r.Asset != nil {
+		resp.AssetName = r.Asset.Name
+	}
+	if r.AssetGroup != nil {
+		resp.AssetGroupName = r.AssetGroup.Name
+	}
+	if resp.Approvals == nil {
+		resp.Approvals = []AccessRequestApproval{}
+	}
+	return resp
+}
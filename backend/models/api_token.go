@@ -0,0 +1,93 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// API令牌权限范围
+const (
+	APITokenScopeAll = "all"       // 拥有者的全部角色和权限
+	APITokenRoleName = "api_token" // 限定权限范围后的合成角色名，不具备任何真实角色身份
+)
+
+// APIToken 个人API访问令牌，用于脚本和CI等自动化场景
+type APIToken struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	Name        string     `json:"name" gorm:"size:100;not null;comment:令牌名称"`
+	TokenPrefix string     `json:"token_prefix" gorm:"size:20;not null;comment:令牌前缀，用于识别"`
+	TokenHash   string     `json:"-" gorm:"size:64;not null;uniqueIndex;comment:令牌SHA-256摘要"`
+	Permissions string     `json:"-" gorm:"type:text;not null;comment:权限范围，逗号分隔，all表示拥有者全部权限"`
+	ExpiresAt   *time.Time `json:"expires_at" gorm:"comment:过期时间，为空表示永不过期"`
+	LastUsedAt  *time.Time `json:"last_used_at" gorm:"comment:最后使用时间"`
+	LastUsedIP  string     `json:"last_used_ip" gorm:"size:45;comment:最后使用IP"`
+	RevokedAt   *time.Time `json:"revoked_at" gorm:"comment:吊销时间"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// TableName 指定表名
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// APITokenCreateRequest 创建API令牌请求
+type APITokenCreateRequest struct {
+	Name          string   `json:"name" binding:"required,min=1,max=100"`
+	Permissions   []string `json:"permissions" binding:"required,min=1"`     // 拥有者权限的子集，all表示全部
+	ExpiresInDays int      `json:"expires_in_days" binding:"min=0,max=3650"` // 0表示永不过期
+}
+
+// APITokenResponse API令牌响应
+type APITokenResponse struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  string     `json:"last_used_ip"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// APITokenCreateResponse 创建API令牌响应，明文令牌只在创建时返回一次
+type APITokenCreateResponse struct {
+	*APITokenResponse
+	Token string `json:"token"`
+}
+
+// PermissionList 解析权限范围
+func (t *APIToken) PermissionList() []string {
+	if t.Permissions == "" {
+		return []string{}
+	}
+	return strings.Split(t.Permissions, ",")
+}
+
+// IsUsable 检查令牌是否未吊销且未过期
+func (t *APIToken) IsUsable() bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || t.ExpiresAt.After(time.Now())
+}
+
+// ToResponse 转换为响应格式
+func (t *APIToken) ToResponse() *APITokenResponse {
+	return &APITokenResponse{
+		ID:          t.ID,
+		Name:        t.Name,
+		TokenPrefix: t.TokenPrefix,
+		Permissions: t.PermissionList(),
+		ExpiresAt:   t.ExpiresAt,
+		LastUsedAt:  t.LastUsedAt,
+		LastUsedIP:  t.LastUsedIP,
+		RevokedAt:   t.RevokedAt,
+		CreatedAt:   t.CreatedAt,
+	}
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// 主机密钥状态
+const (
+	HostKeyStatusTrusted = "trusted" // 已信任，连接时必须与之一致
+	HostKeyStatusPending = "pending" // 连接时出现的未信任密钥，等待管理员确认
+)
+
+// 主机密钥来源
+const (
+	HostKeySourceFirstUse = "first_use" // 首次连接时自动信任
+	HostKeySourceManual   = "manual"    // 管理员预置
+	HostKeySourceAccepted = "accepted"  // 管理员确认待处理的密钥
+)
+
+// AssetHostKey 资产SSH主机密钥
+// 每个资产每种密钥类型最多有一个已信任的密钥；连接时出现的不一致或未登记的密钥记为待确认，
+// 由管理员确认后替换同类型的已信任密钥。
+type AssetHostKey struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	AssetID        uint       `json:"asset_id" gorm:"not null;uniqueIndex:uk_asset_fingerprint;comment:资产ID"`
+	KeyType        string     `json:"key_type" gorm:"size:50;not null;comment:密钥类型"`
+	PublicKey      string     `json:"public_key" gorm:"type:text;not null;comment:公钥，authorized_keys格式"`
+	Fingerprint    string     `json:"fingerprint" gorm:"size:100;not null;uniqueIndex:uk_asset_fingerprint;comment:SHA256指纹"`
+	Status         string     `json:"status" gorm:"size:20;not null;index;comment:状态 trusted/pending"`
+	Source         string     `json:"source" gorm:"size:20;comment:来源 first_use/manual/accepted"`
+	AcceptedBy     *uint      `json:"accepted_by" gorm:"comment:预置或确认的管理员ID"`
+	AcceptedByName string     `json:"accepted_by_name" gorm:"size:50;comment:预置或确认的管理员用户名"`
+	FirstSeenAt    *time.Time `json:"first_seen_at" gorm:"comment:首次出现时间"`
+	LastSeenAt     *time.Time `json:"last_seen_at" gorm:"comment:最近一次连接时出现的时间"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AssetHostKey) TableName() string {
+	return "asset_host_keys"
+}
+
+// AssetHostKeySeedRequest 预置主机密钥请求
+// 每项为 authorized_keys 格式（"ssh-ed25519 AAAA..."）或 known_hosts 格式的一行，
+// 同类型已信任的密钥会被替换
+type AssetHostKeySeedRequest struct {
+	PublicKeys []string `json:"public_keys" binding:"required,min=1,max=10,dive,required"`
+}
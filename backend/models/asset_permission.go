@@ -0,0 +1,245 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserGroup 用户组
+type UserGroup struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"size:100;not null;uniqueIndex;comment:用户组名称"`
+	Description string         `json:"description" gorm:"size:500;comment:描述"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 关联
+	Users []User `json:"users,omitempty" gorm:"many2many:user_group_members;joinForeignKey:user_group_id;joinReferences:user_id;"`
+}
+
+// AssetPermission 资产授权规则
+// 将资产或资产分组（以及可使用的凭证）授权给用户、角色或用户组。
+// 未指定凭证时，允许使用资产已关联的全部凭证。
+// AllowUpload/AllowDownload 控制通过该规则访问资产时能否上传、下载文件。
+// ForwardPorts 列出允许通过端口转发（隧道）访问的资产端口，为空表示不允许转发。
+type AssetPermission struct {
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	Name          string         `json:"name" gorm:"size:100;not null;uniqueIndex;comment:授权规则名称"`
+	Enabled       bool           `json:"enabled" gorm:"default:true;index;comment:是否启用"`
+	AllowUpload   bool           `json:"allow_upload" gorm:"default:true;comment:是否允许上传文件"`
+	AllowDownload bool           `json:"allow_download" gorm:"default:true;comment:是否允许下载文件"`
+	ForwardPorts  string         `json:"forward_ports" gorm:"size:500;comment:允许端口转发的端口，如 80,443,8000-8100，为空表示禁止转发"`
+	ValidFrom     *time.Time     `json:"valid_from" gorm:"comment:生效时间"`
+	ValidTo       *time.Time     `json:"valid_to" gorm:"comment:失效时间"`
+	Remark        string         `json:"remark" gorm:"size:500;comment:备注"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+
+	// 授权对象
+	Users      []User      `json:"users,omitempty" gorm:"many2many:asset_permission_users;joinForeignKey:permission_id;joinReferences:user_id;"`
+	Roles      []Role      `json:"roles,omitempty" gorm:"many2many:asset_permission_roles;joinForeignKey:permission_id;joinReferences:role_id;"`
+	UserGroups []UserGroup `json:"user_groups,omitempty" gorm:"many2many:asset_permission_user_groups;joinForeignKey:permission_id;joinReferences:user_group_id;"`
+
+	// 授权资源
+	Assets      []Asset      `json:"assets,omitempty" gorm:"many2many:asset_permission_assets;joinForeignKey:permission_id;joinReferences:asset_id;"`
+	AssetGroups []AssetGroup `json:"asset_groups,omitempty" gorm:"many2many:asset_permission_asset_groups;joinForeignKey:permission_id;joinReferences:asset_group_id;"`
+	Credentials []Credential `json:"credentials,omitempty" gorm:"many2many:asset_permission_credentials;joinForeignKey:permission_id;joinReferences:credential_id;"`
+}
+
+// TableName 指定表名
+func (UserGroup) TableName() string {
+	return "user_groups"
+}
+
+func (AssetPermission) TableName() string {
+	return "asset_permissions"
+}
+
+// IsEffective 判断授权规则在指定时间是否生效
+func (p *AssetPermission) IsEffective(now time.Time) bool {
+	if !p.Enabled {
+		return false
+	}
+	if p.ValidFrom != nil && now.Before(*p.ValidFrom) {
+		return false
+	}
+	if p.ValidTo != nil && now.After(*p.ValidTo) {
+		return false
+	}
+	return true
+}
+
+// ========================================
+// 用户组相关请求响应结构体
+// ========================================
+
+// UserGroupListRequest 用户组列表请求
+type UserGroupListRequest struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Keyword  string `form:"keyword" binding:"omitempty,max=50"`
+}
+
+// UserGroupCreateRequest 创建用户组请求
+type UserGroupCreateRequest struct {
+	Name        string `json:"name" binding:"required,min=1,max=100"`
+	Description string `json:"description" binding:"omitempty,max=500"`
+	UserIDs     []uint `json:"user_ids" binding:"omitempty"`
+}
+
+// UserGroupUpdateRequest 更新用户组请求
+type UserGroupUpdateRequest struct {
+	Name        string  `json:"name" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=500"`
+	UserIDs     *[]uint `json:"user_ids" binding:"omitempty"`
+}
+
+// UserGroupResponse 用户组响应
+type UserGroupResponse struct {
+	ID          uint                `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	UserCount   int                 `json:"user_count"`
+	Users       []UserBasicResponse `json:"users,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// ToResponse 转换为响应格式
+func (g *UserGroup) ToResponse() *UserGroupResponse {
+	users := make([]UserBasicResponse, len(g.Users))
+	for i, user := range g.Users {
+		users[i] = UserBasicResponse{ID: user.ID, Username: user.Username, Email: user.Email}
+	}
+	return &UserGroupResponse{
+		ID:          g.ID,
+		Name:        g.Name,
+		Description: g.Description,
+		UserCount:   len(g.Users),
+		Users:       users,
+		CreatedAt:   g.CreatedAt,
+		UpdatedAt:   g.UpdatedAt,
+	}
+}
+
+// ========================================
+// 资产授权相关请求响应结构体
+// ========================================
+
+// AssetPermissionListRequest 资产授权列表请求
+type AssetPermissionListRequest struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Keyword  string `form:"keyword" binding:"omitempty,max=50"`
+	Enabled  *bool  `form:"enabled" binding:"omitempty"`
+	UserID   uint   `form:"user_id" binding:"omitempty"`
+	AssetID  uint   `form:"asset_id" binding:"omitempty"`
+}
+
+// AssetPermissionCreateRequest 创建资产授权请求
+type AssetPermissionCreateRequest struct {
+	Name          string     `json:"name" binding:"required,min=1,max=100"`
+	Enabled       *bool      `json:"enabled"`
+	AllowUpload   *bool      `json:"allow_upload"`
+	AllowDownload *bool      `json:"allow_download"`
+	ForwardPorts  string     `json:"forward_ports" binding:"omitempty,max=500"`
+	ValidFrom     *time.Time `json:"valid_from"`
+	ValidTo       *time.Time `json:"valid_to"`
+	Remark        string     `json:"remark" binding:"omitempty,max=500"`
+	UserIDs       []uint     `json:"user_ids" binding:"omitempty"`
+	RoleIDs       []uint     `json:"role_ids" binding:"omitempty"`
+	UserGroupIDs  []uint     `json:"user_group_ids" binding:"omitempty"`
+	AssetIDs      []uint     `json:"asset_ids" binding:"omitempty"`
+	AssetGroupIDs []uint     `json:"asset_group_ids" binding:"omitempty"`
+	CredentialIDs []uint     `json:"credential_ids" binding:"omitempty"`
+}
+
+// AssetPermissionUpdateRequest 更新资产授权请求
+type AssetPermissionUpdateRequest struct {
+	Name          string     `json:"name" binding:"omitempty,min=1,max=100"`
+	Enabled       *bool      `json:"enabled"`
+	AllowUpload   *bool      `json:"allow_upload"`
+	AllowDownload *bool      `json:"allow_download"`
+	ForwardPorts  *string    `json:"forward_ports" binding:"omitempty,max=500"`
+	ValidFrom     *time.Time `json:"valid_from"`
+	ValidTo       *time.Time `json:"valid_to"`
+	Remark        *string    `json:"remark" binding:"omitempty,max=500"`
+	UserIDs       *[]uint    `json:"user_ids" binding:"omitempty"`
+	RoleIDs       *[]uint    `json:"role_ids" binding:"omitempty"`
+	UserGroupIDs  *[]uint    `json:"user_group_ids" binding:"omitempty"`
+	AssetIDs      *[]uint    `json:"asset_ids" binding:"omitempty"`
+	AssetGroupIDs *[]uint    `json:"asset_group_ids" binding:"omitempty"`
+	CredentialIDs *[]uint    `json:"credential_ids" binding:"omitempty"`
+}
+
+// PermissionTargetResponse 授权对象/资源的简要信息
+type PermissionTargetResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// AssetPermissionResponse 资产授权响应
+type AssetPermissionResponse struct {
+	ID            uint                       `json:"id"`
+	Name          string                     `json:"name"`
+	Enabled       bool                       `json:"enabled"`
+	AllowUpload   bool                       `json:"allow_upload"`
+	AllowDownload bool                       `json:"allow_download"`
+	ForwardPorts  string                     `json:"forward_ports"`
+	ValidFrom     *time.Time                 `json:"valid_from"`
+	ValidTo       *time.Time                 `json:"valid_to"`
+	Remark        string                     `json:"remark"`
+	Users         []PermissionTargetResponse `json:"users"`
+	Roles         []PermissionTargetResponse `json:"roles"`
+	UserGroups    []PermissionTargetResponse `json:"user_groups"`
+	Assets        []PermissionTargetResponse `json:"assets"`
+	AssetGroups   []PermissionTargetResponse `json:"asset_groups"`
+	Credentials   []PermissionTargetResponse `json:"credentials"`
+	CreatedAt     time.Time                  `json:"created_at"`
+	UpdatedAt     time.Time                  `json:"updated_at"`
+}
+
+// ToResponse 转换为响应格式
+func (p *AssetPermission) ToResponse() *AssetPermissionResponse {
+	resp := &AssetPermissionResponse{
+		ID:            p.ID,
+		Name:          p.Name,
+		Enabled:       p.Enabled,
+		AllowUpload:   p.AllowUpload,
+		AllowDownload: p.AllowDownload,
+		ForwardPorts:  p.ForwardPorts,
+		ValidFrom:     p.ValidFrom,
+		ValidTo:       p.ValidTo,
+		Remark:        p.Remark,
+		Users:         make([]PermissionTargetResponse, len(p.Users)),
+		Roles:         make([]PermissionTargetResponse, len(p.Roles)),
+		UserGroups:    make([]PermissionTargetResponse, len(p.UserGroups)),
+		Assets:        make([]PermissionTargetResponse, len(p.Assets)),
+		AssetGroups:   make([]PermissionTargetResponse, len(p.AssetGroups)),
+		Credentials:   make([]PermissionTargetResponse, len(p.Credentials)),
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.UpdatedAt,
+	}
+	for i, u := range p.Users {
+		resp.Users[i] = PermissionTargetResponse{ID: u.ID, Name: u.Username}
+	}
+	for i, r := range p.Roles {
+		resp.Roles[i] = PermissionTargetResponse{ID: r.ID, Name: r.Name}
+	}
+	for i, g := range p.UserGroups {
+		resp.UserGroups[i] = PermissionTargetResponse{ID: g.ID, Name: g.Name}
+	}
+	for i, a := range p.Assets {
+		resp.Assets[i] = PermissionTargetResponse{ID: a.ID, Name: a.Name}
+	}
+	for i, g := range p.AssetGroups {
+		resp.AssetGroups[i] = PermissionTargetResponse{ID: g.ID, Name: g.Name}
+	}
+	for i, c := range p.Credentials {
+		resp.Credentials[i] = PermissionTargetResponse{ID: c.ID, Name: c.Name}
+	}
+	return resp
+}
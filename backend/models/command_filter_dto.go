@@ -245,6 +245,9 @@ type CommandMatchRequest struct {
 	UserID   uint   `json:"user_id" binding:"required"`
 	AssetID  uint   `json:"asset_id" binding:"required"`
 	Account  string `json:"account" binding:"required,max=50"`
+
+	// LoggedCommand 写入过滤日志的命令文本，为空时记录 Command；Redis代理用原始参数匹配、日志只记录脱敏后的文本
+	LoggedCommand string `json:"-"`
 }
 
 // CommandMatchResponse 命令匹配响应
@@ -0,0 +1,141 @@
+package models
+
+import "time"
+
+// 凭证借出状态
+const (
+	CheckoutStatusPending    = "pending"     // 待审批
+	CheckoutStatusCheckedOut = "checked_out" // 已借出，借出人可在有效期内查看明文
+	CheckoutStatusRejected   = "rejected"    // 已拒绝
+	CheckoutStatusCancelled  = "cancelled"   // 申请人撤回
+	CheckoutStatusCheckedIn  = "checked_in"  // 已归还
+	CheckoutStatusExpired    = "expired"     // 借出到期或审批超时
+)
+
+// 归还后的改密状态
+const (
+	CheckoutRotationPending = "pending" // 改密进行中
+	CheckoutRotationSkipped = "skipped" // 配置为归还后不改密
+)
+
+// CredentialCheckout 凭证借出记录
+// 用户需要凭证明文（如登录控制台或厂商工具）时申请借出，可配置为需要审批。
+// 借出期间只有借出人可以查看明文，每次查看都写入审计日志；归还或到期后自动改密，使已查看的明文失效。
+type CredentialCheckout struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	CredentialID      uint       `json:"credential_id" gorm:"not null;index;comment:凭证ID"`
+	UserID            uint       `json:"user_id" gorm:"not null;index;comment:借出人ID"`
+	Reason            string     `json:"reason" gorm:"type:text;not null;comment:借出理由"`
+	Duration          int        `json:"duration" gorm:"not null;comment:借出时长，秒"`
+	Status            string     `json:"status" gorm:"size:20;not null;index;comment:状态"`
+	ApproverID        *uint      `json:"approver_id" gorm:"comment:审批人ID"`
+	ApproverName      string     `json:"approver_name" gorm:"size:50;comment:审批人用户名"`
+	ApprovalComment   string     `json:"approval_comment" gorm:"size:500;comment:审批意见"`
+	CheckedOutAt      *time.Time `json:"checked_out_at" gorm:"comment:借出时间"`
+	ExpiresAt         *time.Time `json:"expires_at" gorm:"index;comment:借出到期时间"`
+	ClosedAt          *time.Time `json:"closed_at" gorm:"comment:拒绝、撤回、归还或过期的时间"`
+	RevealCount       int        `json:"reveal_count" gorm:"not null;default:0;comment:查看明文次数"`
+	LastRevealedAt    *time.Time `json:"last_revealed_at" gorm:"comment:最近一次查看明文的时间"`
+	RotationStatus    string     `json:"rotation_status" gorm:"size:20;comment:归还后的改密结果"`
+	RotationHistoryID *uint      `json:"rotation_history_id" gorm:"comment:归还后的改密记录ID"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+
+	// 关联关系
+	User       User       `json:"-" gorm:"foreignKey:UserID"`
+	Credential Credential `json:"-" gorm:"foreignKey:CredentialID"`
+}
+
+// TableName 指定表名
+func (CredentialCheckout) TableName() string {
+	return "credential_checkouts"
+}
+
+// IsClosed 借出是否已结束
+func (c *CredentialCheckout) IsClosed() bool {
+	return c.Status != CheckoutStatusPending && c.Status != CheckoutStatusCheckedOut
+}
+
+// CredentialCheckoutCreateRequest 申请借出凭证请求
+type CredentialCheckoutCreateRequest struct {
+	CredentialID uint   `json:"credential_id" binding:"required"`
+	Reason       string `json:"reason" binding:"required,min=1,max=1000"`
+	Duration     int    `json:"duration" binding:"omitempty,min=60"` // 借出时长，秒，为空时使用默认时长
+}
+
+// CredentialCheckoutListRequest 凭证借出列表请求
+type CredentialCheckoutListRequest struct {
+	Page         int    `form:"page" binding:"omitempty,min=1"`
+	PageSize     int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Status       string `form:"status" binding:"omitempty,oneof=pending checked_out rejected cancelled checked_in expired"`
+	CredentialID uint   `form:"credential_id" binding:"omitempty"`
+	UserID       uint   `form:"user_id" binding:"omitempty"`
+}
+
+// CredentialCheckoutDecisionRequest 审批请求
+type CredentialCheckoutDecisionRequest struct {
+	Comment string `json:"comment" binding:"omitempty,max=500"`
+}
+
+// CredentialCheckoutResponse 凭证借出响应，不包含凭证明文
+type CredentialCheckoutResponse struct {
+	ID                uint       `json:"id"`
+	CredentialID      uint       `json:"credential_id"`
+	CredentialName    string     `json:"credential_name"`
+	CredentialType    string     `json:"credential_type"`
+	UserID            uint       `json:"user_id"`
+	Username          string     `json:"username"`
+	Reason            string     `json:"reason"`
+	Duration          int        `json:"duration"`
+	Status            string     `json:"status"`
+	ApproverID        *uint      `json:"approver_id"`
+	ApproverName      string     `json:"approver_name"`
+	ApprovalComment   string     `json:"approval_comment"`
+	CheckedOutAt      *time.Time `json:"checked_out_at"`
+	ExpiresAt         *time.Time `json:"expires_at"`
+	ClosedAt          *time.Time `json:"closed_at"`
+	RevealCount       int        `json:"reveal_count"`
+	LastRevealedAt    *time.Time `json:"last_revealed_at"`
+	RotationStatus    string     `json:"rotation_status"`
+	RotationHistoryID *uint      `json:"rotation_history_id"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// CredentialSecretResponse 借出期间查看的凭证明文
+type CredentialSecretResponse struct {
+	CheckoutID   uint      `json:"checkout_id"`
+	CredentialID uint      `json:"credential_id"`
+	Type         string    `json:"type"`
+	Username     string    `json:"username"`
+	Password     string    `json:"password,omitempty"`
+	PrivateKey   string    `json:"private_key,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// ToResponse 转换为响应格式
+func (c *CredentialCheckout) ToResponse() *CredentialCheckoutResponse {
+	return &CredentialCheckoutResponse{
+		ID:                c.ID,
+		CredentialID:      c.CredentialID,
+		CredentialName:    c.Credential.Name,
+		CredentialType:    c.Credential.Type,
+		UserID:            c.UserID,
+		Username:          c.User.Username,
+		Reason:            c.Reason,
+		Duration:          c.Duration,
+		Status:            c.Status,
+		ApproverID:        c.ApproverID,
+		ApproverName:      c.ApproverName,
+		ApprovalComment:   c.ApprovalComment,
+		CheckedOutAt:      c.CheckedOutAt,
+		ExpiresAt:         c.ExpiresAt,
+		ClosedAt:          c.ClosedAt,
+		RevealCount:       c.RevealCount,
+		LastRevealedAt:    c.LastRevealedAt,
+		RotationStatus:    c.RotationStatus,
+		RotationHistoryID: c.RotationHistoryID,
+		CreatedAt:         c.CreatedAt,
+		UpdatedAt:         c.UpdatedAt,
+	}
+}
@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// 改密触发方式
+const (
+	RotationTriggerManual         = "manual"          // 管理员立即改密
+	RotationTriggerScheduled      = "scheduled"       // 按改密策略定时执行
+	RotationTriggerCheckin        = "checkin"         // 借出的凭证归还后改密
+	RotationTriggerCheckoutExpiry = "checkout_expiry" // 借出的凭证到期后改密
+)
+
+// 改密结果
+const (
+	RotationStatusSuccess        = "success"         // 所有资产改密并验证成功，凭证已更新
+	RotationStatusFailed         = "failed"          // 未修改任何资产
+	RotationStatusRolledBack     = "rolled_back"     // 部分资产改密后失败，已恢复原密码或移除新公钥
+	RotationStatusRollbackFailed = "rollback_failed" // 恢复失败，需人工处理
+	RotationStatusPartial        = "partial"         // 密钥已轮换，但部分资产上的旧公钥未能删除
+)
+
+// 单台资产的改密结果
+const (
+	RotationAssetRotated         = "rotated"           // 已改密并验证成功
+	RotationAssetFailed          = "failed"            // 改密或验证失败
+	RotationAssetSkipped         = "skipped"           // 之前的资产失败，未执行
+	RotationAssetRolledBack      = "rolled_back"       // 已恢复原密码或移除新公钥
+	RotationAssetRollbackFailed  = "rollback_failed"   // 恢复失败
+	RotationAssetOldKeyRemaining = "old_key_remaining" // 新密钥已生效，旧公钥未能删除
+)
+
+// CredentialRotationPolicy 凭证定时改密策略，每个凭证最多一条
+type CredentialRotationPolicy struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	CredentialID   uint       `json:"credential_id" gorm:"not null;uniqueIndex;comment:凭证ID"`
+	Enabled        bool       `json:"enabled" gorm:"default:true;comment:是否启用"`
+	IntervalDays   int        `json:"interval_days" gorm:"not null;comment:改密周期，天"`
+	PasswordLength int        `json:"password_length" gorm:"not null;comment:新密码长度"`
+	KeyType        string     `json:"key_type" gorm:"size:20;comment:密钥凭证生成的新密钥类型 rsa/ed25519，为空时与当前密钥相同"`
+	NextRunAt      *time.Time `json:"next_run_at" gorm:"index;comment:下次改密时间"`
+	LastRunAt      *time.Time `json:"last_run_at" gorm:"comment:上次改密时间"`
+	LastStatus     string     `json:"last_status" gorm:"size:20;comment:上次改密结果"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (CredentialRotationPolicy) TableName() string {
+	return "credential_rotation_policies"
+}
+
+// CredentialRotationHistory 凭证改密记录
+type CredentialRotationHistory struct {
+	ID           uint                            `json:"id" gorm:"primaryKey"`
+	CredentialID uint                            `json:"credential_id" gorm:"not null;index;comment:凭证ID"`
+	Trigger      string                          `json:"trigger" gorm:"size:20;not null;comment:触发方式 manual/scheduled/checkin/checkout_expiry"`
+	Status       string                          `json:"status" gorm:"size:20;not null;index;comment:结果"`
+	Message      string                          `json:"message" gorm:"type:text;comment:结果说明"`
+	AssetIDs     string                          `json:"asset_ids" gorm:"size:500;comment:改密的资产ID，逗号分隔"`
+	Results      []CredentialRotationAssetResult `json:"results" gorm:"type:json;serializer:json;comment:各资产改密结果"`
+	OperatorID   *uint                           `json:"operator_id" gorm:"comment:操作人ID，定时改密为空"`
+	OperatorName string                          `json:"operator_name" gorm:"size:50;comment:操作人用户名"`
+	StartedAt    time.Time                       `json:"started_at" gorm:"not null;comment:开始时间"`
+	FinishedAt   *time.Time                      `json:"finished_at" gorm:"comment:结束时间"`
+}
+
+// TableName 指定表名
+func (CredentialRotationHistory) TableName() string {
+	return "credential_rotation_histories"
+}
+
+// CredentialRotationAssetResult 单台资产的改密结果
+type CredentialRotationAssetResult struct {
+	AssetID   uint   `json:"asset_id"`
+	AssetName string `json:"asset_name"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+}
+
+// CredentialRotationPolicyRequest 设置改密策略请求
+type CredentialRotationPolicyRequest struct {
+	Enabled        *bool  `json:"enabled"`
+	IntervalDays   int    `json:"interval_days" binding:"required,min=1,max=365"`
+	PasswordLength int    `json:"password_length" binding:"omitempty,min=12,max=64"`
+	KeyType        string `json:"key_type" binding:"omitempty,oneof=rsa ed25519"`
+}
+
+// CredentialRotationHistoryRequest 改密记录列表请求
+type CredentialRotationHistoryRequest struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Status   string `form:"status" binding:"omitempty,oneof=success failed rolled_back rollback_failed partial"`
+}
@@ -0,0 +1,246 @@
+package models
+
+import "time"
+
+// 数据库代理支持的协议
+const (
+	DatabaseProtocolMySQL      = "mysql"
+	DatabaseProtocolPostgreSQL = "postgresql"
+	DatabaseProtocolRedis      = "redis"
+)
+
+// IsDatabaseProtocol 判断会话协议是否由数据库代理承载
+func IsDatabaseProtocol(protocol string) bool {
+	return protocol == DatabaseProtocolMySQL || protocol == DatabaseProtocolPostgreSQL || protocol == DatabaseProtocolRedis
+}
+
+// SQL过滤规则的匹配方式
+const (
+	SQLMatchStatement = "statement" // 语句类型，Pattern 为逗号分隔的语句关键字，如 DROP,TRUNCATE
+	SQLMatchRegex     = "regex"     // 正则表达式，忽略大小写匹配整条语句
+	SQLMatchNoWhere   = "no_where"  // 不带WHERE条件的语句，Pattern 为逗号分隔的语句关键字，如 DELETE,UPDATE
+)
+
+// SQL审计状态
+const (
+	SQLAuditStatusSuccess = "success" // 执行成功
+	SQLAuditStatusError   = "error"   // 数据库返回错误
+	SQLAuditStatusDenied  = "denied"  // 被过滤规则拒绝，未发送到数据库
+)
+
+// SQLFilterRule SQL过滤规则
+// 与命令过滤类似，按优先级从高到低匹配，命中的第一条规则决定动作：
+// deny 拒绝执行并向客户端返回错误，alert 放行并发送安全告警，allow 直接放行。
+// 未关联资产时对所有数据库资产生效。
+type SQLFilterRule struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"size:100;not null;uniqueIndex;comment:规则名称"`
+	Priority  int       `json:"priority" gorm:"default:50;index;comment:优先级，1-100，数字越小优先级越高"`
+	Enabled   bool      `json:"enabled" gorm:"default:true;index;comment:是否启用"`
+	Protocol  string    `json:"protocol" gorm:"size:20;default:all;comment:适用的数据库协议，all表示全部"`
+	MatchType string    `json:"match_type" gorm:"size:20;not null;comment:匹配方式: statement-语句类型, regex-正则表达式, no_where-不带WHERE条件"`
+	Pattern   string    `json:"pattern" gorm:"size:500;not null;comment:语句类型列表或正则表达式"`
+	Action    string    `json:"action" gorm:"size:20;not null;comment:动作: deny-拒绝, alert-告警, allow-放行"`
+	Remark    string    `json:"remark" gorm:"size:500;comment:备注"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 关联
+	Assets []Asset `json:"assets,omitempty" gorm:"many2many:sql_filter_rule_assets;joinForeignKey:rule_id;joinReferences:asset_id;"`
+}
+
+// TableName 指定表名
+func (SQLFilterRule) TableName() string {
+	return "sql_filter_rules"
+}
+
+// SQLAuditLog SQL审计日志
+// 数据库代理上执行（或被拒绝）的每条语句记录一条，通过 SessionID 关联会话记录
+type SQLAuditLog struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	SessionID     string    `json:"session_id" gorm:"size:100;not null;index;comment:会话ID"`
+	UserID        uint      `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	Username      string    `json:"username" gorm:"size:50;not null;comment:用户名"`
+	AssetID       uint      `json:"asset_id" gorm:"not null;index;comment:资产ID"`
+	AssetName     string    `json:"asset_name" gorm:"size:100;not null;comment:资产名称"`
+	Account       string    `json:"account" gorm:"size:100;comment:数据库账号"`
+	Protocol      string    `json:"protocol" gorm:"size:20;not null;comment:数据库协议"`
+	Database      string    `json:"database" gorm:"column:database_name;size:100;comment:当前数据库"`
+	Statement     string    `json:"statement" gorm:"type:mediumtext;not null;comment:SQL语句"`
+	StatementType string    `json:"statement_type" gorm:"size:30;index;comment:语句类型"`
+	Action        string    `json:"action" gorm:"size:20;not null;index;comment:过滤动作(allow/alert/deny)"`
+	RuleID        *uint     `json:"rule_id" gorm:"comment:命中的过滤规则ID"`
+	RuleName      string    `json:"rule_name" gorm:"size:100;comment:命中的过滤规则名称"`
+	Status        string    `json:"status" gorm:"size:20;not null;index;comment:执行状态(success/error/denied)"`
+	AffectedRows  int64     `json:"affected_rows" gorm:"default:0;comment:影响行数"`
+	Message       string    `json:"message" gorm:"size:500;comment:错误信息"`
+	ClientIP      string    `json:"client_ip" gorm:"size:45;comment:客户端IP"`
+	Duration      int64     `json:"duration" gorm:"default:0;comment:执行耗时，毫秒"`
+	CreatedAt     time.Time `json:"created_at" gorm:"index"`
+
+	// 关联关系
+	SessionRecord SessionRecord `json:"-" gorm:"foreignKey:SessionID;references:SessionID"`
+}
+
+// TableName 指定表名
+func (SQLAuditLog) TableName() string {
+	return "sql_audit_logs"
+}
+
+// SQLFilterRuleCreateRequest 创建SQL过滤规则请求
+type SQLFilterRuleCreateRequest struct {
+	Name      string `json:"name" binding:"required,min=1,max=100"`
+	Priority  int    `json:"priority" binding:"omitempty,min=1,max=100"`
+	Enabled   *bool  `json:"enabled"`
+	Protocol  string `json:"protocol" binding:"omitempty,oneof=all mysql postgresql"`
+	MatchType string `json:"match_type" binding:"required,oneof=statement regex no_where"`
+	Pattern   string `json:"pattern" binding:"required,min=1,max=500"`
+	Action    string `json:"action" binding:"required,oneof=deny alert allow"`
+	Remark    string `json:"remark" binding:"omitempty,max=500"`
+	AssetIDs  []uint `json:"asset_ids" binding:"omitempty"`
+}
+
+// SQLFilterRuleUpdateRequest 更新SQL过滤规则请求
+type SQLFilterRuleUpdateRequest struct {
+	Name      string  `json:"name" binding:"omitempty,min=1,max=100"`
+	Priority  int     `json:"priority" binding:"omitempty,min=1,max=100"`
+	Enabled   *bool   `json:"enabled"`
+	Protocol  string  `json:"protocol" binding:"omitempty,oneof=all mysql postgresql"`
+	MatchType string  `json:"match_type" binding:"omitempty,oneof=statement regex no_where"`
+	Pattern   string  `json:"pattern" binding:"omitempty,min=1,max=500"`
+	Action    string  `json:"action" binding:"omitempty,oneof=deny alert allow"`
+	Remark    *string `json:"remark" binding:"omitempty,max=500"`
+	AssetIDs  *[]uint `json:"asset_ids" binding:"omitempty"`
+}
+
+// SQLFilterRuleListRequest SQL过滤规则列表请求
+type SQLFilterRuleListRequest struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Name     string `form:"name" binding:"omitempty,max=100"`
+	Action   string `form:"action" binding:"omitempty,oneof=deny alert allow"`
+	Enabled  *bool  `form:"enabled"`
+}
+
+// SQLFilterRuleResponse SQL过滤规则响应
+type SQLFilterRuleResponse struct {
+	ID        uint                       `json:"id"`
+	Name      string                     `json:"name"`
+	Priority  int                        `json:"priority"`
+	Enabled   bool                       `json:"enabled"`
+	Protocol  string                     `json:"protocol"`
+	MatchType string                     `json:"match_type"`
+	Pattern   string                     `json:"pattern"`
+	Action    string                     `json:"action"`
+	Remark    string                     `json:"remark"`
+	Assets    []PermissionTargetResponse `json:"assets"`
+	CreatedAt time.Time                  `json:"created_at"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+}
+
+// ToResponse 转换为响应格式
+func (r *SQLFilterRule) ToResponse() *SQLFilterRuleResponse {
+	resp := &SQLFilterRuleResponse{
+		ID:        r.ID,
+		Name:      r.Name,
+		Priority:  r.Priority,
+		Enabled:   r.Enabled,
+		Protocol:  r.Protocol,
+		MatchType: r.MatchType,
+		Pattern:   r.Pattern,
+		Action:    r.Action,
+		Remark:    r.Remark,
+		Assets:    make([]PermissionTargetResponse, len(r.Assets)),
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+	for i, a := range r.Assets {
+		resp.Assets[i] = PermissionTargetResponse{ID: a.ID, Name: a.Name}
+	}
+	return resp
+}
+
+// SQLAuditLogListRequest SQL审计日志列表请求
+type SQLAuditLogListRequest struct {
+	Page      int    `form:"page" binding:"omitempty,min=1"`
+	PageSize  int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	SessionID string `form:"session_id" binding:"omitempty,max=100"`
+	Username  string `form:"username" binding:"omitempty,max=50"`
+	AssetID   uint   `form:"asset_id" binding:"omitempty"`
+	Protocol  string `form:"protocol" binding:"omitempty,oneof=mysql postgresql"`
+	Action    string `form:"action" binding:"omitempty,oneof=allow alert deny"`
+	Status    string `form:"status" binding:"omitempty,oneof=success error denied"`
+	Keyword   string `form:"keyword" binding:"omitempty,max=100"`
+	StartTime string `form:"start_time" binding:"omitempty"`
+	EndTime   string `form:"end_time" binding:"omitempty"`
+}
+
+// SQLAuditLogResponse SQL审计日志响应
+type SQLAuditLogResponse struct {
+	ID            uint      `json:"id"`
+	SessionID     string    `json:"session_id"`
+	UserID        uint      `json:"user_id"`
+	Username      string    `json:"username"`
+	AssetID       uint      `json:"asset_id"`
+	AssetName     string    `json:"asset_name"`
+	Account       string    `json:"account"`
+	Protocol      string    `json:"protocol"`
+	Database      string    `json:"database"`
+	Statement     string    `json:"statement"`
+	StatementType string    `json:"statement_type"`
+	Action        string    `json:"action"`
+	RuleID        *uint     `json:"rule_id"`
+	RuleName      string    `json:"rule_name"`
+	Status        string    `json:"status"`
+	AffectedRows  int64     `json:"affected_rows"`
+	Message       string    `json:"message"`
+	ClientIP      string    `json:"client_ip"`
+	Duration      int64     `json:"duration"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ToResponse 转换为响应格式
+func (l *SQLAuditLog) ToResponse() *SQLAuditLogResponse {
+	return &SQLAuditLogResponse{
+		ID:            l.ID,
+		SessionID:     l.SessionID,
+		UserID:        l.UserID,
+		Username:      l.Username,
+		AssetID:       l.AssetID,
+		AssetName:     l.AssetName,
+		Account:       l.Account,
+		Protocol:      l.Protocol,
+		Database:      l.Database,
+		Statement:     l.Statement,
+		StatementType: l.StatementType,
+		Action:        l.Action,
+		RuleID:        l.RuleID,
+		RuleName:      l.RuleName,
+		Status:        l.Status,
+		AffectedRows:  l.AffectedRows,
+		Message:       l.Message,
+		ClientIP:      l.ClientIP,
+		Duration:      l.Duration,
+		CreatedAt:     l.CreatedAt,
+	}
+}
+
+// DatabaseTokenRequest 申请数据库连接令牌请求
+type DatabaseTokenRequest struct {
+	AssetID      uint `json:"asset_id" binding:"required"`
+	CredentialID uint `json:"credential_id" binding:"required"`
+}
+
+// DatabaseTokenResponse 数据库连接令牌
+// 数据库客户端连接代理地址，用户名为堡垒机用户名，密码为令牌；令牌在有效期内可多次使用
+type DatabaseTokenResponse struct {
+	Token     string    `json:"token"`
+	Protocol  string    `json:"protocol"`
+	ProxyHost string    `json:"proxy_host"`
+	ProxyPort int       `json:"proxy_port"`
+	Username  string    `json:"username"`
+	AssetID   uint      `json:"asset_id"`
+	AssetName string    `json:"asset_name"`
+	Account   string    `json:"account"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"os"
+	"path"
+	"time"
+)
+
+// 文件传输方向
+const (
+	TransferDirectionUpload   = "upload"
+	TransferDirectionDownload = "download"
+)
+
+// 文件传输通道
+const (
+	TransferChannelSFTP = "sftp" // SSH网关SFTP子系统
+	TransferChannelWeb  = "web"  // Web会话上传下载接口
+)
+
+// 文件传输状态
+const (
+	TransferStatusSuccess = "success"
+	TransferStatusFailed  = "failed"
+	TransferStatusDenied  = "denied"
+)
+
+// FileTransferLog 文件传输审计日志
+// 每个上传或下载的文件记录一条，通过 SessionID 关联会话记录
+type FileTransferLog struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	SessionID    string    `json:"session_id" gorm:"size:100;not null;index;comment:会话ID"`
+	UserID       uint      `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	Username     string    `json:"username" gorm:"size:50;not null;comment:用户名"`
+	AssetID      uint      `json:"asset_id" gorm:"not null;index;comment:资产ID"`
+	AssetName    string    `json:"asset_name" gorm:"size:100;not null;comment:资产名称"`
+	CredentialID uint      `json:"credential_id" gorm:"comment:凭证ID"`
+	Channel      string    `json:"channel" gorm:"size:10;not null;comment:传输通道(sftp/web)"`
+	Direction    string    `json:"direction" gorm:"size:10;not null;index;comment:传输方向(upload/download)"`
+	Filename     string    `json:"filename" gorm:"size:255;not null;comment:文件名"`
+	RemotePath   string    `json:"remote_path" gorm:"size:1024;not null;comment:资产上的文件路径"`
+	Size         int64     `json:"size" gorm:"default:0;comment:传输字节数"`
+	SHA256       string    `json:"sha256" gorm:"column:sha256;size:64;comment:文件SHA-256摘要"`
+	Status       string    `json:"status" gorm:"size:20;not null;index;comment:传输状态(success/failed/denied)"`
+	Message      string    `json:"message" gorm:"size:500;comment:说明"`
+	ClientIP     string    `json:"client_ip" gorm:"size:45;comment:客户端IP"`
+	StartTime    time.Time `json:"start_time" gorm:"comment:开始时间"`
+	EndTime      time.Time `json:"end_time" gorm:"comment:结束时间"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// 关联关系
+	SessionRecord SessionRecord `json:"-" gorm:"foreignKey:SessionID;references:SessionID"`
+}
+
+// TableName 指定表名
+func (FileTransferLog) TableName() string {
+	return "file_transfer_logs"
+}
+
+// FileTransferLogListRequest 文件传输日志列表请求
+type FileTransferLogListRequest struct {
+	Page      int    `form:"page" binding:"omitempty,min=1"`
+	PageSize  int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	SessionID string `form:"session_id" binding:"omitempty,max=100"`
+	Username  string `form:"username" binding:"omitempty,max=50"`
+	AssetID   uint   `form:"asset_id" binding:"omitempty"`
+	Direction string `form:"direction" binding:"omitempty,oneof=upload download"`
+	Channel   string `form:"channel" binding:"omitempty,oneof=sftp web"`
+	Status    string `form:"status" binding:"omitempty,oneof=success failed denied"`
+	Filename  string `form:"filename" binding:"omitempty,max=255"`
+	StartTime string `form:"start_time" binding:"omitempty"`
+	EndTime   string `form:"end_time" binding:"omitempty"`
+}
+
+// FileTransferLogResponse 文件传输日志响应
+type FileTransferLogResponse struct {
+	ID           uint      `json:"id"`
+	SessionID    string    `json:"session_id"`
+	UserID       uint      `json:"user_id"`
+	Username     string    `json:"username"`
+	AssetID      uint      `json:"asset_id"`
+	AssetName    string    `json:"asset_name"`
+	CredentialID uint      `json:"credential_id"`
+	Channel      string    `json:"channel"`
+	Direction    string    `json:"direction"`
+	Filename     string    `json:"filename"`
+	RemotePath   string    `json:"remote_path"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	Status       string    `json:"status"`
+	Message      string    `json:"message"`
+	ClientIP     string    `json:"client_ip"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+}
+
+// ToResponse 转换为响应格式
+func (l *FileTransferLog) ToResponse() *FileTransferLogResponse {
+	return &FileTransferLogResponse{
+		ID:           l.ID,
+		SessionID:    l.SessionID,
+		UserID:       l.UserID,
+		Username:     l.Username,
+		AssetID:      l.AssetID,
+		AssetName:    l.AssetName,
+		CredentialID: l.CredentialID,
+		Channel:      l.Channel,
+		Direction:    l.Direction,
+		Filename:     l.Filename,
+		RemotePath:   l.RemotePath,
+		Size:         l.Size,
+		SHA256:       l.SHA256,
+		Status:       l.Status,
+		Message:      l.Message,
+		ClientIP:     l.ClientIP,
+		StartTime:    l.StartTime,
+		EndTime:      l.EndTime,
+	}
+}
+
+// RemoteFileInfo 资产上的文件信息，用于Web会话浏览目录
+type RemoteFileInfo struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	IsDir   bool      `json:"is_dir"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// NewRemoteFileInfo 根据文件属性创建远程文件信息
+func NewRemoteFileInfo(dir string, info os.FileInfo) *RemoteFileInfo {
+	return &RemoteFileInfo{
+		Name:    info.Name(),
+		Path:    path.Join(dir, info.Name()),
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		IsDir:   info.IsDir(),
+		ModTime: info.ModTime(),
+	}
+}
+
+// RemoteFileListResponse 远程目录列表响应
+type RemoteFileListResponse struct {
+	Path  string            `json:"path"`
+	Files []*RemoteFileInfo `json:"files"`
+}
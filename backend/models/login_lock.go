@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// 登录锁定相关的登录日志状态
+const (
+	LoginStatusLocked    = "locked"    // 失败次数过多被锁定，或锁定期间尝试登录
+	LoginStatusThrottled = "throttled" // 连续失败后未等待足够时间即再次尝试
+	LoginStatusUnlocked  = "unlocked"  // 管理员解除锁定
+)
+
+// 登录锁定对象类型
+const (
+	LoginLockTypeUser = "user"
+	LoginLockTypeIP   = "ip"
+)
+
+// LoginLockResponse 登录锁定信息
+type LoginLockResponse struct {
+	Type      string    `json:"type"`   // user 或 ip
+	Target    string    `json:"target"` // 用户名或IP
+	Attempts  int       `json:"attempts"`
+	LockedAt  time.Time `json:"locked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LoginUnlockRequest 解除登录锁定请求，用户名和IP至少填写一项
+type LoginUnlockRequest struct {
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
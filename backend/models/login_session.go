@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// 登录会话相关的登录日志状态
+const (
+	LoginStatusSessionRevoked = "session_revoked" // 登录会话被用户或管理员注销
+)
+
+// LoginSession Web登录会话，对应一个已签发的访问令牌，保存在Redis中
+type LoginSession struct {
+	ID         string    `json:"id"` // 访问令牌的jti
+	UserID     uint      `json:"user_id"`
+	Username   string    `json:"username"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	IssuedAt   time.Time `json:"issued_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// LoginSessionResponse 登录会话响应
+type LoginSessionResponse struct {
+	*LoginSession
+	Current bool `json:"current"` // 是否为当前请求使用的会话
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NetworkZone 网络区域
+// 区域内的资产默认经由区域网关（跳板机）连接；资产自身配置的网关优先于区域网关。
+// 网关本身也是资产，可以再经由自己的网关或所在区域的网关连接，形成多级链路。
+type NetworkZone struct {
+	ID                  uint      `json:"id" gorm:"primaryKey"`
+	Name                string    `json:"name" gorm:"uniqueIndex;not null;size:50;comment:区域名称"`
+	Description         string    `json:"description" gorm:"type:text;comment:描述"`
+	GatewayID           *uint     `json:"gateway_id" gorm:"index;comment:区域网关资产ID，为空表示直连"`
+	GatewayCredentialID *uint     `json:"gateway_credential_id" gorm:"comment:登录区域网关使用的凭证ID"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+
+	// 关联关系
+	Gateway *Asset `json:"gateway,omitempty" gorm:"foreignKey:GatewayID"`
+}
+
+// TableName 指定表名
+func (NetworkZone) TableName() string {
+	return "network_zones"
+}
+
+// GatewayHop 网关链路中的一跳，记录连接时实际经过的网关
+type GatewayHop struct {
+	AssetID      uint   `json:"asset_id"`
+	AssetName    string `json:"asset_name"`
+	Address      string `json:"address"`
+	CredentialID uint   `json:"credential_id"`
+	Username     string `json:"username"`
+}
+
+// GatewayChain 网关链路，第一跳为堡垒机直接连接的网关，最后一跳负责转发到目标资产
+// 以JSON保存，直连时为空
+type GatewayChain []GatewayHop
+
+// Value 实现 driver.Valuer
+func (c GatewayChain) Value() (driver.Value, error) {
+	if len(c) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner
+func (c *GatewayChain) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported gateway chain type %T", value)
+	}
+	if len(data) == 0 {
+		*c = nil
+		return nil
+	}
+	return json.Unmarshal(data, c)
+}
+
+// String 以 "name(address) -> ..." 的形式描述链路，用于日志
+func (c GatewayChain) String() string {
+	parts := make([]string, len(c))
+	for i, hop := range c {
+		parts[i] = fmt.Sprintf("%s(%s)", hop.AssetName, hop.Address)
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// NetworkZoneCreateRequest 创建网络区域请求
+type NetworkZoneCreateRequest struct {
+	Name                string `json:"name" binding:"required,min=1,max=50"`
+	Description         string `json:"description" binding:"omitempty,max=500"`
+	GatewayID           *uint  `json:"gateway_id" binding:"omitempty"`
+	GatewayCredentialID *uint  `json:"gateway_credential_id" binding:"omitempty"`
+}
+
+// NetworkZoneUpdateRequest 更新网络区域请求，gateway_id 传0表示改为直连
+type NetworkZoneUpdateRequest struct {
+	Name                string  `json:"name" binding:"omitempty,min=1,max=50"`
+	Description         *string `json:"description" binding:"omitempty,max=500"`
+	GatewayID           *uint   `json:"gateway_id" binding:"omitempty"`
+	GatewayCredentialID *uint   `json:"gateway_credential_id" binding:"omitempty"`
+}
+
+// NetworkZoneListRequest 网络区域列表请求
+type NetworkZoneListRequest struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Keyword  string `form:"keyword" binding:"omitempty,max=50"`
+}
+
+// NetworkZoneResponse 网络区域响应
+type NetworkZoneResponse struct {
+	ID                  uint      `json:"id"`
+	Name                string    `json:"name"`
+	Description         string    `json:"description"`
+	GatewayID           *uint     `json:"gateway_id"`
+	GatewayName         string    `json:"gateway_name,omitempty"`
+	GatewayCredentialID *uint     `json:"gateway_credential_id"`
+	AssetCount          int64     `json:"asset_count"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// ToResponse 转换为响应格式
+func (z *NetworkZone) ToResponse() *NetworkZoneResponse {
+	resp := &NetworkZoneResponse{
+		ID:                  z.ID,
+		Name:                z.Name,
+		Description:         z.Description,
+		GatewayID:           z.GatewayID,
+		GatewayCredentialID: z.GatewayCredentialID,
+		CreatedAt:           z.CreatedAt,
+		UpdatedAt:           z.UpdatedAt,
+	}
+	if z.Gateway != nil {
+		resp.GatewayName = z.Gateway.Name
+	}
+	return resp
+}
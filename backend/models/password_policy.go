@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// PasswordPolicy 密码策略，全局只有一条记录
+type PasswordPolicy struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	MinLength        int       `json:"min_length" gorm:"not null;default:6;comment:最小长度"`
+	RequireUppercase bool      `json:"require_uppercase" gorm:"not null;default:false;comment:必须包含大写字母"`
+	RequireLowercase bool      `json:"require_lowercase" gorm:"not null;default:false;comment:必须包含小写字母"`
+	RequireDigit     bool      `json:"require_digit" gorm:"not null;default:true;comment:必须包含数字"`
+	RequireSpecial   bool      `json:"require_special" gorm:"not null;default:false;comment:必须包含特殊字符"`
+	MinClasses       int       `json:"min_classes" gorm:"not null;default:2;comment:至少包含的字符类别数(大写/小写/数字/特殊字符)"`
+	ForbidUsername   bool      `json:"forbid_username" gorm:"not null;default:true;comment:不能包含用户名"`
+	ForbidCommon     bool      `json:"forbid_common" gorm:"not null;default:true;comment:不能使用常见弱密码"`
+	DictionaryWords  string    `json:"dictionary_words" gorm:"type:text;comment:自定义禁用词，逗号分隔，如公司名"`
+	HistoryCount     int       `json:"history_count" gorm:"not null;default:0;comment:不能与最近N次使用过的密码相同"`
+	MaxAgeDays       int       `json:"max_age_days" gorm:"not null;default:0;comment:密码有效期(天)，0表示永不过期"`
+	UpdatedBy        string    `json:"updated_by" gorm:"size:50;comment:最后修改人"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (PasswordPolicy) TableName() string {
+	return "password_policies"
+}
+
+// PasswordHistory 用户历史密码，用于禁止重复使用
+type PasswordHistory struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UserID       uint      `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	PasswordHash string    `json:"-" gorm:"not null;size:255;comment:历史密码哈希"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
+
+// PasswordPolicyUpdateRequest 更新密码策略请求
+type PasswordPolicyUpdateRequest struct {
+	MinLength        int    `json:"min_length" binding:"min=6,max=50"`
+	RequireUppercase bool   `json:"require_uppercase"`
+	RequireLowercase bool   `json:"require_lowercase"`
+	RequireDigit     bool   `json:"require_digit"`
+	RequireSpecial   bool   `json:"require_special"`
+	MinClasses       int    `json:"min_classes" binding:"min=0,max=4"`
+	ForbidUsername   bool   `json:"forbid_username"`
+	ForbidCommon     bool   `json:"forbid_common"`
+	DictionaryWords  string `json:"dictionary_words" binding:"max=2000"`
+	HistoryCount     int    `json:"history_count" binding:"min=0,max=24"`
+	MaxAgeDays       int    `json:"max_age_days" binding:"min=0,max=3650"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 隧道类型
+const (
+	TunnelTypeSSH = "ssh" // SSH网关上的 direct-tcpip 通道（ssh -L 本地转发、ssh -D 动态转发）
+	TunnelTypeWeb = "web" // 绑定Web会话的本地回环隧道
+)
+
+// TunnelProtocol 隧道在会话记录中的协议名
+const TunnelProtocol = "tunnel"
+
+// ParseForwardPorts 解析端口转发授权的端口列表
+// 格式为逗号分隔的端口或端口范围，如 "80,443,8000-8100"
+func ParseForwardPorts(spec string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		low, high := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			low, high = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+		}
+		start, err := strconv.Atoi(low)
+		if err != nil || start < 1 || start > 65535 {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		end, err := strconv.Atoi(high)
+		if err != nil || end < start || end > 65535 {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges, nil
+}
+
+// ForwardPortAllowed 判断端口是否在端口列表中，列表无法解析时视为不允许
+func ForwardPortAllowed(spec string, port int) bool {
+	ranges, err := ParseForwardPorts(spec)
+	if err != nil {
+		return false
+	}
+	for _, r := range ranges {
+		if port >= r[0] && port <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// PortTunnelCreateRequest 在Web会话上打开隧道的请求
+type PortTunnelCreateRequest struct {
+	AssetID uint `json:"asset_id" binding:"omitempty"` // 目标资产，默认为会话所连资产
+	Port    int  `json:"port" binding:"required,min=1,max=65535"`
+}
+
+// PortTunnelResponse 隧道信息
+type PortTunnelResponse struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	SessionID      string    `json:"session_id,omitempty"`  // 绑定的Web会话
+	ListenAddr     string    `json:"listen_addr,omitempty"` // Web隧道在堡垒机回环地址上的监听地址
+	UserID         uint      `json:"user_id"`
+	Username       string    `json:"username"`
+	ClientIP       string    `json:"client_ip"`
+	AssetID        uint      `json:"asset_id"`
+	AssetName      string    `json:"asset_name"`
+	Target         string    `json:"target"`
+	ActiveChannels int       `json:"active_channels"`
+	TotalChannels  int       `json:"total_channels"`
+	BytesSent      int64     `json:"bytes_sent"`     // 客户端发往目标的字节数
+	BytesReceived  int64     `json:"bytes_received"` // 目标返回客户端的字节数
+	CreatedAt      time.Time `json:"created_at"`
+}
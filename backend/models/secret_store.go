@@ -0,0 +1,19 @@
+package models
+
+// SecretFieldStats 单个加密字段按主密钥版本的统计，版本0表示引入密钥后端之前的旧格式或未加密数据
+type SecretFieldStats struct {
+	Table    string      `json:"table"`
+	Column   string      `json:"column"`
+	Versions map[int]int `json:"versions"`
+}
+
+// SecretReencryptStats 单个加密字段的重新加密结果
+type SecretReencryptStats struct {
+	Table       string   `json:"table"`
+	Column      string   `json:"column"`
+	Scanned     int      `json:"scanned"`     // 检查的记录数
+	Reencrypted int      `json:"reencrypted"` // 重新加密的记录数
+	Skipped     int      `json:"skipped"`     // 处理期间被其它请求更新的记录数
+	Failed      int      `json:"failed"`      // 解密或写入失败的记录数
+	Errors      []string `json:"errors,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// SSH CA 状态
+const (
+	SSHCAStatusActive  = "active"  // 当前用于签发证书
+	SSHCAStatusRetired = "retired" // 已轮换，公钥仍随信任列表导出，便于目标机过渡
+)
+
+// SSHCertificateAuthority 堡垒机管理的SSH证书颁发机构
+// 使用 cert 类型凭证连接资产时，为每个会话签发短期用户证书，目标机通过 TrustedUserCAKeys 信任CA公钥。
+// 同一时间只有一个CA处于 active 状态。
+type SSHCertificateAuthority struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	KeyType     string     `json:"key_type" gorm:"size:50;not null;comment:密钥类型"`
+	PublicKey   string     `json:"public_key" gorm:"type:text;not null;comment:CA公钥，authorized_keys格式"`
+	PrivateKey  string     `json:"-" gorm:"type:text;not null;comment:CA私钥，加密存储"`
+	Fingerprint string     `json:"fingerprint" gorm:"size:100;not null;uniqueIndex;comment:SHA256指纹"`
+	Status      string     `json:"status" gorm:"size:20;not null;index;comment:状态 active/retired"`
+	RetiredAt   *time.Time `json:"retired_at" gorm:"comment:轮换时间"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (SSHCertificateAuthority) TableName() string {
+	return "ssh_certificate_authorities"
+}
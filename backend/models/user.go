@@ -6,17 +6,27 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户认证来源
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+	AuthSourceOIDC  = "oidc"
+)
+
 // User 用户模型
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Username  string         `json:"username" gorm:"uniqueIndex;not null;size:50"`
-	Password  string         `json:"-" gorm:"not null;size:255"`
-	Email     string         `json:"email" gorm:"size:100"`
-	Phone     string         `json:"phone" gorm:"size:20"`
-	Status    int            `json:"status" gorm:"default:1"` // 1-启用, 0-禁用
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	Username          string         `json:"username" gorm:"uniqueIndex;not null;size:50"`
+	Password          string         `json:"-" gorm:"not null;size:255"`
+	Email             string         `json:"email" gorm:"size:100"`
+	Phone             string         `json:"phone" gorm:"size:20"`
+	Status            int            `json:"status" gorm:"default:1"`                  // 1-启用, 0-禁用
+	AuthSource        string         `json:"auth_source" gorm:"size:20;default:local"` // 认证来源：local, ldap, oidc
+	ExternalID        string         `json:"-" gorm:"size:255"`                        // 外部目录中的用户标识，如LDAP DN
+	PasswordChangedAt *time.Time     `json:"password_changed_at"`                      // 最后修改密码时间，为空时以创建时间计算有效期
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// 关联关系
 	UserRoles []UserRole `json:"user_roles" gorm:"foreignKey:UserID"`
@@ -28,6 +38,7 @@ type Role struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
 	Name        string         `json:"name" gorm:"uniqueIndex;not null;size:50"`
 	Description string         `json:"description" gorm:"type:text"`
+	MFARequired bool           `json:"mfa_required" gorm:"column:mfa_required;default:false"` // 该角色用户登录是否强制MFA
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -101,15 +112,17 @@ type UserLoginRequest struct {
 
 // UserResponse 用户响应
 type UserResponse struct {
-	ID          uint      `json:"id"`
-	Username    string    `json:"username"`
-	Email       string    `json:"email"`
-	Phone       string    `json:"phone"`
-	Status      int       `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Roles       []Role    `json:"roles"`
-	Permissions []string  `json:"permissions"` // 添加permissions字段
+	ID                uint       `json:"id"`
+	Username          string     `json:"username"`
+	Email             string     `json:"email"`
+	Phone             string     `json:"phone"`
+	Status            int        `json:"status"`
+	AuthSource        string     `json:"auth_source"`
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	Roles             []Role     `json:"roles"`
+	Permissions       []string   `json:"permissions"` // 添加permissions字段
 }
 
 // PasswordChangeRequest 密码修改请求
@@ -118,16 +131,24 @@ type PasswordChangeRequest struct {
 	NewPassword string `json:"new_password" binding:"required,min=6,max=50"`
 }
 
+// LDAPSyncResult LDAP用户同步结果
+type LDAPSyncResult struct {
+	Checked       int      `json:"checked"`        // 检查的LDAP用户数
+	DisabledUsers []string `json:"disabled_users"` // 因目录中已删除或已禁用而被禁用的用户
+}
+
 // RoleCreateRequest 角色创建请求
 type RoleCreateRequest struct {
 	Name        string   `json:"name" binding:"required,min=2,max=50"`
 	Description string   `json:"description"`
+	MFARequired bool     `json:"mfa_required"`
 	Permissions []string `json:"permissions" binding:"required"`
 }
 
 // RoleUpdateRequest 角色更新请求
 type RoleUpdateRequest struct {
 	Description string   `json:"description"`
+	MFARequired *bool    `json:"mfa_required"`
 	Permissions []string `json:"permissions"`
 }
 
@@ -136,6 +157,7 @@ type RoleResponse struct {
 	ID          uint      `json:"id"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	MFARequired bool      `json:"mfa_required"`
 	Permissions []string  `json:"permissions"`
 	UserCount   int       `json:"user_count"` // 拥有此角色的用户数量
 	CreatedAt   time.Time `json:"created_at"`
@@ -192,15 +214,17 @@ func (u *User) ToResponse() *UserResponse {
 	}
 	
 	return &UserResponse{
-		ID:          u.ID,
-		Username:    u.Username,
-		Email:       u.Email,
-		Phone:       u.Phone,
-		Status:      u.Status,
-		CreatedAt:   u.CreatedAt,
-		UpdatedAt:   u.UpdatedAt,
-		Roles:       u.Roles,
-		Permissions: permissions,
+		ID:                u.ID,
+		Username:          u.Username,
+		Email:             u.Email,
+		Phone:             u.Phone,
+		Status:            u.Status,
+		AuthSource:        u.AuthSource,
+		PasswordChangedAt: u.PasswordChangedAt,
+		CreatedAt:         u.CreatedAt,
+		UpdatedAt:         u.UpdatedAt,
+		Roles:             u.Roles,
+		Permissions:       permissions,
 	}
 }
 
@@ -215,6 +239,7 @@ func (r *Role) ToResponse() *RoleResponse {
 		ID:          r.ID,
 		Name:        r.Name,
 		Description: r.Description,
+		MFARequired: r.MFARequired,
 		Permissions: permissions,
 		UserCount:   len(r.Users), // 这里需要在查询时预加载Users
 		CreatedAt:   r.CreatedAt,
@@ -255,6 +280,36 @@ func (u *User) HasRole(roleName string) bool {
 	return false
 }
 
+// RestrictPermissions 将用户权限限制在API令牌的权限范围内（需预加载Roles.Permissions）
+// 范围包含all时保留全部角色；否则替换为只含范围内权限的合成角色，HasRole不再匹配任何真实角色
+func (u *User) RestrictPermissions(scopes []string) {
+	var permissions []Permission
+	for _, scope := range scopes {
+		if scope == APITokenScopeAll {
+			return
+		}
+		if u.HasPermission(scope) {
+			permissions = append(permissions, Permission{Name: scope})
+		}
+	}
+	u.Roles = []Role{{Name: APITokenRoleName, Permissions: permissions}}
+}
+
+// RequiresMFA 检查用户所属角色是否强制MFA（需预加载Roles）
+func (u *User) RequiresMFA() bool {
+	for _, role := range u.Roles {
+		if role.MFARequired {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExternal 检查用户是否由外部目录认证（密码不在本地维护）
+func (u *User) IsExternal() bool {
+	return u.AuthSource != "" && u.AuthSource != AuthSourceLocal
+}
+
 // IsActive 检查用户是否激活
 func (u *User) IsActive() bool {
 	return u.Status == 1
@@ -280,6 +335,10 @@ type Asset struct {
 	// 分组关系改为一对多（一个资产只能属于一个分组）
 	GroupID     *uint      `json:"group_id" gorm:"index;comment:资产分组ID"`
 	Group       *AssetGroup `json:"group,omitempty" gorm:"foreignKey:GroupID"`
+	// 网关（跳板机）：资产配置的网关优先于所在网络区域的网关，都为空时直连
+	ZoneID              *uint `json:"zone_id" gorm:"index;comment:网络区域ID"`
+	GatewayID           *uint `json:"gateway_id" gorm:"index;comment:网关资产ID"`
+	GatewayCredentialID *uint `json:"gateway_credential_id" gorm:"comment:登录网关使用的凭证ID"`
 }
 
 // Credential 凭证模型
@@ -288,8 +347,9 @@ type Credential struct {
 	Name       string         `json:"name" gorm:"not null;size:100"`
 	Type       string         `json:"type" gorm:"not null;size:20;default:password"`
 	Username   string         `json:"username" gorm:"size:100"`
-	Password   string         `json:"password" gorm:"size:255"`
-	PrivateKey string         `json:"private_key" gorm:"type:text"`
+	Password   string         `json:"-" gorm:"size:512"`  // 加密保存，任何响应都不返回
+	PrivateKey string         `json:"-" gorm:"type:text"` // 加密保存，任何响应都不返回
+	SecretHint string         `json:"secret_hint" gorm:"size:100;comment:密钥指纹或密码长度区间提示"`
 	CreatedAt  time.Time      `json:"created_at"`
 	UpdatedAt  time.Time      `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
@@ -329,10 +389,14 @@ type AssetCreateRequest struct {
 	OsType        string `json:"os_type" binding:"omitempty,oneof=linux windows"`
 	Address       string `json:"address" binding:"required,min=1,max=255"`
 	Port          int    `json:"port" binding:"required,min=1,max=65535"`
-	Protocol      string `json:"protocol" binding:"required,oneof=ssh rdp vnc mysql postgresql"`
+	Protocol      string `json:"protocol" binding:"required,oneof=ssh rdp vnc telnet mysql postgresql redis"`
 	Tags          string `json:"tags"`
 	CredentialIDs []uint `json:"credential_ids" binding:"omitempty"` // 可选的凭证ID列表
 	GroupID       *uint  `json:"group_id" binding:"omitempty"`        // 可选的分组ID
+
+	ZoneID              *uint `json:"zone_id" binding:"omitempty"`               // 可选的网络区域ID
+	GatewayID           *uint `json:"gateway_id" binding:"omitempty"`            // 可选的网关资产ID
+	GatewayCredentialID *uint `json:"gateway_credential_id" binding:"omitempty"` // 登录网关使用的凭证ID，指定网关时必填
 }
 
 // AssetUpdateRequest 资产更新请求
@@ -342,11 +406,15 @@ type AssetUpdateRequest struct {
 	OsType        string `json:"os_type" binding:"omitempty,oneof=linux windows"`
 	Address       string `json:"address" binding:"omitempty,min=1,max=255"`
 	Port          int    `json:"port" binding:"omitempty,min=1,max=65535"`
-	Protocol      string `json:"protocol" binding:"omitempty,oneof=ssh rdp vnc mysql postgresql"`
+	Protocol      string `json:"protocol" binding:"omitempty,oneof=ssh rdp vnc telnet mysql postgresql redis"`
 	Tags          string `json:"tags"`
 	Status        *int   `json:"status" binding:"omitempty,oneof=0 1"`
 	CredentialIDs []uint `json:"credential_ids" binding:"omitempty"` // 可选的凭证ID列表
 	GroupID       *uint  `json:"group_id" binding:"omitempty"`        // 可选的分组ID
+
+	ZoneID              *uint `json:"zone_id" binding:"omitempty"`               // 网络区域ID，传0表示移出区域
+	GatewayID           *uint `json:"gateway_id" binding:"omitempty"`            // 网关资产ID，传0表示不再使用资产级网关
+	GatewayCredentialID *uint `json:"gateway_credential_id" binding:"omitempty"` // 登录网关使用的凭证ID
 }
 
 // AssetResponse 资产响应
@@ -366,6 +434,10 @@ type AssetResponse struct {
 	GroupID          *uint        `json:"group_id,omitempty"`
 	Group            *AssetGroup  `json:"group,omitempty"`
 	ConnectionStatus string       `json:"connection_status,omitempty"`
+
+	ZoneID              *uint `json:"zone_id,omitempty"`
+	GatewayID           *uint `json:"gateway_id,omitempty"`
+	GatewayCredentialID *uint `json:"gateway_credential_id,omitempty"`
 }
 
 // AssetListRequest 资产列表请求
@@ -381,7 +453,7 @@ type AssetListRequest struct {
 // CredentialCreateRequest 凭证创建请求
 type CredentialCreateRequest struct {
 	Name       string `json:"name" binding:"required,min=1,max=100"`
-	Type       string `json:"type" binding:"required,oneof=password key"`
+	Type       string `json:"type" binding:"required,oneof=password key cert"`
 	Username   string `json:"username" binding:"required,min=1,max=100"`
 	Password   string `json:"password"`
 	PrivateKey string `json:"private_key"`
@@ -391,7 +463,7 @@ type CredentialCreateRequest struct {
 // CredentialUpdateRequest 凭证更新请求
 type CredentialUpdateRequest struct {
 	Name       string `json:"name" binding:"omitempty,min=1,max=100"`
-	Type       string `json:"type" binding:"omitempty,oneof=password key"`
+	Type       string `json:"type" binding:"omitempty,oneof=password key cert"`
 	Username   string `json:"username" binding:"omitempty,min=1,max=100"`
 	Password   string `json:"password"`
 	PrivateKey string `json:"private_key"`
@@ -399,13 +471,14 @@ type CredentialUpdateRequest struct {
 
 // CredentialResponse 凭证响应
 type CredentialResponse struct {
-	ID        uint            `json:"id"`
-	Name      string          `json:"name"`
-	Type      string          `json:"type"`
-	Username  string          `json:"username"`
-	Assets    []AssetResponse `json:"assets,omitempty"`
-	CreatedAt time.Time       `json:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at"`
+	ID         uint            `json:"id"`
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Username   string          `json:"username"`
+	SecretHint string          `json:"secret_hint"` // 密钥凭证为公钥指纹，密码凭证为密码长度区间
+	Assets     []AssetResponse `json:"assets,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
 }
 
 // CredentialListRequest 凭证列表请求
@@ -413,7 +486,7 @@ type CredentialListRequest struct {
 	Page     int    `form:"page" binding:"omitempty,min=1"`
 	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
 	Keyword  string `form:"keyword" binding:"omitempty,max=50"`
-	Type     string `form:"type" binding:"omitempty,oneof=password key"`
+	Type     string `form:"type" binding:"omitempty,oneof=password key cert"`
 	AssetID  uint   `form:"asset_id" binding:"omitempty"` // 保留用于过滤
 }
 
@@ -421,7 +494,7 @@ type CredentialListRequest struct {
 type ConnectionTestRequest struct {
 	AssetID      uint   `json:"asset_id" binding:"required"`
 	CredentialID uint   `json:"credential_id" binding:"required"`
-	TestType     string `json:"test_type" binding:"required,oneof=ping ssh rdp database"`
+	TestType     string `json:"test_type" binding:"required,oneof=ping ssh rdp database telnet"`
 }
 
 // ConnectionTestResponse 连接测试响应
@@ -467,17 +540,22 @@ func (a *Asset) ToResponse() *AssetResponse {
 		Credentials: a.Credentials,
 		GroupID:     a.GroupID,
 		Group:       a.Group,
+
+		ZoneID:              a.ZoneID,
+		GatewayID:           a.GatewayID,
+		GatewayCredentialID: a.GatewayCredentialID,
 	}
 }
 
 func (c *Credential) ToResponse() *CredentialResponse {
 	resp := &CredentialResponse{
-		ID:        c.ID,
-		Name:      c.Name,
-		Type:      c.Type,
-		Username:  c.Username,
-		CreatedAt: c.CreatedAt,
-		UpdatedAt: c.UpdatedAt,
+		ID:         c.ID,
+		Name:       c.Name,
+		Type:       c.Type,
+		Username:   c.Username,
+		SecretHint: c.SecretHint,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
 	}
 
 	// 转换关联的资产
@@ -577,6 +655,8 @@ type SessionRecord struct {
 	TimeoutMinutes *int       `json:"timeout_minutes" gorm:"index;comment:会话超时时间(分钟)，null表示无限制"`
 	LastActivity   *time.Time `json:"last_activity" gorm:"comment:最后活动时间，用于超时计算"`
 	CloseReason    string     `json:"close_reason" gorm:"size:100;comment:会话关闭原因"` // normal_exit, timeout, forced_close, network_error, etc.
+
+	GatewayChain GatewayChain `json:"gateway_chain" gorm:"type:text;comment:连接经过的网关链路，直连时为空"`
 	
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
@@ -645,7 +725,7 @@ type SessionRecordListRequest struct {
 	PageSize  int    `form:"page_size" binding:"omitempty,min=1,max=100"`
 	Username  string `form:"username" binding:"omitempty,max=50"`
 	AssetName string `form:"asset_name" binding:"omitempty,max=100"`
-	Protocol  string `form:"protocol" binding:"omitempty,oneof=ssh rdp vnc"`
+	Protocol  string `form:"protocol" binding:"omitempty,oneof=ssh rdp vnc telnet tunnel mysql postgresql redis"`
 	Status    string `form:"status" binding:"omitempty,oneof=active closed timeout"`
 	IP        string `form:"ip" binding:"omitempty,max=45"`
 	StartTime string `form:"start_time" binding:"omitempty"`
@@ -713,6 +793,7 @@ type SessionRecordResponse struct {
 	EndTime      *time.Time `json:"end_time"`
 	Duration     int64      `json:"duration"`
 	RecordPath   string     `json:"record_path"`
+	GatewayChain GatewayChain `json:"gateway_chain,omitempty"` // 连接经过的网关链路
 	
 	// 🆕 超时管理响应字段
 	TimeoutMinutes *int       `json:"timeout_minutes,omitempty"` // 超时时间(分钟)
@@ -826,6 +907,7 @@ func (s *SessionRecord) ToResponse() *SessionRecordResponse {
 		EndTime:      s.EndTime,
 		Duration:     s.Duration,
 		RecordPath:   s.RecordPath,
+		GatewayChain: s.GatewayChain,
 		TimeoutMinutes: s.TimeoutMinutes,
 		LastActivity:   s.LastActivity,
 		CloseReason:    s.CloseReason,
@@ -1018,7 +1100,7 @@ type ActiveSessionListRequest struct {
 	PageSize  int    `form:"page_size" binding:"omitempty,min=1,max=100"`
 	Username  string `form:"username" binding:"omitempty,max=50"`
 	AssetName string `form:"asset_name" binding:"omitempty,max=100"`
-	Protocol  string `form:"protocol" binding:"omitempty,oneof=ssh rdp vnc"`
+	Protocol  string `form:"protocol" binding:"omitempty,oneof=ssh rdp vnc telnet tunnel mysql postgresql redis"`
 	IP        string `form:"ip" binding:"omitempty,max=45"`
 }
 
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+)
+
+// MFA 相关登录日志状态
+const (
+	LoginStatusMFAChallenge  = "mfa_challenge"   // 密码校验通过，等待二次验证
+	LoginStatusMFASuccess    = "mfa_success"     // 二次验证通过
+	LoginStatusMFAFailed     = "mfa_failed"      // 二次验证失败
+	LoginStatusMFARecovery   = "mfa_recovery"    // 使用恢复码通过二次验证
+	LoginStatusMFAEnrolled   = "mfa_enrolled"    // 绑定认证器
+	LoginStatusMFADisabled   = "mfa_disabled"    // 用户自行解绑
+	LoginStatusMFAReset      = "mfa_reset"       // 管理员重置
+	LoginStatusMFACodesRegen = "mfa_codes_regen" // 重新生成恢复码
+)
+
+// UserMFA 用户TOTP多因素认证配置
+type UserMFA struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;uniqueIndex;comment:用户ID"`
+	Secret       string     `json:"-" gorm:"size:512;not null;comment:TOTP密钥(加密存储)"`
+	Enabled      bool       `json:"enabled" gorm:"default:false;comment:是否已完成绑定"`
+	EnabledAt    *time.Time `json:"enabled_at" gorm:"comment:绑定时间"`
+	LastUsedStep int64      `json:"-" gorm:"default:0;comment:最近一次通过验证的时间步，防止验证码重放"`
+	LastUsedAt   *time.Time `json:"last_used_at" gorm:"comment:最后验证时间"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// TableName 指定表名
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// UserMFARecoveryCode MFA恢复码，仅保存摘要，每个恢复码只能使用一次
+type UserMFARecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null;comment:恢复码SHA-256摘要"`
+	UsedAt    *time.Time `json:"used_at" gorm:"comment:使用时间"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (UserMFARecoveryCode) TableName() string {
+	return "user_mfa_recovery_codes"
+}
+
+// MFACodeRequest 携带验证码的请求（绑定、解绑、重新生成恢复码）
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required,min=6,max=20"`
+}
+
+// MFATokenRequest 登录二次验证阶段使用预认证令牌的请求
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFAVerifyRequest 登录二次验证请求，Code 可以是TOTP验证码或恢复码
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required,min=6,max=20"`
+}
+
+// MFAChallengeResponse 登录需要二次验证时的响应
+type MFAChallengeResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	EnrollRequired bool   `json:"enroll_required"` // 角色要求MFA但用户尚未绑定
+	MFAToken       string `json:"mfa_token"`
+	ExpiresIn      int    `json:"expires_in"`
+}
+
+// MFASetupResponse 绑定认证器响应
+type MFASetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// 地址
+	QRCode          string `json:"qr_code"`          // 二维码PNG的data URI
+}
+
+// MFARecoveryCodesResponse 恢复码响应，明文只在生成时返回一次
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAStatusResponse MFA状态响应
+type MFAStatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	Required               bool       `json:"required"` // 所属角色是否强制MFA
+	EnabledAt              *time.Time `json:"enabled_at"`
+	LastUsedAt             *time.Time `json:"last_used_at"`
+	RecoveryCodesRemaining int64      `json:"recovery_codes_remaining"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// UserSSHKey 用户登录SSH网关使用的公钥
+type UserSSHKey struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index;comment:用户ID"`
+	Name        string     `json:"name" gorm:"size:100;not null;comment:公钥名称"`
+	KeyType     string     `json:"key_type" gorm:"size:50;not null;comment:密钥类型"`
+	PublicKey   string     `json:"public_key" gorm:"type:text;not null;comment:公钥内容(authorized_keys格式)"`
+	Fingerprint string     `json:"fingerprint" gorm:"size:100;not null;uniqueIndex;comment:SHA256指纹"`
+	LastUsedAt  *time.Time `json:"last_used_at" gorm:"comment:最后使用时间"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// TableName 指定表名
+func (UserSSHKey) TableName() string {
+	return "user_ssh_keys"
+}
+
+// UserSSHKeyCreateRequest 添加公钥请求
+type UserSSHKeyCreateRequest struct {
+	Name      string `json:"name" binding:"required,min=1,max=100"`
+	PublicKey string `json:"public_key" binding:"required"`
+}
+
+// UserSSHKeyResponse 公钥响应
+type UserSSHKeyResponse struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	KeyType     string     `json:"key_type"`
+	Fingerprint string     `json:"fingerprint"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ToResponse 转换为响应格式
+func (k *UserSSHKey) ToResponse() *UserSSHKeyResponse {
+	return &UserSSHKeyResponse{
+		ID:          k.ID,
+		Name:        k.Name,
+		KeyType:     k.KeyType,
+		Fingerprint: k.Fingerprint,
+		LastUsedAt:  k.LastUsedAt,
+		CreatedAt:   k.CreatedAt,
+	}
+}
@@ -1,6 +1,7 @@
 package routers
 
 import (
+	"bastion/config"
 	"bastion/controllers"
 	"bastion/middleware"
 	"bastion/services"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 // SetupRouter 设置路由器
@@ -15,6 +17,15 @@ func SetupRouter() *gin.Engine {
 	// 创建Gin引擎
 	router := gin.Default()
 
+	// 只采信可信代理转发的客户端地址，c.ClientIP() 与 utils.GetClientIP 使用同一份配置
+	trustedProxies := config.GlobalConfig.Security.TrustedProxies
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		logrus.WithError(err).Fatal("Invalid security.trustedProxies")
+	}
+	if err := utils.SetTrustedProxies(trustedProxies); err != nil {
+		logrus.WithError(err).Fatal("Invalid security.trustedProxies")
+	}
+
 	// 设置CORS中间件 - 允许所有来源访问
 	router.Use(cors.New(cors.Config{
 		AllowOriginFunc: func(origin string) bool {
@@ -33,12 +44,35 @@ func SetupRouter() *gin.Engine {
 	roleService := services.NewRoleService(utils.GetDB())
 	assetService := services.NewAssetService(utils.GetDB())
 	sshService := services.NewSSHService(utils.GetDB())
+	services.GlobalSSHService = sshService // SSH网关与Web终端共享会话
 	auditService := services.NewAuditService(utils.GetDB())
 	monitorService := services.NewMonitorService(utils.GetDB())
 	commandGroupService := services.NewCommandGroupService(utils.GetDB())
 	commandFilterService := services.NewCommandFilterService(utils.GetDB())
 	commandMatcherService := services.NewCommandMatcherService(utils.GetDB(), commandFilterService)
 	dashboardService := services.NewDashboardService(utils.GetDB(), assetService, userService, auditService, monitorService)
+	assetPermissionService := services.NewAssetPermissionService(utils.GetDB())
+	userSSHKeyService := services.NewUserSSHKeyService(utils.GetDB())
+	fileTransferService := services.NewFileTransferService(utils.GetDB(), sshService)
+	mfaService := services.NewMFAService(utils.GetDB())
+	ldapBackend := services.NewLDAPAuthBackend(utils.GetDB())
+	oidcService := services.NewOIDCService(utils.GetDB())
+	loginGuardService := services.NewLoginGuardService(utils.GetDB())
+	passwordPolicyService := services.NewPasswordPolicyService(utils.GetDB())
+	apiTokenService := services.NewAPITokenService(utils.GetDB())
+	loginSessionService := services.NewLoginSessionService(utils.GetDB())
+	accessRequestService := services.NewAccessRequestService(utils.GetDB())
+	accessPolicyService := services.NewAccessPolicyService(utils.GetDB())
+	hostKeyService := services.NewHostKeyService(utils.GetDB())
+	sshCAService := services.NewSSHCAService(utils.GetDB())
+	credentialRotationService := services.NewCredentialRotationService(utils.GetDB())
+	credentialCheckoutService := services.NewCredentialCheckoutService(utils.GetDB())
+	networkZoneService := services.NewNetworkZoneService(utils.GetDB())
+	portForwardService := services.NewPortForwardService(utils.GetDB(), sshService)
+	services.GlobalPortForwardService = portForwardService // SSH网关、Web隧道与会话监控共享隧道
+	sqlFilterService := services.NewSQLFilterService(utils.GetDB())
+	databaseProxyService := services.NewDatabaseProxyService(utils.GetDB(), sqlFilterService, commandMatcherService)
+	services.GlobalDatabaseProxyService = databaseProxyService // 代理监听、令牌签发与会话监控共享会话表
 
 	// 创建控制器实例
 	authController := controllers.NewAuthController(authService)
@@ -52,9 +86,29 @@ func SetupRouter() *gin.Engine {
 	commandGroupController := controllers.NewCommandGroupController(commandGroupService)
 	commandFilterController := controllers.NewCommandFilterController(commandFilterService, commandMatcherService)
 	dashboardController := controllers.NewDashboardController(dashboardService)
+	assetPermissionController := controllers.NewAssetPermissionController(assetPermissionService)
+	userSSHKeyController := controllers.NewUserSSHKeyController(userSSHKeyService)
+	fileTransferController := controllers.NewFileTransferController(fileTransferService)
+	mfaController := controllers.NewMFAController(authService, mfaService, auditService)
+	ldapController := controllers.NewLDAPController(ldapBackend)
+	oidcController := controllers.NewOIDCController(oidcService, auditService)
+	loginLockController := controllers.NewLoginLockController(loginGuardService, auditService)
+	passwordPolicyController := controllers.NewPasswordPolicyController(passwordPolicyService)
+	apiTokenController := controllers.NewAPITokenController(apiTokenService)
+	loginSessionController := controllers.NewLoginSessionController(loginSessionService, auditService)
+	accessRequestController := controllers.NewAccessRequestController(accessRequestService)
+	accessPolicyController := controllers.NewAccessPolicyController(accessPolicyService)
+	hostKeyController := controllers.NewHostKeyController(hostKeyService)
+	sshCAController := controllers.NewSSHCAController(sshCAService)
+	credentialRotationController := controllers.NewCredentialRotationController(credentialRotationService)
+	credentialCheckoutController := controllers.NewCredentialCheckoutController(credentialCheckoutService)
+	networkZoneController := controllers.NewNetworkZoneController(networkZoneService)
+	portForwardController := controllers.NewPortForwardController(portForwardService)
+	databaseProxyController := controllers.NewDatabaseProxyController(databaseProxyService, sqlFilterService)
 
 	// API 路由组
 	api := router.Group("/api/v1")
+	api.Use(middleware.RateLimitMiddleware())
 	{
 		// 健康检查
 		api.GET("/health", func(c *gin.Context) {
@@ -72,8 +126,27 @@ func SetupRouter() *gin.Engine {
 		{
 			auth.POST("/login", authController.Login)
 			auth.POST("/refresh", authController.RefreshToken)
+
+			// 登录二次验证（使用预认证令牌）
+			auth.POST("/mfa/verify", mfaController.VerifyLogin)
+			auth.POST("/mfa/setup", mfaController.SetupLogin)
+			auth.POST("/mfa/enable", mfaController.EnableLogin)
+
+			// OIDC单点登录
+			auth.GET("/oidc/login", oidcController.Login)
+			auth.GET("/oidc/callback", oidcController.Callback)
 		}
 
+		// SSH CA公钥（不需要身份验证），供目标机配置 TrustedUserCAKeys
+		api.GET("/ssh-ca/public-key", sshCAController.GetTrustedPublicKeys)
+
+		// 修改密码，密码过期登录后签发的受限令牌也可以访问
+		api.POST("/change-password",
+			middleware.AuthMiddleware(utils.TokenPurposePasswordChange),
+			middleware.RejectAPIToken(),
+			auditService.LogMiddleware(),
+			authController.ChangePassword)
+
 		// 需要身份验证的路由
 		authenticated := api.Group("/")
 		authenticated.Use(middleware.AuthMiddleware())
@@ -82,10 +155,35 @@ func SetupRouter() *gin.Engine {
 			// 当前用户相关路由
 			authenticated.GET("/profile", authController.GetProfile)
 			authenticated.PUT("/profile", authController.UpdateProfile)
-			authenticated.POST("/change-password", authController.ChangePassword)
 			authenticated.POST("/logout", authController.Logout)
 			authenticated.GET("/me", authController.GetCurrentUser)
 
+			// 账号安全设置（登录凭据、多因素认证、API令牌和登录会话），不接受API令牌访问
+			accountSecurity := authenticated.Group("/profile")
+			accountSecurity.Use(middleware.RejectAPIToken())
+			{
+				// SSH网关登录公钥
+				accountSecurity.GET("/ssh-keys", userSSHKeyController.GetKeys)
+				accountSecurity.POST("/ssh-keys", userSSHKeyController.AddKey)
+				accountSecurity.DELETE("/ssh-keys/:id", userSSHKeyController.DeleteKey)
+
+				// 多因素认证
+				accountSecurity.GET("/mfa", mfaController.GetStatus)
+				accountSecurity.POST("/mfa/setup", mfaController.Setup)
+				accountSecurity.POST("/mfa/enable", mfaController.Enable)
+				accountSecurity.POST("/mfa/disable", mfaController.Disable)
+				accountSecurity.POST("/mfa/recovery-codes", mfaController.RegenerateRecoveryCodes)
+
+				// 个人API令牌
+				accountSecurity.GET("/api-tokens", apiTokenController.GetTokens)
+				accountSecurity.POST("/api-tokens", apiTokenController.CreateToken)
+				accountSecurity.DELETE("/api-tokens/:id", apiTokenController.RevokeToken)
+
+				// 我的登录会话
+				accountSecurity.GET("/login-sessions", loginSessionController.GetMySessions)
+				accountSecurity.DELETE("/login-sessions/:id", loginSessionController.RevokeMySession)
+			}
+
 			// 权限管理路由（所有认证用户可查看权限列表）
 			authenticated.GET("/permissions", roleController.GetPermissions)
 
@@ -112,6 +210,13 @@ func SetupRouter() *gin.Engine {
 				users.DELETE("/:id", userController.DeleteUser)
 				users.POST("/:id/reset-password", userController.ResetPassword)
 				users.POST("/:id/toggle-status", userController.ToggleUserStatus)
+				users.POST("/:id/reset-mfa", mfaController.ResetUserMFA)
+				users.POST("/:id/unlock", loginLockController.UnlockUser)
+				users.GET("/:id/api-tokens", apiTokenController.GetUserTokens)
+				users.DELETE("/:id/api-tokens/:token_id", apiTokenController.RevokeUserToken)
+				users.GET("/:id/login-sessions", loginSessionController.GetUserSessions)
+				users.DELETE("/:id/login-sessions", loginSessionController.RevokeAllUserSessions)
+				users.DELETE("/:id/login-sessions/:session_id", loginSessionController.RevokeUserSession)
 			}
 
 			// 角色管理路由（需要管理员权限）
@@ -125,11 +230,87 @@ func SetupRouter() *gin.Engine {
 				roles.DELETE("/:id", roleController.DeleteRole)
 			}
 
+			// 用户组管理路由（需要管理员权限）
+			userGroups := authenticated.Group("/user-groups")
+			userGroups.Use(middleware.RequireAdmin())
+			{
+				userGroups.POST("/", assetPermissionController.CreateUserGroup)
+				userGroups.GET("/", assetPermissionController.GetUserGroups)
+				userGroups.GET("/:id", assetPermissionController.GetUserGroup)
+				userGroups.PUT("/:id", assetPermissionController.UpdateUserGroup)
+				userGroups.DELETE("/:id", assetPermissionController.DeleteUserGroup)
+			}
+
+			// 资产授权规则管理路由（需要管理员权限）
+			assetPermissions := authenticated.Group("/asset-permissions")
+			assetPermissions.Use(middleware.RequireAdmin())
+			{
+				assetPermissions.POST("/", assetPermissionController.CreateAssetPermission)
+				assetPermissions.GET("/", assetPermissionController.GetAssetPermissions)
+				assetPermissions.GET("/:id", assetPermissionController.GetAssetPermission)
+				assetPermissions.PUT("/:id", assetPermissionController.UpdateAssetPermission)
+				assetPermissions.DELETE("/:id", assetPermissionController.DeleteAssetPermission)
+			}
+
+			// 访问策略管理路由（仅管理员）
+			accessPolicies := authenticated.Group("/access-policies")
+			accessPolicies.Use(middleware.RequireAdmin())
+			{
+				accessPolicies.POST("/", accessPolicyController.CreatePolicy)
+				accessPolicies.GET("/", accessPolicyController.GetPolicies)
+				accessPolicies.GET("/:id", accessPolicyController.GetPolicy)
+				accessPolicies.PUT("/:id", accessPolicyController.UpdatePolicy)
+				accessPolicies.DELETE("/:id", accessPolicyController.DeletePolicy)
+			}
+
 			// 管理员专用资产管理路由
 			admin := authenticated.Group("/admin")
 			admin.Use(middleware.RequireAdmin())
 			{
 				admin.POST("/assets/batch-move", assetController.BatchMoveAssets)
+				admin.POST("/ldap/sync", ldapController.SyncUsers)
+				admin.GET("/login-locks", loginLockController.GetLocks)
+				admin.POST("/login-locks/unlock", loginLockController.Unlock)
+				admin.GET("/password-policy", passwordPolicyController.GetPolicy)
+				admin.PUT("/password-policy", passwordPolicyController.UpdatePolicy)
+			}
+
+			// SSH证书颁发机构管理路由（仅管理员）
+			sshCA := authenticated.Group("/ssh-ca")
+			sshCA.Use(middleware.RequireAdmin())
+			{
+				sshCA.GET("/", sshCAController.GetAuthorities)
+				sshCA.POST("/rotate", sshCAController.Rotate)
+				sshCA.DELETE("/:id", sshCAController.DeleteAuthority)
+			}
+
+			// 临时访问申请路由，审批接口在服务层校验审批角色
+			accessRequests := authenticated.Group("/access-requests")
+			accessRequests.Use(middleware.RequirePermission("asset:connect"))
+			{
+				accessRequests.POST("/", accessRequestController.CreateRequest)
+				accessRequests.GET("/", accessRequestController.GetMyRequests)
+				accessRequests.GET("/approvals", accessRequestController.GetApprovalRequests)
+				accessRequests.GET("/:id", accessRequestController.GetRequest)
+				accessRequests.POST("/:id/approve", accessRequestController.ApproveRequest)
+				accessRequests.POST("/:id/reject", accessRequestController.RejectRequest)
+				accessRequests.POST("/:id/cancel", accessRequestController.CancelRequest)
+			}
+
+			// 凭证借出路由，服务层校验凭证授权、审批角色和借出人；
+			// 查看凭证明文只允许交互式登录的用户，API令牌不能取回密码和私钥
+			credentialCheckouts := authenticated.Group("/credential-checkouts")
+			credentialCheckouts.Use(middleware.RequirePermission("asset:connect"))
+			{
+				credentialCheckouts.POST("/", credentialCheckoutController.CreateCheckout)
+				credentialCheckouts.GET("/", credentialCheckoutController.GetMyCheckouts)
+				credentialCheckouts.GET("/approvals", credentialCheckoutController.GetApprovalCheckouts)
+				credentialCheckouts.GET("/:id", credentialCheckoutController.GetCheckout)
+				credentialCheckouts.POST("/:id/approve", credentialCheckoutController.ApproveCheckout)
+				credentialCheckouts.POST("/:id/reject", credentialCheckoutController.RejectCheckout)
+				credentialCheckouts.POST("/:id/cancel", credentialCheckoutController.CancelCheckout)
+				credentialCheckouts.POST("/:id/checkin", credentialCheckoutController.CheckIn)
+				credentialCheckouts.POST("/:id/reveal", middleware.RejectAPIToken(), credentialCheckoutController.RevealSecret)
 			}
 
 			// 资产管理路由（需要asset权限）
@@ -142,6 +323,12 @@ func SetupRouter() *gin.Engine {
 				assets.PUT("/:id", middleware.RequirePermission("asset:update"), assetController.UpdateAsset)
 				assets.DELETE("/:id", middleware.RequirePermission("asset:delete"), assetController.DeleteAsset)
 				assets.POST("/test-connection", middleware.RequirePermission("asset:connect"), assetController.TestConnection)
+
+				// 主机密钥管理，变更仅限管理员
+				assets.GET("/:id/host-keys", hostKeyController.GetHostKeys)
+				assets.POST("/:id/host-keys", middleware.RequireAdmin(), hostKeyController.SeedHostKeys)
+				assets.DELETE("/:id/host-keys", middleware.RequireAdmin(), hostKeyController.ResetHostKeys)
+				assets.POST("/:id/host-keys/:key_id/accept", middleware.RequireAdmin(), hostKeyController.AcceptHostKey)
 			}
 
 			// 资产分组管理路由（需要asset权限）
@@ -156,6 +343,17 @@ func SetupRouter() *gin.Engine {
 				assetGroups.DELETE("/:id", middleware.RequirePermission("asset:delete"), assetController.DeleteAssetGroup)
 			}
 
+			// 网络区域管理路由（需要asset权限），区域网关用于连接区域内的资产
+			networkZones := authenticated.Group("/network-zones")
+			networkZones.Use(middleware.RequirePermission("asset:read"))
+			{
+				networkZones.POST("/", middleware.RequirePermission("asset:create"), networkZoneController.CreateZone)
+				networkZones.GET("/", networkZoneController.GetZones)
+				networkZones.GET("/:id", networkZoneController.GetZone)
+				networkZones.PUT("/:id", middleware.RequirePermission("asset:update"), networkZoneController.UpdateZone)
+				networkZones.DELETE("/:id", middleware.RequirePermission("asset:delete"), networkZoneController.DeleteZone)
+			}
+
 			// 凭证管理路由（需要asset权限）
 			credentials := authenticated.Group("/credentials")
 			credentials.Use(middleware.RequirePermission("asset:read"))
@@ -165,6 +363,11 @@ func SetupRouter() *gin.Engine {
 				credentials.GET("/:id", assetController.GetCredential)
 				credentials.PUT("/:id", middleware.RequirePermission("asset:update"), assetController.UpdateCredential)
 				credentials.DELETE("/:id", middleware.RequirePermission("asset:delete"), assetController.DeleteCredential)
+				credentials.GET("/:id/rotation-policy", credentialRotationController.GetPolicy)
+				credentials.PUT("/:id/rotation-policy", middleware.RequirePermission("asset:update"), credentialRotationController.SetPolicy)
+				credentials.DELETE("/:id/rotation-policy", middleware.RequirePermission("asset:update"), credentialRotationController.DeletePolicy)
+				credentials.POST("/:id/rotate", middleware.RequirePermission("asset:update"), credentialRotationController.RotateNow)
+				credentials.GET("/:id/rotation-history", credentialRotationController.GetHistory)
 			}
 
 			// SSH会话管理路由（需要连接权限）
@@ -180,6 +383,16 @@ func SetupRouter() *gin.Engine {
 				ssh.POST("/sessions/health-check", middleware.RequirePermission("admin"), sshController.HealthCheckSessions)
 				ssh.POST("/sessions/force-cleanup", middleware.RequirePermission("admin"), sshController.ForceCleanupSessions)
 				ssh.POST("/keypair", sshController.GenerateKeyPair)
+
+				// 会话文件传输
+				ssh.GET("/sessions/:id/files", fileTransferController.ListFiles)
+				ssh.POST("/sessions/:id/files/upload", fileTransferController.UploadFile)
+				ssh.GET("/sessions/:id/files/download", fileTransferController.DownloadFile)
+
+				// 端口转发隧道（绑定会话的本地回环隧道）
+				ssh.POST("/sessions/:id/tunnels", portForwardController.OpenTunnel)
+				ssh.GET("/sessions/:id/tunnels", portForwardController.GetTunnels)
+				ssh.DELETE("/sessions/:id/tunnels/:tunnel_id", portForwardController.CloseTunnel)
 				
 				// 🆕 会话超时管理路由
 				ssh.POST("/sessions/:id/timeout", sshController.CreateSessionTimeout)     // 创建超时配置
@@ -193,6 +406,13 @@ func SetupRouter() *gin.Engine {
 				ssh.GET("/timeout/stats", middleware.RequirePermission("admin"), sshController.GetTimeoutStats) // 获取超时服务统计
 			}
 
+			// 数据库代理路由（需要连接权限）
+			database := authenticated.Group("/database")
+			database.Use(middleware.RequirePermission("asset:connect"))
+			{
+				database.POST("/tokens", databaseProxyController.IssueToken)
+			}
+
 			// 审计管理路由（需要审计权限）
 			audit := authenticated.Group("/audit")
 			audit.Use(middleware.RequirePermission("audit:read"))
@@ -217,6 +437,12 @@ func SetupRouter() *gin.Engine {
 				audit.GET("/command-logs/:id", auditController.GetCommandLog)
 				audit.POST("/command-logs/batch-delete", middleware.RequirePermission("audit:delete"), auditController.BatchDeleteCommandLogs)
 
+				// 文件传输日志
+				audit.GET("/file-transfers", fileTransferController.GetTransferLogs)
+
+				// SQL审计日志
+				audit.GET("/sql-logs", databaseProxyController.GetSQLAuditLogs)
+
 				// 统计数据
 				audit.GET("/statistics", auditController.GetAuditStatistics)
 
@@ -349,6 +575,17 @@ func SetupRouter() *gin.Engine {
 				// 命令匹配测试
 				commandFilter.POST("/match", commandFilterController.TestCommandMatch)
 			}
+
+			// SQL过滤规则管理（需要管理员权限）
+			sqlFilters := authenticated.Group("/sql-filters")
+			sqlFilters.Use(middleware.RequireAdmin())
+			{
+				sqlFilters.POST("", databaseProxyController.CreateFilterRule)
+				sqlFilters.GET("", databaseProxyController.GetFilterRules)
+				sqlFilters.GET("/:id", databaseProxyController.GetFilterRule)
+				sqlFilters.PUT("/:id", databaseProxyController.UpdateFilterRule)
+				sqlFilters.DELETE("/:id", databaseProxyController.DeleteFilterRule)
+			}
 		}
 
 		// WebSocket路由（使用特殊的WebSocket认证中间件）
@@ -0,0 +1,317 @@
+package routers
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 测试使用的凭证明文，任何接口响应中都不能出现
+const (
+	testCredentialPassword        = "Bastion-Secret-9f3kQ7zX"
+	testCredentialUpdatedPassword = "Rotated-Secret-2mPw8vRt"
+)
+
+// persistentModels 测试数据库中需要建表的模型
+var persistentModels = []interface{}{
+	&models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+	&models.UserGroup{}, &models.Asset{}, &models.AssetGroup{}, &models.Credential{}, &models.AssetCredential{},
+	&models.AssetPermission{}, &models.AssetHostKey{}, &models.NetworkZone{},
+	&models.LoginLog{}, &models.OperationLog{}, &models.SessionRecord{}, &models.CommandLog{},
+	&models.SessionMonitorLog{}, &models.SessionWarning{}, &models.WebSocketConnection{},
+	&models.SessionRecording{}, &models.RecordingConfig{}, &models.SessionTimeout{},
+	&models.CommandGroup{}, &models.CommandGroupItem{}, &models.CommandFilter{}, &models.FilterAttribute{},
+	&models.CommandFilterLog{}, &models.UserMFA{}, &models.UserMFARecoveryCode{}, &models.UserSSHKey{},
+	&models.APIToken{}, &models.PasswordPolicy{}, &models.PasswordHistory{},
+	&models.AccessRequest{}, &models.AccessRequestApproval{}, &models.AccessPolicy{},
+	&models.SSHCertificateAuthority{}, &models.CredentialRotationPolicy{}, &models.CredentialRotationHistory{},
+	&models.CredentialCheckout{}, &models.FileTransferLog{}, &models.SQLFilterRule{}, &models.SQLAuditLog{},
+}
+
+// TestResponsesNeverExposeCredentialSecrets 创建凭证后遍历所有GET路由，响应中不能出现凭证明文、私钥、密文或旧的密码掩码
+func TestResponsesNeverExposeCredentialSecrets(t *testing.T) {
+	router, token := setupRouterTest(t)
+
+	privateKeyPEM := generatePrivateKeyPEM(t)
+
+	assetID := createResource(t, router, token, "/api/v1/assets/", map[string]interface{}{
+		"name": "web-01", "type": "server", "address": "10.0.0.1", "port": 22, "protocol": "ssh",
+	})
+	passwordCredentialID := createResource(t, router, token, "/api/v1/credentials/", map[string]interface{}{
+		"name": "root-password", "type": "password", "username": "root",
+		"password": testCredentialPassword, "asset_ids": []uint{assetID},
+	})
+	createResource(t, router, token, "/api/v1/credentials/", map[string]interface{}{
+		"name": "deploy-key", "type": "key", "username": "deploy",
+		"private_key": privateKeyPEM, "asset_ids": []uint{assetID},
+	})
+
+	body := doRequest(t, router, token, http.MethodPut, fmt.Sprintf("/api/v1/credentials/%d", passwordCredentialID), map[string]interface{}{
+		"password": testCredentialUpdatedPassword,
+	})
+	require.Equal(t, http.StatusOK, body.Code, body.Body.String())
+
+	// 审计日志异步写入，等待写入后再遍历审计接口
+	require.Eventually(t, func() bool {
+		var count int64
+		utils.GetDB().Model(&models.OperationLog{}).Count(&count)
+		return count >= 4
+	}, 5*time.Second, 50*time.Millisecond)
+
+	forbidden := secretMaterial(t, privateKeyPEM)
+
+	checked := 0
+	for _, route := range router.Routes() {
+		if route.Method != http.MethodGet || strings.HasPrefix(route.Path, "/api/v1/ws/") || strings.Contains(route.Path, "/oidc/") {
+			continue
+		}
+		path := fillRouteParams(route.Path, assetID, passwordCredentialID)
+		recorder := doRequest(t, router, token, http.MethodGet, path, nil)
+		assertNoSecrets(t, route.Method+" "+path, recorder.Body.String(), forbidden)
+		checked++
+	}
+	require.Greater(t, checked, 50, "expected to walk the GET routes")
+}
+
+// TestCredentialPasswordHintRevealsNoCharacters 密码凭证的提示只给出长度区间
+func TestCredentialPasswordHintRevealsNoCharacters(t *testing.T) {
+	router, token := setupRouterTest(t)
+
+	assetID := createResource(t, router, token, "/api/v1/assets/", map[string]interface{}{
+		"name": "db-01", "type": "server", "address": "10.0.0.2", "port": 22, "protocol": "ssh",
+	})
+	credentialID := createResource(t, router, token, "/api/v1/credentials/", map[string]interface{}{
+		"name": "root-password", "type": "password", "username": "root",
+		"password": testCredentialPassword, "asset_ids": []uint{assetID},
+	})
+
+	recorder := doRequest(t, router, token, http.MethodGet, fmt.Sprintf("/api/v1/credentials/%d", credentialID), nil)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var response struct {
+		Data models.CredentialResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, "****(16+)", response.Data.SecretHint)
+}
+
+// setupRouterTest 使用内存SQLite与miniredis搭建完整路由，返回路由与管理员访问令牌
+func setupRouterTest(t *testing.T) (*gin.Engine, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	config.GlobalConfig = &config.Config{}
+	config.GlobalConfig.JWT = config.JWTConfig{Secret: "router-test-secret", Expire: 3600, Issuer: "bastion-test"}
+	config.GlobalConfig.SecretStore.KeyFile = filepath.Join(dir, "master.key")
+	config.GlobalConfig.Audit.EnableOperationLog = true
+	require.NoError(t, utils.InitSecretStore())
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "bastion.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(persistentModels...))
+	utils.DB = db
+
+	redisServer := miniredis.RunT(t)
+	utils.Redis = redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { utils.Redis.Close() })
+
+	permission := models.Permission{Name: "all", Category: "system"}
+	require.NoError(t, db.Create(&permission).Error)
+	role := models.Role{Name: "admin", Permissions: []models.Permission{permission}}
+	require.NoError(t, db.Create(&role).Error)
+	hashed, err := utils.HashPassword("Admin-Password-1")
+	require.NoError(t, err)
+	admin := models.User{Username: "admin", Password: hashed, Status: 1, AuthSource: models.AuthSourceLocal, Roles: []models.Role{role}}
+	require.NoError(t, db.Create(&admin).Error)
+
+	tokenResponse, err := utils.GenerateToken(&admin)
+	require.NoError(t, err)
+
+	return SetupRouter(), tokenResponse.AccessToken
+}
+
+// createResource 调用创建接口并返回新资源的ID
+func createResource(t *testing.T, router *gin.Engine, token, path string, payload map[string]interface{}) uint {
+	t.Helper()
+	recorder := doRequest(t, router, token, http.MethodPost, path, payload)
+	require.Contains(t, []int{http.StatusOK, http.StatusCreated}, recorder.Code, recorder.Body.String())
+
+	var response struct {
+		Data struct {
+			ID uint `json:"id"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.NotZero(t, response.Data.ID, recorder.Body.String())
+	return response.Data.ID
+}
+
+// doRequest 以管理员身份调用接口
+func doRequest(t *testing.T, router *gin.Engine, token, method, path string, payload interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	if payload != nil {
+		require.NoError(t, json.NewEncoder(&body).Encode(payload))
+	}
+	request := httptest.NewRequest(method, path, &body)
+	request.Header.Set("Authorization", "Bearer "+token)
+	request.Header.Set("Content-Type", "application/json")
+	request.RemoteAddr = "192.0.2.10:50000"
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// routeParamPattern 路由中的路径参数
+var routeParamPattern = regexp.MustCompile(`[:*][A-Za-z_]+`)
+
+// fillRouteParams 用测试数据的ID填充路径参数，资产路由使用资产ID，其余使用凭证ID
+func fillRouteParams(path string, assetID, credentialID uint) string {
+	id := credentialID
+	if strings.HasPrefix(path, "/api/v1/assets/") {
+		id = assetID
+	}
+	return routeParamPattern.ReplaceAllString(path, fmt.Sprint(id))
+}
+
+// secretMaterial 响应中不能出现的内容：明文、私钥、数据库中的密文以及旧格式的掩码（密码末尾字符）
+func secretMaterial(t *testing.T, privateKeyPEM string) []string {
+	t.Helper()
+	forbidden := []string{
+		testCredentialPassword,
+		testCredentialUpdatedPassword,
+		"PRIVATE KEY",
+		"****" + testCredentialPassword[len(testCredentialPassword)-2:],
+		"****" + testCredentialUpdatedPassword[len(testCredentialUpdatedPassword)-2:],
+	}
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	require.NotNil(t, block)
+	for _, line := range strings.Split(string(pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes})), "\n") {
+		if len(line) >= 32 && !strings.HasPrefix(line, "-----") {
+			forbidden = append(forbidden, line)
+		}
+	}
+
+	var credentials []models.Credential
+	require.NoError(t, utils.GetDB().Find(&credentials).Error)
+	for _, credential := range credentials {
+		for _, encrypted := range []string{credential.Password, credential.PrivateKey} {
+			if encrypted != "" {
+				forbidden = append(forbidden, encrypted)
+			}
+		}
+	}
+	return forbidden
+}
+
+// assertNoSecrets 响应体（包括JSON转义后的内容）中不能出现任何敏感内容
+func assertNoSecrets(t *testing.T, route, body string, forbidden []string) {
+	t.Helper()
+	unescaped := body
+	var decoded interface{}
+	if json.Unmarshal([]byte(body), &decoded) == nil {
+		if raw, err := json.Marshal(decoded); err == nil {
+			unescaped = strings.NewReplacer(`<`, "<", `>`, ">", `&`, "&", `\n`, "\n").Replace(string(raw))
+		}
+	}
+	for _, secret := range forbidden {
+		if strings.Contains(body, secret) || strings.Contains(unescaped, secret) {
+			t.Errorf("%s exposes secret material %q", route, secret)
+		}
+	}
+}
+
+// generatePrivateKeyPEM 生成测试用的OpenSSH私钥
+func generatePrivateKeyPEM(t *testing.T) string {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(block))
+}
+
+// TestAPITokenCannotCheckOutOrRevealCredentials 只有审计权限的API令牌不能借出凭证，任何API令牌都不能查看凭证明文
+func TestAPITokenCannotCheckOutOrRevealCredentials(t *testing.T) {
+	router, token := setupRouterTest(t)
+	for _, name := range []string{"audit:read", "asset:connect"} {
+		require.NoError(t, utils.GetDB().Create(&models.Permission{Name: name, Category: "test"}).Error)
+	}
+
+	createAPIToken := func(permission string) string {
+		recorder := doRequest(t, router, token, http.MethodPost, "/api/v1/profile/api-tokens", map[string]interface{}{
+			"name": permission, "permissions": []string{permission},
+		})
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		var response struct {
+			Data struct {
+				Token string `json:"token"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		require.NotEmpty(t, response.Data.Token)
+		return response.Data.Token
+	}
+	auditToken := createAPIToken("audit:read")
+	connectToken := createAPIToken("asset:connect")
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodPost, "/api/v1/credential-checkouts/"},
+		{http.MethodGet, "/api/v1/credential-checkouts/"},
+		{http.MethodPost, "/api/v1/credential-checkouts/1/reveal"},
+		{http.MethodPost, "/api/v1/access-requests/"},
+		{http.MethodGet, "/api/v1/access-requests/approvals"},
+	} {
+		recorder := doRequest(t, router, auditToken, route.method, route.path, map[string]interface{}{})
+		require.Equal(t, http.StatusForbidden, recorder.Code, "%s %s: %s", route.method, route.path, recorder.Body.String())
+	}
+
+	// 权限范围内的令牌可以查询借出记录，但不能查看凭证明文
+	recorder := doRequest(t, router, connectToken, http.MethodGet, "/api/v1/credential-checkouts/", nil)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	recorder = doRequest(t, router, connectToken, http.MethodPost, "/api/v1/credential-checkouts/1/reveal", nil)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+	require.Contains(t, recorder.Body.String(), "API tokens cannot access this endpoint")
+}
+
+// TestDuplicateNamesReturnConflict 用户组和资产授权规则重名时返回409
+func TestDuplicateNamesReturnConflict(t *testing.T) {
+	router, token := setupRouterTest(t)
+	assetID := createResource(t, router, token, "/api/v1/assets/", map[string]interface{}{
+		"name": "web-01", "type": "server", "address": "10.0.0.1", "port": 22, "protocol": "ssh",
+	})
+
+	for path, payload := range map[string]map[string]interface{}{
+		"/api/v1/user-groups/":       {"name": "ops"},
+		"/api/v1/asset-permissions/": {"name": "ops", "user_ids": []uint{1}, "asset_ids": []uint{assetID}},
+	} {
+		createResource(t, router, token, path, payload)
+		recorder := doRequest(t, router, token, http.MethodPost, path, payload)
+		require.Equal(t, http.StatusConflict, recorder.Code, "%s: %s", path, recorder.Body.String())
+		require.Contains(t, recorder.Body.String(), "already exists")
+	}
+}
@@ -0,0 +1,565 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// AccessPolicyViolation 登录或建立会话被访问策略拒绝
+type AccessPolicyViolation struct {
+	Scope    string // login 或 session
+	PolicyID uint   // 命中的拒绝策略，未命中任何允许策略时为0
+	Reason   string
+}
+
+func (e *AccessPolicyViolation) Error() string {
+	return e.Reason
+}
+
+// Unwrap 访问策略拒绝属于权限不足
+func (e *AccessPolicyViolation) Unwrap() error {
+	return utils.ErrPermissionDenied
+}
+
+// LoginFailureStatus 登录失败时登录日志记录的状态
+func LoginFailureStatus(err error) string {
+	var blocked *LoginBlockedError
+	if errors.As(err, &blocked) {
+		return blocked.Status
+	}
+	var violation *AccessPolicyViolation
+	if errors.As(err, &violation) {
+		return models.LoginStatusPolicyDenied
+	}
+	return "failed"
+}
+
+// AccessPolicyService 登录与会话访问策略服务
+type AccessPolicyService struct {
+	db                *gorm.DB
+	auditService      *AuditService
+	permissionService *AssetPermissionService
+}
+
+var (
+	relationPolicyUsers  = permissionRelation{"access_policy_users", "user_id", &models.User{}}
+	relationPolicyRoles  = permissionRelation{"access_policy_roles", "role_id", &models.Role{}}
+	relationPolicyAssets = permissionRelation{"access_policy_assets", "asset_id", &models.Asset{}}
+)
+
+// NewAccessPolicyService 创建访问策略服务实例
+func NewAccessPolicyService(db *gorm.DB) *AccessPolicyService {
+	return &AccessPolicyService{
+		db:                db,
+		auditService:      NewAuditService(db),
+		permissionService: NewAssetPermissionService(db),
+	}
+}
+
+// ======================== 策略管理 ========================
+
+// CreatePolicy 创建访问策略
+func (s *AccessPolicyService) CreatePolicy(req *models.AccessPolicyCreateRequest) (*models.AccessPolicyResponse, error) {
+	policy := &models.AccessPolicy{
+		Name:        req.Name,
+		Description: req.Description,
+		Enabled:     true,
+		Action:      req.Action,
+		Scope:       req.Scope,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		TimeZone:    req.TimeZone,
+	}
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	}
+	if err := s.applyConditions(policy, req.Weekdays, req.CIDRs); err != nil {
+		return nil, err
+	}
+	if err := s.checkNameAvailable(req.Name, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(policy).Error; err != nil {
+			return fmt.Errorf("failed to create access policy: %w", err)
+		}
+		// 显式更新布尔字段，避免gorm忽略false零值后使用默认值
+		if !policy.Enabled {
+			if err := tx.Model(policy).Update("enabled", false).Error; err != nil {
+				return fmt.Errorf("failed to update access policy: %w", err)
+			}
+		}
+		return s.replaceRelations(tx, policy.ID, req.UserIDs, req.RoleIDs, req.AssetIDs)
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.GetPolicy(policy.ID)
+}
+
+// GetPolicies 获取访问策略列表
+func (s *AccessPolicyService) GetPolicies(req *models.AccessPolicyListRequest) ([]*models.AccessPolicyResponse, int64, error) {
+	var policies []models.AccessPolicy
+	var total int64
+
+	query := s.db.Model(&models.AccessPolicy{})
+	if req.Keyword != "" {
+		query = query.Where("name LIKE ?", "%"+req.Keyword+"%")
+	}
+	if req.Scope != "" {
+		query = query.Where("scope = ?", req.Scope)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count access policies: %w", err)
+	}
+
+	if req.Page > 0 && req.PageSize > 0 {
+		query = query.Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize)
+	}
+
+	if err := s.preloadRelations(query).Order("id DESC").Find(&policies).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query access policies: %w", err)
+	}
+
+	responses := make([]*models.AccessPolicyResponse, len(policies))
+	for i := range policies {
+		responses[i] = policies[i].ToResponse()
+	}
+	return responses, total, nil
+}
+
+// GetPolicy 获取访问策略详情
+func (s *AccessPolicyService) GetPolicy(id uint) (*models.AccessPolicyResponse, error) {
+	var policy models.AccessPolicy
+	if err := s.preloadRelations(s.db).First(&policy, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query access policy: %w", err)
+	}
+	return policy.ToResponse(), nil
+}
+
+// UpdatePolicy 更新访问策略
+func (s *AccessPolicyService) UpdatePolicy(id uint, req *models.AccessPolicyUpdateRequest) (*models.AccessPolicyResponse, error) {
+	var policy models.AccessPolicy
+	if err := s.db.First(&policy, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query access policy: %w", err)
+	}
+
+	if req.Name != "" && req.Name != policy.Name {
+		if err := s.checkNameAvailable(req.Name, id); err != nil {
+			return nil, err
+		}
+		policy.Name = req.Name
+	}
+	if req.Description != nil {
+		policy.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	}
+	if req.Action != "" {
+		policy.Action = req.Action
+	}
+	if req.Scope != "" {
+		policy.Scope = req.Scope
+	}
+	if req.StartTime != nil {
+		policy.StartTime = *req.StartTime
+	}
+	if req.EndTime != nil {
+		policy.EndTime = *req.EndTime
+	}
+	if req.TimeZone != nil {
+		policy.TimeZone = *req.TimeZone
+	}
+
+	weekdays := splitPolicyList(policy.Weekdays)
+	days := make([]int, 0, len(weekdays))
+	for _, day := range weekdays {
+		d, _ := strconv.Atoi(day)
+		days = append(days, d)
+	}
+	if req.Weekdays != nil {
+		days = *req.Weekdays
+	}
+	cidrs := splitPolicyList(policy.CIDRs)
+	if req.CIDRs != nil {
+		cidrs = *req.CIDRs
+	}
+	if err := s.applyConditions(&policy, days, cidrs); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&policy).Select("*").Omit("created_at", "deleted_at").Updates(&policy).Error; err != nil {
+			return fmt.Errorf("failed to update access policy: %w", err)
+		}
+		if req.UserIDs != nil {
+			if err := s.permissionService.replaceRelation(tx, relationPolicyUsers, "policy_id", id, *req.UserIDs); err != nil {
+				return err
+			}
+		}
+		if req.RoleIDs != nil {
+			if err := s.permissionService.replaceRelation(tx, relationPolicyRoles, "policy_id", id, *req.RoleIDs); err != nil {
+				return err
+			}
+		}
+		if req.AssetIDs != nil {
+			if err := s.permissionService.replaceRelation(tx, relationPolicyAssets, "policy_id", id, *req.AssetIDs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.GetPolicy(id)
+}
+
+// DeletePolicy 删除访问策略
+func (s *AccessPolicyService) DeletePolicy(id uint) error {
+	var policy models.AccessPolicy
+	if err := s.db.First(&policy, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrNotFound
+		}
+		return fmt.Errorf("failed to query access policy: %w", err)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []string{"access_policy_users", "access_policy_roles", "access_policy_assets"} {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE policy_id = ?", id).Error; err != nil {
+				return fmt.Errorf("failed to delete %s: %w", table, err)
+			}
+		}
+		if err := tx.Delete(&policy).Error; err != nil {
+			return fmt.Errorf("failed to delete access policy: %w", err)
+		}
+		return nil
+	})
+}
+
+// ======================== 策略判定 ========================
+
+// CheckLogin 校验用户能否在当前时间从 clientIP 登录，被拒绝时记录安全事件
+func (s *AccessPolicyService) CheckLogin(user *models.User, clientIP string) error {
+	var policies []models.AccessPolicy
+	err := s.enabledPolicies(models.AccessPolicyScopeLogin).
+		Where("("+policySubjectCondition+") OR ("+policyNoSubjectCondition+" AND "+policyNoAssetCondition+")", user.ID, user.ID).
+		Find(&policies).Error
+	if err != nil {
+		return fmt.Errorf("failed to query access policies: %w", err)
+	}
+
+	violation := evaluateAccessPolicies(policies, models.AccessPolicyScopeLogin, clientIP, time.Now())
+	if violation != nil {
+		s.recordViolation(user, clientIP, 0, violation)
+		return violation
+	}
+	return nil
+}
+
+// CheckSession 校验用户能否在当前时间从 clientIP 建立到资产的会话，被拒绝时记录安全事件
+func (s *AccessPolicyService) CheckSession(user *models.User, assetID uint, clientIP string) error {
+	var policies []models.AccessPolicy
+	err := s.enabledPolicies(models.AccessPolicyScopeSession).
+		Where("("+policySubjectCondition+") OR "+policyNoSubjectCondition, user.ID, user.ID).
+		Where("id IN (SELECT policy_id FROM access_policy_assets WHERE asset_id = ?) OR "+policyNoAssetCondition, assetID).
+		Find(&policies).Error
+	if err != nil {
+		return fmt.Errorf("failed to query access policies: %w", err)
+	}
+
+	violation := evaluateAccessPolicies(policies, models.AccessPolicyScopeSession, clientIP, time.Now())
+	if violation != nil {
+		s.recordViolation(user, clientIP, assetID, violation)
+		return violation
+	}
+	return nil
+}
+
+// 策略关联条件
+const (
+	policySubjectCondition = "id IN (SELECT policy_id FROM access_policy_users WHERE user_id = ?) " +
+		"OR id IN (SELECT pr.policy_id FROM access_policy_roles pr JOIN user_roles ur ON ur.role_id = pr.role_id WHERE ur.user_id = ?)"
+	policyNoSubjectCondition = "(id NOT IN (SELECT policy_id FROM access_policy_users) AND id NOT IN (SELECT policy_id FROM access_policy_roles))"
+	policyNoAssetCondition   = "id NOT IN (SELECT policy_id FROM access_policy_assets)"
+)
+
+// enabledPolicies 查询作用于指定范围的启用策略
+func (s *AccessPolicyService) enabledPolicies(scope string) *gorm.DB {
+	return s.db.Model(&models.AccessPolicy{}).
+		Where("enabled = ? AND scope IN ?", true, []string{scope, models.AccessPolicyScopeAll})
+}
+
+// evaluateAccessPolicies 命中任意拒绝策略即拒绝；存在允许策略时必须命中其中之一
+func evaluateAccessPolicies(policies []models.AccessPolicy, scope, clientIP string, now time.Time) *AccessPolicyViolation {
+	action := "login"
+	if scope == models.AccessPolicyScopeSession {
+		action = "session"
+	}
+
+	ip := net.ParseIP(clientIP)
+	hasAllow, allowed := false, false
+	for i := range policies {
+		policy := &policies[i]
+		matched := accessPolicyMatches(policy, ip, now)
+		switch policy.Action {
+		case models.AccessPolicyActionDeny:
+			if matched {
+				return &AccessPolicyViolation{
+					Scope:    scope,
+					PolicyID: policy.ID,
+					Reason:   fmt.Sprintf("%s denied by access policy %q", action, policy.Name),
+				}
+			}
+		case models.AccessPolicyActionAllow:
+			hasAllow = true
+			allowed = allowed || matched
+		}
+	}
+
+	if hasAllow && !allowed {
+		return &AccessPolicyViolation{
+			Scope:  scope,
+			Reason: fmt.Sprintf("%s is not allowed at this time or from address %s", action, clientIP),
+		}
+	}
+	return nil
+}
+
+// accessPolicyMatches 判断当前时间和客户端地址是否满足策略条件
+// 星期按策略时区下的当天判断，跨天的时间段在次日的部分同样按次日的星期判断
+func accessPolicyMatches(policy *models.AccessPolicy, ip net.IP, now time.Time) bool {
+	location := time.Local
+	if policy.TimeZone != "" {
+		if loc, err := time.LoadLocation(policy.TimeZone); err == nil {
+			location = loc
+		}
+	}
+	local := now.In(location)
+
+	if policy.Weekdays != "" {
+		today := strconv.Itoa(int(local.Weekday()))
+		matched := false
+		for _, day := range splitPolicyList(policy.Weekdays) {
+			if day == today {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if policy.StartTime != "" && policy.EndTime != "" {
+		minute := local.Hour()*60 + local.Minute()
+		start, _ := parseClockMinutes(policy.StartTime)
+		end, _ := parseClockMinutes(policy.EndTime)
+		if start < end {
+			if minute < start || minute >= end {
+				return false
+			}
+		} else if minute < start && minute >= end {
+			return false
+		}
+	}
+
+	if policy.CIDRs != "" {
+		if ip == nil {
+			return false
+		}
+		matched := false
+		for _, cidr := range splitPolicyList(policy.CIDRs) {
+			if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// recordViolation 将策略拒绝作为安全事件写入操作日志并推送告警
+func (s *AccessPolicyService) recordViolation(user *models.User, clientIP string, assetID uint, violation *AccessPolicyViolation) {
+	url := "/api/v1/access-policies"
+	if violation.PolicyID != 0 {
+		url = fmt.Sprintf("/api/v1/access-policies/%d", violation.PolicyID)
+	}
+	details := map[string]interface{}{
+		"scope":     violation.Scope,
+		"policy_id": violation.PolicyID,
+		"user_id":   user.ID,
+		"username":  user.Username,
+		"asset_id":  assetID,
+		"client_ip": clientIP,
+	}
+
+	go s.auditService.RecordOperationLog(
+		user.ID,
+		user.Username,
+		clientIP,
+		"SYSTEM",
+		url,
+		violation.Scope+"_denied",
+		"access_policy",
+		violation.PolicyID,
+		"",
+		403,
+		violation.Reason,
+		details,
+		nil,
+		0,
+		false,
+	)
+
+	if sender := notifier(); sender != nil {
+		if err := sender.NotifySecurityEvent(context.Background(), "access_policy_denied", details); err != nil {
+			logrus.WithError(err).Warn("发送访问策略告警失败")
+		}
+	}
+}
+
+// applyConditions 校验并写入策略条件，星期和地址段保存为逗号分隔的规范格式
+func (s *AccessPolicyService) applyConditions(policy *models.AccessPolicy, weekdays []int, cidrs []string) error {
+	if (policy.StartTime == "") != (policy.EndTime == "") {
+		return fmt.Errorf("%w: start_time and end_time must be set together", utils.ErrInvalidParam)
+	}
+	if policy.StartTime != "" {
+		start, err := parseClockMinutes(policy.StartTime)
+		if err != nil {
+			return err
+		}
+		end, err := parseClockMinutes(policy.EndTime)
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return fmt.Errorf("%w: start_time and end_time must differ", utils.ErrInvalidParam)
+		}
+	}
+	if policy.TimeZone != "" {
+		if _, err := time.LoadLocation(policy.TimeZone); err != nil {
+			return fmt.Errorf("%w: unknown time zone %s", utils.ErrInvalidParam, policy.TimeZone)
+		}
+	}
+
+	days := make([]string, 0, len(weekdays))
+	seen := make(map[int]bool, len(weekdays))
+	sort.Ints(weekdays)
+	for _, day := range weekdays {
+		if day < 0 || day > 6 {
+			return fmt.Errorf("%w: weekday must be between 0 (Sunday) and 6", utils.ErrInvalidParam)
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, strconv.Itoa(day))
+		}
+	}
+	policy.Weekdays = strings.Join(days, ",")
+
+	networks := make([]string, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		// 单个地址按主机地址段处理
+		if ip := net.ParseIP(cidr); ip != nil {
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("%w: invalid cidr %s", utils.ErrInvalidParam, cidr)
+		}
+		networks = append(networks, network.String())
+	}
+	policy.CIDRs = strings.Join(networks, ",")
+	return nil
+}
+
+// checkNameAvailable 检查策略名称是否已被其它策略使用
+func (s *AccessPolicyService) checkNameAvailable(name string, excludeID uint) error {
+	var count int64
+	if err := s.db.Model(&models.AccessPolicy{}).Where("name = ? AND id <> ?", name, excludeID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check policy name: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: access policy name already exists", utils.ErrDuplicate)
+	}
+	return nil
+}
+
+// replaceRelations 写入策略关联的用户、角色和资产
+func (s *AccessPolicyService) replaceRelations(tx *gorm.DB, policyID uint, userIDs, roleIDs, assetIDs []uint) error {
+	for _, rel := range []struct {
+		relation permissionRelation
+		ids      []uint
+	}{
+		{relationPolicyUsers, userIDs},
+		{relationPolicyRoles, roleIDs},
+		{relationPolicyAssets, assetIDs},
+	} {
+		if err := s.permissionService.replaceRelation(tx, rel.relation, "policy_id", policyID, rel.ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preloadRelations 预加载策略关联对象
+func (s *AccessPolicyService) preloadRelations(query *gorm.DB) *gorm.DB {
+	return query.Preload("Users").Preload("Roles").Preload("Assets")
+}
+
+// parseClockMinutes 解析 HH:MM 为当天的分钟数
+func parseClockMinutes(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid time %s, expected HH:MM", utils.ErrInvalidParam, value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// splitPolicyList 拆分逗号分隔的策略条件
+func splitPolicyList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	parts := strings.Split(value, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
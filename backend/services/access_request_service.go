@@ -0,0 +1,554 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// AccessRequestService 临时访问申请服务
+// 审批通过后为申请人生成限定时间窗口的资产授权规则，SSHService 建立会话时按授权规则校验；
+// 到期检查任务删除过期的授权规则，并关闭申请人在相关资产上仍在进行的会话。
+type AccessRequestService struct {
+	db                *gorm.DB
+	cfg               config.AccessRequestConfig
+	auditService      *AuditService
+	permissionService *AssetPermissionService
+}
+
+// NewAccessRequestService 创建临时访问申请服务实例
+func NewAccessRequestService(db *gorm.DB) *AccessRequestService {
+	cfg := config.GlobalConfig.AccessRequest
+	if len(cfg.ApproverRoles) == 0 {
+		cfg.ApproverRoles = []string{"admin"}
+	}
+	if cfg.RequiredApprovals <= 0 {
+		cfg.RequiredApprovals = 1
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 60
+	}
+
+	return &AccessRequestService{
+		db:                db,
+		cfg:               cfg,
+		auditService:      NewAuditService(db),
+		permissionService: NewAssetPermissionService(db),
+	}
+}
+
+// CreateRequest 提交临时访问申请，并通知审批人
+func (s *AccessRequestService) CreateRequest(user *models.User, req *models.AccessRequestCreateRequest, clientIP string) (*models.AccessRequestResponse, error) {
+	if (req.AssetID == 0) == (req.AssetGroupID == 0) {
+		return nil, fmt.Errorf("%w: exactly one of asset_id and asset_group_id is required", utils.ErrInvalidParam)
+	}
+
+	request := &models.AccessRequest{
+		UserID:            user.ID,
+		Reason:            req.Reason,
+		EndAt:             req.EndAt,
+		Status:            models.AccessRequestStatusPending,
+		RequiredApprovals: s.cfg.RequiredApprovals,
+	}
+	if req.AssetID != 0 {
+		if err := s.db.Select("id").First(&models.Asset{}, req.AssetID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: asset does not exist", utils.ErrInvalidParam)
+			}
+			return nil, fmt.Errorf("failed to query asset: %w", err)
+		}
+		request.AssetID = &req.AssetID
+	} else {
+		if err := s.db.Select("id").First(&models.AssetGroup{}, req.AssetGroupID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: asset group does not exist", utils.ErrInvalidParam)
+			}
+			return nil, fmt.Errorf("failed to query asset group: %w", err)
+		}
+		request.AssetGroupID = &req.AssetGroupID
+	}
+
+	// 开始时间为空或早于当前时间时，审批通过后立即生效
+	now := time.Now()
+	request.StartAt = now
+	if req.StartAt != nil && req.StartAt.After(now) {
+		request.StartAt = *req.StartAt
+	}
+	if !request.EndAt.After(request.StartAt) {
+		return nil, fmt.Errorf("%w: end_at must be after start_at", utils.ErrInvalidParam)
+	}
+	if s.cfg.MaxDuration > 0 && request.EndAt.Sub(request.StartAt) > time.Duration(s.cfg.MaxDuration)*time.Second {
+		return nil, fmt.Errorf("%w: access window exceeds the maximum of %d seconds", utils.ErrInvalidParam, s.cfg.MaxDuration)
+	}
+
+	if err := s.db.Create(request).Error; err != nil {
+		return nil, fmt.Errorf("failed to create access request: %w", err)
+	}
+
+	s.recordTransition(user, clientIP, "create", request, fmt.Sprintf("提交临时访问申请 #%d", request.ID))
+	if approverIDs, err := s.getApproverIDs(user.ID); err != nil {
+		logrus.WithError(err).Warn("查询临时访问申请审批人失败")
+	} else {
+		s.notify(approverIDs, "access_request_pending", request)
+	}
+
+	return s.GetRequest(user, request.ID)
+}
+
+// GetMyRequests 获取用户自己的临时访问申请
+func (s *AccessRequestService) GetMyRequests(userID uint, req *models.AccessRequestListRequest) ([]*models.AccessRequestResponse, int64, error) {
+	req.UserID = userID
+	return s.listRequests(req)
+}
+
+// GetApprovalRequests 审批人查看全部临时访问申请，可按状态和申请人过滤
+func (s *AccessRequestService) GetApprovalRequests(approver *models.User, req *models.AccessRequestListRequest) ([]*models.AccessRequestResponse, int64, error) {
+	if !s.isApprover(approver) {
+		return nil, 0, fmt.Errorf("%w: user is not an access request approver", utils.ErrPermissionDenied)
+	}
+	return s.listRequests(req)
+}
+
+// GetRequest 获取临时访问申请详情，仅申请人和审批人可见
+func (s *AccessRequestService) GetRequest(user *models.User, id uint) (*models.AccessRequestResponse, error) {
+	request, err := s.getRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if request.UserID != user.ID && !s.isApprover(user) {
+		return nil, utils.ErrNotFound
+	}
+	return request.ToResponse(), nil
+}
+
+// ApproveRequest 批准临时访问申请，批准人数达到要求后生成临时授权规则
+func (s *AccessRequestService) ApproveRequest(approver *models.User, id uint, comment, clientIP string) (*models.AccessRequestResponse, error) {
+	request, err := s.getDecidableRequest(approver, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 时间窗口已结束的申请直接过期
+	if !request.EndAt.After(time.Now()) {
+		if err := s.expire(request); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: access window has ended", utils.ErrInvalidParam)
+	}
+
+	granted := false
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.createApproval(tx, request, approver, models.AccessRequestDecisionApprove, comment); err != nil {
+			return err
+		}
+
+		var approvals int64
+		if err := tx.Model(&models.AccessRequestApproval{}).
+			Where("request_id = ? AND decision = ?", request.ID, models.AccessRequestDecisionApprove).
+			Count(&approvals).Error; err != nil {
+			return fmt.Errorf("failed to count approvals: %w", err)
+		}
+		if int(approvals) < request.RequiredApprovals {
+			return nil
+		}
+
+		permissionID, err := s.createGrant(tx, request)
+		if err != nil {
+			return err
+		}
+		granted = true
+		return s.transition(tx, request.ID, []string{models.AccessRequestStatusPending}, map[string]interface{}{
+			"status":        models.AccessRequestStatusApproved,
+			"permission_id": permissionID,
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	if granted {
+		request.Status = models.AccessRequestStatusApproved
+		s.recordTransition(approver, clientIP, "approve", request, fmt.Sprintf("批准临时访问申请 #%d，授权已生成", request.ID))
+		s.notify([]uint{request.UserID}, "access_request_approved", request)
+	} else {
+		s.recordTransition(approver, clientIP, "approve", request, fmt.Sprintf("批准临时访问申请 #%d，等待其他审批人", request.ID))
+	}
+
+	return s.GetRequest(approver, id)
+}
+
+// RejectRequest 拒绝临时访问申请，任一审批人拒绝即结束申请
+func (s *AccessRequestService) RejectRequest(approver *models.User, id uint, comment, clientIP string) (*models.AccessRequestResponse, error) {
+	request, err := s.getDecidableRequest(approver, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.createApproval(tx, request, approver, models.AccessRequestDecisionReject, comment); err != nil {
+			return err
+		}
+		return s.transition(tx, request.ID, []string{models.AccessRequestStatusPending}, map[string]interface{}{
+			"status":    models.AccessRequestStatusRejected,
+			"closed_at": time.Now(),
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	request.Status = models.AccessRequestStatusRejected
+	s.recordTransition(approver, clientIP, "reject", request, fmt.Sprintf("拒绝临时访问申请 #%d", request.ID))
+	s.notify([]uint{request.UserID}, "access_request_rejected", request)
+
+	return s.GetRequest(approver, id)
+}
+
+// CancelRequest 申请人撤回申请，已生效的授权立即收回
+func (s *AccessRequestService) CancelRequest(user *models.User, id uint, clientIP string) (*models.AccessRequestResponse, error) {
+	request, err := s.getRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if request.UserID != user.ID {
+		return nil, utils.ErrNotFound
+	}
+	if request.IsClosed() {
+		return nil, fmt.Errorf("%w: access request is already %s", utils.ErrInvalidParam, request.Status)
+	}
+
+	previous := request.Status
+	if err := s.transition(s.db, request.ID, []string{previous}, map[string]interface{}{
+		"status":    models.AccessRequestStatusCancelled,
+		"closed_at": time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+	if previous == models.AccessRequestStatusApproved {
+		s.revokeGrant(request, "临时访问申请已撤回")
+	}
+
+	request.Status = models.AccessRequestStatusCancelled
+	s.recordTransition(user, clientIP, "cancel", request, fmt.Sprintf("撤回临时访问申请 #%d", request.ID))
+
+	return s.GetRequest(user, id)
+}
+
+// StartExpiryCheck 定时处理到期的临时访问申请
+func (s *AccessRequestService) StartExpiryCheck(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.CheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.ExpireRequests()
+			if err != nil {
+				logrus.WithError(err).Error("临时访问申请到期检查失败")
+				continue
+			}
+			if expired > 0 {
+				logrus.WithField("expired", expired).Info("临时访问申请已到期")
+			}
+		}
+	}
+}
+
+// ExpireRequests 将时间窗口已结束的申请标记为过期并收回授权，返回处理的申请数
+func (s *AccessRequestService) ExpireRequests() (int, error) {
+	var requests []models.AccessRequest
+	if err := s.db.Where("status IN ? AND end_at <= ?",
+		[]string{models.AccessRequestStatusPending, models.AccessRequestStatusApproved}, time.Now()).
+		Find(&requests).Error; err != nil {
+		return 0, fmt.Errorf("failed to query expired access requests: %w", err)
+	}
+
+	expired := 0
+	for i := range requests {
+		if err := s.expire(&requests[i]); err != nil {
+			logrus.WithError(err).WithField("request_id", requests[i].ID).Warn("临时访问申请过期处理失败")
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// expire 将申请标记为过期，已批准的申请收回授权并关闭相关会话
+func (s *AccessRequestService) expire(request *models.AccessRequest) error {
+	previous := request.Status
+	if err := s.transition(s.db, request.ID, []string{previous}, map[string]interface{}{
+		"status":    models.AccessRequestStatusExpired,
+		"closed_at": time.Now(),
+	}); err != nil {
+		return err
+	}
+	if previous == models.AccessRequestStatusApproved {
+		s.revokeGrant(request, "临时访问授权已到期")
+	}
+
+	request.Status = models.AccessRequestStatusExpired
+	s.recordTransition(nil, "", "expire", request, fmt.Sprintf("临时访问申请 #%d 已到期", request.ID))
+	s.notify([]uint{request.UserID}, "access_request_expired", request)
+	return nil
+}
+
+// createGrant 为申请人生成限定时间窗口的资产授权规则
+func (s *AccessRequestService) createGrant(tx *gorm.DB, request *models.AccessRequest) (uint, error) {
+	startAt, endAt := request.StartAt, request.EndAt
+	permission := &models.AssetPermission{
+		Name:          fmt.Sprintf("JIT-%d", request.ID),
+		Enabled:       true,
+		AllowUpload:   true,
+		AllowDownload: true,
+		ValidFrom:     &startAt,
+		ValidTo:       &endAt,
+		Remark:        fmt.Sprintf("临时访问申请 #%d 审批通过后自动生成，到期自动删除", request.ID),
+	}
+	if err := tx.Create(permission).Error; err != nil {
+		return 0, fmt.Errorf("failed to create asset permission: %w", err)
+	}
+
+	var assetIDs, assetGroupIDs []uint
+	if request.AssetID != nil {
+		assetIDs = []uint{*request.AssetID}
+	}
+	if request.AssetGroupID != nil {
+		assetGroupIDs = []uint{*request.AssetGroupID}
+	}
+	if err := s.permissionService.replacePermissionRelations(tx, permission.ID, []uint{request.UserID}, nil, nil, assetIDs, assetGroupIDs, nil); err != nil {
+		return 0, err
+	}
+	return permission.ID, nil
+}
+
+// revokeGrant 删除申请生成的授权规则，并关闭申请人已无权访问的资产上的会话
+func (s *AccessRequestService) revokeGrant(request *models.AccessRequest, reason string) {
+	if request.PermissionID != nil {
+		if err := s.permissionService.DeletePermission(*request.PermissionID); err != nil && !errors.Is(err, utils.ErrNotFound) {
+			logrus.WithError(err).WithField("request_id", request.ID).Error("删除临时访问授权失败")
+			return
+		}
+	}
+
+	var assetIDs []uint
+	if request.AssetID != nil {
+		assetIDs = []uint{*request.AssetID}
+	} else if request.AssetGroupID != nil {
+		if err := s.db.Model(&models.Asset{}).Where("group_id = ?", *request.AssetGroupID).Pluck("id", &assetIDs).Error; err != nil {
+			logrus.WithError(err).WithField("request_id", request.ID).Error("查询临时访问授权覆盖的资产失败")
+			return
+		}
+	}
+
+	// 仍被其它授权规则覆盖的资产保留会话
+	var revokedIDs []uint
+	for _, assetID := range assetIDs {
+		err := s.permissionService.CheckAssetAccess(request.UserID, assetID, 0)
+		if errors.Is(err, utils.ErrPermissionDenied) {
+			revokedIDs = append(revokedIDs, assetID)
+		} else if err != nil {
+			logrus.WithError(err).WithField("asset_id", assetID).Warn("校验资产授权失败")
+		}
+	}
+	if len(revokedIDs) == 0 {
+		return
+	}
+
+	closed, err := closeUserAssetSessions(request.UserID, revokedIDs, reason)
+	if err != nil {
+		logrus.WithError(err).WithField("request_id", request.ID).Error("关闭已收回授权的会话失败")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"request_id": request.ID,
+		"user_id":    request.UserID,
+		"closed":     closed,
+	}).Info("临时访问授权已收回")
+}
+
+// getDecidableRequest 获取当前审批人可以审批的申请
+func (s *AccessRequestService) getDecidableRequest(approver *models.User, id uint) (*models.AccessRequest, error) {
+	if !s.isApprover(approver) {
+		return nil, fmt.Errorf("%w: user is not an access request approver", utils.ErrPermissionDenied)
+	}
+
+	request, err := s.getRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if request.UserID == approver.ID {
+		return nil, fmt.Errorf("%w: cannot approve your own access request", utils.ErrPermissionDenied)
+	}
+	if request.Status != models.AccessRequestStatusPending {
+		return nil, fmt.Errorf("%w: access request is already %s", utils.ErrInvalidParam, request.Status)
+	}
+	for _, approval := range request.Approvals {
+		if approval.ApproverID == approver.ID {
+			return nil, fmt.Errorf("%w: access request already decided by this approver", utils.ErrDuplicate)
+		}
+	}
+	return request, nil
+}
+
+// createApproval 写入审批记录
+func (s *AccessRequestService) createApproval(tx *gorm.DB, request *models.AccessRequest, approver *models.User, decision, comment string) error {
+	approval := &models.AccessRequestApproval{
+		RequestID:    request.ID,
+		ApproverID:   approver.ID,
+		ApproverName: approver.Username,
+		Decision:     decision,
+		Comment:      comment,
+	}
+	if err := tx.Create(approval).Error; err != nil {
+		return fmt.Errorf("failed to create approval: %w", err)
+	}
+	return nil
+}
+
+// transition 仅当申请仍处于 from 中的状态时更新，避免并发审批或到期检查重复处理
+func (s *AccessRequestService) transition(tx *gorm.DB, id uint, from []string, updates map[string]interface{}) error {
+	result := tx.Model(&models.AccessRequest{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update access request: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: access request status has changed", utils.ErrInvalidParam)
+	}
+	return nil
+}
+
+// listRequests 分页查询临时访问申请
+func (s *AccessRequestService) listRequests(req *models.AccessRequestListRequest) ([]*models.AccessRequestResponse, int64, error) {
+	var requests []models.AccessRequest
+	var total int64
+
+	query := s.db.Model(&models.AccessRequest{})
+	if req.UserID != 0 {
+		query = query.Where("user_id = ?", req.UserID)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count access requests: %w", err)
+	}
+
+	if req.Page > 0 && req.PageSize > 0 {
+		query = query.Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize)
+	}
+
+	if err := s.preloadRelations(query).Order("id DESC").Find(&requests).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query access requests: %w", err)
+	}
+
+	responses := make([]*models.AccessRequestResponse, len(requests))
+	for i := range requests {
+		responses[i] = requests[i].ToResponse()
+	}
+	return responses, total, nil
+}
+
+// getRequest 加载申请及其关联
+func (s *AccessRequestService) getRequest(id uint) (*models.AccessRequest, error) {
+	var request models.AccessRequest
+	if err := s.preloadRelations(s.db).First(&request, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query access request: %w", err)
+	}
+	return &request, nil
+}
+
+// preloadRelations 预加载申请人、资产和审批记录
+func (s *AccessRequestService) preloadRelations(query *gorm.DB) *gorm.DB {
+	return query.Preload("User").
+		Preload("Asset").
+		Preload("AssetGroup").
+		Preload("Approvals", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") })
+}
+
+// isApprover 用户是否拥有审批角色
+func (s *AccessRequestService) isApprover(user *models.User) bool {
+	for _, role := range s.cfg.ApproverRoles {
+		if user.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// getApproverIDs 获取除申请人外所有启用状态的审批人
+func (s *AccessRequestService) getApproverIDs(requesterID uint) ([]uint, error) {
+	var ids []uint
+	err := s.db.Model(&models.User{}).
+		Joins("JOIN user_roles ur ON ur.user_id = users.id").
+		Joins("JOIN roles r ON r.id = ur.role_id").
+		Where("r.name IN ? AND users.status = ? AND users.id <> ?", s.cfg.ApproverRoles, 1, requesterID).
+		Distinct().Pluck("users.id", &ids).Error
+	return ids, err
+}
+
+// recordTransition 记录申请状态变化的操作日志，operator 为nil表示系统自动处理
+func (s *AccessRequestService) recordTransition(operator *models.User, clientIP, action string, request *models.AccessRequest, message string) {
+	userID, username, method := uint(0), "system", "SYSTEM"
+	if operator != nil {
+		userID, username, method = operator.ID, operator.Username, "POST"
+	}
+	url := fmt.Sprintf("/api/v1/access-requests/%d/%s", request.ID, action)
+	if action == "create" {
+		url = "/api/v1/access-requests"
+	}
+
+	go s.auditService.RecordOperationLog(
+		userID,
+		username,
+		clientIP,
+		method,
+		url,
+		action,
+		"access_request",
+		request.ID,
+		"",
+		200,
+		message,
+		map[string]interface{}{
+			"user_id":        request.UserID,
+			"asset_id":       request.AssetID,
+			"asset_group_id": request.AssetGroupID,
+			"start_at":       request.StartAt,
+			"end_at":         request.EndAt,
+		},
+		map[string]interface{}{"status": request.Status},
+		0,
+		false,
+	)
+}
+
+// notify 推送申请状态通知
+func (s *AccessRequestService) notify(userIDs []uint, eventType string, request *models.AccessRequest) {
+	sender := notifier()
+	if sender == nil || len(userIDs) == 0 {
+		return
+	}
+	if err := sender.NotifyUsers(context.Background(), userIDs, eventType, map[string]interface{}{
+		"request_id":     request.ID,
+		"user_id":        request.UserID,
+		"asset_id":       request.AssetID,
+		"asset_group_id": request.AssetGroupID,
+		"start_at":       request.StartAt,
+		"end_at":         request.EndAt,
+		"status":         request.Status,
+	}); err != nil {
+		logrus.WithError(err).Warn("发送临时访问申请通知失败")
+	}
+}
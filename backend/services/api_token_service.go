@@ -0,0 +1,121 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APITokenService 个人API令牌服务
+type APITokenService struct {
+	db *gorm.DB
+}
+
+// NewAPITokenService 创建个人API令牌服务实例
+func NewAPITokenService(db *gorm.DB) *APITokenService {
+	return &APITokenService{db: db}
+}
+
+// GetTokens 获取用户的API令牌列表，包含已吊销和已过期的令牌
+func (s *APITokenService) GetTokens(userID uint) ([]*models.APITokenResponse, error) {
+	var tokens []models.APIToken
+	if err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("failed to get api tokens: %w", err)
+	}
+
+	responses := make([]*models.APITokenResponse, len(tokens))
+	for i := range tokens {
+		responses[i] = tokens[i].ToResponse()
+	}
+	return responses, nil
+}
+
+// CreateToken 为用户创建API令牌，权限范围必须是用户自身权限的子集
+// 明文令牌只在创建时返回一次
+func (s *APITokenService) CreateToken(user *models.User, req *models.APITokenCreateRequest) (*models.APITokenCreateResponse, error) {
+	permissions, err := s.normalizePermissions(user, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, prefix, err := utils.GenerateAPIToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate api token: %w", err)
+	}
+
+	token := &models.APIToken{
+		UserID:      user.ID,
+		Name:        req.Name,
+		TokenPrefix: prefix,
+		TokenHash:   utils.HashAPIToken(plain),
+		Permissions: strings.Join(permissions, ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := s.db.Create(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to create api token: %w", err)
+	}
+
+	return &models.APITokenCreateResponse{APITokenResponse: token.ToResponse(), Token: plain}, nil
+}
+
+// RevokeToken 吊销用户的API令牌，保留记录用于审计
+func (s *APITokenService) RevokeToken(userID, tokenID uint) error {
+	var token models.APIToken
+	if err := s.db.Where("id = ? AND user_id = ?", tokenID, userID).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrNotFound
+		}
+		return fmt.Errorf("failed to find api token: %w", err)
+	}
+	if token.RevokedAt != nil {
+		return nil
+	}
+
+	if err := s.db.Model(&token).Update("revoked_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to revoke api token: %w", err)
+	}
+	return nil
+}
+
+// normalizePermissions 校验并去重权限范围
+// all 表示拥有者的全部权限；其它权限必须存在且用户本身拥有
+func (s *APITokenService) normalizePermissions(user *models.User, permissions []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	for _, name := range permissions {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if name == models.APITokenScopeAll {
+			return []string{models.APITokenScopeAll}, nil
+		}
+		if !user.HasPermission(name) {
+			return nil, fmt.Errorf("%w: permission %s is not granted to the user", utils.ErrInvalidParam, name)
+		}
+		result = append(result, name)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: at least one permission is required", utils.ErrInvalidParam)
+	}
+
+	var count int64
+	if err := s.db.Model(&models.Permission{}).Where("name IN ?", result).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check permissions: %w", err)
+	}
+	if int(count) != len(result) {
+		return nil, fmt.Errorf("%w: some permissions not found", utils.ErrInvalidParam)
+	}
+
+	return result, nil
+}
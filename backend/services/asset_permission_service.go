@@ -0,0 +1,910 @@
+package services
+
+import (
+	"bastion/interfaces"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AssetPermissionService 资产授权服务
+// 负责用户组、资产授权规则的管理，并实现 interfaces.PermissionChecker
+type AssetPermissionService struct {
+	db *gorm.DB
+}
+
+// 编译期检查接口实现
+var _ interfaces.PermissionChecker = (*AssetPermissionService)(nil)
+
+// NewAssetPermissionService 创建资产授权服务实例
+func NewAssetPermissionService(db *gorm.DB) *AssetPermissionService {
+	return &AssetPermissionService{db: db}
+}
+
+// ======================== 用户组管理 ========================
+
+// CreateUserGroup 创建用户组
+func (s *AssetPermissionService) CreateUserGroup(req *models.UserGroupCreateRequest) (*models.UserGroupResponse, error) {
+	var count int64
+	if err := s.db.Model(&models.UserGroup{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check user group name: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: user group name already exists", utils.ErrDuplicate)
+	}
+
+	group := &models.UserGroup{
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(group).Error; err != nil {
+			return fmt.Errorf("failed to create user group: %w", err)
+		}
+		return s.replaceRelation(tx, relationGroupMembers, "user_group_id", group.ID, req.UserIDs)
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.GetUserGroup(group.ID)
+}
+
+// GetUserGroups 获取用户组列表
+func (s *AssetPermissionService) GetUserGroups(req *models.UserGroupListRequest) ([]*models.UserGroupResponse, int64, error) {
+	var groups []models.UserGroup
+	var total int64
+
+	query := s.db.Model(&models.UserGroup{})
+	if req.Keyword != "" {
+		query = query.Where("name LIKE ?", "%"+req.Keyword+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count user groups: %w", err)
+	}
+
+	if req.Page > 0 && req.PageSize > 0 {
+		query = query.Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize)
+	}
+
+	if err := query.Preload("Users").Order("id DESC").Find(&groups).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query user groups: %w", err)
+	}
+
+	responses := make([]*models.UserGroupResponse, len(groups))
+	for i := range groups {
+		responses[i] = groups[i].ToResponse()
+	}
+	return responses, total, nil
+}
+
+// GetUserGroup 获取用户组详情
+func (s *AssetPermissionService) GetUserGroup(id uint) (*models.UserGroupResponse, error) {
+	var group models.UserGroup
+	if err := s.db.Preload("Users").First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query user group: %w", err)
+	}
+	return group.ToResponse(), nil
+}
+
+// UpdateUserGroup 更新用户组
+func (s *AssetPermissionService) UpdateUserGroup(id uint, req *models.UserGroupUpdateRequest) (*models.UserGroupResponse, error) {
+	var group models.UserGroup
+	if err := s.db.First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query user group: %w", err)
+	}
+
+	if req.Name != "" && req.Name != group.Name {
+		var count int64
+		if err := s.db.Model(&models.UserGroup{}).Where("name = ? AND id != ?", req.Name, id).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to check user group name: %w", err)
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("%w: user group name already exists", utils.ErrDuplicate)
+		}
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		updates := make(map[string]interface{})
+		if req.Name != "" {
+			updates["name"] = req.Name
+		}
+		if req.Description != nil {
+			updates["description"] = *req.Description
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&group).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update user group: %w", err)
+			}
+		}
+		if req.UserIDs != nil {
+			return s.replaceRelation(tx, relationGroupMembers, "user_group_id", id, *req.UserIDs)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.GetUserGroup(id)
+}
+
+// DeleteUserGroup 删除用户组
+func (s *AssetPermissionService) DeleteUserGroup(id uint) error {
+	var group models.UserGroup
+	if err := s.db.First(&group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrNotFound
+		}
+		return fmt.Errorf("failed to query user group: %w", err)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM user_group_members WHERE user_group_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to delete user group members: %w", err)
+		}
+		if err := tx.Exec("DELETE FROM asset_permission_user_groups WHERE user_group_id = ?", id).Error; err != nil {
+			return fmt.Errorf("failed to delete user group permissions: %w", err)
+		}
+		if err := tx.Delete(&group).Error; err != nil {
+			return fmt.Errorf("failed to delete user group: %w", err)
+		}
+		return nil
+	})
+}
+
+// ======================== 授权规则管理 ========================
+
+// CreatePermission 创建资产授权规则
+func (s *AssetPermissionService) CreatePermission(req *models.AssetPermissionCreateRequest) (*models.AssetPermissionResponse, error) {
+	if err := s.validatePermissionRequest(req.UserIDs, req.RoleIDs, req.UserGroupIDs, req.AssetIDs, req.AssetGroupIDs, req.ValidFrom, req.ValidTo); err != nil {
+		return nil, err
+	}
+
+	if err := validateForwardPorts(req.ForwardPorts); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&models.AssetPermission{}).Where("name = ?", req.Name).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check permission name: %w", err)
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: permission name already exists", utils.ErrDuplicate)
+	}
+
+	enabled, allowUpload, allowDownload := true, true, true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	if req.AllowUpload != nil {
+		allowUpload = *req.AllowUpload
+	}
+	if req.AllowDownload != nil {
+		allowDownload = *req.AllowDownload
+	}
+
+	permission := &models.AssetPermission{
+		Name:          req.Name,
+		Enabled:       enabled,
+		AllowUpload:   allowUpload,
+		AllowDownload: allowDownload,
+		ForwardPorts:  req.ForwardPorts,
+		ValidFrom:     req.ValidFrom,
+		ValidTo:       req.ValidTo,
+		Remark:        req.Remark,
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(permission).Error; err != nil {
+			return fmt.Errorf("failed to create asset permission: %w", err)
+		}
+		// 显式更新布尔字段，避免gorm忽略false零值后使用默认值
+		if !enabled || !allowUpload || !allowDownload {
+			if err := tx.Model(permission).Updates(map[string]interface{}{
+				"enabled":        enabled,
+				"allow_upload":   allowUpload,
+				"allow_download": allowDownload,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to update asset permission: %w", err)
+			}
+		}
+		return s.replacePermissionRelations(tx, permission.ID, req.UserIDs, req.RoleIDs, req.UserGroupIDs, req.AssetIDs, req.AssetGroupIDs, req.CredentialIDs)
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.GetPermission(permission.ID)
+}
+
+// GetPermissions 获取资产授权规则列表
+func (s *AssetPermissionService) GetPermissions(req *models.AssetPermissionListRequest) ([]*models.AssetPermissionResponse, int64, error) {
+	var permissions []models.AssetPermission
+	var total int64
+
+	query := s.db.Model(&models.AssetPermission{})
+	if req.Keyword != "" {
+		query = query.Where("name LIKE ?", "%"+req.Keyword+"%")
+	}
+	if req.Enabled != nil {
+		query = query.Where("enabled = ?", *req.Enabled)
+	}
+	if req.UserID != 0 {
+		query = query.Where("id IN (SELECT permission_id FROM asset_permission_users WHERE user_id = ?)", req.UserID)
+	}
+	if req.AssetID != 0 {
+		query = query.Where("(id IN (SELECT permission_id FROM asset_permission_assets WHERE asset_id = ?) "+
+			"OR id IN (SELECT pg.permission_id FROM asset_permission_asset_groups pg JOIN assets a ON a.group_id = pg.asset_group_id WHERE a.id = ?))",
+			req.AssetID, req.AssetID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count asset permissions: %w", err)
+	}
+
+	if req.Page > 0 && req.PageSize > 0 {
+		query = query.Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize)
+	}
+
+	if err := s.preloadPermissionRelations(query).Order("id DESC").Find(&permissions).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query asset permissions: %w", err)
+	}
+
+	responses := make([]*models.AssetPermissionResponse, len(permissions))
+	for i := range permissions {
+		responses[i] = permissions[i].ToResponse()
+	}
+	return responses, total, nil
+}
+
+// GetPermission 获取资产授权规则详情
+func (s *AssetPermissionService) GetPermission(id uint) (*models.AssetPermissionResponse, error) {
+	var permission models.AssetPermission
+	if err := s.preloadPermissionRelations(s.db).First(&permission, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query asset permission: %w", err)
+	}
+	return permission.ToResponse(), nil
+}
+
+// UpdatePermission 更新资产授权规则
+func (s *AssetPermissionService) UpdatePermission(id uint, req *models.AssetPermissionUpdateRequest) (*models.AssetPermissionResponse, error) {
+	var permission models.AssetPermission
+	if err := s.db.First(&permission, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query asset permission: %w", err)
+	}
+
+	if req.Name != "" && req.Name != permission.Name {
+		var count int64
+		if err := s.db.Model(&models.AssetPermission{}).Where("name = ? AND id != ?", req.Name, id).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to check permission name: %w", err)
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("%w: permission name already exists", utils.ErrDuplicate)
+		}
+	}
+
+	validFrom, validTo := permission.ValidFrom, permission.ValidTo
+	if req.ValidFrom != nil {
+		validFrom = req.ValidFrom
+	}
+	if req.ValidTo != nil {
+		validTo = req.ValidTo
+	}
+	if validFrom != nil && validTo != nil && validTo.Before(*validFrom) {
+		return nil, utils.ErrInvalidParam
+	}
+	if req.ForwardPorts != nil {
+		if err := validateForwardPorts(*req.ForwardPorts); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		updates := make(map[string]interface{})
+		if req.Name != "" {
+			updates["name"] = req.Name
+		}
+		if req.Enabled != nil {
+			updates["enabled"] = *req.Enabled
+		}
+		if req.AllowUpload != nil {
+			updates["allow_upload"] = *req.AllowUpload
+		}
+		if req.AllowDownload != nil {
+			updates["allow_download"] = *req.AllowDownload
+		}
+		if req.ForwardPorts != nil {
+			updates["forward_ports"] = *req.ForwardPorts
+		}
+		if req.ValidFrom != nil {
+			updates["valid_from"] = req.ValidFrom
+		}
+		if req.ValidTo != nil {
+			updates["valid_to"] = req.ValidTo
+		}
+		if req.Remark != nil {
+			updates["remark"] = *req.Remark
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&permission).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update asset permission: %w", err)
+			}
+		}
+
+		// 仅替换请求中提供的关联
+		relations := []struct {
+			relation permissionRelation
+			ids      *[]uint
+		}{
+			{relationUsers, req.UserIDs},
+			{relationRoles, req.RoleIDs},
+			{relationUserGroups, req.UserGroupIDs},
+			{relationAssets, req.AssetIDs},
+			{relationAssetGroups, req.AssetGroupIDs},
+			{relationCredentials, req.CredentialIDs},
+		}
+		for _, rel := range relations {
+			if rel.ids == nil {
+				continue
+			}
+			if err := s.replaceRelation(tx, rel.relation, "permission_id", id, *rel.ids); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.GetPermission(id)
+}
+
+// DeletePermission 删除资产授权规则
+func (s *AssetPermissionService) DeletePermission(id uint) error {
+	var permission models.AssetPermission
+	if err := s.db.First(&permission, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrNotFound
+		}
+		return fmt.Errorf("failed to query asset permission: %w", err)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []string{
+			"asset_permission_users",
+			"asset_permission_roles",
+			"asset_permission_user_groups",
+			"asset_permission_assets",
+			"asset_permission_asset_groups",
+			"asset_permission_credentials",
+		} {
+			if err := tx.Exec("DELETE FROM "+table+" WHERE permission_id = ?", id).Error; err != nil {
+				return fmt.Errorf("failed to delete %s: %w", table, err)
+			}
+		}
+		if err := tx.Delete(&permission).Error; err != nil {
+			return fmt.Errorf("failed to delete asset permission: %w", err)
+		}
+		return nil
+	})
+}
+
+// ======================== 授权判定 ========================
+
+// IsAdminUser 判断用户是否为管理员（管理员不受资产授权限制）
+func (s *AssetPermissionService) IsAdminUser(userID uint) (bool, error) {
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return false, err
+	}
+	return isAdminUser(user), nil
+}
+
+// GetAuthorizedAssetIDs 获取用户被授权的资产ID列表
+// 返回 all=true 表示用户不受限制（管理员）
+func (s *AssetPermissionService) GetAuthorizedAssetIDs(userID uint) (assetIDs []uint, all bool, err error) {
+	isAdmin, err := s.IsAdminUser(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if isAdmin {
+		return nil, true, nil
+	}
+
+	permissionIDs, err := s.getEffectivePermissionIDs(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(permissionIDs) == 0 {
+		return []uint{}, false, nil
+	}
+
+	var directIDs []uint
+	if err := s.db.Table("asset_permission_assets").
+		Where("permission_id IN ?", permissionIDs).
+		Distinct().Pluck("asset_id", &directIDs).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to query authorized assets: %w", err)
+	}
+
+	var groupAssetIDs []uint
+	if err := s.db.Model(&models.Asset{}).
+		Where("group_id IN (SELECT asset_group_id FROM asset_permission_asset_groups WHERE permission_id IN ?)", permissionIDs).
+		Pluck("id", &groupAssetIDs).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to query authorized group assets: %w", err)
+	}
+
+	seen := make(map[uint]bool, len(directIDs)+len(groupAssetIDs))
+	assetIDs = make([]uint, 0, len(directIDs)+len(groupAssetIDs))
+	for _, id := range append(directIDs, groupAssetIDs...) {
+		if !seen[id] {
+			seen[id] = true
+			assetIDs = append(assetIDs, id)
+		}
+	}
+	return assetIDs, false, nil
+}
+
+// GetAuthorizedCredentialIDs 获取用户在指定资产上可使用的凭证ID列表
+// 返回 all=true 表示可使用资产关联的全部凭证
+func (s *AssetPermissionService) GetAuthorizedCredentialIDs(userID, assetID uint) (credentialIDs []uint, all bool, err error) {
+	isAdmin, err := s.IsAdminUser(userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if isAdmin {
+		return nil, true, nil
+	}
+
+	permissionIDs, err := s.getPermissionIDsForAsset(userID, assetID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(permissionIDs) == 0 {
+		return []uint{}, false, nil
+	}
+
+	// 只要有一条覆盖该资产的规则未限定凭证，即允许使用全部凭证
+	var restricted []uint
+	if err := s.db.Table("asset_permission_credentials").
+		Where("permission_id IN ?", permissionIDs).
+		Distinct().Pluck("permission_id", &restricted).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to query permission credentials: %w", err)
+	}
+	if len(restricted) < len(permissionIDs) {
+		return nil, true, nil
+	}
+
+	if err := s.db.Table("asset_permission_credentials").
+		Where("permission_id IN ?", permissionIDs).
+		Distinct().Pluck("credential_id", &credentialIDs).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to query authorized credentials: %w", err)
+	}
+	return credentialIDs, false, nil
+}
+
+// CheckAssetAccess 校验用户是否可以使用指定凭证访问资产
+// credentialID 为0时仅校验资产授权
+func (s *AssetPermissionService) CheckAssetAccess(userID, assetID, credentialID uint) error {
+	credentialIDs, all, err := s.GetAuthorizedCredentialIDs(userID, assetID)
+	if err != nil {
+		return err
+	}
+	if all {
+		return nil
+	}
+	if len(credentialIDs) == 0 {
+		return fmt.Errorf("%w: asset %d is not authorized for user %d", utils.ErrPermissionDenied, assetID, userID)
+	}
+	if credentialID == 0 {
+		return nil
+	}
+	for _, id := range credentialIDs {
+		if id == credentialID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: credential %d is not authorized on asset %d", utils.ErrPermissionDenied, credentialID, assetID)
+}
+
+// CheckFileTransfer 校验用户是否可以在资产上上传或下载文件
+// 覆盖该资产的生效规则中任意一条允许对应方向即可，管理员不受限制
+func (s *AssetPermissionService) CheckFileTransfer(userID, assetID uint, direction string) error {
+	var column string
+	switch direction {
+	case models.TransferDirectionUpload:
+		column = "allow_upload"
+	case models.TransferDirectionDownload:
+		column = "allow_download"
+	default:
+		return fmt.Errorf("%w: unknown transfer direction %s", utils.ErrInvalidParam, direction)
+	}
+
+	isAdmin, err := s.IsAdminUser(userID)
+	if err != nil {
+		return err
+	}
+	if isAdmin {
+		return nil
+	}
+
+	permissionIDs, err := s.getPermissionIDsForAsset(userID, assetID)
+	if err != nil {
+		return err
+	}
+	if len(permissionIDs) == 0 {
+		return fmt.Errorf("%w: asset %d is not authorized for user %d", utils.ErrPermissionDenied, assetID, userID)
+	}
+
+	var count int64
+	if err := s.db.Model(&models.AssetPermission{}).
+		Where("id IN ?", permissionIDs).
+		Where(column+" = ?", true).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check file transfer permission: %w", err)
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: file %s is not allowed on asset %d", utils.ErrPermissionDenied, direction, assetID)
+	}
+	return nil
+}
+
+// CheckPortForward 校验用户是否可以通过端口转发访问资产的指定端口
+// 覆盖该资产的生效规则中任意一条的端口列表包含该端口即可，管理员不受限制
+func (s *AssetPermissionService) CheckPortForward(userID, assetID uint, port int) error {
+	isAdmin, err := s.IsAdminUser(userID)
+	if err != nil {
+		return err
+	}
+	if isAdmin {
+		return nil
+	}
+
+	permissionIDs, err := s.getPermissionIDsForAsset(userID, assetID)
+	if err != nil {
+		return err
+	}
+	if len(permissionIDs) == 0 {
+		return fmt.Errorf("%w: asset %d is not authorized for user %d", utils.ErrPermissionDenied, assetID, userID)
+	}
+
+	var specs []string
+	if err := s.db.Model(&models.AssetPermission{}).
+		Where("id IN ? AND forward_ports <> ''", permissionIDs).
+		Pluck("forward_ports", &specs).Error; err != nil {
+		return fmt.Errorf("failed to check port forward permission: %w", err)
+	}
+	for _, spec := range specs {
+		if models.ForwardPortAllowed(spec, port) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: port %d is not allowed to be forwarded on asset %d", utils.ErrPermissionDenied, port, assetID)
+}
+
+// CanAccessAsset 检查用户是否可以访问资产
+func (s *AssetPermissionService) CanAccessAsset(ctx context.Context, userID uint, assetID uint) (bool, error) {
+	return s.checkResult(s.CheckAssetAccess(userID, assetID, 0))
+}
+
+// CanModifyAsset 检查用户是否可以修改资产
+func (s *AssetPermissionService) CanModifyAsset(ctx context.Context, userID uint, assetID uint) (bool, error) {
+	return s.canOperateAsset(userID, assetID, "asset:update")
+}
+
+// CanDeleteAsset 检查用户是否可以删除资产
+func (s *AssetPermissionService) CanDeleteAsset(ctx context.Context, userID uint, assetID uint) (bool, error) {
+	return s.canOperateAsset(userID, assetID, "asset:delete")
+}
+
+// CanCreateSession 检查用户是否可以对资产创建会话
+func (s *AssetPermissionService) CanCreateSession(ctx context.Context, userID uint, assetID uint) (bool, error) {
+	return s.canOperateAsset(userID, assetID, "asset:connect")
+}
+
+// CanTerminateSession 检查用户是否可以终止会话（会话所有者或拥有终止权限）
+func (s *AssetPermissionService) CanTerminateSession(ctx context.Context, userID uint, sessionID string) (bool, error) {
+	return s.canOperateSession(userID, sessionID, "audit:terminate")
+}
+
+// CanViewSession 检查用户是否可以查看会话（会话所有者或拥有监控权限）
+func (s *AssetPermissionService) CanViewSession(ctx context.Context, userID uint, sessionID string) (bool, error) {
+	return s.canOperateSession(userID, sessionID, "audit:monitor")
+}
+
+// CanAccessCredential 检查用户是否可以使用凭证（凭证需在某个已授权资产上被授权）
+func (s *AssetPermissionService) CanAccessCredential(ctx context.Context, userID uint, credentialID uint) (bool, error) {
+	var assetIDs []uint
+	if err := s.db.Table("asset_credentials").Where("credential_id = ?", credentialID).Pluck("asset_id", &assetIDs).Error; err != nil {
+		return false, fmt.Errorf("failed to query credential assets: %w", err)
+	}
+
+	isAdmin, err := s.IsAdminUser(userID)
+	if err != nil {
+		return false, err
+	}
+	if isAdmin {
+		return true, nil
+	}
+
+	for _, assetID := range assetIDs {
+		if err := s.CheckAssetAccess(userID, assetID, credentialID); err == nil {
+			return true, nil
+		} else if !errors.Is(err, utils.ErrPermissionDenied) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// CanModifyCredential 检查用户是否可以修改凭证
+func (s *AssetPermissionService) CanModifyCredential(ctx context.Context, userID uint, credentialID uint) (bool, error) {
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return false, err
+	}
+	if isAdminUser(user) {
+		return true, nil
+	}
+	if !user.HasPermission("asset:update") {
+		return false, nil
+	}
+	return s.CanAccessCredential(context.Background(), userID, credentialID)
+}
+
+// IsSystemAdmin 检查用户是否为系统管理员
+func (s *AssetPermissionService) IsSystemAdmin(ctx context.Context, userID uint) (bool, error) {
+	return s.IsAdminUser(userID)
+}
+
+// GetUserPermissions 获取用户的功能权限列表
+func (s *AssetPermissionService) GetUserPermissions(ctx context.Context, userID uint) ([]string, error) {
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	return user.ToResponse().Permissions, nil
+}
+
+// ======================== 内部辅助方法 ========================
+
+// isAdminUser 管理员角色或拥有all权限的用户视为管理员
+func isAdminUser(user *models.User) bool {
+	return user.HasRole("admin") || user.HasPermission("all")
+}
+
+// loadUser 加载用户及其角色权限
+func (s *AssetPermissionService) loadUser(userID uint) (*models.User, error) {
+	var user models.User
+	if err := s.db.Preload("Roles.Permissions").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	return &user, nil
+}
+
+// getEffectivePermissionIDs 获取当前对用户生效的授权规则ID（直接授权、角色授权、用户组授权）
+func (s *AssetPermissionService) getEffectivePermissionIDs(userID uint) ([]uint, error) {
+	now := time.Now()
+	var ids []uint
+	err := s.db.Model(&models.AssetPermission{}).
+		Where("enabled = ?", true).
+		Where("(valid_from IS NULL OR valid_from <= ?) AND (valid_to IS NULL OR valid_to >= ?)", now, now).
+		Where("(id IN (SELECT permission_id FROM asset_permission_users WHERE user_id = ?) "+
+			"OR id IN (SELECT pr.permission_id FROM asset_permission_roles pr JOIN user_roles ur ON ur.role_id = pr.role_id WHERE ur.user_id = ?) "+
+			"OR id IN (SELECT pg.permission_id FROM asset_permission_user_groups pg JOIN user_group_members m ON m.user_group_id = pg.user_group_id WHERE m.user_id = ?))",
+			userID, userID, userID).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query effective permissions: %w", err)
+	}
+	return ids, nil
+}
+
+// getPermissionIDsForAsset 获取对用户生效且覆盖指定资产的授权规则ID
+func (s *AssetPermissionService) getPermissionIDsForAsset(userID, assetID uint) ([]uint, error) {
+	permissionIDs, err := s.getEffectivePermissionIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(permissionIDs) == 0 {
+		return nil, nil
+	}
+
+	var ids []uint
+	if err := s.db.Table("asset_permission_assets").
+		Where("permission_id IN ? AND asset_id = ?", permissionIDs, assetID).
+		Pluck("permission_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to query asset permissions: %w", err)
+	}
+
+	var groupIDs []uint
+	if err := s.db.Table("asset_permission_asset_groups pg").
+		Joins("JOIN assets a ON a.group_id = pg.asset_group_id").
+		Where("pg.permission_id IN ? AND a.id = ? AND a.deleted_at IS NULL", permissionIDs, assetID).
+		Pluck("pg.permission_id", &groupIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to query asset group permissions: %w", err)
+	}
+
+	seen := make(map[uint]bool)
+	result := make([]uint, 0, len(ids)+len(groupIDs))
+	for _, id := range append(ids, groupIDs...) {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// canOperateAsset 检查功能权限和资产授权
+func (s *AssetPermissionService) canOperateAsset(userID, assetID uint, permission string) (bool, error) {
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return false, err
+	}
+	if isAdminUser(user) {
+		return true, nil
+	}
+	if !user.HasPermission(permission) {
+		return false, nil
+	}
+	return s.checkResult(s.CheckAssetAccess(userID, assetID, 0))
+}
+
+// canOperateSession 会话所有者或拥有指定功能权限的用户可以操作会话
+func (s *AssetPermissionService) canOperateSession(userID uint, sessionID, permission string) (bool, error) {
+	user, err := s.loadUser(userID)
+	if err != nil {
+		return false, err
+	}
+	if isAdminUser(user) || user.HasPermission(permission) {
+		return true, nil
+	}
+
+	var count int64
+	if err := s.db.Model(&models.SessionRecord{}).
+		Where("session_id = ? AND user_id = ?", sessionID, userID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to query session record: %w", err)
+	}
+	return count > 0, nil
+}
+
+// checkResult 将授权校验错误转换为布尔结果
+func (s *AssetPermissionService) checkResult(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, utils.ErrPermissionDenied) {
+		return false, nil
+	}
+	return false, err
+}
+
+// validatePermissionRequest 校验授权规则请求：至少一个授权对象、一个授权资源
+func (s *AssetPermissionService) validatePermissionRequest(userIDs, roleIDs, userGroupIDs, assetIDs, assetGroupIDs []uint, validFrom, validTo *time.Time) error {
+	if len(userIDs)+len(roleIDs)+len(userGroupIDs) == 0 {
+		return fmt.Errorf("%w: at least one user, role or user group is required", utils.ErrInvalidParam)
+	}
+	if len(assetIDs)+len(assetGroupIDs) == 0 {
+		return fmt.Errorf("%w: at least one asset or asset group is required", utils.ErrInvalidParam)
+	}
+	if validFrom != nil && validTo != nil && validTo.Before(*validFrom) {
+		return fmt.Errorf("%w: valid_to must be after valid_from", utils.ErrInvalidParam)
+	}
+	return nil
+}
+
+// validateForwardPorts 校验端口转发的端口列表格式
+func validateForwardPorts(spec string) error {
+	if _, err := models.ParseForwardPorts(spec); err != nil {
+		return fmt.Errorf("%w: forward_ports %v", utils.ErrInvalidParam, err)
+	}
+	return nil
+}
+
+// preloadPermissionRelations 预加载授权规则的所有关联
+func (s *AssetPermissionService) preloadPermissionRelations(query *gorm.DB) *gorm.DB {
+	return query.Preload("Users").
+		Preload("Roles").
+		Preload("UserGroups").
+		Preload("Assets").
+		Preload("AssetGroups").
+		Preload("Credentials")
+}
+
+// permissionRelation 授权规则关联表定义
+type permissionRelation struct {
+	table  string      // 关联表
+	column string      // 关联目标字段
+	model  interface{} // 目标模型（用于校验ID是否存在）
+}
+
+var (
+	relationUsers       = permissionRelation{"asset_permission_users", "user_id", &models.User{}}
+	relationRoles       = permissionRelation{"asset_permission_roles", "role_id", &models.Role{}}
+	relationUserGroups  = permissionRelation{"asset_permission_user_groups", "user_group_id", &models.UserGroup{}}
+	relationAssets      = permissionRelation{"asset_permission_assets", "asset_id", &models.Asset{}}
+	relationAssetGroups = permissionRelation{"asset_permission_asset_groups", "asset_group_id", &models.AssetGroup{}}
+	relationCredentials = permissionRelation{"asset_permission_credentials", "credential_id", &models.Credential{}}
+
+	// 用户组成员关联
+	relationGroupMembers = permissionRelation{"user_group_members", "user_id", &models.User{}}
+)
+
+// replacePermissionRelations 写入授权规则的所有关联
+func (s *AssetPermissionService) replacePermissionRelations(tx *gorm.DB, permissionID uint, userIDs, roleIDs, userGroupIDs, assetIDs, assetGroupIDs, credentialIDs []uint) error {
+	relations := []struct {
+		relation permissionRelation
+		ids      []uint
+	}{
+		{relationUsers, userIDs},
+		{relationRoles, roleIDs},
+		{relationUserGroups, userGroupIDs},
+		{relationAssets, assetIDs},
+		{relationAssetGroups, assetGroupIDs},
+		{relationCredentials, credentialIDs},
+	}
+	for _, rel := range relations {
+		if err := s.replaceRelation(tx, rel.relation, "permission_id", permissionID, rel.ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceRelation 替换关联表中的记录，目标ID必须全部存在
+func (s *AssetPermissionService) replaceRelation(tx *gorm.DB, relation permissionRelation, ownerColumn string, ownerID uint, ids []uint) error {
+	ids = uniqueIDs(ids)
+	if len(ids) > 0 {
+		var count int64
+		if err := tx.Model(relation.model).Where("id IN ?", ids).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check %s: %w", relation.column, err)
+		}
+		if int(count) != len(ids) {
+			return fmt.Errorf("%w: some %s do not exist", utils.ErrInvalidParam, relation.column)
+		}
+	}
+
+	if err := tx.Exec("DELETE FROM "+relation.table+" WHERE "+ownerColumn+" = ?", ownerID).Error; err != nil {
+		return fmt.Errorf("failed to delete old %s: %w", relation.table, err)
+	}
+	for _, id := range ids {
+		if err := tx.Exec("INSERT INTO "+relation.table+" ("+ownerColumn+", "+relation.column+") VALUES (?, ?)", ownerID, id).Error; err != nil {
+			return fmt.Errorf("failed to create %s: %w", relation.table, err)
+		}
+	}
+	return nil
+}
+
+// uniqueIDs ID去重
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
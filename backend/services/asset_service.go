@@ -1,25 +1,34 @@
 package services
 
 import (
+	"bastion/config"
 	"bastion/models"
 	"bastion/utils"
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
 // AssetService 资产服务
 type AssetService struct {
-	db *gorm.DB
+	db                *gorm.DB
+	permissionService *AssetPermissionService // 资产授权服务
+	gateways          *GatewayChainService    // 网关链路服务
 }
 
 // NewAssetService 创建资产服务实例
 func NewAssetService(db *gorm.DB) *AssetService {
-	return &AssetService{db: db}
+	return &AssetService{
+		db:                db,
+		permissionService: NewAssetPermissionService(db),
+		gateways:          NewGatewayChainService(db),
+	}
 }
 
 // CreateAsset 创建资产
@@ -65,6 +74,10 @@ func (s *AssetService) CreateAsset(request *models.AssetCreateRequest) (*models.
 		Tags:     request.Tags,
 		Status:   1, // 默认启用
 		GroupID:  request.GroupID,
+
+		ZoneID:              request.ZoneID,
+		GatewayID:           request.GatewayID,
+		GatewayCredentialID: request.GatewayCredentialID,
 	}
 	
 	// 如果没有指定操作系统类型，根据资产类型设置默认值
@@ -76,6 +89,11 @@ func (s *AssetService) CreateAsset(request *models.AssetCreateRequest) (*models.
 		}
 	}
 
+	// 校验网络区域和网关配置
+	if err := s.validateGateway(&asset); err != nil {
+		return nil, err
+	}
+
 	// 使用事务创建资产及其关联
 	tx := s.db.Begin()
 	if err := tx.Create(&asset).Error; err != nil {
@@ -103,14 +121,23 @@ func (s *AssetService) CreateAsset(request *models.AssetCreateRequest) (*models.
 	return asset.ToResponse(), nil
 }
 
-// GetAssets 获取资产列表
-func (s *AssetService) GetAssets(request *models.AssetListRequest) ([]*models.AssetResponse, int64, error) {
+// GetAssets 获取资产列表（仅返回用户被授权的资产）
+func (s *AssetService) GetAssets(userID uint, request *models.AssetListRequest) ([]*models.AssetResponse, int64, error) {
 	var assets []models.Asset
 	var total int64
 
 	// 构建查询
 	query := s.db.Model(&models.Asset{})
 
+	// 资产授权过滤
+	authorizedIDs, all, err := s.permissionService.GetAuthorizedAssetIDs(userID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get authorized assets: %w", err)
+	}
+	if !all {
+		query = query.Where("id IN ?", authorizedIDs)
+	}
+
 	// 关键字搜索
 	if request.Keyword != "" {
 		query = query.Where("name LIKE ? OR address LIKE ?", "%"+request.Keyword+"%", "%"+request.Keyword+"%")
@@ -161,8 +188,8 @@ func (s *AssetService) GetAssets(request *models.AssetListRequest) ([]*models.As
 	return responses, total, nil
 }
 
-// GetAsset 获取单个资产
-func (s *AssetService) GetAsset(id uint) (*models.AssetResponse, error) {
+// GetAsset 获取单个资产（仅限用户被授权的资产）
+func (s *AssetService) GetAsset(userID, id uint) (*models.AssetResponse, error) {
 	var asset models.Asset
 	if err := s.db.Preload("Credentials").Preload("Group").Where("id = ?", id).First(&asset).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -171,6 +198,10 @@ func (s *AssetService) GetAsset(id uint) (*models.AssetResponse, error) {
 		return nil, fmt.Errorf("failed to query asset: %w", err)
 	}
 
+	if err := s.permissionService.CheckAssetAccess(userID, asset.ID, 0); err != nil {
+		return nil, err
+	}
+
 	return asset.ToResponse(), nil
 }
 
@@ -206,6 +237,31 @@ func (s *AssetService) UpdateAsset(id uint, request *models.AssetUpdateRequest)
 		}
 	}
 
+	// 网络区域和网关，传0表示清除
+	updates := make(map[string]interface{})
+	if request.ZoneID != nil || request.GatewayID != nil || request.GatewayCredentialID != nil {
+		proposed := asset
+		if request.ZoneID != nil {
+			proposed.ZoneID = nilIfZero(*request.ZoneID)
+			updates["zone_id"] = proposed.ZoneID
+		}
+		if request.GatewayID != nil {
+			proposed.GatewayID = nilIfZero(*request.GatewayID)
+			updates["gateway_id"] = proposed.GatewayID
+			if proposed.GatewayID == nil {
+				proposed.GatewayCredentialID = nil
+				updates["gateway_credential_id"] = nil
+			}
+		}
+		if request.GatewayCredentialID != nil && proposed.GatewayID != nil {
+			proposed.GatewayCredentialID = nilIfZero(*request.GatewayCredentialID)
+			updates["gateway_credential_id"] = proposed.GatewayCredentialID
+		}
+		if err := s.validateGateway(&proposed); err != nil {
+			return nil, err
+		}
+	}
+
 	// 使用事务更新资产信息和关联关系
 	tx := s.db.Begin()
 	defer func() {
@@ -215,7 +271,6 @@ func (s *AssetService) UpdateAsset(id uint, request *models.AssetUpdateRequest)
 	}()
 
 	// 更新资产信息
-	updates := make(map[string]interface{})
 	if request.Name != "" {
 		updates["name"] = request.Name
 	}
@@ -273,6 +328,18 @@ func (s *AssetService) DeleteAsset(id uint) error {
 		return fmt.Errorf("failed to query asset: %w", err)
 	}
 
+	// 仍被其他资产或网络区域用作网关时不能删除
+	var gatewayRefs, zoneRefs int64
+	if err := s.db.Model(&models.Asset{}).Where("gateway_id = ?", id).Count(&gatewayRefs).Error; err != nil {
+		return fmt.Errorf("failed to check gateway references: %w", err)
+	}
+	if err := s.db.Model(&models.NetworkZone{}).Where("gateway_id = ?", id).Count(&zoneRefs).Error; err != nil {
+		return fmt.Errorf("failed to check gateway references: %w", err)
+	}
+	if gatewayRefs > 0 || zoneRefs > 0 {
+		return errors.New("asset is used as a gateway")
+	}
+
 	// 开始事务
 	tx := s.db.Begin()
 	defer func() {
@@ -310,6 +377,10 @@ func (s *AssetService) CreateCredential(request *models.CredentialCreateRequest)
 	if request.Type == "key" && request.PrivateKey == "" {
 		return nil, errors.New("private key is required for key type credential")
 	}
+	// 证书类型凭证登录时由堡垒机CA签发短期证书，不保存密码和私钥
+	if request.Type == utils.CredentialTypeCert {
+		request.Password, request.PrivateKey = "", ""
+	}
 
 	// 检查资产是否存在
 	var assets []models.Asset
@@ -330,13 +401,24 @@ func (s *AssetService) CreateCredential(request *models.CredentialCreateRequest)
 		encryptedPassword = encrypted
 	}
 
+	// 加密私钥
+	var encryptedPrivateKey string
+	if request.PrivateKey != "" {
+		encrypted, err := utils.EncryptPassword(request.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt private key: %w", err)
+		}
+		encryptedPrivateKey = encrypted
+	}
+
 	// 创建凭证
 	credential := models.Credential{
 		Name:       request.Name,
 		Type:       request.Type,
 		Username:   request.Username,
 		Password:   encryptedPassword,
-		PrivateKey: request.PrivateKey,
+		PrivateKey: encryptedPrivateKey,
+		SecretHint: utils.CredentialSecretHint(request.Type, request.Password, request.PrivateKey),
 	}
 
 	// 使用事务创建凭证及其关联
@@ -454,7 +536,27 @@ func (s *AssetService) UpdateCredential(id uint, request *models.CredentialUpdat
 		updates["password"] = encrypted
 	}
 	if request.PrivateKey != "" {
-		updates["private_key"] = request.PrivateKey
+		encrypted, err := utils.EncryptPassword(request.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt private key: %w", err)
+		}
+		updates["private_key"] = encrypted
+	}
+	if request.Type == utils.CredentialTypeCert {
+		updates["password"] = ""
+		updates["private_key"] = ""
+	}
+
+	// 密码或私钥变化时更新提示，提示由类型决定：密码凭证为密码长度区间，密钥凭证为公钥指纹
+	credType := credential.Type
+	if request.Type != "" {
+		credType = request.Type
+	}
+	switch {
+	case credType == utils.CredentialTypePassword && request.Password != "",
+		credType == utils.CredentialTypeKey && request.PrivateKey != "",
+		credType == utils.CredentialTypeCert:
+		updates["secret_hint"] = utils.CredentialSecretHint(credType, request.Password, request.PrivateKey)
 	}
 
 	if len(updates) > 0 {
@@ -490,8 +592,47 @@ func (s *AssetService) DeleteCredential(id uint) error {
 	return nil
 }
 
-// TestConnection 测试连接
-func (s *AssetService) TestConnection(request *models.ConnectionTestRequest) (*models.ConnectionTestResponse, error) {
+// BackfillSecretHints 为缺少提示的密码和密钥凭证生成提示（历史凭证及迁移中清除的旧密码掩码）
+func (s *AssetService) BackfillSecretHints() error {
+	var credentials []models.Credential
+	if err := s.db.Where("(secret_hint IS NULL OR secret_hint = '') AND type IN ?",
+		[]string{utils.CredentialTypePassword, utils.CredentialTypeKey}).Find(&credentials).Error; err != nil {
+		return fmt.Errorf("failed to query credentials: %w", err)
+	}
+
+	for _, credential := range credentials {
+		var password, privateKey string
+		var err error
+		switch credential.Type {
+		case utils.CredentialTypePassword:
+			if credential.Password == "" {
+				continue
+			}
+			password, err = utils.DecryptPassword(credential.Password)
+		case utils.CredentialTypeKey:
+			if credential.PrivateKey == "" {
+				continue
+			}
+			privateKey, err = utils.DecryptPrivateKey(credential.PrivateKey)
+		}
+		if err != nil {
+			logrus.WithError(err).WithField("credential_id", credential.ID).Warn("解密凭证失败，跳过生成提示")
+			continue
+		}
+
+		hint := utils.CredentialSecretHint(credential.Type, password, privateKey)
+		if hint == "" {
+			continue
+		}
+		if err := s.db.Model(&models.Credential{}).Where("id = ?", credential.ID).Update("secret_hint", hint).Error; err != nil {
+			return fmt.Errorf("failed to update secret hint of credential %d: %w", credential.ID, err)
+		}
+	}
+	return nil
+}
+
+// TestConnection 测试连接，用户需被授权使用该资产上的凭证
+func (s *AssetService) TestConnection(userID uint, request *models.ConnectionTestRequest) (*models.ConnectionTestResponse, error) {
 	// 获取资产信息
 	var asset models.Asset
 	if err := s.db.Where("id = ?", request.AssetID).First(&asset).Error; err != nil {
@@ -522,6 +663,10 @@ func (s *AssetService) TestConnection(request *models.ConnectionTestRequest) (*m
 		return nil, errors.New("credential is not associated with the asset")
 	}
 
+	if err := s.permissionService.CheckAssetAccess(userID, asset.ID, credential.ID); err != nil {
+		return nil, err
+	}
+
 	// 执行连接测试
 	response := &models.ConnectionTestResponse{
 		TestedAt: time.Now(),
@@ -540,6 +685,9 @@ func (s *AssetService) TestConnection(request *models.ConnectionTestRequest) (*m
 	case "database":
 		// 数据库连接测试
 		response = s.testDatabase(asset, credential, response)
+	case "telnet":
+		// Telnet连通性和自动登录测试
+		response = s.testTelnet(asset, credential, response)
 	default:
 		return nil, errors.New("unsupported test type")
 	}
@@ -619,10 +767,10 @@ func (s *AssetService) testSSH(asset models.Asset, credential models.Credential,
 		password = decrypted
 	}
 
-	// 首先测试TCP连接
+	// 首先测试TCP连接，资产配置了网关时经网关链路连接
 	startTime := time.Now()
-	address := fmt.Sprintf("%s:%d", asset.Address, asset.Port)
-	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	address := net.JoinHostPort(asset.Address, strconv.Itoa(asset.Port))
+	conn, _, err := s.gateways.Dial(&asset, address, 5*time.Second)
 	if err != nil {
 		latency := time.Since(startTime)
 		response.Success = false
@@ -671,7 +819,7 @@ func (s *AssetService) testSSH(asset models.Asset, credential models.Credential,
 func (s *AssetService) testRDP(asset models.Asset, credential models.Credential, response *models.ConnectionTestResponse) *models.ConnectionTestResponse {
 	// RDP连接测试实现
 	startTime := time.Now()
-	address := fmt.Sprintf("%s:%d", asset.Address, asset.Port)
+	address := net.JoinHostPort(asset.Address, strconv.Itoa(asset.Port))
 	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
 	if err != nil {
 		response.Success = false
@@ -704,10 +852,10 @@ func (s *AssetService) testDatabase(asset models.Asset, credential models.Creden
 		password = decrypted
 	}
 
-	// 数据库连接测试实现
+	// 数据库连接测试实现，资产配置了网关时经网关链路连接
 	startTime := time.Now()
-	address := fmt.Sprintf("%s:%d", asset.Address, asset.Port)
-	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	address := net.JoinHostPort(asset.Address, strconv.Itoa(asset.Port))
+	conn, _, err := s.gateways.Dial(&asset, address, 5*time.Second)
 	if err != nil {
 		response.Success = false
 		response.Error = err.Error()
@@ -716,17 +864,154 @@ func (s *AssetService) testDatabase(asset models.Asset, credential models.Creden
 	}
 	defer conn.Close()
 
+	// MySQL、PostgreSQL和Redis资产使用凭证完成协议登录，其他协议仅测试端口连通性
+	if asset.Protocol == models.DatabaseProtocolMySQL {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		upstream, err := mysqlHandshake(conn, mysqlUpstreamOptions{Username: credential.Username, Password: password})
+		if err != nil {
+			response.Success = false
+			response.Error = err.Error()
+			response.Message = "Database authentication failed"
+			response.Latency = int(time.Since(startTime).Milliseconds())
+			return response
+		}
+		upstream.conn.writePacket(0, []byte{mysqlComQuit})
+
+		response.Success = true
+		response.Message = fmt.Sprintf("Database authentication successful (user: %s, server: %s)", credential.Username, upstream.ServerVersion)
+		response.Latency = int(time.Since(startTime).Milliseconds())
+		return response
+	}
+	if asset.Protocol == models.DatabaseProtocolPostgreSQL {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		// 未指定数据库时登录默认的维护库
+		upstream, err := pgHandshake(conn, pgUpstreamOptions{Username: credential.Username, Password: password, Database: "postgres"})
+		if err != nil {
+			response.Success = false
+			response.Error = err.Error()
+			response.Message = "Database authentication failed"
+			response.Latency = int(time.Since(startTime).Milliseconds())
+			return response
+		}
+		upstream.conn.writeMessage('X', nil)
+
+		response.Success = true
+		response.Message = fmt.Sprintf("Database authentication successful (user: %s, server: %s)", credential.Username, upstream.ServerVersion)
+		response.Latency = int(time.Since(startTime).Milliseconds())
+		return response
+	}
+	if asset.Protocol == models.DatabaseProtocolRedis {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		upstream := newRedisConn(conn)
+		err := redisLogin(upstream, credential.Username, password)
+		if err == nil {
+			_, err = upstream.call([]byte("PING"))
+		}
+		if err != nil {
+			response.Success = false
+			response.Error = err.Error()
+			response.Message = "Database authentication failed"
+			response.Latency = int(time.Since(startTime).Milliseconds())
+			return response
+		}
+		upstream.call([]byte("QUIT"))
+
+		response.Success = true
+		response.Message = fmt.Sprintf("Database authentication successful (user: %s)", credential.Username)
+		response.Latency = int(time.Since(startTime).Milliseconds())
+		return response
+	}
+
 	latency := time.Since(startTime)
 	response.Success = true
 	response.Message = fmt.Sprintf("Database connection successful (user: %s)", credential.Username)
 	response.Latency = int(latency.Milliseconds())
 
-	// 在实际实现中，这里应该使用数据库驱动进行真正的连接测试
-	_ = password // 避免未使用变量警告
+	return response
+}
+
+// testTelnet 测试Telnet连接，按配置的提示符使用凭证完成自动登录
+func (s *AssetService) testTelnet(asset models.Asset, credential models.Credential, response *models.ConnectionTestResponse) *models.ConnectionTestResponse {
+	if credential.Type != utils.CredentialTypePassword {
+		response.Success = false
+		response.Error = "Telnet requires a password credential"
+		response.Message = "Telnet authentication failed"
+		return response
+	}
+	password, err := utils.DecryptPassword(credential.Password)
+	if err != nil {
+		response.Success = false
+		response.Error = "Failed to decrypt password"
+		response.Message = "Telnet authentication failed"
+		return response
+	}
+	prompts, err := newTelnetPrompts(config.GlobalConfig.Telnet)
+	if err != nil {
+		response.Success = false
+		response.Error = err.Error()
+		response.Message = "Telnet authentication failed"
+		return response
+	}
 
+	// 资产配置了网关时经网关链路连接
+	startTime := time.Now()
+	address := net.JoinHostPort(asset.Address, strconv.Itoa(asset.Port))
+	conn, _, err := s.gateways.Dial(&asset, address, 5*time.Second)
+	if err != nil {
+		response.Success = false
+		response.Error = err.Error()
+		response.Message = "Telnet connection failed"
+		return response
+	}
+	defer conn.Close()
+
+	loginTimeout := config.GlobalConfig.Telnet.LoginTimeout
+	if loginTimeout <= 0 {
+		loginTimeout = defaultTelnetLoginTimeout
+	}
+	telnet, err := newTelnetConn(conn, defaultTelnetTerminalType, 80, 24)
+	if err == nil {
+		err = telnet.login(credential.Username, password, prompts, time.Duration(loginTimeout)*time.Second)
+	}
+	if err != nil {
+		response.Success = false
+		response.Error = err.Error()
+		response.Message = "Telnet authentication failed"
+		response.Latency = int(time.Since(startTime).Milliseconds())
+		return response
+	}
+
+	response.Success = true
+	response.Message = fmt.Sprintf("Telnet login successful (user: %s)", credential.Username)
+	response.Latency = int(time.Since(startTime).Milliseconds())
 	return response
 }
 
+// validateGateway 校验资产的网络区域存在，且网关配置能解析出有效链路
+func (s *AssetService) validateGateway(asset *models.Asset) error {
+	if asset.ZoneID != nil {
+		var count int64
+		if err := s.db.Model(&models.NetworkZone{}).Where("id = ?", *asset.ZoneID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check network zone: %w", err)
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: network zone not found", utils.ErrInvalidParam)
+		}
+	}
+	if asset.GatewayID == nil && asset.GatewayCredentialID != nil {
+		return fmt.Errorf("%w: gateway_credential_id requires gateway_id", utils.ErrInvalidParam)
+	}
+	return s.gateways.ValidateAssetGateway(asset)
+}
+
+// nilIfZero 0表示清除，转换为nil
+func nilIfZero(value uint) *uint {
+	if value == 0 {
+		return nil
+	}
+	return &value
+}
+
 // GetAssetByName 根据名称获取资产
 func (s *AssetService) GetAssetByName(name string) (*models.Asset, error) {
 	var asset models.Asset
@@ -932,18 +1217,29 @@ func (s *AssetService) BatchMoveAssetsToGroup(request *models.AssetBatchMoveRequ
 }
 
 // GetAssetGroupsWithHosts 获取包含主机详细信息的资产分组列表（用于控制台树形菜单）
-func (s *AssetService) GetAssetGroupsWithHosts(assetType string) ([]*models.AssetGroupWithHostsResponse, error) {
+func (s *AssetService) GetAssetGroupsWithHosts(userID uint, assetType string) ([]*models.AssetGroupWithHostsResponse, error) {
 	var groups []models.AssetGroup
 	
+	// 资产授权过滤
+	authorizedIDs, all, err := s.permissionService.GetAuthorizedAssetIDs(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get authorized assets: %w", err)
+	}
+	
 	// 查询所有分组，预加载指定类型的资产
 	query := s.db.Model(&models.AssetGroup{})
 	
-	// 预加载资产时过滤资产类型
-	if assetType != "" {
-		query = query.Preload("Assets", "type = ? AND deleted_at IS NULL", assetType)
-	} else {
-		query = query.Preload("Assets", "deleted_at IS NULL")
-	}
+	// 预加载资产时过滤资产类型和授权范围
+	query = query.Preload("Assets", func(db *gorm.DB) *gorm.DB {
+		db = db.Where("deleted_at IS NULL")
+		if assetType != "" {
+			db = db.Where("type = ?", assetType)
+		}
+		if !all {
+			db = db.Where("id IN ?", authorizedIDs)
+		}
+		return db
+	})
 	
 	if err := query.Find(&groups).Error; err != nil {
 		return nil, fmt.Errorf("failed to query asset groups with hosts: %w", err)
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestBackfillSecretHints(t *testing.T) {
+	setupTestConfig(t)
+	db := newTestDB(t, &models.Credential{})
+
+	encryptedPassword, err := utils.EncryptPassword("Backfill-Password-42")
+	require.NoError(t, err)
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	require.NoError(t, err)
+	encryptedKey, err := utils.EncryptPassword(string(pem.EncodeToMemory(block)))
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	require.NoError(t, err)
+	_, legacyKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	legacyBlock, err := ssh.MarshalPrivateKey(legacyKey, "")
+	require.NoError(t, err)
+	legacySigner, err := ssh.NewSignerFromKey(legacyKey)
+	require.NoError(t, err)
+
+	// 迁移清除了旧的密码掩码，密钥凭证为创建提示字段之前的历史数据
+	password := models.Credential{Name: "password", Type: utils.CredentialTypePassword, Username: "root", Password: encryptedPassword}
+	key := models.Credential{Name: "key", Type: utils.CredentialTypeKey, Username: "deploy", PrivateKey: encryptedKey}
+	// 加密存储之前保存的明文PEM私钥
+	plaintextKey := models.Credential{Name: "legacy-key", Type: utils.CredentialTypeKey, Username: "backup", PrivateKey: string(pem.EncodeToMemory(legacyBlock))}
+	fingerprinted := models.Credential{Name: "existing", Type: utils.CredentialTypeKey, Username: "ops", SecretHint: "SHA256:existing"}
+	require.NoError(t, db.Create(&password).Error)
+	require.NoError(t, db.Create(&key).Error)
+	require.NoError(t, db.Create(&plaintextKey).Error)
+	require.NoError(t, db.Create(&fingerprinted).Error)
+
+	require.NoError(t, NewAssetService(db).BackfillSecretHints())
+
+	hints := map[uint]string{}
+	var credentials []models.Credential
+	require.NoError(t, db.Find(&credentials).Error)
+	for _, credential := range credentials {
+		hints[credential.ID] = credential.SecretHint
+	}
+	require.Equal(t, "****(16+)", hints[password.ID])
+	require.Equal(t, ssh.FingerprintSHA256(signer.PublicKey()), hints[key.ID])
+	require.Equal(t, ssh.FingerprintSHA256(legacySigner.PublicKey()), hints[plaintextKey.ID])
+	require.Equal(t, "SHA256:existing", hints[fingerprinted.ID])
+}
+
+func TestCredentialSecretHintRevealsNoPasswordCharacters(t *testing.T) {
+	for password, expected := range map[string]string{
+		"abc":                    "****(<8)",
+		"abcdefgh":               "****(8-11)",
+		"abcdefghijkl":           "****(12-15)",
+		"abcdefghijklmnopqrstuv": "****(16+)",
+	} {
+		hint := utils.CredentialSecretHint(utils.CredentialTypePassword, password, "")
+		require.Equal(t, expected, hint)
+		require.NotContains(t, hint, password[len(password)-2:])
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"bastion/interfaces"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditLoggerService 审计日志器，实现 interfaces.AuditLogger
+// 审计事件写入操作日志表。与HTTP操作日志不同，这些事件不受 audit.enableOperationLog 开关影响，
+// 写入失败时返回错误，调用方可据此拒绝敏感操作（如查看凭证明文）。
+type AuditLoggerService struct {
+	db *gorm.DB
+}
+
+// NewAuditLoggerService 创建审计日志器
+func NewAuditLoggerService(db *gorm.DB) *AuditLoggerService {
+	return &AuditLoggerService{db: db}
+}
+
+// auditClientIPKey 上下文中客户端IP的键
+type auditClientIPKey struct{}
+
+// WithAuditClientIP 在上下文中携带客户端IP，审计日志器记录到日志中
+func WithAuditClientIP(ctx context.Context, clientIP string) context.Context {
+	return context.WithValue(ctx, auditClientIPKey{}, clientIP)
+}
+
+// auditLogger 获取已注册的审计日志器，未注册时返回nil
+func auditLogger() interfaces.AuditLogger {
+	return GlobalServiceRegistry.GetAuditLogger()
+}
+
+// LogSessionStart 记录会话开始
+func (l *AuditLoggerService) LogSessionStart(ctx context.Context, session interfaces.SessionInfo) error {
+	if _, ok := ctx.Value(auditClientIPKey{}).(string); !ok {
+		ctx = WithAuditClientIP(ctx, session.GetClientIP())
+	}
+	return l.record(ctx, session.GetUserID(), "session_start", "session", session.GetAssetID(), session.GetSessionID(), 200,
+		fmt.Sprintf("会话 %s 开始", session.GetSessionID()), map[string]interface{}{
+			"asset_id":   session.GetAssetID(),
+			"start_time": session.GetStartTime(),
+		})
+}
+
+// LogSessionEnd 记录会话结束
+func (l *AuditLoggerService) LogSessionEnd(ctx context.Context, sessionID string, reason string) error {
+	return l.record(ctx, 0, "session_end", "session", 0, sessionID, 200,
+		fmt.Sprintf("会话 %s 结束", sessionID), map[string]interface{}{"reason": reason})
+}
+
+// LogSessionCommand 记录会话中执行的命令
+func (l *AuditLoggerService) LogSessionCommand(ctx context.Context, sessionID string, command string) error {
+	return l.record(ctx, 0, "session_command", "session", 0, sessionID, 200,
+		"会话执行命令", map[string]interface{}{"command": command})
+}
+
+// LogAssetOperation 记录资产操作
+func (l *AuditLoggerService) LogAssetOperation(ctx context.Context, userID uint, assetID uint, operation string, details interface{}) error {
+	return l.record(ctx, userID, operation, "asset", assetID, "", 200,
+		fmt.Sprintf("资产 #%d %s", assetID, operation), details)
+}
+
+// LogConnectionTest 记录连接测试结果
+func (l *AuditLoggerService) LogConnectionTest(ctx context.Context, userID uint, assetID uint, result *interfaces.ConnectionResult) error {
+	status, message := 200, ""
+	if result != nil {
+		message = result.Message
+		if !result.Success {
+			status = 500
+		}
+	}
+	return l.record(ctx, userID, "test_connection", "asset", assetID, "", status, message, result)
+}
+
+// LogCredentialAccess 记录凭证访问，如查看明文
+func (l *AuditLoggerService) LogCredentialAccess(ctx context.Context, userID uint, credentialID uint, operation string) error {
+	return l.record(ctx, userID, operation, "credential", credentialID, "", 200,
+		fmt.Sprintf("凭证 #%d %s", credentialID, operation), map[string]interface{}{"credential_id": credentialID})
+}
+
+// LogSecurityEvent 记录安全事件
+func (l *AuditLoggerService) LogSecurityEvent(ctx context.Context, userID uint, eventType string, details interface{}) error {
+	return l.record(ctx, userID, eventType, "security", 0, "", 200, "安全事件 "+eventType, details)
+}
+
+// LogFailedAuthentication 记录资产认证失败
+func (l *AuditLoggerService) LogFailedAuthentication(ctx context.Context, userID uint, assetID uint, reason string) error {
+	return l.record(ctx, userID, "authentication_failed", "asset", assetID, "", 401, reason, nil)
+}
+
+// record 写入一条审计日志，userID 为0表示系统事件
+func (l *AuditLoggerService) record(ctx context.Context, userID uint, action, resource string, resourceID uint, sessionID string, status int, message string, details interface{}) error {
+	username, method := "system", "SYSTEM"
+	if userID != 0 {
+		var user models.User
+		if err := l.db.Select("id, username").First(&user, userID).Error; err != nil {
+			return fmt.Errorf("failed to query audit user: %w", err)
+		}
+		username, method = user.Username, "AUDIT"
+	}
+	clientIP, _ := ctx.Value(auditClientIPKey{}).(string)
+
+	var requestData string
+	if details != nil {
+		if data, err := json.Marshal(details); err == nil {
+			requestData = string(utils.RedactJSON(data))
+		}
+	}
+
+	now := time.Now()
+	log := &models.OperationLog{
+		UserID:      userID,
+		Username:    username,
+		IP:          clientIP,
+		Method:      method,
+		URL:         fmt.Sprintf("/audit/%s/%d/%s", resource, resourceID, action),
+		Action:      action,
+		Resource:    resource,
+		ResourceID:  resourceID,
+		SessionID:   sessionID,
+		Status:      status,
+		Message:     message,
+		RequestData: requestData,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := l.db.WithContext(ctx).Create(log).Error; err != nil {
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+	return nil
+}
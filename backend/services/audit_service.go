@@ -128,15 +128,16 @@ func (a *AuditService) RecordOperationLog(userID uint, username, ip, method, url
 	// 注意：测试连接操作已在shouldLogOperationWithContext中完全屏蔽
 	// 这里不再需要单独的去重逻辑，因为test类型操作不会达到这里
 
+	// 请求和响应中的密码、私钥等敏感字段脱敏后保存
 	var reqData, respData string
 	if requestData != nil {
 		if data, err := json.Marshal(requestData); err == nil {
-			reqData = string(data)
+			reqData = string(utils.RedactJSON(data))
 		}
 	}
 	if responseData != nil {
 		if data, err := json.Marshal(responseData); err == nil {
-			respData = string(data)
+			respData = string(utils.RedactJSON(data))
 		}
 	}
 
@@ -233,8 +234,8 @@ func (a *AuditService) GetOperationLogs(req *models.OperationLogListRequest) ([]
 
 // ======================== 会话记录相关 ========================
 
-// RecordSessionStart 记录会话开始
-func (a *AuditService) RecordSessionStart(sessionID string, userID uint, username string, assetID uint, assetName, assetAddress string, credentialID uint, protocol, ip string) error {
+// RecordSessionStart 记录会话开始，gatewayChain 为连接经过的网关链路，直连时为空
+func (a *AuditService) RecordSessionStart(sessionID string, userID uint, username string, assetID uint, assetName, assetAddress string, credentialID uint, protocol, ip string, gatewayChain models.GatewayChain) error {
 	if !config.GlobalConfig.Audit.EnableSessionRecord {
 		return nil
 	}
@@ -271,6 +272,7 @@ func (a *AuditService) RecordSessionStart(sessionID string, userID uint, usernam
 		CredentialID: credentialID,
 		Protocol:     protocol,
 		IP:           ip,
+		GatewayChain: gatewayChain,
 		Status:       "active",
 		StartTime:    time.Now(),
 		CreatedAt:    time.Now(),
@@ -623,7 +625,8 @@ func (a *AuditService) LogMiddleware() gin.HandlerFunc {
 		// 获取请求数据
 		var requestData interface{}
 		var requestBody []byte
-		if c.Request.Method != "GET" && c.Request.ContentLength > 0 {
+		// 文件上传请求体不读入内存
+		if c.Request.Method != "GET" && c.Request.ContentLength > 0 && !strings.HasPrefix(c.ContentType(), "multipart/") {
 			if body, err := c.GetRawData(); err == nil {
 				requestBody = body
 				json.Unmarshal(body, &requestData)
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// AuthBackend 认证后端
+// 每个后端对应 users.auth_source 的一种取值，外部后端负责在首次登录时创建本地用户
+type AuthBackend interface {
+	// Name 后端名称，与 users.auth_source 对应
+	Name() string
+	// Authenticate 校验用户名和密码，返回对应的本地用户
+	Authenticate(username, password string) (*models.User, error)
+}
+
+// errInvalidCredentials 用户名或密码错误，各后端统一返回该错误避免泄露账号是否存在
+var errInvalidCredentials = errors.New("invalid username or password")
+
+// LocalAuthBackend 本地账号认证后端（bcrypt密码）
+type LocalAuthBackend struct {
+	db *gorm.DB
+}
+
+// NewLocalAuthBackend 创建本地认证后端
+func NewLocalAuthBackend(db *gorm.DB) *LocalAuthBackend {
+	return &LocalAuthBackend{db: db}
+}
+
+// Name 后端名称
+func (b *LocalAuthBackend) Name() string {
+	return models.AuthSourceLocal
+}
+
+// Authenticate 校验本地账号密码
+func (b *LocalAuthBackend) Authenticate(username, password string) (*models.User, error) {
+	var user models.User
+	if err := b.db.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if user.IsExternal() {
+		return nil, errInvalidCredentials
+	}
+
+	if !utils.CheckPassword(password, user.Password) {
+		return nil, errInvalidCredentials
+	}
+
+	return &user, nil
+}
+
+// externalProfile 外部认证源中的用户信息
+type externalProfile struct {
+	Source     string // 认证来源，对应 users.auth_source
+	ExternalID string // 外部标识，如LDAP DN、OIDC sub
+	Username   string
+	Email      string
+	Phone      string
+}
+
+// mapGroupRoles 根据组映射计算用户角色，未匹配任何映射时使用默认角色
+func mapGroupRoles(db *gorm.DB, groups []string, mappings []config.GroupRoleMapping, defaultRoles []string, match func(group, want string) bool) ([]models.Role, error) {
+	roleNames := make(map[string]bool)
+	for _, mapping := range mappings {
+		for _, group := range groups {
+			if match(group, mapping.Group) {
+				roleNames[mapping.Role] = true
+				break
+			}
+		}
+	}
+	if len(roleNames) == 0 {
+		for _, name := range defaultRoles {
+			roleNames[name] = true
+		}
+	}
+	if len(roleNames) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(roleNames))
+	for name := range roleNames {
+		names = append(names, name)
+	}
+
+	var roles []models.Role
+	if err := db.Where("name IN ?", names).Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("failed to find roles: %w", err)
+	}
+	if len(roles) != len(names) {
+		logrus.WithField("roles", names).Warn("组映射中存在未创建的角色")
+	}
+
+	return roles, nil
+}
+
+// provisionExternalUser 创建或更新外部认证用户，并按外部组重新分配角色
+// 同名的其他来源账号不允许被接管
+func provisionExternalUser(db *gorm.DB, profile *externalProfile, roles []models.Role) (*models.User, error) {
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("username = ?", profile.Username).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// 本地密码不可用于登录，仅为满足非空约束
+			password, err := randomPasswordHash()
+			if err != nil {
+				return err
+			}
+			user = models.User{
+				Username:   profile.Username,
+				Password:   password,
+				Email:      profile.Email,
+				Phone:      profile.Phone,
+				Status:     1,
+				AuthSource: profile.Source,
+				ExternalID: profile.ExternalID,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
+			logrus.WithFields(logrus.Fields{
+				"username": user.Username,
+				"source":   profile.Source,
+			}).Info("外部认证用户首次登录，已创建本地用户")
+		case err != nil:
+			return fmt.Errorf("failed to find user: %w", err)
+		case user.AuthSource != profile.Source:
+			return errInvalidCredentials
+		default:
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"email":       profile.Email,
+				"phone":       profile.Phone,
+				"external_id": profile.ExternalID,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to update user: %w", err)
+			}
+		}
+
+		if err := tx.Model(&user).Association("Roles").Replace(roles); err != nil {
+			return fmt.Errorf("failed to sync user roles: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// randomPasswordHash 生成随机密码的哈希，外部认证用户不能使用本地密码登录
+func randomPasswordHash() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return utils.HashPassword(hex.EncodeToString(buf))
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bastion/config"
 	"bastion/models"
 	"bastion/utils"
 	"errors"
@@ -11,44 +12,335 @@ import (
 
 // AuthService 认证服务
 type AuthService struct {
-	db *gorm.DB
+	db             *gorm.DB
+	mfaService     *MFAService
+	loginGuard     *LoginGuardService
+	passwordPolicy *PasswordPolicyService
+	accessPolicy   *AccessPolicyService
+	backends       []AuthBackend // 按注册顺序尝试，第一个为本地认证
+}
+
+// LoginResult 登录结果
+// 需要二次验证时 Token 为空，Challenge 中携带预认证令牌
+type LoginResult struct {
+	User             *models.User
+	Token            *utils.TokenResponse
+	Challenge        *models.MFAChallengeResponse
+	RecoveryCodes    []string // 登录过程中完成MFA绑定时生成的恢复码
+	UsedRecoveryCode bool
 }
 
 // NewAuthService 创建认证服务实例
 func NewAuthService(db *gorm.DB) *AuthService {
-	return &AuthService{db: db}
+	s := &AuthService{
+		db:             db,
+		mfaService:     NewMFAService(db),
+		loginGuard:     NewLoginGuardService(db),
+		passwordPolicy: NewPasswordPolicyService(db),
+		accessPolicy:   NewAccessPolicyService(db),
+	}
+	s.RegisterBackend(NewLocalAuthBackend(db))
+	if config.GlobalConfig != nil && config.GlobalConfig.LDAP.Enable {
+		s.RegisterBackend(NewLDAPAuthBackend(db))
+	}
+	return s
 }
 
-// Login 用户登录
-func (s *AuthService) Login(request *models.UserLoginRequest) (*utils.TokenResponse, error) {
-	// 根据用户名查找用户
-	var user models.User
-	if err := s.db.Preload("Roles.Permissions").Where("username = ?", request.Username).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid username or password")
+// RegisterBackend 注册认证后端，同名后端会被替换
+func (s *AuthService) RegisterBackend(backend AuthBackend) {
+	for i, existing := range s.backends {
+		if existing.Name() == backend.Name() {
+			s.backends[i] = backend
+			return
 		}
+	}
+	s.backends = append(s.backends, backend)
+}
+
+// Authenticate 校验用户名和密码
+// 已存在的用户只交给其认证来源对应的后端校验；本地不存在的用户依次尝试外部后端，
+// 由外部后端完成自动创建。返回的用户已预加载角色和权限。
+func (s *AuthService) Authenticate(username, password string) (*models.User, error) {
+	var existing models.User
+	err := s.db.Where("username = ?", username).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return nil, fmt.Errorf("failed to find user: %w", err)
 	}
 
+	var authenticated *models.User
+	if err == nil {
+		if existing.AuthSource == models.AuthSourceOIDC {
+			// 单点登录用户没有可用的密码
+			return nil, errInvalidCredentials
+		}
+		backend := s.getBackend(existing.AuthSource)
+		if backend == nil {
+			return nil, fmt.Errorf("authentication backend %s is not enabled", existing.AuthSource)
+		}
+		if authenticated, err = backend.Authenticate(username, password); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, backend := range s.backends {
+			if backend.Name() == models.AuthSourceLocal {
+				continue
+			}
+			authenticated, err = backend.Authenticate(username, password)
+			if err == nil {
+				break
+			}
+			if !errors.Is(err, errInvalidCredentials) {
+				return nil, err
+			}
+		}
+		if authenticated == nil {
+			return nil, errInvalidCredentials
+		}
+	}
+
+	// 重新加载角色和权限（外部后端可能刚同步过角色）
+	return s.loadActiveUser(authenticated.ID)
+}
+
+// loadActiveUser 加载用户及其角色和权限，并检查用户是否激活
+func (s *AuthService) loadActiveUser(userID uint) (*models.User, error) {
+	var user models.User
+	if err := s.db.Preload("Roles.Permissions").Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
 	// 检查用户是否激活
 	if !user.IsActive() {
 		return nil, errors.New("user account is disabled")
 	}
 
-	// 验证密码
-	if !utils.CheckPassword(request.Password, user.Password) {
-		return nil, errors.New("invalid username or password")
+	return &user, nil
+}
+
+// getBackend 按认证来源查找后端，来源为空的历史数据视为本地用户
+func (s *AuthService) getBackend(source string) AuthBackend {
+	if source == "" {
+		source = models.AuthSourceLocal
+	}
+	for _, backend := range s.backends {
+		if backend.Name() == source {
+			return backend
+		}
+	}
+	return nil
+}
+
+// CheckCredentials 带失败锁定的用户名密码校验，Web登录与SSH网关共用
+// 被锁定或需要等待时返回 *LoginBlockedError，不再校验密码
+func (s *AuthService) CheckCredentials(username, password, clientIP string) (*models.User, error) {
+	if err := s.loginGuard.Check(username, clientIP); err != nil {
+		return nil, err
 	}
 
-	// 生成JWT token
-	token, err := utils.GenerateToken(&user)
+	user, err := s.Authenticate(username, password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate token: %w", err)
+		// 只有密码错误计入失败次数，账号禁用、目录不可用等不计入
+		if errors.Is(err, errInvalidCredentials) {
+			return nil, s.loginGuard.RecordFailure(username, clientIP, err)
+		}
+		return nil, err
+	}
+
+	s.loginGuard.RecordSuccess(username)
+
+	// 凭证正确但不在允许的时间或地址范围内
+	if err := s.CheckLoginPolicy(user, clientIP); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CheckLoginPolicy 校验访问策略是否允许用户当前从 clientIP 登录
+// 密码、公钥和单点登录在身份校验通过后都需要调用
+func (s *AuthService) CheckLoginPolicy(user *models.User, clientIP string) error {
+	return s.accessPolicy.CheckLogin(user, clientIP)
+}
+
+// Login 用户登录
+// 用户已绑定认证器或所属角色强制MFA时，只返回二次验证挑战
+func (s *AuthService) Login(request *models.UserLoginRequest, clientIP, userAgent string) (*LoginResult, error) {
+	// 通过认证后端校验用户名和密码
+	user, err := s.CheckCredentials(request.Username, request.Password, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueLogin(user, clientIP, userAgent)
+}
+
+// IsPasswordExpired 检查用户密码是否超过密码策略规定的有效期
+func (s *AuthService) IsPasswordExpired(user *models.User) (bool, error) {
+	return s.passwordPolicy.IsExpired(user)
+}
+
+// issueLogin 身份校验通过后签发访问令牌，需要二次验证时只签发预认证令牌
+// 密码登录与单点登录共用
+func (s *AuthService) issueLogin(user *models.User, clientIP, userAgent string) (*LoginResult, error) {
+	// 检查是否需要二次验证
+	mfaEnabled, err := s.mfaService.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled || user.RequiresMFA() {
+		challenge, err := s.issueMFAChallenge(user, !mfaEnabled)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, Challenge: challenge}, nil
+	}
+
+	token, err := s.generateLoginToken(user, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{User: user, Token: token}, nil
+}
+
+// generateLoginToken 生成访问令牌并登记登录会话
+// 密码已过期时只签发修改密码专用的短期令牌，修改成功后再换取正常令牌
+func (s *AuthService) generateLoginToken(user *models.User, clientIP, userAgent string) (*utils.TokenResponse, error) {
+	expired, err := s.passwordPolicy.IsExpired(user)
+	if err != nil {
+		return nil, err
 	}
 
+	var token *utils.TokenResponse
+	if expired {
+		token, err = utils.GeneratePurposeToken(user, utils.TokenPurposePasswordChange, passwordChangeTokenExpire)
+	} else {
+		token, err = utils.GenerateLoginToken(user, clientIP, userAgent)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
 	return token, nil
 }
 
+// VerifyMFA 登录二次验证，校验通过后签发访问令牌
+// 验证码错误时返回的结果中仍包含用户信息，便于记录登录日志
+func (s *AuthService) VerifyMFA(request *models.MFAVerifyRequest, clientIP, userAgent string) (*LoginResult, error) {
+	user, claims, err := s.getMFAUser(request.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	usedRecoveryCode, err := s.mfaService.Verify(user.ID, request.Code)
+	if err != nil {
+		return &LoginResult{User: user}, s.recordMFAFailure(request.MFAToken, claims, err)
+	}
+
+	token, err := s.completeMFALogin(user, request.MFAToken, claims, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{User: user, Token: token, UsedRecoveryCode: usedRecoveryCode}, nil
+}
+
+// BeginMFAEnrollment 角色强制MFA但用户未绑定时，使用预认证令牌开始绑定认证器
+func (s *AuthService) BeginMFAEnrollment(mfaToken string) (*models.MFASetupResponse, error) {
+	user, _, err := s.getMFAUser(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.mfaService.BeginSetup(user)
+}
+
+// CompleteMFAEnrollment 使用预认证令牌完成绑定，同时签发访问令牌
+func (s *AuthService) CompleteMFAEnrollment(request *models.MFAVerifyRequest, clientIP, userAgent string) (*LoginResult, error) {
+	user, claims, err := s.getMFAUser(request.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := s.mfaService.Enable(user.ID, request.Code)
+	if err != nil {
+		return &LoginResult{User: user}, s.recordMFAFailure(request.MFAToken, claims, err)
+	}
+
+	token, err := s.completeMFALogin(user, request.MFAToken, claims, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{User: user, Token: token, RecoveryCodes: codes.RecoveryCodes}, nil
+}
+
+// issueMFAChallenge 签发预认证令牌
+func (s *AuthService) issueMFAChallenge(user *models.User, enrollRequired bool) (*models.MFAChallengeResponse, error) {
+	token, err := utils.GeneratePurposeToken(user, utils.TokenPurposeMFA, mfaPreAuthExpire())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate mfa token: %w", err)
+	}
+
+	return &models.MFAChallengeResponse{
+		MFARequired:    true,
+		EnrollRequired: enrollRequired,
+		MFAToken:       token.AccessToken,
+		ExpiresIn:      token.ExpiresIn,
+	}, nil
+}
+
+// getMFAUser 校验预认证令牌并加载用户
+func (s *AuthService) getMFAUser(mfaToken string) (*models.User, *utils.Claims, error) {
+	claims, err := utils.ValidatePurposeToken(mfaToken, utils.TokenPurposeMFA)
+	if err != nil || utils.IsTokenBlacklisted(mfaToken) {
+		return nil, nil, errors.New("mfa token is invalid or expired, please login again")
+	}
+
+	var user models.User
+	if err := s.db.Preload("Roles.Permissions").Where("id = ?", claims.UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("user not found")
+		}
+		return nil, nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if !user.IsActive() {
+		return nil, nil, errors.New("user account is disabled")
+	}
+
+	return &user, claims, nil
+}
+
+// completeMFALogin 预认证令牌只能使用一次，验证通过后作废并签发访问令牌
+func (s *AuthService) completeMFALogin(user *models.User, mfaToken string, claims *utils.Claims, clientIP, userAgent string) (*utils.TokenResponse, error) {
+	if err := utils.BlacklistToken(mfaToken); err != nil {
+		return nil, fmt.Errorf("failed to revoke mfa token: %w", err)
+	}
+	utils.GetRedis().Del(utils.GetRedis().Context(), mfaAttemptsKey(claims.ID))
+
+	return s.generateLoginToken(user, clientIP, userAgent)
+}
+
+// recordMFAFailure 累计预认证令牌的验证失败次数，超过上限后作废令牌
+func (s *AuthService) recordMFAFailure(mfaToken string, claims *utils.Claims, cause error) error {
+	key := mfaAttemptsKey(claims.ID)
+	ctx := utils.GetRedis().Context()
+	attempts, err := utils.GetRedis().Incr(ctx, key).Result()
+	if err != nil {
+		return cause
+	}
+	utils.GetRedis().ExpireAt(ctx, key, claims.ExpiresAt.Time)
+
+	if attempts >= mfaMaxAttempts() {
+		utils.BlacklistToken(mfaToken)
+		return errors.New("too many invalid verification codes, please login again")
+	}
+	return cause
+}
+
+// mfaAttemptsKey 预认证令牌验证失败次数的Redis键
+func mfaAttemptsKey(jti string) string {
+	return "mfa:attempts:" + jti
+}
+
 // Logout 用户登出
 func (s *AuthService) Logout(tokenString string) error {
 	// 将token加入黑名单
@@ -121,14 +413,19 @@ func (s *AuthService) ChangePassword(userID uint, request *models.PasswordChange
 		return fmt.Errorf("failed to find user: %w", err)
 	}
 
+	// 外部目录用户的密码不在本地维护
+	if user.IsExternal() {
+		return fmt.Errorf("password of %s user must be changed in the directory", user.AuthSource)
+	}
+
 	// 验证旧密码
 	if !utils.CheckPassword(request.OldPassword, user.Password) {
 		return errors.New("invalid old password")
 	}
 
-	// 验证新密码强度
-	if !utils.ValidatePasswordStrength(request.NewPassword) {
-		return errors.New("password does not meet strength requirements")
+	// 按密码策略校验新密码
+	if err := s.passwordPolicy.Validate(&user, request.NewPassword); err != nil {
+		return err
 	}
 
 	// 哈希新密码
@@ -137,14 +434,36 @@ func (s *AuthService) ChangePassword(userID uint, request *models.PasswordChange
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	// 更新密码
-	if err := s.db.Model(&user).Update("password", hashedPassword).Error; err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	// 更新密码并记录历史
+	if err := s.passwordPolicy.SetPassword(user.ID, hashedPassword); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// ExchangePasswordChangeToken 密码过期用户修改密码后，作废受限令牌并签发正常的访问令牌
+func (s *AuthService) ExchangePasswordChangeToken(tokenString, clientIP, userAgent string) (*utils.TokenResponse, error) {
+	claims, err := utils.ValidatePurposeToken(tokenString, utils.TokenPurposePasswordChange)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password change token: %w", err)
+	}
+	if err := utils.BlacklistToken(tokenString); err != nil {
+		return nil, fmt.Errorf("failed to revoke password change token: %w", err)
+	}
+
+	user, err := s.loadActiveUser(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateLoginToken(user, clientIP, userAgent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	return token, nil
+}
+
 // ValidateToken 验证token
 func (s *AuthService) ValidateToken(tokenString string) (*models.User, error) {
 	return utils.GetUserFromToken(tokenString)
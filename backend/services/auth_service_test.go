@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const testLoginPassword = "Login-Pass-3vR8"
+
+// setupAuthService 使用模拟Redis初始化认证服务，创建本地用户 alice，并发登录上限为 maxConcurrent
+func setupAuthService(t *testing.T, maxConcurrent int) (*AuthService, *gorm.DB, *miniredis.Miniredis, *models.User) {
+	t.Helper()
+	setupTestConfig(t)
+	config.GlobalConfig.JWT = config.JWTConfig{Secret: "auth-test-secret", Expire: 3600, Issuer: "bastion-test"}
+	config.GlobalConfig.Security.LoginSession.MaxConcurrent = maxConcurrent
+
+	redisServer := miniredis.RunT(t)
+	utils.Redis = redis.NewClient(&redis.Options{Addr: redisServer.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { utils.Redis.Close() })
+
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+		&models.UserMFA{}, &models.UserMFARecoveryCode{}, &models.PasswordPolicy{}, &models.PasswordHistory{}, &models.AccessPolicy{})
+	hash, err := utils.HashPassword(testLoginPassword)
+	require.NoError(t, err)
+	user := &models.User{Username: "alice", Password: hash, Status: 1, AuthSource: models.AuthSourceLocal}
+	require.NoError(t, db.Create(user).Error)
+	return NewAuthService(db), db, redisServer, user
+}
+
+// loginAlice 使用正确的密码登录
+func loginAlice(t *testing.T, service *AuthService) *LoginResult {
+	t.Helper()
+	result, err := service.Login(&models.UserLoginRequest{Username: "alice", Password: testLoginPassword}, "192.0.2.10", "test-agent")
+	require.NoError(t, err)
+	return result
+}
+
+func TestLoginRegistersSessionAndEnforcesLimit(t *testing.T) {
+	service, _, _, user := setupAuthService(t, 2)
+
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		result := loginAlice(t, service)
+		require.NotNil(t, result.Token)
+		tokens = append(tokens, result.Token.AccessToken)
+	}
+
+	// 令牌签发后尚未使用也计入并发登录上限
+	sessions, err := utils.ListLoginSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, "192.0.2.10", sessions[0].IP)
+	require.Equal(t, "test-agent", sessions[0].UserAgent)
+	require.True(t, utils.IsTokenBlacklisted(tokens[0]))
+	require.False(t, utils.IsTokenBlacklisted(tokens[2]))
+}
+
+func TestLoginRefusedWhenSessionStoreUnavailable(t *testing.T) {
+	service, _, redisServer, _ := setupAuthService(t, 2)
+	redisServer.Close()
+
+	result, err := service.Login(&models.UserLoginRequest{Username: "alice", Password: testLoginPassword}, "192.0.2.10", "test-agent")
+	require.ErrorContains(t, err, "failed to register login session")
+	require.Nil(t, result)
+}
+
+func TestVerifyMFARegistersSessionAndEnforcesLimit(t *testing.T) {
+	service, _, _, user := setupAuthService(t, 1)
+	setup, err := service.mfaService.BeginSetup(user)
+	require.NoError(t, err)
+	code, err := totp.GenerateCode(setup.Secret, time.Now())
+	require.NoError(t, err)
+	codes, err := service.mfaService.Enable(user.ID, code)
+	require.NoError(t, err)
+
+	// 预认证令牌不是登录会话
+	first := loginAlice(t, service)
+	require.NotNil(t, first.Challenge)
+	sessions, err := utils.ListLoginSessions(user.ID)
+	require.NoError(t, err)
+	require.Empty(t, sessions)
+
+	verified, err := service.VerifyMFA(&models.MFAVerifyRequest{MFAToken: first.Challenge.MFAToken, Code: codes.RecoveryCodes[0]}, "192.0.2.10", "test-agent")
+	require.NoError(t, err)
+
+	second := loginAlice(t, service)
+	again, err := service.VerifyMFA(&models.MFAVerifyRequest{MFAToken: second.Challenge.MFAToken, Code: codes.RecoveryCodes[1]}, "192.0.2.11", "test-agent")
+	require.NoError(t, err)
+
+	sessions, err = utils.ListLoginSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, "192.0.2.11", sessions[0].IP)
+	require.True(t, utils.IsTokenBlacklisted(verified.Token.AccessToken))
+	require.False(t, utils.IsTokenBlacklisted(again.Token.AccessToken))
+}
@@ -386,6 +386,11 @@ func (s *CommandMatcherService) logFilterMatch(req *models.CommandMatchRequest,
 		return fmt.Errorf("get user and asset info failed: %w", err)
 	}
 	
+	command := req.Command
+	if req.LoggedCommand != "" {
+		command = req.LoggedCommand
+	}
+
 	// 创建日志记录
 	log := &models.CommandFilterLog{
 		SessionID:  fmt.Sprintf("session_%d_%d_%s", req.UserID, req.AssetID, time.Now().Format("20060102150405")),
@@ -394,7 +399,7 @@ func (s *CommandMatcherService) logFilterMatch(req *models.CommandMatchRequest,
 		AssetID:    req.AssetID,
 		AssetName:  result.AssetName,
 		Account:    req.Account,
-		Command:    req.Command,
+		Command:    command,
 		FilterID:   filter.ID,
 		FilterName: filter.Name,
 		Action:     filter.Action,
@@ -18,6 +18,8 @@ type ConnectivityService struct {
 	assetProvider  interfaces.AssetProvider
 	auditLogger    interfaces.AuditLogger
 	permChecker    interfaces.PermissionChecker
+	hostKeys       *HostKeyService
+	gateways       *GatewayChainService
 }
 
 // NewConnectivityService 创建新的连接服务实例
@@ -39,6 +41,16 @@ func (cs *ConnectivityService) SetDependencies(
 	cs.permChecker = permChecker
 }
 
+// SetHostKeyService 设置SSH主机密钥校验服务，未设置时SSH连接测试会被拒绝
+func (cs *ConnectivityService) SetHostKeyService(hostKeys *HostKeyService) {
+	cs.hostKeys = hostKeys
+}
+
+// SetGatewayChainService 设置网关链路服务，设置后SSH和端口连通性测试经资产的网关链路连接
+func (cs *ConnectivityService) SetGatewayChainService(gateways *GatewayChainService) {
+	cs.gateways = gateways
+}
+
 // TestConnection 统一连接测试入口
 func (cs *ConnectivityService) TestConnection(ctx context.Context, asset interfaces.Asset, credential interfaces.Credential) (*interfaces.ConnectionResult, error) {
 	startTime := time.Now()
@@ -63,6 +75,13 @@ func (cs *ConnectivityService) TestConnection(ctx context.Context, asset interfa
 				result.Message = "无权限访问"
 				return result, result.Error
 			}
+			if credential != nil {
+				if err := cs.ValidateCredentialAccess(ctx, userID, credential.GetID()); err != nil {
+					result.Error = err
+					result.Message = "无权限使用该凭证"
+					return result, err
+				}
+			}
 		}
 	}
 
@@ -357,6 +376,21 @@ func (cs *ConnectivityService) createSSHConfig(asset interfaces.Asset, credentia
 		Timeout:  utils.DefaultConnectTimeout,
 	}
 
+	// 校验资产主机密钥
+	if cs.hostKeys != nil {
+		algorithms, err := cs.hostKeys.Algorithms(asset.GetID())
+		if err != nil {
+			return nil, err
+		}
+		config.HostKeyCallback = cs.hostKeys.Callback(asset.GetID())
+		config.HostKeyAlgorithms = algorithms
+	}
+
+	// 资产配置了网关时经网关链路连接
+	if cs.gateways != nil {
+		config.Dial = cs.gateways.Dialer(asset.GetID(), config.Timeout)
+	}
+
 	// 处理密码认证
 	if credential.GetPassword() != "" {
 		config.Password = credential.GetPassword()
@@ -432,18 +466,28 @@ func (cs *ConnectivityService) testDatabaseConnection(asset interfaces.Asset, cr
 // testTelnetConnection 内部Telnet连接测试
 func (cs *ConnectivityService) testTelnetConnection(asset interfaces.Asset, credential interfaces.Credential) error {
 	// Telnet主要是TCP连接测试
-	return cs.connUtils.TestTCPConnection(asset.GetHost(), asset.GetPort(), utils.DefaultConnectTimeout)
+	return cs.testTCPConnection(asset)
 }
 
 // testVNCConnection 内部VNC连接测试
 func (cs *ConnectivityService) testVNCConnection(asset interfaces.Asset, credential interfaces.Credential) error {
 	// VNC主要是TCP连接测试
-	return cs.connUtils.TestTCPConnection(asset.GetHost(), asset.GetPort(), utils.DefaultConnectTimeout)
+	return cs.testTCPConnection(asset)
 }
 
-// testTCPConnection 内部TCP连接测试
+// testTCPConnection 内部TCP连接测试，资产配置了网关时经网关链路连接
 func (cs *ConnectivityService) testTCPConnection(asset interfaces.Asset) error {
-	return cs.connUtils.TestTCPConnection(asset.GetHost(), asset.GetPort(), utils.DefaultConnectTimeout)
+	if cs.gateways == nil {
+		return cs.connUtils.TestTCPConnection(asset.GetHost(), asset.GetPort(), utils.DefaultConnectTimeout)
+	}
+
+	address := cs.connUtils.FormatAddress(asset.GetHost(), asset.GetPort())
+	conn, err := cs.gateways.Dialer(asset.GetID(), utils.DefaultConnectTimeout)("tcp", address)
+	if err != nil {
+		return fmt.Errorf("TCP连接失败 %s: %v", address, err)
+	}
+	conn.Close()
+	return nil
 }
 
 // GetSupportedConnectionTypes 获取支持的连接类型
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectivityServiceChecksAssetPermission(t *testing.T) {
+	setupTestConfig(t)
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+		&models.UserGroup{}, &models.Asset{}, &models.AssetGroup{}, &models.Credential{}, &models.AssetCredential{},
+		&models.AssetPermission{}, &models.AssetHostKey{}, &models.NetworkZone{}, &models.OperationLog{})
+	GlobalServiceRegistry.RegisterPermissionChecker(NewAssetPermissionService(db))
+	t.Cleanup(func() { GlobalServiceRegistry.RegisterPermissionChecker(nil) })
+
+	port, accepted := newCountingListener(t)
+	encrypted, err := utils.EncryptPassword("Root-Pass-2kD5")
+	require.NoError(t, err)
+	credential := &models.Credential{Name: "root", Type: utils.CredentialTypePassword, Username: "root", Password: encrypted}
+	require.NoError(t, db.Create(credential).Error)
+	asset := &models.Asset{Name: "web-01", Type: "server", Protocol: "ssh", Address: "127.0.0.1", Port: port, Status: 1,
+		Credentials: []models.Credential{*credential}}
+	require.NoError(t, db.Create(asset).Error)
+	alice := &models.User{Username: "alice", Password: "hash", Status: 1, AuthSource: models.AuthSourceLocal}
+	require.NoError(t, db.Create(alice).Error)
+	bob := &models.User{Username: "bob", Password: "hash", Status: 1, AuthSource: models.AuthSourceLocal}
+	require.NoError(t, db.Create(bob).Error)
+	require.NoError(t, db.Create(&models.AssetPermission{Name: "web-01-alice", Enabled: true,
+		Users: []models.User{*alice}, Assets: []models.Asset{*asset}}).Error)
+
+	// 改密服务持有的连接服务使用注册的权限检查器
+	connectivity := NewCredentialRotationService(db).connectivity
+
+	// 未被授权的用户不能测试连接，也不会连接资产
+	ctx := context.WithValue(context.Background(), "user_id", bob.ID)
+	result, err := connectivity.TestConnection(ctx, &AssetWrapper{asset}, &CredentialWrapper{credential})
+	require.Error(t, err)
+	require.Equal(t, "无权限访问", result.Message)
+	time.Sleep(50 * time.Millisecond)
+	require.Zero(t, atomic.LoadInt32(accepted))
+
+	// 被授权的用户通过权限校验后才连接资产
+	ctx = context.WithValue(context.Background(), "user_id", alice.ID)
+	result, _ = connectivity.TestConnection(ctx, &AssetWrapper{asset}, &CredentialWrapper{credential})
+	require.NotEqual(t, "无权限访问", result.Message)
+	require.NotEqual(t, "无权限使用该凭证", result.Message)
+	require.Positive(t, atomic.LoadInt32(accepted))
+}
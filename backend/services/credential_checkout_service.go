@@ -0,0 +1,612 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CredentialCheckoutService 凭证借出服务
+// 用户申请借出凭证，按配置由审批人批准（申请人不能审批自己的申请）后，在借出有效期内可查看凭证明文，
+// 每次查看都通过 AuditLogger.LogCredentialAccess 记录，审计日志写入失败时拒绝查看。
+// 同一凭证同时只能借出给一个用户；归还或到期后自动改密，使借出期间查看过的明文失效。
+type CredentialCheckoutService struct {
+	db                *gorm.DB
+	cfg               config.CredentialCheckoutConfig
+	auditService      *AuditService
+	permissionService *AssetPermissionService
+	rotationService   *CredentialRotationService
+}
+
+// NewCredentialCheckoutService 创建凭证借出服务实例
+func NewCredentialCheckoutService(db *gorm.DB) *CredentialCheckoutService {
+	cfg := config.GlobalConfig.CredentialCheckout
+	if len(cfg.ApproverRoles) == 0 {
+		cfg.ApproverRoles = []string{"admin"}
+	}
+	if cfg.DefaultDuration <= 0 {
+		cfg.DefaultDuration = 3600
+	}
+	if cfg.MaxDuration <= 0 {
+		cfg.MaxDuration = 28800
+	}
+	if cfg.ApprovalTimeout <= 0 {
+		cfg.ApprovalTimeout = 86400
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 60
+	}
+
+	return &CredentialCheckoutService{
+		db:                db,
+		cfg:               cfg,
+		auditService:      NewAuditService(db),
+		permissionService: NewAssetPermissionService(db),
+		rotationService:   NewCredentialRotationService(db),
+	}
+}
+
+// CreateCheckout 申请借出凭证，不需要审批时立即借出，否则通知审批人
+func (s *CredentialCheckoutService) CreateCheckout(user *models.User, req *models.CredentialCheckoutCreateRequest, clientIP string) (*models.CredentialCheckoutResponse, error) {
+	duration := req.Duration
+	if duration == 0 {
+		duration = s.cfg.DefaultDuration
+	}
+	if duration > s.cfg.MaxDuration {
+		return nil, fmt.Errorf("%w: checkout duration exceeds the maximum of %d seconds", utils.ErrInvalidParam, s.cfg.MaxDuration)
+	}
+
+	credential, err := s.rotationService.loadCredential(req.CredentialID)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, fmt.Errorf("%w: credential does not exist", utils.ErrInvalidParam)
+		}
+		return nil, err
+	}
+	// 归还后必须能够改密，否则借出的明文将一直有效
+	if _, err := s.rotationService.rotatableAssets(credential); err != nil {
+		return nil, fmt.Errorf("%w (credentials that cannot be rotated automatically cannot be checked out)", err)
+	}
+	allowed, err := s.permissionService.CanAccessCredential(context.Background(), user.ID, credential.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: credential is not authorized for user %d", utils.ErrPermissionDenied, user.ID)
+	}
+
+	var active int64
+	if err := s.db.Model(&models.CredentialCheckout{}).
+		Where("credential_id = ? AND user_id = ? AND status IN ?", credential.ID, user.ID,
+			[]string{models.CheckoutStatusPending, models.CheckoutStatusCheckedOut}).
+		Count(&active).Error; err != nil {
+		return nil, fmt.Errorf("failed to query credential checkouts: %w", err)
+	}
+	if active > 0 {
+		return nil, fmt.Errorf("%w: you already have an active checkout for this credential", utils.ErrDuplicate)
+	}
+
+	checkout := &models.CredentialCheckout{
+		CredentialID: credential.ID,
+		UserID:       user.ID,
+		Reason:       req.Reason,
+		Duration:     duration,
+		Status:       models.CheckoutStatusPending,
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(checkout).Error; err != nil {
+			return fmt.Errorf("failed to create credential checkout: %w", err)
+		}
+		if s.cfg.RequireApproval {
+			return nil
+		}
+		return s.checkOut(tx, checkout, nil)
+	}); err != nil {
+		return nil, err
+	}
+
+	if s.cfg.RequireApproval {
+		s.recordTransition(user, clientIP, "create", checkout, fmt.Sprintf("申请借出凭证 #%d", checkout.CredentialID))
+		if approverIDs, err := s.getApproverIDs(user.ID); err != nil {
+			logrus.WithError(err).Warn("查询凭证借出审批人失败")
+		} else {
+			s.notify(approverIDs, "credential_checkout_pending", checkout)
+		}
+	} else {
+		checkout.Status = models.CheckoutStatusCheckedOut
+		s.recordTransition(user, clientIP, "create", checkout, fmt.Sprintf("借出凭证 #%d", checkout.CredentialID))
+	}
+
+	return s.GetCheckout(user, checkout.ID)
+}
+
+// GetMyCheckouts 获取用户自己的借出记录
+func (s *CredentialCheckoutService) GetMyCheckouts(userID uint, req *models.CredentialCheckoutListRequest) ([]*models.CredentialCheckoutResponse, int64, error) {
+	req.UserID = userID
+	return s.listCheckouts(req)
+}
+
+// GetApprovalCheckouts 审批人查看全部借出记录，可按状态、凭证和借出人过滤
+func (s *CredentialCheckoutService) GetApprovalCheckouts(approver *models.User, req *models.CredentialCheckoutListRequest) ([]*models.CredentialCheckoutResponse, int64, error) {
+	if !s.isApprover(approver) {
+		return nil, 0, fmt.Errorf("%w: user is not a credential checkout approver", utils.ErrPermissionDenied)
+	}
+	return s.listCheckouts(req)
+}
+
+// GetCheckout 获取借出详情，仅借出人和审批人可见
+func (s *CredentialCheckoutService) GetCheckout(user *models.User, id uint) (*models.CredentialCheckoutResponse, error) {
+	checkout, err := s.getCheckout(id)
+	if err != nil {
+		return nil, err
+	}
+	if checkout.UserID != user.ID && !s.isApprover(user) {
+		return nil, utils.ErrNotFound
+	}
+	return checkout.ToResponse(), nil
+}
+
+// ApproveCheckout 批准借出申请，凭证已借出给其他用户时需等待归还
+func (s *CredentialCheckoutService) ApproveCheckout(approver *models.User, id uint, comment, clientIP string) (*models.CredentialCheckoutResponse, error) {
+	checkout, err := s.getDecidableCheckout(approver, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return s.checkOut(tx, checkout, map[string]interface{}{
+			"approver_id":      approver.ID,
+			"approver_name":    approver.Username,
+			"approval_comment": comment,
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	checkout.Status = models.CheckoutStatusCheckedOut
+	s.recordTransition(approver, clientIP, "approve", checkout, fmt.Sprintf("批准凭证借出 #%d", checkout.ID))
+	s.notify([]uint{checkout.UserID}, "credential_checkout_approved", checkout)
+
+	return s.GetCheckout(approver, id)
+}
+
+// RejectCheckout 拒绝借出申请
+func (s *CredentialCheckoutService) RejectCheckout(approver *models.User, id uint, comment, clientIP string) (*models.CredentialCheckoutResponse, error) {
+	checkout, err := s.getDecidableCheckout(approver, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.transition(s.db, checkout.ID, []string{models.CheckoutStatusPending}, map[string]interface{}{
+		"status":           models.CheckoutStatusRejected,
+		"approver_id":      approver.ID,
+		"approver_name":    approver.Username,
+		"approval_comment": comment,
+		"closed_at":        time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+
+	checkout.Status = models.CheckoutStatusRejected
+	s.recordTransition(approver, clientIP, "reject", checkout, fmt.Sprintf("拒绝凭证借出 #%d", checkout.ID))
+	s.notify([]uint{checkout.UserID}, "credential_checkout_rejected", checkout)
+
+	return s.GetCheckout(approver, id)
+}
+
+// CancelCheckout 申请人撤回待审批的借出申请，已借出的凭证需要归还
+func (s *CredentialCheckoutService) CancelCheckout(user *models.User, id uint, clientIP string) (*models.CredentialCheckoutResponse, error) {
+	checkout, err := s.getCheckout(id)
+	if err != nil {
+		return nil, err
+	}
+	if checkout.UserID != user.ID {
+		return nil, utils.ErrNotFound
+	}
+	if checkout.Status != models.CheckoutStatusPending {
+		return nil, fmt.Errorf("%w: credential checkout is already %s", utils.ErrInvalidParam, checkout.Status)
+	}
+
+	if err := s.transition(s.db, checkout.ID, []string{models.CheckoutStatusPending}, map[string]interface{}{
+		"status":    models.CheckoutStatusCancelled,
+		"closed_at": time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+
+	checkout.Status = models.CheckoutStatusCancelled
+	s.recordTransition(user, clientIP, "cancel", checkout, fmt.Sprintf("撤回凭证借出 #%d", checkout.ID))
+
+	return s.GetCheckout(user, id)
+}
+
+// CheckIn 归还凭证并在后台改密，借出人或审批人都可以归还
+func (s *CredentialCheckoutService) CheckIn(user *models.User, id uint, clientIP string) (*models.CredentialCheckoutResponse, error) {
+	checkout, err := s.getCheckout(id)
+	if err != nil {
+		return nil, err
+	}
+	if checkout.UserID != user.ID && !s.isApprover(user) {
+		return nil, utils.ErrNotFound
+	}
+	if checkout.Status != models.CheckoutStatusCheckedOut {
+		return nil, fmt.Errorf("%w: credential checkout is %s", utils.ErrInvalidParam, checkout.Status)
+	}
+
+	if err := s.close(checkout, models.CheckoutStatusCheckedIn); err != nil {
+		return nil, err
+	}
+	s.recordTransition(user, clientIP, "checkin", checkout, fmt.Sprintf("归还凭证借出 #%d", checkout.ID))
+	go s.rotateAfterReturn(checkout, models.RotationTriggerCheckin, user, clientIP)
+
+	return s.GetCheckout(user, id)
+}
+
+// RevealSecret 借出人在有效期内查看凭证明文，审计日志写入成功后才返回明文
+func (s *CredentialCheckoutService) RevealSecret(user *models.User, id uint, clientIP string) (*models.CredentialSecretResponse, error) {
+	checkout, err := s.getCheckout(id)
+	if err != nil {
+		return nil, err
+	}
+	if checkout.UserID != user.ID {
+		if s.isApprover(user) {
+			return nil, fmt.Errorf("%w: only the user who checked out the credential can reveal it", utils.ErrPermissionDenied)
+		}
+		return nil, utils.ErrNotFound
+	}
+	if checkout.Status != models.CheckoutStatusCheckedOut {
+		return nil, fmt.Errorf("%w: credential checkout is %s", utils.ErrInvalidParam, checkout.Status)
+	}
+	if checkout.ExpiresAt == nil || !checkout.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: credential checkout has expired", utils.ErrInvalidParam)
+	}
+
+	credential := &checkout.Credential
+	secret := &models.CredentialSecretResponse{
+		CheckoutID:   checkout.ID,
+		CredentialID: credential.ID,
+		Type:         credential.Type,
+		Username:     credential.Username,
+		ExpiresAt:    *checkout.ExpiresAt,
+	}
+	if credential.Password != "" {
+		if secret.Password, err = utils.DecryptPassword(credential.Password); err != nil {
+			return nil, fmt.Errorf("failed to decrypt credential password: %w", err)
+		}
+	}
+	if credential.PrivateKey != "" {
+		if secret.PrivateKey, err = utils.DecryptPrivateKey(credential.PrivateKey); err != nil {
+			return nil, fmt.Errorf("failed to decrypt credential private key: %w", err)
+		}
+	}
+
+	logger := auditLogger()
+	if logger == nil {
+		return nil, errors.New("audit logger is not registered, credential reveal is disabled")
+	}
+
+	// 校验状态与累计查看次数在同一条条件更新中完成：与归还并发时，要么归还后的改密能看到本次查看，
+	// 要么本次查看因借出已结束而被拒绝，不会出现已查看明文却跳过改密
+	now := time.Now()
+	result := s.db.Model(&models.CredentialCheckout{}).
+		Where("id = ? AND status = ? AND expires_at > ?", checkout.ID, models.CheckoutStatusCheckedOut, now).
+		Updates(map[string]interface{}{
+			"reveal_count":     gorm.Expr("reveal_count + 1"),
+			"last_revealed_at": now,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to record credential reveal: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: credential checkout is no longer checked out", utils.ErrInvalidParam)
+	}
+
+	if err := logger.LogCredentialAccess(WithAuditClientIP(context.Background(), clientIP), user.ID, credential.ID, "reveal"); err != nil {
+		return nil, fmt.Errorf("failed to audit credential reveal: %w", err)
+	}
+	return secret, nil
+}
+
+// StartExpiryCheck 定时处理到期的借出和超时未审批的申请
+func (s *CredentialCheckoutService) StartExpiryCheck(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.CheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.ExpireCheckouts()
+			if err != nil {
+				logrus.WithError(err).Error("凭证借出到期检查失败")
+				continue
+			}
+			if expired > 0 {
+				logrus.WithField("expired", expired).Info("凭证借出已到期")
+			}
+		}
+	}
+}
+
+// ExpireCheckouts 将到期的借出标记为过期并改密，超时未审批的申请一并过期，返回处理的记录数
+func (s *CredentialCheckoutService) ExpireCheckouts() (int, error) {
+	now := time.Now()
+	var checkouts []models.CredentialCheckout
+	if err := s.db.Where("(status = ? AND expires_at <= ?) OR (status = ? AND created_at <= ?)",
+		models.CheckoutStatusCheckedOut, now,
+		models.CheckoutStatusPending, now.Add(-time.Duration(s.cfg.ApprovalTimeout)*time.Second)).
+		Find(&checkouts).Error; err != nil {
+		return 0, fmt.Errorf("failed to query expired credential checkouts: %w", err)
+	}
+
+	expired := 0
+	for i := range checkouts {
+		checkout := &checkouts[i]
+		previous := checkout.Status
+		if err := s.close(checkout, models.CheckoutStatusExpired); err != nil {
+			logrus.WithError(err).WithField("checkout_id", checkout.ID).Warn("凭证借出过期处理失败")
+			continue
+		}
+		expired++
+
+		s.recordTransition(nil, "", "expire", checkout, fmt.Sprintf("凭证借出 #%d 已到期", checkout.ID))
+		s.notify([]uint{checkout.UserID}, "credential_checkout_expired", checkout)
+		if previous == models.CheckoutStatusCheckedOut {
+			s.rotateAfterReturn(checkout, models.RotationTriggerCheckoutExpiry, nil, "")
+		}
+	}
+	return expired, nil
+}
+
+// checkOut 将待审批的申请置为已借出，锁定凭证行保证同一凭证同时只借出给一个用户
+func (s *CredentialCheckoutService) checkOut(tx *gorm.DB, checkout *models.CredentialCheckout, updates map[string]interface{}) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		First(&models.Credential{}, checkout.CredentialID).Error; err != nil {
+		return fmt.Errorf("failed to lock credential: %w", err)
+	}
+
+	var checkedOut int64
+	if err := tx.Model(&models.CredentialCheckout{}).
+		Where("credential_id = ? AND status = ? AND id <> ?", checkout.CredentialID, models.CheckoutStatusCheckedOut, checkout.ID).
+		Count(&checkedOut).Error; err != nil {
+		return fmt.Errorf("failed to query credential checkouts: %w", err)
+	}
+	if checkedOut > 0 {
+		return fmt.Errorf("%w: credential is checked out by another user", utils.ErrDuplicate)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(checkout.Duration) * time.Second)
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = models.CheckoutStatusCheckedOut
+	updates["checked_out_at"] = now
+	updates["expires_at"] = expiresAt
+	if err := s.transition(tx, checkout.ID, []string{models.CheckoutStatusPending}, updates); err != nil {
+		return err
+	}
+	checkout.CheckedOutAt, checkout.ExpiresAt = &now, &expiresAt
+	return nil
+}
+
+// close 结束借出，已借出的凭证将在之后改密
+func (s *CredentialCheckoutService) close(checkout *models.CredentialCheckout, status string) error {
+	updates := map[string]interface{}{
+		"status":    status,
+		"closed_at": time.Now(),
+	}
+	if checkout.Status == models.CheckoutStatusCheckedOut {
+		updates["rotation_status"] = models.CheckoutRotationPending
+	}
+	if err := s.transition(s.db, checkout.ID, []string{checkout.Status}, updates); err != nil {
+		return err
+	}
+	checkout.Status = status
+	return nil
+}
+
+// rotateAfterReturn 归还或到期后改密，借出期间未查看明文时无需改密；改密失败时告警，借出的明文可能仍然有效
+func (s *CredentialCheckoutService) rotateAfterReturn(checkout *models.CredentialCheckout, trigger string, operator *models.User, clientIP string) {
+	// 重新读取查看次数，避免与归还前最后一次查看并发
+	var current models.CredentialCheckout
+	if err := s.db.Select("id, reveal_count").First(&current, checkout.ID).Error; err != nil {
+		logrus.WithError(err).WithField("checkout_id", checkout.ID).Error("查询凭证借出失败")
+		return
+	}
+	if current.RevealCount == 0 {
+		s.db.Model(&current).Update("rotation_status", models.CheckoutRotationSkipped)
+		return
+	}
+
+	history, err := s.rotationService.rotate(checkout.CredentialID, trigger, operator, clientIP)
+	updates := map[string]interface{}{"rotation_status": models.RotationStatusFailed}
+	if history != nil {
+		updates["rotation_status"] = history.Status
+		updates["rotation_history_id"] = history.ID
+	}
+	s.db.Model(&current).Updates(updates)
+
+	if history != nil && history.Status == models.RotationStatusSuccess {
+		return
+	}
+	details := map[string]interface{}{
+		"checkout_id":   checkout.ID,
+		"credential_id": checkout.CredentialID,
+		"user_id":       checkout.UserID,
+		"trigger":       trigger,
+	}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	if history != nil {
+		details["rotation_status"] = history.Status
+	}
+	logrus.WithFields(logrus.Fields(details)).Error("凭证归还后改密失败，借出的明文可能仍然有效")
+	if sender := notifier(); sender != nil {
+		if err := sender.NotifySecurityEvent(context.Background(), "credential_checkout_rotation_failed", details); err != nil {
+			logrus.WithError(err).Warn("发送凭证借出改密告警失败")
+		}
+	}
+}
+
+// getDecidableCheckout 获取当前审批人可以审批的申请
+func (s *CredentialCheckoutService) getDecidableCheckout(approver *models.User, id uint) (*models.CredentialCheckout, error) {
+	if !s.isApprover(approver) {
+		return nil, fmt.Errorf("%w: user is not a credential checkout approver", utils.ErrPermissionDenied)
+	}
+
+	checkout, err := s.getCheckout(id)
+	if err != nil {
+		return nil, err
+	}
+	if checkout.UserID == approver.ID {
+		return nil, fmt.Errorf("%w: cannot approve your own credential checkout", utils.ErrPermissionDenied)
+	}
+	if checkout.Status != models.CheckoutStatusPending {
+		return nil, fmt.Errorf("%w: credential checkout is already %s", utils.ErrInvalidParam, checkout.Status)
+	}
+	return checkout, nil
+}
+
+// transition 仅当借出记录仍处于 from 中的状态时更新，避免并发审批、归还或到期检查重复处理
+func (s *CredentialCheckoutService) transition(tx *gorm.DB, id uint, from []string, updates map[string]interface{}) error {
+	result := tx.Model(&models.CredentialCheckout{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update credential checkout: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: credential checkout status has changed", utils.ErrInvalidParam)
+	}
+	return nil
+}
+
+// listCheckouts 分页查询借出记录
+func (s *CredentialCheckoutService) listCheckouts(req *models.CredentialCheckoutListRequest) ([]*models.CredentialCheckoutResponse, int64, error) {
+	var checkouts []models.CredentialCheckout
+	var total int64
+
+	query := s.db.Model(&models.CredentialCheckout{})
+	if req.UserID != 0 {
+		query = query.Where("user_id = ?", req.UserID)
+	}
+	if req.CredentialID != 0 {
+		query = query.Where("credential_id = ?", req.CredentialID)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count credential checkouts: %w", err)
+	}
+
+	if req.Page > 0 && req.PageSize > 0 {
+		query = query.Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize)
+	}
+
+	if err := query.Preload("User").Preload("Credential").Order("id DESC").Find(&checkouts).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query credential checkouts: %w", err)
+	}
+
+	responses := make([]*models.CredentialCheckoutResponse, len(checkouts))
+	for i := range checkouts {
+		responses[i] = checkouts[i].ToResponse()
+	}
+	return responses, total, nil
+}
+
+// getCheckout 加载借出记录及借出人、凭证
+func (s *CredentialCheckoutService) getCheckout(id uint) (*models.CredentialCheckout, error) {
+	var checkout models.CredentialCheckout
+	if err := s.db.Preload("User").Preload("Credential").First(&checkout, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query credential checkout: %w", err)
+	}
+	return &checkout, nil
+}
+
+// isApprover 用户是否拥有审批角色
+func (s *CredentialCheckoutService) isApprover(user *models.User) bool {
+	for _, role := range s.cfg.ApproverRoles {
+		if user.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// getApproverIDs 获取除申请人外所有启用状态的审批人
+func (s *CredentialCheckoutService) getApproverIDs(requesterID uint) ([]uint, error) {
+	var ids []uint
+	err := s.db.Model(&models.User{}).
+		Joins("JOIN user_roles ur ON ur.user_id = users.id").
+		Joins("JOIN roles r ON r.id = ur.role_id").
+		Where("r.name IN ? AND users.status = ? AND users.id <> ?", s.cfg.ApproverRoles, 1, requesterID).
+		Distinct().Pluck("users.id", &ids).Error
+	return ids, err
+}
+
+// recordTransition 记录借出状态变化的操作日志，operator 为nil表示系统自动处理
+func (s *CredentialCheckoutService) recordTransition(operator *models.User, clientIP, action string, checkout *models.CredentialCheckout, message string) {
+	userID, username, method := uint(0), "system", "SYSTEM"
+	if operator != nil {
+		userID, username, method = operator.ID, operator.Username, "POST"
+	}
+	url := fmt.Sprintf("/api/v1/credential-checkouts/%d/%s", checkout.ID, action)
+	if action == "create" {
+		url = "/api/v1/credential-checkouts"
+	}
+
+	go s.auditService.RecordOperationLog(
+		userID,
+		username,
+		clientIP,
+		method,
+		url,
+		action,
+		"credential_checkout",
+		checkout.ID,
+		"",
+		200,
+		message,
+		map[string]interface{}{
+			"user_id":       checkout.UserID,
+			"credential_id": checkout.CredentialID,
+			"duration":      checkout.Duration,
+			"expires_at":    checkout.ExpiresAt,
+		},
+		map[string]interface{}{"status": checkout.Status},
+		0,
+		false,
+	)
+}
+
+// notify 推送借出状态通知
+func (s *CredentialCheckoutService) notify(userIDs []uint, eventType string, checkout *models.CredentialCheckout) {
+	sender := notifier()
+	if sender == nil || len(userIDs) == 0 {
+		return
+	}
+	if err := sender.NotifyUsers(context.Background(), userIDs, eventType, map[string]interface{}{
+		"checkout_id":   checkout.ID,
+		"user_id":       checkout.UserID,
+		"credential_id": checkout.CredentialID,
+		"duration":      checkout.Duration,
+		"expires_at":    checkout.ExpiresAt,
+		"status":        checkout.Status,
+	}); err != nil {
+		logrus.WithError(err).Warn("发送凭证借出通知失败")
+	}
+}
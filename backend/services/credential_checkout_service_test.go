@@ -0,0 +1,79 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// setupCheckoutService 创建凭证借出服务和一条已借出给 alice 的密码凭证
+func setupCheckoutService(t *testing.T) (*CredentialCheckoutService, *gorm.DB, *models.User, *models.CredentialCheckout) {
+	t.Helper()
+	setupTestConfig(t)
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+		&models.Credential{}, &models.CredentialCheckout{}, &models.OperationLog{})
+	GlobalServiceRegistry.RegisterAuditLogger(NewAuditLoggerService(db))
+	t.Cleanup(func() { GlobalServiceRegistry.RegisterAuditLogger(nil) })
+
+	user := &models.User{Username: "alice", Password: "hash", Status: 1, AuthSource: models.AuthSourceLocal}
+	require.NoError(t, db.Create(user).Error)
+	encrypted, err := utils.EncryptPassword("Checkout-Pass-6tY1")
+	require.NoError(t, err)
+	credential := &models.Credential{Name: "root", Type: utils.CredentialTypePassword, Username: "root", Password: encrypted}
+	require.NoError(t, db.Create(credential).Error)
+
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	checkout := &models.CredentialCheckout{CredentialID: credential.ID, UserID: user.ID, Reason: "incident", Duration: 3600,
+		Status: models.CheckoutStatusCheckedOut, CheckedOutAt: &now, ExpiresAt: &expiresAt}
+	require.NoError(t, db.Create(checkout).Error)
+	return NewCredentialCheckoutService(db), db, user, checkout
+}
+
+func TestRevealSecretCountsRevealBeforeReturningSecret(t *testing.T) {
+	service, db, user, checkout := setupCheckoutService(t)
+
+	secret, err := service.RevealSecret(user, checkout.ID, "192.0.2.10")
+	require.NoError(t, err)
+	require.Equal(t, "Checkout-Pass-6tY1", secret.Password)
+
+	var current models.CredentialCheckout
+	require.NoError(t, db.First(&current, checkout.ID).Error)
+	require.Equal(t, 1, current.RevealCount)
+	require.NotNil(t, current.LastRevealedAt)
+	var audits int64
+	require.NoError(t, db.Model(&models.OperationLog{}).Where("action = ?", "reveal").Count(&audits).Error)
+	require.EqualValues(t, 1, audits)
+}
+
+func TestRevealSecretRefusedWhenCheckedInConcurrently(t *testing.T) {
+	service, db, user, checkout := setupCheckoutService(t)
+
+	// 在读取借出记录之后、累计查看次数之前归还，模拟与 CheckIn 并发
+	checkedIn := false
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:concurrent_checkin", func(tx *gorm.DB) {
+		if checkedIn || tx.Statement.Table != "credential_checkouts" {
+			return
+		}
+		checkedIn = true
+		require.NoError(t, db.Exec("UPDATE credential_checkouts SET status = ? WHERE id = ?", models.CheckoutStatusCheckedIn, checkout.ID).Error)
+	}))
+
+	secret, err := service.RevealSecret(user, checkout.ID, "192.0.2.10")
+	require.ErrorIs(t, err, utils.ErrInvalidParam)
+	require.ErrorContains(t, err, "no longer checked out")
+	require.Nil(t, secret)
+	require.True(t, checkedIn)
+
+	// 未计入查看次数也未写审计日志，归还后的改密可以安全跳过
+	var current models.CredentialCheckout
+	require.NoError(t, db.First(&current, checkout.ID).Error)
+	require.Zero(t, current.RevealCount)
+	var audits int64
+	require.NoError(t, db.Model(&models.OperationLog{}).Where("action = ?", "reveal").Count(&audits).Error)
+	require.Zero(t, audits)
+}
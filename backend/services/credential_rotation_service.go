@@ -0,0 +1,835 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// 生成新密码使用的字符，不包含 chpasswd 的分隔符和需要转义的字符
+const (
+	rotationLowerChars  = "abcdefghijkmnopqrstuvwxyz"
+	rotationUpperChars  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	rotationDigitChars  = "23456789"
+	rotationSymbolChars = "!@#%^*-_=+"
+)
+
+// CredentialRotationService 凭证自动改密服务
+// 通过SSH登录凭证关联的Linux资产修改账号密码或轮换 authorized_keys 中的公钥，用新凭证验证登录成功后才更新凭证；
+// 任一资产失败时，已修改的资产恢复为原密码或移除新公钥。
+type CredentialRotationService struct {
+	db           *gorm.DB
+	cfg          config.CredentialRotationConfig
+	auditService *AuditService
+	hostKeys     *HostKeyService
+	gateways     *GatewayChainService
+	connectivity *ConnectivityService
+}
+
+// NewCredentialRotationService 创建凭证改密服务实例
+func NewCredentialRotationService(db *gorm.DB) *CredentialRotationService {
+	cfg := config.GlobalConfig.CredentialRotation
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = 300
+	}
+	if cfg.PasswordLength <= 0 {
+		cfg.PasswordLength = 24
+	}
+	if cfg.CommandTimeout <= 0 {
+		cfg.CommandTimeout = 30
+	}
+
+	hostKeys := NewHostKeyService(db)
+	gateways := NewGatewayChainService(db)
+	connectivity := NewConnectivityService()
+	connectivity.SetDependencies(GlobalServiceRegistry.GetAssetProvider(), auditLogger(), GlobalServiceRegistry.GetPermissionChecker())
+	connectivity.SetHostKeyService(hostKeys)
+	connectivity.SetGatewayChainService(gateways)
+
+	return &CredentialRotationService{
+		db:           db,
+		cfg:          cfg,
+		auditService: NewAuditService(db),
+		hostKeys:     hostKeys,
+		gateways:     gateways,
+		connectivity: connectivity,
+	}
+}
+
+// ======================== 改密策略 ========================
+
+// GetPolicy 获取凭证的改密策略
+func (s *CredentialRotationService) GetPolicy(credentialID uint) (*models.CredentialRotationPolicy, error) {
+	var policy models.CredentialRotationPolicy
+	if err := s.db.Where("credential_id = ?", credentialID).First(&policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query rotation policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// SetPolicy 创建或更新凭证的改密策略，下次改密时间从上次改密（或现在）起算
+func (s *CredentialRotationService) SetPolicy(credentialID uint, req *models.CredentialRotationPolicyRequest) (*models.CredentialRotationPolicy, error) {
+	credential, err := s.loadCredential(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.rotatableAssets(credential); err != nil {
+		return nil, err
+	}
+
+	var policy models.CredentialRotationPolicy
+	if err := s.db.Where("credential_id = ?", credentialID).First(&policy).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to query rotation policy: %w", err)
+		}
+		policy = models.CredentialRotationPolicy{CredentialID: credentialID, Enabled: true}
+	}
+
+	policy.IntervalDays = req.IntervalDays
+	if req.PasswordLength > 0 {
+		policy.PasswordLength = req.PasswordLength
+	} else if policy.PasswordLength == 0 {
+		policy.PasswordLength = s.cfg.PasswordLength
+	}
+	if req.KeyType != "" {
+		policy.KeyType = req.KeyType
+	}
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	}
+
+	base := time.Now()
+	if policy.LastRunAt != nil {
+		base = *policy.LastRunAt
+	}
+	next := base.AddDate(0, 0, policy.IntervalDays)
+	policy.NextRunAt = &next
+
+	if err := s.db.Save(&policy).Error; err != nil {
+		return nil, fmt.Errorf("failed to save rotation policy: %w", err)
+	}
+	// 显式更新布尔字段，避免gorm忽略false零值后使用默认值
+	if !policy.Enabled {
+		if err := s.db.Model(&policy).Update("enabled", false).Error; err != nil {
+			return nil, fmt.Errorf("failed to save rotation policy: %w", err)
+		}
+	}
+	return &policy, nil
+}
+
+// DeletePolicy 删除凭证的改密策略
+func (s *CredentialRotationService) DeletePolicy(credentialID uint) error {
+	result := s.db.Where("credential_id = ?", credentialID).Delete(&models.CredentialRotationPolicy{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete rotation policy: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrNotFound
+	}
+	return nil
+}
+
+// GetHistory 获取凭证的改密记录
+func (s *CredentialRotationService) GetHistory(credentialID uint, req *models.CredentialRotationHistoryRequest) ([]models.CredentialRotationHistory, int64, error) {
+	var histories []models.CredentialRotationHistory
+	var total int64
+
+	query := s.db.Model(&models.CredentialRotationHistory{}).Where("credential_id = ?", credentialID)
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count rotation history: %w", err)
+	}
+	if req.Page > 0 && req.PageSize > 0 {
+		query = query.Offset((req.Page - 1) * req.PageSize).Limit(req.PageSize)
+	}
+	if err := query.Order("id DESC").Find(&histories).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query rotation history: %w", err)
+	}
+	return histories, total, nil
+}
+
+// ======================== 改密执行 ========================
+
+// RotateNow 立即为凭证改密
+func (s *CredentialRotationService) RotateNow(credentialID uint, operator *models.User, clientIP string) (*models.CredentialRotationHistory, error) {
+	return s.rotate(credentialID, models.RotationTriggerManual, operator, clientIP)
+}
+
+// StartScheduler 定时执行到期的改密策略
+func (s *CredentialRotationService) StartScheduler(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(s.cfg.CheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rotated, err := s.RunDuePolicies()
+			if err != nil {
+				logrus.WithError(err).Error("定时改密检查失败")
+				continue
+			}
+			if rotated > 0 {
+				logrus.WithField("credentials", rotated).Info("定时改密完成")
+			}
+		}
+	}
+}
+
+// RunDuePolicies 执行所有到期的改密策略，返回执行的凭证数
+func (s *CredentialRotationService) RunDuePolicies() (int, error) {
+	var policies []models.CredentialRotationPolicy
+	if err := s.db.Where("enabled = ? AND next_run_at <= ?", true, time.Now()).Find(&policies).Error; err != nil {
+		return 0, fmt.Errorf("failed to query due rotation policies: %w", err)
+	}
+
+	rotated := 0
+	for _, policy := range policies {
+		// 借出期间不定时改密，避免借出人拿到的密码失效，归还或到期后会立即改密
+		var checkedOut int64
+		if err := s.db.Model(&models.CredentialCheckout{}).
+			Where("credential_id = ? AND status = ?", policy.CredentialID, models.CheckoutStatusCheckedOut).
+			Count(&checkedOut).Error; err != nil || checkedOut > 0 {
+			continue
+		}
+
+		history, err := s.rotate(policy.CredentialID, models.RotationTriggerScheduled, nil, "")
+		if err != nil && history == nil {
+			logrus.WithError(err).WithField("credential_id", policy.CredentialID).Warn("定时改密未执行")
+			continue
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// rotate 执行一次改密，进入执行阶段后无论成败都返回改密记录
+func (s *CredentialRotationService) rotate(credentialID uint, trigger string, operator *models.User, clientIP string) (*models.CredentialRotationHistory, error) {
+	credential, err := s.loadCredential(credentialID)
+	if err != nil {
+		return nil, err
+	}
+	assets, err := s.rotatableAssets(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一凭证同时只能有一个改密任务
+	ctx := context.Background()
+	lockKey := fmt.Sprintf("credential:rotation:%d", credentialID)
+	locked, err := utils.GetRedis().SetNX(ctx, lockKey, time.Now().Unix(), 10*time.Minute).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire rotation lock: %w", err)
+	}
+	if !locked {
+		return nil, fmt.Errorf("%w: credential rotation is already in progress", utils.ErrDuplicate)
+	}
+	defer utils.GetRedis().Del(ctx, lockKey)
+
+	// 没有改密策略时使用默认配置
+	policy, _ := s.GetPolicy(credentialID)
+
+	history := &models.CredentialRotationHistory{
+		CredentialID: credentialID,
+		Trigger:      trigger,
+		StartedAt:    time.Now(),
+		Results:      make([]models.CredentialRotationAssetResult, len(assets)),
+	}
+	for i, asset := range assets {
+		history.Results[i] = models.CredentialRotationAssetResult{
+			AssetID:   asset.ID,
+			AssetName: asset.Name,
+			Status:    models.RotationAssetSkipped,
+		}
+	}
+	if operator != nil {
+		history.OperatorID = &operator.ID
+		history.OperatorName = operator.Username
+	}
+
+	var changed []int
+	if credential.Type == utils.CredentialTypeKey {
+		changed, err = s.rotateKey(credential, assets, policy, history)
+	} else {
+		changed, err = s.rotatePassword(credential, assets, policy, history)
+	}
+	if err != nil && history.Status == "" {
+		history.Status = models.RotationStatusFailed
+		history.Message = err.Error()
+	}
+
+	changedAssets := make([]models.Asset, len(changed))
+	for i, index := range changed {
+		changedAssets[i] = assets[index]
+	}
+	history.AssetIDs = joinAssetIDs(changedAssets)
+	finishedAt := time.Now()
+	history.FinishedAt = &finishedAt
+
+	if err := s.db.Create(history).Error; err != nil {
+		logrus.WithError(err).WithField("credential_id", credentialID).Error("保存改密记录失败")
+	}
+	s.updatePolicyResult(credentialID, history)
+	s.recordResult(credential, history, operator, clientIP)
+
+	return history, err
+}
+
+// rotatePassword 逐个资产修改密码并用新密码验证登录，全部成功后才更新凭证，返回已改密的资产下标
+func (s *CredentialRotationService) rotatePassword(credential *models.Credential, assets []models.Asset, policy *models.CredentialRotationPolicy, history *models.CredentialRotationHistory) ([]int, error) {
+	currentPassword, err := utils.DecryptPassword(credential.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credential password: %w", err)
+	}
+	length := s.cfg.PasswordLength
+	if policy != nil && policy.PasswordLength > 0 {
+		length = policy.PasswordLength
+	}
+	newPassword, err := generateRotationPassword(length)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := utils.EncryptPassword(newPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt password: %w", err)
+	}
+
+	var changed []int
+	var rotateErr error
+	verifyCredential := &models.Credential{ID: credential.ID, Type: credential.Type, Username: credential.Username, Password: encrypted}
+	for i := range assets {
+		asset := &assets[i]
+		if err := s.changePassword(asset, credential.Username, currentPassword, newPassword); err != nil {
+			rotateErr = s.failAsset(history, i, "failed to change password", err)
+			break
+		}
+		changed = append(changed, i)
+		if err := s.verify(asset, verifyCredential); err != nil {
+			rotateErr = s.failAsset(history, i, "new password verification failed", err)
+			break
+		}
+		history.Results[i].Status = models.RotationAssetRotated
+	}
+
+	if rotateErr == nil {
+		if err := s.db.Model(&models.Credential{}).Where("id = ?", credential.ID).Updates(map[string]interface{}{
+			"password":    encrypted,
+			"secret_hint": utils.CredentialSecretHint(utils.CredentialTypePassword, newPassword, ""),
+		}).Error; err != nil {
+			rotateErr = fmt.Errorf("failed to update credential: %w", err)
+		}
+	}
+	if rotateErr != nil {
+		s.rollback(history, assets, changed, rotateErr, func(asset *models.Asset) error {
+			return s.restorePassword(asset, credential.Username, newPassword, currentPassword)
+		})
+		return changed, rotateErr
+	}
+
+	history.Status = models.RotationStatusSuccess
+	history.Message = fmt.Sprintf("password rotated on %d asset(s)", len(changed))
+	return changed, nil
+}
+
+// rotateKey 生成新密钥，用当前密钥把新公钥追加到各资产的 authorized_keys 并验证新密钥登录，
+// 全部成功后更新凭证，再用新密钥删除旧公钥，返回已安装新公钥的资产下标。
+// 先更新凭证再删除旧公钥，避免凭证更新失败时保存的私钥已无法登录。
+func (s *CredentialRotationService) rotateKey(credential *models.Credential, assets []models.Asset, policy *models.CredentialRotationPolicy, history *models.CredentialRotationHistory) ([]int, error) {
+	currentPrivateKey, err := utils.DecryptPrivateKey(credential.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt current private key: %w", err)
+	}
+	currentSigner, err := ssh.ParsePrivateKey([]byte(currentPrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse current private key: %w", err)
+	}
+	keyType := utils.SSHKeyType(currentSigner.PublicKey())
+	if policy != nil && policy.KeyType != "" {
+		keyType = policy.KeyType
+	}
+	newPrivateKey, newPublicKey, err := utils.GenerateSSHKeyPair(keyType, fmt.Sprintf("bastion-credential-%d", credential.ID))
+	if err != nil {
+		return nil, err
+	}
+	newSigner, err := ssh.ParsePrivateKey([]byte(newPrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new private key: %w", err)
+	}
+	encrypted, err := utils.EncryptPassword(newPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+
+	var changed []int
+	var rotateErr error
+	verifyCredential := &models.Credential{ID: credential.ID, Type: credential.Type, Username: credential.Username, PrivateKey: encrypted}
+	for i := range assets {
+		asset := &assets[i]
+		if err := s.runCommand(asset, credential.Username, ssh.PublicKeys(currentSigner), addAuthorizedKeyCommand(newPublicKey)); err != nil {
+			rotateErr = s.failAsset(history, i, "failed to install new public key", err)
+			break
+		}
+		changed = append(changed, i)
+		if err := s.verify(asset, verifyCredential); err != nil {
+			rotateErr = s.failAsset(history, i, "new key verification failed", err)
+			break
+		}
+		history.Results[i].Status = models.RotationAssetRotated
+	}
+
+	if rotateErr == nil {
+		if err := s.db.Model(&models.Credential{}).Where("id = ?", credential.ID).Updates(map[string]interface{}{
+			"private_key": encrypted,
+			"secret_hint": ssh.FingerprintSHA256(newSigner.PublicKey()),
+		}).Error; err != nil {
+			rotateErr = fmt.Errorf("failed to update credential: %w", err)
+		}
+	}
+	if rotateErr != nil {
+		s.rollback(history, assets, changed, rotateErr, func(asset *models.Asset) error {
+			return s.runCommand(asset, credential.Username, ssh.PublicKeys(currentSigner), removeAuthorizedKeyCommand(newSigner.PublicKey()))
+		})
+		return changed, rotateErr
+	}
+
+	// 新密钥已在所有资产生效，删除旧公钥；失败不影响新密钥使用，但需人工清理
+	var remaining []string
+	for _, i := range changed {
+		asset := &assets[i]
+		if err := s.runCommand(asset, credential.Username, ssh.PublicKeys(newSigner), removeAuthorizedKeyCommand(currentSigner.PublicKey())); err != nil {
+			history.Results[i].Status = models.RotationAssetOldKeyRemaining
+			history.Results[i].Message = fmt.Sprintf("failed to remove old public key: %v", err)
+			remaining = append(remaining, asset.Name)
+		}
+	}
+	if len(remaining) > 0 {
+		history.Status = models.RotationStatusPartial
+		history.Message = fmt.Sprintf("key rotated on %d asset(s); failed to remove old public key on: %s", len(changed), strings.Join(remaining, ", "))
+		return changed, nil
+	}
+
+	history.Status = models.RotationStatusSuccess
+	history.Message = fmt.Sprintf("key rotated on %d asset(s)", len(changed))
+	return changed, nil
+}
+
+// rotatableAssets 只有密码和密钥凭证可以自动改密，关联的资产都必须是可通过SSH登录的Linux主机
+func (s *CredentialRotationService) rotatableAssets(credential *models.Credential) ([]models.Asset, error) {
+	if credential.Type != utils.CredentialTypePassword && credential.Type != utils.CredentialTypeKey {
+		return nil, fmt.Errorf("%w: only password and key credentials can be rotated", utils.ErrInvalidParam)
+	}
+	if len(credential.Assets) == 0 {
+		return nil, fmt.Errorf("%w: credential is not associated with any asset", utils.ErrInvalidParam)
+	}
+	for _, asset := range credential.Assets {
+		if asset.Type != "server" || asset.Protocol != "ssh" || !strings.EqualFold(asset.OsType, "linux") {
+			return nil, fmt.Errorf("%w: asset %s does not support automatic credential rotation (linux ssh servers only)",
+				utils.ErrInvalidParam, asset.Name)
+		}
+	}
+	return credential.Assets, nil
+}
+
+// changePassword 登录资产修改账号密码，root 使用 chpasswd，其它账号通过 passwd 修改自己的密码
+func (s *CredentialRotationService) changePassword(asset *models.Asset, username, currentPassword, newPassword string) error {
+	client, err := s.dial(asset, username, passwordAuth(currentPassword)...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	timeout := time.Duration(s.cfg.CommandTimeout) * time.Second
+	if username == "root" {
+		return runRemoteCommand(client, "chpasswd", username+":"+newPassword+"\n", timeout)
+	}
+	return runPasswd(client, currentPassword, newPassword, timeout)
+}
+
+// runCommand 登录资产执行一条命令
+func (s *CredentialRotationService) runCommand(asset *models.Asset, username string, auth ssh.AuthMethod, command string) error {
+	client, err := s.dial(asset, username, auth)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return runRemoteCommand(client, command, "", time.Duration(s.cfg.CommandTimeout)*time.Second)
+}
+
+// verify 使用新凭证测试登录
+func (s *CredentialRotationService) verify(asset *models.Asset, credential *models.Credential) error {
+	result, err := s.connectivity.TestSSHConnection(context.Background(), &AssetWrapper{asset}, &CredentialWrapper{credential})
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.New(result.Message)
+	}
+	return nil
+}
+
+// failAsset 记录资产失败原因，返回带资产名称的错误
+func (s *CredentialRotationService) failAsset(history *models.CredentialRotationHistory, index int, reason string, err error) error {
+	history.Results[index].Status = models.RotationAssetFailed
+	history.Results[index].Message = fmt.Sprintf("%s: %v", reason, err)
+	return fmt.Errorf("asset %s: %s: %w", history.Results[index].AssetName, reason, err)
+}
+
+// rollback 恢复已修改的资产，并根据恢复结果设置改密记录状态
+func (s *CredentialRotationService) rollback(history *models.CredentialRotationHistory, assets []models.Asset, changed []int, rotateErr error, restore func(asset *models.Asset) error) {
+	if len(changed) == 0 {
+		history.Status = models.RotationStatusFailed
+		history.Message = rotateErr.Error()
+		return
+	}
+
+	var failed []string
+	for _, i := range changed {
+		result := &history.Results[i]
+		if err := restore(&assets[i]); err != nil {
+			logrus.WithError(err).WithField("asset_id", assets[i].ID).Error("改密回滚失败")
+			result.Status = models.RotationAssetRollbackFailed
+			result.Message = strings.TrimPrefix(result.Message+"; ", "; ") + fmt.Sprintf("rollback failed: %v", err)
+			failed = append(failed, assets[i].Name)
+			continue
+		}
+		result.Status = models.RotationAssetRolledBack
+	}
+
+	if len(failed) == 0 {
+		history.Status = models.RotationStatusRolledBack
+		history.Message = rotateErr.Error() + "; changes rolled back"
+		return
+	}
+	history.Status = models.RotationStatusRollbackFailed
+	history.Message = fmt.Sprintf("%s; rollback failed on: %s", rotateErr.Error(), strings.Join(failed, ", "))
+}
+
+// restorePassword 将资产恢复为原密码，新密码无法登录时确认原密码是否仍然有效（改密命令可能未生效）
+func (s *CredentialRotationService) restorePassword(asset *models.Asset, username, newPassword, originalPassword string) error {
+	err := s.changePassword(asset, username, newPassword, originalPassword)
+	if err == nil {
+		return nil
+	}
+	if client, dialErr := s.dial(asset, username, passwordAuth(originalPassword)...); dialErr == nil {
+		client.Close()
+		return nil
+	}
+	return err
+}
+
+// dial 登录资产，校验主机密钥，资产配置了网关时经网关链路连接
+func (s *CredentialRotationService) dial(asset *models.Asset, username string, auth ...ssh.AuthMethod) (*ssh.Client, error) {
+	clientConfig := &ssh.ClientConfig{
+		User:    username,
+		Auth:    auth,
+		Timeout: time.Duration(s.cfg.CommandTimeout) * time.Second,
+	}
+	if err := s.hostKeys.ConfigureClient(clientConfig, asset.ID); err != nil {
+		return nil, err
+	}
+
+	client, _, err := s.gateways.DialSSH(asset, clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	return client, nil
+}
+
+// updatePolicyResult 记录策略的执行结果并计算下次改密时间
+func (s *CredentialRotationService) updatePolicyResult(credentialID uint, history *models.CredentialRotationHistory) {
+	var policy models.CredentialRotationPolicy
+	if err := s.db.Where("credential_id = ?", credentialID).First(&policy).Error; err != nil {
+		return
+	}
+
+	// 失败后一小时再试，避免持续失败时频繁登录目标机
+	next := history.StartedAt.Add(time.Hour)
+	if history.Status == models.RotationStatusSuccess || history.Status == models.RotationStatusPartial {
+		next = history.StartedAt.AddDate(0, 0, policy.IntervalDays)
+	}
+	s.db.Model(&policy).Updates(map[string]interface{}{
+		"last_run_at": history.StartedAt,
+		"last_status": history.Status,
+		"next_run_at": next,
+	})
+}
+
+// recordResult 将改密结果写入操作日志，回滚失败或旧公钥未删除时告警
+func (s *CredentialRotationService) recordResult(credential *models.Credential, history *models.CredentialRotationHistory, operator *models.User, clientIP string) {
+	userID, username, method := uint(0), "system", "SYSTEM"
+	if operator != nil {
+		userID, username, method = operator.ID, operator.Username, "POST"
+	}
+	status := 200
+	if history.Status != models.RotationStatusSuccess {
+		status = 500
+	}
+	action := "rotate_password"
+	if credential.Type == utils.CredentialTypeKey {
+		action = "rotate_key"
+	}
+	details := map[string]interface{}{
+		"credential_id": credential.ID,
+		"username":      credential.Username,
+		"trigger":       history.Trigger,
+		"status":        history.Status,
+		"asset_ids":     history.AssetIDs,
+		"results":       history.Results,
+	}
+
+	go s.auditService.RecordOperationLog(
+		userID,
+		username,
+		clientIP,
+		method,
+		fmt.Sprintf("/api/v1/credentials/%d/rotate", credential.ID),
+		action,
+		"credential",
+		credential.ID,
+		"",
+		status,
+		history.Message,
+		details,
+		nil,
+		history.FinishedAt.Sub(history.StartedAt).Milliseconds(),
+		false,
+	)
+
+	eventType := ""
+	switch history.Status {
+	case models.RotationStatusRollbackFailed:
+		eventType = "credential_rotation_rollback_failed"
+	case models.RotationStatusPartial:
+		eventType = "credential_rotation_old_key_remaining"
+	}
+	if eventType != "" {
+		if sender := notifier(); sender != nil {
+			if err := sender.NotifySecurityEvent(context.Background(), eventType, details); err != nil {
+				logrus.WithError(err).Warn("发送改密告警失败")
+			}
+		}
+	}
+}
+
+// loadCredential 加载凭证及其关联资产
+func (s *CredentialRotationService) loadCredential(credentialID uint) (*models.Credential, error) {
+	var credential models.Credential
+	if err := s.db.Preload("Assets").First(&credential, credentialID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query credential: %w", err)
+	}
+	return &credential, nil
+}
+
+// passwordAuth 密码登录方式，兼容只开启 keyboard-interactive 的sshd
+func passwordAuth(password string) []ssh.AuthMethod {
+	return []ssh.AuthMethod{
+		ssh.Password(password),
+		ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range answers {
+				answers[i] = password
+			}
+			return answers, nil
+		}),
+	}
+}
+
+// addAuthorizedKeyCommand 追加公钥到 ~/.ssh/authorized_keys，文件末尾缺少换行时先补上
+func addAuthorizedKeyCommand(publicKey string) string {
+	return fmt.Sprintf(`umask 077 && mkdir -p ~/.ssh && touch ~/.ssh/authorized_keys && `+
+		`{ [ -z "$(tail -c 1 ~/.ssh/authorized_keys)" ] || echo >> ~/.ssh/authorized_keys; } && `+
+		`printf '%%s\n' '%s' >> ~/.ssh/authorized_keys`, strings.TrimSpace(publicKey))
+}
+
+// removeAuthorizedKeyCommand 从 ~/.ssh/authorized_keys 删除包含指定公钥的行，原地写回以保留文件权限
+func removeAuthorizedKeyCommand(publicKey ssh.PublicKey) string {
+	blob := base64.StdEncoding.EncodeToString(publicKey.Marshal())
+	return fmt.Sprintf(`f=~/.ssh/authorized_keys; t="$f.bastion-tmp"; `+
+		`{ grep -vF '%s' "$f" || [ $? -eq 1 ]; } > "$t" && cat "$t" > "$f"; rc=$?; rm -f "$t"; exit $rc`, blob)
+}
+
+// runRemoteCommand 执行命令，stdin 非空时作为命令输入
+func runRemoteCommand(client *ssh.Client, command, stdin string, timeout time.Duration) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	if stdin != "" {
+		session.Stdin = strings.NewReader(stdin)
+	}
+	done := make(chan error, 1)
+	var output []byte
+	go func() {
+		var err error
+		output, err = session.CombinedOutput(command)
+		done <- err
+	}()
+
+	name := strings.Fields(command)[0]
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s failed: %s", name, lastOutputLine(string(output)))
+		}
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("%s timed out", name)
+	}
+}
+
+// runPasswd 通过伪终端执行 passwd 修改当前账号的密码，按提示依次输入原密码和新密码
+func runPasswd(client *ssh.Client, currentPassword, newPassword string, timeout time.Duration) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	if err := session.RequestPty("dumb", 24, 200, ssh.TerminalModes{ssh.ECHO: 0}); err != nil {
+		return fmt.Errorf("failed to request pty: %w", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open stdin: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to open stdout: %w", err)
+	}
+	if err := session.Start("LC_ALL=C passwd"); err != nil {
+		return fmt.Errorf("failed to start passwd: %w", err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	chunks := make(chan string, 16)
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, 1024)
+		for {
+			n, err := stdout.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- string(buf[:n]):
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var output, pending strings.Builder
+	answers := 0
+	deadline := time.After(timeout)
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				if err := session.Wait(); err != nil {
+					return fmt.Errorf("passwd failed: %s", lastOutputLine(output.String()))
+				}
+				return nil
+			}
+			output.WriteString(chunk)
+			pending.WriteString(chunk)
+
+			prompt := strings.ToLower(lastOutputLine(pending.String()))
+			if !strings.HasSuffix(prompt, ":") || !strings.Contains(prompt, "password") {
+				continue
+			}
+			// 原密码一次、新密码两次，再次提示说明新密码被拒绝
+			if answers++; answers > 3 {
+				return fmt.Errorf("passwd rejected the new password: %s", lastOutputLine(output.String()))
+			}
+			answer := newPassword
+			if strings.Contains(prompt, "current") || strings.Contains(prompt, "old") {
+				answer = currentPassword
+			}
+			if _, err := fmt.Fprintf(stdin, "%s\n", answer); err != nil {
+				return fmt.Errorf("failed to write to passwd: %w", err)
+			}
+			pending.Reset()
+		case <-deadline:
+			return errors.New("passwd timed out")
+		}
+	}
+}
+
+// lastOutputLine 命令输出的最后一行非空内容，用于错误说明
+func lastOutputLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" {
+			return line
+		}
+	}
+	return "unknown error"
+}
+
+// generateRotationPassword 生成包含大小写字母、数字和符号的随机密码
+func generateRotationPassword(length int) (string, error) {
+	classes := []string{rotationLowerChars, rotationUpperChars, rotationDigitChars, rotationSymbolChars}
+	all := strings.Join(classes, "")
+
+	password := make([]byte, length)
+	for i := range password {
+		charset := all
+		// 前几位各取一类字符，保证满足复杂度要求
+		if i < len(classes) {
+			charset = classes[i]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		password[i] = charset[n.Int64()]
+	}
+
+	// 打乱顺序，避免固定位置的字符类型
+	for i := len(password) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		j := n.Int64()
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+// joinAssetIDs 资产ID列表转为逗号分隔字符串
+func joinAssetIDs(assets []models.Asset) string {
+	ids := make([]string, len(assets))
+	for i, asset := range assets {
+		ids[i] = strconv.FormatUint(uint64(asset.ID), 10)
+	}
+	return strings.Join(ids, ",")
+}
@@ -0,0 +1,523 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+const testRotationPassword = "Original-Pass-4kT9"
+
+// testSSHAccount 测试SSH服务器上的账号
+type testSSHAccount struct {
+	password       string
+	authorizedKeys []ssh.PublicKey
+}
+
+// testSSHServer 进程内SSH服务器，模拟改密涉及的命令：
+// 连接测试的echo、chpasswd、passwd（伪终端交互）以及 authorized_keys 的追加与删除
+type testSSHServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+
+	mu       sync.Mutex
+	accounts map[string]*testSSHAccount
+	commands []string
+	// failPrefix 以此开头的命令执行失败
+	failPrefix string
+	// ignorePasswordChanges 改密命令返回成功但不生效
+	ignorePasswordChanges bool
+}
+
+// newTestSSHServer 在回环地址上启动SSH服务器
+func newTestSSHServer(t *testing.T, accounts map[string]*testSSHAccount) *testSSHServer {
+	t.Helper()
+	_, hostPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostKey, err := ssh.NewSignerFromKey(hostPrivateKey)
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &testSSHServer{listener: listener, hostKey: hostKey, accounts: accounts}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+// port 监听端口
+func (s *testSSHServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// password 账号当前的密码
+func (s *testSSHServer) password(username string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accounts[username].password
+}
+
+// authorizedKeys 账号当前 authorized_keys 中的公钥指纹
+func (s *testSSHServer) authorizedKeys(username string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fingerprints := make([]string, 0, len(s.accounts[username].authorizedKeys))
+	for _, key := range s.accounts[username].authorizedKeys {
+		fingerprints = append(fingerprints, ssh.FingerprintSHA256(key))
+	}
+	return fingerprints
+}
+
+// executed 执行过的命令名
+func (s *testSSHServer) executed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func (s *testSSHServer) serverConfig() *ssh.ServerConfig {
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if account := s.accounts[conn.User()]; account != nil && account.password == string(password) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", conn.User())
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if account := s.accounts[conn.User()]; account != nil {
+				for _, authorized := range account.authorizedKeys {
+					if bytes.Equal(authorized.Marshal(), key.Marshal()) {
+						return nil, nil
+					}
+				}
+			}
+			return nil, fmt.Errorf("public key rejected for %s", conn.User())
+		},
+	}
+	serverConfig.AddHostKey(s.hostKey)
+	return serverConfig
+}
+
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testSSHServer) handle(conn net.Conn) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.serverConfig())
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go s.handleSession(serverConn.User(), channel, channelRequests)
+	}
+}
+
+// handleSession 处理会话请求，exec时执行命令并返回退出码
+func (s *testSSHServer) handleSession(username string, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for request := range requests {
+		switch request.Type {
+		case "pty-req":
+			request.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(request.Payload, &payload); err != nil {
+				request.Reply(false, nil)
+				return
+			}
+			request.Reply(true, nil)
+			status := s.execute(username, payload.Command, channel)
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		default:
+			request.Reply(false, nil)
+		}
+	}
+}
+
+// execute 模拟命令执行
+func (s *testSSHServer) execute(username, command string, channel ssh.Channel) uint32 {
+	s.mu.Lock()
+	s.commands = append(s.commands, strings.Fields(command)[0])
+	failed := s.failPrefix != "" && strings.HasPrefix(command, s.failPrefix)
+	s.mu.Unlock()
+
+	if failed {
+		io.Copy(io.Discard, channel)
+		fmt.Fprintln(channel, "operation not permitted")
+		return 1
+	}
+
+	switch {
+	case command == "echo 'connection_test'":
+		fmt.Fprintln(channel, "connection_test")
+		return 0
+	case command == "chpasswd":
+		input, _ := io.ReadAll(channel)
+		name, password, ok := strings.Cut(strings.TrimSpace(string(input)), ":")
+		if !ok || name != username {
+			fmt.Fprintln(channel, "chpasswd: line 1: invalid input")
+			return 1
+		}
+		s.setPassword(username, password)
+		return 0
+	case command == "LC_ALL=C passwd":
+		return s.passwd(username, channel)
+	case strings.HasPrefix(command, "umask 077"):
+		return s.addAuthorizedKey(username, command, channel)
+	case strings.HasPrefix(command, "f=~/.ssh/authorized_keys"):
+		return s.removeAuthorizedKey(username, command, channel)
+	}
+	fmt.Fprintf(channel, "%s: command not found\n", strings.Fields(command)[0])
+	return 127
+}
+
+// passwd 按 passwd 的交互流程提示输入原密码和两次新密码
+func (s *testSSHServer) passwd(username string, channel ssh.Channel) uint32 {
+	reader := bufio.NewReader(channel)
+	readLine := func(prompt string) string {
+		fmt.Fprint(channel, prompt)
+		line, _ := reader.ReadString('\n')
+		return strings.TrimRight(line, "\r\n")
+	}
+
+	fmt.Fprintf(channel, "Changing password for %s.\n", username)
+	if current := readLine("Current password: "); current != s.password(username) {
+		fmt.Fprintln(channel, "passwd: Authentication token manipulation error")
+		return 1
+	}
+	newPassword := readLine("New password: ")
+	if retyped := readLine("Retype new password: "); retyped != newPassword {
+		fmt.Fprintln(channel, "Sorry, passwords do not match.")
+		return 1
+	}
+	s.setPassword(username, newPassword)
+	fmt.Fprintln(channel, "passwd: password updated successfully")
+	return 0
+}
+
+func (s *testSSHServer) setPassword(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ignorePasswordChanges {
+		s.accounts[username].password = password
+	}
+}
+
+// addAuthorizedKey 取出命令中 printf 输出的公钥追加到 authorized_keys
+func (s *testSSHServer) addAuthorizedKey(username, command string, channel ssh.Channel) uint32 {
+	index := strings.LastIndex(command, `printf '%s\n' '`)
+	if index < 0 {
+		fmt.Fprintln(channel, "unexpected command")
+		return 2
+	}
+	line := strings.TrimSuffix(command[index+len(`printf '%s\n' '`):], `' >> ~/.ssh/authorized_keys`)
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		fmt.Fprintln(channel, err)
+		return 2
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[username].authorizedKeys = append(s.accounts[username].authorizedKeys, key)
+	return 0
+}
+
+// removeAuthorizedKey 删除 authorized_keys 中包含 grep 参数的公钥
+func (s *testSSHServer) removeAuthorizedKey(username, command string, channel ssh.Channel) uint32 {
+	_, rest, ok := strings.Cut(command, "grep -vF '")
+	blob, _, _ := strings.Cut(rest, "'")
+	if !ok || blob == "" {
+		fmt.Fprintln(channel, "unexpected command")
+		return 2
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	account := s.accounts[username]
+	kept := account.authorizedKeys[:0]
+	for _, key := range account.authorizedKeys {
+		if base64.StdEncoding.EncodeToString(key.Marshal()) != blob {
+			kept = append(kept, key)
+		}
+	}
+	account.authorizedKeys = kept
+	return 0
+}
+
+// setupRotationService 使用miniredis与SQLite创建改密服务
+func setupRotationService(t *testing.T) (*CredentialRotationService, *gorm.DB) {
+	t.Helper()
+	setupTestConfig(t)
+	config.GlobalConfig.CredentialRotation = config.CredentialRotationConfig{CommandTimeout: 5}
+
+	redisServer := miniredis.RunT(t)
+	utils.Redis = redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { utils.Redis.Close() })
+
+	db := newTestDB(t, &models.Asset{}, &models.Credential{}, &models.AssetCredential{}, &models.AssetHostKey{},
+		&models.NetworkZone{}, &models.CredentialRotationPolicy{}, &models.CredentialRotationHistory{},
+		&models.CredentialCheckout{}, &models.OperationLog{})
+	return NewCredentialRotationService(db), db
+}
+
+// createRotationCredential 创建关联到各测试服务器的凭证，secret为密码或私钥
+func createRotationCredential(t *testing.T, db *gorm.DB, credentialType, username, secret string, servers ...*testSSHServer) *models.Credential {
+	t.Helper()
+	encrypted, err := utils.EncryptPassword(secret)
+	require.NoError(t, err)
+
+	credential := &models.Credential{Name: "rotation", Type: credentialType, Username: username}
+	if credentialType == utils.CredentialTypeKey {
+		credential.PrivateKey = encrypted
+	} else {
+		credential.Password = encrypted
+	}
+	for i, server := range servers {
+		credential.Assets = append(credential.Assets, models.Asset{
+			Name: "linux-" + strconv.Itoa(i+1), Type: "server", OsType: "linux", Protocol: "ssh",
+			Address: "127.0.0.1", Port: server.port(), Status: 1,
+		})
+	}
+	require.NoError(t, db.Create(credential).Error)
+	return credential
+}
+
+// credentialSecret 凭证当前保存的明文密码或私钥
+func credentialSecret(t *testing.T, db *gorm.DB, credentialID uint) (*models.Credential, string) {
+	t.Helper()
+	var credential models.Credential
+	require.NoError(t, db.First(&credential, credentialID).Error)
+	if credential.Type == utils.CredentialTypeKey {
+		privateKey, err := utils.DecryptPrivateKey(credential.PrivateKey)
+		require.NoError(t, err)
+		return &credential, privateKey
+	}
+	password, err := utils.DecryptPassword(credential.Password)
+	require.NoError(t, err)
+	return &credential, password
+}
+
+// generateTestSigner 生成ed25519私钥，返回PEM和签名器
+func generateTestSigner(t *testing.T) (string, ssh.Signer) {
+	t.Helper()
+	privateKeyPEM, _, err := utils.GenerateSSHKeyPair("ed25519", "test")
+	require.NoError(t, err)
+	signer, err := ssh.ParsePrivateKey([]byte(privateKeyPEM))
+	require.NoError(t, err)
+	return privateKeyPEM, signer
+}
+
+func TestRotatePasswordAsRootOnAllAssets(t *testing.T) {
+	service, db := setupRotationService(t)
+	servers := []*testSSHServer{
+		newTestSSHServer(t, map[string]*testSSHAccount{"root": {password: testRotationPassword}}),
+		newTestSSHServer(t, map[string]*testSSHAccount{"root": {password: testRotationPassword}}),
+	}
+	credential := createRotationCredential(t, db, utils.CredentialTypePassword, "root", testRotationPassword, servers...)
+
+	history, err := service.RotateNow(credential.ID, nil, "")
+	require.NoError(t, err)
+	require.Equal(t, models.RotationStatusSuccess, history.Status)
+
+	updated, newPassword := credentialSecret(t, db, credential.ID)
+	require.NotEqual(t, testRotationPassword, newPassword)
+	require.Len(t, newPassword, 24)
+	require.Equal(t, utils.CredentialSecretHint(utils.CredentialTypePassword, newPassword, ""), updated.SecretHint)
+	for i, server := range servers {
+		require.Equal(t, newPassword, server.password("root"))
+		require.Contains(t, server.executed(), "chpasswd")
+		require.Equal(t, models.RotationAssetRotated, history.Results[i].Status)
+	}
+
+	var saved int64
+	db.Model(&models.CredentialRotationHistory{}).Where("credential_id = ?", credential.ID).Count(&saved)
+	require.EqualValues(t, 1, saved)
+}
+
+func TestRotatePasswordWithPasswdForNonRootAccount(t *testing.T) {
+	service, db := setupRotationService(t)
+	server := newTestSSHServer(t, map[string]*testSSHAccount{"deploy": {password: testRotationPassword}})
+	credential := createRotationCredential(t, db, utils.CredentialTypePassword, "deploy", testRotationPassword, server)
+
+	history, err := service.RotateNow(credential.ID, nil, "")
+	require.NoError(t, err)
+	require.Equal(t, models.RotationStatusSuccess, history.Status)
+
+	_, newPassword := credentialSecret(t, db, credential.ID)
+	require.Equal(t, newPassword, server.password("deploy"))
+	require.Contains(t, server.executed(), "LC_ALL=C")
+	require.NotContains(t, server.executed(), "chpasswd")
+}
+
+func TestRotatePasswordRollsBackWhenAnAssetFails(t *testing.T) {
+	service, db := setupRotationService(t)
+	first := newTestSSHServer(t, map[string]*testSSHAccount{"root": {password: testRotationPassword}})
+	second := newTestSSHServer(t, map[string]*testSSHAccount{"root": {password: testRotationPassword}})
+	second.failPrefix = "chpasswd"
+	credential := createRotationCredential(t, db, utils.CredentialTypePassword, "root", testRotationPassword, first, second)
+
+	history, err := service.RotateNow(credential.ID, nil, "")
+	require.ErrorContains(t, err, "failed to change password")
+	require.Equal(t, models.RotationStatusRolledBack, history.Status)
+	require.Equal(t, models.RotationAssetRolledBack, history.Results[0].Status)
+	require.Equal(t, models.RotationAssetFailed, history.Results[1].Status)
+
+	// 已改密的资产恢复为原密码，凭证保持不变
+	require.Equal(t, testRotationPassword, first.password("root"))
+	require.Equal(t, testRotationPassword, second.password("root"))
+	_, password := credentialSecret(t, db, credential.ID)
+	require.Equal(t, testRotationPassword, password)
+}
+
+func TestRotatePasswordRollsBackUnverifiedChange(t *testing.T) {
+	service, db := setupRotationService(t)
+	server := newTestSSHServer(t, map[string]*testSSHAccount{"root": {password: testRotationPassword}})
+	server.ignorePasswordChanges = true
+	credential := createRotationCredential(t, db, utils.CredentialTypePassword, "root", testRotationPassword, server)
+
+	history, err := service.RotateNow(credential.ID, nil, "")
+	require.ErrorContains(t, err, "new password verification failed")
+	// 新密码无法登录时确认原密码仍然有效，视为回滚成功
+	require.Equal(t, models.RotationStatusRolledBack, history.Status)
+	_, password := credentialSecret(t, db, credential.ID)
+	require.Equal(t, testRotationPassword, password)
+}
+
+func TestRotateKeyReplacesAuthorizedKey(t *testing.T) {
+	service, db := setupRotationService(t)
+	privateKeyPEM, oldSigner := generateTestSigner(t)
+	server := newTestSSHServer(t, map[string]*testSSHAccount{"deploy": {authorizedKeys: []ssh.PublicKey{oldSigner.PublicKey()}}})
+	credential := createRotationCredential(t, db, utils.CredentialTypeKey, "deploy", privateKeyPEM, server)
+
+	history, err := service.RotateNow(credential.ID, nil, "")
+	require.NoError(t, err)
+	require.Equal(t, models.RotationStatusSuccess, history.Status)
+
+	updated, newPrivateKey := credentialSecret(t, db, credential.ID)
+	newSigner, err := ssh.ParsePrivateKey([]byte(newPrivateKey))
+	require.NoError(t, err)
+	newFingerprint := ssh.FingerprintSHA256(newSigner.PublicKey())
+	require.Equal(t, newFingerprint, updated.SecretHint)
+	require.Equal(t, []string{newFingerprint}, server.authorizedKeys("deploy"))
+}
+
+func TestRotateKeyKeepsOldKeyWhenRemovalFails(t *testing.T) {
+	service, db := setupRotationService(t)
+	privateKeyPEM, oldSigner := generateTestSigner(t)
+	server := newTestSSHServer(t, map[string]*testSSHAccount{"deploy": {authorizedKeys: []ssh.PublicKey{oldSigner.PublicKey()}}})
+	server.failPrefix = "f=~/.ssh/authorized_keys"
+	credential := createRotationCredential(t, db, utils.CredentialTypeKey, "deploy", privateKeyPEM, server)
+
+	history, err := service.RotateNow(credential.ID, nil, "")
+	require.NoError(t, err)
+	require.Equal(t, models.RotationStatusPartial, history.Status)
+	require.Equal(t, models.RotationAssetOldKeyRemaining, history.Results[0].Status)
+
+	// 新密钥已保存，旧公钥仍在 authorized_keys 中等待人工清理
+	_, newPrivateKey := credentialSecret(t, db, credential.ID)
+	newSigner, err := ssh.ParsePrivateKey([]byte(newPrivateKey))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{
+		ssh.FingerprintSHA256(oldSigner.PublicKey()),
+		ssh.FingerprintSHA256(newSigner.PublicKey()),
+	}, server.authorizedKeys("deploy"))
+}
+
+func TestRotateKeyRemovesNewKeyWhenInstallFailsOnLaterAsset(t *testing.T) {
+	service, db := setupRotationService(t)
+	privateKeyPEM, oldSigner := generateTestSigner(t)
+	first := newTestSSHServer(t, map[string]*testSSHAccount{"deploy": {authorizedKeys: []ssh.PublicKey{oldSigner.PublicKey()}}})
+	second := newTestSSHServer(t, map[string]*testSSHAccount{"deploy": {authorizedKeys: []ssh.PublicKey{oldSigner.PublicKey()}}})
+	second.failPrefix = "umask 077"
+	credential := createRotationCredential(t, db, utils.CredentialTypeKey, "deploy", privateKeyPEM, first, second)
+
+	history, err := service.RotateNow(credential.ID, nil, "")
+	require.ErrorContains(t, err, "failed to install new public key")
+	require.Equal(t, models.RotationStatusRolledBack, history.Status)
+
+	oldFingerprint := ssh.FingerprintSHA256(oldSigner.PublicKey())
+	require.Equal(t, []string{oldFingerprint}, first.authorizedKeys("deploy"))
+	require.Equal(t, []string{oldFingerprint}, second.authorizedKeys("deploy"))
+	_, privateKey := credentialSecret(t, db, credential.ID)
+	require.Equal(t, privateKeyPEM, privateKey)
+}
+
+func TestRotateRefusesHostKeyMismatch(t *testing.T) {
+	service, db := setupRotationService(t)
+	server := newTestSSHServer(t, map[string]*testSSHAccount{"root": {password: testRotationPassword}})
+	credential := createRotationCredential(t, db, utils.CredentialTypePassword, "root", testRotationPassword, server)
+
+	// 资产已信任另一把主机密钥，连接到的服务器可能是伪造的
+	_, otherHostKey := generateTestSigner(t)
+	require.NoError(t, db.Create(&models.AssetHostKey{
+		AssetID: credential.Assets[0].ID, KeyType: otherHostKey.PublicKey().Type(),
+		PublicKey:   string(ssh.MarshalAuthorizedKey(otherHostKey.PublicKey())),
+		Fingerprint: ssh.FingerprintSHA256(otherHostKey.PublicKey()),
+		Status:      models.HostKeyStatusTrusted, Source: models.HostKeySourceManual,
+	}).Error)
+
+	history, err := service.RotateNow(credential.ID, nil, "")
+	require.Error(t, err)
+	require.Equal(t, models.RotationStatusFailed, history.Status)
+	require.Empty(t, server.executed())
+	require.Equal(t, testRotationPassword, server.password("root"))
+	_, password := credentialSecret(t, db, credential.ID)
+	require.Equal(t, testRotationPassword, password)
+}
+
+func TestRotateRejectsConcurrentRotation(t *testing.T) {
+	service, db := setupRotationService(t)
+	server := newTestSSHServer(t, map[string]*testSSHAccount{"root": {password: testRotationPassword}})
+	credential := createRotationCredential(t, db, utils.CredentialTypePassword, "root", testRotationPassword, server)
+
+	lockKey := fmt.Sprintf("credential:rotation:%d", credential.ID)
+	require.NoError(t, utils.GetRedis().Set(utils.GetRedis().Context(), lockKey, 1, 0).Err())
+
+	history, err := service.RotateNow(credential.ID, nil, "")
+	require.ErrorIs(t, err, utils.ErrDuplicate)
+	require.Nil(t, history)
+	require.Empty(t, server.executed())
+}
@@ -16,6 +16,7 @@ type DashboardService struct {
 	userService     *UserService
 	auditService    *AuditService
 	monitorService  *MonitorService
+	permissionService *AssetPermissionService
 }
 
 // NewDashboardService 创建仪表盘服务实例
@@ -27,6 +28,7 @@ func NewDashboardService(db *gorm.DB, assetService *AssetService, userService *U
 		userService:    userService,
 		auditService:   auditService,
 		monitorService: monitorService,
+		permissionService: NewAssetPermissionService(db),
 	}
 }
 
@@ -69,8 +71,13 @@ func (s *DashboardService) getHostStats(stats *models.DashboardStats, userID uin
 	
 	// 非管理员只统计有权限的主机
 	if !isAdmin {
-		// TODO: 添加权限过滤逻辑
-		query = query.Where("id IN (SELECT asset_id FROM user_asset_permissions WHERE user_id = ?)", userID)
+		assetIDs, all, err := s.permissionService.GetAuthorizedAssetIDs(userID)
+		if err != nil {
+			return err
+		}
+		if !all {
+			query = query.Where("id IN ?", assetIDs)
+		}
 	}
 
 	// 总数
@@ -150,10 +157,15 @@ func (s *DashboardService) getUserStats(stats *models.DashboardStats) error {
 func (s *DashboardService) getCredentialStats(stats *models.DashboardStats, userID uint, isAdmin bool) error {
 	query := s.db.Model(&models.Credential{})
 	
-	// 非管理员只统计有权限的凭证
+	// 非管理员只统计有权限主机上的凭证
 	if !isAdmin {
-		// TODO: 添加权限过滤逻辑
-		query = query.Where("id IN (SELECT credential_id FROM user_credential_permissions WHERE user_id = ?)", userID)
+		assetIDs, all, err := s.permissionService.GetAuthorizedAssetIDs(userID)
+		if err != nil {
+			return err
+		}
+		if !all {
+			query = query.Where("id IN (SELECT credential_id FROM asset_credentials WHERE asset_id IN ?)", assetIDs)
+		}
 	}
 
 	// 密码凭证数
@@ -244,7 +256,13 @@ func (s *DashboardService) GetHostDistribution(userID uint, isAdmin bool) ([]mod
 
 	// 非管理员只统计有权限的主机
 	if !isAdmin {
-		// TODO: 添加权限过滤
+		assetIDs, all, err := s.permissionService.GetAuthorizedAssetIDs(userID)
+		if err != nil {
+			return nil, err
+		}
+		if !all {
+			query = query.Where("assets.id IN ?", assetIDs)
+		}
 	}
 
 	if err := query.Scan(&results).Error; err != nil {
@@ -377,9 +395,22 @@ func (s *DashboardService) GetQuickAccessHosts(userID uint, limit int) ([]models
 			continue
 		}
 
-		// 获取默认凭证
+		// 只保留当前仍被授权的主机，并选择一个被授权的凭证
+		credentialIDs, allCredentials, err := s.permissionService.GetAuthorizedCredentialIDs(userID, asset.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !allCredentials && len(credentialIDs) == 0 {
+			continue
+		}
+
 		var credential models.Credential
-		s.db.Where("asset_id = ? AND is_default = ?", asset.ID, true).First(&credential)
+		credQuery := s.db.Model(&models.Credential{}).
+			Where("id IN (SELECT credential_id FROM asset_credentials WHERE asset_id = ?)", asset.ID)
+		if !allCredentials {
+			credQuery = credQuery.Where("id IN ?", credentialIDs)
+		}
+		credQuery.Order("id ASC").First(&credential)
 
 		host := models.QuickAccessHost{
 			ID:          asset.ID,
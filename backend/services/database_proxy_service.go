@@ -0,0 +1,569 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// 数据库代理默认参数
+const (
+	defaultDatabaseTokenTTL    = 5 * time.Minute
+	defaultDatabaseDialTimeout = 10 * time.Second
+	defaultMySQLServerVersion  = "5.7.99-Bastion"
+
+	// databaseSessionTouchInterval 刷新会话记录活动时间的间隔，避免空闲的数据库连接被当作陈旧会话清理
+	databaseSessionTouchInterval = time.Minute
+)
+
+// DatabaseProxyService 数据库协议代理服务
+// 用户在Web端申请连接令牌后，使用原生数据库客户端以堡垒机用户名和令牌登录代理，
+// 代理复核授权与访问策略后以资产关联的凭证登录真实数据库（经资产的网关链路），
+// 客户端不会接触到数据库账号密码。每个连接写入一条会话记录，
+// SQL语句在发送前经SQL过滤规则检查，并连同执行结果写入SQL审计日志；
+// Redis命令与SSH会话共用命令组和命令过滤规则，写入命令日志。
+type DatabaseProxyService struct {
+	db                *gorm.DB
+	auditService      *AuditService
+	permissionService *AssetPermissionService
+	accessPolicy      *AccessPolicyService
+	filters           *SQLFilterService
+	commands          *CommandMatcherService
+	gateways          *GatewayChainService
+
+	tokens       map[string]*databaseToken
+	sessions     map[string]*databaseSession
+	listeners    []net.Listener
+	connectionID uint32 // 代理握手报告的连接ID，仅用于客户端显示
+	mu           sync.Mutex
+}
+
+// databaseToken 数据库连接令牌
+type databaseToken struct {
+	Token        string
+	UserID       uint
+	Username     string
+	AssetID      uint
+	CredentialID uint
+	Protocol     string
+	ExpiresAt    time.Time
+}
+
+// databaseSession 一个数据库代理连接
+type databaseSession struct {
+	ID         string
+	Protocol   string
+	User       *models.User
+	Asset      *models.Asset
+	Credential *models.Credential
+	ClientIP   string
+	StartTime  time.Time
+
+	client   net.Conn
+	upstream net.Conn
+	closed   chan struct{}
+	once     sync.Once
+	reason   string
+
+	// PostgreSQL取消请求：客户端拿到的是代理分配的密钥，转发时换成数据库的BackendKeyData
+	cancelKey  []byte
+	backendKey []byte
+}
+
+// GlobalDatabaseProxyService 全局数据库代理服务实例，代理监听与会话监控共享同一会话表
+var GlobalDatabaseProxyService *DatabaseProxyService
+
+// NewDatabaseProxyService 创建数据库代理服务实例
+func NewDatabaseProxyService(db *gorm.DB, filters *SQLFilterService, commands *CommandMatcherService) *DatabaseProxyService {
+	if filters == nil {
+		filters = NewSQLFilterService(db)
+	}
+	if commands == nil {
+		commands = NewCommandMatcherService(db, NewCommandFilterService(db))
+	}
+	return &DatabaseProxyService{
+		db:                db,
+		auditService:      NewAuditService(db),
+		permissionService: NewAssetPermissionService(db),
+		accessPolicy:      NewAccessPolicyService(db),
+		filters:           filters,
+		commands:          commands,
+		gateways:          NewGatewayChainService(db),
+		tokens:            make(map[string]*databaseToken),
+		sessions:          make(map[string]*databaseSession),
+	}
+}
+
+// Start 启动各协议的代理监听
+func (s *DatabaseProxyService) Start() error {
+	cfg := config.GlobalConfig.DatabaseProxy
+	if cfg.MySQLPort > 0 {
+		if err := s.listen(models.DatabaseProtocolMySQL, cfg.GetListenAddr(cfg.MySQLPort), s.serveMySQL); err != nil {
+			s.Stop()
+			return err
+		}
+	}
+	if cfg.PostgreSQLPort > 0 {
+		if err := s.listen(models.DatabaseProtocolPostgreSQL, cfg.GetListenAddr(cfg.PostgreSQLPort), s.servePostgreSQL); err != nil {
+			s.Stop()
+			return err
+		}
+	}
+	if cfg.RedisPort > 0 {
+		if err := s.listen(models.DatabaseProtocolRedis, cfg.GetListenAddr(cfg.RedisPort), s.serveRedis); err != nil {
+			s.Stop()
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop 停止代理监听，已建立的连接不受影响
+func (s *DatabaseProxyService) Stop() error {
+	s.mu.Lock()
+	listeners := s.listeners
+	s.listeners = nil
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, listener := range listeners {
+		if err := listener.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// IssueToken 为用户签发连接数据库资产的令牌，签发前校验授权与访问策略
+func (s *DatabaseProxyService) IssueToken(userID uint, clientIP string, request *models.DatabaseTokenRequest) (*models.DatabaseTokenResponse, error) {
+	cfg := config.GlobalConfig.DatabaseProxy
+	if !cfg.Enable {
+		return nil, fmt.Errorf("%w: database proxy is disabled", utils.ErrPermissionDenied)
+	}
+
+	user, asset, credential, err := s.authorize(userID, request.AssetID, request.CredentialID, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	port := s.proxyPort(asset.Protocol)
+	if port == 0 {
+		return nil, fmt.Errorf("%w: protocol %s is not supported by the database proxy", utils.ErrInvalidParam, asset.Protocol)
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	ttl := time.Duration(cfg.TokenTTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultDatabaseTokenTTL
+	}
+	token := &databaseToken{
+		Token:        hex.EncodeToString(raw),
+		UserID:       user.ID,
+		Username:     user.Username,
+		AssetID:      asset.ID,
+		CredentialID: credential.ID,
+		Protocol:     asset.Protocol,
+		ExpiresAt:    time.Now().Add(ttl),
+	}
+
+	s.mu.Lock()
+	s.purgeExpiredTokens()
+	s.tokens[token.Token] = token
+	s.mu.Unlock()
+
+	host := cfg.AdvertiseHost
+	if host == "" {
+		host = cfg.Host
+	}
+	return &models.DatabaseTokenResponse{
+		Token:     token.Token,
+		Protocol:  asset.Protocol,
+		ProxyHost: host,
+		ProxyPort: port,
+		Username:  user.Username,
+		AssetID:   asset.ID,
+		AssetName: asset.Name,
+		Account:   credential.Username,
+		ExpiresAt: token.ExpiresAt,
+	}, nil
+}
+
+// CloseSession 断开数据库代理会话，返回会话是否存在
+func (s *DatabaseProxyService) CloseSession(sessionID, reason string) bool {
+	s.mu.Lock()
+	session, exists := s.sessions[sessionID]
+	s.mu.Unlock()
+	if !exists {
+		return false
+	}
+	session.close(reason)
+	return true
+}
+
+// CloseUserAssetSessions 断开用户在指定资产上的全部数据库代理会话，assetIDs 为空时断开该用户的全部会话，
+// 返回断开的会话数。授权收回或用户被禁用时调用，代理只在登录时校验授权
+func (s *DatabaseProxyService) CloseUserAssetSessions(userID uint, assetIDs []uint, reason string) int {
+	targets := make(map[uint]bool, len(assetIDs))
+	for _, id := range assetIDs {
+		targets[id] = true
+	}
+
+	s.mu.Lock()
+	var sessions []*databaseSession
+	for _, session := range s.sessions {
+		if session.User.ID == userID && (len(targets) == 0 || targets[session.Asset.ID]) {
+			sessions = append(sessions, session)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.close(reason)
+	}
+	return len(sessions)
+}
+
+// listen 在地址上监听并为每个连接调用 serve
+func (s *DatabaseProxyService) listen(protocol, addr string, serve func(net.Conn)) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	s.mu.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"protocol": protocol,
+		"addr":     addr,
+	}).Info("数据库代理已启动")
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					logrus.WithField("protocol", protocol).Info("数据库代理已停止监听")
+					return
+				}
+				logrus.WithError(err).WithField("protocol", protocol).Warn("数据库代理接受连接失败")
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						logrus.WithField("panic", r).Error("数据库代理连接处理异常")
+						conn.Close()
+					}
+				}()
+				serve(conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+// authorize 校验资产、凭证及用户授权和访问策略，签发令牌与代理登录时各校验一次
+func (s *DatabaseProxyService) authorize(userID, assetID, credentialID uint, clientIP string) (*models.User, *models.Asset, *models.Credential, error) {
+	var asset models.Asset
+	if err := s.db.Where("id = ?", assetID).First(&asset).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, fmt.Errorf("%w: asset %d", utils.ErrNotFound, assetID)
+		}
+		return nil, nil, nil, fmt.Errorf("failed to query asset: %w", err)
+	}
+	if !asset.IsActive() {
+		return nil, nil, nil, fmt.Errorf("%w: asset %s is disabled", utils.ErrInvalidParam, asset.Name)
+	}
+
+	var credential models.Credential
+	if err := s.db.Where("id = ?", credentialID).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil, fmt.Errorf("%w: credential %d", utils.ErrNotFound, credentialID)
+		}
+		return nil, nil, nil, fmt.Errorf("failed to query credential: %w", err)
+	}
+
+	var count int64
+	if err := s.db.Table("asset_credentials").Where("asset_id = ? AND credential_id = ?", assetID, credentialID).Count(&count).Error; err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to verify asset-credential relationship: %w", err)
+	}
+	if count == 0 {
+		return nil, nil, nil, fmt.Errorf("%w: credential is not associated with the asset", utils.ErrInvalidParam)
+	}
+
+	if err := s.permissionService.CheckAssetAccess(userID, assetID, credentialID); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if !user.IsActive() {
+		return nil, nil, nil, fmt.Errorf("%w: user account is disabled", utils.ErrPermissionDenied)
+	}
+
+	if err := s.accessPolicy.CheckSession(&user, assetID, clientIP); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &user, &asset, &credential, nil
+}
+
+// findToken 查找用户名下验证通过的有效令牌，用户名为空时（如Redis只带密码的AUTH）只按令牌匹配
+func (s *DatabaseProxyService) findToken(protocol, username string, verify func(token string) bool) *databaseToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purgeExpiredTokens()
+	for _, token := range s.tokens {
+		if token.Protocol == protocol && (username == "" || token.Username == username) && verify(token.Token) {
+			copied := *token
+			return &copied
+		}
+	}
+	return nil
+}
+
+// purgeExpiredTokens 清理过期令牌，调用方需持有锁
+func (s *DatabaseProxyService) purgeExpiredTokens() {
+	now := time.Now()
+	for key, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, key)
+		}
+	}
+}
+
+// proxyPort 协议对应的代理端口，未启用时返回0
+func (s *DatabaseProxyService) proxyPort(protocol string) int {
+	cfg := config.GlobalConfig.DatabaseProxy
+	switch protocol {
+	case models.DatabaseProtocolMySQL:
+		return cfg.MySQLPort
+	case models.DatabaseProtocolPostgreSQL:
+		return cfg.PostgreSQLPort
+	case models.DatabaseProtocolRedis:
+		return cfg.RedisPort
+	default:
+		return 0
+	}
+}
+
+// dialTimeout 连接数据库的超时时间
+func (s *DatabaseProxyService) dialTimeout() time.Duration {
+	if timeout := time.Duration(config.GlobalConfig.DatabaseProxy.DialTimeout) * time.Second; timeout > 0 {
+		return timeout
+	}
+	return defaultDatabaseDialTimeout
+}
+
+// credentialPassword 解密凭证密码
+func (s *DatabaseProxyService) credentialPassword(credential *models.Credential) (string, error) {
+	if credential.Password == "" {
+		return "", nil
+	}
+	password, err := utils.DecryptPassword(credential.Password)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt credential password: %w", err)
+	}
+	return password, nil
+}
+
+// recordLogin 记录代理登录日志
+func (s *DatabaseProxyService) recordLogin(userID uint, username, clientIP, protocol, status, message string) {
+	go s.auditService.RecordLoginLog(userID, username, clientIP, "database-proxy", protocol, status, message)
+}
+
+// openSession 写入会话记录并登记会话
+func (s *DatabaseProxyService) openSession(session *databaseSession, chain models.GatewayChain) error {
+	address := net.JoinHostPort(session.Asset.Address, strconv.Itoa(session.Asset.Port))
+	if err := s.auditService.RecordSessionStart(session.ID, session.User.ID, session.User.Username, session.Asset.ID,
+		session.Asset.Name, address, session.Credential.ID, session.Protocol, session.ClientIP, chain); err != nil {
+		return fmt.Errorf("failed to record database session: %w", err)
+	}
+
+	s.mu.Lock()
+	s.sessions[session.ID] = session
+	s.mu.Unlock()
+
+	go s.touchLoop(session)
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"protocol":   session.Protocol,
+		"user_id":    session.User.ID,
+		"asset_id":   session.Asset.ID,
+	}).Info("数据库代理会话已建立")
+	return nil
+}
+
+// finishSession 结束会话记录并移出会话表
+func (s *DatabaseProxyService) finishSession(session *databaseSession) {
+	session.close("客户端断开连接")
+
+	s.mu.Lock()
+	delete(s.sessions, session.ID)
+	s.mu.Unlock()
+
+	now := time.Now()
+	if err := s.db.Model(&models.SessionRecord{}).
+		Where("session_id = ? AND status = ?", session.ID, "active").
+		Updates(map[string]interface{}{
+			"status":       "closed",
+			"end_time":     now,
+			"duration":     int64(now.Sub(session.StartTime).Seconds()),
+			"close_reason": session.reason,
+			"updated_at":   now,
+		}).Error; err != nil {
+		logrus.WithError(err).WithField("session_id", session.ID).Error("结束数据库会话记录失败")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": session.ID,
+		"reason":     session.reason,
+	}).Info("数据库代理会话已关闭")
+}
+
+// touchLoop 定期刷新会话记录的活动时间，直到会话关闭
+func (s *DatabaseProxyService) touchLoop(session *databaseSession) {
+	ticker := time.NewTicker(databaseSessionTouchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-session.closed:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			s.db.Model(&models.SessionRecord{}).
+				Where("session_id = ? AND status = ?", session.ID, "active").
+				Updates(map[string]interface{}{"last_activity": now, "updated_at": now})
+		}
+	}
+}
+
+// checkStatement 按SQL过滤规则检查语句，命中告警或拒绝规则时发送安全告警
+func (s *DatabaseProxyService) checkStatement(session *databaseSession, statement string) *SQLFilterResult {
+	result := s.filters.Check(session.Protocol, session.Asset.ID, statement)
+	if result.Rule == nil || result.Action == models.FilterActionAllow {
+		return result
+	}
+
+	if sender := notifier(); sender != nil {
+		details := map[string]interface{}{
+			"session_id": session.ID,
+			"user_id":    session.User.ID,
+			"username":   session.User.Username,
+			"asset_id":   session.Asset.ID,
+			"asset_name": session.Asset.Name,
+			"client_ip":  session.ClientIP,
+			"protocol":   session.Protocol,
+			"statement":  truncateStatement(statement, 500),
+			"rule_id":    result.Rule.ID,
+			"rule_name":  result.Rule.Name,
+			"action":     result.Action,
+		}
+		go func() {
+			if err := sender.NotifySecurityEvent(context.Background(), "sql_filter_"+result.Action, details); err != nil {
+				logrus.WithError(err).Warn("发送SQL过滤告警失败")
+			}
+		}()
+	}
+	return result
+}
+
+// newAuditEntry 创建语句的审计记录，执行结果由协议处理填写
+func (s *DatabaseProxyService) newAuditEntry(session *databaseSession, database, statement string, result *SQLFilterResult) *models.SQLAuditLog {
+	entry := &models.SQLAuditLog{
+		SessionID:     session.ID,
+		UserID:        session.User.ID,
+		Username:      session.User.Username,
+		AssetID:       session.Asset.ID,
+		AssetName:     session.Asset.Name,
+		Account:       session.Credential.Username,
+		Protocol:      session.Protocol,
+		Database:      database,
+		Statement:     statement,
+		StatementType: truncateStatement(result.StatementType, 30),
+		Action:        result.Action,
+		Status:        models.SQLAuditStatusSuccess,
+		ClientIP:      session.ClientIP,
+		CreatedAt:     time.Now(),
+	}
+	if result.Rule != nil {
+		ruleID := result.Rule.ID
+		entry.RuleID = &ruleID
+		entry.RuleName = result.Rule.Name
+	}
+	return entry
+}
+
+// saveAuditEntry 写入SQL审计日志
+func (s *DatabaseProxyService) saveAuditEntry(entry *models.SQLAuditLog) {
+	entry.Duration = time.Since(entry.CreatedAt).Milliseconds()
+	entry.Message = truncateStatement(entry.Message, 500)
+	go func() {
+		if err := s.db.Create(entry).Error; err != nil {
+			logrus.WithError(err).WithField("session_id", entry.SessionID).Error("写入SQL审计日志失败")
+		}
+	}()
+}
+
+// close 断开客户端与数据库之间的连接，只有第一次调用的原因会被记录
+func (ds *databaseSession) close(reason string) {
+	ds.once.Do(func() {
+		ds.reason = reason
+		close(ds.closed)
+		ds.client.Close()
+		if ds.upstream != nil {
+			ds.upstream.Close()
+		}
+	})
+}
+
+// newDatabaseSession 创建数据库代理会话对象
+func newDatabaseSession(protocol string, user *models.User, asset *models.Asset, credential *models.Credential, clientIP string, client, upstream net.Conn) *databaseSession {
+	return &databaseSession{
+		ID:         protocol + "-" + uuid.New().String(),
+		Protocol:   protocol,
+		User:       user,
+		Asset:      asset,
+		Credential: credential,
+		ClientIP:   clientIP,
+		StartTime:  time.Now(),
+		client:     client,
+		upstream:   upstream,
+		closed:     make(chan struct{}),
+	}
+}
+
+// truncateStatement 截断过长的文本（按字节，保证UTF-8完整）
+func truncateStatement(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
@@ -0,0 +1,917 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxPendingHashBytes 计算摘要时允许暂存的乱序数据上限
+const maxPendingHashBytes = 16 << 20
+
+// FileTransferService 文件传输服务
+// 负责SSH网关SFTP子系统代理与Web会话文件上传下载，
+// 按资产授权规则控制上传/下载方向，按上传配置限制文件类型和大小，
+// 并为每个传输的文件记录文件名、方向、大小与SHA-256摘要。
+type FileTransferService struct {
+	db                *gorm.DB
+	sshService        *SSHService
+	permissionService *AssetPermissionService
+}
+
+// NewFileTransferService 创建文件传输服务实例
+func NewFileTransferService(db *gorm.DB, sshService *SSHService) *FileTransferService {
+	return &FileTransferService{
+		db:                db,
+		sshService:        sshService,
+		permissionService: NewAssetPermissionService(db),
+	}
+}
+
+// ======================== 审计查询 ========================
+
+// GetTransferLogs 获取文件传输日志列表
+func (s *FileTransferService) GetTransferLogs(req *models.FileTransferLogListRequest) ([]*models.FileTransferLogResponse, int64, error) {
+	var logs []models.FileTransferLog
+	var total int64
+
+	query := s.db.Model(&models.FileTransferLog{})
+	if req.SessionID != "" {
+		query = query.Where("session_id = ?", req.SessionID)
+	}
+	if req.Username != "" {
+		query = query.Where("username LIKE ?", "%"+req.Username+"%")
+	}
+	if req.AssetID > 0 {
+		query = query.Where("asset_id = ?", req.AssetID)
+	}
+	if req.Direction != "" {
+		query = query.Where("direction = ?", req.Direction)
+	}
+	if req.Channel != "" {
+		query = query.Where("channel = ?", req.Channel)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.Filename != "" {
+		query = query.Where("filename LIKE ?", "%"+req.Filename+"%")
+	}
+	if req.StartTime != "" {
+		if startTime, err := time.Parse("2006-01-02", req.StartTime); err == nil {
+			query = query.Where("start_time >= ?", startTime)
+		}
+	}
+	if req.EndTime != "" {
+		if endTime, err := time.Parse("2006-01-02", req.EndTime); err == nil {
+			query = query.Where("start_time <= ?", endTime.Add(24*time.Hour))
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count file transfer logs: %w", err)
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("start_time DESC").Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query file transfer logs: %w", err)
+	}
+
+	responses := make([]*models.FileTransferLogResponse, len(logs))
+	for i := range logs {
+		responses[i] = logs[i].ToResponse()
+	}
+	return responses, total, nil
+}
+
+// ======================== Web会话文件操作 ========================
+
+// ListFiles 列出Web会话所连资产上的目录内容，dir为空时使用登录账号的家目录
+func (s *FileTransferService) ListFiles(userID uint, sessionID, dir string) (*models.RemoteFileListResponse, error) {
+	session, err := s.getUserSession(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := s.openSFTPClient(session)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	dir, err = resolveRemotePath(client, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := client.ReadDir(dir)
+	if err != nil {
+		return nil, wrapRemoteError(err, "failed to read remote directory")
+	}
+
+	// 目录在前，同类按名称排序
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].IsDir() != infos[j].IsDir() {
+			return infos[i].IsDir()
+		}
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	files := make([]*models.RemoteFileInfo, len(infos))
+	for i, info := range infos {
+		files[i] = models.NewRemoteFileInfo(dir, info)
+	}
+	return &models.RemoteFileListResponse{Path: dir, Files: files}, nil
+}
+
+// UploadFile 将Web上传的文件经 SavePath 暂存后写入资产，remoteDir为空时上传到家目录
+func (s *FileTransferService) UploadFile(userID uint, sessionID, remoteDir string, file *multipart.FileHeader) (*models.FileTransferLogResponse, error) {
+	session, err := s.getUserSession(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	filename := path.Base(strings.ReplaceAll(file.Filename, "\\", "/"))
+	if filename == "." || filename == ".." || filename == "/" {
+		return nil, fmt.Errorf("%w: invalid file name", utils.ErrInvalidParam)
+	}
+
+	entry := s.newTransferLog(session, models.TransferChannelWeb, models.TransferDirectionUpload, path.Join(remoteDir, filename))
+	entry.Size = file.Size
+	if err := s.checkUpload(session.UserID, session.AssetID, filename, file.Size); err != nil {
+		s.saveLog(entry, models.TransferStatusDenied, err.Error())
+		return nil, err
+	}
+
+	staged, checksum, size, err := stageUpload(file)
+	if err != nil {
+		s.saveLog(entry, models.TransferStatusFailed, err.Error())
+		return nil, err
+	}
+	defer func() {
+		staged.Close()
+		os.Remove(staged.Name())
+	}()
+	entry.Size = size
+	entry.SHA256 = checksum
+
+	client, err := s.openSFTPClient(session)
+	if err != nil {
+		s.saveLog(entry, models.TransferStatusFailed, err.Error())
+		return nil, err
+	}
+	defer client.Close()
+
+	remoteDir, err = resolveRemotePath(client, remoteDir)
+	if err != nil {
+		s.saveLog(entry, models.TransferStatusFailed, err.Error())
+		return nil, err
+	}
+	entry.RemotePath = path.Join(remoteDir, filename)
+
+	if err := copyToRemote(client, entry.RemotePath, staged); err != nil {
+		s.saveLog(entry, models.TransferStatusFailed, err.Error())
+		return nil, wrapRemoteError(err, "failed to upload file")
+	}
+
+	s.saveLog(entry, models.TransferStatusSuccess, "")
+	return entry.ToResponse(), nil
+}
+
+// FileDownload Web会话文件下载流
+// 调用方读取完毕后必须调用 Close，关闭时根据读取情况写入传输日志
+type FileDownload struct {
+	Name string
+	Size int64
+
+	service *FileTransferService
+	client  *sftp.Client
+	file    *sftp.File
+	entry   *models.FileTransferLog
+	hash    hash.Hash
+	read    int64
+}
+
+// Read 读取文件内容并同步计算摘要
+func (d *FileDownload) Read(p []byte) (int, error) {
+	n, err := d.file.Read(p)
+	if n > 0 {
+		d.hash.Write(p[:n])
+		d.read += int64(n)
+	}
+	return n, err
+}
+
+// Close 关闭远程文件并记录传输结果
+func (d *FileDownload) Close() error {
+	err := d.file.Close()
+	d.client.Close()
+
+	d.entry.Size = d.read
+	if d.read < d.Size {
+		d.service.saveLog(d.entry, models.TransferStatusFailed, fmt.Sprintf("transfer interrupted after %d of %d bytes", d.read, d.Size))
+		return err
+	}
+	d.entry.SHA256 = hex.EncodeToString(d.hash.Sum(nil))
+	d.service.saveLog(d.entry, models.TransferStatusSuccess, "")
+	return err
+}
+
+// OpenDownload 打开Web会话所连资产上的文件用于下载
+func (s *FileTransferService) OpenDownload(userID uint, sessionID, remotePath string) (*FileDownload, error) {
+	if remotePath == "" {
+		return nil, fmt.Errorf("%w: path is required", utils.ErrInvalidParam)
+	}
+
+	session, err := s.getUserSession(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := s.newTransferLog(session, models.TransferChannelWeb, models.TransferDirectionDownload, remotePath)
+	if err := s.permissionService.CheckFileTransfer(session.UserID, session.AssetID, models.TransferDirectionDownload); err != nil {
+		s.saveLog(entry, models.TransferStatusDenied, err.Error())
+		return nil, err
+	}
+
+	client, err := s.openSFTPClient(session)
+	if err != nil {
+		return nil, err
+	}
+
+	remotePath, err = resolveRemotePath(client, remotePath)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	entry.RemotePath = remotePath
+
+	info, err := client.Stat(remotePath)
+	if err != nil {
+		client.Close()
+		return nil, wrapRemoteError(err, "failed to stat remote file")
+	}
+	if info.IsDir() {
+		client.Close()
+		return nil, fmt.Errorf("%w: cannot download a directory", utils.ErrInvalidParam)
+	}
+
+	file, err := client.Open(remotePath)
+	if err != nil {
+		client.Close()
+		return nil, wrapRemoteError(err, "failed to open remote file")
+	}
+
+	return &FileDownload{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		service: s,
+		client:  client,
+		file:    file,
+		entry:   entry,
+		hash:    sha256.New(),
+	}, nil
+}
+
+// ======================== SSH网关SFTP代理 ========================
+
+// ServeSFTP 在网关通道上运行SFTP服务端，并将请求代理到会话所连资产的SFTP子系统
+// 客户端退出或通道关闭时返回
+func (s *FileTransferService) ServeSFTP(sessionID string, channel io.ReadWriteCloser) error {
+	session, err := s.sshService.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.openSFTPClient(session)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	home, err := client.Getwd()
+	if err != nil {
+		home = "/"
+	}
+
+	proxy := &sftpProxy{
+		service: s,
+		session: session,
+		client:  client,
+		base:    s.newTransferLog(session, models.TransferChannelSFTP, "", ""),
+	}
+	server := sftp.NewRequestServer(channel, sftp.Handlers{
+		FileGet:  proxy,
+		FilePut:  proxy,
+		FileCmd:  proxy,
+		FileList: proxy,
+	}, sftp.WithStartDirectory(home))
+	defer server.Close()
+
+	if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// sftpProxy 将网关SFTP请求转发到资产的SFTP客户端
+type sftpProxy struct {
+	service *FileTransferService
+	session *SSHSession
+	client  *sftp.Client
+	base    *models.FileTransferLog // 会话级公共字段，每个文件复制一份
+}
+
+// newLog 基于会话信息创建单个文件的传输日志
+func (p *sftpProxy) newLog(direction, remotePath string) *models.FileTransferLog {
+	entry := *p.base
+	entry.Direction = direction
+	entry.RemotePath = remotePath
+	entry.Filename = path.Base(remotePath)
+	entry.StartTime = time.Now()
+	return &entry
+}
+
+// Fileread 处理下载请求
+func (p *sftpProxy) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	p.session.UpdateActivity()
+
+	entry := p.newLog(models.TransferDirectionDownload, r.Filepath)
+	if err := p.service.permissionService.CheckFileTransfer(p.session.UserID, p.session.AssetID, models.TransferDirectionDownload); err != nil {
+		p.service.saveLog(entry, models.TransferStatusDenied, err.Error())
+		return nil, os.ErrPermission
+	}
+
+	file, err := p.client.Open(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	return &sftpTransfer{service: p.service, client: p.client, entry: entry, file: file, hasher: newTransferHasher()}, nil
+}
+
+// Filewrite 处理上传请求
+func (p *sftpProxy) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	p.session.UpdateActivity()
+
+	entry := p.newLog(models.TransferDirectionUpload, r.Filepath)
+	if err := p.service.checkUpload(p.session.UserID, p.session.AssetID, entry.Filename, -1); err != nil {
+		p.service.saveLog(entry, models.TransferStatusDenied, err.Error())
+		return nil, os.ErrPermission
+	}
+
+	file, err := p.client.OpenFile(r.Filepath, sftpOpenFlags(r.Pflags()))
+	if err != nil {
+		return nil, err
+	}
+	return &sftpTransfer{
+		service: p.service,
+		client:  p.client,
+		entry:   entry,
+		file:    file,
+		hasher:  newTransferHasher(),
+		maxSize: uploadMaxBytes(),
+	}, nil
+}
+
+// Filecmd 处理修改文件系统的命令
+// 这些操作会改变资产上的文件，禁止上传时一并拒绝
+func (p *sftpProxy) Filecmd(r *sftp.Request) error {
+	p.session.UpdateActivity()
+
+	switch r.Method {
+	case "Rename", "Link", "Symlink":
+		return p.renameOrLink(r)
+	}
+
+	if err := p.service.permissionService.CheckFileTransfer(p.session.UserID, p.session.AssetID, models.TransferDirectionUpload); err != nil {
+		return os.ErrPermission
+	}
+
+	switch r.Method {
+	case "Setstat":
+		return p.setstat(r)
+	case "Rmdir":
+		return p.client.RemoveDirectory(r.Filepath)
+	case "Remove":
+		return p.client.Remove(r.Filepath)
+	case "Mkdir":
+		return p.client.Mkdir(r.Filepath)
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// renameOrLink 处理重命名、硬链接与符号链接
+// 这些操作在资产上产生新的文件名，新文件名按上传校验文件类型，避免上传允许的类型后改名绕过限制，
+// 并与上传一样写入传输日志
+func (p *sftpProxy) renameOrLink(r *sftp.Request) error {
+	// 三种请求中 Target 均为新产生的路径，Symlink 的 Filepath 为链接指向的目标
+	entry := p.newLog(models.TransferDirectionUpload, r.Target)
+	operation := fmt.Sprintf("%s %s -> %s", strings.ToLower(r.Method), r.Filepath, r.Target)
+	if err := p.service.checkUpload(p.session.UserID, p.session.AssetID, entry.Filename, -1); err != nil {
+		p.service.saveLog(entry, models.TransferStatusDenied, fmt.Sprintf("%s: %v", operation, err))
+		return os.ErrPermission
+	}
+
+	var err error
+	switch r.Method {
+	case "Rename":
+		err = p.client.Rename(r.Filepath, r.Target)
+	case "Link":
+		err = p.client.Link(r.Filepath, r.Target)
+	case "Symlink":
+		err = p.client.Symlink(r.Filepath, r.Target)
+	}
+	if err != nil {
+		p.service.saveLog(entry, models.TransferStatusFailed, fmt.Sprintf("%s: %v", operation, err))
+		return err
+	}
+	p.service.saveLog(entry, models.TransferStatusSuccess, operation)
+	return nil
+}
+
+// setstat 按请求中携带的属性修改文件
+func (p *sftpProxy) setstat(r *sftp.Request) error {
+	attrs := r.Attributes()
+	flags := r.AttrFlags()
+	if flags.Size {
+		if err := p.client.Truncate(r.Filepath, int64(attrs.Size)); err != nil {
+			return err
+		}
+	}
+	if flags.Permissions {
+		if err := p.client.Chmod(r.Filepath, attrs.FileMode()); err != nil {
+			return err
+		}
+	}
+	if flags.UidGid {
+		if err := p.client.Chown(r.Filepath, int(attrs.UID), int(attrs.GID)); err != nil {
+			return err
+		}
+	}
+	if flags.Acmodtime {
+		if err := p.client.Chtimes(r.Filepath, time.Unix(int64(attrs.Atime), 0), time.Unix(int64(attrs.Mtime), 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Filelist 处理目录列表与文件属性查询
+func (p *sftpProxy) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	p.session.UpdateActivity()
+
+	switch r.Method {
+	case "List":
+		infos, err := p.client.ReadDir(r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return fileInfoList(infos), nil
+	case "Stat":
+		info, err := p.client.Stat(r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return fileInfoList{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// Lstat 查询文件属性，不跟随符号链接
+func (p *sftpProxy) Lstat(r *sftp.Request) (sftp.ListerAt, error) {
+	info, err := p.client.Lstat(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfoList{info}, nil
+}
+
+// Readlink 读取符号链接目标
+func (p *sftpProxy) Readlink(filePath string) (string, error) {
+	return p.client.ReadLink(filePath)
+}
+
+// fileInfoList 实现 sftp.ListerAt
+type fileInfoList []os.FileInfo
+
+// ListAt 从offset开始填充文件信息
+func (l fileInfoList) ListAt(list []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(list, l[offset:])
+	if n < len(list) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// sftpTransfer 单个文件的SFTP传输，关闭时写入传输日志
+type sftpTransfer struct {
+	service  *FileTransferService
+	client   *sftp.Client
+	entry    *models.FileTransferLog
+	file     *sftp.File
+	hasher   *transferHasher
+	maxSize  int64 // 上传大小上限，0表示不限制
+	mu       sync.Mutex
+	err      error
+	exceeded bool
+}
+
+// ReadAt 读取资产文件
+func (t *sftpTransfer) ReadAt(p []byte, off int64) (int, error) {
+	n, err := t.file.ReadAt(p, off)
+	t.hasher.Write(p[:n], off)
+	return n, err
+}
+
+// WriteAt 写入资产文件，超过上传大小限制时中止
+func (t *sftpTransfer) WriteAt(p []byte, off int64) (int, error) {
+	if t.maxSize > 0 && off+int64(len(p)) > t.maxSize {
+		err := fmt.Errorf("file exceeds upload size limit of %d MB", config.GlobalConfig.Upload.MaxSize)
+		t.mu.Lock()
+		t.exceeded = true
+		t.mu.Unlock()
+		t.TransferError(err)
+		return 0, err
+	}
+	n, err := t.file.WriteAt(p, off)
+	t.hasher.Write(p[:n], off)
+	return n, err
+}
+
+// TransferError 记录传输过程中的错误
+func (t *sftpTransfer) TransferError(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = err
+	}
+}
+
+// Close 关闭资产文件并记录传输结果
+func (t *sftpTransfer) Close() error {
+	closeErr := t.file.Close()
+
+	t.mu.Lock()
+	transferErr, exceeded := t.err, t.exceeded
+	t.mu.Unlock()
+
+	// 超出大小限制的上传不保留残缺文件
+	if exceeded {
+		if err := t.client.Remove(t.entry.RemotePath); err != nil {
+			logrus.WithError(err).WithField("path", t.entry.RemotePath).Warn("删除超限上传文件失败")
+		}
+	}
+
+	t.entry.Size = t.hasher.Size()
+	t.entry.SHA256 = t.hasher.Sum()
+	switch {
+	case transferErr != nil:
+		t.service.saveLog(t.entry, models.TransferStatusFailed, transferErr.Error())
+	case closeErr != nil:
+		t.service.saveLog(t.entry, models.TransferStatusFailed, closeErr.Error())
+	default:
+		t.service.saveLog(t.entry, models.TransferStatusSuccess, "")
+	}
+	return closeErr
+}
+
+// transferHasher 按偏移顺序计算传输内容的SHA-256
+// SFTP客户端会并发发送多个读写请求，乱序到达的数据块先暂存，补齐后再写入摘要
+type transferHasher struct {
+	mu           sync.Mutex
+	hash         hash.Hash
+	offset       int64 // 已写入摘要的连续字节数
+	size         int64 // 观察到的最大结束偏移
+	pending      map[int64][]byte
+	pendingBytes int64
+	broken       bool // 暂存数据超过上限等无法还原顺序的情况，放弃计算摘要
+}
+
+// newTransferHasher 创建传输摘要计算器
+func newTransferHasher() *transferHasher {
+	return &transferHasher{hash: sha256.New(), pending: make(map[int64][]byte)}
+}
+
+// Write 写入从off开始的数据块
+func (h *transferHasher) Write(p []byte, off int64) {
+	if len(p) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if end := off + int64(len(p)); end > h.size {
+		h.size = end
+	}
+	if h.broken {
+		return
+	}
+
+	if off > h.offset {
+		h.pending[off] = append([]byte(nil), p...)
+		h.pendingBytes += int64(len(p))
+		if h.pendingBytes > maxPendingHashBytes {
+			h.broken = true
+			h.pending = nil
+		}
+		return
+	}
+
+	h.consume(p, off)
+	h.drain()
+}
+
+// consume 将与已摘要部分相接或重叠的数据块写入摘要
+func (h *transferHasher) consume(p []byte, off int64) {
+	if end := off + int64(len(p)); end > h.offset {
+		h.hash.Write(p[h.offset-off:])
+		h.offset = end
+	}
+}
+
+// drain 写入已可以接续的暂存数据块
+func (h *transferHasher) drain() {
+	for len(h.pending) > 0 {
+		progressed := false
+		for off, p := range h.pending {
+			if off > h.offset {
+				continue
+			}
+			delete(h.pending, off)
+			h.pendingBytes -= int64(len(p))
+			h.consume(p, off)
+			progressed = true
+		}
+		if !progressed {
+			return
+		}
+	}
+}
+
+// Size 返回传输的字节数
+func (h *transferHasher) Size() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.size
+}
+
+// Sum 返回完整连续内容的摘要，内容不连续时返回空字符串
+func (h *transferHasher) Sum() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.broken || len(h.pending) > 0 || h.offset != h.size {
+		return ""
+	}
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
+
+// ======================== 内部方法 ========================
+
+// checkUpload 校验上传授权与上传配置，size 未知时传 -1
+func (s *FileTransferService) checkUpload(userID, assetID uint, filename string, size int64) error {
+	if err := s.permissionService.CheckFileTransfer(userID, assetID, models.TransferDirectionUpload); err != nil {
+		return err
+	}
+
+	if maxSize := uploadMaxBytes(); maxSize > 0 && size > maxSize {
+		return fmt.Errorf("%w: file exceeds upload size limit of %d MB", utils.ErrInvalidParam, config.GlobalConfig.Upload.MaxSize)
+	}
+
+	allowTypes := config.GlobalConfig.Upload.AllowTypes
+	if len(allowTypes) == 0 {
+		return nil
+	}
+	ext := strings.ToLower(path.Ext(filename))
+	for _, allowed := range allowTypes {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == "*" || allowed == ext || (ext != "" && "."+allowed == ext) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: file type %q is not allowed", utils.ErrInvalidParam, ext)
+}
+
+// getUserSession 获取属于当前用户的活跃会话
+func (s *FileTransferService) getUserSession(userID uint, sessionID string) (*SSHSession, error) {
+	session, err := s.sshService.GetSession(sessionID)
+	if err != nil {
+		return nil, utils.ErrNotFound
+	}
+	if session.UserID != userID {
+		return nil, fmt.Errorf("%w: session %s does not belong to user %d", utils.ErrPermissionDenied, sessionID, userID)
+	}
+	return session, nil
+}
+
+// openSFTPClient 在会话的资产连接上打开SFTP子系统
+func (s *FileTransferService) openSFTPClient(session *SSHSession) (*sftp.Client, error) {
+	session.mu.RLock()
+	conn := session.ClientConn
+	session.mu.RUnlock()
+	if conn == nil {
+		return nil, fmt.Errorf("session connection is closed")
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sftp subsystem: %w", err)
+	}
+	return client, nil
+}
+
+// newTransferLog 根据会话创建传输日志
+func (s *FileTransferService) newTransferLog(session *SSHSession, channel, direction, remotePath string) *models.FileTransferLog {
+	entry := &models.FileTransferLog{
+		SessionID:    session.ID,
+		UserID:       session.UserID,
+		AssetID:      session.AssetID,
+		CredentialID: session.CredentialID,
+		Channel:      channel,
+		Direction:    direction,
+		RemotePath:   remotePath,
+		Filename:     path.Base(remotePath),
+		ClientIP:     session.ClientIP,
+		StartTime:    time.Now(),
+	}
+
+	var user models.User
+	if err := s.db.Select("username").Where("id = ?", session.UserID).First(&user).Error; err == nil {
+		entry.Username = user.Username
+	}
+	var asset models.Asset
+	if err := s.db.Select("name").Where("id = ?", session.AssetID).First(&asset).Error; err == nil {
+		entry.AssetName = asset.Name
+	}
+	return entry
+}
+
+// saveLog 写入传输日志
+func (s *FileTransferService) saveLog(entry *models.FileTransferLog, status, message string) {
+	entry.Status = status
+	if len(message) > 500 {
+		message = message[:500]
+	}
+	entry.Message = message
+	entry.EndTime = time.Now()
+
+	if err := s.db.Create(entry).Error; err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"session_id": entry.SessionID,
+			"path":       entry.RemotePath,
+		}).Error("记录文件传输日志失败")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"session_id": entry.SessionID,
+		"user_id":    entry.UserID,
+		"direction":  entry.Direction,
+		"path":       entry.RemotePath,
+		"size":       entry.Size,
+		"status":     entry.Status,
+	}).Info("文件传输已记录")
+}
+
+// uploadMaxBytes 上传大小上限（字节），0表示不限制
+func uploadMaxBytes() int64 {
+	if config.GlobalConfig == nil || config.GlobalConfig.Upload.MaxSize <= 0 {
+		return 0
+	}
+	return int64(config.GlobalConfig.Upload.MaxSize) << 20
+}
+
+// stageUpload 将上传文件暂存到 SavePath 并计算摘要，返回定位到开头的暂存文件
+func stageUpload(file *multipart.FileHeader) (*os.File, string, int64, error) {
+	dir := config.GlobalConfig.Upload.SavePath
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, "", 0, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to open upload file: %w", err)
+	}
+	defer src.Close()
+
+	staged, err := os.CreateTemp(dir, "transfer-*")
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to create staging file: %w", err)
+	}
+	discard := func() {
+		staged.Close()
+		os.Remove(staged.Name())
+	}
+
+	var reader io.Reader = src
+	maxSize := uploadMaxBytes()
+	if maxSize > 0 {
+		reader = io.LimitReader(src, maxSize+1)
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(staged, hasher), reader)
+	if err != nil {
+		discard()
+		return nil, "", 0, fmt.Errorf("failed to stage upload file: %w", err)
+	}
+	if maxSize > 0 && size > maxSize {
+		discard()
+		return nil, "", 0, fmt.Errorf("%w: file exceeds upload size limit of %d MB", utils.ErrInvalidParam, config.GlobalConfig.Upload.MaxSize)
+	}
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		discard()
+		return nil, "", 0, fmt.Errorf("failed to rewind staging file: %w", err)
+	}
+
+	return staged, hex.EncodeToString(hasher.Sum(nil)), size, nil
+}
+
+// copyToRemote 将本地内容写入资产文件
+func copyToRemote(client *sftp.Client, remotePath string, src io.Reader) error {
+	remote, err := client.Create(remotePath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(remote, src); err != nil {
+		remote.Close()
+		return err
+	}
+	return remote.Close()
+}
+
+// resolveRemotePath 将相对路径解析为登录账号家目录下的绝对路径
+func resolveRemotePath(client *sftp.Client, p string) (string, error) {
+	if path.IsAbs(p) {
+		return path.Clean(p), nil
+	}
+	home, err := client.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get remote working directory: %w", err)
+	}
+	return path.Join(home, p), nil
+}
+
+// wrapRemoteError 将资产返回的文件错误转换为统一错误
+func wrapRemoteError(err error, message string) error {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("%w: %s: %v", utils.ErrNotFound, message, err)
+	case errors.Is(err, os.ErrPermission):
+		return fmt.Errorf("%w: %s: %v", utils.ErrPermissionDenied, message, err)
+	default:
+		return fmt.Errorf("%s: %w", message, err)
+	}
+}
+
+// sftpOpenFlags 将SFTP打开标志转换为 os.OpenFile 标志
+func sftpOpenFlags(flags sftp.FileOpenFlags) int {
+	mode := os.O_WRONLY
+	if flags.Read {
+		mode = os.O_RDWR
+	}
+	if flags.Append {
+		mode |= os.O_APPEND
+	}
+	if flags.Creat {
+		mode |= os.O_CREATE
+	}
+	if flags.Trunc {
+		mode |= os.O_TRUNC
+	}
+	if flags.Excl {
+		mode |= os.O_EXCL
+	}
+	return mode
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// setupSFTPProxy 创建代理到本地SFTP服务端的网关SFTP处理器，alice 被授权上传，只允许上传 .txt 文件
+func setupSFTPProxy(t *testing.T) (*sftpProxy, *gorm.DB, string) {
+	t.Helper()
+	setupTestConfig(t)
+	config.GlobalConfig.Upload.AllowTypes = []string{".txt"}
+
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+		&models.UserGroup{}, &models.Asset{}, &models.AssetGroup{}, &models.Credential{}, &models.AssetCredential{},
+		&models.AssetPermission{}, &models.SessionRecord{}, &models.FileTransferLog{})
+	asset := &models.Asset{Name: "web-01", Type: "server", Protocol: "ssh", Address: "127.0.0.1", Port: 22, Status: 1}
+	require.NoError(t, db.Create(asset).Error)
+	user := &models.User{Username: "alice", Password: "hash", Status: 1, AuthSource: models.AuthSourceLocal}
+	require.NoError(t, db.Create(user).Error)
+	require.NoError(t, db.Create(&models.AssetPermission{Name: "web-01-upload", Enabled: true, AllowUpload: true,
+		Users: []models.User{*user}, Assets: []models.Asset{*asset}}).Error)
+
+	serverConn, clientConn := net.Pipe()
+	server, err := sftp.NewServer(serverConn)
+	require.NoError(t, err)
+	go server.Serve()
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	session := &SSHSession{ID: "ssh-test", UserID: user.ID, AssetID: asset.ID}
+	proxy := &sftpProxy{
+		service: NewFileTransferService(db, nil),
+		session: session,
+		client:  client,
+		base: &models.FileTransferLog{SessionID: session.ID, UserID: user.ID, Username: user.Username,
+			AssetID: asset.ID, AssetName: asset.Name, Channel: models.TransferChannelSFTP},
+	}
+	return proxy, db, t.TempDir()
+}
+
+// sftpFilecmd 构造并处理带目标路径的文件命令
+func sftpFilecmd(proxy *sftpProxy, method, filePath, target string) error {
+	request := sftp.NewRequest(method, filePath)
+	request.Target = target
+	return proxy.Filecmd(request)
+}
+
+func TestSFTPRenameAndLinkCheckUploadTypes(t *testing.T) {
+	proxy, db, dir := setupSFTPProxy(t)
+	notes := filepath.Join(dir, "notes.txt")
+	require.NoError(t, os.WriteFile(notes, []byte("echo pwned"), 0o644))
+
+	// 改名为允许的类型
+	renamed := filepath.Join(dir, "renamed.txt")
+	require.NoError(t, sftpFilecmd(proxy, "Rename", notes, renamed))
+	require.FileExists(t, renamed)
+
+	// 改名或链接为不允许的类型被拒绝，资产上不产生新文件
+	require.ErrorIs(t, sftpFilecmd(proxy, "Rename", renamed, filepath.Join(dir, "run.sh")), os.ErrPermission)
+	require.ErrorIs(t, sftpFilecmd(proxy, "Symlink", renamed, filepath.Join(dir, "link.sh")), os.ErrPermission)
+	require.ErrorIs(t, sftpFilecmd(proxy, "Link", renamed, filepath.Join(dir, "hard.sh")), os.ErrPermission)
+	require.FileExists(t, renamed)
+	require.NoFileExists(t, filepath.Join(dir, "run.sh"))
+	require.NoFileExists(t, filepath.Join(dir, "link.sh"))
+	require.NoFileExists(t, filepath.Join(dir, "hard.sh"))
+
+	var logs []models.FileTransferLog
+	require.NoError(t, db.Order("id").Find(&logs).Error)
+	require.Len(t, logs, 4)
+	require.Equal(t, models.TransferStatusSuccess, logs[0].Status)
+	require.Equal(t, "renamed.txt", logs[0].Filename)
+	require.Equal(t, models.TransferDirectionUpload, logs[0].Direction)
+	require.Contains(t, logs[0].Message, "rename "+notes)
+	for _, log := range logs[1:] {
+		require.Equal(t, models.TransferStatusDenied, log.Status)
+		require.Contains(t, log.Message, "is not allowed")
+	}
+	require.Equal(t, "link.sh", logs[2].Filename)
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMaxGatewayHops = 5
+	defaultGatewayTimeout = 30 * time.Second
+)
+
+// GatewayChainService 资产网关（跳板机）链路服务
+// 资产可以指定网关资产及登录网关的凭证，未指定时使用所在网络区域的网关；网关本身也按同样规则解析，
+// 形成从堡垒机到目标资产的多级链路。连接时依次登录各级网关，由最后一级网关转发到目标资产，
+// 每一级都校验主机密钥。
+type GatewayChainService struct {
+	db            *gorm.DB
+	hostKeys      *HostKeyService
+	certAuthority *SSHCAService
+	maxHops       int
+	timeout       time.Duration
+}
+
+// NewGatewayChainService 创建网关链路服务实例
+func NewGatewayChainService(db *gorm.DB) *GatewayChainService {
+	maxHops := defaultMaxGatewayHops
+	timeout := defaultGatewayTimeout
+	if config.GlobalConfig != nil {
+		if config.GlobalConfig.SSH.MaxGatewayHops > 0 {
+			maxHops = config.GlobalConfig.SSH.MaxGatewayHops
+		}
+		if config.GlobalConfig.SSH.Timeout > 0 {
+			timeout = time.Duration(config.GlobalConfig.SSH.Timeout) * time.Second
+		}
+	}
+	return &GatewayChainService{
+		db:            db,
+		hostKeys:      NewHostKeyService(db),
+		certAuthority: NewSSHCAService(db),
+		maxHops:       maxHops,
+		timeout:       timeout,
+	}
+}
+
+// gatewayHop 链路中的一级网关及登录凭证
+type gatewayHop struct {
+	asset      models.Asset
+	credential models.Credential
+}
+
+// address 网关的SSH地址
+func (h *gatewayHop) address() string {
+	return net.JoinHostPort(h.asset.Address, strconv.Itoa(h.asset.Port))
+}
+
+// ======================== 链路解析 ========================
+
+// ResolveChain 解析连接资产需要依次经过的网关，第一个为堡垒机直接连接的网关，直连时返回空
+// 链路成环、超过最大级数或网关不可用时返回 utils.ErrInvalidParam
+func (s *GatewayChainService) ResolveChain(asset *models.Asset) ([]gatewayHop, error) {
+	var hops []gatewayHop
+	visited := map[uint]bool{asset.ID: true}
+	current := *asset
+	for {
+		gatewayID, credentialID, err := s.gatewayOf(&current)
+		if err != nil {
+			return nil, err
+		}
+		if gatewayID == 0 {
+			return hops, nil
+		}
+		if visited[gatewayID] {
+			return nil, fmt.Errorf("%w: gateway chain of asset %s loops back to asset %d", utils.ErrInvalidParam, asset.Name, gatewayID)
+		}
+		if len(hops) >= s.maxHops {
+			return nil, fmt.Errorf("%w: gateway chain of asset %s exceeds %d hops", utils.ErrInvalidParam, asset.Name, s.maxHops)
+		}
+
+		hop, err := s.loadHop(gatewayID, credentialID)
+		if err != nil {
+			return nil, err
+		}
+		visited[gatewayID] = true
+		hops = append([]gatewayHop{*hop}, hops...)
+		current = hop.asset
+	}
+}
+
+// ValidateAssetGateway 校验资产（可以是尚未保存的修改）的网关配置能解析出有效链路
+func (s *GatewayChainService) ValidateAssetGateway(asset *models.Asset) error {
+	_, err := s.ResolveChain(asset)
+	return err
+}
+
+// ValidateZoneGateway 校验网络区域的网关：网关本身可用，且区域内资产的链路不超过最大级数
+func (s *GatewayChainService) ValidateZoneGateway(gatewayID, credentialID uint) error {
+	hop, err := s.loadHop(gatewayID, credentialID)
+	if err != nil {
+		return err
+	}
+	hops, err := s.ResolveChain(&hop.asset)
+	if err != nil {
+		return err
+	}
+	if len(hops)+1 > s.maxHops {
+		return fmt.Errorf("%w: gateway chain through asset %s exceeds %d hops", utils.ErrInvalidParam, hop.asset.Name, s.maxHops)
+	}
+	return nil
+}
+
+// gatewayOf 资产的上一级网关：资产配置的网关优先，其次为所在区域的网关；区域网关就是资产自身时视为直连
+func (s *GatewayChainService) gatewayOf(asset *models.Asset) (uint, uint, error) {
+	if asset.GatewayID != nil && *asset.GatewayID != 0 {
+		return *asset.GatewayID, derefUint(asset.GatewayCredentialID), nil
+	}
+	if asset.ZoneID == nil || *asset.ZoneID == 0 {
+		return 0, 0, nil
+	}
+
+	var zone models.NetworkZone
+	if err := s.db.Where("id = ?", *asset.ZoneID).First(&zone).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, 0, fmt.Errorf("%w: network zone %d of asset %s not found", utils.ErrInvalidParam, *asset.ZoneID, asset.Name)
+		}
+		return 0, 0, fmt.Errorf("failed to query network zone: %w", err)
+	}
+	if zone.GatewayID == nil || *zone.GatewayID == asset.ID {
+		return 0, 0, nil
+	}
+	return *zone.GatewayID, derefUint(zone.GatewayCredentialID), nil
+}
+
+// loadHop 加载网关资产及登录凭证，网关必须是启用的SSH资产，凭证必须与网关关联
+func (s *GatewayChainService) loadHop(gatewayID, credentialID uint) (*gatewayHop, error) {
+	var gateway models.Asset
+	if err := s.db.Where("id = ?", gatewayID).First(&gateway).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: gateway asset %d not found", utils.ErrInvalidParam, gatewayID)
+		}
+		return nil, fmt.Errorf("failed to query gateway asset: %w", err)
+	}
+	if gateway.Protocol != "ssh" {
+		return nil, fmt.Errorf("%w: gateway asset %s is not an SSH asset", utils.ErrInvalidParam, gateway.Name)
+	}
+	if gateway.Status != 1 {
+		return nil, fmt.Errorf("%w: gateway asset %s is disabled", utils.ErrInvalidParam, gateway.Name)
+	}
+	if credentialID == 0 {
+		return nil, fmt.Errorf("%w: no credential configured for gateway asset %s", utils.ErrInvalidParam, gateway.Name)
+	}
+
+	var credential models.Credential
+	if err := s.db.Where("id = ?", credentialID).First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: gateway credential %d not found", utils.ErrInvalidParam, credentialID)
+		}
+		return nil, fmt.Errorf("failed to query gateway credential: %w", err)
+	}
+	var count int64
+	if err := s.db.Table("asset_credentials").Where("asset_id = ? AND credential_id = ?", gatewayID, credentialID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to verify gateway credential: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%w: credential %s is not associated with gateway asset %s", utils.ErrInvalidParam, credential.Name, gateway.Name)
+	}
+
+	return &gatewayHop{asset: gateway, credential: credential}, nil
+}
+
+// ======================== 连接 ========================
+
+// Dial 连接资产网络中的地址：直连时直接建立TCP连接，否则依次登录链路上的网关，由最后一级网关转发。
+// 关闭返回的连接时一并断开各级网关；timeout 为0时使用 ssh.timeout
+func (s *GatewayChainService) Dial(asset *models.Asset, address string, timeout time.Duration) (net.Conn, models.GatewayChain, error) {
+	if timeout <= 0 {
+		timeout = s.timeout
+	}
+	hops, err := s.ResolveChain(asset)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(hops) == 0 {
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		return conn, nil, nil
+	}
+
+	clients := make([]*ssh.Client, 0, len(hops))
+	chain := make(models.GatewayChain, 0, len(hops))
+	for i := range hops {
+		hop := &hops[i]
+		client, err := s.dialHop(clients, hop, timeout)
+		if err != nil {
+			closeChainClients(clients)
+			return nil, nil, fmt.Errorf("failed to connect to gateway %s (%s): %w", hop.asset.Name, hop.address(), err)
+		}
+		clients = append(clients, client)
+		chain = append(chain, models.GatewayHop{
+			AssetID:      hop.asset.ID,
+			AssetName:    hop.asset.Name,
+			Address:      hop.address(),
+			CredentialID: hop.credential.ID,
+			Username:     hop.credential.Username,
+		})
+	}
+
+	last := hops[len(hops)-1]
+	conn, err := clients[len(clients)-1].Dial("tcp", address)
+	if err != nil {
+		closeChainClients(clients)
+		return nil, nil, fmt.Errorf("gateway %s failed to reach %s: %w", last.asset.Name, address, err)
+	}
+	return &chainConn{Conn: conn, clients: clients}, chain, nil
+}
+
+// DialSSH 经网关链路建立到资产的SSH连接，断开连接时一并断开各级网关
+func (s *GatewayChainService) DialSSH(asset *models.Asset, clientConfig *ssh.ClientConfig) (*ssh.Client, models.GatewayChain, error) {
+	address := net.JoinHostPort(asset.Address, strconv.Itoa(asset.Port))
+	conn, chain, err := s.Dial(asset, address, clientConfig.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := newSSHClient(conn, address, clientConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, chain, nil
+}
+
+// Dialer 返回经资产网关链路拨号的函数，供只持有资产ID的连接测试使用
+func (s *GatewayChainService) Dialer(assetID uint, timeout time.Duration) func(network, address string) (net.Conn, error) {
+	return func(network, address string) (net.Conn, error) {
+		var asset models.Asset
+		if err := s.db.Where("id = ?", assetID).First(&asset).Error; err != nil {
+			return nil, fmt.Errorf("failed to query asset: %w", err)
+		}
+		conn, _, err := s.Dial(&asset, address, timeout)
+		return conn, err
+	}
+}
+
+// dialHop 登录一级网关，第一级直接连接，其余经上一级网关转发
+func (s *GatewayChainService) dialHop(previous []*ssh.Client, hop *gatewayHop, timeout time.Duration) (*ssh.Client, error) {
+	clientConfig, err := s.clientConfig(hop, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if len(previous) == 0 {
+		return ssh.Dial("tcp", hop.address(), clientConfig)
+	}
+	conn, err := previous[len(previous)-1].Dial("tcp", hop.address())
+	if err != nil {
+		return nil, err
+	}
+	return newSSHClient(conn, hop.address(), clientConfig)
+}
+
+// clientConfig 登录网关的SSH客户端配置，校验网关主机密钥
+func (s *GatewayChainService) clientConfig(hop *gatewayHop, timeout time.Duration) (*ssh.ClientConfig, error) {
+	clientConfig := &ssh.ClientConfig{
+		User:    hop.credential.Username,
+		Timeout: timeout,
+	}
+	if err := s.hostKeys.ConfigureClient(clientConfig, hop.asset.ID); err != nil {
+		return nil, err
+	}
+
+	switch hop.credential.Type {
+	case "password":
+		password, err := utils.DecryptPassword(hop.credential.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt password: %w", err)
+		}
+		clientConfig.Auth = []ssh.AuthMethod{ssh.Password(password)}
+	case "key":
+		privateKey, err := utils.DecryptPrivateKey(hop.credential.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		clientConfig.Auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
+	case utils.CredentialTypeCert:
+		keyID := fmt.Sprintf("bastion:gateway asset=%d credential=%d", hop.asset.ID, hop.credential.ID)
+		signer, err := s.certAuthority.NewCertSigner(hop.credential.Username, keyID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to issue ssh certificate: %w", err)
+		}
+		clientConfig.Auth = []ssh.AuthMethod{ssh.PublicKeys(signer)}
+	default:
+		return nil, fmt.Errorf("unsupported gateway credential type %s", hop.credential.Type)
+	}
+	return clientConfig, nil
+}
+
+// newSSHClient 在已建立的连接上完成SSH握手，失败时关闭连接
+func newSSHClient(conn net.Conn, address string, clientConfig *ssh.ClientConfig) (*ssh.Client, error) {
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, address, clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// chainConn 经网关链路转发的连接，关闭时由近及远断开各级网关
+type chainConn struct {
+	net.Conn
+	clients []*ssh.Client
+	once    sync.Once
+}
+
+// CloseWrite 半关闭写方向，底层连接不支持时关闭整个连接
+func (c *chainConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return c.Close()
+}
+
+// Close 关闭连接及各级网关
+func (c *chainConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		closeChainClients(c.clients)
+	})
+	return err
+}
+
+// closeChainClients 从最后一级开始断开网关
+func closeChainClients(clients []*ssh.Client) {
+	for i := len(clients) - 1; i >= 0; i-- {
+		clients[i].Close()
+	}
+}
+
+// derefUint 取指针值，nil 返回0
+func derefUint(value *uint) uint {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/utils"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestConfig 使用空配置和临时目录中的密钥文件初始化全局配置与凭证加密
+func setupTestConfig(t *testing.T) {
+	t.Helper()
+	config.GlobalConfig = &config.Config{}
+	config.GlobalConfig.SecretStore.KeyFile = filepath.Join(t.TempDir(), "master.key")
+	require.NoError(t, utils.InitSecretStore())
+}
+
+// newTestDB 创建临时SQLite数据库并为指定模型建表
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "bastion.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(models...))
+	return db
+}
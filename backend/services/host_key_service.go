@@ -0,0 +1,390 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HostKeyError 资产主机密钥未通过校验
+type HostKeyError struct {
+	AssetID     uint
+	Fingerprint string // 连接时出现的密钥指纹
+	Mismatch    bool   // true 表示与已信任的密钥不一致，false 表示严格模式下尚未登记
+}
+
+func (e *HostKeyError) Error() string {
+	if e.Mismatch {
+		return fmt.Sprintf("host key mismatch for asset %d (got %s), possible man-in-the-middle attack; an administrator must accept the new key before connecting",
+			e.AssetID, e.Fingerprint)
+	}
+	return fmt.Sprintf("host key %s for asset %d is not trusted; an administrator must accept or pre-seed it before connecting",
+		e.Fingerprint, e.AssetID)
+}
+
+// HostKeyService 资产SSH主机密钥校验与管理服务
+// 默认首次连接时信任资产的主机密钥（TOFU），严格模式下只接受管理员预置或确认过的密钥
+type HostKeyService struct {
+	db           *gorm.DB
+	auditService *AuditService
+	strict       bool
+}
+
+// NewHostKeyService 创建主机密钥服务实例
+func NewHostKeyService(db *gorm.DB) *HostKeyService {
+	return &HostKeyService{
+		db:           db,
+		auditService: NewAuditService(db),
+		strict:       config.GlobalConfig != nil && config.GlobalConfig.SSH.StrictHostKeyChecking,
+	}
+}
+
+// ======================== 连接校验 ========================
+
+// ConfigureClient 为连接资产的SSH客户端配置主机密钥校验
+func (s *HostKeyService) ConfigureClient(clientConfig *ssh.ClientConfig, assetID uint) error {
+	algorithms, err := s.Algorithms(assetID)
+	if err != nil {
+		return err
+	}
+	clientConfig.HostKeyCallback = s.Callback(assetID)
+	clientConfig.HostKeyAlgorithms = algorithms
+	return nil
+}
+
+// Callback 返回校验资产主机密钥的回调
+func (s *HostKeyService) Callback(assetID uint) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return s.verify(assetID, hostname, key)
+	}
+}
+
+// Algorithms 已登记密钥对应的主机密钥算法，保证目标返回的是已信任类型的密钥
+// 资产没有已信任的密钥时返回nil，使用默认算法列表
+func (s *HostKeyService) Algorithms(assetID uint) ([]string, error) {
+	var keyTypes []string
+	if err := s.db.Model(&models.AssetHostKey{}).
+		Where("asset_id = ? AND status = ?", assetID, models.HostKeyStatusTrusted).
+		Distinct().Pluck("key_type", &keyTypes).Error; err != nil {
+		return nil, fmt.Errorf("failed to query host keys: %w", err)
+	}
+
+	var algorithms []string
+	for _, keyType := range keyTypes {
+		// RSA密钥同时支持SHA-2签名算法
+		if keyType == ssh.KeyAlgoRSA {
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algorithms = append(algorithms, keyType)
+	}
+	return algorithms, nil
+}
+
+// verify 校验目标返回的主机密钥
+func (s *HostKeyService) verify(assetID uint, hostname string, key ssh.PublicKey) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+	now := time.Now()
+
+	var trusted []models.AssetHostKey
+	if err := s.db.Where("asset_id = ? AND status = ?", assetID, models.HostKeyStatusTrusted).Find(&trusted).Error; err != nil {
+		return fmt.Errorf("failed to query host keys: %w", err)
+	}
+	for i := range trusted {
+		if trusted[i].Fingerprint == fingerprint {
+			s.db.Model(&trusted[i]).UpdateColumn("last_seen_at", now)
+			return nil
+		}
+	}
+
+	// 首次连接，信任目标返回的密钥
+	if len(trusted) == 0 && !s.strict {
+		if err := s.saveKey(s.db, assetID, key, models.HostKeyStatusTrusted, models.HostKeySourceFirstUse, nil, now); err != nil {
+			return err
+		}
+		s.recordOperation(nil, "", "SYSTEM", "trust_first_use", assetID, 200, "host key trusted on first use", map[string]interface{}{
+			"host":        hostname,
+			"key_type":    key.Type(),
+			"fingerprint": fingerprint,
+		})
+		return nil
+	}
+
+	// 密钥不一致或严格模式下未登记，记为待确认并拒绝连接
+	if err := s.saveKey(s.db, assetID, key, models.HostKeyStatusPending, "", nil, now); err != nil {
+		logrus.WithError(err).WithField("asset_id", assetID).Warn("记录待确认主机密钥失败")
+	}
+	hostKeyErr := &HostKeyError{AssetID: assetID, Fingerprint: fingerprint, Mismatch: len(trusted) > 0}
+	s.recordRejection(hostname, key, trusted, hostKeyErr)
+	return hostKeyErr
+}
+
+// ======================== 密钥管理 ========================
+
+// GetHostKeys 获取资产的主机密钥，包括待确认的密钥
+func (s *HostKeyService) GetHostKeys(assetID uint) ([]models.AssetHostKey, error) {
+	if err := s.checkAsset(assetID); err != nil {
+		return nil, err
+	}
+
+	var keys []models.AssetHostKey
+	if err := s.db.Where("asset_id = ?", assetID).Order("status DESC, id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to query host keys: %w", err)
+	}
+	return keys, nil
+}
+
+// SeedHostKeys 预置资产的主机密钥，替换同类型的已信任密钥
+func (s *HostKeyService) SeedHostKeys(assetID uint, publicKeys []string, operator *models.User, clientIP string) ([]models.AssetHostKey, error) {
+	if err := s.checkAsset(assetID); err != nil {
+		return nil, err
+	}
+
+	keys := make([]ssh.PublicKey, 0, len(publicKeys))
+	for _, line := range publicKeys {
+		key, err := parseHostKey(line)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	var replaced []string
+	now := time.Now()
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			fingerprints, err := s.removeTrusted(tx, assetID, key.Type(), ssh.FingerprintSHA256(key))
+			if err != nil {
+				return err
+			}
+			replaced = append(replaced, fingerprints...)
+			if err := s.saveKey(tx, assetID, key, models.HostKeyStatusTrusted, models.HostKeySourceManual, operator, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	seeded := make([]string, len(keys))
+	for i, key := range keys {
+		seeded[i] = ssh.FingerprintSHA256(key)
+	}
+	s.recordOperation(operator, clientIP, "POST", "seed", assetID, 200, "host keys pre-seeded", map[string]interface{}{
+		"fingerprints": seeded,
+		"replaced":     replaced,
+	})
+
+	return s.GetHostKeys(assetID)
+}
+
+// AcceptHostKey 确认待处理的主机密钥，替换同类型的已信任密钥
+func (s *HostKeyService) AcceptHostKey(assetID, keyID uint, operator *models.User, clientIP string) (*models.AssetHostKey, error) {
+	var hostKey models.AssetHostKey
+	if err := s.db.Where("id = ? AND asset_id = ?", keyID, assetID).First(&hostKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to query host key: %w", err)
+	}
+	if hostKey.Status == models.HostKeyStatusTrusted {
+		return nil, fmt.Errorf("%w: host key is already trusted", utils.ErrInvalidParam)
+	}
+
+	var replaced []string
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if replaced, err = s.removeTrusted(tx, assetID, hostKey.KeyType, hostKey.Fingerprint); err != nil {
+			return err
+		}
+		return tx.Model(&hostKey).Updates(map[string]interface{}{
+			"status":           models.HostKeyStatusTrusted,
+			"source":           models.HostKeySourceAccepted,
+			"accepted_by":      operator.ID,
+			"accepted_by_name": operator.Username,
+		}).Error
+	}); err != nil {
+		return nil, fmt.Errorf("failed to accept host key: %w", err)
+	}
+
+	s.recordOperation(operator, clientIP, "POST", "accept", assetID, 200, "host key accepted", map[string]interface{}{
+		"key_type":    hostKey.KeyType,
+		"fingerprint": hostKey.Fingerprint,
+		"replaced":    replaced,
+	})
+
+	if err := s.db.First(&hostKey, hostKey.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to query host key: %w", err)
+	}
+	return &hostKey, nil
+}
+
+// ResetHostKeys 清除资产的全部主机密钥，下次连接时重新信任（严格模式下需再次确认）
+func (s *HostKeyService) ResetHostKeys(assetID uint, operator *models.User, clientIP string) error {
+	if err := s.checkAsset(assetID); err != nil {
+		return err
+	}
+
+	var fingerprints []string
+	if err := s.db.Model(&models.AssetHostKey{}).Where("asset_id = ? AND status = ?", assetID, models.HostKeyStatusTrusted).
+		Pluck("fingerprint", &fingerprints).Error; err != nil {
+		return fmt.Errorf("failed to query host keys: %w", err)
+	}
+	if err := s.db.Where("asset_id = ?", assetID).Delete(&models.AssetHostKey{}).Error; err != nil {
+		return fmt.Errorf("failed to reset host keys: %w", err)
+	}
+
+	s.recordOperation(operator, clientIP, "DELETE", "reset", assetID, 200, "host keys reset", map[string]interface{}{
+		"removed": fingerprints,
+	})
+	return nil
+}
+
+// saveKey 写入主机密钥，同一指纹已存在时更新状态
+func (s *HostKeyService) saveKey(tx *gorm.DB, assetID uint, key ssh.PublicKey, status, source string, operator *models.User, now time.Time) error {
+	hostKey := &models.AssetHostKey{
+		AssetID:     assetID,
+		KeyType:     key.Type(),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		Status:      status,
+		Source:      source,
+		FirstSeenAt: &now,
+	}
+	updates := []string{"last_seen_at"}
+	if status == models.HostKeyStatusPending {
+		hostKey.LastSeenAt = &now
+	} else {
+		updates = []string{"status", "source", "accepted_by", "accepted_by_name"}
+		if source == models.HostKeySourceFirstUse {
+			hostKey.LastSeenAt = &now
+			updates = append(updates, "last_seen_at")
+		}
+	}
+	if operator != nil {
+		hostKey.AcceptedBy = &operator.ID
+		hostKey.AcceptedByName = operator.Username
+	}
+
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "asset_id"}, {Name: "fingerprint"}},
+		DoUpdates: clause.AssignmentColumns(updates),
+	}).Create(hostKey).Error; err != nil {
+		return fmt.Errorf("failed to save host key: %w", err)
+	}
+	return nil
+}
+
+// removeTrusted 删除同类型的其它已信任密钥，返回被替换的指纹
+func (s *HostKeyService) removeTrusted(tx *gorm.DB, assetID uint, keyType, keepFingerprint string) ([]string, error) {
+	query := tx.Where("asset_id = ? AND key_type = ? AND status = ? AND fingerprint <> ?",
+		assetID, keyType, models.HostKeyStatusTrusted, keepFingerprint)
+
+	var fingerprints []string
+	if err := query.Model(&models.AssetHostKey{}).Pluck("fingerprint", &fingerprints).Error; err != nil {
+		return nil, fmt.Errorf("failed to query host keys: %w", err)
+	}
+	if len(fingerprints) == 0 {
+		return nil, nil
+	}
+	if err := tx.Where("asset_id = ? AND fingerprint IN ?", assetID, fingerprints).Delete(&models.AssetHostKey{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to replace host key: %w", err)
+	}
+	return fingerprints, nil
+}
+
+// checkAsset 检查资产是否存在
+func (s *HostKeyService) checkAsset(assetID uint) error {
+	var count int64
+	if err := s.db.Model(&models.Asset{}).Where("id = ?", assetID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to query asset: %w", err)
+	}
+	if count == 0 {
+		return utils.ErrNotFound
+	}
+	return nil
+}
+
+// recordRejection 主机密钥校验失败时记录安全事件并告警
+func (s *HostKeyService) recordRejection(hostname string, key ssh.PublicKey, trusted []models.AssetHostKey, hostKeyErr *HostKeyError) {
+	expected := make([]string, len(trusted))
+	for i, k := range trusted {
+		expected[i] = k.Fingerprint
+	}
+	details := map[string]interface{}{
+		"asset_id":    hostKeyErr.AssetID,
+		"host":        hostname,
+		"key_type":    key.Type(),
+		"fingerprint": hostKeyErr.Fingerprint,
+		"expected":    expected,
+	}
+
+	action, eventType := "reject_untrusted", "host_key_untrusted"
+	if hostKeyErr.Mismatch {
+		action, eventType = "reject_mismatch", "host_key_mismatch"
+	}
+	s.recordOperation(nil, "", "SYSTEM", action, hostKeyErr.AssetID, 403, hostKeyErr.Error(), details)
+
+	if sender := notifier(); sender != nil {
+		if err := sender.NotifySecurityEvent(context.Background(), eventType, details); err != nil {
+			logrus.WithError(err).Warn("发送主机密钥告警失败")
+		}
+	}
+}
+
+// recordOperation 记录主机密钥变更，连接过程中的自动变更没有操作人
+func (s *HostKeyService) recordOperation(operator *models.User, clientIP, method, action string, assetID uint, status int, message string, details map[string]interface{}) {
+	userID, username := uint(0), "system"
+	if operator != nil {
+		userID, username = operator.ID, operator.Username
+	}
+
+	go s.auditService.RecordOperationLog(
+		userID,
+		username,
+		clientIP,
+		method,
+		fmt.Sprintf("/api/v1/assets/%d/host-keys", assetID),
+		action,
+		"asset_host_key",
+		assetID,
+		"",
+		status,
+		message,
+		details,
+		nil,
+		0,
+		false,
+	)
+}
+
+// parseHostKey 解析 authorized_keys 或 known_hosts 格式的主机公钥
+func parseHostKey(line string) (ssh.PublicKey, error) {
+	line = strings.TrimSpace(line)
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+	if err != nil {
+		var marker string
+		marker, _, key, _, _, err = ssh.ParseKnownHosts([]byte(line))
+		if err == nil && marker != "" {
+			err = errors.New("markers are not supported")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid host public key: %v", utils.ErrInvalidParam, err)
+	}
+	if _, ok := key.(*ssh.Certificate); ok {
+		return nil, fmt.Errorf("%w: host certificates are not supported", utils.ErrInvalidParam)
+	}
+	return key, nil
+}
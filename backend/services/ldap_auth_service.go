@@ -0,0 +1,355 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	ldapDefaultTimeout  = 10
+	ldapAccountDisabled = 0x2 // AD userAccountControl 中的 ACCOUNTDISABLE 标志
+)
+
+// ldapConn LDAP连接上认证与同步用到的操作
+// 默认实现为 *ldap.Conn，测试时可替换为进程内的目录实现
+type ldapConn interface {
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close() error
+}
+
+// ldapUser 目录中的用户信息
+type ldapUser struct {
+	DN       string
+	Username string
+	Email    string
+	Phone    string
+	Groups   []string
+	Disabled bool
+}
+
+// LDAPAuthBackend LDAP/Active Directory认证后端
+// 使用服务账号查找用户DN后以用户密码绑定校验，首次登录自动创建本地用户，
+// 每次登录按所属组重新分配角色。
+type LDAPAuthBackend struct {
+	db   *gorm.DB
+	cfg  config.LDAPConfig
+	dial func() (ldapConn, error)
+}
+
+// NewLDAPAuthBackend 创建LDAP认证后端
+func NewLDAPAuthBackend(db *gorm.DB) *LDAPAuthBackend {
+	b := &LDAPAuthBackend{db: db}
+	if config.GlobalConfig != nil {
+		b.cfg = config.GlobalConfig.LDAP
+	}
+	b.dial = b.dialDirectory
+	return b
+}
+
+// Name 后端名称
+func (b *LDAPAuthBackend) Name() string {
+	return models.AuthSourceLDAP
+}
+
+// Enabled 是否启用了LDAP认证
+func (b *LDAPAuthBackend) Enabled() bool {
+	return b.cfg.Enable
+}
+
+// Authenticate 以用户DN和密码绑定目录，成功后同步本地用户和角色
+func (b *LDAPAuthBackend) Authenticate(username, password string) (*models.User, error) {
+	// 空密码会被目录当作匿名绑定而成功
+	if username == "" || password == "" {
+		return nil, errInvalidCredentials
+	}
+
+	conn, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := b.findUser(conn, username)
+	if err != nil {
+		if errors.Is(err, utils.ErrNotFound) {
+			return nil, errInvalidCredentials
+		}
+		return nil, err
+	}
+	if entry.Disabled {
+		return nil, errors.New("user account is disabled in directory")
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, errInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap bind failed: %w", err)
+	}
+
+	// 用户账号不一定有查询组的权限，切回服务账号查询
+	if b.cfg.GroupBaseDN != "" {
+		if err := b.bindService(conn); err != nil {
+			return nil, err
+		}
+		if entry.Groups, err = b.findGroups(conn, entry.DN); err != nil {
+			return nil, err
+		}
+	}
+
+	roles, err := mapGroupRoles(b.db, entry.Groups, b.cfg.GroupMappings, b.cfg.DefaultRoles, ldapGroupMatches)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return nil, errors.New("user is not a member of any authorized ldap group")
+	}
+
+	return provisionExternalUser(b.db, &externalProfile{
+		Source:     models.AuthSourceLDAP,
+		ExternalID: entry.DN,
+		Username:   entry.Username,
+		Email:      entry.Email,
+		Phone:      entry.Phone,
+	}, roles)
+}
+
+// SyncUsers 检查所有启用中的LDAP用户，禁用目录中已删除或已禁用的用户
+// 目录查询出错时立即中止，避免因网络问题误禁用用户
+func (b *LDAPAuthBackend) SyncUsers() (*models.LDAPSyncResult, error) {
+	if !b.cfg.Enable {
+		return nil, fmt.Errorf("%w: ldap authentication is not enabled", utils.ErrInvalidParam)
+	}
+
+	conn, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var users []models.User
+	if err := b.db.Where("auth_source = ? AND status = ?", models.AuthSourceLDAP, 1).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to query ldap users: %w", err)
+	}
+
+	result := &models.LDAPSyncResult{Checked: len(users), DisabledUsers: []string{}}
+	for _, user := range users {
+		entry, err := b.findUser(conn, user.Username)
+		if err == nil && !entry.Disabled {
+			continue
+		}
+		if err != nil && !errors.Is(err, utils.ErrNotFound) {
+			return result, err
+		}
+
+		if err := b.db.Model(&models.User{}).Where("id = ?", user.ID).Update("status", 0).Error; err != nil {
+			return result, fmt.Errorf("failed to disable user %s: %w", user.Username, err)
+		}
+		result.DisabledUsers = append(result.DisabledUsers, user.Username)
+		logrus.WithField("username", user.Username).Info("LDAP同步：目录中已不存在或已禁用，禁用本地用户")
+	}
+
+	return result, nil
+}
+
+// StartSync 按配置的间隔定时同步LDAP用户
+func (b *LDAPAuthBackend) StartSync(ctx context.Context) {
+	if !b.cfg.Enable || b.cfg.SyncInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(b.cfg.SyncInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := b.SyncUsers()
+			if err != nil {
+				logrus.WithError(err).Error("LDAP用户同步失败")
+				continue
+			}
+			logrus.WithFields(logrus.Fields{
+				"checked":  result.Checked,
+				"disabled": len(result.DisabledUsers),
+			}).Info("LDAP用户同步完成")
+		}
+	}
+}
+
+// connect 建立连接并以服务账号绑定
+func (b *LDAPAuthBackend) connect() (ldapConn, error) {
+	conn, err := b.dial()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect ldap server: %w", err)
+	}
+	if err := b.bindService(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// bindService 以服务账号绑定，未配置服务账号时使用匿名查询
+func (b *LDAPAuthBackend) bindService(conn ldapConn) error {
+	if b.cfg.BindDN == "" {
+		return nil
+	}
+	if err := conn.Bind(b.cfg.BindDN, b.cfg.BindPassword); err != nil {
+		return fmt.Errorf("ldap service bind failed: %w", err)
+	}
+	return nil
+}
+
+// dialDirectory 连接配置的LDAP服务器
+func (b *LDAPAuthBackend) dialDirectory() (ldapConn, error) {
+	timeout := time.Duration(b.cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = ldapDefaultTimeout * time.Second
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: b.cfg.InsecureSkipVerify}
+	if u, err := url.Parse(b.cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(b.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(timeout)
+
+	if b.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap starttls failed: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// findUser 按用户名查找目录中的用户，不存在时返回 utils.ErrNotFound
+func (b *LDAPAuthBackend) findUser(conn ldapConn, username string) (*ldapUser, error) {
+	attributes := []string{b.usernameAttr(), "userAccountControl"}
+	for _, attr := range []string{b.cfg.EmailAttr, b.cfg.PhoneAttr, b.cfg.GroupAttr} {
+		if attr != "" {
+			attributes = append(attributes, attr)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		b.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, 0, false,
+		fmt.Sprintf(b.userFilter(), ldap.EscapeFilter(username)), attributes, nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("ldap user search failed: %w", err)
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, utils.ErrNotFound
+	case 1:
+	default:
+		return nil, fmt.Errorf("ldap user search returned multiple entries for %s", username)
+	}
+
+	entry := result.Entries[0]
+	user := &ldapUser{
+		DN:       entry.DN,
+		Username: entry.GetAttributeValue(b.usernameAttr()),
+		Email:    entry.GetAttributeValue(b.cfg.EmailAttr),
+		Phone:    entry.GetAttributeValue(b.cfg.PhoneAttr),
+	}
+	if user.Username == "" {
+		user.Username = username
+	}
+	if b.cfg.GroupAttr != "" {
+		user.Groups = entry.GetAttributeValues(b.cfg.GroupAttr)
+	}
+	if uac, err := strconv.ParseInt(entry.GetAttributeValue("userAccountControl"), 10, 64); err == nil {
+		user.Disabled = uac&ldapAccountDisabled != 0
+	}
+
+	return user, nil
+}
+
+// findGroups 按 groupFilter 查询用户所属组的DN
+func (b *LDAPAuthBackend) findGroups(conn ldapConn, userDN string) ([]string, error) {
+	filter := b.cfg.GroupFilter
+	if filter == "" {
+		filter = "(member=%s)"
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		b.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf(filter, ldap.EscapeFilter(userDN)), []string{"cn"}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ldap group search failed: %w", err)
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.DN)
+	}
+	return groups, nil
+}
+
+// userFilter 用户查询过滤器
+func (b *LDAPAuthBackend) userFilter() string {
+	if b.cfg.UserFilter != "" {
+		return b.cfg.UserFilter
+	}
+	return "(" + b.usernameAttr() + "=%s)"
+}
+
+// usernameAttr 用户名属性
+func (b *LDAPAuthBackend) usernameAttr() string {
+	if b.cfg.UsernameAttr != "" {
+		return b.cfg.UsernameAttr
+	}
+	return "uid"
+}
+
+// ldapGroupMatches 判断组DN是否与映射中的组匹配，映射可以写完整DN或组CN
+func ldapGroupMatches(groupDN, want string) bool {
+	if strings.EqualFold(groupDN, want) {
+		return true
+	}
+
+	dn, err := ldap.ParseDN(groupDN)
+	if err != nil || len(dn.RDNs) == 0 {
+		return false
+	}
+	if wantDN, err := ldap.ParseDN(want); err == nil && len(wantDN.RDNs) > 1 {
+		return dn.EqualFold(wantDN)
+	}
+	for _, attr := range dn.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, "cn") && strings.EqualFold(attr.Value, want) {
+			return true
+		}
+	}
+	return false
+}
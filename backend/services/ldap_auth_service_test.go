@@ -0,0 +1,419 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const (
+	testLDAPBaseDN      = "dc=example,dc=com"
+	testLDAPServiceDN   = "cn=svc,dc=example,dc=com"
+	testLDAPServicePass = "svc-secret"
+)
+
+// testLDAPEntry 测试目录中的条目
+type testLDAPEntry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// testLDAPServer 进程内LDAP服务器，支持简单绑定、按过滤器查询与解绑
+type testLDAPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	entries  []*testLDAPEntry
+	binds    []string
+}
+
+// newTestLDAPServer 在回环地址上启动LDAP服务器
+func newTestLDAPServer(t *testing.T, entries ...*testLDAPEntry) *testLDAPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &testLDAPServer{listener: listener, entries: entries}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+// URL 服务器地址
+func (s *testLDAPServer) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// removeEntry 从目录中删除条目
+func (s *testLDAPServer) removeEntry(dn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// boundDNs 成功绑定过的DN
+func (s *testLDAPServer) boundDNs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+func (s *testLDAPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testLDAPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			code := s.bind(packetString(request.Children[1]), packetString(request.Children[2]))
+			if _, err := conn.Write(ldapMessage(messageID, ldapResult(ldap.ApplicationBindResponse, code)).Bytes()); err != nil {
+				return
+			}
+		case ldap.ApplicationSearchRequest:
+			for _, entry := range s.search(packetString(request.Children[0]), request.Children[6]) {
+				if _, err := conn.Write(ldapMessage(messageID, ldapSearchEntry(entry)).Bytes()); err != nil {
+					return
+				}
+			}
+			if _, err := conn.Write(ldapMessage(messageID, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)).Bytes()); err != nil {
+				return
+			}
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			if _, err := conn.Write(ldapMessage(messageID, ldapResult(ber.Tag(request.Tag+1), ldap.LDAPResultUnwillingToPerform)).Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// bind 简单绑定，空DN为匿名绑定
+func (s *testLDAPServer) bind(dn, password string) uint16 {
+	if dn == "" {
+		return ldap.LDAPResultSuccess
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) && entry.Password != "" && entry.Password == password {
+			s.binds = append(s.binds, entry.DN)
+			return ldap.LDAPResultSuccess
+		}
+	}
+	return ldap.LDAPResultInvalidCredentials
+}
+
+// search 返回 baseDN 下匹配过滤器的条目
+func (s *testLDAPServer) search(baseDN string, filter *ber.Packet) []*testLDAPEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []*testLDAPEntry
+	for _, entry := range s.entries {
+		if strings.HasSuffix(strings.ToLower(entry.DN), strings.ToLower(baseDN)) && matchLDAPFilter(entry, filter) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// matchLDAPFilter 支持与、或、非、相等与存在过滤器
+func matchLDAPFilter(entry *testLDAPEntry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchLDAPFilter(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchLDAPFilter(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matchLDAPFilter(entry, filter.Children[0])
+	case ldap.FilterEqualityMatch:
+		want := packetString(filter.Children[1])
+		for _, value := range entryAttribute(entry, packetString(filter.Children[0])) {
+			if strings.EqualFold(value, want) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(entryAttribute(entry, packetString(filter))) > 0
+	default:
+		return false
+	}
+}
+
+// entryAttribute 按属性名（不区分大小写）获取属性值
+func entryAttribute(entry *testLDAPEntry, name string) []string {
+	for attr, values := range entry.Attributes {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func packetString(packet *ber.Packet) string {
+	return string(packet.Data.Bytes())
+}
+
+func ldapMessage(messageID int64, op *ber.Packet) *ber.Packet {
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	message.AppendChild(op)
+	return message
+}
+
+func ldapResult(tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, uint64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return op
+}
+
+func ldapSearchEntry(entry *testLDAPEntry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "Object Name"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range entry.Attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	op.AppendChild(attributes)
+	return op
+}
+
+// testLDAPDirectory 测试目录：服务账号、运维组成员、已禁用用户、无组用户和组条目
+func testLDAPDirectory() []*testLDAPEntry {
+	return []*testLDAPEntry{
+		{DN: testLDAPServiceDN, Password: testLDAPServicePass, Attributes: map[string][]string{"cn": {"svc"}}},
+		{DN: "uid=alice,ou=people,dc=example,dc=com", Password: "alice-pass", Attributes: map[string][]string{
+			"objectClass": {"person"}, "uid": {"alice"}, "mail": {"alice@example.com"}, "telephoneNumber": {"13800000000"},
+			"memberOf": {"cn=ops,ou=groups,dc=example,dc=com"},
+		}},
+		{DN: "uid=bob,ou=people,dc=example,dc=com", Password: "bob-pass", Attributes: map[string][]string{
+			"objectClass": {"person"}, "uid": {"bob"}, "userAccountControl": {"514"},
+			"memberOf": {"cn=ops,ou=groups,dc=example,dc=com"},
+		}},
+		{DN: "uid=carol,ou=people,dc=example,dc=com", Password: "carol-pass", Attributes: map[string][]string{
+			"objectClass": {"person"}, "uid": {"carol"},
+		}},
+		{DN: "cn=ops,ou=groups,dc=example,dc=com", Attributes: map[string][]string{
+			"cn": {"ops"}, "member": {"uid=alice,ou=people,dc=example,dc=com", "uid=bob,ou=people,dc=example,dc=com"},
+		}},
+		{DN: "cn=dba,ou=groups,dc=example,dc=com", Attributes: map[string][]string{
+			"cn": {"dba"}, "member": {"uid=carol,ou=people,dc=example,dc=com"},
+		}},
+	}
+}
+
+// setupLDAPBackend 启动测试目录并创建连接它的LDAP认证后端
+func setupLDAPBackend(t *testing.T, configure func(cfg *config.LDAPConfig)) (*LDAPAuthBackend, *testLDAPServer, *gorm.DB) {
+	t.Helper()
+	setupTestConfig(t)
+	server := newTestLDAPServer(t, testLDAPDirectory()...)
+
+	config.GlobalConfig.LDAP = config.LDAPConfig{
+		Enable:        true,
+		URL:           server.URL(),
+		Timeout:       2,
+		BindDN:        testLDAPServiceDN,
+		BindPassword:  testLDAPServicePass,
+		BaseDN:        testLDAPBaseDN,
+		UserFilter:    "(&(objectClass=person)(uid=%s))",
+		UsernameAttr:  "uid",
+		EmailAttr:     "mail",
+		PhoneAttr:     "telephoneNumber",
+		GroupAttr:     "memberOf",
+		GroupMappings: []config.GroupRoleMapping{{Group: "ops", Role: "operator"}, {Group: "cn=dba,ou=groups,dc=example,dc=com", Role: "dba"}},
+	}
+	if configure != nil {
+		configure(&config.GlobalConfig.LDAP)
+	}
+
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{})
+	for _, name := range []string{"operator", "dba", "viewer"} {
+		require.NoError(t, db.Create(&models.Role{Name: name}).Error)
+	}
+	return NewLDAPAuthBackend(db), server, db
+}
+
+// userRoleNames 用户当前的角色名
+func userRoleNames(t *testing.T, db *gorm.DB, userID uint) []string {
+	t.Helper()
+	var user models.User
+	require.NoError(t, db.Preload("Roles").First(&user, userID).Error)
+	names := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+func TestLDAPAuthenticateProvisionsUserWithMappedRoles(t *testing.T) {
+	backend, server, db := setupLDAPBackend(t, nil)
+
+	user, err := backend.Authenticate("alice", "alice-pass")
+	require.NoError(t, err)
+	require.Equal(t, "alice", user.Username)
+	require.Equal(t, models.AuthSourceLDAP, user.AuthSource)
+	require.Equal(t, "uid=alice,ou=people,dc=example,dc=com", user.ExternalID)
+	require.Equal(t, "alice@example.com", user.Email)
+	require.Equal(t, "13800000000", user.Phone)
+	require.Equal(t, []string{"operator"}, userRoleNames(t, db, user.ID))
+	require.Equal(t, []string{testLDAPServiceDN, "uid=alice,ou=people,dc=example,dc=com"}, server.boundDNs())
+
+	// 再次登录更新同一个本地用户
+	again, err := backend.Authenticate("alice", "alice-pass")
+	require.NoError(t, err)
+	require.Equal(t, user.ID, again.ID)
+}
+
+func TestLDAPAuthenticateRejectsInvalidCredentials(t *testing.T) {
+	backend, _, db := setupLDAPBackend(t, nil)
+
+	for name, credentials := range map[string][2]string{
+		"wrong password":   {"alice", "wrong"},
+		"empty password":   {"alice", ""},
+		"unknown user":     {"mallory", "alice-pass"},
+		"filter injection": {"*", "alice-pass"},
+		"injected or":      {"alice)(|(uid=*", "alice-pass"},
+	} {
+		_, err := backend.Authenticate(credentials[0], credentials[1])
+		require.ErrorIs(t, err, errInvalidCredentials, name)
+	}
+
+	var count int64
+	require.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+	require.Zero(t, count)
+}
+
+func TestLDAPAuthenticateRejectsDisabledAndUnmappedUsers(t *testing.T) {
+	backend, _, _ := setupLDAPBackend(t, nil)
+
+	_, err := backend.Authenticate("bob", "bob-pass")
+	require.EqualError(t, err, "user account is disabled in directory")
+
+	_, err = backend.Authenticate("carol", "carol-pass")
+	require.EqualError(t, err, "user is not a member of any authorized ldap group")
+}
+
+func TestLDAPAuthenticateUsesDefaultRoles(t *testing.T) {
+	backend, _, db := setupLDAPBackend(t, func(cfg *config.LDAPConfig) {
+		cfg.DefaultRoles = []string{"viewer"}
+	})
+
+	user, err := backend.Authenticate("carol", "carol-pass")
+	require.NoError(t, err)
+	require.Equal(t, []string{"viewer"}, userRoleNames(t, db, user.ID))
+}
+
+func TestLDAPAuthenticateSearchesGroupsWithServiceAccount(t *testing.T) {
+	backend, server, db := setupLDAPBackend(t, func(cfg *config.LDAPConfig) {
+		cfg.GroupAttr = ""
+		cfg.GroupBaseDN = "ou=groups,dc=example,dc=com"
+		cfg.GroupFilter = "(member=%s)"
+	})
+
+	user, err := backend.Authenticate("carol", "carol-pass")
+	require.NoError(t, err)
+	require.Equal(t, []string{"dba"}, userRoleNames(t, db, user.ID))
+	// 用户绑定后切回服务账号查询组
+	require.Equal(t, []string{testLDAPServiceDN, "uid=carol,ou=people,dc=example,dc=com", testLDAPServiceDN}, server.boundDNs())
+}
+
+func TestLDAPAuthenticateDoesNotTakeOverLocalAccount(t *testing.T) {
+	backend, _, db := setupLDAPBackend(t, nil)
+	require.NoError(t, db.Create(&models.User{Username: "alice", Password: "x", Status: 1, AuthSource: models.AuthSourceLocal}).Error)
+
+	_, err := backend.Authenticate("alice", "alice-pass")
+	require.ErrorIs(t, err, errInvalidCredentials)
+}
+
+func TestLDAPServiceBindFailure(t *testing.T) {
+	backend, _, _ := setupLDAPBackend(t, func(cfg *config.LDAPConfig) {
+		cfg.BindPassword = "wrong"
+	})
+
+	_, err := backend.Authenticate("alice", "alice-pass")
+	require.ErrorContains(t, err, "ldap service bind failed")
+}
+
+func TestLDAPSyncUsersDisablesRemovedAndDisabledUsers(t *testing.T) {
+	backend, server, db := setupLDAPBackend(t, nil)
+
+	alice, err := backend.Authenticate("alice", "alice-pass")
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.User{Username: "bob", Password: "x", Status: 1, AuthSource: models.AuthSourceLDAP}).Error)
+	require.NoError(t, db.Create(&models.User{Username: "carol", Password: "x", Status: 1, AuthSource: models.AuthSourceLDAP}).Error)
+	require.NoError(t, db.Create(&models.User{Username: "dave", Password: "x", Status: 1, AuthSource: models.AuthSourceLocal}).Error)
+	server.removeEntry("uid=carol,ou=people,dc=example,dc=com")
+
+	result, err := backend.SyncUsers()
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Checked)
+	require.ElementsMatch(t, []string{"bob", "carol"}, result.DisabledUsers)
+
+	var active []string
+	require.NoError(t, db.Model(&models.User{}).Where("status = ?", 1).Order("username").Pluck("username", &active).Error)
+	require.Equal(t, []string{alice.Username, "dave"}, active)
+}
+
+func TestLDAPSyncUsersAbortsWhenDirectoryUnavailable(t *testing.T) {
+	backend, server, db := setupLDAPBackend(t, nil)
+	require.NoError(t, db.Create(&models.User{Username: "alice", Password: "x", Status: 1, AuthSource: models.AuthSourceLDAP}).Error)
+	server.listener.Close()
+
+	_, err := backend.SyncUsers()
+	require.ErrorContains(t, err, "failed to connect ldap server")
+
+	var status int
+	require.NoError(t, db.Model(&models.User{}).Where("username = ?", "alice").Pluck("status", &status).Error)
+	require.Equal(t, 1, status)
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	loginFailKeyPrefix  = "login:fail:"
+	loginLockKeyPrefix  = "login:lock:"
+	loginDelayKeyPrefix = "login:delay:"
+)
+
+// LoginBlockedError 登录被锁定或限速，Status 对应登录日志状态
+type LoginBlockedError struct {
+	Status     string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return e.Reason
+}
+
+// loginLockState 锁定记录，保存在锁定键中
+type loginLockState struct {
+	Attempts int   `json:"attempts"`
+	LockedAt int64 `json:"locked_at"`
+}
+
+// LoginGuardService 登录防暴力破解
+// 按用户名和来源IP分别在Redis中累计失败次数：连续失败后要求等待逐次翻倍的时间，
+// 达到上限后临时锁定，锁定到期自动解除，管理员也可提前解锁。
+// 来源IP须为可信地址（Web登录取自 utils.GetClientIP，SSH网关取自TCP连接），
+// IPv6地址按/64网段计数，避免攻击者在自己的网段内轮换地址绕过IP锁定。
+// Redis不可用时不拦截登录，只记录日志。
+type LoginGuardService struct {
+	db  *gorm.DB
+	cfg config.LoginLockoutConfig
+}
+
+// NewLoginGuardService 创建登录防暴力破解服务
+func NewLoginGuardService(db *gorm.DB) *LoginGuardService {
+	g := &LoginGuardService{db: db}
+	if config.GlobalConfig != nil {
+		g.cfg = config.GlobalConfig.Security.LoginLockout
+	}
+	return g
+}
+
+// Check 校验密码前检查用户名和IP是否被锁定或仍需等待
+func (g *LoginGuardService) Check(username, clientIP string) error {
+	if !g.enabled() {
+		return nil
+	}
+	ctx := context.Background()
+	rdb := utils.GetRedis()
+	clientIP = loginGuardIP(clientIP)
+
+	if clientIP != "" {
+		if ttl := g.ttl(ctx, rdb, loginLockKeyPrefix+models.LoginLockTypeIP+":"+clientIP); ttl > 0 {
+			return &LoginBlockedError{
+				Status:     models.LoginStatusLocked,
+				Reason:     fmt.Sprintf("too many failed login attempts from this address, try again in %s", formatRetryAfter(ttl)),
+				RetryAfter: ttl,
+			}
+		}
+	}
+
+	user := normalizeLoginName(username)
+	if ttl := g.ttl(ctx, rdb, loginLockKeyPrefix+models.LoginLockTypeUser+":"+user); ttl > 0 {
+		return &LoginBlockedError{
+			Status:     models.LoginStatusLocked,
+			Reason:     fmt.Sprintf("account is locked due to too many failed login attempts, try again in %s", formatRetryAfter(ttl)),
+			RetryAfter: ttl,
+		}
+	}
+	if ttl := g.ttl(ctx, rdb, loginDelayKeyPrefix+user); ttl > 0 {
+		return &LoginBlockedError{
+			Status:     models.LoginStatusThrottled,
+			Reason:     fmt.Sprintf("too many failed login attempts, please wait %s before retrying", formatRetryAfter(ttl)),
+			RetryAfter: ttl,
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure 记录一次密码错误，触发锁定时返回 LoginBlockedError，否则原样返回 cause
+func (g *LoginGuardService) RecordFailure(username, clientIP string, cause error) error {
+	if !g.enabled() {
+		return cause
+	}
+	ctx := context.Background()
+	rdb := utils.GetRedis()
+	user := normalizeLoginName(username)
+	clientIP = loginGuardIP(clientIP)
+
+	var blocked *LoginBlockedError
+
+	if clientIP != "" && g.cfg.IPMaxAttempts > 0 {
+		attempts, err := g.incrFailures(ctx, rdb, models.LoginLockTypeIP, clientIP)
+		if err == nil && attempts >= g.cfg.IPMaxAttempts {
+			g.lock(ctx, rdb, models.LoginLockTypeIP, clientIP, attempts)
+			go g.notifyIPLocked(clientIP, attempts)
+			blocked = &LoginBlockedError{
+				Status:     models.LoginStatusLocked,
+				Reason:     fmt.Sprintf("%v; address locked for %s after %d failed attempts", cause, formatRetryAfter(g.lockDuration()), attempts),
+				RetryAfter: g.lockDuration(),
+			}
+		}
+	}
+
+	attempts, err := g.incrFailures(ctx, rdb, models.LoginLockTypeUser, user)
+	if err != nil {
+		if blocked != nil {
+			return blocked
+		}
+		return cause
+	}
+
+	if g.cfg.MaxAttempts > 0 && attempts >= g.cfg.MaxAttempts {
+		g.lock(ctx, rdb, models.LoginLockTypeUser, user, attempts)
+		go g.notifyUserLocked(user, attempts)
+		return &LoginBlockedError{
+			Status:     models.LoginStatusLocked,
+			Reason:     fmt.Sprintf("%v; account locked for %s after %d failed attempts", cause, formatRetryAfter(g.lockDuration()), attempts),
+			RetryAfter: g.lockDuration(),
+		}
+	}
+	if blocked != nil {
+		return blocked
+	}
+
+	// 超过免等待次数后，下一次尝试前需要等待，等待时间逐次翻倍
+	if g.cfg.DelayAfter > 0 && attempts > g.cfg.DelayAfter {
+		delay := g.delayFor(attempts)
+		if err := rdb.Set(ctx, loginDelayKeyPrefix+user, attempts, delay).Err(); err != nil {
+			logrus.WithError(err).Warn("记录登录等待时间失败")
+		}
+	}
+
+	return cause
+}
+
+// RecordSuccess 登录成功后清除用户名的失败计数
+// IP计数不清除，避免攻击者用自己的账号登录来重置计数
+func (g *LoginGuardService) RecordSuccess(username string) {
+	if !g.enabled() {
+		return
+	}
+	user := normalizeLoginName(username)
+	rdb := utils.GetRedis()
+	if err := rdb.Del(context.Background(), loginFailKeyPrefix+models.LoginLockTypeUser+":"+user, loginDelayKeyPrefix+user).Err(); err != nil {
+		logrus.WithError(err).Warn("清除登录失败计数失败")
+	}
+}
+
+// UnlockUser 解除用户名的锁定并清零失败计数
+func (g *LoginGuardService) UnlockUser(username string) error {
+	user := normalizeLoginName(username)
+	return g.unlock(models.LoginLockTypeUser, user, loginDelayKeyPrefix+user)
+}
+
+// UnlockUserByID 按用户ID解除锁定
+func (g *LoginGuardService) UnlockUserByID(userID uint) (*models.User, error) {
+	var user models.User
+	if err := g.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if err := g.UnlockUser(user.Username); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UnlockIP 解除IP的锁定并清零失败计数
+func (g *LoginGuardService) UnlockIP(clientIP string) error {
+	return g.unlock(models.LoginLockTypeIP, loginGuardIP(clientIP))
+}
+
+// ListLocks 列出当前处于锁定状态的用户名和IP
+func (g *LoginGuardService) ListLocks() ([]models.LoginLockResponse, error) {
+	ctx := context.Background()
+	rdb := utils.GetRedis()
+
+	locks := []models.LoginLockResponse{}
+	iter := rdb.Scan(ctx, 0, loginLockKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		lockType, target, ok := strings.Cut(strings.TrimPrefix(key, loginLockKeyPrefix), ":")
+		if !ok {
+			continue
+		}
+
+		data, err := rdb.Get(ctx, key).Bytes()
+		if err != nil {
+			continue // 扫描期间已过期
+		}
+		ttl := g.ttl(ctx, rdb, key)
+		if ttl <= 0 {
+			continue
+		}
+
+		var state loginLockState
+		json.Unmarshal(data, &state)
+		locks = append(locks, models.LoginLockResponse{
+			Type:      lockType,
+			Target:    target,
+			Attempts:  state.Attempts,
+			LockedAt:  time.Unix(state.LockedAt, 0),
+			ExpiresAt: time.Now().Add(ttl),
+		})
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list login locks: %w", err)
+	}
+
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].LockedAt.After(locks[j].LockedAt)
+	})
+	return locks, nil
+}
+
+// incrFailures 累计失败次数，计数在首次失败后的窗口期内有效
+func (g *LoginGuardService) incrFailures(ctx context.Context, rdb *redis.Client, lockType, target string) (int, error) {
+	key := loginFailKeyPrefix + lockType + ":" + target
+	attempts, err := rdb.Incr(ctx, key).Result()
+	if err != nil {
+		logrus.WithError(err).Warn("记录登录失败次数失败")
+		return 0, err
+	}
+	if attempts == 1 {
+		rdb.Expire(ctx, key, g.window())
+	}
+	return int(attempts), nil
+}
+
+// lock 锁定用户名或IP，锁定后失败计数重新开始
+func (g *LoginGuardService) lock(ctx context.Context, rdb *redis.Client, lockType, target string, attempts int) {
+	data, _ := json.Marshal(loginLockState{Attempts: attempts, LockedAt: time.Now().Unix()})
+	if err := rdb.Set(ctx, loginLockKeyPrefix+lockType+":"+target, data, g.lockDuration()).Err(); err != nil {
+		logrus.WithError(err).Warn("记录登录锁定失败")
+		return
+	}
+	rdb.Del(ctx, loginFailKeyPrefix+lockType+":"+target)
+	if lockType == models.LoginLockTypeUser {
+		rdb.Del(ctx, loginDelayKeyPrefix+target)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"type":     lockType,
+		"target":   target,
+		"attempts": attempts,
+	}).Warn("登录失败次数过多，已临时锁定")
+}
+
+// unlock 删除锁定、失败计数及额外的键
+func (g *LoginGuardService) unlock(lockType, target string, extraKeys ...string) error {
+	if target == "" {
+		return fmt.Errorf("%w: unlock target is required", utils.ErrInvalidParam)
+	}
+	keys := append([]string{
+		loginLockKeyPrefix + lockType + ":" + target,
+		loginFailKeyPrefix + lockType + ":" + target,
+	}, extraKeys...)
+	if err := utils.GetRedis().Del(context.Background(), keys...).Err(); err != nil {
+		return fmt.Errorf("failed to unlock %s %s: %w", lockType, target, err)
+	}
+	return nil
+}
+
+// notifyUserLocked 账号锁定通知
+func (g *LoginGuardService) notifyUserLocked(username string, attempts int) {
+	sender := notifier()
+	if sender == nil {
+		return
+	}
+
+	var user models.User
+	if err := g.db.Select("id").Where("username = ?", username).First(&user).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logrus.WithError(err).Warn("查询被锁定的用户失败")
+	}
+	if err := sender.NotifyFailedLogin(context.Background(), user.ID, attempts); err != nil {
+		logrus.WithError(err).Warn("发送登录锁定通知失败")
+	}
+}
+
+// notifyIPLocked IP锁定通知
+func (g *LoginGuardService) notifyIPLocked(clientIP string, attempts int) {
+	sender := notifier()
+	if sender == nil {
+		return
+	}
+	if err := sender.NotifySecurityEvent(context.Background(), "login_ip_locked", map[string]interface{}{
+		"ip":       clientIP,
+		"attempts": attempts,
+	}); err != nil {
+		logrus.WithError(err).Warn("发送登录锁定通知失败")
+	}
+}
+
+// ttl 键的剩余有效期，不存在或查询失败时返回0
+func (g *LoginGuardService) ttl(ctx context.Context, rdb *redis.Client, key string) time.Duration {
+	ttl, err := rdb.PTTL(ctx, key).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logrus.WithError(err).Warn("查询登录锁定状态失败")
+		}
+		return 0
+	}
+	return ttl
+}
+
+// delayFor 第 attempts 次失败后需要等待的时间
+func (g *LoginGuardService) delayFor(attempts int) time.Duration {
+	maxDelay := time.Duration(g.cfg.MaxDelay) * time.Second
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+
+	delay := time.Second
+	for i := g.cfg.DelayAfter + 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// enabled 是否启用登录锁定
+func (g *LoginGuardService) enabled() bool {
+	return g.cfg.Enable && utils.GetRedis() != nil
+}
+
+// window 失败计数窗口
+func (g *LoginGuardService) window() time.Duration {
+	if g.cfg.Window > 0 {
+		return time.Duration(g.cfg.Window) * time.Second
+	}
+	return 15 * time.Minute
+}
+
+// lockDuration 锁定时长
+func (g *LoginGuardService) lockDuration() time.Duration {
+	if g.cfg.LockDuration > 0 {
+		return time.Duration(g.cfg.LockDuration) * time.Second
+	}
+	return 15 * time.Minute
+}
+
+// normalizeLoginName 用户名不区分大小写计数，避免通过大小写变化绕过锁定
+func normalizeLoginName(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// loginGuardIP IP失败计数使用的键：IPv4为地址本身，IPv6为所在的/64网段
+func loginGuardIP(clientIP string) string {
+	clientIP = strings.TrimSpace(clientIP)
+	if _, network, err := net.ParseCIDR(clientIP); err == nil {
+		// 管理员按列表中显示的网段解锁
+		clientIP = network.IP.String()
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return clientIP
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.String()
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// formatRetryAfter 剩余等待时间，向上取整到秒
+func formatRetryAfter(d time.Duration) string {
+	return (d + time.Second - 1).Truncate(time.Second).String()
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// LoginSessionService Web登录会话服务
+// 会话在访问令牌首次使用时登记到Redis，注销会话即将令牌加入黑名单
+type LoginSessionService struct {
+	db *gorm.DB
+}
+
+// NewLoginSessionService 创建登录会话服务实例
+func NewLoginSessionService(db *gorm.DB) *LoginSessionService {
+	return &LoginSessionService{db: db}
+}
+
+// GetSessions 获取用户当前有效的登录会话，currentID 为当前请求令牌的jti
+func (s *LoginSessionService) GetSessions(userID uint, currentID string) ([]*models.LoginSessionResponse, error) {
+	sessions, err := utils.ListLoginSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*models.LoginSessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = &models.LoginSessionResponse{LoginSession: session, Current: session.ID == currentID}
+	}
+	return responses, nil
+}
+
+// RevokeSession 注销用户的指定登录会话，返回被注销的会话
+func (s *LoginSessionService) RevokeSession(userID uint, sessionID string) (*models.LoginSession, error) {
+	return utils.RevokeLoginSession(userID, sessionID)
+}
+
+// RevokeUserSessions 注销用户的全部登录会话，返回用户和注销的会话数
+func (s *LoginSessionService) RevokeUserSessions(userID uint) (*models.User, int, error) {
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, utils.ErrNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	count, err := utils.RevokeUserLoginSessions(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &user, count, nil
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaTOTPPeriod         = 30 // TOTP时间步长，秒（RFC 6238默认值）
+	mfaTOTPSkew           = 1  // 允许前后各一个时间步的时钟偏差
+	mfaRecoveryCodeCount  = 10
+	mfaRecoveryCodeBytes  = 5
+	mfaQRCodeSize         = 200
+	mfaDefaultIssuer      = "Bastion"
+	mfaDefaultPreAuthTTL  = 300
+	mfaDefaultMaxAttempts = 5
+)
+
+// MFAService TOTP多因素认证服务
+type MFAService struct {
+	db *gorm.DB
+}
+
+// NewMFAService 创建MFA服务实例
+func NewMFAService(db *gorm.DB) *MFAService {
+	return &MFAService{db: db}
+}
+
+// IsEnabled 检查用户是否已绑定认证器
+func (s *MFAService) IsEnabled(userID uint) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.UserMFA{}).Where("user_id = ? AND enabled = ?", userID, true).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to query mfa: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetStatus 获取用户MFA状态
+func (s *MFAService) GetStatus(user *models.User) (*models.MFAStatusResponse, error) {
+	status := &models.MFAStatusResponse{Required: user.RequiresMFA()}
+
+	mfa, err := s.getMFA(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return status, nil
+	}
+
+	status.Enabled = true
+	status.EnabledAt = mfa.EnabledAt
+	status.LastUsedAt = mfa.LastUsedAt
+	if err := s.db.Model(&models.UserMFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Count(&status.RecoveryCodesRemaining).Error; err != nil {
+		return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return status, nil
+}
+
+// BeginSetup 生成新的TOTP密钥，用户使用验证码确认后才会启用
+func (s *MFAService) BeginSetup(user *models.User) (*models.MFASetupResponse, error) {
+	mfa, err := s.getMFA(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, fmt.Errorf("%w: mfa is already enabled", utils.ErrInvalidParam)
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      mfaIssuer(),
+		AccountName: user.Username,
+		Period:      mfaTOTPPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+
+	encrypted, err := utils.EncryptPassword(key.Secret())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+
+	// 未完成绑定的密钥直接覆盖
+	if mfa == nil {
+		mfa = &models.UserMFA{UserID: user.ID, Secret: encrypted}
+		if err := s.db.Create(mfa).Error; err != nil {
+			return nil, fmt.Errorf("failed to save mfa: %w", err)
+		}
+	} else if err := s.db.Model(mfa).Updates(map[string]interface{}{
+		"secret":         encrypted,
+		"last_used_step": 0,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to save mfa: %w", err)
+	}
+
+	qrCode, err := encodeQRCode(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MFASetupResponse{
+		Secret:          key.Secret(),
+		ProvisioningURI: key.URL(),
+		QRCode:          qrCode,
+	}, nil
+}
+
+// Enable 校验认证器生成的验证码并启用MFA，返回恢复码
+func (s *MFAService) Enable(userID uint, code string) (*models.MFARecoveryCodesResponse, error) {
+	mfa, err := s.getMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, fmt.Errorf("%w: mfa setup has not been started", utils.ErrInvalidParam)
+	}
+	if mfa.Enabled {
+		return nil, fmt.Errorf("%w: mfa is already enabled", utils.ErrInvalidParam)
+	}
+
+	if err := s.verifyTOTP(mfa, normalizeMFACode(code)); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(mfa).Updates(map[string]interface{}{
+			"enabled":    true,
+			"enabled_at": &now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to enable mfa: %w", err)
+		}
+
+		var genErr error
+		codes, genErr = replaceRecoveryCodes(tx, userID)
+		return genErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Disable 用户自行解绑认证器，角色强制MFA时不允许解绑
+func (s *MFAService) Disable(user *models.User, code string) error {
+	if user.RequiresMFA() {
+		return fmt.Errorf("%w: mfa is required by role policy", utils.ErrPermissionDenied)
+	}
+	if _, err := s.Verify(user.ID, code); err != nil {
+		return err
+	}
+
+	return s.deleteMFA(user.ID)
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧恢复码全部失效
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code string) (*models.MFARecoveryCodesResponse, error) {
+	if _, err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Reset 管理员重置用户MFA，用户下次登录需重新绑定（角色强制时）
+func (s *MFAService) Reset(userID uint) (*models.User, error) {
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if err := s.deleteMFA(userID); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Verify 校验TOTP验证码或恢复码，返回是否使用了恢复码
+func (s *MFAService) Verify(userID uint, code string) (bool, error) {
+	mfa, err := s.getMFA(userID)
+	if err != nil {
+		return false, err
+	}
+	if mfa == nil || !mfa.Enabled {
+		return false, fmt.Errorf("%w: mfa is not enabled", utils.ErrInvalidParam)
+	}
+
+	code = normalizeMFACode(code)
+	if isTOTPCode(code) {
+		return false, s.verifyTOTP(mfa, code)
+	}
+	return true, s.useRecoveryCode(userID, code)
+}
+
+// verifyTOTP 校验TOTP验证码，同一时间步的验证码只能使用一次
+func (s *MFAService) verifyTOTP(mfa *models.UserMFA, code string) error {
+	secret, err := utils.DecryptPassword(mfa.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+
+	step, ok := matchTOTPStep(secret, code, time.Now())
+	if !ok {
+		return fmt.Errorf("%w: invalid verification code", utils.ErrInvalidParam)
+	}
+
+	now := time.Now()
+	result := s.db.Model(&models.UserMFA{}).
+		Where("id = ? AND last_used_step < ?", mfa.ID, step).
+		Updates(map[string]interface{}{
+			"last_used_step": step,
+			"last_used_at":   &now,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update mfa: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: verification code has already been used", utils.ErrInvalidParam)
+	}
+
+	return nil
+}
+
+// useRecoveryCode 使用一个未使用过的恢复码
+func (s *MFAService) useRecoveryCode(userID uint, code string) error {
+	result := s.db.Model(&models.UserMFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to update recovery code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: invalid verification code", utils.ErrInvalidParam)
+	}
+	return nil
+}
+
+// getMFA 获取用户MFA配置，不存在时返回nil
+func (s *MFAService) getMFA(userID uint) (*models.UserMFA, error) {
+	var mfa models.UserMFA
+	if err := s.db.Where("user_id = ?", userID).First(&mfa).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query mfa: %w", err)
+	}
+	return &mfa, nil
+}
+
+// deleteMFA 删除用户的认证器与恢复码
+func (s *MFAService) deleteMFA(userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserMFARecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserMFA{}).Error; err != nil {
+			return fmt.Errorf("failed to delete mfa: %w", err)
+		}
+		return nil
+	})
+}
+
+// replaceRecoveryCodes 生成新的恢复码并替换旧恢复码，返回明文
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UserMFARecoveryCode{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	codes := make([]string, mfaRecoveryCodeCount)
+	records := make([]models.UserMFARecoveryCode, mfaRecoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, mfaRecoveryCodeBytes)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := hex.EncodeToString(buf)
+		codes[i] = raw[:5] + "-" + raw[5:]
+		records[i] = models.UserMFARecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(raw)}
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// matchTOTPStep 在允许的时钟偏差内匹配验证码，返回匹配到的时间步
+func matchTOTPStep(secret, code string, now time.Time) (int64, bool) {
+	opts := totp.ValidateOpts{
+		Period:    mfaTOTPPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+	for offset := -mfaTOTPSkew; offset <= mfaTOTPSkew; offset++ {
+		t := now.Add(time.Duration(offset*mfaTOTPPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, t, opts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return t.Unix() / mfaTOTPPeriod, true
+		}
+	}
+	return 0, false
+}
+
+// encodeQRCode 将otpauth地址编码为二维码PNG的data URI
+func encodeQRCode(key *otp.Key) (string, error) {
+	img, err := key.Image(mfaQRCodeSize, mfaQRCodeSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate qr code: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("failed to encode qr code: %w", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// normalizeMFACode 去除验证码中的空格和连字符
+func normalizeMFACode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+// isTOTPCode 6位纯数字视为TOTP验证码，其余按恢复码处理
+func isTOTPCode(code string) bool {
+	if len(code) != int(otp.DigitsSix) {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// hashRecoveryCode 计算恢复码摘要
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// mfaIssuer 认证器中显示的发行方名称
+func mfaIssuer() string {
+	if issuer := config.GlobalConfig.Security.MFA.Issuer; issuer != "" {
+		return issuer
+	}
+	return mfaDefaultIssuer
+}
+
+// mfaPreAuthExpire 预认证令牌有效期，秒
+func mfaPreAuthExpire() int {
+	if expire := config.GlobalConfig.Security.MFA.PreAuthExpire; expire > 0 {
+		return expire
+	}
+	return mfaDefaultPreAuthTTL
+}
+
+// mfaMaxAttempts 单个预认证令牌允许的验证失败次数
+func mfaMaxAttempts() int64 {
+	if attempts := config.GlobalConfig.Security.MFA.MaxAttempts; attempts > 0 {
+		return int64(attempts)
+	}
+	return mfaDefaultMaxAttempts
+}
@@ -469,9 +469,29 @@ func (m *MonitorService) TerminateSession(sessionID string, adminUserID uint, re
 		}).Info("已发送精确的会话终止通知")
 	}
 
-	// 实际关闭 SSH 连接
-	if m.sshService != nil {
-		if err := m.sshService.CloseSessionWithReason(sessionID, req.Reason); err != nil {
+	// 端口转发隧道由端口转发服务持有，关闭隧道上的所有通道
+	if session.Protocol == models.TunnelProtocol {
+		if GlobalPortForwardService == nil || !GlobalPortForwardService.CloseTunnel(sessionID, req.Reason) {
+			logrus.WithField("session_id", sessionID).Warn("未找到端口转发隧道，但会话已标记为终止")
+		}
+		return nil
+	}
+
+	// 数据库代理会话由数据库代理服务持有，断开客户端与数据库之间的连接
+	if models.IsDatabaseProtocol(session.Protocol) {
+		if GlobalDatabaseProxyService == nil || !GlobalDatabaseProxyService.CloseSession(sessionID, req.Reason) {
+			logrus.WithField("session_id", sessionID).Warn("未找到数据库代理会话，但会话已标记为终止")
+		}
+		return nil
+	}
+
+	// 实际关闭 SSH 连接（优先使用持有会话连接的全局SSH服务）
+	sshService := m.sshService
+	if GlobalSSHService != nil {
+		sshService = GlobalSSHService
+	}
+	if sshService != nil {
+		if err := sshService.CloseSessionWithReason(sessionID, req.Reason); err != nil {
 			// 记录详细错误信息
 			logrus.WithError(err).WithFields(logrus.Fields{
 				"session_id": sessionID,
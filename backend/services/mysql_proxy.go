@@ -0,0 +1,859 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MySQL客户端/服务端协议常量
+const (
+	mysqlMaxPacketSize = 1<<24 - 1
+
+	mysqlComQuit        = 0x01
+	mysqlComInitDB      = 0x02
+	mysqlComQuery       = 0x03
+	mysqlComStmtPrepare = 0x16
+
+	mysqlPacketOK         = 0x00
+	mysqlPacketAuthMore   = 0x01
+	mysqlPacketAuthSwitch = 0xfe
+	mysqlPacketERR        = 0xff
+
+	mysqlNativePassword         = "mysql_native_password"
+	mysqlCachingSHA2Password    = "caching_sha2_password"
+	mysqlDefaultCharset         = 45 // utf8mb4_general_ci
+	mysqlServerStatusAutocommit = 0x0002
+)
+
+// MySQL能力标志
+const (
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientFoundRows        = 0x00000002
+	mysqlClientLongFlag         = 0x00000004
+	mysqlClientConnectWithDB    = 0x00000008
+	mysqlClientCompress         = 0x00000020
+	mysqlClientLocalFiles       = 0x00000080
+	mysqlClientIgnoreSpace      = 0x00000100
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientInteractive      = 0x00000400
+	mysqlClientSSL              = 0x00000800
+	mysqlClientTransactions     = 0x00002000
+	mysqlClientSecureConnection = 0x00008000
+	mysqlClientMultiStatements  = 0x00010000
+	mysqlClientMultiResults     = 0x00020000
+	mysqlClientPSMultiResults   = 0x00040000
+	mysqlClientPluginAuth       = 0x00080000
+	mysqlClientConnectAttrs     = 0x00100000
+	mysqlClientPluginAuthLenenc = 0x00200000
+)
+
+// mysqlProxyCapabilities 代理向客户端声明的能力
+// 不支持SSL与压缩；不声明 DEPRECATE_EOF、SESSION_TRACK 等扩展，使转发的响应格式保持最简单的形式
+const mysqlProxyCapabilities = mysqlClientLongPassword | mysqlClientFoundRows | mysqlClientLongFlag |
+	mysqlClientConnectWithDB | mysqlClientIgnoreSpace | mysqlClientProtocol41 | mysqlClientInteractive |
+	mysqlClientTransactions | mysqlClientSecureConnection | mysqlClientMultiStatements | mysqlClientMultiResults |
+	mysqlClientPSMultiResults | mysqlClientPluginAuth | mysqlClientPluginAuthLenenc
+
+// MySQLError 数据库返回的ERR包
+type MySQLError struct {
+	Code     uint16
+	SQLState string
+	Message  string
+}
+
+// Error 实现error接口
+func (e *MySQLError) Error() string {
+	return fmt.Sprintf("mysql error %d (%s): %s", e.Code, e.SQLState, e.Message)
+}
+
+// mysqlConn 按MySQL包格式读写的连接
+type mysqlConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex // 串行化写入
+}
+
+func newMySQLConn(conn net.Conn) *mysqlConn {
+	return &mysqlConn{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// readFrame 读取一个原始帧（含4字节包头），用于原样转发
+func (c *mysqlConn) readFrame() ([]byte, error) {
+	frame := make([]byte, 4)
+	if _, err := io.ReadFull(c.reader, frame); err != nil {
+		return nil, err
+	}
+	length := int(uint32(frame[0]) | uint32(frame[1])<<8 | uint32(frame[2])<<16)
+	frame = append(frame, make([]byte, length)...)
+	if _, err := io.ReadFull(c.reader, frame[4:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// readPacket 读取一个完整的逻辑包，超过16MB被拆分的包会被合并，返回最后一帧的序号
+func (c *mysqlConn) readPacket() ([]byte, byte, error) {
+	var payload []byte
+	for {
+		frame, err := c.readFrame()
+		if err != nil {
+			return nil, 0, err
+		}
+		payload = append(payload, frame[4:]...)
+		if len(frame)-4 < mysqlMaxPacketSize {
+			return payload, frame[3], nil
+		}
+	}
+}
+
+// readRawPacket 读取一个完整的逻辑包，同时返回原始帧以便原样转发，seq 为第一帧的序号
+func (c *mysqlConn) readRawPacket() ([]byte, []byte, byte, error) {
+	var raw, payload []byte
+	for {
+		frame, err := c.readFrame()
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		raw = append(raw, frame...)
+		payload = append(payload, frame[4:]...)
+		if len(frame)-4 < mysqlMaxPacketSize {
+			return raw, payload, raw[3], nil
+		}
+	}
+}
+
+// writePacket 按序号写入一个逻辑包，返回下一个可用的序号
+func (c *mysqlConn) writePacket(seq byte, payload []byte) (byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		size := len(payload)
+		if size > mysqlMaxPacketSize {
+			size = mysqlMaxPacketSize
+		}
+		frame := make([]byte, 4+size)
+		frame[0], frame[1], frame[2], frame[3] = byte(size), byte(size>>8), byte(size>>16), seq
+		copy(frame[4:], payload[:size])
+		if _, err := c.conn.Write(frame); err != nil {
+			return seq, err
+		}
+		seq++
+		payload = payload[size:]
+		if size < mysqlMaxPacketSize {
+			return seq, nil
+		}
+	}
+}
+
+// writeFrame 原样写入一个原始帧
+func (c *mysqlConn) writeFrame(frame []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// writeError 向对端写入ERR包
+func (c *mysqlConn) writeError(seq byte, code uint16, sqlState, message string) error {
+	payload := []byte{mysqlPacketERR, byte(code), byte(code >> 8), '#'}
+	payload = append(payload, sqlState...)
+	payload = append(payload, message...)
+	_, err := c.writePacket(seq, payload)
+	return err
+}
+
+// mysqlHandshakeTimeout 代理握手与登录数据库的超时时间
+const mysqlHandshakeTimeout = 30 * time.Second
+
+// mysqlPendingStatement 已发送到数据库、等待响应的语句
+type mysqlPendingStatement struct {
+	entry    *models.SQLAuditLog
+	prepared bool // COM_STMT_PREPARE 的响应不含影响行数
+}
+
+// serveMySQL 处理MySQL客户端连接：以令牌认证用户，使用凭证登录数据库后转发命令
+func (s *DatabaseProxyService) serveMySQL(conn net.Conn) {
+	client := newMySQLConn(conn)
+	clientIP := remoteIP(conn.RemoteAddr())
+	conn.SetDeadline(time.Now().Add(mysqlHandshakeTimeout))
+
+	salt := make([]byte, 20)
+	if _, err := rand.Read(salt); err != nil {
+		conn.Close()
+		return
+	}
+	for i := range salt {
+		salt[i] = salt[i]%94 + 33 // 可打印字符，不含0字节
+	}
+	serverVersion := config.GlobalConfig.DatabaseProxy.ServerVersion
+	if serverVersion == "" {
+		serverVersion = defaultMySQLServerVersion
+	}
+	if err := writeServerHandshake(client, serverVersion, atomic.AddUint32(&s.connectionID, 1), salt); err != nil {
+		conn.Close()
+		return
+	}
+
+	payload, seq, err := client.readPacket()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	handshake, err := parseHandshakeResponse(payload)
+	if err != nil {
+		client.writeError(seq+1, 1043, "08S01", "Bad handshake: "+err.Error())
+		conn.Close()
+		return
+	}
+	seq++
+
+	// 客户端使用其他认证插件时切换为 mysql_native_password
+	scramble := handshake.AuthResponse
+	if handshake.AuthPlugin != "" && handshake.AuthPlugin != mysqlNativePassword {
+		authSwitch := append([]byte{mysqlPacketAuthSwitch}, mysqlNativePassword...)
+		authSwitch = append(authSwitch, 0)
+		authSwitch = append(authSwitch, salt...)
+		authSwitch = append(authSwitch, 0)
+		if _, err := client.writePacket(seq, authSwitch); err != nil {
+			conn.Close()
+			return
+		}
+		if scramble, seq, err = client.readPacket(); err != nil {
+			conn.Close()
+			return
+		}
+		seq++
+	}
+
+	token := s.findToken(models.DatabaseProtocolMySQL, handshake.Username, func(token string) bool {
+		return mysqlCheckScramble(salt, scramble, token)
+	})
+	if token == nil {
+		s.recordLogin(0, handshake.Username, clientIP, models.DatabaseProtocolMySQL, "failed", "invalid or expired database proxy token")
+		client.writeError(seq, 1045, "28000", fmt.Sprintf("Access denied for user '%s' (invalid or expired token)", handshake.Username))
+		conn.Close()
+		return
+	}
+
+	user, asset, credential, err := s.authorize(token.UserID, token.AssetID, token.CredentialID, clientIP)
+	if err != nil {
+		s.recordLogin(token.UserID, token.Username, clientIP, models.DatabaseProtocolMySQL, "failed", err.Error())
+		client.writeError(seq, 1045, "28000", "Access denied: "+gatewaySessionErrorMessage(err))
+		conn.Close()
+		return
+	}
+	password, err := s.credentialPassword(credential)
+	if err != nil {
+		logrus.WithError(err).WithField("credential_id", credential.ID).Error("数据库代理解密凭证失败")
+		client.writeError(seq, 1045, "28000", "Access denied: failed to load database credential")
+		conn.Close()
+		return
+	}
+
+	address := net.JoinHostPort(asset.Address, strconv.Itoa(asset.Port))
+	upstreamConn, chain, err := s.gateways.Dial(asset, address, s.dialTimeout())
+	if err != nil {
+		logrus.WithError(err).WithField("asset_id", asset.ID).Warn("数据库代理连接数据库失败")
+		client.writeError(seq, 2003, "HY000", fmt.Sprintf("Can't connect to database server %s", asset.Name))
+		conn.Close()
+		return
+	}
+	upstreamConn.SetDeadline(time.Now().Add(mysqlHandshakeTimeout))
+	upstream, err := mysqlHandshake(upstreamConn, mysqlUpstreamOptions{
+		Username:     credential.Username,
+		Password:     password,
+		Database:     handshake.Database,
+		Capabilities: handshake.Capabilities & mysqlProxyCapabilities,
+		Charset:      handshake.Charset,
+	})
+	if err != nil {
+		upstreamConn.Close()
+		var mysqlErr *MySQLError
+		if errors.As(err, &mysqlErr) {
+			client.writeError(seq, mysqlErr.Code, mysqlErr.SQLState, mysqlErr.Message)
+		} else {
+			client.writeError(seq, 1045, "28000", "Access denied: failed to login to database server")
+		}
+		s.recordLogin(user.ID, user.Username, clientIP, models.DatabaseProtocolMySQL, "failed", err.Error())
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	upstreamConn.SetDeadline(time.Time{})
+
+	session := newDatabaseSession(models.DatabaseProtocolMySQL, user, asset, credential, clientIP, conn, upstreamConn)
+	if err := s.openSession(session, chain); err != nil {
+		logrus.WithError(err).Error("数据库代理创建会话失败")
+		client.writeError(seq, 1105, "HY000", "Failed to create bastion session")
+		session.close("创建会话失败")
+		return
+	}
+	s.recordLogin(user.ID, user.Username, clientIP, models.DatabaseProtocolMySQL, "success",
+		fmt.Sprintf("Database proxy login successful (%s@%s)", credential.Username, asset.Name))
+
+	if _, err := client.writePacket(seq, upstream.OK); err == nil {
+		s.relayMySQL(session, client, upstream.conn, handshake.Database)
+	}
+	s.finishSession(session)
+}
+
+// relayMySQL 在客户端与数据库之间转发命令与响应。
+// 协议是一问一答的：语句在发送前入队，数据库返回的下一个包即该语句响应的第一个包，据此填写审计结果。
+func (s *DatabaseProxyService) relayMySQL(session *databaseSession, client, upstream *mysqlConn, database string) {
+	var pendingMu sync.Mutex
+	var pending []*mysqlPendingStatement
+
+	serverDone := make(chan struct{})
+	go func() {
+		defer close(serverDone)
+		for {
+			frame, err := upstream.readFrame()
+			if err != nil {
+				session.close("数据库断开连接")
+				return
+			}
+
+			var statement *mysqlPendingStatement
+			pendingMu.Lock()
+			if len(pending) > 0 {
+				statement, pending = pending[0], pending[1:]
+			}
+			pendingMu.Unlock()
+			if statement != nil {
+				fillMySQLAuditResult(statement, frame[4:])
+				s.saveAuditEntry(statement.entry)
+			}
+
+			if err := client.writeFrame(frame); err != nil {
+				session.close("客户端断开连接")
+				return
+			}
+		}
+	}()
+
+	for {
+		raw, payload, seq, err := client.readRawPacket()
+		if err != nil {
+			break
+		}
+
+		if seq == 0 && len(payload) > 0 {
+			command := payload[0]
+			if command == mysqlComQuit {
+				break
+			}
+			if command == mysqlComInitDB {
+				database = string(payload[1:])
+			}
+			if command == mysqlComQuery || command == mysqlComStmtPrepare {
+				statement := string(payload[1:])
+				result := s.checkStatement(session, statement)
+				entry := s.newAuditEntry(session, database, statement, result)
+
+				if result.Action == models.FilterActionDeny {
+					entry.Status = models.SQLAuditStatusDenied
+					entry.Message = "denied by sql filter rule"
+					s.saveAuditEntry(entry)
+					if err := client.writeError(1, 1227, "42000",
+						fmt.Sprintf("Access denied; statement blocked by bastion SQL filter rule '%s'", result.Rule.Name)); err != nil {
+						break
+					}
+					continue
+				}
+
+				pendingMu.Lock()
+				pending = append(pending, &mysqlPendingStatement{entry: entry, prepared: command == mysqlComStmtPrepare})
+				pendingMu.Unlock()
+			}
+		}
+
+		if err := upstream.writeFrame(raw); err != nil {
+			break
+		}
+	}
+
+	session.close("客户端断开连接")
+	<-serverDone
+
+	// 连接断开时仍未收到响应的语句
+	for _, statement := range pending {
+		statement.entry.Status = models.SQLAuditStatusError
+		statement.entry.Message = "connection closed before response"
+		s.saveAuditEntry(statement.entry)
+	}
+}
+
+// fillMySQLAuditResult 根据响应的第一个包填写语句的执行结果
+func fillMySQLAuditResult(statement *mysqlPendingStatement, payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+	switch payload[0] {
+	case mysqlPacketOK:
+		if !statement.prepared {
+			statement.entry.AffectedRows = parseOKAffectedRows(payload)
+		}
+	case mysqlPacketERR:
+		mysqlErr := parseMySQLError(payload)
+		statement.entry.Status = models.SQLAuditStatusError
+		statement.entry.Message = fmt.Sprintf("ERROR %d (%s): %s", mysqlErr.Code, mysqlErr.SQLState, mysqlErr.Message)
+	}
+}
+
+// mysqlHandshakeResponse 客户端的握手响应
+type mysqlHandshakeResponse struct {
+	Capabilities uint32
+	Charset      byte
+	Username     string
+	AuthResponse []byte
+	Database     string
+	AuthPlugin   string
+}
+
+// writeServerHandshake 向客户端发送 HandshakeV10，认证插件固定为 mysql_native_password
+func writeServerHandshake(c *mysqlConn, serverVersion string, connectionID uint32, salt []byte) error {
+	payload := []byte{10}
+	payload = append(payload, serverVersion...)
+	payload = append(payload, 0)
+	payload = binary.LittleEndian.AppendUint32(payload, connectionID)
+	payload = append(payload, salt[:8]...)
+	payload = append(payload, 0)
+	payload = binary.LittleEndian.AppendUint16(payload, uint16(mysqlProxyCapabilities&0xffff))
+	payload = append(payload, mysqlDefaultCharset)
+	payload = binary.LittleEndian.AppendUint16(payload, mysqlServerStatusAutocommit)
+	payload = binary.LittleEndian.AppendUint16(payload, uint16(mysqlProxyCapabilities>>16))
+	payload = append(payload, byte(len(salt)+1))
+	payload = append(payload, make([]byte, 10)...)
+	payload = append(payload, salt[8:]...)
+	payload = append(payload, 0)
+	payload = append(payload, mysqlNativePassword...)
+	payload = append(payload, 0)
+	_, err := c.writePacket(0, payload)
+	return err
+}
+
+// parseHandshakeResponse 解析客户端的 HandshakeResponse41
+func parseHandshakeResponse(payload []byte) (*mysqlHandshakeResponse, error) {
+	if len(payload) < 32 {
+		return nil, errors.New("handshake response too short")
+	}
+	resp := &mysqlHandshakeResponse{
+		Capabilities: binary.LittleEndian.Uint32(payload),
+		Charset:      payload[8],
+	}
+	if resp.Capabilities&mysqlClientProtocol41 == 0 {
+		return nil, errors.New("client does not support protocol 4.1")
+	}
+	if resp.Capabilities&mysqlClientSSL != 0 && len(payload) == 32 {
+		return nil, errors.New("ssl is not supported by the proxy")
+	}
+
+	r := &mysqlReader{buf: payload[32:]}
+	resp.Username = r.nulString()
+	switch {
+	case resp.Capabilities&mysqlClientPluginAuthLenenc != 0:
+		resp.AuthResponse = r.bytes(int(r.lenencInt()))
+	case resp.Capabilities&mysqlClientSecureConnection != 0:
+		resp.AuthResponse = r.bytes(int(r.byte()))
+	default:
+		resp.AuthResponse = []byte(r.nulString())
+	}
+	if resp.Capabilities&mysqlClientConnectWithDB != 0 {
+		resp.Database = r.nulString()
+	}
+	if resp.Capabilities&mysqlClientPluginAuth != 0 {
+		resp.AuthPlugin = r.nulString()
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed handshake response: %w", r.err)
+	}
+	return resp, nil
+}
+
+// mysqlUpstreamOptions 连接真实数据库时使用的参数
+type mysqlUpstreamOptions struct {
+	Username     string
+	Password     string
+	Database     string
+	Capabilities uint32 // 客户端请求的能力，为0时使用代理默认能力
+	Charset      byte
+}
+
+// mysqlUpstream 已认证的数据库连接
+type mysqlUpstream struct {
+	conn          *mysqlConn
+	ServerVersion string
+	Capabilities  uint32
+	OK            []byte // 认证成功时的OK包，转发给客户端
+}
+
+// mysqlHandshake 以客户端身份登录真实数据库，支持 mysql_native_password 与 caching_sha2_password
+func mysqlHandshake(conn net.Conn, opts mysqlUpstreamOptions) (*mysqlUpstream, error) {
+	c := newMySQLConn(conn)
+	payload, seq, err := c.readPacket()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server handshake: %w", err)
+	}
+	if len(payload) > 0 && payload[0] == mysqlPacketERR {
+		return nil, parseMySQLError(payload)
+	}
+
+	r := &mysqlReader{buf: payload}
+	if protocol := r.byte(); protocol != 10 {
+		return nil, fmt.Errorf("unsupported protocol version %d", protocol)
+	}
+	up := &mysqlUpstream{conn: c, ServerVersion: r.nulString()}
+	r.skip(4)
+	salt := append([]byte{}, r.bytes(8)...)
+	r.skip(1)
+	serverCaps := uint32(r.uint16())
+	r.skip(3)
+	serverCaps |= uint32(r.uint16()) << 16
+	saltLen := int(r.byte())
+	r.skip(10)
+	if serverCaps&mysqlClientSecureConnection != 0 {
+		n := saltLen - 9
+		if n < 12 {
+			n = 12
+		}
+		salt = append(salt, r.bytes(n)...)
+		r.skip(1)
+	}
+	plugin := mysqlNativePassword
+	if serverCaps&mysqlClientPluginAuth != 0 {
+		if name := r.nulString(); name != "" {
+			plugin = name
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("malformed server handshake: %w", r.err)
+	}
+
+	requested := opts.Capabilities
+	if requested == 0 {
+		requested = mysqlProxyCapabilities
+	}
+	caps := requested & serverCaps &^ (mysqlClientSSL | mysqlClientCompress | mysqlClientConnectAttrs | mysqlClientLocalFiles)
+	caps |= mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientLongPassword
+	if serverCaps&mysqlClientPluginAuth != 0 {
+		caps |= mysqlClientPluginAuth
+	}
+	if opts.Database != "" {
+		caps |= mysqlClientConnectWithDB
+	} else {
+		caps &^= mysqlClientConnectWithDB
+	}
+	up.Capabilities = caps
+
+	authData, err := mysqlAuthResponse(plugin, opts.Password, salt)
+	if err != nil {
+		return nil, err
+	}
+	charset := opts.Charset
+	if charset == 0 {
+		charset = mysqlDefaultCharset
+	}
+
+	resp := binary.LittleEndian.AppendUint32(nil, caps)
+	resp = binary.LittleEndian.AppendUint32(resp, mysqlMaxPacketSize)
+	resp = append(resp, charset)
+	resp = append(resp, make([]byte, 23)...)
+	resp = append(resp, opts.Username...)
+	resp = append(resp, 0)
+	if caps&mysqlClientPluginAuthLenenc != 0 {
+		resp = appendLenencInt(resp, uint64(len(authData)))
+	} else {
+		resp = append(resp, byte(len(authData)))
+	}
+	resp = append(resp, authData...)
+	if caps&mysqlClientConnectWithDB != 0 {
+		resp = append(resp, opts.Database...)
+		resp = append(resp, 0)
+	}
+	if caps&mysqlClientPluginAuth != 0 {
+		resp = append(resp, plugin...)
+		resp = append(resp, 0)
+	}
+	if _, err := c.writePacket(seq+1, resp); err != nil {
+		return nil, err
+	}
+
+	for {
+		payload, last, err := c.readPacket()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read authentication result: %w", err)
+		}
+		seq = last + 1
+		if len(payload) == 0 {
+			return nil, errors.New("empty authentication packet")
+		}
+
+		switch payload[0] {
+		case mysqlPacketOK:
+			up.OK = payload
+			return up, nil
+		case mysqlPacketERR:
+			return nil, parseMySQLError(payload)
+		case mysqlPacketAuthSwitch:
+			r := &mysqlReader{buf: payload[1:]}
+			plugin = r.nulString()
+			salt = bytes.TrimSuffix(r.rest(), []byte{0})
+			authData, err := mysqlAuthResponse(plugin, opts.Password, salt)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := c.writePacket(seq, authData); err != nil {
+				return nil, err
+			}
+		case mysqlPacketAuthMore:
+			if plugin != mysqlCachingSHA2Password || len(payload) < 2 {
+				return nil, fmt.Errorf("unexpected auth data for plugin %s", plugin)
+			}
+			switch payload[1] {
+			case 3: // 快速认证成功，随后是OK包
+				continue
+			case 4: // 需要完整认证：未使用TLS，获取服务器公钥后用RSA加密密码
+				if _, err := c.writePacket(seq, []byte{2}); err != nil {
+					return nil, err
+				}
+				keyPacket, last, err := c.readPacket()
+				if err != nil {
+					return nil, fmt.Errorf("failed to read server public key: %w", err)
+				}
+				if len(keyPacket) < 2 || keyPacket[0] != mysqlPacketAuthMore {
+					return nil, errors.New("unexpected public key response")
+				}
+				encrypted, err := mysqlEncryptPassword(opts.Password, salt, keyPacket[1:])
+				if err != nil {
+					return nil, err
+				}
+				if _, err := c.writePacket(last+1, encrypted); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("unexpected caching_sha2_password state %d", payload[1])
+			}
+		default:
+			return nil, fmt.Errorf("unexpected authentication packet 0x%02x", payload[0])
+		}
+	}
+}
+
+// mysqlAuthResponse 按认证插件计算密码的认证数据
+func mysqlAuthResponse(plugin, password string, salt []byte) ([]byte, error) {
+	if password == "" {
+		return []byte{}, nil
+	}
+	switch plugin {
+	case mysqlNativePassword:
+		return mysqlNativeScramble(salt, []byte(password)), nil
+	case mysqlCachingSHA2Password:
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)), salt)
+		h1 := sha256.Sum256([]byte(password))
+		h2 := sha256.Sum256(h1[:])
+		h := sha256.New()
+		h.Write(h2[:])
+		h.Write(salt)
+		h3 := h.Sum(nil)
+		for i := range h3 {
+			h3[i] ^= h1[i]
+		}
+		return h3, nil
+	default:
+		return nil, fmt.Errorf("unsupported authentication plugin %s", plugin)
+	}
+}
+
+// mysqlNativeScramble 计算 mysql_native_password 的认证数据
+// SHA1(password) XOR SHA1(salt, SHA1(SHA1(password)))
+func mysqlNativeScramble(salt, password []byte) []byte {
+	if len(password) == 0 {
+		return nil
+	}
+	if len(salt) > 20 {
+		salt = salt[:20]
+	}
+	h1 := sha1.Sum(password)
+	h2 := sha1.Sum(h1[:])
+	h := sha1.New()
+	h.Write(salt)
+	h.Write(h2[:])
+	h3 := h.Sum(nil)
+	for i := range h3 {
+		h3[i] ^= h1[i]
+	}
+	return h3
+}
+
+// mysqlCheckScramble 校验客户端使用 mysql_native_password 提交的认证数据
+func mysqlCheckScramble(salt, scramble []byte, password string) bool {
+	expected := mysqlNativeScramble(salt, []byte(password))
+	return len(scramble) == len(expected) && subtle.ConstantTimeCompare(scramble, expected) == 1
+}
+
+// mysqlEncryptPassword 使用服务器公钥加密密码（caching_sha2_password 完整认证）
+func mysqlEncryptPassword(password string, salt, pemKey []byte) ([]byte, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("invalid server public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("server public key is not RSA")
+	}
+
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= salt[i%len(salt)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaKey, plain, nil)
+}
+
+// parseMySQLError 解析ERR包
+func parseMySQLError(payload []byte) *MySQLError {
+	e := &MySQLError{SQLState: "HY000"}
+	if len(payload) < 3 {
+		e.Message = "malformed error packet"
+		return e
+	}
+	e.Code = binary.LittleEndian.Uint16(payload[1:3])
+	rest := payload[3:]
+	if len(rest) >= 6 && rest[0] == '#' {
+		e.SQLState = string(rest[1:6])
+		rest = rest[6:]
+	}
+	e.Message = string(rest)
+	return e
+}
+
+// parseOKAffectedRows 解析OK包中的影响行数
+func parseOKAffectedRows(payload []byte) int64 {
+	r := &mysqlReader{buf: payload[1:]}
+	n := r.lenencInt()
+	if r.err != nil {
+		return 0
+	}
+	return int64(n)
+}
+
+// appendLenencInt 追加长度编码整数
+func appendLenencInt(buf []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(buf, byte(n))
+	case n < 1<<16:
+		return append(buf, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(buf, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		return binary.LittleEndian.AppendUint64(append(buf, 0xfe), n)
+	}
+}
+
+// mysqlReader 顺序解析包内容，越界时记录错误并返回零值
+type mysqlReader struct {
+	buf []byte
+	err error
+}
+
+func (r *mysqlReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.buf) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *mysqlReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *mysqlReader) byte() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *mysqlReader) uint16() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *mysqlReader) lenencInt() uint64 {
+	switch first := r.byte(); first {
+	case 0xfc:
+		b := r.bytes(2)
+		if b == nil {
+			return 0
+		}
+		return uint64(binary.LittleEndian.Uint16(b))
+	case 0xfd:
+		b := r.bytes(3)
+		if b == nil {
+			return 0
+		}
+		return uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16
+	case 0xfe:
+		b := r.bytes(8)
+		if b == nil {
+			return 0
+		}
+		return binary.LittleEndian.Uint64(b)
+	default:
+		return uint64(first)
+	}
+}
+
+// nulString 读取以0结尾的字符串，缺少结尾时读取剩余全部内容
+func (r *mysqlReader) nulString() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.buf, 0)
+	if i < 0 {
+		s := string(r.buf)
+		r.buf = nil
+		return s
+	}
+	s := string(r.buf[:i])
+	r.buf = r.buf[i+1:]
+	return s
+}
+
+func (r *mysqlReader) rest() []byte {
+	b := r.buf
+	r.buf = nil
+	return b
+}
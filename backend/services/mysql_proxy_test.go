@@ -0,0 +1,347 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const (
+	testMySQLAccount  = "app_rw"
+	testMySQLPassword = "Upstream-Pass-7uQ2"
+)
+
+// testMySQLServer 模拟MySQL服务器：mysql_native_password 认证，COM_QUERY 返回OK包，
+// 访问 missing_table 的语句返回ERR包
+type testMySQLServer struct {
+	listener net.Listener
+
+	mu         sync.Mutex
+	statements []string
+	logins     []string
+}
+
+// newTestMySQLServer 在回环地址上启动模拟服务器
+func newTestMySQLServer(t *testing.T) *testMySQLServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &testMySQLServer{listener: listener}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+// port 监听端口
+func (s *testMySQLServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// received 服务器收到的语句
+func (s *testMySQLServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statements...)
+}
+
+// loginAccounts 登录成功的数据库账号
+func (s *testMySQLServer) loginAccounts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.logins...)
+}
+
+func (s *testMySQLServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testMySQLServer) handle(conn net.Conn) {
+	defer conn.Close()
+	c := newMySQLConn(conn)
+
+	salt := make([]byte, 20)
+	rand.Read(salt)
+	for i := range salt {
+		salt[i] = salt[i]%94 + 33
+	}
+	if err := writeServerHandshake(c, "8.0.99-test", 1, salt); err != nil {
+		return
+	}
+	payload, seq, err := c.readPacket()
+	if err != nil {
+		return
+	}
+	handshake, err := parseHandshakeResponse(payload)
+	if err != nil || handshake.Username != testMySQLAccount || !mysqlCheckScramble(salt, handshake.AuthResponse, testMySQLPassword) {
+		c.writeError(seq+1, 1045, "28000", "Access denied for user")
+		return
+	}
+	s.mu.Lock()
+	s.logins = append(s.logins, handshake.Username)
+	s.mu.Unlock()
+	if _, err := c.writePacket(seq+1, testMySQLOK(0)); err != nil {
+		return
+	}
+
+	for {
+		payload, _, err := c.readPacket()
+		if err != nil || len(payload) == 0 || payload[0] == mysqlComQuit {
+			return
+		}
+		if payload[0] != mysqlComQuery {
+			c.writePacket(1, testMySQLOK(0))
+			continue
+		}
+
+		statement := string(payload[1:])
+		s.mu.Lock()
+		s.statements = append(s.statements, statement)
+		s.mu.Unlock()
+		if strings.Contains(statement, "missing_table") {
+			c.writeError(1, 1146, "42S02", "Table 'appdb.missing_table' doesn't exist")
+			continue
+		}
+		c.writePacket(1, testMySQLOK(3))
+	}
+}
+
+// testMySQLOK 构造OK包
+func testMySQLOK(affectedRows uint64) []byte {
+	payload := appendLenencInt([]byte{mysqlPacketOK}, affectedRows)
+	payload = appendLenencInt(payload, 0)
+	return append(payload, byte(mysqlServerStatusAutocommit), 0, 0, 0)
+}
+
+// setupMySQLProxy 启动代理与模拟数据库，返回代理服务、代理地址、数据库以及资产与凭证
+func setupMySQLProxy(t *testing.T) (*DatabaseProxyService, string, *gorm.DB, *testMySQLServer, *models.User, *models.Asset, *models.Credential) {
+	t.Helper()
+	setupTestConfig(t)
+	config.GlobalConfig.DatabaseProxy = config.DatabaseProxyConfig{Enable: true, Host: "127.0.0.1", MySQLPort: 3307, DialTimeout: 2}
+	config.GlobalConfig.Audit.EnableSessionRecord = true
+
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+		&models.UserGroup{}, &models.Asset{}, &models.AssetGroup{}, &models.Credential{}, &models.AssetCredential{},
+		&models.AssetPermission{}, &models.AccessPolicy{}, &models.NetworkZone{}, &models.SessionRecord{}, &models.LoginLog{},
+		&models.SQLFilterRule{}, &models.SQLAuditLog{})
+
+	server := newTestMySQLServer(t)
+	encrypted, err := utils.EncryptPassword(testMySQLPassword)
+	require.NoError(t, err)
+	credential := &models.Credential{Name: "app", Type: utils.CredentialTypePassword, Username: testMySQLAccount, Password: encrypted}
+	require.NoError(t, db.Create(credential).Error)
+	asset := &models.Asset{Name: "orders-db", Type: "database", Protocol: models.DatabaseProtocolMySQL,
+		Address: "127.0.0.1", Port: server.port(), Status: 1, Credentials: []models.Credential{*credential}}
+	require.NoError(t, db.Create(asset).Error)
+
+	role := models.Role{Name: "admin"}
+	require.NoError(t, db.Create(&role).Error)
+	user := &models.User{Username: "alice", Password: "hash", Status: 1, AuthSource: models.AuthSourceLocal, Roles: []models.Role{role}}
+	require.NoError(t, db.Create(user).Error)
+
+	proxy := NewDatabaseProxyService(db, nil, nil)
+	require.NoError(t, proxy.listen(models.DatabaseProtocolMySQL, "127.0.0.1:0", proxy.serveMySQL))
+	t.Cleanup(func() { proxy.Stop() })
+	return proxy, proxy.listeners[0].Addr().String(), db, server, user, asset, credential
+}
+
+// openMySQLClient 以堡垒机用户名和连接令牌通过代理连接数据库
+func openMySQLClient(t *testing.T, proxyAddr, username, token string) *sql.DB {
+	t.Helper()
+	cfg := mysql.NewConfig()
+	cfg.User = username
+	cfg.Passwd = token
+	cfg.Net = "tcp"
+	cfg.Addr = proxyAddr
+	cfg.DBName = "appdb"
+	cfg.Timeout = 2 * time.Second
+
+	client, err := sql.Open("mysql", cfg.FormatDSN())
+	require.NoError(t, err)
+	client.SetMaxOpenConns(1)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// issueMySQLToken 签发数据库连接令牌
+func issueMySQLToken(t *testing.T, proxy *DatabaseProxyService, user *models.User, asset *models.Asset, credential *models.Credential) string {
+	t.Helper()
+	response, err := proxy.IssueToken(user.ID, "127.0.0.1", &models.DatabaseTokenRequest{AssetID: asset.ID, CredentialID: credential.ID})
+	require.NoError(t, err)
+	return response.Token
+}
+
+// waitSQLAuditLogs 等待异步写入的SQL审计日志
+func waitSQLAuditLogs(t *testing.T, db *gorm.DB, count int) []models.SQLAuditLog {
+	t.Helper()
+	var logs []models.SQLAuditLog
+	require.Eventually(t, func() bool {
+		logs = nil
+		db.Order("id").Find(&logs)
+		return len(logs) >= count
+	}, 5*time.Second, 20*time.Millisecond)
+	return logs
+}
+
+// auditLogFor 查找语句的审计记录
+func auditLogFor(t *testing.T, logs []models.SQLAuditLog, statement string) models.SQLAuditLog {
+	t.Helper()
+	for _, log := range logs {
+		if log.Statement == statement {
+			return log
+		}
+	}
+	t.Fatalf("no sql audit log for %q", statement)
+	return models.SQLAuditLog{}
+}
+
+func TestMySQLProxyForwardsAllowedStatementsWithCredential(t *testing.T) {
+	proxy, proxyAddr, db, server, user, asset, credential := setupMySQLProxy(t)
+	client := openMySQLClient(t, proxyAddr, user.Username, issueMySQLToken(t, proxy, user, asset, credential))
+
+	result, err := client.Exec("UPDATE orders SET status = 2 WHERE id = 7")
+	require.NoError(t, err)
+	affected, err := result.RowsAffected()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, affected)
+
+	// 代理使用凭证登录数据库，客户端只持有令牌
+	require.Equal(t, []string{testMySQLAccount}, server.loginAccounts())
+	require.Equal(t, []string{"UPDATE orders SET status = 2 WHERE id = 7"}, server.received())
+
+	log := waitSQLAuditLogs(t, db, 1)[0]
+	require.Equal(t, models.SQLAuditStatusSuccess, log.Status)
+	require.Equal(t, models.FilterActionAllow, log.Action)
+	require.Equal(t, "UPDATE", log.StatementType)
+	require.EqualValues(t, 3, log.AffectedRows)
+	require.Equal(t, "appdb", log.Database)
+	require.Equal(t, testMySQLAccount, log.Account)
+	require.Equal(t, user.Username, log.Username)
+
+	var session models.SessionRecord
+	require.NoError(t, db.Where("session_id = ?", log.SessionID).First(&session).Error)
+	require.Equal(t, models.DatabaseProtocolMySQL, session.Protocol)
+	require.Equal(t, asset.ID, session.AssetID)
+}
+
+func TestMySQLProxyBlocksDeniedStatements(t *testing.T) {
+	proxy, proxyAddr, db, server, user, asset, credential := setupMySQLProxy(t)
+	_, err := proxy.filters.CreateRule(&models.SQLFilterRuleCreateRequest{
+		Name: "no-drop", Priority: 1, MatchType: models.SQLMatchStatement, Pattern: "DROP,TRUNCATE", Action: models.FilterActionDeny,
+	})
+	require.NoError(t, err)
+	_, err = proxy.filters.CreateRule(&models.SQLFilterRuleCreateRequest{
+		Name: "no-unbounded-delete", Priority: 2, MatchType: models.SQLMatchNoWhere, Pattern: "DELETE", Action: models.FilterActionDeny,
+	})
+	require.NoError(t, err)
+	client := openMySQLClient(t, proxyAddr, user.Username, issueMySQLToken(t, proxy, user, asset, credential))
+
+	denied := []string{
+		"DROP TABLE orders",
+		"/* cleanup */ drop table orders",
+		"SELECT 1; TRUNCATE orders",
+		"DELETE FROM orders",
+	}
+	for _, statement := range denied {
+		_, err := client.Exec(statement)
+		var mysqlErr *mysql.MySQLError
+		require.True(t, errors.As(err, &mysqlErr), "statement %q: %v", statement, err)
+		require.EqualValues(t, 1227, mysqlErr.Number)
+		require.Contains(t, mysqlErr.Message, "blocked by bastion SQL filter rule")
+	}
+
+	// 拒绝后连接仍可继续使用，被拒绝的语句不会发送到数据库
+	_, err = client.Exec("DELETE FROM orders WHERE id = 7")
+	require.NoError(t, err)
+	require.Equal(t, []string{"DELETE FROM orders WHERE id = 7"}, server.received())
+
+	logs := waitSQLAuditLogs(t, db, len(denied)+1)
+	for _, statement := range denied {
+		log := auditLogFor(t, logs, statement)
+		require.Equal(t, models.SQLAuditStatusDenied, log.Status)
+		require.Equal(t, models.FilterActionDeny, log.Action)
+		require.NotNil(t, log.RuleID)
+	}
+	require.Equal(t, "no-unbounded-delete", auditLogFor(t, logs, "DELETE FROM orders").RuleName)
+	require.Equal(t, models.SQLAuditStatusSuccess, auditLogFor(t, logs, "DELETE FROM orders WHERE id = 7").Status)
+}
+
+func TestMySQLProxyRecordsDatabaseErrors(t *testing.T) {
+	proxy, proxyAddr, db, _, user, asset, credential := setupMySQLProxy(t)
+	client := openMySQLClient(t, proxyAddr, user.Username, issueMySQLToken(t, proxy, user, asset, credential))
+
+	_, err := client.Exec("SELECT * FROM missing_table")
+	var mysqlErr *mysql.MySQLError
+	require.True(t, errors.As(err, &mysqlErr), "%v", err)
+	require.EqualValues(t, 1146, mysqlErr.Number)
+
+	log := waitSQLAuditLogs(t, db, 1)[0]
+	require.Equal(t, models.SQLAuditStatusError, log.Status)
+	require.Contains(t, log.Message, "ERROR 1146 (42S02)")
+}
+
+func TestMySQLProxyRejectsInvalidOrRevokedTokens(t *testing.T) {
+	proxy, proxyAddr, db, server, user, asset, credential := setupMySQLProxy(t)
+	token := issueMySQLToken(t, proxy, user, asset, credential)
+
+	// 错误的令牌与其他用户名都无法登录
+	for _, login := range [][2]string{{user.Username, "not-a-token"}, {"mallory", token}} {
+		err := openMySQLClient(t, proxyAddr, login[0], login[1]).Ping()
+		var mysqlErr *mysql.MySQLError
+		require.True(t, errors.As(err, &mysqlErr), "%v", err)
+		require.EqualValues(t, 1045, mysqlErr.Number)
+	}
+
+	// 签发令牌后用户被禁用，登录代理时重新校验授权
+	require.NoError(t, db.Model(user).Update("status", 0).Error)
+	err := openMySQLClient(t, proxyAddr, user.Username, token).Ping()
+	var mysqlErr *mysql.MySQLError
+	require.True(t, errors.As(err, &mysqlErr), "%v", err)
+	require.EqualValues(t, 1045, mysqlErr.Number)
+	require.Contains(t, mysqlErr.Message, "Access denied")
+
+	require.Empty(t, server.loginAccounts())
+}
+
+func TestMySQLProxyClosesSessionsWhenAccessIsRevoked(t *testing.T) {
+	proxy, proxyAddr, db, _, user, asset, credential := setupMySQLProxy(t)
+	client := openMySQLClient(t, proxyAddr, user.Username, issueMySQLToken(t, proxy, user, asset, credential))
+	ctx := context.Background()
+	conn, err := client.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, "UPDATE orders SET status = 2 WHERE id = 7")
+	require.NoError(t, err)
+
+	// 其他资产上的授权变化不影响当前会话
+	require.Zero(t, proxy.CloseUserAssetSessions(user.ID, []uint{asset.ID + 1}, "临时访问授权已到期"))
+	require.Equal(t, 1, proxy.CloseUserAssetSessions(user.ID, []uint{asset.ID}, "临时访问授权已到期"))
+
+	_, err = conn.ExecContext(ctx, "UPDATE orders SET status = 3 WHERE id = 7")
+	require.Error(t, err)
+
+	var session models.SessionRecord
+	require.Eventually(t, func() bool {
+		return db.Where("protocol = ? AND status = ?", models.DatabaseProtocolMySQL, "closed").First(&session).Error == nil
+	}, 5*time.Second, 20*time.Millisecond)
+	require.Equal(t, "临时访问授权已到期", session.CloseReason)
+}
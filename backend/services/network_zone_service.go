@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// NetworkZoneService 网络区域服务
+// 网络区域为区域内的资产统一指定网关（跳板机），资产自身配置的网关优先
+type NetworkZoneService struct {
+	db       *gorm.DB
+	gateways *GatewayChainService
+}
+
+// NewNetworkZoneService 创建网络区域服务实例
+func NewNetworkZoneService(db *gorm.DB) *NetworkZoneService {
+	return &NetworkZoneService{
+		db:       db,
+		gateways: NewGatewayChainService(db),
+	}
+}
+
+// CreateZone 创建网络区域
+func (s *NetworkZoneService) CreateZone(request *models.NetworkZoneCreateRequest) (*models.NetworkZoneResponse, error) {
+	if err := s.checkName(request.Name, 0); err != nil {
+		return nil, err
+	}
+
+	zone := models.NetworkZone{
+		Name:        request.Name,
+		Description: request.Description,
+	}
+	if request.GatewayID != nil && *request.GatewayID != 0 {
+		if err := s.gateways.ValidateZoneGateway(*request.GatewayID, derefUint(request.GatewayCredentialID)); err != nil {
+			return nil, err
+		}
+		zone.GatewayID = request.GatewayID
+		zone.GatewayCredentialID = request.GatewayCredentialID
+	}
+
+	if err := s.db.Create(&zone).Error; err != nil {
+		return nil, fmt.Errorf("failed to create network zone: %w", err)
+	}
+	return s.GetZone(zone.ID)
+}
+
+// GetZones 获取网络区域列表
+func (s *NetworkZoneService) GetZones(request *models.NetworkZoneListRequest) ([]*models.NetworkZoneResponse, int64, error) {
+	query := s.db.Model(&models.NetworkZone{})
+	if request.Keyword != "" {
+		query = query.Where("name LIKE ? OR description LIKE ?", "%"+request.Keyword+"%", "%"+request.Keyword+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count network zones: %w", err)
+	}
+
+	var zones []models.NetworkZone
+	offset := (request.Page - 1) * request.PageSize
+	if err := query.Preload("Gateway").Order("id ASC").Offset(offset).Limit(request.PageSize).Find(&zones).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query network zones: %w", err)
+	}
+
+	responses := make([]*models.NetworkZoneResponse, len(zones))
+	for i := range zones {
+		responses[i] = s.toResponse(&zones[i])
+	}
+	return responses, total, nil
+}
+
+// GetZone 获取网络区域详情
+func (s *NetworkZoneService) GetZone(id uint) (*models.NetworkZoneResponse, error) {
+	zone, err := s.getZone(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toResponse(zone), nil
+}
+
+// UpdateZone 更新网络区域，gateway_id 传0表示区域内资产改为直连
+func (s *NetworkZoneService) UpdateZone(id uint, request *models.NetworkZoneUpdateRequest) (*models.NetworkZoneResponse, error) {
+	zone, err := s.getZone(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if request.Name != "" && request.Name != zone.Name {
+		if err := s.checkName(request.Name, id); err != nil {
+			return nil, err
+		}
+		updates["name"] = request.Name
+	}
+	if request.Description != nil {
+		updates["description"] = *request.Description
+	}
+	if request.GatewayID != nil || request.GatewayCredentialID != nil {
+		gatewayID := derefUint(zone.GatewayID)
+		credentialID := derefUint(zone.GatewayCredentialID)
+		if request.GatewayID != nil {
+			gatewayID = *request.GatewayID
+		}
+		if request.GatewayCredentialID != nil {
+			credentialID = *request.GatewayCredentialID
+		}
+
+		if gatewayID == 0 {
+			updates["gateway_id"] = nil
+			updates["gateway_credential_id"] = nil
+		} else {
+			if err := s.gateways.ValidateZoneGateway(gatewayID, credentialID); err != nil {
+				return nil, err
+			}
+			updates["gateway_id"] = gatewayID
+			updates["gateway_credential_id"] = credentialID
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(zone).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update network zone: %w", err)
+		}
+	}
+	return s.GetZone(id)
+}
+
+// DeleteZone 删除网络区域，区域内的资产移出区域
+func (s *NetworkZoneService) DeleteZone(id uint) error {
+	zone, err := s.getZone(id)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Asset{}).Where("zone_id = ?", id).Update("zone_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to detach assets from network zone: %w", err)
+		}
+		if err := tx.Delete(zone).Error; err != nil {
+			return fmt.Errorf("failed to delete network zone: %w", err)
+		}
+		return nil
+	})
+}
+
+// getZone 查询网络区域
+func (s *NetworkZoneService) getZone(id uint) (*models.NetworkZone, error) {
+	var zone models.NetworkZone
+	if err := s.db.Preload("Gateway").Where("id = ?", id).First(&zone).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: network zone %d", utils.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to query network zone: %w", err)
+	}
+	return &zone, nil
+}
+
+// checkName 检查区域名称是否重复
+func (s *NetworkZoneService) checkName(name string, excludeID uint) error {
+	var count int64
+	if err := s.db.Model(&models.NetworkZone{}).Where("name = ? AND id != ?", name, excludeID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check network zone name: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: network zone name already exists", utils.ErrDuplicate)
+	}
+	return nil
+}
+
+// toResponse 转换为响应并统计区域内的资产数
+func (s *NetworkZoneService) toResponse(zone *models.NetworkZone) *models.NetworkZoneResponse {
+	response := zone.ToResponse()
+	s.db.Model(&models.Asset{}).Where("zone_id = ?", zone.ID).Count(&response.AssetCount)
+	return response
+}
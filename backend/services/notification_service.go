@@ -0,0 +1,147 @@
+package services
+
+import (
+	"bastion/interfaces"
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationService 通知发送器
+// 事件写入日志，安全类事件同时以 system_alert 消息推送给在线的监控客户端
+type NotificationService struct{}
+
+// NewNotificationService 创建通知发送器
+func NewNotificationService() *NotificationService {
+	return &NotificationService{}
+}
+
+// NotifySessionStart 会话开始通知
+func (ns *NotificationService) NotifySessionStart(ctx context.Context, session interfaces.SessionInfo) error {
+	logrus.WithFields(sessionFields(session)).Info("会话开始")
+	return nil
+}
+
+// NotifySessionEnd 会话结束通知
+func (ns *NotificationService) NotifySessionEnd(ctx context.Context, session interfaces.SessionInfo) error {
+	logrus.WithFields(sessionFields(session)).Info("会话结束")
+	return nil
+}
+
+// NotifySessionTimeout 会话超时通知
+func (ns *NotificationService) NotifySessionTimeout(ctx context.Context, session interfaces.SessionInfo) error {
+	logrus.WithFields(sessionFields(session)).Warn("会话超时")
+	return nil
+}
+
+// NotifySecurityEvent 安全事件通知
+func (ns *NotificationService) NotifySecurityEvent(ctx context.Context, eventType string, details interface{}) error {
+	logrus.WithFields(logrus.Fields{
+		"event":   eventType,
+		"details": details,
+	}).Warn("安全事件")
+
+	ns.broadcast(map[string]interface{}{
+		"event":   eventType,
+		"details": details,
+	})
+	return nil
+}
+
+// NotifyFailedLogin 登录失败次数过多通知
+func (ns *NotificationService) NotifyFailedLogin(ctx context.Context, userID uint, attempts int) error {
+	logrus.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"attempts": attempts,
+	}).Warn("登录失败次数过多，账号已锁定")
+
+	ns.broadcast(map[string]interface{}{
+		"event":    "failed_login",
+		"user_id":  userID,
+		"attempts": attempts,
+	})
+	return nil
+}
+
+// NotifySystemMaintenance 系统维护通知
+func (ns *NotificationService) NotifySystemMaintenance(ctx context.Context, message string, affectedUsers []uint) error {
+	logrus.WithFields(logrus.Fields{
+		"message":        message,
+		"affected_users": affectedUsers,
+	}).Info("系统维护通知")
+
+	ns.broadcast(map[string]interface{}{
+		"event":          "system_maintenance",
+		"message":        message,
+		"affected_users": affectedUsers,
+	})
+	return nil
+}
+
+// NotifyAssetUnavailable 资产不可用通知
+func (ns *NotificationService) NotifyAssetUnavailable(ctx context.Context, assetID uint, reason string) error {
+	logrus.WithFields(logrus.Fields{
+		"asset_id": assetID,
+		"reason":   reason,
+	}).Warn("资产不可用")
+
+	ns.broadcast(map[string]interface{}{
+		"event":    "asset_unavailable",
+		"asset_id": assetID,
+		"reason":   reason,
+	})
+	return nil
+}
+
+// NotifyUsers 向指定用户推送通知，用户不在线时只记录日志
+func (ns *NotificationService) NotifyUsers(ctx context.Context, userIDs []uint, eventType string, details interface{}) error {
+	logrus.WithFields(logrus.Fields{
+		"event":    eventType,
+		"user_ids": userIDs,
+		"details":  details,
+	}).Info("用户通知")
+
+	if GlobalWebSocketService == nil {
+		return nil
+	}
+	for _, userID := range userIDs {
+		GlobalWebSocketService.SendMessageToUser(userID, WSMessage{
+			Type: SystemAlert,
+			Data: map[string]interface{}{
+				"event":   eventType,
+				"details": details,
+			},
+			Timestamp: time.Now(),
+			UserID:    userID,
+		})
+	}
+	return nil
+}
+
+// broadcast 推送告警给监控客户端，WebSocket服务未启用时忽略
+func (ns *NotificationService) broadcast(data map[string]interface{}) {
+	if GlobalWebSocketService == nil {
+		return
+	}
+	GlobalWebSocketService.BroadcastToMonitorClients(WSMessage{
+		Type:      SystemAlert,
+		Data:      data,
+		Timestamp: time.Now(),
+	})
+}
+
+// sessionFields 会话日志字段
+func sessionFields(session interfaces.SessionInfo) logrus.Fields {
+	return logrus.Fields{
+		"session_id": session.GetSessionID(),
+		"user_id":    session.GetUserID(),
+		"asset_id":   session.GetAssetID(),
+		"client_ip":  session.GetClientIP(),
+	}
+}
+
+// notifier 获取已注册的通知发送器，未注册时返回nil
+func notifier() interfaces.NotificationSender {
+	return GlobalServiceRegistry.GetNotificationSender()
+}
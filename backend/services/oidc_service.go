@@ -0,0 +1,305 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcDefaultStateExpire = 600
+	oidcHTTPTimeout        = 10 * time.Second
+)
+
+// oidcLoginState 发起登录时保存的状态，回调时校验
+type oidcLoginState struct {
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"` // PKCE code_verifier
+}
+
+// OIDCService OpenID Connect单点登录服务
+// 使用授权码 + PKCE 流程，ID Token 通过提供方的 JWKS 校验签名，
+// 首次登录自动创建本地用户，每次登录按 groups 声明重新分配角色。
+type OIDCService struct {
+	db          *gorm.DB
+	cfg         config.OIDCConfig
+	authService *AuthService
+	httpClient  *http.Client
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+// NewOIDCService 创建OIDC单点登录服务
+func NewOIDCService(db *gorm.DB) *OIDCService {
+	s := &OIDCService{
+		db:          db,
+		authService: NewAuthService(db),
+		httpClient:  &http.Client{Timeout: oidcHTTPTimeout},
+	}
+	if config.GlobalConfig != nil {
+		s.cfg = config.GlobalConfig.OIDC
+	}
+	return s
+}
+
+// Enabled 是否启用了OIDC单点登录
+func (s *OIDCService) Enabled() bool {
+	return s.cfg.Enable
+}
+
+// FrontendURL 登录完成后跳转的前端地址
+func (s *OIDCService) FrontendURL() string {
+	return s.cfg.FrontendURL
+}
+
+// AuthCodeURL 生成跳转到提供方的授权地址，state、nonce 和 PKCE 校验码保存到Redis
+func (s *OIDCService) AuthCodeURL() (string, error) {
+	if !s.cfg.Enable {
+		return "", fmt.Errorf("%w: oidc login is not enabled", utils.ErrInvalidParam)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcHTTPTimeout)
+	defer cancel()
+
+	oauthConfig, err := s.oauthConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomURLToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomURLToken()
+	if err != nil {
+		return "", err
+	}
+	loginState := oidcLoginState{Nonce: nonce, Verifier: oauth2.GenerateVerifier()}
+
+	data, err := json.Marshal(loginState)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode oidc state: %w", err)
+	}
+	if err := utils.GetRedis().Set(ctx, oidcStateKey(state), data, s.stateExpire()).Err(); err != nil {
+		return "", fmt.Errorf("failed to save oidc state: %w", err)
+	}
+
+	return oauthConfig.AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.S256ChallengeOption(loginState.Verifier),
+	), nil
+}
+
+// Callback 处理提供方回调：校验state，用授权码换取并校验ID Token，同步用户后签发堡垒机令牌
+// 校验通过但用户同步失败时返回的结果中包含用户名，便于记录登录日志
+func (s *OIDCService) Callback(code, state, clientIP, userAgent string) (*LoginResult, error) {
+	if !s.cfg.Enable {
+		return nil, fmt.Errorf("%w: oidc login is not enabled", utils.ErrInvalidParam)
+	}
+	if code == "" || state == "" {
+		return nil, fmt.Errorf("%w: code and state are required", utils.ErrInvalidParam)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), oidcHTTPTimeout)
+	defer cancel()
+
+	loginState, err := s.consumeState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+
+	oauthConfig, err := s.oauthConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := oauthConfig.Exchange(oidc.ClientContext(ctx, s.httpClient), code, oauth2.VerifierOption(loginState.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response does not contain an id_token")
+	}
+
+	provider, err := s.getProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 校验签名（JWKS按需拉取并缓存）、签发方、受众和有效期
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if idToken.Nonce != loginState.Nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse id_token claims: %w", err)
+	}
+
+	profile := &externalProfile{
+		Source:     models.AuthSourceOIDC,
+		ExternalID: idToken.Subject,
+		Username:   claimString(claims, s.claimName(s.cfg.UsernameClaim, "preferred_username")),
+		Email:      claimString(claims, s.claimName(s.cfg.EmailClaim, "email")),
+	}
+	if profile.Username == "" {
+		return nil, errors.New("id_token does not contain a username claim")
+	}
+	failed := &LoginResult{User: &models.User{Username: profile.Username}}
+
+	groups := claimStrings(claims, s.claimName(s.cfg.GroupsClaim, "groups"))
+	roles, err := mapGroupRoles(s.db, groups, s.cfg.GroupMappings, s.cfg.DefaultRoles, strings.EqualFold)
+	if err != nil {
+		return failed, err
+	}
+	if len(roles) == 0 {
+		return failed, errors.New("user is not a member of any authorized oidc group")
+	}
+
+	user, err := provisionExternalUser(s.db, profile, roles)
+	if err != nil {
+		return failed, err
+	}
+	if user, err = s.authService.loadActiveUser(user.ID); err != nil {
+		return failed, err
+	}
+	if err := s.authService.CheckLoginPolicy(user, clientIP); err != nil {
+		return &LoginResult{User: user}, err
+	}
+
+	result, err := s.authService.issueLogin(user, clientIP, userAgent)
+	if err != nil {
+		return &LoginResult{User: user}, err
+	}
+	return result, nil
+}
+
+// consumeState 取出并删除登录状态，每个state只能使用一次
+func (s *OIDCService) consumeState(ctx context.Context, state string) (*oidcLoginState, error) {
+	key := oidcStateKey(state)
+	data, err := utils.GetRedis().Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, errors.New("oidc login state is invalid or expired, please login again")
+	}
+	// 删除失败说明已被并发请求使用
+	if deleted, err := utils.GetRedis().Del(ctx, key).Result(); err != nil || deleted == 0 {
+		return nil, errors.New("oidc login state is invalid or expired, please login again")
+	}
+
+	var loginState oidcLoginState
+	if err := json.Unmarshal(data, &loginState); err != nil {
+		return nil, fmt.Errorf("failed to decode oidc state: %w", err)
+	}
+	return &loginState, nil
+}
+
+// oauthConfig 构造OAuth2客户端配置
+func (s *OIDCService) oauthConfig(ctx context.Context) (*oauth2.Config, error) {
+	provider, err := s.getProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := s.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+
+	return &oauth2.Config{
+		ClientID:     s.cfg.ClientID,
+		ClientSecret: s.cfg.ClientSecret,
+		RedirectURL:  s.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       scopes,
+	}, nil
+}
+
+// getProvider 获取提供方元数据，首次使用时通过 discovery 加载并缓存
+// 不在启动时加载，避免提供方不可用时影响堡垒机启动
+func (s *OIDCService) getProvider(ctx context.Context) (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider != nil {
+		return s.provider, nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, s.httpClient), s.cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load oidc provider: %w", err)
+	}
+	s.provider = provider
+	return provider, nil
+}
+
+// stateExpire 登录状态有效期
+func (s *OIDCService) stateExpire() time.Duration {
+	if s.cfg.StateExpire > 0 {
+		return time.Duration(s.cfg.StateExpire) * time.Second
+	}
+	return oidcDefaultStateExpire * time.Second
+}
+
+// claimName 声明名称，未配置时使用默认值
+func (s *OIDCService) claimName(configured, fallback string) string {
+	if configured != "" {
+		return configured
+	}
+	return fallback
+}
+
+// oidcStateKey 登录状态的Redis键
+func oidcStateKey(state string) string {
+	return "oidc:state:" + state
+}
+
+// claimString 读取字符串类型的声明
+func claimString(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return strings.TrimSpace(value)
+}
+
+// claimStrings 读取字符串数组类型的声明，兼容提供方将单个值返回为字符串的情况
+func claimStrings(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// randomURLToken 生成URL安全的随机串
+func randomURLToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,406 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const (
+	testOIDCClientID     = "bastion"
+	testOIDCClientSecret = "oidc-client-secret"
+	testOIDCRedirectURL  = "https://bastion.example.com/api/v1/auth/oidc/callback"
+)
+
+// testOIDCAuthorization 授权端点记录的一次授权，换取令牌时校验
+type testOIDCAuthorization struct {
+	Challenge string
+	Claims    map[string]interface{}
+}
+
+// testOIDCIssuer 模拟OIDC提供方，提供discovery、JWKS与令牌端点
+type testOIDCIssuer struct {
+	server     *httptest.Server
+	signingKey *rsa.PrivateKey
+
+	mu             sync.Mutex
+	authorizations map[string]*testOIDCAuthorization
+	tokenRequests  int
+	forgingKey     *rsa.PrivateKey // 设置后使用未在JWKS中公布的密钥签名
+}
+
+// newTestOIDCIssuer 启动模拟提供方
+func newTestOIDCIssuer(t *testing.T) *testOIDCIssuer {
+	t.Helper()
+	issuer := &testOIDCIssuer{
+		signingKey:     generateRSAKey(t),
+		authorizations: make(map[string]*testOIDCAuthorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.handleDiscovery)
+	mux.HandleFunc("/keys", issuer.handleJWKS)
+	mux.HandleFunc("/token", issuer.handleToken)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// URL 提供方地址，同时作为签发方标识
+func (i *testOIDCIssuer) URL() string {
+	return i.server.URL
+}
+
+// authorize 模拟用户在提供方完成登录：按授权地址中的参数登记授权码，ID Token 使用请求中的nonce
+func (i *testOIDCIssuer) authorize(t *testing.T, authURL string, claims map[string]interface{}) (code, state string) {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	query := parsed.Query()
+	require.Equal(t, testOIDCClientID, query.Get("client_id"))
+	require.Equal(t, testOIDCRedirectURL, query.Get("redirect_uri"))
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+	require.NotEmpty(t, query.Get("code_challenge"))
+	require.NotEmpty(t, query.Get("nonce"))
+
+	idClaims := map[string]interface{}{
+		"iss":   i.URL(),
+		"aud":   testOIDCClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	code, err = randomURLToken()
+	require.NoError(t, err)
+	i.mu.Lock()
+	i.authorizations[code] = &testOIDCAuthorization{Challenge: query.Get("code_challenge"), Claims: idClaims}
+	i.mu.Unlock()
+	return code, query.Get("state")
+}
+
+// forgeSignatures 之后签发的ID Token使用未公布的密钥签名
+func (i *testOIDCIssuer) forgeSignatures(key *rsa.PrivateKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.forgingKey = key
+}
+
+// tokenRequestCount 令牌端点收到的请求数
+func (i *testOIDCIssuer) tokenRequestCount() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.tokenRequests
+}
+
+func (i *testOIDCIssuer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                i.URL(),
+		"authorization_endpoint":                i.URL() + "/authorize",
+		"token_endpoint":                        i.URL() + "/token",
+		"jwks_uri":                              i.URL() + "/keys",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (i *testOIDCIssuer) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeTestJSON(w, http.StatusOK, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key: &i.signingKey.PublicKey, KeyID: "test-key", Algorithm: "RS256", Use: "sig",
+	}}})
+}
+
+// handleToken 校验客户端凭据、授权码与PKCE校验码后签发ID Token，授权码只能使用一次
+func (i *testOIDCIssuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	i.tokenRequests++
+	i.mu.Unlock()
+
+	if err := r.ParseForm(); err != nil {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != testOIDCClientID || clientSecret != testOIDCClientSecret {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	i.mu.Lock()
+	authorization := i.authorizations[r.PostForm.Get("code")]
+	delete(i.authorizations, r.PostForm.Get("code"))
+	signingKey := i.signingKey
+	if i.forgingKey != nil {
+		signingKey = i.forgingKey
+	}
+	i.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if authorization == nil || base64.RawURLEncoding.EncodeToString(verifier[:]) != authorization.Challenge {
+		writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := signTestIDToken(signingKey, authorization.Claims)
+	if err != nil {
+		writeTestJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "provider-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// signTestIDToken 使用RS256签名ID Token
+func signTestIDToken(key *rsa.PrivateKey, claims map[string]interface{}) (string, error) {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test-key"))
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return signed.CompactSerialize()
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func generateRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+// setupOIDCService 使用模拟提供方、miniredis与SQLite创建OIDC服务
+func setupOIDCService(t *testing.T, configure func(cfg *config.OIDCConfig)) (*OIDCService, *testOIDCIssuer, *gorm.DB) {
+	t.Helper()
+	setupTestConfig(t)
+	config.GlobalConfig.JWT = config.JWTConfig{Secret: "oidc-test-secret", Expire: 3600, Issuer: "bastion-test"}
+
+	issuer := newTestOIDCIssuer(t)
+	config.GlobalConfig.OIDC = config.OIDCConfig{
+		Enable:        true,
+		Issuer:        issuer.URL(),
+		ClientID:      testOIDCClientID,
+		ClientSecret:  testOIDCClientSecret,
+		RedirectURL:   testOIDCRedirectURL,
+		GroupMappings: []config.GroupRoleMapping{{Group: "ops", Role: "operator"}},
+	}
+	if configure != nil {
+		configure(&config.GlobalConfig.OIDC)
+	}
+
+	redisServer := miniredis.RunT(t)
+	utils.Redis = redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { utils.Redis.Close() })
+
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+		&models.UserMFA{}, &models.UserMFARecoveryCode{}, &models.PasswordPolicy{}, &models.PasswordHistory{}, &models.AccessPolicy{})
+	for _, name := range []string{"operator", "viewer"} {
+		require.NoError(t, db.Create(&models.Role{Name: name}).Error)
+	}
+	return NewOIDCService(db), issuer, db
+}
+
+// beginOIDCLogin 发起登录并在提供方完成授权，返回回调参数
+func beginOIDCLogin(t *testing.T, service *OIDCService, issuer *testOIDCIssuer, claims map[string]interface{}) (code, state string) {
+	t.Helper()
+	authURL, err := service.AuthCodeURL()
+	require.NoError(t, err)
+	return issuer.authorize(t, authURL, claims)
+}
+
+func TestOIDCCallbackProvisionsUserWithMappedRoles(t *testing.T) {
+	service, issuer, db := setupOIDCService(t, nil)
+
+	code, state := beginOIDCLogin(t, service, issuer, map[string]interface{}{
+		"sub": "user-1001", "preferred_username": "alice", "email": "alice@example.com", "groups": []string{"OPS", "sales"},
+	})
+	result, err := service.Callback(code, state, "192.0.2.10", "test-agent")
+	require.NoError(t, err)
+	require.NotNil(t, result.Token)
+	require.NotEmpty(t, result.Token.AccessToken)
+
+	var user models.User
+	require.NoError(t, db.Where("username = ?", "alice").First(&user).Error)
+	require.Equal(t, models.AuthSourceOIDC, user.AuthSource)
+	require.Equal(t, "user-1001", user.ExternalID)
+	require.Equal(t, "alice@example.com", user.Email)
+	require.ElementsMatch(t, []string{"operator"}, userRoleNames(t, db, user.ID))
+
+	// 签发令牌时即登记登录会话
+	sessions, err := utils.ListLoginSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, "192.0.2.10", sessions[0].IP)
+	require.Equal(t, "test-agent", sessions[0].UserAgent)
+}
+
+func TestOIDCCallbackUsesDefaultRolesAndConfiguredClaims(t *testing.T) {
+	service, issuer, db := setupOIDCService(t, func(cfg *config.OIDCConfig) {
+		cfg.UsernameClaim = "login"
+		cfg.GroupsClaim = "roles"
+		cfg.DefaultRoles = []string{"viewer"}
+	})
+
+	code, state := beginOIDCLogin(t, service, issuer, map[string]interface{}{
+		"sub": "user-1002", "login": "bob", "preferred_username": "ignored", "roles": "sales",
+	})
+	result, err := service.Callback(code, state, "192.0.2.10", "test-agent")
+	require.NoError(t, err)
+	require.Equal(t, "bob", result.User.Username)
+	require.ElementsMatch(t, []string{"viewer"}, userRoleNames(t, db, result.User.ID))
+}
+
+func TestOIDCCallbackRejectsUnmappedGroups(t *testing.T) {
+	service, issuer, db := setupOIDCService(t, nil)
+
+	code, state := beginOIDCLogin(t, service, issuer, map[string]interface{}{
+		"sub": "user-1003", "preferred_username": "carol", "groups": []string{"sales"},
+	})
+	result, err := service.Callback(code, state, "192.0.2.10", "test-agent")
+	require.Error(t, err)
+	require.Equal(t, "carol", result.User.Username)
+
+	var count int64
+	db.Model(&models.User{}).Where("username = ?", "carol").Count(&count)
+	require.Zero(t, count)
+}
+
+func TestOIDCCallbackStateIsSingleUse(t *testing.T) {
+	service, issuer, _ := setupOIDCService(t, nil)
+
+	claims := map[string]interface{}{"sub": "user-1001", "preferred_username": "alice", "groups": []string{"ops"}}
+	code, state := beginOIDCLogin(t, service, issuer, claims)
+	_, err := service.Callback(code, state, "192.0.2.10", "test-agent")
+	require.NoError(t, err)
+
+	// 同一state重放时在换取令牌前就被拒绝
+	requests := issuer.tokenRequestCount()
+	_, err = service.Callback(code, state, "192.0.2.10", "test-agent")
+	require.ErrorContains(t, err, "oidc login state is invalid or expired")
+	require.Equal(t, requests, issuer.tokenRequestCount())
+
+	// 伪造的state同样被拒绝
+	_, err = service.Callback(code, "forged-state", "192.0.2.10", "test-agent")
+	require.ErrorContains(t, err, "oidc login state is invalid or expired")
+}
+
+func TestOIDCCallbackRejectsInvalidIDTokens(t *testing.T) {
+	cases := []struct {
+		name   string
+		claims map[string]interface{}
+		sign   *rsa.PrivateKey
+		err    string
+	}{
+		{name: "nonce mismatch", claims: map[string]interface{}{"nonce": "replayed-nonce"}, err: "nonce mismatch"},
+		{name: "wrong audience", claims: map[string]interface{}{"aud": "another-client"}, err: "invalid id_token"},
+		{name: "wrong issuer", claims: map[string]interface{}{"iss": "https://evil.example.com"}, err: "invalid id_token"},
+		{name: "expired", claims: map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}, err: "invalid id_token"},
+		{name: "untrusted signing key", sign: generateRSAKey(t), err: "invalid id_token"},
+		{name: "missing username", claims: map[string]interface{}{"preferred_username": ""}, err: "username claim"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, issuer, db := setupOIDCService(t, nil)
+
+			claims := map[string]interface{}{"sub": "user-1001", "preferred_username": "alice", "groups": []string{"ops"}}
+			for name, value := range tc.claims {
+				claims[name] = value
+			}
+			code, state := beginOIDCLogin(t, service, issuer, claims)
+			if tc.sign != nil {
+				issuer.forgeSignatures(tc.sign)
+			}
+
+			result, err := service.Callback(code, state, "192.0.2.10", "test-agent")
+			require.ErrorContains(t, err, tc.err)
+			require.Nil(t, result)
+
+			var count int64
+			db.Model(&models.User{}).Count(&count)
+			require.Zero(t, count)
+		})
+	}
+}
+
+func TestOIDCCallbackRejectsWrongCodeVerifier(t *testing.T) {
+	service, issuer, _ := setupOIDCService(t, nil)
+
+	code, state := beginOIDCLogin(t, service, issuer, map[string]interface{}{
+		"sub": "user-1001", "preferred_username": "alice", "groups": []string{"ops"},
+	})
+	// 授权码被另一次登录（不同的PKCE校验码）截获使用时，提供方拒绝换取令牌
+	_, otherState := beginOIDCLogin(t, service, issuer, nil)
+	_, err := service.Callback(code, otherState, "192.0.2.10", "test-agent")
+	require.ErrorContains(t, err, "failed to exchange authorization code")
+
+	// 授权码已被消费，原登录也无法继续
+	_, err = service.Callback(code, state, "192.0.2.10", "test-agent")
+	require.ErrorContains(t, err, "failed to exchange authorization code")
+}
+
+func TestOIDCCallbackDoesNotTakeOverLocalAccount(t *testing.T) {
+	service, issuer, db := setupOIDCService(t, nil)
+
+	local := models.User{Username: "admin", Password: "local-hash", Status: 1, AuthSource: models.AuthSourceLocal}
+	require.NoError(t, db.Create(&local).Error)
+
+	code, state := beginOIDCLogin(t, service, issuer, map[string]interface{}{
+		"sub": "attacker", "preferred_username": "admin", "groups": []string{"ops"},
+	})
+	_, err := service.Callback(code, state, "192.0.2.10", "test-agent")
+	require.Error(t, err)
+
+	var user models.User
+	require.NoError(t, db.First(&user, local.ID).Error)
+	require.Equal(t, models.AuthSourceLocal, user.AuthSource)
+	require.Empty(t, user.ExternalID)
+	require.Empty(t, userRoleNames(t, db, user.ID))
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	service, _, _ := setupOIDCService(t, func(cfg *config.OIDCConfig) { cfg.Enable = false })
+
+	_, err := service.AuthCodeURL()
+	require.ErrorIs(t, err, utils.ErrInvalidParam)
+	_, err = service.Callback("code", "state", "192.0.2.10", "test-agent")
+	require.ErrorIs(t, err, utils.ErrInvalidParam)
+}
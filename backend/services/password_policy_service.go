@@ -0,0 +1,275 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	passwordPolicyID          = 1       // 密码策略全局只有一条记录
+	passwordChangeTokenExpire = 10 * 60 // 密码过期后修改密码专用令牌的有效期，秒
+)
+
+// commonPasswords 常见弱密码，比较时忽略大小写以及末尾的数字和符号
+var commonPasswords = map[string]bool{
+	"password": true, "passw0rd": true, "p@ssw0rd": true, "p@ssword": true,
+	"admin": true, "admin123": true, "administrator": true, "root": true, "toor": true,
+	"qwerty": true, "qwertyuiop": true, "asdfgh": true, "asdfghjkl": true, "zxcvbnm": true,
+	"abc": true, "abcd": true, "abcdef": true, "abc123": true, "a1b2c3": true,
+	"letmein": true, "welcome": true, "iloveyou": true, "monkey": true, "dragon": true,
+	"master": true, "login": true, "changeme": true, "default": true, "test": true,
+	"guest": true, "user": true, "secret": true, "sunshine": true, "football": true,
+	"1q2w3e": true, "1q2w3e4r": true, "qazwsx": true, "1qaz2wsx": true, "zaq12wsx": true,
+	"123456": true, "12345678": true, "123456789": true, "1234567890": true, "111111": true,
+	"000000": true, "666666": true, "888888": true, "123123": true, "654321": true,
+	"bastion": true,
+}
+
+// PasswordPolicyService 密码策略服务
+type PasswordPolicyService struct {
+	db *gorm.DB
+}
+
+// NewPasswordPolicyService 创建密码策略服务实例
+func NewPasswordPolicyService(db *gorm.DB) *PasswordPolicyService {
+	return &PasswordPolicyService{db: db}
+}
+
+// defaultPasswordPolicy 未配置策略时使用的默认值，与原有的密码强度要求一致
+func defaultPasswordPolicy() *models.PasswordPolicy {
+	return &models.PasswordPolicy{
+		ID:             passwordPolicyID,
+		MinLength:      6,
+		RequireDigit:   true,
+		MinClasses:     2,
+		ForbidUsername: true,
+		ForbidCommon:   true,
+	}
+}
+
+// GetPolicy 获取密码策略，未配置时返回默认策略
+func (s *PasswordPolicyService) GetPolicy() (*models.PasswordPolicy, error) {
+	var policy models.PasswordPolicy
+	if err := s.db.Where("id = ?", passwordPolicyID).First(&policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return defaultPasswordPolicy(), nil
+		}
+		return nil, fmt.Errorf("failed to get password policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// UpdatePolicy 更新密码策略，只影响之后设置的密码和有效期计算
+func (s *PasswordPolicyService) UpdatePolicy(request *models.PasswordPolicyUpdateRequest, updatedBy string) (*models.PasswordPolicy, error) {
+	policy, err := s.GetPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	policy.MinLength = request.MinLength
+	policy.RequireUppercase = request.RequireUppercase
+	policy.RequireLowercase = request.RequireLowercase
+	policy.RequireDigit = request.RequireDigit
+	policy.RequireSpecial = request.RequireSpecial
+	policy.MinClasses = request.MinClasses
+	policy.ForbidUsername = request.ForbidUsername
+	policy.ForbidCommon = request.ForbidCommon
+	policy.DictionaryWords = strings.Join(splitDictionaryWords(request.DictionaryWords), ",")
+	policy.HistoryCount = request.HistoryCount
+	policy.MaxAgeDays = request.MaxAgeDays
+	policy.UpdatedBy = updatedBy
+
+	if err := s.db.Save(policy).Error; err != nil {
+		return nil, fmt.Errorf("failed to save password policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+// Validate 按密码策略校验新密码
+// user 为空或ID为0时（创建用户）不检查历史密码
+func (s *PasswordPolicyService) Validate(user *models.User, password string) error {
+	policy, err := s.GetPolicy()
+	if err != nil {
+		return err
+	}
+
+	if len([]rune(password)) < policy.MinLength {
+		return fmt.Errorf("%w: password must be at least %d characters", utils.ErrInvalidParam, policy.MinLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, char := range password {
+		switch {
+		case unicode.IsUpper(char):
+			hasUpper = true
+		case unicode.IsLower(char):
+			hasLower = true
+		case unicode.IsDigit(char):
+			hasDigit = true
+		case !unicode.IsSpace(char):
+			hasSpecial = true
+		}
+	}
+	if policy.RequireUppercase && !hasUpper {
+		return fmt.Errorf("%w: password must contain an uppercase letter", utils.ErrInvalidParam)
+	}
+	if policy.RequireLowercase && !hasLower {
+		return fmt.Errorf("%w: password must contain a lowercase letter", utils.ErrInvalidParam)
+	}
+	if policy.RequireDigit && !hasDigit {
+		return fmt.Errorf("%w: password must contain a digit", utils.ErrInvalidParam)
+	}
+	if policy.RequireSpecial && !hasSpecial {
+		return fmt.Errorf("%w: password must contain a special character", utils.ErrInvalidParam)
+	}
+	classes := 0
+	for _, has := range []bool{hasUpper, hasLower, hasDigit, hasSpecial} {
+		if has {
+			classes++
+		}
+	}
+	if classes < policy.MinClasses {
+		return fmt.Errorf("%w: password must contain at least %d of uppercase letters, lowercase letters, digits and special characters",
+			utils.ErrInvalidParam, policy.MinClasses)
+	}
+
+	lower := strings.ToLower(password)
+	if policy.ForbidUsername && user != nil && len(user.Username) >= 3 &&
+		strings.Contains(lower, strings.ToLower(user.Username)) {
+		return fmt.Errorf("%w: password must not contain the username", utils.ErrInvalidParam)
+	}
+	if policy.ForbidCommon && isCommonPassword(lower) {
+		return fmt.Errorf("%w: password is too common", utils.ErrInvalidParam)
+	}
+	for _, word := range splitDictionaryWords(policy.DictionaryWords) {
+		if strings.Contains(lower, word) {
+			return fmt.Errorf("%w: password must not contain the word %q", utils.ErrInvalidParam, word)
+		}
+	}
+
+	if user != nil && user.ID != 0 && policy.HistoryCount > 0 {
+		reused, err := s.isReused(user, password, policy.HistoryCount)
+		if err != nil {
+			return err
+		}
+		if reused {
+			return fmt.Errorf("%w: password must not be one of the last %d passwords", utils.ErrInvalidParam, policy.HistoryCount)
+		}
+	}
+
+	return nil
+}
+
+// isReused 检查新密码是否与当前密码或最近N次使用过的密码相同
+func (s *PasswordPolicyService) isReused(user *models.User, password string, count int) (bool, error) {
+	if user.Password != "" && utils.CheckPassword(password, user.Password) {
+		return true, nil
+	}
+
+	var histories []models.PasswordHistory
+	if err := s.db.Where("user_id = ?", user.ID).Order("id DESC").Limit(count).Find(&histories).Error; err != nil {
+		return false, fmt.Errorf("failed to get password history: %w", err)
+	}
+	for _, history := range histories {
+		if utils.CheckPassword(password, history.PasswordHash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetPassword 更新用户密码及修改时间，并记录历史密码
+func (s *PasswordPolicyService) SetPassword(userID uint, passwordHash string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password":            passwordHash,
+			"password_changed_at": time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		return s.RecordPassword(tx, userID, passwordHash)
+	})
+}
+
+// RecordPassword 在事务中记录历史密码，只保留策略要求的条数
+func (s *PasswordPolicyService) RecordPassword(tx *gorm.DB, userID uint, passwordHash string) error {
+	policy, err := s.GetPolicy()
+	if err != nil {
+		return err
+	}
+	if policy.HistoryCount <= 0 {
+		return tx.Where("user_id = ?", userID).Delete(&models.PasswordHistory{}).Error
+	}
+
+	history := models.PasswordHistory{UserID: userID, PasswordHash: passwordHash}
+	if err := tx.Create(&history).Error; err != nil {
+		return fmt.Errorf("failed to save password history: %w", err)
+	}
+
+	// 删除超出保留条数的旧记录
+	var keepIDs []uint
+	if err := tx.Model(&models.PasswordHistory{}).Where("user_id = ?", userID).
+		Order("id DESC").Limit(policy.HistoryCount).Pluck("id", &keepIDs).Error; err != nil {
+		return fmt.Errorf("failed to get password history: %w", err)
+	}
+	if err := tx.Where("user_id = ? AND id NOT IN ?", userID, keepIDs).Delete(&models.PasswordHistory{}).Error; err != nil {
+		return fmt.Errorf("failed to trim password history: %w", err)
+	}
+	return nil
+}
+
+// IsExpired 检查本地用户的密码是否超过有效期，外部目录和单点登录用户不受限制
+func (s *PasswordPolicyService) IsExpired(user *models.User) (bool, error) {
+	if user.IsExternal() {
+		return false, nil
+	}
+
+	policy, err := s.GetPolicy()
+	if err != nil {
+		return false, err
+	}
+	if policy.MaxAgeDays <= 0 {
+		return false, nil
+	}
+
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	return time.Since(changedAt) > time.Duration(policy.MaxAgeDays)*24*time.Hour, nil
+}
+
+// isCommonPassword 检查是否为常见弱密码，如 Password123!
+func isCommonPassword(lower string) bool {
+	if commonPasswords[lower] {
+		return true
+	}
+	return commonPasswords[strings.TrimRightFunc(lower, func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})]
+}
+
+// splitDictionaryWords 解析逗号或换行分隔的禁用词，统一为小写并去重
+func splitDictionaryWords(words string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, word := range strings.FieldsFunc(words, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r'
+	}) {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+		result = append(result, word)
+	}
+	return result
+}
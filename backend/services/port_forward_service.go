@@ -0,0 +1,605 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// defaultTunnelDialTimeout 连接目标端口的默认超时时间
+const defaultTunnelDialTimeout = 10 * time.Second
+
+// PortForwardService 端口转发服务
+// 管理SSH网关上的 direct-tcpip 通道（ssh -L 本地转发、ssh -D 动态转发）以及绑定Web会话的本地回环隧道。
+// 转发目标必须是用户被授权的资产，端口须在授权规则的端口列表中，连接经由资产的网关链路建立。
+// 每条隧道以 tunnel 协议写入会话记录并出现在活跃会话监控中，隧道上每个通道的打开与关闭连同字节数写入操作日志。
+type PortForwardService struct {
+	db                *gorm.DB
+	sshService        *SSHService
+	auditService      *AuditService
+	permissionService *AssetPermissionService
+	accessPolicy      *AccessPolicyService
+	gateways          *GatewayChainService
+	dialTimeout       time.Duration
+	maxWebTunnels     int
+	tunnels           map[string]*PortTunnel
+	mu                sync.Mutex
+}
+
+// PortTunnel 一条端口转发隧道
+// SSH网关上同一连接到同一资产端口的通道共用一条隧道，Web隧道对应一个回环监听端口
+type PortTunnel struct {
+	ID         string
+	Type       string
+	SessionID  string // Web隧道绑定的会话
+	ListenAddr string
+	UserID     uint
+	Username   string
+	ClientIP   string
+	Asset      models.Asset
+	Port       int
+	CreatedAt  time.Time
+
+	user          *models.User
+	listener      net.Listener
+	channels      map[io.Closer]io.Closer // 客户端通道 -> 目标连接
+	totalChannels int
+	bytesSent     int64
+	bytesReceived int64
+	closed        bool
+	mu            sync.Mutex
+}
+
+// GlobalPortForwardService 全局端口转发服务实例，SSH网关、Web隧道与会话监控共享同一隧道表
+var GlobalPortForwardService *PortForwardService
+
+// NewPortForwardService 创建端口转发服务实例
+func NewPortForwardService(db *gorm.DB, sshService *SSHService) *PortForwardService {
+	if sshService == nil {
+		sshService = NewSSHService(db)
+	}
+
+	cfg := config.GlobalConfig.PortForward
+	dialTimeout := time.Duration(cfg.DialTimeout) * time.Second
+	if dialTimeout <= 0 {
+		dialTimeout = defaultTunnelDialTimeout
+	}
+	maxWebTunnels := cfg.MaxWebTunnels
+	if maxWebTunnels <= 0 {
+		maxWebTunnels = 5
+	}
+
+	return &PortForwardService{
+		db:                db,
+		sshService:        sshService,
+		auditService:      NewAuditService(db),
+		permissionService: NewAssetPermissionService(db),
+		accessPolicy:      NewAccessPolicyService(db),
+		gateways:          NewGatewayChainService(db),
+		dialTimeout:       dialTimeout,
+		maxWebTunnels:     maxWebTunnels,
+		tunnels:           make(map[string]*PortTunnel),
+	}
+}
+
+// Enabled 是否启用端口转发
+func (s *PortForwardService) Enabled() bool {
+	return config.GlobalConfig.PortForward.Enable
+}
+
+// ResolveDestination 将 direct-tcpip 请求中的目标主机解析为用户可转发的资产
+// 主机可以是资产名称、地址或ID；地址相同的多个资产中任意一个允许该端口且符合会话访问策略即可
+func (s *PortForwardService) ResolveDestination(user *models.User, clientIP, host string, port int) (*models.Asset, error) {
+	userID := user.ID
+	assetIDs, all, err := s.permissionService.GetAuthorizedAssetIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !all && len(assetIDs) == 0 {
+		return nil, fmt.Errorf("%w: no authorized assets", utils.ErrPermissionDenied)
+	}
+
+	query := s.db.Where("name = ? OR address = ? OR id = ?", host, host, parseAssetIDString(host)).Order("id ASC")
+	if !all {
+		query = query.Where("id IN ?", assetIDs)
+	}
+	var assets []models.Asset
+	if err := query.Find(&assets).Error; err != nil {
+		return nil, fmt.Errorf("failed to query assets: %w", err)
+	}
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("%w: destination %s is not an authorized asset", utils.ErrPermissionDenied, host)
+	}
+
+	var violation *AccessPolicyViolation
+	for i := range assets {
+		if err := s.permissionService.CheckPortForward(userID, assets[i].ID, port); err != nil {
+			if !errors.Is(err, utils.ErrPermissionDenied) {
+				return nil, err
+			}
+			continue
+		}
+		if err := s.accessPolicy.CheckSession(user, assets[i].ID, clientIP); err != nil {
+			if !errors.As(err, &violation) {
+				return nil, err
+			}
+			continue
+		}
+		return &assets[i], nil
+	}
+	if violation != nil {
+		return nil, violation
+	}
+	return nil, fmt.Errorf("%w: port %d is not allowed to be forwarded on %s", utils.ErrPermissionDenied, port, host)
+}
+
+// OpenTunnel 创建隧道并写入会话记录
+func (s *PortForwardService) OpenTunnel(tunnelType string, user *models.User, clientIP string, asset *models.Asset, port int) (*PortTunnel, error) {
+	tunnel := newPortTunnel(tunnelType, user, clientIP, asset, port)
+	if err := s.registerTunnel(tunnel); err != nil {
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// ServeChannel 连接隧道目标并在客户端通道与目标之间转发数据，直到任意一端关闭
+// accept 在目标连接成功后调用以接受客户端通道，目标连接失败时不调用
+func (s *PortForwardService) ServeChannel(tunnel *PortTunnel, origin string, accept func() (io.ReadWriteCloser, error)) error {
+	start := time.Now()
+	target, chain, err := s.gateways.Dial(&tunnel.Asset, tunnel.Target(), s.dialTimeout)
+	if err != nil {
+		s.recordChannel(tunnel, "open", origin, 502, fmt.Sprintf("Failed to connect to %s: %v", tunnel.Target(), err), nil, 0)
+		return fmt.Errorf("failed to connect to %s: %w", tunnel.Target(), err)
+	}
+
+	channel, err := accept()
+	if err != nil {
+		target.Close()
+		return err
+	}
+	if !tunnel.addChannel(channel, target) {
+		channel.Close()
+		target.Close()
+		return fmt.Errorf("tunnel %s is closed", tunnel.ID)
+	}
+	s.touchTunnel(tunnel.ID)
+	s.recordChannel(tunnel, "open", origin, 200, fmt.Sprintf("Tunnel channel opened to %s", tunnel.Target()),
+		map[string]interface{}{"gateway_chain": chain.String()}, 0)
+
+	// 客户端结束发送时半关闭目标写方向，等待目标返回剩余数据；目标关闭时整个通道结束
+	var sent, received int64
+	sentDone := make(chan struct{})
+	go func() {
+		sent, _ = io.Copy(target, channel)
+		closeWrite(target)
+		close(sentDone)
+	}()
+	received, _ = io.Copy(channel, target)
+	channel.Close()
+	target.Close()
+	<-sentDone
+
+	tunnel.removeChannel(channel, sent, received)
+	s.touchTunnel(tunnel.ID)
+	s.recordChannel(tunnel, "close", origin, 200, fmt.Sprintf("Tunnel channel to %s closed", tunnel.Target()),
+		map[string]interface{}{"bytes_sent": sent, "bytes_received": received}, time.Since(start).Milliseconds())
+	return nil
+}
+
+// RecordDenied 记录被拒绝的通道请求
+func (s *PortForwardService) RecordDenied(user *models.User, clientIP, host string, port int, origin string, reason error) {
+	go s.auditService.RecordOperationLog(
+		user.ID,
+		user.Username,
+		clientIP,
+		"TUNNEL",
+		fmt.Sprintf("tunnel://%s", net.JoinHostPort(host, strconv.Itoa(port))),
+		"open",
+		"tunnel",
+		0,
+		"",
+		403,
+		fmt.Sprintf("Tunnel channel denied: %v", reason),
+		map[string]interface{}{"origin": origin},
+		nil,
+		0,
+		false,
+	)
+}
+
+// CloseTunnel 关闭隧道及其上的所有通道并结束会话记录，返回隧道是否存在
+func (s *PortForwardService) CloseTunnel(tunnelID, reason string) bool {
+	s.mu.Lock()
+	tunnel, exists := s.tunnels[tunnelID]
+	delete(s.tunnels, tunnelID)
+	s.mu.Unlock()
+	if !exists {
+		return false
+	}
+
+	tunnel.close()
+
+	now := time.Now()
+	if err := s.db.Model(&models.SessionRecord{}).
+		Where("session_id = ? AND status = ?", tunnelID, "active").
+		Updates(map[string]interface{}{
+			"status":       "closed",
+			"end_time":     now,
+			"duration":     int64(now.Sub(tunnel.CreatedAt).Seconds()),
+			"close_reason": reason,
+			"updated_at":   now,
+		}).Error; err != nil {
+		logrus.WithError(err).WithField("tunnel_id", tunnelID).Error("结束隧道会话记录失败")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"tunnel_id": tunnelID,
+		"reason":    reason,
+	}).Info("端口转发隧道已关闭")
+	return true
+}
+
+// CloseUserAssetTunnels 关闭用户在指定资产上的全部隧道，assetIDs 为空时关闭该用户的全部隧道，
+// 返回关闭的隧道数。授权收回或用户被禁用时调用
+func (s *PortForwardService) CloseUserAssetTunnels(userID uint, assetIDs []uint, reason string) int {
+	targets := make(map[uint]bool, len(assetIDs))
+	for _, id := range assetIDs {
+		targets[id] = true
+	}
+
+	s.mu.Lock()
+	var tunnelIDs []string
+	for id, tunnel := range s.tunnels {
+		if tunnel.UserID == userID && (len(targets) == 0 || targets[tunnel.Asset.ID]) {
+			tunnelIDs = append(tunnelIDs, id)
+		}
+	}
+	s.mu.Unlock()
+
+	closed := 0
+	for _, id := range tunnelIDs {
+		if s.CloseTunnel(id, reason) {
+			closed++
+		}
+	}
+	return closed
+}
+
+// OpenWebTunnel 在用户的Web会话上打开本地回环隧道，会话关闭时隧道随之关闭
+func (s *PortForwardService) OpenWebTunnel(userID uint, sessionID string, request *models.PortTunnelCreateRequest) (*models.PortTunnelResponse, error) {
+	session, err := s.getUserSession(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	assetID := request.AssetID
+	if assetID == 0 {
+		assetID = session.AssetID
+	}
+	var asset models.Asset
+	if err := s.db.Where("id = ?", assetID).First(&asset).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: asset %d", utils.ErrNotFound, assetID)
+		}
+		return nil, fmt.Errorf("failed to query asset: %w", err)
+	}
+	if err := s.permissionService.CheckPortForward(userID, asset.ID, request.Port); err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to query user: %w", err)
+	}
+	if err := s.accessPolicy.CheckSession(&user, asset.ID, session.ClientIP); err != nil {
+		return nil, err
+	}
+	if len(s.sessionTunnels(sessionID)) >= s.maxWebTunnels {
+		return nil, fmt.Errorf("%w: at most %d tunnels per session", utils.ErrInvalidParam, s.maxWebTunnels)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on loopback: %w", err)
+	}
+
+	tunnel := newPortTunnel(models.TunnelTypeWeb, &user, session.ClientIP, &asset, request.Port)
+	tunnel.SessionID = sessionID
+	tunnel.ListenAddr = listener.Addr().String()
+	tunnel.listener = listener
+	if err := s.registerTunnel(tunnel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	if session.resources != nil {
+		session.resources.AddCloseFunc("tunnel-"+tunnel.ID, func() error {
+			s.CloseTunnel(tunnel.ID, "会话已关闭")
+			return nil
+		})
+	}
+
+	go s.acceptLoop(tunnel)
+	return tunnel.ToResponse(), nil
+}
+
+// GetSessionTunnels 获取Web会话上打开的隧道
+func (s *PortForwardService) GetSessionTunnels(userID uint, sessionID string) ([]*models.PortTunnelResponse, error) {
+	if _, err := s.getUserSession(userID, sessionID); err != nil {
+		return nil, err
+	}
+
+	tunnels := s.sessionTunnels(sessionID)
+	responses := make([]*models.PortTunnelResponse, len(tunnels))
+	for i, tunnel := range tunnels {
+		responses[i] = tunnel.ToResponse()
+	}
+	return responses, nil
+}
+
+// CloseWebTunnel 关闭Web会话上的隧道
+func (s *PortForwardService) CloseWebTunnel(userID uint, sessionID, tunnelID string) error {
+	if _, err := s.getUserSession(userID, sessionID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	tunnel, exists := s.tunnels[tunnelID]
+	s.mu.Unlock()
+	if !exists || tunnel.SessionID != sessionID {
+		return fmt.Errorf("%w: tunnel %s", utils.ErrNotFound, tunnelID)
+	}
+
+	s.CloseTunnel(tunnelID, "用户关闭隧道")
+	return nil
+}
+
+// registerTunnel 写入隧道会话记录并登记到隧道表
+func (s *PortForwardService) registerTunnel(tunnel *PortTunnel) error {
+	if !s.Enabled() {
+		return fmt.Errorf("%w: port forwarding is disabled", utils.ErrPermissionDenied)
+	}
+	if !tunnel.Asset.IsActive() {
+		return fmt.Errorf("%w: asset %s is disabled", utils.ErrInvalidParam, tunnel.Asset.Name)
+	}
+
+	if err := s.auditService.RecordSessionStart(tunnel.ID, tunnel.UserID, tunnel.Username, tunnel.Asset.ID, tunnel.Asset.Name,
+		tunnel.Target(), 0, models.TunnelProtocol, tunnel.ClientIP, nil); err != nil {
+		return fmt.Errorf("failed to record tunnel session: %w", err)
+	}
+
+	s.mu.Lock()
+	s.tunnels[tunnel.ID] = tunnel
+	s.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"tunnel_id": tunnel.ID,
+		"type":      tunnel.Type,
+		"user_id":   tunnel.UserID,
+		"target":    tunnel.Target(),
+	}).Info("端口转发隧道已打开")
+	return nil
+}
+
+// acceptLoop 接受Web隧道回环端口上的连接
+func (s *PortForwardService) acceptLoop(tunnel *PortTunnel) {
+	for {
+		conn, err := tunnel.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logrus.WithError(err).WithField("tunnel_id", tunnel.ID).Warn("隧道接受连接失败")
+				s.CloseTunnel(tunnel.ID, "隧道监听异常")
+			}
+			return
+		}
+		go func() {
+			// 每个通道都重新校验端口转发授权和会话访问策略，与SSH网关上的通道一致
+			if err := s.checkChannel(tunnel); err != nil {
+				conn.Close()
+				s.recordChannel(tunnel, "open", conn.RemoteAddr().String(), 403, fmt.Sprintf("Tunnel channel denied: %v", err), nil, 0)
+				return
+			}
+			if err := s.ServeChannel(tunnel, conn.RemoteAddr().String(), func() (io.ReadWriteCloser, error) {
+				return conn, nil
+			}); err != nil {
+				conn.Close()
+				logrus.WithError(err).WithField("tunnel_id", tunnel.ID).Debug("隧道通道转发失败")
+			}
+		}()
+	}
+}
+
+// checkChannel 按当前授权规则和时间校验隧道是否仍可打开新通道
+func (s *PortForwardService) checkChannel(tunnel *PortTunnel) error {
+	if err := s.permissionService.CheckPortForward(tunnel.UserID, tunnel.Asset.ID, tunnel.Port); err != nil {
+		return err
+	}
+	return s.accessPolicy.CheckSession(tunnel.user, tunnel.Asset.ID, tunnel.ClientIP)
+}
+
+// getUserSession 获取属于当前用户的活跃会话
+func (s *PortForwardService) getUserSession(userID uint, sessionID string) (*SSHSession, error) {
+	session, err := s.sshService.GetSession(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: session %s", utils.ErrNotFound, sessionID)
+	}
+	if session.UserID != userID {
+		return nil, fmt.Errorf("%w: session %s does not belong to user %d", utils.ErrPermissionDenied, sessionID, userID)
+	}
+	return session, nil
+}
+
+// sessionTunnels 获取绑定到会话的隧道
+func (s *PortForwardService) sessionTunnels(sessionID string) []*PortTunnel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tunnels []*PortTunnel
+	for _, tunnel := range s.tunnels {
+		if tunnel.SessionID == sessionID {
+			tunnels = append(tunnels, tunnel)
+		}
+	}
+	return tunnels
+}
+
+// touchTunnel 刷新隧道会话记录的活动时间，避免长时间运行的隧道被当作陈旧会话清理
+func (s *PortForwardService) touchTunnel(tunnelID string) {
+	now := time.Now()
+	s.db.Model(&models.SessionRecord{}).
+		Where("session_id = ? AND status = ?", tunnelID, "active").
+		Updates(map[string]interface{}{"last_activity": now, "updated_at": now})
+}
+
+// recordChannel 记录通道打开或关闭的操作日志
+func (s *PortForwardService) recordChannel(tunnel *PortTunnel, action, origin string, status int, message string, details map[string]interface{}, durationMs int64) {
+	request := map[string]interface{}{
+		"tunnel_id": tunnel.ID,
+		"type":      tunnel.Type,
+		"origin":    origin,
+	}
+	if tunnel.SessionID != "" {
+		request["session_id"] = tunnel.SessionID
+	}
+
+	go s.auditService.RecordOperationLog(
+		tunnel.UserID,
+		tunnel.Username,
+		tunnel.ClientIP,
+		"TUNNEL",
+		"tunnel://"+tunnel.Target(),
+		action,
+		"tunnel",
+		tunnel.Asset.ID,
+		tunnel.ID,
+		status,
+		message,
+		request,
+		details,
+		durationMs,
+		false,
+	)
+}
+
+// parseAssetIDString 将主机名按资产ID解析，非数字时返回0
+func parseAssetIDString(host string) uint64 {
+	id, err := strconv.ParseUint(host, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// closeWrite 半关闭连接的写方向，不支持时直接关闭
+func closeWrite(conn io.Closer) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+		return
+	}
+	conn.Close()
+}
+
+// newPortTunnel 创建隧道对象
+func newPortTunnel(tunnelType string, user *models.User, clientIP string, asset *models.Asset, port int) *PortTunnel {
+	return &PortTunnel{
+		ID:        "tunnel-" + uuid.New().String(),
+		Type:      tunnelType,
+		UserID:    user.ID,
+		Username:  user.Username,
+		ClientIP:  clientIP,
+		user:      user,
+		Asset:     *asset,
+		Port:      port,
+		CreatedAt: time.Now(),
+		channels:  make(map[io.Closer]io.Closer),
+	}
+}
+
+// Target 隧道目标地址
+func (t *PortTunnel) Target() string {
+	return net.JoinHostPort(t.Asset.Address, strconv.Itoa(t.Port))
+}
+
+// ToResponse 转换为响应格式
+func (t *PortTunnel) ToResponse() *models.PortTunnelResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &models.PortTunnelResponse{
+		ID:             t.ID,
+		Type:           t.Type,
+		SessionID:      t.SessionID,
+		ListenAddr:     t.ListenAddr,
+		UserID:         t.UserID,
+		Username:       t.Username,
+		ClientIP:       t.ClientIP,
+		AssetID:        t.Asset.ID,
+		AssetName:      t.Asset.Name,
+		Target:         t.Target(),
+		ActiveChannels: len(t.channels),
+		TotalChannels:  t.totalChannels,
+		BytesSent:      atomic.LoadInt64(&t.bytesSent),
+		BytesReceived:  atomic.LoadInt64(&t.bytesReceived),
+		CreatedAt:      t.CreatedAt,
+	}
+}
+
+// IsClosed 隧道是否已关闭
+func (t *PortTunnel) IsClosed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// addChannel 登记通道的两端，隧道已关闭时返回false
+func (t *PortTunnel) addChannel(channel, target io.Closer) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return false
+	}
+	t.channels[channel] = target
+	t.totalChannels++
+	return true
+}
+
+// removeChannel 注销通道并累计字节数
+func (t *PortTunnel) removeChannel(channel io.Closer, sent, received int64) {
+	atomic.AddInt64(&t.bytesSent, sent)
+	atomic.AddInt64(&t.bytesReceived, received)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.channels, channel)
+}
+
+// close 关闭监听端口和所有通道
+func (t *PortTunnel) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+	t.closed = true
+	if t.listener != nil {
+		t.listener.Close()
+	}
+	for channel, target := range t.channels {
+		channel.Close()
+		target.Close()
+	}
+}
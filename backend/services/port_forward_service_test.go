@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newEchoListener 在回环地址上启动回显服务，作为隧道目标端口
+func newEchoListener(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// setupWebTunnel 为普通用户 alice 打开一条到回显端口的Web隧道，授权规则允许转发该端口
+func setupWebTunnel(t *testing.T) (*PortForwardService, *gorm.DB, *PortTunnel, *models.AssetPermission) {
+	t.Helper()
+	setupTestConfig(t)
+	config.GlobalConfig.PortForward.Enable = true
+	config.GlobalConfig.Audit.EnableSessionRecord = true
+	config.GlobalConfig.Audit.EnableOperationLog = true
+
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+		&models.UserGroup{}, &models.Asset{}, &models.AssetGroup{}, &models.Credential{}, &models.AssetCredential{},
+		&models.AssetPermission{}, &models.AccessPolicy{}, &models.NetworkZone{}, &models.SessionRecord{}, &models.OperationLog{})
+
+	port := newEchoListener(t)
+	asset := &models.Asset{Name: "web-01", Type: "server", Protocol: "ssh", Address: "127.0.0.1", Port: 22, Status: 1}
+	require.NoError(t, db.Create(asset).Error)
+	user := &models.User{Username: "alice", Password: "hash", Status: 1, AuthSource: models.AuthSourceLocal}
+	require.NoError(t, db.Create(user).Error)
+	permission := &models.AssetPermission{Name: "web-01-forward", Enabled: true, ForwardPorts: strconv.Itoa(port),
+		Users: []models.User{*user}, Assets: []models.Asset{*asset}}
+	require.NoError(t, db.Create(permission).Error)
+
+	service := NewPortForwardService(db, &SSHService{db: db})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tunnel := newPortTunnel(models.TunnelTypeWeb, user, "127.0.0.1", asset, port)
+	tunnel.ListenAddr = listener.Addr().String()
+	tunnel.listener = listener
+	require.NoError(t, service.registerTunnel(tunnel))
+	t.Cleanup(func() { service.CloseTunnel(tunnel.ID, "test") })
+
+	go service.acceptLoop(tunnel)
+	return service, db, tunnel, permission
+}
+
+// echoThroughTunnel 经隧道发送数据并读取回显，返回读取错误
+func echoThroughTunnel(t *testing.T, tunnel *PortTunnel) error {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", tunnel.ListenAddr, time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		return err
+	}
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	require.Equal(t, "ping", string(reply))
+	return nil
+}
+
+func TestWebTunnelRechecksPortForwardPerChannel(t *testing.T) {
+	_, db, tunnel, permission := setupWebTunnel(t)
+	require.NoError(t, echoThroughTunnel(t, tunnel))
+
+	// 授权规则不再允许转发该端口后，隧道上的新通道被拒绝
+	require.NoError(t, db.Model(permission).Update("forward_ports", "").Error)
+	require.Error(t, echoThroughTunnel(t, tunnel))
+
+	require.Eventually(t, func() bool {
+		var denied int64
+		db.Model(&models.OperationLog{}).Where("session_id = ? AND status = ?", tunnel.ID, 403).Count(&denied)
+		return denied == 1
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestCloseUserAssetTunnels(t *testing.T) {
+	service, db, tunnel, _ := setupWebTunnel(t)
+	require.NoError(t, echoThroughTunnel(t, tunnel))
+
+	// 其他用户或其他资产上的授权变化不影响隧道
+	require.Zero(t, service.CloseUserAssetTunnels(tunnel.UserID+1, nil, "临时访问授权已到期"))
+	require.Zero(t, service.CloseUserAssetTunnels(tunnel.UserID, []uint{tunnel.Asset.ID + 1}, "临时访问授权已到期"))
+	require.Equal(t, 1, service.CloseUserAssetTunnels(tunnel.UserID, []uint{tunnel.Asset.ID}, "临时访问授权已到期"))
+
+	require.True(t, tunnel.IsClosed())
+	require.Error(t, echoThroughTunnel(t, tunnel))
+	var session models.SessionRecord
+	require.NoError(t, db.Where("session_id = ?", tunnel.ID).First(&session).Error)
+	require.Equal(t, "closed", session.Status)
+	require.Equal(t, "临时访问授权已到期", session.CloseReason)
+}
+
+func TestRevokeGrantClosesTunnelsThroughSessionManager(t *testing.T) {
+	service, db, tunnel, permission := setupWebTunnel(t)
+	GlobalPortForwardService = service
+	GlobalServiceRegistry.RegisterSessionManager(NewSessionManagerAdapter(nil))
+	t.Cleanup(func() {
+		GlobalPortForwardService = nil
+		GlobalServiceRegistry.RegisterSessionManager(nil)
+	})
+	require.NoError(t, echoThroughTunnel(t, tunnel))
+
+	// 到期的临时授权是该资产上唯一的授权规则，收回后隧道被关闭
+	request := &models.AccessRequest{UserID: tunnel.UserID, AssetID: &tunnel.Asset.ID, PermissionID: &permission.ID}
+	NewAccessRequestService(db).revokeGrant(request, "临时访问授权已到期")
+
+	require.True(t, tunnel.IsClosed())
+	var session models.SessionRecord
+	require.NoError(t, db.Where("session_id = ?", tunnel.ID).First(&session).Error)
+	require.Equal(t, "临时访问授权已到期", session.CloseReason)
+}
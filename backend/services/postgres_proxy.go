@@ -0,0 +1,879 @@
+package services
+
+import (
+	"bastion/models"
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PostgreSQL前后端协议常量
+const (
+	pgProtocolVersion3  = 196608 // 3.0
+	pgSSLRequestCode    = 80877103
+	pgGSSENCRequestCode = 80877104
+	pgCancelRequestCode = 80877102
+
+	pgMaxStartupSize = 10000
+	pgMaxMessageSize = 1 << 30
+
+	pgAuthOK                = 0
+	pgAuthCleartextPassword = 3
+	pgAuthMD5Password       = 5
+	pgAuthSASL              = 10
+	pgAuthSASLContinue      = 11
+	pgAuthSASLFinal         = 12
+
+	pgScramSHA256 = "SCRAM-SHA-256"
+
+	// pgHandshakeTimeout 代理启动握手与登录数据库的超时时间
+	pgHandshakeTimeout = 30 * time.Second
+)
+
+// pgSyncMessage 扩展查询协议的Sync消息
+var pgSyncMessage = []byte{'S', 0, 0, 0, 4}
+
+// PostgreSQLError 数据库返回的ErrorResponse
+type PostgreSQLError struct {
+	Severity string
+	Code     string
+	Message  string
+}
+
+// Error 实现error接口
+func (e *PostgreSQLError) Error() string {
+	return fmt.Sprintf("postgresql %s %s: %s", e.Severity, e.Code, e.Message)
+}
+
+// pgConn 按PostgreSQL消息格式读写的连接
+type pgConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex // 串行化写入
+}
+
+// pgParameter 启动消息中的参数，保持客户端发送的顺序
+type pgParameter struct {
+	Name  string
+	Value string
+}
+
+// pgPendingStatement 已发送到数据库、等待响应的语句或同步点
+type pgPendingStatement struct {
+	entry     *models.SQLAuditLog // 为nil时表示同步点（Sync或简单查询结束），对应数据库的ReadyForQuery
+	rejection []byte              // 同步点之前返回给客户端的ErrorResponse（语句被过滤规则拒绝）
+}
+
+// pgPreparedStatement 扩展查询协议中已解析的语句，执行时按解析时的检查结果写入审计日志
+type pgPreparedStatement struct {
+	query  string
+	result *SQLFilterResult
+}
+
+func newPGConn(conn net.Conn) *pgConn {
+	return &pgConn{conn: conn, reader: bufio.NewReaderSize(conn, 16*1024)}
+}
+
+// readStartup 读取不带类型字节的启动类消息（StartupMessage、SSLRequest、CancelRequest），返回长度之后的内容
+func (c *pgConn) readStartup() ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return nil, err
+	}
+	length := int(binary.BigEndian.Uint32(header))
+	if length < 8 || length > pgMaxStartupSize {
+		return nil, fmt.Errorf("invalid startup packet length %d", length)
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// readMessage 读取一条消息，返回类型与包含类型和长度的原始字节，消息内容为 raw[5:]
+func (c *pgConn) readMessage() (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > pgMaxMessageSize {
+		return 0, nil, fmt.Errorf("invalid message length %d", length)
+	}
+	raw := make([]byte, 1+length)
+	copy(raw, header)
+	if _, err := io.ReadFull(c.reader, raw[5:]); err != nil {
+		return 0, nil, err
+	}
+	return raw[0], raw, nil
+}
+
+// writeRaw 写入已编码的消息
+func (c *pgConn) writeRaw(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// writeMessage 编码并写入一条消息
+func (c *pgConn) writeMessage(typ byte, body []byte) error {
+	return c.writeRaw(pgMessage(typ, body))
+}
+
+// writeFatal 向客户端返回FATAL级别的错误，之后连接将被关闭
+func (c *pgConn) writeFatal(code, message string) error {
+	return c.writeRaw(pgErrorMessage("FATAL", code, message))
+}
+
+// servePostgreSQL 处理一个PostgreSQL客户端连接。
+// 代理不支持SSL，客户端以堡垒机用户名和令牌（MD5口令认证）登录；
+// 认证通过后代理复核授权并以凭证登录数据库，之后转发消息并逐条审计语句。
+func (s *DatabaseProxyService) servePostgreSQL(conn net.Conn) {
+	client := newPGConn(conn)
+	clientIP := remoteIP(conn.RemoteAddr())
+	conn.SetDeadline(time.Now().Add(pgHandshakeTimeout))
+
+	var params []pgParameter
+	for params == nil {
+		body, err := client.readStartup()
+		if err != nil {
+			conn.Close()
+			return
+		}
+		code := binary.BigEndian.Uint32(body)
+		switch {
+		case code == pgSSLRequestCode || code == pgGSSENCRequestCode:
+			// 代理不支持加密连接，客户端按自身配置改用明文连接或放弃
+			if err := client.writeRaw([]byte{'N'}); err != nil {
+				conn.Close()
+				return
+			}
+		case code == pgCancelRequestCode:
+			s.cancelPostgreSQLQuery(body)
+			conn.Close()
+			return
+		case code>>16 == 3:
+			if params, err = parsePGStartupParameters(body[4:]); err != nil {
+				client.writeFatal("08P01", "invalid startup packet: "+err.Error())
+				conn.Close()
+				return
+			}
+			// 只支持协议3.0，客户端请求更高的小版本或协议扩展选项时告知服务端支持的版本
+			var unsupported []string
+			supported := params[:0]
+			for _, param := range params {
+				if strings.HasPrefix(param.Name, "_pq_.") {
+					unsupported = append(unsupported, param.Name)
+				} else {
+					supported = append(supported, param)
+				}
+			}
+			params = supported
+			if code != pgProtocolVersion3 || len(unsupported) > 0 {
+				if err := client.writeMessage('v', pgNegotiateProtocolVersion(unsupported)); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		default:
+			client.writeFatal("0A000", fmt.Sprintf("unsupported frontend protocol %d.%d", code>>16, code&0xffff))
+			conn.Close()
+			return
+		}
+	}
+
+	username := pgParameterValue(params, "user")
+	if username == "" {
+		client.writeFatal("28000", "no PostgreSQL user name specified in startup packet")
+		conn.Close()
+		return
+	}
+	if replication := strings.ToLower(pgParameterValue(params, "replication")); replication != "" && replication != "false" && replication != "off" && replication != "no" && replication != "0" {
+		client.writeFatal("0A000", "replication connections are not supported by the bastion proxy")
+		conn.Close()
+		return
+	}
+
+	salt := make([]byte, 4)
+	if _, err := rand.Read(salt); err != nil {
+		conn.Close()
+		return
+	}
+	if err := client.writeMessage('R', append([]byte{0, 0, 0, pgAuthMD5Password}, salt...)); err != nil {
+		conn.Close()
+		return
+	}
+	typ, raw, err := client.readMessage()
+	if err != nil || typ != 'p' {
+		conn.Close()
+		return
+	}
+	response := string(bytes.TrimRight(raw[5:], "\x00"))
+
+	token := s.findToken(models.DatabaseProtocolPostgreSQL, username, func(token string) bool {
+		return subtle.ConstantTimeCompare([]byte(pgMD5Password(username, token, salt)), []byte(response)) == 1
+	})
+	if token == nil {
+		s.recordLogin(0, username, clientIP, models.DatabaseProtocolPostgreSQL, "failed", "invalid or expired database proxy token")
+		client.writeFatal("28P01", fmt.Sprintf("password authentication failed for user \"%s\" (invalid or expired token)", username))
+		conn.Close()
+		return
+	}
+
+	user, asset, credential, err := s.authorize(token.UserID, token.AssetID, token.CredentialID, clientIP)
+	if err != nil {
+		s.recordLogin(token.UserID, token.Username, clientIP, models.DatabaseProtocolPostgreSQL, "failed", err.Error())
+		client.writeFatal("28000", "access denied: "+gatewaySessionErrorMessage(err))
+		conn.Close()
+		return
+	}
+	password, err := s.credentialPassword(credential)
+	if err != nil {
+		logrus.WithError(err).WithField("credential_id", credential.ID).Error("数据库代理解密凭证失败")
+		client.writeFatal("28000", "access denied: failed to load database credential")
+		conn.Close()
+		return
+	}
+
+	// libpq等客户端未指定数据库时以用户名作为数据库名，此时改用数据库账号的默认数据库
+	database := pgParameterValue(params, "database")
+	if database == "" || database == username {
+		database = credential.Username
+	}
+	var forwarded []pgParameter
+	for _, param := range params {
+		if param.Name != "user" && param.Name != "database" && param.Name != "replication" {
+			forwarded = append(forwarded, param)
+		}
+	}
+
+	address := net.JoinHostPort(asset.Address, strconv.Itoa(asset.Port))
+	upstreamConn, chain, err := s.gateways.Dial(asset, address, s.dialTimeout())
+	if err != nil {
+		logrus.WithError(err).WithField("asset_id", asset.ID).Warn("数据库代理连接数据库失败")
+		client.writeFatal("08006", fmt.Sprintf("could not connect to database server %s", asset.Name))
+		conn.Close()
+		return
+	}
+	upstreamConn.SetDeadline(time.Now().Add(pgHandshakeTimeout))
+	upstream, err := pgHandshake(upstreamConn, pgUpstreamOptions{
+		Username:   credential.Username,
+		Password:   password,
+		Database:   database,
+		Parameters: forwarded,
+	})
+	if err != nil {
+		upstreamConn.Close()
+		var pgErr *PostgreSQLError
+		if errors.As(err, &pgErr) {
+			client.writeFatal(pgErr.Code, pgErr.Message)
+		} else {
+			client.writeFatal("28000", "access denied: failed to login to database server")
+		}
+		s.recordLogin(user.ID, user.Username, clientIP, models.DatabaseProtocolPostgreSQL, "failed", err.Error())
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	upstreamConn.SetDeadline(time.Time{})
+
+	session := newDatabaseSession(models.DatabaseProtocolPostgreSQL, user, asset, credential, clientIP, conn, upstreamConn)
+	session.backendKey = upstream.BackendKey
+	session.cancelKey = make([]byte, 8)
+	if _, err := rand.Read(session.cancelKey); err != nil {
+		session.close("创建会话失败")
+		return
+	}
+	if err := s.openSession(session, chain); err != nil {
+		logrus.WithError(err).Error("数据库代理创建会话失败")
+		client.writeFatal("XX000", "failed to create bastion session")
+		session.close("创建会话失败")
+		return
+	}
+	s.recordLogin(user.ID, user.Username, clientIP, models.DatabaseProtocolPostgreSQL, "success",
+		fmt.Sprintf("Database proxy login successful (%s@%s)", credential.Username, asset.Name))
+
+	// 认证成功后转发数据库的参数状态，取消请求使用代理分配的密钥
+	startup := pgMessage('R', []byte{0, 0, 0, pgAuthOK})
+	for _, message := range upstream.Messages {
+		startup = append(startup, message...)
+	}
+	startup = append(startup, pgMessage('K', session.cancelKey)...)
+	startup = append(startup, pgMessage('Z', []byte{upstream.TxStatus})...)
+	if err := client.writeRaw(startup); err == nil {
+		s.relayPostgreSQL(session, client, upstream.conn, database)
+	}
+	s.finishSession(session)
+}
+
+// relayPostgreSQL 在客户端与数据库之间转发消息。
+// 简单查询按语句拆分审计，扩展查询在Parse时检查、在Execute时审计；数据库按顺序响应，
+// 每个 CommandComplete 对应队首的语句，ErrorResponse 之后直到同步点的语句都不会执行。
+// 被拒绝的语句不发送给数据库，改为发送Sync，在数据库返回ReadyForQuery之前把错误返回给客户端，保证响应顺序。
+// FunctionCall 按OID直接调用函数，不经过SQL过滤规则，一律按同样方式拒绝。
+func (s *DatabaseProxyService) relayPostgreSQL(session *databaseSession, client, upstream *pgConn, database string) {
+	var pendingMu sync.Mutex
+	var pending []*pgPendingStatement
+
+	serverDone := make(chan struct{})
+	go func() {
+		defer close(serverDone)
+		for {
+			typ, raw, err := upstream.readMessage()
+			if err != nil {
+				session.close("数据库断开连接")
+				return
+			}
+
+			var finished []*pgPendingStatement
+			var rejection []byte
+			pendingMu.Lock()
+			switch typ {
+			case 'C', 'I', 's': // CommandComplete、EmptyQueryResponse、PortalSuspended
+				if len(pending) > 0 && pending[0].entry != nil {
+					finished, pending = pending[:1], pending[1:]
+					if typ == 'C' {
+						finished[0].entry.AffectedRows = pgCommandRows(raw[5:])
+					}
+				}
+			case 'E':
+				pgErr := parsePGError(raw[5:])
+				for i := 0; len(pending) > 0 && pending[0].entry != nil; i++ {
+					entry := pending[0].entry
+					entry.Status = models.SQLAuditStatusError
+					entry.Message = "skipped after previous error"
+					if i == 0 {
+						entry.Message = fmt.Sprintf("%s %s: %s", pgErr.Severity, pgErr.Code, pgErr.Message)
+					}
+					finished, pending = append(finished, pending[0]), pending[1:]
+				}
+			case 'Z':
+				for len(pending) > 0 {
+					statement := pending[0]
+					pending = pending[1:]
+					if statement.entry == nil {
+						rejection = statement.rejection
+						break
+					}
+					finished = append(finished, statement)
+				}
+			}
+			pendingMu.Unlock()
+
+			for _, statement := range finished {
+				s.saveAuditEntry(statement.entry)
+			}
+			if rejection != nil {
+				if err := client.writeRaw(rejection); err != nil {
+					session.close("客户端断开连接")
+					return
+				}
+			}
+			if err := client.writeRaw(raw); err != nil {
+				session.close("客户端断开连接")
+				return
+			}
+		}
+	}()
+
+	enqueue := func(statements ...*pgPendingStatement) {
+		pendingMu.Lock()
+		pending = append(pending, statements...)
+		pendingMu.Unlock()
+	}
+	deny := func(entry *models.SQLAuditLog, result *SQLFilterResult) []byte {
+		entry.Status = models.SQLAuditStatusDenied
+		entry.Message = "denied by sql filter rule"
+		s.saveAuditEntry(entry)
+		return pgErrorMessage("ERROR", "42501",
+			fmt.Sprintf("permission denied: statement blocked by bastion SQL filter rule \"%s\"", result.Rule.Name))
+	}
+
+	prepared := make(map[string]*pgPreparedStatement)
+	portals := make(map[string]*pgPreparedStatement)
+	var rejection []byte // 扩展查询中语句被拒绝后丢弃消息直到Sync
+	for {
+		typ, raw, err := client.readMessage()
+		if err != nil || typ == 'X' {
+			break
+		}
+		body := raw[5:]
+
+		if rejection != nil && typ != 'S' {
+			continue
+		}
+		switch typ {
+		case 'Q':
+			query := pgCString(body)
+			var statements []*pgPendingStatement
+			var denied []byte
+			for _, stmt := range splitSQLStatements(session.Protocol, query) {
+				result := s.checkStatement(session, stmt.Text)
+				entry := s.newAuditEntry(session, database, stmt.Text, result)
+				if result.Action == models.FilterActionDeny && denied == nil {
+					denied = deny(entry, result)
+					continue
+				}
+				statements = append(statements, &pgPendingStatement{entry: entry})
+			}
+			if denied != nil {
+				// 整个查询不执行，同一查询中的其他语句也记为拒绝
+				for _, statement := range statements {
+					statement.entry.Status = models.SQLAuditStatusDenied
+					statement.entry.Message = "another statement in the query was denied"
+					s.saveAuditEntry(statement.entry)
+				}
+				enqueue(&pgPendingStatement{rejection: denied})
+				raw = pgSyncMessage
+				break
+			}
+			enqueue(append(statements, &pgPendingStatement{})...)
+		case 'P':
+			name, rest := pgSplitCString(body)
+			query := pgCString(rest)
+			result := s.checkStatement(session, query)
+			if result.Action == models.FilterActionDeny {
+				rejection = deny(s.newAuditEntry(session, database, query, result), result)
+				continue
+			}
+			prepared[name] = &pgPreparedStatement{query: query, result: result}
+		case 'B':
+			portal, rest := pgSplitCString(body)
+			if statement, ok := prepared[pgCString(rest)]; ok {
+				portals[portal] = statement
+			} else {
+				delete(portals, portal)
+			}
+		case 'E':
+			if statement, ok := portals[pgCString(body)]; ok {
+				enqueue(&pgPendingStatement{entry: s.newAuditEntry(session, database, statement.query, statement.result)})
+			}
+		case 'C': // Close
+			if len(body) > 0 {
+				if body[0] == 'S' {
+					delete(prepared, pgCString(body[1:]))
+				} else {
+					delete(portals, pgCString(body[1:]))
+				}
+			}
+		case 'S':
+			enqueue(&pgPendingStatement{rejection: rejection})
+			rejection = nil
+		case 'F':
+			entry := s.newAuditEntry(session, database, pgFunctionCallStatement(body),
+				&SQLFilterResult{Action: models.FilterActionDeny, StatementType: "FUNCTION CALL"})
+			entry.Status = models.SQLAuditStatusDenied
+			entry.Message = "function call protocol is not allowed"
+			s.saveAuditEntry(entry)
+			enqueue(&pgPendingStatement{rejection: pgErrorMessage("ERROR", "0A000",
+				"permission denied: function call protocol is not supported by the bastion proxy")})
+			raw = pgSyncMessage
+		}
+
+		if err := upstream.writeRaw(raw); err != nil {
+			break
+		}
+	}
+
+	session.close("客户端断开连接")
+	<-serverDone
+
+	// 连接断开时仍未收到响应的语句
+	for _, statement := range pending {
+		if statement.entry == nil {
+			continue
+		}
+		statement.entry.Status = models.SQLAuditStatusError
+		statement.entry.Message = "connection closed before response"
+		s.saveAuditEntry(statement.entry)
+	}
+}
+
+// cancelPostgreSQLQuery 把客户端的取消请求转发给代理会话对应的数据库连接
+func (s *DatabaseProxyService) cancelPostgreSQLQuery(body []byte) {
+	if len(body) != 12 {
+		return
+	}
+	key := body[4:]
+
+	var target *databaseSession
+	s.mu.Lock()
+	for _, session := range s.sessions {
+		if session.Protocol == models.DatabaseProtocolPostgreSQL && len(session.backendKey) == 8 &&
+			subtle.ConstantTimeCompare(session.cancelKey, key) == 1 {
+			target = session
+			break
+		}
+	}
+	s.mu.Unlock()
+	if target == nil {
+		return
+	}
+
+	address := net.JoinHostPort(target.Asset.Address, strconv.Itoa(target.Asset.Port))
+	conn, _, err := s.gateways.Dial(target.Asset, address, s.dialTimeout())
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", target.ID).Warn("转发PostgreSQL取消请求失败")
+		return
+	}
+	defer conn.Close()
+
+	request := make([]byte, 8, 16)
+	binary.BigEndian.PutUint32(request, 16)
+	binary.BigEndian.PutUint32(request[4:], pgCancelRequestCode)
+	request = append(request, target.backendKey...)
+	conn.SetDeadline(time.Now().Add(s.dialTimeout()))
+	conn.Write(request)
+	// 数据库处理完取消请求后关闭连接
+	io.Copy(io.Discard, conn)
+}
+
+// pgUpstreamOptions 登录数据库的参数
+type pgUpstreamOptions struct {
+	Username   string
+	Password   string
+	Database   string
+	Parameters []pgParameter // 转发的其他启动参数，如 application_name、client_encoding
+}
+
+// pgUpstream 已认证的数据库连接
+type pgUpstream struct {
+	conn          *pgConn
+	Messages      [][]byte // 认证成功后数据库发送的ParameterStatus、NoticeResponse，转发给客户端
+	BackendKey    []byte   // BackendKeyData中的进程ID与密钥，用于取消请求
+	ServerVersion string
+	TxStatus      byte
+}
+
+// pgHandshake 以客户端身份登录真实数据库，支持明文口令、MD5与SCRAM-SHA-256认证，
+// 读取到第一个ReadyForQuery时返回
+func pgHandshake(conn net.Conn, opts pgUpstreamOptions) (*pgUpstream, error) {
+	c := newPGConn(conn)
+
+	startup := make([]byte, 8)
+	binary.BigEndian.PutUint32(startup[4:], pgProtocolVersion3)
+	params := append([]pgParameter{{Name: "user", Value: opts.Username}}, opts.Parameters...)
+	if opts.Database != "" {
+		params = append(params, pgParameter{Name: "database", Value: opts.Database})
+	}
+	for _, param := range params {
+		startup = append(startup, param.Name...)
+		startup = append(startup, 0)
+		startup = append(startup, param.Value...)
+		startup = append(startup, 0)
+	}
+	startup = append(startup, 0)
+	binary.BigEndian.PutUint32(startup, uint32(len(startup)))
+	if err := c.writeRaw(startup); err != nil {
+		return nil, fmt.Errorf("failed to send startup message: %w", err)
+	}
+
+	upstream := &pgUpstream{conn: c}
+	var scram *pgScramClient
+	for {
+		typ, raw, err := c.readMessage()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read server message: %w", err)
+		}
+		body := raw[5:]
+
+		switch typ {
+		case 'R':
+			if len(body) < 4 {
+				return nil, errors.New("malformed authentication request")
+			}
+			switch method := binary.BigEndian.Uint32(body); method {
+			case pgAuthOK:
+			case pgAuthCleartextPassword:
+				err = c.writeMessage('p', append([]byte(opts.Password), 0))
+			case pgAuthMD5Password:
+				if len(body) < 8 {
+					return nil, errors.New("malformed md5 authentication request")
+				}
+				err = c.writeMessage('p', append([]byte(pgMD5Password(opts.Username, opts.Password, body[4:8])), 0))
+			case pgAuthSASL:
+				mechanisms := strings.Split(strings.TrimRight(string(body[4:]), "\x00"), "\x00")
+				if !containsString(mechanisms, pgScramSHA256) {
+					return nil, fmt.Errorf("unsupported SASL mechanisms: %s", strings.Join(mechanisms, ", "))
+				}
+				if scram, err = newPGScramClient(opts.Password); err != nil {
+					return nil, err
+				}
+				first := scram.clientFirst()
+				message := append([]byte(pgScramSHA256), 0, 0, 0, 0, 0)
+				binary.BigEndian.PutUint32(message[len(pgScramSHA256)+1:], uint32(len(first)))
+				err = c.writeMessage('p', append(message, first...))
+			case pgAuthSASLContinue:
+				if scram == nil {
+					return nil, errors.New("unexpected SASL continue message")
+				}
+				final, scramErr := scram.clientFinal(string(body[4:]))
+				if scramErr != nil {
+					return nil, scramErr
+				}
+				err = c.writeMessage('p', []byte(final))
+			case pgAuthSASLFinal:
+				if scram == nil {
+					return nil, errors.New("unexpected SASL final message")
+				}
+				if err := scram.verifyServerFinal(string(body[4:])); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("unsupported authentication method %d", method)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to send authentication response: %w", err)
+			}
+		case 'E':
+			return nil, parsePGError(body)
+		case 'S':
+			if name, rest := pgSplitCString(body); name == "server_version" {
+				upstream.ServerVersion = pgCString(rest)
+			}
+			upstream.Messages = append(upstream.Messages, raw)
+		case 'N':
+			upstream.Messages = append(upstream.Messages, raw)
+		case 'K':
+			upstream.BackendKey = append([]byte(nil), body...)
+		case 'v':
+			// 数据库不支持请求的协议扩展，代理本身只使用3.0
+		case 'Z':
+			if len(body) > 0 {
+				upstream.TxStatus = body[0]
+			}
+			return upstream, nil
+		default:
+			return nil, fmt.Errorf("unexpected message %q during startup", typ)
+		}
+	}
+}
+
+// pgScramClient SCRAM-SHA-256客户端（不使用通道绑定）
+type pgScramClient struct {
+	password        string
+	nonce           string
+	clientFirstBare string
+	authMessage     string
+	saltedPassword  []byte
+}
+
+func newPGScramClient(password string) (*pgScramClient, error) {
+	raw := make([]byte, 18)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate SCRAM nonce: %w", err)
+	}
+	nonce := base64.StdEncoding.EncodeToString(raw)
+	// PostgreSQL使用启动消息中的用户名，SCRAM消息中的用户名留空
+	return &pgScramClient{password: password, nonce: nonce, clientFirstBare: "n=,r=" + nonce}, nil
+}
+
+// clientFirst 客户端首条消息，gs2头 n,, 表示不支持通道绑定
+func (c *pgScramClient) clientFirst() string {
+	return "n,," + c.clientFirstBare
+}
+
+// clientFinal 根据服务端首条消息计算客户端证明
+func (c *pgScramClient) clientFinal(serverFirst string) (string, error) {
+	attrs := parseSCRAMAttributes(serverFirst)
+	nonce, salt64, iterations := attrs["r"], attrs["s"], attrs["i"]
+	if !strings.HasPrefix(nonce, c.nonce) || len(nonce) == len(c.nonce) {
+		return "", errors.New("invalid SCRAM server nonce")
+	}
+	salt, err := base64.StdEncoding.DecodeString(salt64)
+	if err != nil {
+		return "", errors.New("invalid SCRAM salt")
+	}
+	iter, err := strconv.Atoi(iterations)
+	if err != nil || iter <= 0 {
+		return "", errors.New("invalid SCRAM iteration count")
+	}
+
+	c.saltedPassword = pbkdf2.Key([]byte(c.password), salt, iter, sha256.Size, sha256.New)
+	withoutProof := "c=biws,r=" + nonce
+	c.authMessage = c.clientFirstBare + "," + serverFirst + "," + withoutProof
+
+	clientKey := hmacSHA256(c.saltedPassword, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	signature := hmacSHA256(storedKey[:], c.authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ signature[i]
+	}
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof), nil
+}
+
+// verifyServerFinal 校验服务端签名，确认对端确实持有口令
+func (c *pgScramClient) verifyServerFinal(serverFinal string) error {
+	attrs := parseSCRAMAttributes(serverFinal)
+	if e, ok := attrs["e"]; ok {
+		return fmt.Errorf("SCRAM authentication failed: %s", e)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil {
+		return errors.New("invalid SCRAM server signature")
+	}
+	serverKey := hmacSHA256(c.saltedPassword, "Server Key")
+	if !hmac.Equal(signature, hmacSHA256(serverKey, c.authMessage)) {
+		return errors.New("SCRAM server signature mismatch")
+	}
+	return nil
+}
+
+// parseSCRAMAttributes 解析逗号分隔的 key=value 属性
+func parseSCRAMAttributes(message string) map[string]string {
+	attrs := make(map[string]string)
+	for _, part := range strings.Split(message, ",") {
+		if len(part) >= 2 && part[1] == '=' {
+			attrs[part[:1]] = part[2:]
+		}
+	}
+	return attrs
+}
+
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// pgMD5Password 计算MD5口令认证的响应：md5(md5(password + user) + salt)
+func pgMD5Password(username, password string, salt []byte) string {
+	inner := md5.Sum([]byte(password + username))
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt...))
+	return "md5" + hex.EncodeToString(outer[:])
+}
+
+// parsePGStartupParameters 解析启动消息中以空字符分隔的参数对
+func parsePGStartupParameters(data []byte) ([]pgParameter, error) {
+	params := []pgParameter{}
+	for len(data) > 0 && data[0] != 0 {
+		name, rest := pgSplitCString(data)
+		if len(rest) == 0 {
+			return nil, fmt.Errorf("missing value for parameter %q", name)
+		}
+		value, rest := pgSplitCString(rest)
+		params = append(params, pgParameter{Name: name, Value: value})
+		data = rest
+	}
+	return params, nil
+}
+
+// pgParameterValue 查找启动参数
+func pgParameterValue(params []pgParameter, name string) string {
+	for _, param := range params {
+		if param.Name == name {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// pgNegotiateProtocolVersion 编码NegotiateProtocolVersion：支持的最高小版本为0，并列出不支持的选项
+func pgNegotiateProtocolVersion(unsupported []string) []byte {
+	body := make([]byte, 8)
+	binary.BigEndian.PutUint32(body, pgProtocolVersion3)
+	binary.BigEndian.PutUint32(body[4:], uint32(len(unsupported)))
+	for _, name := range unsupported {
+		body = append(body, name...)
+		body = append(body, 0)
+	}
+	return body
+}
+
+// parsePGError 解析ErrorResponse中的严重级别、错误码与消息
+func parsePGError(body []byte) *PostgreSQLError {
+	pgErr := &PostgreSQLError{}
+	for len(body) > 0 && body[0] != 0 {
+		field := body[0]
+		var value string
+		value, body = pgSplitCString(body[1:])
+		switch field {
+		case 'S':
+			pgErr.Severity = value
+		case 'C':
+			pgErr.Code = value
+		case 'M':
+			pgErr.Message = value
+		}
+	}
+	return pgErr
+}
+
+// pgErrorMessage 编码ErrorResponse消息
+func pgErrorMessage(severity, code, message string) []byte {
+	var body []byte
+	for _, field := range []struct {
+		typ   byte
+		value string
+	}{{'S', severity}, {'V', severity}, {'C', code}, {'M', message}} {
+		body = append(body, field.typ)
+		body = append(body, field.value...)
+		body = append(body, 0)
+	}
+	return pgMessage('E', append(body, 0))
+}
+
+// pgMessage 编码一条带类型字节的消息
+func pgMessage(typ byte, body []byte) []byte {
+	message := make([]byte, 5, 5+len(body))
+	message[0] = typ
+	binary.BigEndian.PutUint32(message[1:], uint32(4+len(body)))
+	return append(message, body...)
+}
+
+// pgFunctionCallStatement 描述FunctionCall调用的函数，用于审计日志
+func pgFunctionCallStatement(body []byte) string {
+	if len(body) < 4 {
+		return "function call"
+	}
+	return fmt.Sprintf("function call (oid %d)", binary.BigEndian.Uint32(body))
+}
+
+// pgCommandRows 从CommandComplete的命令标签（如 "UPDATE 3"、"INSERT 0 1"）中取出影响行数
+func pgCommandRows(body []byte) int64 {
+	fields := strings.Fields(pgCString(body))
+	if len(fields) < 2 {
+		return 0
+	}
+	rows, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return rows
+}
+
+// pgSplitCString 拆分出开头以空字符结尾的字符串与剩余内容
+func pgSplitCString(data []byte) (string, []byte) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return string(data), nil
+	}
+	return string(data[:end]), data[end+1:]
+}
+
+// pgCString 读取开头以空字符结尾的字符串
+func pgCString(data []byte) string {
+	value, _ := pgSplitCString(data)
+	return value
+}
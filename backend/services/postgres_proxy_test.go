@@ -0,0 +1,293 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const (
+	testPGAccount  = "app_rw"
+	testPGPassword = "Upstream-Pass-4nW9"
+)
+
+// testPostgreSQLServer 模拟PostgreSQL服务器：明文口令认证，简单查询的每条语句和扩展查询的每次执行返回 "UPDATE 1"，
+// FunctionCall 返回空结果；记录收到的简单查询、Parse、Sync 与 FunctionCall 消息
+type testPostgreSQLServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []string
+}
+
+// newTestPostgreSQLServer 在回环地址上启动模拟服务器
+func newTestPostgreSQLServer(t *testing.T) *testPostgreSQLServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &testPostgreSQLServer{listener: listener}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+// port 监听端口
+func (s *testPostgreSQLServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// received 服务器收到的消息，如 "Q UPDATE ..."、"P UPDATE ..."、"S"、"F"
+func (s *testPostgreSQLServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func (s *testPostgreSQLServer) record(message string) {
+	s.mu.Lock()
+	s.messages = append(s.messages, message)
+	s.mu.Unlock()
+}
+
+func (s *testPostgreSQLServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testPostgreSQLServer) handle(conn net.Conn) {
+	defer conn.Close()
+	c := newPGConn(conn)
+
+	body, err := c.readStartup()
+	if err != nil {
+		return
+	}
+	params, err := parsePGStartupParameters(body[4:])
+	if err != nil || pgParameterValue(params, "user") != testPGAccount {
+		c.writeFatal("28000", "role does not exist")
+		return
+	}
+	if err := c.writeMessage('R', []byte{0, 0, 0, pgAuthCleartextPassword}); err != nil {
+		return
+	}
+	typ, raw, err := c.readMessage()
+	if err != nil || typ != 'p' || pgCString(raw[5:]) != testPGPassword {
+		c.writeFatal("28P01", "password authentication failed")
+		return
+	}
+	startup := pgMessage('R', []byte{0, 0, 0, pgAuthOK})
+	startup = append(startup, pgMessage('S', []byte("server_version\x0016.0\x00"))...)
+	startup = append(startup, pgMessage('K', []byte{0, 0, 0, 42, 1, 2, 3, 4})...)
+	startup = append(startup, pgMessage('Z', []byte{'I'})...)
+	if err := c.writeRaw(startup); err != nil {
+		return
+	}
+
+	ready := pgMessage('Z', []byte{'I'})
+	for {
+		typ, raw, err := c.readMessage()
+		if err != nil || typ == 'X' {
+			return
+		}
+		body := raw[5:]
+		switch typ {
+		case 'Q':
+			query := pgCString(body)
+			s.record("Q " + query)
+			var response []byte
+			for _, statement := range strings.Split(query, ";") {
+				if strings.TrimSpace(statement) != "" {
+					response = append(response, pgMessage('C', []byte("UPDATE 1\x00"))...)
+				}
+			}
+			c.writeRaw(append(response, ready...))
+		case 'P':
+			_, rest := pgSplitCString(body)
+			s.record("P " + pgCString(rest))
+			c.writeMessage('1', nil)
+		case 'B':
+			c.writeMessage('2', nil)
+		case 'E':
+			c.writeMessage('C', []byte("UPDATE 1\x00"))
+		case 'S':
+			s.record("S")
+			c.writeRaw(ready)
+		case 'F':
+			s.record("F")
+			c.writeRaw(append(pgMessage('V', []byte{0xff, 0xff, 0xff, 0xff}), ready...))
+		}
+	}
+}
+
+// setupPostgreSQLProxy 启动代理与模拟数据库，创建禁止 DROP 的过滤规则，返回代理服务、已登录的客户端连接、数据库与模拟服务器
+func setupPostgreSQLProxy(t *testing.T) (*DatabaseProxyService, *pgConn, *gorm.DB, *testPostgreSQLServer) {
+	t.Helper()
+	setupTestConfig(t)
+	config.GlobalConfig.DatabaseProxy = config.DatabaseProxyConfig{Enable: true, Host: "127.0.0.1", PostgreSQLPort: 5433, DialTimeout: 2}
+	config.GlobalConfig.Audit.EnableSessionRecord = true
+
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+		&models.UserGroup{}, &models.Asset{}, &models.AssetGroup{}, &models.Credential{}, &models.AssetCredential{},
+		&models.AssetPermission{}, &models.AccessPolicy{}, &models.NetworkZone{}, &models.SessionRecord{}, &models.LoginLog{},
+		&models.SQLFilterRule{}, &models.SQLAuditLog{})
+
+	server := newTestPostgreSQLServer(t)
+	encrypted, err := utils.EncryptPassword(testPGPassword)
+	require.NoError(t, err)
+	credential := &models.Credential{Name: "app", Type: utils.CredentialTypePassword, Username: testPGAccount, Password: encrypted}
+	require.NoError(t, db.Create(credential).Error)
+	asset := &models.Asset{Name: "orders-pg", Type: "database", Protocol: models.DatabaseProtocolPostgreSQL,
+		Address: "127.0.0.1", Port: server.port(), Status: 1, Credentials: []models.Credential{*credential}}
+	require.NoError(t, db.Create(asset).Error)
+
+	role := models.Role{Name: "admin"}
+	require.NoError(t, db.Create(&role).Error)
+	user := &models.User{Username: "alice", Password: "hash", Status: 1, AuthSource: models.AuthSourceLocal, Roles: []models.Role{role}}
+	require.NoError(t, db.Create(user).Error)
+
+	proxy := NewDatabaseProxyService(db, nil, nil)
+	require.NoError(t, proxy.listen(models.DatabaseProtocolPostgreSQL, "127.0.0.1:0", proxy.servePostgreSQL))
+	t.Cleanup(func() { proxy.Stop() })
+	_, err = proxy.filters.CreateRule(&models.SQLFilterRuleCreateRequest{
+		Name: "no-drop", Priority: 1, MatchType: models.SQLMatchStatement, Pattern: "DROP", Action: models.FilterActionDeny,
+	})
+	require.NoError(t, err)
+
+	token, err := proxy.IssueToken(user.ID, "127.0.0.1", &models.DatabaseTokenRequest{AssetID: asset.ID, CredentialID: credential.ID})
+	require.NoError(t, err)
+	conn, err := net.DialTimeout("tcp", proxy.listeners[0].Addr().String(), 2*time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// 以客户端身份通过MD5口令认证登录代理
+	client, err := pgHandshake(conn, pgUpstreamOptions{Username: user.Username, Password: token.Token, Database: "appdb"})
+	require.NoError(t, err)
+	return proxy, client.conn, db, server
+}
+
+// pgParse 编码使用未命名语句的Parse消息
+func pgParse(query string) []byte {
+	return pgMessage('P', append(append([]byte{0}, query...), 0, 0, 0))
+}
+
+// pgExtendedQuery 编码一次完整的扩展查询：Parse、Bind、Execute、Sync
+func pgExtendedQuery(query string) []byte {
+	message := pgParse(query)
+	message = append(message, pgMessage('B', []byte{0, 0, 0, 0, 0, 0, 0})...)
+	message = append(message, pgMessage('E', []byte{0, 0, 0, 0, 0})...)
+	return append(message, pgSyncMessage...)
+}
+
+// readPGResponse 读取直到ReadyForQuery的响应，返回消息类型序列与其中的错误
+func readPGResponse(t *testing.T, client *pgConn) (string, []*PostgreSQLError) {
+	t.Helper()
+	var types []byte
+	var errs []*PostgreSQLError
+	for {
+		typ, raw, err := client.readMessage()
+		require.NoError(t, err)
+		types = append(types, typ)
+		if typ == 'E' {
+			errs = append(errs, parsePGError(raw[5:]))
+		}
+		if typ == 'Z' {
+			return string(types), errs
+		}
+	}
+}
+
+func TestPostgreSQLProxyRejectsDeniedExtendedQueryBeforeSync(t *testing.T) {
+	_, client, db, server := setupPostgreSQLProxy(t)
+
+	// 三次扩展查询一次性发送，被拒绝的语句之后的 Bind、Execute 被丢弃，错误在其 Sync 的 ReadyForQuery 之前返回
+	var batch []byte
+	batch = append(batch, pgExtendedQuery("UPDATE orders SET status = 2 WHERE id = 7")...)
+	batch = append(batch, pgExtendedQuery("DROP TABLE orders")...)
+	batch = append(batch, pgExtendedQuery("UPDATE orders SET status = 3 WHERE id = 7")...)
+	require.NoError(t, client.writeRaw(batch))
+
+	types, errs := readPGResponse(t, client)
+	require.Equal(t, "12CZ", types)
+	require.Empty(t, errs)
+	types, errs = readPGResponse(t, client)
+	require.Equal(t, "EZ", types)
+	require.Len(t, errs, 1)
+	require.Equal(t, "42501", errs[0].Code)
+	require.Contains(t, errs[0].Message, "no-drop")
+	types, errs = readPGResponse(t, client)
+	require.Equal(t, "12CZ", types)
+	require.Empty(t, errs)
+
+	require.Equal(t, []string{
+		"P UPDATE orders SET status = 2 WHERE id = 7", "S",
+		"S",
+		"P UPDATE orders SET status = 3 WHERE id = 7", "S",
+	}, server.received())
+
+	logs := waitSQLAuditLogs(t, db, 3)
+	require.Equal(t, models.SQLAuditStatusDenied, auditLogFor(t, logs, "DROP TABLE orders").Status)
+	require.Equal(t, models.SQLAuditStatusSuccess, auditLogFor(t, logs, "UPDATE orders SET status = 2 WHERE id = 7").Status)
+	require.Equal(t, models.SQLAuditStatusSuccess, auditLogFor(t, logs, "UPDATE orders SET status = 3 WHERE id = 7").Status)
+}
+
+func TestPostgreSQLProxyRejectsMultiStatementQueryWithDeniedStatement(t *testing.T) {
+	_, client, db, server := setupPostgreSQLProxy(t)
+
+	require.NoError(t, client.writeMessage('Q', []byte("UPDATE orders SET status = 2 WHERE id = 7; DROP TABLE orders\x00")))
+	types, errs := readPGResponse(t, client)
+	require.Equal(t, "EZ", types)
+	require.Len(t, errs, 1)
+	require.Equal(t, "42501", errs[0].Code)
+
+	// 整个查询都不发送给数据库，连接仍可继续使用
+	require.NoError(t, client.writeMessage('Q', []byte("UPDATE orders SET status = 3 WHERE id = 7\x00")))
+	types, errs = readPGResponse(t, client)
+	require.Equal(t, "CZ", types)
+	require.Empty(t, errs)
+	require.Equal(t, []string{"S", "Q UPDATE orders SET status = 3 WHERE id = 7"}, server.received())
+
+	logs := waitSQLAuditLogs(t, db, 3)
+	denied := auditLogFor(t, logs, "DROP TABLE orders")
+	require.Equal(t, models.SQLAuditStatusDenied, denied.Status)
+	require.Equal(t, "no-drop", denied.RuleName)
+	sibling := auditLogFor(t, logs, "UPDATE orders SET status = 2 WHERE id = 7")
+	require.Equal(t, models.SQLAuditStatusDenied, sibling.Status)
+	require.Equal(t, "another statement in the query was denied", sibling.Message)
+	require.Equal(t, models.SQLAuditStatusSuccess, auditLogFor(t, logs, "UPDATE orders SET status = 3 WHERE id = 7").Status)
+}
+
+func TestPostgreSQLProxyRejectsFunctionCall(t *testing.T) {
+	_, client, db, server := setupPostgreSQLProxy(t)
+
+	// FunctionCall：函数OID、参数格式数、参数个数、结果格式
+	body := make([]byte, 10)
+	binary.BigEndian.PutUint32(body, 1234)
+	require.NoError(t, client.writeMessage('F', body))
+	types, errs := readPGResponse(t, client)
+	require.Equal(t, "EZ", types)
+	require.Len(t, errs, 1)
+	require.Equal(t, "0A000", errs[0].Code)
+	require.Contains(t, errs[0].Message, "function call")
+	require.Equal(t, []string{"S"}, server.received())
+
+	log := waitSQLAuditLogs(t, db, 1)[0]
+	require.Equal(t, "function call (oid 1234)", log.Statement)
+	require.Equal(t, models.SQLAuditStatusDenied, log.Status)
+	require.Equal(t, models.FilterActionDeny, log.Action)
+}
@@ -0,0 +1,725 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redis协议（RESP）常量
+const (
+	redisMaxBulkSize   = 512 << 20 // 与Redis的 proto-max-bulk-len 默认值一致
+	redisMaxArraySize  = 1 << 20
+	redisMaxReplyDepth = 32
+
+	// redisHandshakeTimeout 客户端认证与登录数据库的超时时间
+	redisHandshakeTimeout = 30 * time.Second
+
+	// redisMaxLoggedCommand 命令日志中记录的命令最大长度
+	redisMaxLoggedCommand = 4096
+
+	redisRedactedValue = "***"
+)
+
+var redisOK = []byte("+OK\r\n")
+
+// defaultRedisRedactRules 未配置脱敏规则时使用的内置规则，隐藏写入命令中的值
+var defaultRedisRedactRules = []config.RedisRedactRule{
+	{Command: "SET", From: 2, Count: 1},
+	{Command: "SETNX", From: 2, Count: 1},
+	{Command: "SETEX", From: 3, Count: 1},
+	{Command: "PSETEX", From: 3, Count: 1},
+	{Command: "GETSET", From: 2, Count: 1},
+	{Command: "APPEND", From: 2, Count: 1},
+	{Command: "SETRANGE", From: 3, Count: 1},
+	{Command: "MSET", From: 2, Step: 2},
+	{Command: "MSETNX", From: 2, Step: 2},
+	{Command: "HSET", From: 3, Step: 2},
+	{Command: "HMSET", From: 3, Step: 2},
+	{Command: "HSETNX", From: 3, Count: 1},
+	{Command: "LPUSH", From: 2},
+	{Command: "RPUSH", From: 2},
+	{Command: "LPUSHX", From: 2},
+	{Command: "RPUSHX", From: 2},
+	{Command: "LSET", From: 3, Count: 1},
+	{Command: "LINSERT", From: 4, Count: 1},
+	{Command: "SADD", From: 2},
+	{Command: "PUBLISH", From: 2, Count: 1},
+	{Command: "CONFIG SET", From: 2, Step: 2},
+}
+
+// RedisError 数据库返回的错误回复
+type RedisError struct {
+	Message string
+}
+
+// Error 实现error接口
+func (e *RedisError) Error() string {
+	return "redis error: " + e.Message
+}
+
+// redisConn 按RESP格式读写的连接
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex // 串行化写入
+}
+
+// redisPendingCommand 已发送到数据库、等待回复的命令
+type redisPendingCommand struct {
+	text   string // 脱敏后的命令
+	action string
+	start  time.Time
+}
+
+func newRedisConn(conn net.Conn) *redisConn {
+	return &redisConn{conn: conn, reader: bufio.NewReaderSize(conn, 64*1024)}
+}
+
+// readLine 读取一行，返回包含行尾的内容。与Redis一致，内联命令允许只以LF结尾
+func (c *redisConn) readLine() ([]byte, error) {
+	line, err := c.reader.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return nil, errors.New("protocol error: line too long")
+		}
+		return nil, err
+	}
+	return append([]byte(nil), line...), nil
+}
+
+// readCommand 读取客户端的一条命令，返回参数与RESP编码的原始命令。
+// 支持RESP数组与内联命令（如 telnet 中直接输入），内联命令会重新编码为数组；空行返回空参数。
+func (c *redisConn) readCommand() ([][]byte, []byte, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, nil, err
+	}
+	if line[0] != '*' {
+		fields := strings.Fields(string(line))
+		args := make([][]byte, len(fields))
+		for i, field := range fields {
+			args[i] = []byte(field)
+		}
+		if len(args) == 0 {
+			return nil, nil, nil
+		}
+		return args, encodeRedisCommand(args), nil
+	}
+
+	count, err := parseRedisLength(line)
+	if err != nil || count > redisMaxArraySize {
+		return nil, nil, errors.New("protocol error: invalid multibulk length")
+	}
+	raw := line
+	args := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := c.readLine()
+		if err != nil {
+			return nil, nil, err
+		}
+		if header[0] != '$' {
+			return nil, nil, fmt.Errorf("protocol error: expected '$', got '%c'", header[0])
+		}
+		size, err := parseRedisLength(header)
+		if err != nil || size < 0 || size > redisMaxBulkSize {
+			return nil, nil, errors.New("protocol error: invalid bulk length")
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, nil, err
+		}
+		raw = append(append(raw, header...), data...)
+		args = append(args, data[:size])
+	}
+	return args, raw, nil
+}
+
+// readReply 读取一个完整的回复，返回原始字节
+func (c *redisConn) readReply() ([]byte, error) {
+	return c.readValue(nil, 0)
+}
+
+// readValue 递归读取一个RESP2/RESP3值并追加到 raw
+func (c *redisConn) readValue(raw []byte, depth int) ([]byte, error) {
+	if depth > redisMaxReplyDepth {
+		return nil, errors.New("protocol error: reply nested too deep")
+	}
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	raw = append(raw, line...)
+
+	switch line[0] {
+	case '+', '-', ':', '_', '#', ',', '(':
+		return raw, nil
+	case '$', '!', '=':
+		size, err := parseRedisLength(line)
+		if err != nil || size > redisMaxBulkSize {
+			return nil, errors.New("protocol error: invalid bulk length")
+		}
+		if size < 0 {
+			return raw, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return append(raw, data...), nil
+	case '*', '~', '>', '%', '|':
+		count, err := parseRedisLength(line)
+		if err != nil || count > redisMaxArraySize {
+			return nil, errors.New("protocol error: invalid aggregate length")
+		}
+		if line[0] == '%' || line[0] == '|' {
+			count *= 2
+		}
+		for i := 0; i < count; i++ {
+			if raw, err = c.readValue(raw, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		if line[0] == '|' {
+			// 属性之后紧跟实际的回复
+			return c.readValue(raw, depth+1)
+		}
+		return raw, nil
+	default:
+		return nil, fmt.Errorf("protocol error: unexpected reply type '%c'", line[0])
+	}
+}
+
+// writeRaw 写入已编码的内容
+func (c *redisConn) writeRaw(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write(data)
+	return err
+}
+
+// writeError 写入错误回复，message 以错误类型开头，如 "ERR ..."、"NOAUTH ..."
+func (c *redisConn) writeError(message string) error {
+	return c.writeRaw([]byte("-" + message + "\r\n"))
+}
+
+// call 发送命令并读取回复，错误回复以 *RedisError 返回，同时返回原始回复
+func (c *redisConn) call(args ...[]byte) ([]byte, error) {
+	if err := c.writeRaw(encodeRedisCommand(args)); err != nil {
+		return nil, err
+	}
+	reply, err := c.readReply()
+	if err != nil {
+		return nil, err
+	}
+	if reply[0] == '-' {
+		return reply, &RedisError{Message: strings.TrimSpace(string(reply[1:]))}
+	}
+	return reply, nil
+}
+
+// serveRedis 处理一个Redis客户端连接。
+// 客户端以 AUTH [堡垒机用户名] 令牌 或 HELLO <版本> AUTH 用户名 令牌 登录代理，
+// 代理复核授权后使用凭证在数据库上完成AUTH，之后转发命令并按命令过滤规则检查、写入命令日志。
+func (s *DatabaseProxyService) serveRedis(conn net.Conn) {
+	client := newRedisConn(conn)
+	clientIP := remoteIP(conn.RemoteAddr())
+	conn.SetDeadline(time.Now().Add(redisHandshakeTimeout))
+
+	var token *databaseToken
+	var hello [][]byte // 登录时的HELLO命令（去掉AUTH参数），登录数据库后转发以协商协议版本
+	for token == nil {
+		args, _, err := client.readCommand()
+		if err != nil {
+			conn.Close()
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		var username, password string
+		switch strings.ToUpper(string(args[0])) {
+		case "AUTH":
+			switch len(args) {
+			case 2:
+				password = string(args[1])
+			case 3:
+				username, password = string(args[1]), string(args[2])
+			default:
+				client.writeError("ERR wrong number of arguments for 'auth' command")
+				continue
+			}
+		case "HELLO":
+			hello, username, password = splitRedisHelloAuth(args)
+			if password == "" {
+				hello = nil
+				client.writeError("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+				continue
+			}
+		case "QUIT":
+			client.writeRaw(redisOK)
+			conn.Close()
+			return
+		default:
+			client.writeError("NOAUTH Authentication required.")
+			continue
+		}
+
+		// Redis客户端的默认用户名为 default，按只带密码的AUTH处理
+		if username == "default" {
+			username = ""
+		}
+		token = s.findToken(models.DatabaseProtocolRedis, username, func(token string) bool {
+			return subtle.ConstantTimeCompare([]byte(token), []byte(password)) == 1
+		})
+		if token == nil {
+			s.recordLogin(0, username, clientIP, models.DatabaseProtocolRedis, "failed", "invalid or expired database proxy token")
+			client.writeError("WRONGPASS invalid username-password pair or user is disabled.")
+			hello = nil
+		}
+	}
+
+	user, asset, credential, err := s.authorize(token.UserID, token.AssetID, token.CredentialID, clientIP)
+	if err != nil {
+		s.recordLogin(token.UserID, token.Username, clientIP, models.DatabaseProtocolRedis, "failed", err.Error())
+		client.writeError("NOPERM access denied: " + gatewaySessionErrorMessage(err))
+		conn.Close()
+		return
+	}
+	password, err := s.credentialPassword(credential)
+	if err != nil {
+		logrus.WithError(err).WithField("credential_id", credential.ID).Error("数据库代理解密凭证失败")
+		client.writeError("ERR failed to load database credential")
+		conn.Close()
+		return
+	}
+
+	address := net.JoinHostPort(asset.Address, strconv.Itoa(asset.Port))
+	upstreamConn, chain, err := s.gateways.Dial(asset, address, s.dialTimeout())
+	if err != nil {
+		logrus.WithError(err).WithField("asset_id", asset.ID).Warn("数据库代理连接数据库失败")
+		client.writeError(fmt.Sprintf("ERR could not connect to database server %s", asset.Name))
+		conn.Close()
+		return
+	}
+	upstreamConn.SetDeadline(time.Now().Add(redisHandshakeTimeout))
+	upstream := newRedisConn(upstreamConn)
+	greeting := redisOK
+	err = redisLogin(upstream, credential.Username, password)
+	if err == nil && hello != nil {
+		// 数据库不支持HELLO（Redis 6以下）时把错误返回给客户端，客户端会改用RESP2
+		if greeting, err = upstream.call(hello...); err != nil && errors.As(err, new(*RedisError)) {
+			err = nil
+		}
+	}
+	if err != nil {
+		upstreamConn.Close()
+		var redisErr *RedisError
+		if errors.As(err, &redisErr) {
+			client.writeError(redisErr.Message)
+		} else {
+			client.writeError("ERR failed to login to database server")
+		}
+		s.recordLogin(user.ID, user.Username, clientIP, models.DatabaseProtocolRedis, "failed", err.Error())
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	upstreamConn.SetDeadline(time.Time{})
+
+	session := newDatabaseSession(models.DatabaseProtocolRedis, user, asset, credential, clientIP, conn, upstreamConn)
+	if err := s.openSession(session, chain); err != nil {
+		logrus.WithError(err).Error("数据库代理创建会话失败")
+		client.writeError("ERR failed to create bastion session")
+		session.close("创建会话失败")
+		return
+	}
+	s.recordLogin(user.ID, user.Username, clientIP, models.DatabaseProtocolRedis, "success",
+		fmt.Sprintf("Database proxy login successful (%s@%s)", credential.Username, asset.Name))
+
+	if err := client.writeRaw(greeting); err == nil {
+		s.relayRedis(session, client, upstream, token.Token)
+	}
+	s.finishSession(session)
+}
+
+// relayRedis 在客户端与数据库之间转发命令与回复。
+// 命令按顺序得到回复，回复到达时与队首的命令配对写入命令日志；进入订阅或监视模式、
+// 或关闭回复（CLIENT REPLY OFF/SKIP）后回复不再与命令一一对应，之后的命令在发送时即写入日志。
+func (s *DatabaseProxyService) relayRedis(session *databaseSession, client, upstream *redisConn, token string) {
+	var pendingMu sync.Mutex
+	var pending []*redisPendingCommand
+
+	serverDone := make(chan struct{})
+	go func() {
+		defer close(serverDone)
+		for {
+			reply, err := upstream.readReply()
+			if err != nil {
+				session.close("数据库断开连接")
+				return
+			}
+
+			// RESP3的推送消息不是命令的回复
+			if reply[0] != '>' {
+				var command *redisPendingCommand
+				pendingMu.Lock()
+				if len(pending) > 0 {
+					command, pending = pending[0], pending[1:]
+				}
+				pendingMu.Unlock()
+				if command != nil {
+					output, failed := redisReplySummary(reply)
+					s.recordRedisCommand(session, command, output, failed)
+				}
+			}
+
+			if err := client.writeRaw(reply); err != nil {
+				session.close("客户端断开连接")
+				return
+			}
+		}
+	}()
+
+	unpaired := false
+	for {
+		args, raw, err := client.readCommand()
+		if err != nil {
+			break
+		}
+		if len(args) == 0 {
+			continue
+		}
+		name := strings.ToUpper(string(args[0]))
+		if name == "QUIT" {
+			client.writeRaw(redisOK)
+			break
+		}
+
+		// 认证由代理完成，客户端重新认证时只校验令牌，不转发给数据库
+		if name == "AUTH" || name == "HELLO" {
+			if name == "AUTH" && len(args) < 2 {
+				client.writeError("ERR wrong number of arguments for 'auth' command")
+				continue
+			}
+			password := string(args[len(args)-1])
+			if name == "HELLO" {
+				args, _, password = splitRedisHelloAuth(args)
+				raw = encodeRedisCommand(args)
+			}
+			if password != "" && subtle.ConstantTimeCompare([]byte(password), []byte(token)) != 1 {
+				client.writeError("WRONGPASS invalid username-password pair or user is disabled.")
+				continue
+			}
+			if name == "AUTH" {
+				client.writeRaw(redisOK)
+				continue
+			}
+		}
+
+		command := &redisPendingCommand{
+			text:   renderRedisCommand(args, redisRedactedArgs(args, redisRedactRules())),
+			action: models.FilterActionAllow,
+			start:  time.Now(),
+		}
+		result := s.checkRedisCommand(session, args, command.text)
+		if result.Matched {
+			command.action = result.Action
+		}
+		if command.action == models.FilterActionDeny || command.action == models.FilterActionPromptAlert {
+			s.recordRedisCommand(session, command, "blocked by command filter rule: "+result.FilterName, true)
+			if err := client.writeError(fmt.Sprintf("NOPERM command blocked by bastion command filter rule '%s'", result.FilterName)); err != nil {
+				break
+			}
+			continue
+		}
+
+		if unpaired {
+			s.recordRedisCommand(session, command, "", false)
+		} else {
+			pendingMu.Lock()
+			pending = append(pending, command)
+			pendingMu.Unlock()
+			unpaired = redisCommandBreaksPairing(args)
+		}
+
+		if err := upstream.writeRaw(raw); err != nil {
+			break
+		}
+	}
+
+	session.close("客户端断开连接")
+	<-serverDone
+
+	// 连接断开时仍未收到回复的命令
+	for _, command := range pending {
+		s.recordRedisCommand(session, command, "connection closed before reply", true)
+	}
+}
+
+// checkRedisCommand 按命令过滤规则检查命令，与SSH会话共用命令组和过滤规则；
+// 规则匹配未脱敏的完整命令，过滤日志和安全告警中只出现脱敏后的文本 logged。
+// 命中告警或拒绝规则时发送安全告警
+func (s *DatabaseProxyService) checkRedisCommand(session *databaseSession, args [][]byte, logged string) *models.CommandMatchResponse {
+	result, err := s.commands.MatchCommand(&models.CommandMatchRequest{
+		Command:       formatRedisCommand(args, nil, 0),
+		UserID:        session.User.ID,
+		AssetID:       session.Asset.ID,
+		Account:       session.Credential.Username,
+		LoggedCommand: logged,
+	})
+	if err != nil {
+		// 与SSH会话一致，无法完成规则匹配时拒绝执行
+		logrus.WithError(err).WithField("session_id", session.ID).Warn("Redis命令过滤规则匹配失败")
+		return &models.CommandMatchResponse{Matched: true, Action: models.FilterActionDeny, FilterName: "command filter unavailable"}
+	}
+	if !result.Matched || result.Action == models.FilterActionAllow {
+		return result
+	}
+
+	if sender := notifier(); sender != nil {
+		details := map[string]interface{}{
+			"session_id":  session.ID,
+			"user_id":     session.User.ID,
+			"username":    session.User.Username,
+			"asset_id":    session.Asset.ID,
+			"asset_name":  session.Asset.Name,
+			"client_ip":   session.ClientIP,
+			"protocol":    session.Protocol,
+			"command":     truncateStatement(logged, 500),
+			"filter_id":   result.FilterID,
+			"filter_name": result.FilterName,
+			"action":      result.Action,
+		}
+		go func() {
+			if err := sender.NotifySecurityEvent(context.Background(), "redis_command_"+result.Action, details); err != nil {
+				logrus.WithError(err).Warn("发送Redis命令过滤告警失败")
+			}
+		}()
+	}
+	return result
+}
+
+// recordRedisCommand 写入命令日志，输出只记录回复的类型与概要，不记录数据
+func (s *DatabaseProxyService) recordRedisCommand(session *databaseSession, command *redisPendingCommand, output string, failed bool) {
+	exitCode := 0
+	if failed {
+		exitCode = 1
+	}
+	endTime := time.Now()
+	go s.auditService.RecordCommandLog(session.ID, session.User.ID, session.User.Username, session.Asset.ID,
+		command.text, output, exitCode, command.action, command.start, &endTime)
+}
+
+// redisLogin 使用凭证在数据库上完成AUTH，凭证没有密码时不认证
+func redisLogin(c *redisConn, username, password string) error {
+	if password == "" {
+		return nil
+	}
+	args := [][]byte{[]byte("AUTH"), []byte(password)}
+	if username != "" && username != "default" {
+		args = [][]byte{[]byte("AUTH"), []byte(username), []byte(password)}
+	}
+	_, err := c.call(args...)
+	return err
+}
+
+// splitRedisHelloAuth 拆出HELLO命令中的AUTH用户名与密码，返回去掉AUTH参数的命令
+func splitRedisHelloAuth(args [][]byte) ([][]byte, string, string) {
+	var username, password string
+	hello := [][]byte{args[0]}
+	for i := 1; i < len(args); i++ {
+		if strings.EqualFold(string(args[i]), "AUTH") && i+2 < len(args) {
+			username, password = string(args[i+1]), string(args[i+2])
+			i += 2
+			continue
+		}
+		hello = append(hello, args[i])
+	}
+	return hello, username, password
+}
+
+// redisCommandBreaksPairing 判断命令之后的回复是否不再与命令一一对应
+func redisCommandBreaksPairing(args [][]byte) bool {
+	switch strings.ToUpper(string(args[0])) {
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE", "MONITOR":
+		return true
+	case "CLIENT":
+		return len(args) >= 3 && strings.EqualFold(string(args[1]), "REPLY") && !strings.EqualFold(string(args[2]), "ON")
+	}
+	return false
+}
+
+// redisRedactRules 当前生效的脱敏规则
+func redisRedactRules() []config.RedisRedactRule {
+	if rules := config.GlobalConfig.DatabaseProxy.RedisRedact; len(rules) > 0 {
+		return rules
+	}
+	return defaultRedisRedactRules
+}
+
+// redisRedactedArgs 返回需要脱敏的参数下标（命令名为0）。
+// AUTH、HELLO、MIGRATE中的密码始终脱敏，其他参数按规则脱敏
+func redisRedactedArgs(args [][]byte, rules []config.RedisRedactRule) map[int]bool {
+	redacted := make(map[int]bool)
+	name := strings.ToUpper(string(args[0]))
+	switch name {
+	case "AUTH":
+		for i := 1; i < len(args); i++ {
+			redacted[i] = true
+		}
+	case "HELLO", "MIGRATE":
+		for i := 1; i < len(args); i++ {
+			switch strings.ToUpper(string(args[i])) {
+			case "AUTH":
+				if name == "HELLO" {
+					redacted[i+2] = true
+				} else {
+					redacted[i+1] = true
+				}
+			case "AUTH2":
+				redacted[i+2] = true
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		words := strings.Fields(rule.Command)
+		if len(words) == 0 || len(args) <= len(words) {
+			continue
+		}
+		matched := true
+		for i, word := range words {
+			if !strings.EqualFold(string(args[i]), word) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		from, step := rule.From, rule.Step
+		if from < 1 {
+			from = 1
+		}
+		if step < 1 {
+			step = 1
+		}
+		for k := 0; rule.Count <= 0 || k < rule.Count; k++ {
+			index := len(words) - 1 + from + k*step
+			if index >= len(args) {
+				break
+			}
+			redacted[index] = true
+		}
+	}
+	return redacted
+}
+
+// renderRedisCommand 把命令渲染为写入命令日志的文本，脱敏的参数替换为占位符，超长时截断
+func renderRedisCommand(args [][]byte, redacted map[int]bool) string {
+	return truncateStatement(formatRedisCommand(args, redacted, redisMaxLoggedCommand), redisMaxLoggedCommand)
+}
+
+// formatRedisCommand 把命令格式化为 redis-cli 风格的文本，命令名大写，
+// 含空白或不可打印字符的参数加引号，redacted 中的参数替换为占位符；
+// limit 大于0时超过该长度后不再追加参数
+func formatRedisCommand(args [][]byte, redacted map[int]bool, limit int) string {
+	var builder strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			builder.WriteByte(' ')
+		}
+		switch {
+		case i == 0:
+			builder.WriteString(strings.ToUpper(string(arg)))
+		case redacted[i]:
+			builder.WriteString(redisRedactedValue)
+		case len(arg) > 0 && bytes.IndexFunc(arg, func(r rune) bool { return r <= ' ' || r == '"' || r == '\'' || r > '~' }) < 0:
+			builder.Write(arg)
+		default:
+			builder.WriteString(strconv.Quote(string(arg)))
+		}
+		if limit > 0 && builder.Len() > limit {
+			break
+		}
+	}
+	return builder.String()
+}
+
+// redisReplySummary 回复的概要，用于命令日志的输出，返回是否为错误回复
+func redisReplySummary(reply []byte) (string, bool) {
+	end := bytes.Index(reply, []byte("\r\n"))
+	if end < 0 {
+		return "", false
+	}
+	line := string(reply[1:end])
+	switch reply[0] {
+	case '-', '!':
+		if reply[0] == '!' {
+			// RESP3大块错误：内容在长度行之后
+			if size, err := parseRedisLength(reply[:end+2]); err == nil && size >= 0 && end+2+size <= len(reply) {
+				line = string(reply[end+2 : end+2+size])
+			}
+		}
+		return truncateStatement(line, 500), true
+	case '+':
+		return truncateStatement(line, 500), false
+	case ':':
+		return "(integer) " + line, false
+	case '_':
+		return "(nil)", false
+	case '#':
+		return "(boolean) " + line, false
+	case ',', '(':
+		return "(number) " + line, false
+	case '$', '=':
+		if line == "-1" {
+			return "(nil)", false
+		}
+		return fmt.Sprintf("(%s bytes)", line), false
+	case '*', '~':
+		if line == "-1" {
+			return "(nil)", false
+		}
+		return fmt.Sprintf("(%s elements)", line), false
+	case '%':
+		return fmt.Sprintf("(%s entries)", line), false
+	case '|':
+		return "(attribute)", false
+	}
+	return "", false
+}
+
+// encodeRedisCommand 把参数编码为RESP数组
+func encodeRedisCommand(args [][]byte) []byte {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// parseRedisLength 解析 *N、$N 等行中的长度
+func parseRedisLength(line []byte) (int, error) {
+	return strconv.Atoi(string(bytes.TrimRight(line[1:], "\r\n")))
+}
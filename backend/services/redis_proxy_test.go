@@ -0,0 +1,152 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const (
+	testRedisAccount  = "cache_rw"
+	testRedisPassword = "Upstream-Redis-4kP9"
+)
+
+// setupRedisProxy 启动代理与模拟Redis，返回代理服务、代理地址、数据库、模拟Redis以及用户、资产与凭证
+func setupRedisProxy(t *testing.T) (*DatabaseProxyService, string, *gorm.DB, *miniredis.Miniredis, *models.User, *models.Asset, *models.Credential) {
+	t.Helper()
+	setupTestConfig(t)
+	config.GlobalConfig.DatabaseProxy = config.DatabaseProxyConfig{Enable: true, Host: "127.0.0.1", RedisPort: 6380, DialTimeout: 2}
+	config.GlobalConfig.Audit.EnableSessionRecord = true
+
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+		&models.UserGroup{}, &models.Asset{}, &models.AssetGroup{}, &models.Credential{}, &models.AssetCredential{},
+		&models.AssetPermission{}, &models.AccessPolicy{}, &models.NetworkZone{}, &models.SessionRecord{}, &models.LoginLog{},
+		&models.CommandLog{}, &models.CommandGroup{}, &models.CommandGroupItem{}, &models.CommandFilter{},
+		&models.FilterAttribute{}, &models.CommandFilterLog{})
+
+	server := miniredis.RunT(t)
+	server.RequireUserAuth(testRedisAccount, testRedisPassword)
+	port, err := strconv.Atoi(server.Port())
+	require.NoError(t, err)
+
+	encrypted, err := utils.EncryptPassword(testRedisPassword)
+	require.NoError(t, err)
+	credential := &models.Credential{Name: "cache", Type: utils.CredentialTypePassword, Username: testRedisAccount, Password: encrypted}
+	require.NoError(t, db.Create(credential).Error)
+	asset := &models.Asset{Name: "session-cache", Type: "database", Protocol: models.DatabaseProtocolRedis,
+		Address: "127.0.0.1", Port: port, Status: 1, Credentials: []models.Credential{*credential}}
+	require.NoError(t, db.Create(asset).Error)
+
+	role := models.Role{Name: "admin"}
+	require.NoError(t, db.Create(&role).Error)
+	user := &models.User{Username: "alice", Password: "hash", Status: 1, AuthSource: models.AuthSourceLocal, Roles: []models.Role{role}}
+	require.NoError(t, db.Create(user).Error)
+
+	proxy := NewDatabaseProxyService(db, nil, nil)
+	require.NoError(t, proxy.listen(models.DatabaseProtocolRedis, "127.0.0.1:0", proxy.serveRedis))
+	t.Cleanup(func() { proxy.Stop() })
+	return proxy, proxy.listeners[0].Addr().String(), db, server, user, asset, credential
+}
+
+// createRedisDenyFilter 创建拒绝命令组中命令的过滤规则
+func createRedisDenyFilter(t *testing.T, db *gorm.DB, name string, items ...models.CommandGroupItem) {
+	t.Helper()
+	group := &models.CommandGroup{Name: name, Items: items}
+	require.NoError(t, db.Create(group).Error)
+	filter := &models.CommandFilter{Name: name, Priority: 10, Enabled: true, UserType: "all", AssetType: "all",
+		AccountType: "all", CommandGroupID: group.ID, Action: models.FilterActionDeny}
+	require.NoError(t, db.Create(filter).Error)
+}
+
+// openRedisClient 以堡垒机用户名和连接令牌通过代理连接Redis
+func openRedisClient(t *testing.T, proxy *DatabaseProxyService, proxyAddr string, user *models.User, asset *models.Asset, credential *models.Credential) *redis.Client {
+	t.Helper()
+	response, err := proxy.IssueToken(user.ID, "127.0.0.1", &models.DatabaseTokenRequest{AssetID: asset.ID, CredentialID: credential.ID})
+	require.NoError(t, err)
+	client := redis.NewClient(&redis.Options{Addr: proxyAddr, Username: user.Username, Password: response.Token,
+		MaxRetries: -1, PoolSize: 1, DialTimeout: 2 * time.Second})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// waitCommandLogs 等待异步写入的命令日志
+func waitCommandLogs(t *testing.T, db *gorm.DB, count int) []models.CommandLog {
+	t.Helper()
+	var logs []models.CommandLog
+	require.Eventually(t, func() bool {
+		logs = nil
+		db.Order("id").Find(&logs)
+		return len(logs) >= count
+	}, 5*time.Second, 20*time.Millisecond)
+	return logs
+}
+
+func TestRedisProxyMatchesRulesOnRawArgumentsAndLogsRedactedText(t *testing.T) {
+	proxy, proxyAddr, db, server, user, asset, credential := setupRedisProxy(t)
+	// 规则针对的是默认脱敏规则会隐藏的 SET 值
+	createRedisDenyFilter(t, db, "no-debug-flag", models.CommandGroupItem{Type: models.CommandTypeRegex, Content: `^SET\s+\S+\s+debug-on$`, IgnoreCase: true})
+	client := openRedisClient(t, proxy, proxyAddr, user, asset, credential)
+	ctx := context.Background()
+
+	err := client.Set(ctx, "feature:flag", "debug-on", 0).Err()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "NOPERM command blocked by bastion command filter rule 'no-debug-flag'")
+	require.False(t, server.Exists("feature:flag"))
+
+	require.NoError(t, client.Set(ctx, "feature:flag", "debug-off", 0).Err())
+	value, err := server.Get("feature:flag")
+	require.NoError(t, err)
+	require.Equal(t, "debug-off", value)
+
+	// 命令日志与过滤日志只记录脱敏后的文本
+	logs := waitCommandLogs(t, db, 2)
+	require.Equal(t, "SET feature:flag ***", logs[0].Command)
+	require.Equal(t, models.FilterActionDeny, logs[0].Action)
+	require.Equal(t, "SET feature:flag ***", logs[1].Command)
+	require.Equal(t, models.FilterActionAllow, logs[1].Action)
+
+	var filterLogs []models.CommandFilterLog
+	require.NoError(t, db.Find(&filterLogs).Error)
+	require.Len(t, filterLogs, 1)
+	require.Equal(t, "SET feature:flag ***", filterLogs[0].Command)
+	require.Equal(t, "no-debug-flag", filterLogs[0].FilterName)
+	for _, log := range logs {
+		require.False(t, strings.Contains(log.Command, "debug-on"), log.Command)
+	}
+}
+
+func TestRedisProxyBlocksScriptCommandsInDefaultGroup(t *testing.T) {
+	proxy, proxyAddr, db, server, user, asset, credential := setupRedisProxy(t)
+	// 与 20250820、20250822 迁移中的默认命令组一致
+	var items []models.CommandGroupItem
+	for i, command := range []string{"FLUSHALL", "FLUSHDB", "EVAL", "EVALSHA", "EVAL_RO", "EVALSHA_RO", "FCALL", "FCALL_RO", "SCRIPT", "FUNCTION"} {
+		items = append(items, models.CommandGroupItem{Type: models.CommandTypeExact, Content: command, IgnoreCase: true, SortOrder: i + 1})
+	}
+	createRedisDenyFilter(t, db, "Redis高危命令", items...)
+	require.NoError(t, server.Set("orders:1", "pending"))
+	client := openRedisClient(t, proxy, proxyAddr, user, asset, credential)
+	ctx := context.Background()
+
+	blocked := []*redis.Cmd{
+		client.Do(ctx, "EVAL", "return redis.call('FLUSHALL')", "0"),
+		client.Do(ctx, "evalsha", "e0e1f9fabfc9d4800c877a703b823ac0578ff8db", "0"),
+		client.Do(ctx, "SCRIPT", "LOAD", "return redis.call('FLUSHALL')"),
+		client.Do(ctx, "FCALL", "wipe", "0"),
+		client.Do(ctx, "FLUSHALL"),
+	}
+	for _, cmd := range blocked {
+		require.Error(t, cmd.Err(), "%v", cmd.Args())
+		require.Contains(t, cmd.Err().Error(), "NOPERM command blocked by bastion command filter rule", "%v", cmd.Args())
+	}
+	require.True(t, server.Exists("orders:1"))
+}
@@ -51,6 +51,7 @@ func (s *RoleService) CreateRole(request *models.RoleCreateRequest) (*models.Rol
 	role := models.Role{
 		Name:        request.Name,
 		Description: request.Description,
+		MFARequired: request.MFARequired,
 	}
 
 	if err := tx.Create(&role).Error; err != nil {
@@ -166,6 +167,9 @@ func (s *RoleService) UpdateRole(id uint, request *models.RoleUpdateRequest) (*m
 	if request.Description != "" {
 		updates["description"] = request.Description
 	}
+	if request.MFARequired != nil {
+		updates["mfa_required"] = *request.MFARequired
+	}
 
 	if len(updates) > 0 {
 		if err := tx.Model(&role).Updates(updates).Error; err != nil {
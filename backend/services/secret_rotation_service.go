@@ -0,0 +1,250 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// secretField 保存加密数据的表字段
+type secretField struct {
+	table  string
+	column string
+}
+
+// secretFields 所有通过密钥后端加密的字段，新增加密字段时需要加入此列表
+var secretFields = []secretField{
+	{table: "credentials", column: "password"},
+	{table: "credentials", column: "private_key"},
+	{table: "ssh_certificate_authorities", column: "private_key"},
+	{table: "user_mfa", column: "secret"},
+}
+
+// defaultReencryptBatchSize 每批处理的记录数
+const defaultReencryptBatchSize = 200
+
+// 单条记录的重新加密结果
+const (
+	reencryptUnchanged = iota // 已使用当前主密钥，无需处理
+	reencryptUpdated          // 已重新加密
+	reencryptConflict         // 处理期间被其它请求更新，跳过
+)
+
+// SecretRotationService 主密钥轮换与在线重新加密服务
+// 轮换后新数据立即使用新版本主密钥；重新加密逐批处理旧版本密文，只在记录未被并发修改时写回，服务无需停机。
+type SecretRotationService struct {
+	db           *gorm.DB
+	auditService *AuditService
+}
+
+// NewSecretRotationService 创建主密钥轮换服务实例
+func NewSecretRotationService(db *gorm.DB) *SecretRotationService {
+	return &SecretRotationService{
+		db:           db,
+		auditService: NewAuditService(db),
+	}
+}
+
+// Status 返回当前主密钥版本及各加密字段使用的版本分布
+func (s *SecretRotationService) Status() (int, []models.SecretFieldStats, error) {
+	store, err := utils.GetSecretStore()
+	if err != nil {
+		return 0, nil, err
+	}
+	current, err := store.CurrentVersion()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get current master key version: %w", err)
+	}
+
+	stats := make([]models.SecretFieldStats, 0, len(secretFields))
+	for _, field := range secretFields {
+		fieldStats := models.SecretFieldStats{Table: field.table, Column: field.column, Versions: map[int]int{}}
+		err := s.eachBatch(field, defaultReencryptBatchSize, func(id uint, value string) {
+			fieldStats.Versions[utils.SecretKeyVersion(value)]++
+		})
+		if err != nil {
+			return 0, nil, err
+		}
+		stats = append(stats, fieldStats)
+	}
+	return current, stats, nil
+}
+
+// RotateMasterKey 生成新的主密钥版本，之后加密的数据使用新版本
+func (s *SecretRotationService) RotateMasterKey() (int, error) {
+	store, err := utils.GetSecretStore()
+	if err != nil {
+		return 0, err
+	}
+	previous, err := store.CurrentVersion()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current master key version: %w", err)
+	}
+	version, err := store.Rotate()
+	if err != nil {
+		return 0, fmt.Errorf("failed to rotate master key: %w", err)
+	}
+
+	s.recordOperation("rotate_master_key", fmt.Sprintf("master key rotated from version %d to %d", previous, version), map[string]interface{}{
+		"backend":          store.Name(),
+		"previous_version": previous,
+		"version":          version,
+	})
+	return version, nil
+}
+
+// Reencrypt 使用当前主密钥重新加密所有旧版本或旧格式的数据
+func (s *SecretRotationService) Reencrypt(batchSize int) ([]models.SecretReencryptStats, error) {
+	if batchSize <= 0 {
+		batchSize = defaultReencryptBatchSize
+	}
+	if _, err := utils.GetSecretStore(); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.SecretReencryptStats, 0, len(secretFields))
+	for _, field := range secretFields {
+		stats := models.SecretReencryptStats{Table: field.table, Column: field.column}
+		err := s.eachBatch(field, batchSize, func(id uint, value string) {
+			stats.Scanned++
+			result, err := s.reencryptValue(field, id, value)
+			if err != nil {
+				stats.Failed++
+				stats.Errors = append(stats.Errors, fmt.Sprintf("id %d: %v", id, err))
+				logrus.WithError(err).WithFields(logrus.Fields{"table": field.table, "column": field.column, "id": id}).Error("重新加密失败")
+				return
+			}
+			switch result {
+			case reencryptUpdated:
+				stats.Reencrypted++
+			case reencryptConflict:
+				stats.Skipped++
+			}
+		})
+		if err != nil {
+			return results, err
+		}
+		results = append(results, stats)
+	}
+
+	summary := make([]string, len(results))
+	for i, stats := range results {
+		summary[i] = fmt.Sprintf("%s.%s: %d reencrypted, %d failed", stats.Table, stats.Column, stats.Reencrypted, stats.Failed)
+	}
+	s.recordOperation("reencrypt_secrets", strings.Join(summary, "; "), map[string]interface{}{"results": results})
+	return results, nil
+}
+
+// RetireOldVersions 停用当前版本之前的主密钥，仍有数据使用旧版本时拒绝执行
+func (s *SecretRotationService) RetireOldVersions() (int, error) {
+	current, stats, err := s.Status()
+	if err != nil {
+		return 0, err
+	}
+	for _, field := range stats {
+		for version, count := range field.Versions {
+			if version < current && count > 0 {
+				return 0, fmt.Errorf("%w: %d record(s) in %s.%s still use master key version %d, run reencrypt first",
+					utils.ErrInvalidParam, count, field.Table, field.Column, version)
+			}
+		}
+	}
+
+	store, err := utils.GetSecretStore()
+	if err != nil {
+		return 0, err
+	}
+	if err := store.RetireVersionsBelow(current); err != nil {
+		return 0, fmt.Errorf("failed to retire master key versions: %w", err)
+	}
+
+	s.recordOperation("retire_master_keys", fmt.Sprintf("master key versions below %d retired", current), map[string]interface{}{
+		"backend": store.Name(),
+		"version": current,
+	})
+	return current, nil
+}
+
+// reencryptValue 重新加密单条记录
+func (s *SecretRotationService) reencryptValue(field secretField, id uint, value string) (int, error) {
+	needs, err := utils.SecretNeedsReencrypt(value)
+	if err != nil {
+		return reencryptUnchanged, err
+	}
+	if !needs {
+		return reencryptUnchanged, nil
+	}
+
+	// 凭证私钥在引入密钥后端之前以明文保存
+	plaintext, err := utils.DecryptPrivateKey(value)
+	if err != nil {
+		return reencryptUnchanged, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	encrypted, err := utils.EncryptSecret(plaintext)
+	if err != nil {
+		return reencryptUnchanged, fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	// 仅在值未被修改时写回，避免覆盖并发更新
+	result := s.db.Table(field.table).Where("id = ? AND "+field.column+" = ?", id, value).Update(field.column, encrypted)
+	if result.Error != nil {
+		return reencryptUnchanged, fmt.Errorf("failed to update: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return reencryptConflict, nil
+	}
+	return reencryptUpdated, nil
+}
+
+// eachBatch 按主键顺序分批遍历字段的非空值
+func (s *SecretRotationService) eachBatch(field secretField, batchSize int, fn func(id uint, value string)) error {
+	var lastID uint
+	for {
+		var rows []struct {
+			ID    uint
+			Value string
+		}
+		err := s.db.Table(field.table).
+			Select("id, "+field.column+" AS value").
+			Where("id > ? AND "+field.column+" IS NOT NULL AND "+field.column+" <> ''", lastID).
+			Order("id").
+			Limit(batchSize).
+			Scan(&rows).Error
+		if err != nil {
+			return fmt.Errorf("failed to query %s.%s: %w", field.table, field.column, err)
+		}
+
+		for _, row := range rows {
+			fn(row.ID, row.Value)
+			lastID = row.ID
+		}
+		if len(rows) < batchSize {
+			return nil
+		}
+	}
+}
+
+// recordOperation 记录主密钥操作，命令行执行时进程很快退出，因此同步写入
+func (s *SecretRotationService) recordOperation(action, message string, details map[string]interface{}) {
+	s.auditService.RecordOperationLog(
+		0,
+		"system",
+		"",
+		"SYSTEM",
+		"/cmd/secrets",
+		action,
+		"secret_store",
+		0,
+		"",
+		200,
+		message,
+		details,
+		nil,
+		0,
+		false,
+	)
+}
@@ -104,6 +104,15 @@ func (sr *ServiceRegistry) GetSessionManager() interfaces.SessionManager {
 	return sr.sessionManager
 }
 
+// closeUserAssetSessions 通过已注册的会话管理器断开用户在指定资产上的会话，未注册时不做处理
+func closeUserAssetSessions(userID uint, assetIDs []uint, reason string) (int, error) {
+	manager := GlobalServiceRegistry.GetSessionManager()
+	if manager == nil {
+		return 0, nil
+	}
+	return manager.CloseUserAssetSessions(context.Background(), userID, assetIDs, reason)
+}
+
 // GetConnectionTester 获取连接测试器
 func (sr *ServiceRegistry) GetConnectionTester() interfaces.ConnectionTester {
 	sr.mu.RLock()
@@ -570,6 +579,22 @@ func (sma *SessionManagerAdapter) CloseAssetSessions(ctx context.Context, assetI
 	return sma.unifiedSessionService.CloseAssetSessions(ctx, assetID)
 }
 
+// CloseUserAssetSessions 断开用户在指定资产上的终端、数据库代理会话和端口转发隧道，
+// assetIDs 为空时断开该用户的全部会话，返回断开的会话数。授权收回或用户被禁用时调用
+func (sma *SessionManagerAdapter) CloseUserAssetSessions(ctx context.Context, userID uint, assetIDs []uint, reason string) (int, error) {
+	closed := 0
+	if GlobalSSHService != nil {
+		closed += GlobalSSHService.CloseUserAssetSessions(userID, assetIDs, reason)
+	}
+	if GlobalDatabaseProxyService != nil {
+		closed += GlobalDatabaseProxyService.CloseUserAssetSessions(userID, assetIDs, reason)
+	}
+	if GlobalPortForwardService != nil {
+		closed += GlobalPortForwardService.CloseUserAssetTunnels(userID, assetIDs, reason)
+	}
+	return closed, nil
+}
+
 // GetSessionCount 获取会话数量
 func (sma *SessionManagerAdapter) GetSessionCount(ctx context.Context, userID *uint) (int64, error) {
 	return sma.unifiedSessionService.GetSessionCount(ctx, userID)
@@ -602,24 +627,3 @@ func (sma *SessionManagerAdapter) ForceCloseSession(ctx context.Context, session
 
 // 全局服务注册表实例
 var GlobalServiceRegistry = NewServiceRegistry()
-
-// InitializeServices 初始化所有服务
-func InitializeServices(assetService *AssetService, db *gorm.DB, redisClient interface{}) error {
-	// 创建适配器
-	assetAdapter := NewAssetServiceAdapter(assetService, db)
-	connectivityService := NewConnectivityService()
-	connectionTesterAdapter := NewConnectionTesterAdapter(connectivityService)
-	
-	// 注册服务
-	GlobalServiceRegistry.RegisterAssetProvider(assetAdapter)
-	GlobalServiceRegistry.RegisterConnectionTester(connectionTesterAdapter)
-	
-	// 如果有Redis客户端，创建会话管理器
-	if redisClient != nil {
-		// 这里需要类型断言或其他方式处理Redis客户端
-		log.Println("会话管理器初始化需要Redis客户端配置")
-	}
-	
-	// 初始化注册表
-	return GlobalServiceRegistry.Initialize()
-}
\ No newline at end of file
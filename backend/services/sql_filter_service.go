@@ -0,0 +1,619 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// sqlFilterCacheTTL 过滤规则缓存时间，规则变更在该时间内对其他服务实例生效
+const sqlFilterCacheTTL = 30 * time.Second
+
+// SQLFilterService SQL过滤规则服务
+// 数据库代理在语句发送到数据库之前按规则检查，规则按优先级匹配，命中的第一条规则决定动作
+type SQLFilterService struct {
+	db *gorm.DB
+
+	mu       sync.RWMutex
+	rules    []models.SQLFilterRule
+	regexps  map[uint]*regexp.Regexp
+	loadedAt time.Time
+}
+
+// SQLFilterResult 语句检查结果
+type SQLFilterResult struct {
+	Action        string
+	Rule          *models.SQLFilterRule // 未命中任何规则时为nil
+	StatementType string
+}
+
+// sqlStatement 分析后的单条语句
+type sqlStatement struct {
+	Text     string // 去除注释后的语句
+	Type     string // 语句类型（首个关键字，大写）
+	HasWhere bool
+}
+
+// NewSQLFilterService 创建SQL过滤规则服务实例
+func NewSQLFilterService(db *gorm.DB) *SQLFilterService {
+	return &SQLFilterService{db: db}
+}
+
+// CreateRule 创建SQL过滤规则
+func (s *SQLFilterService) CreateRule(request *models.SQLFilterRuleCreateRequest) (*models.SQLFilterRuleResponse, error) {
+	if err := s.checkName(request.Name, 0); err != nil {
+		return nil, err
+	}
+	if err := validateSQLFilterPattern(request.MatchType, request.Pattern); err != nil {
+		return nil, err
+	}
+
+	rule := models.SQLFilterRule{
+		Name:      request.Name,
+		Priority:  request.Priority,
+		Enabled:   true,
+		Protocol:  request.Protocol,
+		MatchType: request.MatchType,
+		Pattern:   request.Pattern,
+		Action:    request.Action,
+		Remark:    request.Remark,
+	}
+	if rule.Priority == 0 {
+		rule.Priority = 50
+	}
+	if rule.Protocol == "" {
+		rule.Protocol = "all"
+	}
+	if request.Enabled != nil {
+		rule.Enabled = *request.Enabled
+	}
+
+	assets, err := s.loadAssets(request.AssetIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Enabled 为false时需显式写入，避免被数据库默认值覆盖
+		if err := tx.Omit(clause.Associations).Create(&rule).Error; err != nil {
+			return fmt.Errorf("failed to create sql filter rule: %w", err)
+		}
+		if !rule.Enabled {
+			if err := tx.Model(&rule).Update("enabled", false).Error; err != nil {
+				return fmt.Errorf("failed to create sql filter rule: %w", err)
+			}
+		}
+		if len(assets) > 0 {
+			if err := tx.Model(&rule).Association("Assets").Replace(assets); err != nil {
+				return fmt.Errorf("failed to bind sql filter rule assets: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return s.GetRule(rule.ID)
+}
+
+// GetRules 获取SQL过滤规则列表
+func (s *SQLFilterService) GetRules(request *models.SQLFilterRuleListRequest) ([]*models.SQLFilterRuleResponse, int64, error) {
+	query := s.db.Model(&models.SQLFilterRule{})
+	if request.Name != "" {
+		query = query.Where("name LIKE ?", "%"+request.Name+"%")
+	}
+	if request.Action != "" {
+		query = query.Where("action = ?", request.Action)
+	}
+	if request.Enabled != nil {
+		query = query.Where("enabled = ?", *request.Enabled)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count sql filter rules: %w", err)
+	}
+
+	var rules []models.SQLFilterRule
+	offset := (request.Page - 1) * request.PageSize
+	if err := query.Preload("Assets").Order("priority ASC, id ASC").Offset(offset).Limit(request.PageSize).Find(&rules).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query sql filter rules: %w", err)
+	}
+
+	responses := make([]*models.SQLFilterRuleResponse, len(rules))
+	for i := range rules {
+		responses[i] = rules[i].ToResponse()
+	}
+	return responses, total, nil
+}
+
+// GetRule 获取SQL过滤规则详情
+func (s *SQLFilterService) GetRule(id uint) (*models.SQLFilterRuleResponse, error) {
+	rule, err := s.getRule(id)
+	if err != nil {
+		return nil, err
+	}
+	return rule.ToResponse(), nil
+}
+
+// UpdateRule 更新SQL过滤规则
+func (s *SQLFilterService) UpdateRule(id uint, request *models.SQLFilterRuleUpdateRequest) (*models.SQLFilterRuleResponse, error) {
+	rule, err := s.getRule(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if request.Name != "" && request.Name != rule.Name {
+		if err := s.checkName(request.Name, id); err != nil {
+			return nil, err
+		}
+		updates["name"] = request.Name
+	}
+	if request.Priority != 0 {
+		updates["priority"] = request.Priority
+	}
+	if request.Enabled != nil {
+		updates["enabled"] = *request.Enabled
+	}
+	if request.Protocol != "" {
+		updates["protocol"] = request.Protocol
+	}
+	if request.Action != "" {
+		updates["action"] = request.Action
+	}
+	if request.Remark != nil {
+		updates["remark"] = *request.Remark
+	}
+	if request.MatchType != "" || request.Pattern != "" {
+		matchType, pattern := rule.MatchType, rule.Pattern
+		if request.MatchType != "" {
+			matchType = request.MatchType
+		}
+		if request.Pattern != "" {
+			pattern = request.Pattern
+		}
+		if err := validateSQLFilterPattern(matchType, pattern); err != nil {
+			return nil, err
+		}
+		updates["match_type"] = matchType
+		updates["pattern"] = pattern
+	}
+
+	var assets []models.Asset
+	if request.AssetIDs != nil {
+		if assets, err = s.loadAssets(*request.AssetIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(rule).Omit(clause.Associations).Updates(updates).Error; err != nil {
+				return fmt.Errorf("failed to update sql filter rule: %w", err)
+			}
+		}
+		if request.AssetIDs != nil {
+			if err := tx.Model(rule).Association("Assets").Replace(assets); err != nil {
+				return fmt.Errorf("failed to bind sql filter rule assets: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return s.GetRule(id)
+}
+
+// DeleteRule 删除SQL过滤规则
+func (s *SQLFilterService) DeleteRule(id uint) error {
+	rule, err := s.getRule(id)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(rule).Association("Assets").Clear(); err != nil {
+			return fmt.Errorf("failed to unbind sql filter rule assets: %w", err)
+		}
+		if err := tx.Delete(rule).Error; err != nil {
+			return fmt.Errorf("failed to delete sql filter rule: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// Check 检查SQL文本（可能包含多条语句）应执行的动作
+// 规则按优先级依次匹配，规则命中文本中任意一条语句即生效；未命中任何规则时放行
+func (s *SQLFilterService) Check(protocol string, assetID uint, sql string) *SQLFilterResult {
+	statements := splitSQLStatements(protocol, sql)
+	result := &SQLFilterResult{Action: models.FilterActionAllow}
+	if len(statements) > 0 {
+		result.StatementType = statements[0].Type
+	}
+
+	rules, regexps := s.loadRules()
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Protocol != "" && rule.Protocol != "all" && rule.Protocol != protocol {
+			continue
+		}
+		if !sqlRuleAppliesToAsset(rule, assetID) {
+			continue
+		}
+		for _, stmt := range statements {
+			if sqlRuleMatches(rule, regexps[rule.ID], stmt) {
+				result.Action = rule.Action
+				result.Rule = rule
+				result.StatementType = stmt.Type
+				return result
+			}
+		}
+	}
+	return result
+}
+
+// GetSQLAuditLogs 获取SQL审计日志列表
+func (s *SQLFilterService) GetSQLAuditLogs(req *models.SQLAuditLogListRequest) ([]*models.SQLAuditLogResponse, int64, error) {
+	var logs []models.SQLAuditLog
+	var total int64
+
+	query := s.db.Model(&models.SQLAuditLog{})
+	if req.SessionID != "" {
+		query = query.Where("session_id = ?", req.SessionID)
+	}
+	if req.Username != "" {
+		query = query.Where("username LIKE ?", "%"+req.Username+"%")
+	}
+	if req.AssetID > 0 {
+		query = query.Where("asset_id = ?", req.AssetID)
+	}
+	if req.Protocol != "" {
+		query = query.Where("protocol = ?", req.Protocol)
+	}
+	if req.Action != "" {
+		query = query.Where("action = ?", req.Action)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.Keyword != "" {
+		query = query.Where("statement LIKE ?", "%"+req.Keyword+"%")
+	}
+	if req.StartTime != "" {
+		if startTime, err := time.Parse("2006-01-02", req.StartTime); err == nil {
+			query = query.Where("created_at >= ?", startTime)
+		}
+	}
+	if req.EndTime != "" {
+		if endTime, err := time.Parse("2006-01-02", req.EndTime); err == nil {
+			query = query.Where("created_at <= ?", endTime.Add(24*time.Hour))
+		}
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count sql audit logs: %w", err)
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	if err := query.Offset((page - 1) * pageSize).Limit(pageSize).Order("created_at DESC, id DESC").Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query sql audit logs: %w", err)
+	}
+
+	responses := make([]*models.SQLAuditLogResponse, len(logs))
+	for i := range logs {
+		responses[i] = logs[i].ToResponse()
+	}
+	return responses, total, nil
+}
+
+// loadRules 获取启用的规则，缓存过期时从数据库重新加载
+func (s *SQLFilterService) loadRules() ([]models.SQLFilterRule, map[uint]*regexp.Regexp) {
+	s.mu.RLock()
+	if !s.loadedAt.IsZero() && time.Since(s.loadedAt) < sqlFilterCacheTTL {
+		rules, regexps := s.rules, s.regexps
+		s.mu.RUnlock()
+		return rules, regexps
+	}
+	s.mu.RUnlock()
+
+	var rules []models.SQLFilterRule
+	if err := s.db.Preload("Assets").Where("enabled = ?", true).Order("priority ASC, id ASC").Find(&rules).Error; err != nil {
+		// 加载失败时沿用旧规则，避免数据库抖动导致过滤失效
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.rules, s.regexps
+	}
+
+	regexps := make(map[uint]*regexp.Regexp)
+	for _, rule := range rules {
+		if rule.MatchType == models.SQLMatchRegex {
+			if re, err := regexp.Compile("(?is)" + rule.Pattern); err == nil {
+				regexps[rule.ID] = re
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.rules, s.regexps, s.loadedAt = rules, regexps, time.Now()
+	s.mu.Unlock()
+	return rules, regexps
+}
+
+// invalidate 清除规则缓存
+func (s *SQLFilterService) invalidate() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+// getRule 查询SQL过滤规则
+func (s *SQLFilterService) getRule(id uint) (*models.SQLFilterRule, error) {
+	var rule models.SQLFilterRule
+	if err := s.db.Preload("Assets").Where("id = ?", id).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: sql filter rule %d", utils.ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to query sql filter rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// checkName 检查规则名称是否重复
+func (s *SQLFilterService) checkName(name string, excludeID uint) error {
+	var count int64
+	if err := s.db.Model(&models.SQLFilterRule{}).Where("name = ? AND id != ?", name, excludeID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check sql filter rule name: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: sql filter rule name already exists", utils.ErrDuplicate)
+	}
+	return nil
+}
+
+// loadAssets 加载规则关联的数据库资产
+func (s *SQLFilterService) loadAssets(assetIDs []uint) ([]models.Asset, error) {
+	if len(assetIDs) == 0 {
+		return nil, nil
+	}
+	var assets []models.Asset
+	if err := s.db.Where("id IN ?", assetIDs).Find(&assets).Error; err != nil {
+		return nil, fmt.Errorf("failed to query assets: %w", err)
+	}
+	if len(assets) != len(uniqueIDs(assetIDs)) {
+		return nil, fmt.Errorf("%w: some assets do not exist", utils.ErrInvalidParam)
+	}
+	return assets, nil
+}
+
+// validateSQLFilterPattern 校验规则的匹配内容
+func validateSQLFilterPattern(matchType, pattern string) error {
+	if matchType == models.SQLMatchRegex {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%w: invalid regular expression: %v", utils.ErrInvalidParam, err)
+		}
+		return nil
+	}
+	if len(splitSQLKeywords(pattern)) == 0 {
+		return fmt.Errorf("%w: pattern must list at least one statement type", utils.ErrInvalidParam)
+	}
+	return nil
+}
+
+// sqlRuleAppliesToAsset 规则未关联资产时适用于所有资产
+func sqlRuleAppliesToAsset(rule *models.SQLFilterRule, assetID uint) bool {
+	if len(rule.Assets) == 0 {
+		return true
+	}
+	for _, asset := range rule.Assets {
+		if asset.ID == assetID {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlRuleMatches 判断规则是否命中语句
+func sqlRuleMatches(rule *models.SQLFilterRule, re *regexp.Regexp, stmt sqlStatement) bool {
+	switch rule.MatchType {
+	case models.SQLMatchStatement:
+		return containsString(splitSQLKeywords(rule.Pattern), stmt.Type)
+	case models.SQLMatchNoWhere:
+		return !stmt.HasWhere && containsString(splitSQLKeywords(rule.Pattern), stmt.Type)
+	case models.SQLMatchRegex:
+		return re != nil && re.MatchString(stmt.Text)
+	default:
+		return false
+	}
+}
+
+// splitSQLKeywords 解析逗号分隔的语句类型列表
+func splitSQLKeywords(pattern string) []string {
+	var keywords []string
+	for _, part := range strings.Split(pattern, ",") {
+		if part = strings.ToUpper(strings.TrimSpace(part)); part != "" {
+			keywords = append(keywords, part)
+		}
+	}
+	return keywords
+}
+
+// splitSQLStatements 去除注释并按分号拆分语句，字符串和引用标识符中的内容不参与关键字识别。
+// MySQL的可执行注释 /*! ... */ 中的内容会被执行，按普通语句内容处理；
+// PostgreSQL没有 # 注释和反引号，支持美元符号引用（$tag$...$tag$）和嵌套块注释，
+// 反斜杠只在 E'...' 字符串中转义。
+func splitSQLStatements(protocol, sql string) []sqlStatement {
+	postgres := protocol == models.DatabaseProtocolPostgreSQL
+	var statements []sqlStatement
+	var text, code strings.Builder
+
+	flush := func() {
+		stmt := analyzeSQLStatement(text.String(), code.String())
+		if stmt.Type != "" {
+			statements = append(statements, stmt)
+		}
+		text.Reset()
+		code.Reset()
+	}
+
+	for i := 0; i < len(sql); i++ {
+		ch := sql[i]
+		switch {
+		case ch == '\'' || ch == '"' || (ch == '`' && !postgres):
+			escapes := ch != '`'
+			if postgres {
+				escapes = ch == '\'' && i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !isSQLIdentByte(sql[i-2]))
+			}
+			end := i + 1
+			for end < len(sql) {
+				if sql[end] == '\\' && escapes {
+					end += 2
+					continue
+				}
+				if sql[end] == ch {
+					if end+1 < len(sql) && sql[end+1] == ch {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if end >= len(sql) {
+				end = len(sql) - 1
+			}
+			text.WriteString(sql[i : end+1])
+			code.WriteString(" ? ")
+			i = end
+		case ch == '$' && postgres && (i == 0 || !isSQLIdentByte(sql[i-1])) && sqlDollarTag(sql[i:]) != "":
+			tag := sqlDollarTag(sql[i:])
+			end := len(sql)
+			if idx := strings.Index(sql[i+len(tag):], tag); idx >= 0 {
+				end = i + len(tag) + idx + len(tag)
+			}
+			text.WriteString(sql[i:end])
+			code.WriteString(" ? ")
+			i = end - 1
+		case (ch == '#' && !postgres) || (ch == '-' && strings.HasPrefix(sql[i:], "--") && (postgres || i+2 == len(sql) || sql[i+2] == ' ' || sql[i+2] == '\t' || sql[i+2] == '\n')):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			text.WriteByte(' ')
+			code.WriteByte(' ')
+		case ch == '/' && !postgres && strings.HasPrefix(sql[i:], "/*!"):
+			// 可执行注释：跳过版本号，注释内容按语句处理
+			i += 3
+			for i < len(sql) && sql[i] >= '0' && sql[i] <= '9' {
+				i++
+			}
+			i--
+			text.WriteByte(' ')
+			code.WriteByte(' ')
+		case ch == '*' && !postgres && strings.HasPrefix(sql[i:], "*/"):
+			i++
+			text.WriteByte(' ')
+			code.WriteByte(' ')
+		case ch == '/' && strings.HasPrefix(sql[i:], "/*"):
+			depth := 0
+			for i < len(sql) {
+				if strings.HasPrefix(sql[i:], "/*") && (depth == 0 || postgres) {
+					depth++
+					i += 2
+					continue
+				}
+				if strings.HasPrefix(sql[i:], "*/") {
+					depth--
+					i++
+					if depth == 0 {
+						break
+					}
+				}
+				i++
+			}
+			text.WriteByte(' ')
+			code.WriteByte(' ')
+		case ch == ';':
+			flush()
+		default:
+			text.WriteByte(ch)
+			code.WriteByte(ch)
+		}
+	}
+	flush()
+	return statements
+}
+
+// isSQLIdentByte 判断字节是否可以出现在标识符中
+func isSQLIdentByte(ch byte) bool {
+	return ch == '_' || ch == '$' || ch >= 0x80 || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+// sqlDollarTag 返回文本开头的PostgreSQL美元符号引用标记（如 $$ 或 $body$），不是引用标记时返回空
+func sqlDollarTag(sql string) string {
+	for i := 1; i < len(sql); i++ {
+		ch := sql[i]
+		if ch == '$' {
+			return sql[:i+1]
+		}
+		if !(ch == '_' || ch >= 0x80 || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (i > 1 && ch >= '0' && ch <= '9')) {
+			return ""
+		}
+	}
+	return ""
+}
+
+// analyzeSQLStatement 识别语句类型和是否带WHERE条件
+func analyzeSQLStatement(text, code string) sqlStatement {
+	words := strings.FieldsFunc(strings.ToUpper(code), func(r rune) bool {
+		return !(r == '_' || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'))
+	})
+	stmt := sqlStatement{Text: strings.Join(strings.Fields(text), " ")}
+	if len(words) == 0 {
+		return stmt
+	}
+	stmt.Type = words[0]
+	for _, word := range words[1:] {
+		if word == "WHERE" {
+			stmt.HasWhere = true
+			break
+		}
+	}
+	return stmt
+}
+
+// containsString 判断切片是否包含字符串
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultCertValidity = 5 * time.Minute
+	certClockSkew       = time.Minute // 证书生效时间提前量，容忍目标机时钟偏差
+)
+
+// SSHCAService SSH证书颁发机构服务
+type SSHCAService struct {
+	db           *gorm.DB
+	auditService *AuditService
+	validity     time.Duration
+}
+
+// NewSSHCAService 创建SSH CA服务实例
+func NewSSHCAService(db *gorm.DB) *SSHCAService {
+	validity := defaultCertValidity
+	if config.GlobalConfig != nil && config.GlobalConfig.SSH.CertValidity > 0 {
+		validity = time.Duration(config.GlobalConfig.SSH.CertValidity) * time.Minute
+	}
+	return &SSHCAService{
+		db:           db,
+		auditService: NewAuditService(db),
+		validity:     validity,
+	}
+}
+
+// NewCertSigner 生成一次性密钥对并签发用户证书，用于单个会话登录目标机
+// principal 为目标机登录用户名，keyID 会出现在目标机的sshd日志中
+func (s *SSHCAService) NewCertSigner(principal, keyID string) (ssh.Signer, error) {
+	ca, err := s.activeAuthority()
+	if err != nil {
+		return nil, err
+	}
+	caSigner, err := s.parseSigner(ca)
+	if err != nil {
+		return nil, err
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session key: %w", err)
+	}
+	keySigner, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session signer: %w", err)
+	}
+
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate certificate serial: %w", err)
+	}
+
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             keySigner.PublicKey(),
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           keyID,
+		ValidPrincipals: []string{principal},
+		ValidAfter:      uint64(now.Add(-certClockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(s.validity).Unix()),
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-pty":             "",
+				"permit-port-forwarding": "",
+				"permit-user-rc":         "",
+			},
+		},
+	}
+	if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"serial":    cert.Serial,
+		"key_id":    keyID,
+		"principal": principal,
+		"ca":        ca.Fingerprint,
+	}).Info("签发SSH用户证书")
+
+	return ssh.NewCertSigner(cert, keySigner)
+}
+
+// GetAuthorities 获取所有CA，当前使用的排在最前
+func (s *SSHCAService) GetAuthorities() ([]models.SSHCertificateAuthority, error) {
+	if _, err := s.activeAuthority(); err != nil {
+		return nil, err
+	}
+
+	var authorities []models.SSHCertificateAuthority
+	if err := s.db.Order("id DESC").Find(&authorities).Error; err != nil {
+		return nil, fmt.Errorf("failed to query ssh certificate authorities: %w", err)
+	}
+	return authorities, nil
+}
+
+// TrustedPublicKeys 导出所有未删除CA的公钥，可直接写入目标机 TrustedUserCAKeys 文件
+// 轮换后旧CA的公钥仍然导出，直到管理员删除
+func (s *SSHCAService) TrustedPublicKeys() (string, error) {
+	authorities, err := s.GetAuthorities()
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, len(authorities))
+	for i, ca := range authorities {
+		lines[i] = ca.PublicKey
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// Rotate 生成新的CA并停用当前CA，之后签发的证书都使用新CA
+func (s *SSHCAService) Rotate(operator *models.User, clientIP string) (*models.SSHCertificateAuthority, error) {
+	var previous []string
+	var ca *models.SSHCertificateAuthority
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.SSHCertificateAuthority{}).Where("status = ?", models.SSHCAStatusActive).
+			Pluck("fingerprint", &previous).Error; err != nil {
+			return fmt.Errorf("failed to query ssh certificate authority: %w", err)
+		}
+		if err := tx.Model(&models.SSHCertificateAuthority{}).Where("status = ?", models.SSHCAStatusActive).
+			Updates(map[string]interface{}{"status": models.SSHCAStatusRetired, "retired_at": time.Now()}).Error; err != nil {
+			return fmt.Errorf("failed to retire ssh certificate authority: %w", err)
+		}
+
+		var err error
+		ca, err = s.createAuthority(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordOperation(operator, clientIP, "POST", "/api/v1/ssh-ca/rotate", "rotate", ca.ID, "ssh certificate authority rotated", map[string]interface{}{
+		"fingerprint": ca.Fingerprint,
+		"retired":     previous,
+	})
+	return ca, nil
+}
+
+// DeleteAuthority 删除已轮换的CA，目标机不再需要信任它时使用
+func (s *SSHCAService) DeleteAuthority(id uint, operator *models.User, clientIP string) error {
+	var ca models.SSHCertificateAuthority
+	if err := s.db.First(&ca, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.ErrNotFound
+		}
+		return fmt.Errorf("failed to query ssh certificate authority: %w", err)
+	}
+	if ca.Status == models.SSHCAStatusActive {
+		return fmt.Errorf("%w: the active certificate authority cannot be deleted, rotate it first", utils.ErrInvalidParam)
+	}
+
+	if err := s.db.Delete(&ca).Error; err != nil {
+		return fmt.Errorf("failed to delete ssh certificate authority: %w", err)
+	}
+
+	s.recordOperation(operator, clientIP, "DELETE", fmt.Sprintf("/api/v1/ssh-ca/%d", ca.ID), "delete", ca.ID, "retired ssh certificate authority deleted", map[string]interface{}{
+		"fingerprint": ca.Fingerprint,
+	})
+	return nil
+}
+
+// activeAuthority 获取当前CA，尚未创建时自动生成
+func (s *SSHCAService) activeAuthority() (*models.SSHCertificateAuthority, error) {
+	var ca models.SSHCertificateAuthority
+	err := s.db.Where("status = ?", models.SSHCAStatusActive).Order("id DESC").First(&ca).Error
+	if err == nil {
+		return &ca, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to query ssh certificate authority: %w", err)
+	}
+
+	created, err := s.createAuthority(s.db)
+	if err != nil {
+		return nil, err
+	}
+	s.recordOperation(nil, "", "SYSTEM", "/api/v1/ssh-ca", "create", created.ID, "ssh certificate authority created", map[string]interface{}{
+		"fingerprint": created.Fingerprint,
+	})
+	return created, nil
+}
+
+// createAuthority 生成Ed25519 CA密钥，私钥加密后保存
+func (s *SSHCAService) createAuthority(tx *gorm.DB) (*models.SSHCertificateAuthority, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ca key: %w", err)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ca public key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "bastion-ca")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ca private key: %w", err)
+	}
+	encrypted, err := utils.EncryptPassword(string(pem.EncodeToMemory(block)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt ca private key: %w", err)
+	}
+
+	ca := &models.SSHCertificateAuthority{
+		KeyType:     sshPublicKey.Type(),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPublicKey))) + " bastion-ca",
+		PrivateKey:  encrypted,
+		Fingerprint: ssh.FingerprintSHA256(sshPublicKey),
+		Status:      models.SSHCAStatusActive,
+	}
+	if err := tx.Create(ca).Error; err != nil {
+		return nil, fmt.Errorf("failed to save ssh certificate authority: %w", err)
+	}
+	return ca, nil
+}
+
+// parseSigner 解密CA私钥
+func (s *SSHCAService) parseSigner(ca *models.SSHCertificateAuthority) (ssh.Signer, error) {
+	decrypted, err := utils.DecryptPassword(ca.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt ca private key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(decrypted))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ca private key: %w", err)
+	}
+	return signer, nil
+}
+
+// recordOperation 记录CA变更，自动创建时没有操作人
+func (s *SSHCAService) recordOperation(operator *models.User, clientIP, method, url, action string, caID uint, message string, details map[string]interface{}) {
+	userID, username := uint(0), "system"
+	if operator != nil {
+		userID, username = operator.ID, operator.Username
+	}
+
+	go s.auditService.RecordOperationLog(
+		userID,
+		username,
+		clientIP,
+		method,
+		url,
+		action,
+		"ssh_ca",
+		caID,
+		"",
+		200,
+		message,
+		details,
+		nil,
+		0,
+		false,
+	)
+}
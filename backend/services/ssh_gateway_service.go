@@ -0,0 +1,1133 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"bastion/utils"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// defaultGatewayHostKeyPath 默认的网关主机密钥路径
+const defaultGatewayHostKeyPath = "./data/ssh_gateway_host_key"
+
+// errGatewayAborted 用户在交互菜单中主动退出
+var errGatewayAborted = errors.New("gateway menu aborted")
+
+// SSHGatewayService SSH网关服务
+// 用户使用原生SSH客户端以堡垒机账号登录，通过 ssh user@asset@bastion
+// 或交互菜单选择目标资产，会话复用SSHService的录制、审计、命令过滤与Redis会话跟踪。
+// 指定了目标资产的连接还可以打开sftp子系统进行文件传输，
+// 并可通过 direct-tcpip 通道（ssh -L、ssh -D）转发到授权资产的授权端口。
+type SSHGatewayService struct {
+	db                *gorm.DB
+	sshService        *SSHService
+	auditService      *AuditService
+	authService       *AuthService
+	permissionService *AssetPermissionService
+	keyService        *UserSSHKeyService
+	transferService   *FileTransferService
+	mfaService        *MFAService
+	portForward       *PortForwardService
+	serverConfig      *ssh.ServerConfig
+	listener          net.Listener
+	mu                sync.Mutex
+}
+
+// gatewayTarget 从SSH用户名中解析出的登录目标
+// 支持 user、user@asset、user@account@asset 三种格式
+type gatewayTarget struct {
+	Username string
+	Account  string
+	Asset    string
+}
+
+// gatewayConn 已认证的网关连接
+type gatewayConn struct {
+	user          *models.User
+	target        gatewayTarget
+	clientIP      string
+	clientVersion string
+	tunnels       map[string]*PortTunnel // 资产ID:端口 -> 转发隧道
+	tunnelsMu     sync.Mutex
+}
+
+// gatewaySession 网关连接上的一个交互式会话通道
+type gatewaySession struct {
+	conn      *gatewayConn
+	channel   ssh.Channel
+	cmdBuffer *utils.CircularBuffer
+	mu        sync.Mutex
+	sessionID string
+	width     int
+	height    int
+	started   bool
+	closeOnce sync.Once
+}
+
+// subsystemRequest subsystem 请求负载（RFC 4254 6.5）
+type subsystemRequest struct {
+	Name string
+}
+
+// directTCPIPRequest direct-tcpip 通道负载（RFC 4254 7.2）
+type directTCPIPRequest struct {
+	DestAddr string
+	DestPort uint32
+	OrigAddr string
+	OrigPort uint32
+}
+
+// ptyRequest pty-req 请求负载（RFC 4254 6.2）
+type ptyRequest struct {
+	Term     string
+	Columns  uint32
+	Rows     uint32
+	Width    uint32
+	Height   uint32
+	Modelist string
+}
+
+// windowChangeRequest window-change 请求负载（RFC 4254 6.7）
+type windowChangeRequest struct {
+	Columns uint32
+	Rows    uint32
+	Width   uint32
+	Height  uint32
+}
+
+// NewSSHGatewayService 创建SSH网关服务实例
+func NewSSHGatewayService(db *gorm.DB, sshService *SSHService) *SSHGatewayService {
+	if sshService == nil {
+		sshService = NewSSHService(db)
+	}
+	portForward := GlobalPortForwardService
+	if portForward == nil {
+		portForward = NewPortForwardService(db, sshService)
+	}
+	return &SSHGatewayService{
+		db:                db,
+		sshService:        sshService,
+		auditService:      NewAuditService(db),
+		authService:       NewAuthService(db),
+		permissionService: NewAssetPermissionService(db),
+		keyService:        NewUserSSHKeyService(db),
+		transferService:   NewFileTransferService(db, sshService),
+		mfaService:        NewMFAService(db),
+		portForward:       portForward,
+	}
+}
+
+// Start 启动SSH网关监听
+func (g *SSHGatewayService) Start() error {
+	cfg := config.GlobalConfig.SSHGateway
+
+	hostKeyPath := cfg.HostKeyPath
+	if hostKeyPath == "" {
+		hostKeyPath = defaultGatewayHostKeyPath
+	}
+	hostKey, err := loadOrCreateHostKey(hostKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load host key: %w", err)
+	}
+
+	serverConfig := &ssh.ServerConfig{
+		MaxAuthTries:      cfg.MaxAuthTries,
+		PublicKeyCallback: g.publicKeyCallback,
+		ServerVersion:     "SSH-2.0-Bastion",
+	}
+	if cfg.PasswordAuth {
+		serverConfig.PasswordCallback = g.passwordCallback
+		serverConfig.KeyboardInteractiveCallback = g.keyboardInteractiveCallback
+	}
+	if cfg.Banner != "" {
+		banner := cfg.Banner
+		serverConfig.BannerCallback = func(conn ssh.ConnMetadata) string {
+			return banner + "\r\n"
+		}
+	}
+	serverConfig.AddHostKey(hostKey)
+	g.serverConfig = serverConfig
+
+	listener, err := net.Listen("tcp", cfg.GetListenAddr())
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", cfg.GetListenAddr(), err)
+	}
+
+	g.mu.Lock()
+	g.listener = listener
+	g.mu.Unlock()
+
+	logrus.WithFields(logrus.Fields{
+		"addr":        cfg.GetListenAddr(),
+		"fingerprint": ssh.FingerprintSHA256(hostKey.PublicKey()),
+	}).Info("SSH网关已启动")
+
+	go g.acceptLoop(listener)
+	return nil
+}
+
+// Stop 停止SSH网关监听
+func (g *SSHGatewayService) Stop() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.listener == nil {
+		return nil
+	}
+	err := g.listener.Close()
+	g.listener = nil
+	return err
+}
+
+// acceptLoop 接受新的TCP连接
+func (g *SSHGatewayService) acceptLoop(listener net.Listener) {
+	for {
+		netConn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				logrus.Info("SSH网关已停止监听")
+				return
+			}
+			logrus.WithError(err).Warn("SSH网关接受连接失败")
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		go g.handleConnection(netConn)
+	}
+}
+
+// handleConnection 完成SSH握手并处理连接上的通道
+func (g *SSHGatewayService) handleConnection(netConn net.Conn) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithField("panic", r).Error("SSH网关连接处理异常")
+		}
+	}()
+
+	sshConn, chans, reqs, err := ssh.NewServerConn(netConn, g.serverConfig)
+	if err != nil {
+		logrus.WithError(err).WithField("remote", netConn.RemoteAddr().String()).Debug("SSH网关握手失败")
+		netConn.Close()
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	userID, _ := strconv.ParseUint(sshConn.Permissions.Extensions["user_id"], 10, 32)
+	var user models.User
+	if err := g.db.Preload("Roles").Where("id = ?", userID).First(&user).Error; err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("SSH网关加载用户失败")
+		return
+	}
+
+	// 仅凭公钥登录的连接在握手完成、确认持有私钥后才校验账号，未通过时断开连接
+	authMethod := sshConn.Permissions.Extensions["auth_method"]
+	if authMethod == "publickey" {
+		if err := g.checkPublicKeyLogin(sshConn, &user); err != nil {
+			logrus.WithError(err).WithField("username", user.Username).Warn("SSH网关公钥登录校验未通过")
+			return
+		}
+		if user.RequiresMFA() {
+			g.recordLoginFailure(sshConn, user.Username, "mfa enrollment required, please login via web first")
+			return
+		}
+	}
+
+	gc := &gatewayConn{
+		user:          &user,
+		target:        parseGatewayUser(sshConn.User()),
+		clientIP:      remoteIP(sshConn.RemoteAddr()),
+		clientVersion: string(sshConn.ClientVersion()),
+		tunnels:       make(map[string]*PortTunnel),
+	}
+
+	// 记录登录成功
+	if keyID, err := strconv.ParseUint(sshConn.Permissions.Extensions["key_id"], 10, 32); err == nil {
+		go g.keyService.TouchKey(uint(keyID))
+	}
+	go g.auditService.RecordLoginLog(user.ID, user.Username, gc.clientIP, gc.clientVersion, "ssh", "success",
+		fmt.Sprintf("SSH gateway login successful (%s)", authMethod))
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			go g.handleDirectTCPIP(gc, newChannel)
+			continue
+		}
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			logrus.WithError(err).Warn("SSH网关接受会话通道失败")
+			continue
+		}
+		go g.handleSession(gc, channel, requests)
+	}
+
+	g.closeTunnels(gc, "用户断开SSH连接")
+}
+
+// passwordCallback 使用堡垒机账号密码认证
+// 需要MFA的用户不能只用密码登录，须使用keyboard-interactive输入验证码
+func (g *SSHGatewayService) passwordCallback(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	user, err := g.checkPassword(conn, string(password))
+	if err != nil {
+		return nil, err
+	}
+
+	mfaEnabled, err := g.mfaService.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfaEnabled || user.RequiresMFA() {
+		g.recordLoginFailure(conn, user.Username, "mfa required, use keyboard-interactive authentication")
+		return nil, errors.New("mfa required")
+	}
+
+	return gatewayPermissions(user, "password"), nil
+}
+
+// keyboardInteractiveCallback 依次提示输入密码和MFA验证码
+func (g *SSHGatewayService) keyboardInteractiveCallback(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+	answers, err := client("", "", []string{"Password: "}, []bool{false})
+	if err != nil || len(answers) != 1 {
+		return nil, errors.New("password is required")
+	}
+	user, err := g.checkPassword(conn, answers[0])
+	if err != nil {
+		return nil, err
+	}
+
+	mfaEnabled, err := g.mfaService.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !mfaEnabled {
+		if user.RequiresMFA() {
+			g.recordLoginFailure(conn, user.Username, "mfa enrollment required, please login via web first")
+			return nil, errors.New("mfa enrollment required")
+		}
+		return gatewayPermissions(user, "password"), nil
+	}
+
+	if err := g.verifyTOTP(conn, client, user); err != nil {
+		return nil, err
+	}
+
+	return gatewayPermissions(user, "password+totp"), nil
+}
+
+// verifyTOTP 通过keyboard-interactive提示输入MFA验证码（支持恢复码）
+func (g *SSHGatewayService) verifyTOTP(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge, user *models.User) error {
+	answers, err := client("", "", []string{"Verification code: "}, []bool{true})
+	if err != nil || len(answers) != 1 {
+		return errors.New("verification code is required")
+	}
+	usedRecoveryCode, err := g.mfaService.Verify(user.ID, answers[0])
+	if err != nil {
+		go g.auditService.RecordLoginLog(user.ID, user.Username, remoteIP(conn.RemoteAddr()), string(conn.ClientVersion()),
+			"ssh", models.LoginStatusMFAFailed, err.Error())
+		return errors.New("invalid verification code")
+	}
+	if usedRecoveryCode {
+		go g.auditService.RecordLoginLog(user.ID, user.Username, remoteIP(conn.RemoteAddr()), string(conn.ClientVersion()),
+			"ssh", models.LoginStatusMFARecovery, "MFA verification successful with recovery code")
+	}
+	return nil
+}
+
+// checkPassword 通过认证后端校验堡垒机账号密码（支持本地与LDAP账号），失败次数过多时锁定
+func (g *SSHGatewayService) checkPassword(conn ssh.ConnMetadata, password string) (*models.User, error) {
+	target := parseGatewayUser(conn.User())
+
+	user, err := g.authService.CheckCredentials(target.Username, password, remoteIP(conn.RemoteAddr()))
+	if err != nil {
+		go g.auditService.RecordLoginLog(0, target.Username, remoteIP(conn.RemoteAddr()), string(conn.ClientVersion()), "ssh", LoginFailureStatus(err), err.Error())
+		return nil, err
+	}
+
+	if err := g.checkPasswordExpiry(conn, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// checkPasswordExpiry 密码过期的用户只能在Web端修改密码后再登录网关（公钥登录同样适用）
+func (g *SSHGatewayService) checkPasswordExpiry(conn ssh.ConnMetadata, user *models.User) error {
+	expired, err := g.authService.IsPasswordExpired(user)
+	if err != nil {
+		return err
+	}
+	if expired {
+		go g.auditService.RecordLoginLog(user.ID, user.Username, remoteIP(conn.RemoteAddr()), string(conn.ClientVersion()),
+			"ssh", "failed", "password expired")
+		return errors.New("password expired, please change it on the web console")
+	}
+	return nil
+}
+
+// gatewayPermissions 认证通过后传递给连接处理的用户信息
+func gatewayPermissions(user *models.User, authMethod string) *ssh.Permissions {
+	return &ssh.Permissions{
+		Extensions: map[string]string{
+			"user_id":     strconv.FormatUint(uint64(user.ID), 10),
+			"auth_method": authMethod,
+		},
+	}
+}
+
+// publicKeyCallback 使用用户登记的公钥认证
+// 客户端查询公钥是否可用时也会调用该回调，此时尚未证明持有私钥，因此这里只匹配公钥，
+// 账号状态、登录策略等在认证完成后由 checkPublicKeyLogin 校验并记录登录日志
+// 已启用MFA的用户公钥校验通过后只算部分成功，还须通过keyboard-interactive输入验证码
+func (g *SSHGatewayService) publicKeyCallback(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	target := parseGatewayUser(conn.User())
+
+	user, userKey, err := g.keyService.FindUserByPublicKey(target.Username, key)
+	if err != nil {
+		// 客户端会依次尝试多个公钥，未匹配的公钥不记录登录失败
+		return nil, errors.New("public key not authorized")
+	}
+
+	keyID := strconv.FormatUint(uint64(userKey.ID), 10)
+	mfaEnabled, err := g.mfaService.IsEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !mfaEnabled {
+		permissions := gatewayPermissions(user, "publickey")
+		permissions.Extensions["key_id"] = keyID
+		return permissions, nil
+	}
+
+	return nil, &ssh.PartialSuccessError{
+		Next: ssh.ServerAuthCallbacks{
+			KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+				// 进入第二步时客户端已用私钥签名
+				if err := g.checkPublicKeyLogin(conn, user); err != nil {
+					return nil, err
+				}
+				if err := g.verifyTOTP(conn, client, user); err != nil {
+					return nil, err
+				}
+				permissions := gatewayPermissions(user, "publickey+totp")
+				permissions.Extensions["key_id"] = keyID
+				return permissions, nil
+			},
+		},
+	}
+}
+
+// checkPublicKeyLogin 公钥认证完成后校验账号状态、登录策略和密码有效期，失败时记录登录日志
+func (g *SSHGatewayService) checkPublicKeyLogin(conn ssh.ConnMetadata, user *models.User) error {
+	if !user.IsActive() {
+		g.recordLoginFailure(conn, user.Username, "user account is disabled")
+		return errors.New("user account is disabled")
+	}
+
+	if err := g.authService.CheckLoginPolicy(user, remoteIP(conn.RemoteAddr())); err != nil {
+		go g.auditService.RecordLoginLog(user.ID, user.Username, remoteIP(conn.RemoteAddr()), string(conn.ClientVersion()),
+			"ssh", LoginFailureStatus(err), err.Error())
+		return err
+	}
+	return g.checkPasswordExpiry(conn, user)
+}
+
+// gatewaySessionErrorMessage 创建会话失败时提示给网关用户的信息
+func gatewaySessionErrorMessage(err error) string {
+	var violation *AccessPolicyViolation
+	if errors.As(err, &violation) {
+		return violation.Error()
+	}
+	var hostKeyErr *HostKeyError
+	if errors.As(err, &hostKeyErr) {
+		return hostKeyErr.Error()
+	}
+	if errors.Is(err, utils.ErrPermissionDenied) {
+		return "没有使用该凭证访问此资产的权限"
+	}
+	return "连接资产失败"
+}
+
+// recordLoginFailure 记录SSH网关登录失败日志
+func (g *SSHGatewayService) recordLoginFailure(conn ssh.ConnMetadata, username, message string) {
+	go g.auditService.RecordLoginLog(0, username, remoteIP(conn.RemoteAddr()), string(conn.ClientVersion()), "ssh", "failed", message)
+}
+
+// handleSession 处理会话通道上的请求
+func (g *SSHGatewayService) handleSession(gc *gatewayConn, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+
+	gs := &gatewaySession{
+		conn:      gc,
+		channel:   channel,
+		cmdBuffer: utils.NewCircularBuffer(4096),
+		width:     80,
+		height:    24,
+	}
+
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			var pty ptyRequest
+			if err := ssh.Unmarshal(req.Payload, &pty); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			gs.resize(g.sshService, int(pty.Columns), int(pty.Rows))
+			req.Reply(true, nil)
+		case "window-change":
+			var win windowChangeRequest
+			if err := ssh.Unmarshal(req.Payload, &win); err == nil {
+				gs.resize(g.sshService, int(win.Columns), int(win.Rows))
+			}
+			req.Reply(true, nil)
+		case "env":
+			req.Reply(true, nil)
+		case "shell":
+			if !gs.markStarted() {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go g.runShell(gs)
+		case "subsystem":
+			var sub subsystemRequest
+			if err := ssh.Unmarshal(req.Payload, &sub); err != nil || sub.Name != "sftp" || !gs.markStarted() {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go g.runSFTP(gs)
+		case "exec":
+			req.Reply(false, nil)
+			fmt.Fprint(channel.Stderr(), "堡垒机仅支持交互式会话，请直接登录或使用 ssh -t；文件传输请使用 sftp 或 scp -s\r\n")
+			sendExitStatus(channel, 1)
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+
+	// 请求通道关闭表示客户端已断开
+	g.closeSession(gs, "用户断开SSH连接")
+}
+
+// runShell 选择目标资产并在客户端与资产会话之间转发数据
+func (g *SSHGatewayService) runShell(gs *gatewaySession) {
+	channel := gs.channel
+	defer channel.Close()
+
+	asset, credential, err := g.resolveTarget(gs, true)
+	if err != nil {
+		if !errors.Is(err, errGatewayAborted) {
+			fmt.Fprintf(channel, "\r\n\033[31m%s\033[0m\r\n", err.Error())
+		}
+		sendExitStatus(channel, 1)
+		return
+	}
+
+	width, height := gs.size()
+	resp, err := g.sshService.CreateSession(gs.conn.user.ID, &SSHSessionRequest{
+		AssetID:      asset.ID,
+		CredentialID: credential.ID,
+		Protocol:     "ssh",
+		Width:        width,
+		Height:       height,
+		ClientIP:     gs.conn.clientIP,
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":  gs.conn.user.ID,
+			"asset_id": asset.ID,
+		}).Warn("SSH网关创建会话失败")
+		message := gatewaySessionErrorMessage(err)
+		fmt.Fprintf(channel, "\r\n\033[31m%s\033[0m\r\n", message)
+		sendExitStatus(channel, 1)
+		return
+	}
+
+	gs.mu.Lock()
+	gs.sessionID = resp.ID
+	gs.mu.Unlock()
+
+	fmt.Fprintf(channel, "已连接到 %s (%s)，账号 %s\r\n", resp.AssetName, resp.AssetAddr, resp.Username)
+
+	go g.pumpInput(gs)
+	g.pumpOutput(gs)
+
+	g.closeSession(gs, "用户正常退出")
+	sendExitStatus(channel, 0)
+}
+
+// runSFTP 在资产上打开SFTP会话并代理客户端的文件操作
+// SFTP通道无法进行交互选择，目标资产与账号需在登录用户名中指定
+func (g *SSHGatewayService) runSFTP(gs *gatewaySession) {
+	channel := gs.channel
+	defer channel.Close()
+
+	asset, credential, err := g.resolveTarget(gs, false)
+	if err != nil {
+		fmt.Fprintf(channel.Stderr(), "%s\r\n", err.Error())
+		sendExitStatus(channel, 1)
+		return
+	}
+
+	resp, err := g.sshService.CreateFileSession(gs.conn.user.ID, &SSHSessionRequest{
+		AssetID:      asset.ID,
+		CredentialID: credential.ID,
+		Protocol:     "sftp",
+		ClientIP:     gs.conn.clientIP,
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":  gs.conn.user.ID,
+			"asset_id": asset.ID,
+		}).Warn("SSH网关创建SFTP会话失败")
+		message := gatewaySessionErrorMessage(err)
+		fmt.Fprintf(channel.Stderr(), "%s\r\n", message)
+		sendExitStatus(channel, 1)
+		return
+	}
+
+	gs.mu.Lock()
+	gs.sessionID = resp.ID
+	gs.mu.Unlock()
+
+	if err := g.transferService.ServeSFTP(resp.ID, channel); err != nil {
+		logrus.WithError(err).WithField("session_id", resp.ID).Warn("SSH网关SFTP会话异常结束")
+		g.closeSession(gs, "SFTP会话异常结束")
+		sendExitStatus(channel, 1)
+		return
+	}
+
+	g.closeSession(gs, "用户正常退出")
+	sendExitStatus(channel, 0)
+}
+
+// pumpOutput 将资产会话输出转发给客户端，并写入录制与监控
+func (g *SSHGatewayService) pumpOutput(gs *gatewaySession) {
+	reader, err := g.sshService.ReadFromSession(gs.sessionID)
+	if err != nil {
+		logrus.WithError(err).WithField("session_id", gs.sessionID).Error("SSH网关获取会话输出失败")
+		return
+	}
+
+	buffer := make([]byte, 4096)
+	for {
+		n, err := reader.Read(buffer)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buffer[:n])
+
+			if _, werr := gs.channel.Write(data); werr != nil {
+				return
+			}
+			recordSessionData(gs.sessionID, "output", data)
+
+			if GlobalWebSocketService != nil {
+				GlobalWebSocketService.BroadcastToMonitorClients(WSMessage{
+					Type: "terminal_output",
+					Data: map[string]interface{}{
+						"session_id": gs.sessionID,
+						"output":     string(data),
+						"timestamp":  time.Now(),
+					},
+					Timestamp: time.Now(),
+					SessionID: gs.sessionID,
+				})
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				logrus.WithError(err).WithField("session_id", gs.sessionID).Debug("SSH网关读取会话输出结束")
+			}
+			return
+		}
+	}
+}
+
+// pumpInput 读取客户端输入，经命令过滤后写入资产会话
+func (g *SSHGatewayService) pumpInput(gs *gatewaySession) {
+	buffer := make([]byte, 4096)
+	for {
+		n, err := gs.channel.Read(buffer)
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buffer[:n])
+
+			recordSessionData(gs.sessionID, "input", data)
+			if werr := g.forwardInput(gs, data); werr != nil {
+				logrus.WithError(werr).WithField("session_id", gs.sessionID).Debug("SSH网关写入会话失败")
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// forwardInput 维护命令缓冲区，在回车时按命令过滤规则检查整行命令
+// 与Web终端保持一致：被禁止的命令不发送回车，并向资产发送 Ctrl+C 清除当前输入
+func (g *SSHGatewayService) forwardInput(gs *gatewaySession, data []byte) error {
+	pending := make([]byte, 0, len(data))
+	for _, b := range data {
+		switch {
+		case b == '\r' || b == '\n':
+			command := gs.cmdBuffer.String()
+			gs.cmdBuffer.Clear()
+			if command != "" && !g.sshService.CheckCommand(gs.sessionID, command) {
+				if len(pending) > 0 {
+					if err := g.sshService.WriteToSession(gs.sessionID, pending); err != nil {
+						return err
+					}
+					pending = pending[:0]
+				}
+				if err := g.sshService.WriteToSession(gs.sessionID, []byte{0x03}); err != nil {
+					return err
+				}
+				fmt.Fprintf(gs.channel, "\r\n\033[31m命令 `%s` 是被禁止的 ...\033[0m\r\n", command)
+				logrus.WithFields(logrus.Fields{
+					"session_id": gs.sessionID,
+					"user_id":    gs.conn.user.ID,
+					"command":    command,
+				}).Info("SSH网关拦截命令")
+				continue
+			}
+		case b == '\b' || b == 0x7f:
+			gs.cmdBuffer.RemoveLast()
+		case b < 32 && b != '\t':
+			// 其他控制字符（如 Ctrl+C）清空缓冲区
+			gs.cmdBuffer.Clear()
+		default:
+			gs.cmdBuffer.Write([]byte{b})
+		}
+		pending = append(pending, b)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+	return g.sshService.WriteToSession(gs.sessionID, pending)
+}
+
+// closeSession 关闭网关会话对应的资产会话
+func (g *SSHGatewayService) closeSession(gs *gatewaySession, reason string) {
+	gs.closeOnce.Do(func() {
+		gs.mu.Lock()
+		sessionID := gs.sessionID
+		gs.mu.Unlock()
+		if sessionID == "" {
+			return
+		}
+		// 资产端已退出时会话已被SSHService清理，无需重复关闭
+		if _, err := g.sshService.GetSession(sessionID); err != nil {
+			return
+		}
+		if err := g.sshService.CloseSessionWithReason(sessionID, reason); err != nil {
+			logrus.WithError(err).WithField("session_id", sessionID).Warn("SSH网关关闭会话失败")
+		}
+	})
+}
+
+// ======================== 端口转发 ========================
+
+// handleDirectTCPIP 处理端口转发通道，ssh -L 本地转发与 ssh -D 动态转发均以 direct-tcpip 通道到达
+func (g *SSHGatewayService) handleDirectTCPIP(gc *gatewayConn, newChannel ssh.NewChannel) {
+	var req directTCPIPRequest
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &req); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid direct-tcpip request")
+		return
+	}
+	if !g.portForward.Enabled() {
+		newChannel.Reject(ssh.Prohibited, "堡垒机未开启端口转发")
+		return
+	}
+
+	origin := net.JoinHostPort(req.OrigAddr, strconv.Itoa(int(req.OrigPort)))
+	tunnel, err := g.gatewayTunnel(gc, req.DestAddr, int(req.DestPort))
+	if err != nil {
+		g.portForward.RecordDenied(gc.user, gc.clientIP, req.DestAddr, int(req.DestPort), origin, err)
+		newChannel.Reject(ssh.Prohibited, gatewayTunnelErrorMessage(err))
+		return
+	}
+
+	accepted := false
+	err = g.portForward.ServeChannel(tunnel, origin, func() (io.ReadWriteCloser, error) {
+		accepted = true
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return nil, err
+		}
+		go ssh.DiscardRequests(requests)
+		return channel, nil
+	})
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{
+			"user_id":   gc.user.ID,
+			"tunnel_id": tunnel.ID,
+		}).Debug("SSH网关端口转发失败")
+		if !accepted {
+			newChannel.Reject(ssh.ConnectionFailed, "连接目标端口失败")
+		}
+	}
+}
+
+// gatewayTunnel 获取连接上到目标资产端口的隧道，首次转发时创建
+func (g *SSHGatewayService) gatewayTunnel(gc *gatewayConn, host string, port int) (*PortTunnel, error) {
+	asset, err := g.portForward.ResolveDestination(gc.user, gc.clientIP, host, port)
+	if err != nil {
+		return nil, err
+	}
+
+	gc.tunnelsMu.Lock()
+	defer gc.tunnelsMu.Unlock()
+
+	key := fmt.Sprintf("%d:%d", asset.ID, port)
+	if tunnel, exists := gc.tunnels[key]; exists {
+		if tunnel.IsClosed() {
+			return nil, fmt.Errorf("%w: tunnel %s has been terminated", utils.ErrPermissionDenied, tunnel.ID)
+		}
+		return tunnel, nil
+	}
+	tunnel, err := g.portForward.OpenTunnel(models.TunnelTypeSSH, gc.user, gc.clientIP, asset, port)
+	if err != nil {
+		return nil, err
+	}
+	gc.tunnels[key] = tunnel
+	return tunnel, nil
+}
+
+// closeTunnels 关闭连接上的所有转发隧道
+func (g *SSHGatewayService) closeTunnels(gc *gatewayConn, reason string) {
+	gc.tunnelsMu.Lock()
+	defer gc.tunnelsMu.Unlock()
+
+	for key, tunnel := range gc.tunnels {
+		g.portForward.CloseTunnel(tunnel.ID, reason)
+		delete(gc.tunnels, key)
+	}
+}
+
+// gatewayTunnelErrorMessage 拒绝转发通道时提示给网关用户的信息
+func gatewayTunnelErrorMessage(err error) string {
+	var violation *AccessPolicyViolation
+	if errors.As(err, &violation) {
+		return violation.Error()
+	}
+	if errors.Is(err, utils.ErrPermissionDenied) {
+		return "没有转发到该目标端口的权限"
+	}
+	return "端口转发失败"
+}
+
+// ======================== 目标选择 ========================
+
+// resolveTarget 根据登录用户名或交互菜单确定目标资产与凭证
+// interactive 为 false 时不显示菜单，目标不明确直接返回错误
+func (g *SSHGatewayService) resolveTarget(gs *gatewaySession, interactive bool) (*models.Asset, *models.Credential, error) {
+	userID := gs.conn.user.ID
+	target := gs.conn.target
+
+	assets, err := g.authorizedAssets(userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取可访问资产失败")
+	}
+	if len(assets) == 0 {
+		return nil, nil, fmt.Errorf("当前账号没有可访问的SSH资产")
+	}
+
+	var asset *models.Asset
+	if target.Asset != "" {
+		asset, err = findGatewayAsset(assets, target.Asset)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if !interactive {
+		return nil, nil, fmt.Errorf("请在用户名中指定目标资产，例如 sftp -P <端口> %s@<资产>@<堡垒机>", gs.conn.user.Username)
+	} else {
+		fmt.Fprintf(gs.channel, "\r\n%s，请选择要登录的资产：\r\n", gs.conn.user.Username)
+		for i, a := range assets {
+			fmt.Fprintf(gs.channel, "  [%d] %-24s %s:%d\r\n", i+1, a.Name, a.Address, a.Port)
+		}
+		index, err := promptChoice(gs.channel, "资产编号 (q 退出): ", len(assets))
+		if err != nil {
+			return nil, nil, err
+		}
+		asset = &assets[index]
+	}
+
+	credentials, err := g.authorizedCredentials(userID, asset.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取可用凭证失败")
+	}
+	if target.Account != "" {
+		filtered := credentials[:0]
+		for _, c := range credentials {
+			if c.Username == target.Account {
+				filtered = append(filtered, c)
+			}
+		}
+		credentials = filtered
+	}
+	if len(credentials) == 0 {
+		return nil, nil, fmt.Errorf("资产 %s 没有可用的登录账号", asset.Name)
+	}
+	if len(credentials) == 1 {
+		return asset, &credentials[0], nil
+	}
+	if !interactive {
+		return nil, nil, fmt.Errorf("资产 %s 有多个可用账号，请使用 %s@<账号>@%s 指定", asset.Name, gs.conn.user.Username, target.Asset)
+	}
+
+	fmt.Fprintf(gs.channel, "\r\n请选择登录 %s 使用的账号：\r\n", asset.Name)
+	for i, c := range credentials {
+		fmt.Fprintf(gs.channel, "  [%d] %-24s %s\r\n", i+1, c.Username, c.Name)
+	}
+	index, err := promptChoice(gs.channel, "账号编号 (q 退出): ", len(credentials))
+	if err != nil {
+		return nil, nil, err
+	}
+	return asset, &credentials[index], nil
+}
+
+// authorizedAssets 获取用户可通过SSH访问的资产
+func (g *SSHGatewayService) authorizedAssets(userID uint) ([]models.Asset, error) {
+	assetIDs, all, err := g.permissionService.GetAuthorizedAssetIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := g.db.Where("protocol = ?", "ssh").Order("name ASC")
+	if !all {
+		if len(assetIDs) == 0 {
+			return nil, nil
+		}
+		query = query.Where("id IN ?", assetIDs)
+	}
+
+	var assets []models.Asset
+	if err := query.Find(&assets).Error; err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// authorizedCredentials 获取用户在资产上可使用的凭证
+func (g *SSHGatewayService) authorizedCredentials(userID, assetID uint) ([]models.Credential, error) {
+	credentialIDs, all, err := g.permissionService.GetAuthorizedCredentialIDs(userID, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := g.db.Joins("JOIN asset_credentials ON asset_credentials.credential_id = credentials.id").
+		Where("asset_credentials.asset_id = ?", assetID).
+		Order("credentials.id ASC")
+	if !all {
+		if len(credentialIDs) == 0 {
+			return nil, nil
+		}
+		query = query.Where("credentials.id IN ?", credentialIDs)
+	}
+
+	var credentials []models.Credential
+	if err := query.Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// findGatewayAsset 按名称、地址或ID在授权资产中查找目标
+func findGatewayAsset(assets []models.Asset, name string) (*models.Asset, error) {
+	var matched []int
+	for i, a := range assets {
+		if a.Name == name || a.Address == name || strconv.FormatUint(uint64(a.ID), 10) == name {
+			matched = append(matched, i)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("资产 %s 不存在或未授权", name)
+	case 1:
+		return &assets[matched[0]], nil
+	default:
+		return nil, fmt.Errorf("存在多个名为 %s 的资产，请使用资产ID登录", name)
+	}
+}
+
+// ======================== 工具函数 ========================
+
+// parseGatewayUser 解析SSH登录用户名
+func parseGatewayUser(raw string) gatewayTarget {
+	parts := strings.Split(raw, "@")
+	switch len(parts) {
+	case 1:
+		return gatewayTarget{Username: parts[0]}
+	case 2:
+		return gatewayTarget{Username: parts[0], Asset: parts[1]}
+	default:
+		return gatewayTarget{Username: parts[0], Account: parts[1], Asset: strings.Join(parts[2:], "@")}
+	}
+}
+
+// promptChoice 提示用户输入编号，返回从0开始的索引
+func promptChoice(rw io.ReadWriter, prompt string, count int) (int, error) {
+	for {
+		line, err := readLine(rw, prompt)
+		if err != nil {
+			return 0, errGatewayAborted
+		}
+		line = strings.TrimSpace(line)
+		if line == "q" || line == "quit" || line == "exit" {
+			return 0, errGatewayAborted
+		}
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= count {
+			return n - 1, nil
+		}
+		fmt.Fprintf(rw, "无效的编号，请输入 1-%d\r\n", count)
+	}
+}
+
+// readLine 从交互式终端读取一行输入（支持退格，Ctrl+C/Ctrl+D 退出）
+func readLine(rw io.ReadWriter, prompt string) (string, error) {
+	if _, err := fmt.Fprint(rw, prompt); err != nil {
+		return "", err
+	}
+
+	line := make([]byte, 0, 64)
+	buf := make([]byte, 1)
+	for {
+		if _, err := rw.Read(buf); err != nil {
+			return "", err
+		}
+		switch b := buf[0]; {
+		case b == '\r' || b == '\n':
+			fmt.Fprint(rw, "\r\n")
+			return string(line), nil
+		case b == 0x03 || b == 0x04:
+			fmt.Fprint(rw, "\r\n")
+			return "", io.EOF
+		case b == '\b' || b == 0x7f:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				fmt.Fprint(rw, "\b \b")
+			}
+		case b >= 32 && len(line) < 256:
+			line = append(line, b)
+			rw.Write(buf)
+		}
+	}
+}
+
+// markStarted 标记通道已启动shell或子系统，重复启动时返回false
+func (gs *gatewaySession) markStarted() bool {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if gs.started {
+		return false
+	}
+	gs.started = true
+	return true
+}
+
+// size 获取当前终端尺寸
+func (gs *gatewaySession) size() (int, int) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	return gs.width, gs.height
+}
+
+// resize 更新终端尺寸，会话已建立时同步到资产端
+func (gs *gatewaySession) resize(sshService *SSHService, width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	gs.mu.Lock()
+	gs.width = width
+	gs.height = height
+	sessionID := gs.sessionID
+	gs.mu.Unlock()
+
+	if sessionID != "" {
+		if err := sshService.ResizeSession(sessionID, width, height); err != nil {
+			logrus.WithError(err).WithField("session_id", sessionID).Debug("SSH网关调整窗口大小失败")
+		}
+	}
+}
+
+// recordSessionData 将会话数据写入录制
+func recordSessionData(sessionID, recordType string, data []byte) {
+	if GlobalRecordingService == nil {
+		return
+	}
+	if recorder, exists := GlobalRecordingService.GetRecorder(sessionID); exists {
+		recorder.WriteRecord(&WSRecord{
+			Timestamp: time.Now(),
+			Type:      recordType,
+			Data:      data,
+			Size:      len(data),
+		})
+	}
+}
+
+// sendExitStatus 向客户端发送退出状态
+func sendExitStatus(channel ssh.Channel, status uint32) {
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+}
+
+// remoteIP 提取远端地址中的IP
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// loadOrCreateHostKey 加载网关主机密钥，不存在时生成ed25519密钥
+func loadOrCreateHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate host key: %w", err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "bastion-ssh-gateway")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal host key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create host key directory: %w", err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write host key: %w", err)
+	}
+	logrus.WithField("path", path).Info("已生成SSH网关主机密钥")
+
+	return ssh.NewSignerFromKey(privateKey)
+}
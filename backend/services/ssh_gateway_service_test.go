@@ -0,0 +1,117 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// queryOnlySigner 只持有公钥的客户端：可以查询公钥是否可用，但无法签名
+type queryOnlySigner struct {
+	ssh.Signer
+}
+
+func (s queryOnlySigner) Sign(io.Reader, []byte) (*ssh.Signature, error) {
+	return nil, errors.New("private key not available")
+}
+
+// setupGatewayKeyLogin 创建只允许公钥登录的网关，alice 登记了返回的公钥
+func setupGatewayKeyLogin(t *testing.T) (*SSHGatewayService, *gorm.DB, *models.User, ssh.Signer) {
+	t.Helper()
+	setupTestConfig(t)
+	config.GlobalConfig.Audit.EnableOperationLog = true
+	db := newTestDB(t, &models.User{}, &models.Role{}, &models.Permission{}, &models.UserRole{}, &models.RolePermission{},
+		&models.UserSSHKey{}, &models.UserMFA{}, &models.AccessPolicy{}, &models.NetworkZone{}, &models.PasswordPolicy{}, &models.LoginLog{})
+
+	user := &models.User{Username: "alice", Password: "hash", Status: 1, AuthSource: models.AuthSourceLocal}
+	require.NoError(t, db.Create(user).Error)
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&models.UserSSHKey{UserID: user.ID, Name: "laptop", KeyType: signer.PublicKey().Type(),
+		PublicKey: string(ssh.MarshalAuthorizedKey(signer.PublicKey())), Fingerprint: ssh.FingerprintSHA256(signer.PublicKey())}).Error)
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	require.NoError(t, err)
+
+	gateway := NewSSHGatewayService(db, &SSHService{db: db})
+	gateway.serverConfig = &ssh.ServerConfig{PublicKeyCallback: gateway.publicKeyCallback}
+	gateway.serverConfig.AddHostKey(hostSigner)
+	return gateway, db, user, signer
+}
+
+// dialGateway 使用指定公钥登录网关，返回握手错误
+func dialGateway(t *testing.T, gateway *SSHGatewayService, signer ssh.Signer) error {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		if serverConn, err := listener.Accept(); err == nil {
+			gateway.handleConnection(serverConn)
+		}
+	}()
+
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	conn, _, _, err := ssh.NewClientConn(clientConn, listener.Addr().String(), &ssh.ClientConfig{
+		User:            "alice",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		clientConn.Close()
+		return err
+	}
+	conn.Close()
+	return nil
+}
+
+// loginLogs 等待异步写入的登录日志
+func loginLogs(t *testing.T, db *gorm.DB, count int) []models.LoginLog {
+	t.Helper()
+	var logs []models.LoginLog
+	require.Eventually(t, func() bool {
+		logs = nil
+		db.Order("id").Find(&logs)
+		return len(logs) == count
+	}, 5*time.Second, 20*time.Millisecond)
+	return logs
+}
+
+func TestGatewayPublicKeyQueryWritesNoLoginLog(t *testing.T) {
+	gateway, db, user, signer := setupGatewayKeyLogin(t)
+	require.NoError(t, db.Model(user).Update("status", 0).Error)
+
+	// 只知道公钥的客户端查询公钥后无法签名，不产生该账号的登录日志
+	require.Error(t, dialGateway(t, gateway, queryOnlySigner{signer}))
+	time.Sleep(100 * time.Millisecond)
+	var count int64
+	require.NoError(t, db.Model(&models.LoginLog{}).Count(&count).Error)
+	require.Zero(t, count)
+
+	// 持有私钥的客户端完成认证后才校验账号状态
+	require.NoError(t, dialGateway(t, gateway, signer))
+	logs := loginLogs(t, db, 1)
+	require.Equal(t, "failed", logs[0].Status)
+	require.Equal(t, "user account is disabled", logs[0].Message)
+
+	require.NoError(t, db.Model(user).Update("status", 1).Error)
+	require.NoError(t, dialGateway(t, gateway, signer))
+	logs = loginLogs(t, db, 2)
+	require.Equal(t, "success", logs[1].Status)
+	require.Equal(t, user.ID, logs[1].UserID)
+}
@@ -5,15 +5,12 @@ import (
 	"bastion/models"
 	"bastion/utils"
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
 	"encoding/json"
-	"encoding/pem"
 	"fmt"
 	"io"
 	"log"
 	mathrand "math/rand"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,6 +29,11 @@ type SSHService struct {
 	sessionsMu      sync.RWMutex
 	redisSession    *RedisSessionService // Redis会话管理
 	resourceManager *utils.SessionResourceManager // 会话资源管理器
+	permissionService *AssetPermissionService // 资产授权服务
+	accessPolicy      *AccessPolicyService    // 访问策略服务
+	hostKeys          *HostKeyService         // 主机密钥校验服务
+	certAuthority     *SSHCAService           // SSH证书颁发机构
+	gateways          *GatewayChainService    // 网关链路服务
 }
 
 // SSHSession SSH会话
@@ -40,6 +42,9 @@ type SSHSession struct {
 	UserID       uint                `json:"user_id"`
 	AssetID      uint                `json:"asset_id"`
 	CredentialID uint                `json:"credential_id"`
+	Protocol     string              `json:"protocol"` // ssh 交互式终端，sftp 文件传输，telnet Telnet终端
+	ClientIP     string              `json:"client_ip"`
+	GatewayChain models.GatewayChain `json:"gateway_chain,omitempty"` // 连接经过的网关链路
 	ClientConn   *ssh.Client         `json:"-"`
 	SessionConn  *ssh.Session        `json:"-"`
 	StdoutPipe   io.Reader           `json:"-"`
@@ -49,6 +54,7 @@ type SSHSession struct {
 	UpdatedAt    time.Time           `json:"updated_at"`
 	LastActive   time.Time           `json:"last_active"`
 	Commands     []SSHCommand        `json:"commands,omitempty"`
+	telnet       *telnetConn         `json:"-"` // Telnet会话的连接，SSH会话为空
 	recorder     *SessionRecorder    `json:"-"` // 会话录制器
 	resources    *utils.SessionResources `json:"-"` // 会话资源管理
 	mu           sync.RWMutex        `json:"-"`
@@ -70,9 +76,10 @@ type SSHCommand struct {
 type SSHSessionRequest struct {
 	AssetID      uint   `json:"asset_id" binding:"required"`
 	CredentialID uint   `json:"credential_id" binding:"required"`
-	Protocol     string `json:"protocol" binding:"required,oneof=ssh"`
+	Protocol     string `json:"protocol" binding:"required,oneof=ssh telnet"`
 	Width        int    `json:"width" binding:"omitempty,min=1"`
 	Height       int    `json:"height" binding:"omitempty,min=1"`
+	ClientIP     string `json:"-"` // 客户端IP，由控制器或SSH网关填充
 }
 
 // SSHSessionResponse SSH会话响应
@@ -86,6 +93,9 @@ type SSHSessionResponse struct {
 	LastActive time.Time `json:"last_active"`
 }
 
+// GlobalSSHService 全局SSH服务实例，Web终端与SSH网关共享同一会话表
+var GlobalSSHService *SSHService
+
 // NewSSHService 创建SSH服务实例
 func NewSSHService(db *gorm.DB) *SSHService {
 	redisSessionService := NewRedisSessionService()
@@ -112,6 +122,11 @@ func NewSSHService(db *gorm.DB) *SSHService {
 		sessions:        make(map[string]*SSHSession),
 		redisSession:    redisSessionService,
 		resourceManager: utils.NewSessionResourceManager(),
+		permissionService: NewAssetPermissionService(db),
+		accessPolicy:      NewAccessPolicyService(db),
+		hostKeys:          NewHostKeyService(db),
+		certAuthority:     NewSSHCAService(db),
+		gateways:          NewGatewayChainService(db),
 	}
 	
 	// 🆕 设置超时回调 (简化版，仅处理超时，不处理警告)
@@ -125,50 +140,98 @@ func NewSSHService(db *gorm.DB) *SSHService {
 	return service
 }
 
-// CreateSession 创建SSH会话
-func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SSHSessionResponse, error) {
+// connectAsset 校验资产、凭证及用户授权，并建立到资产的SSH连接，资产配置了网关时经网关链路连接
+func (s *SSHService) connectAsset(userID uint, request *SSHSessionRequest) (*models.Asset, *models.Credential, *models.User, *ssh.Client, models.GatewayChain, error) {
+	asset, credential, user, err := s.authorizeAsset(userID, request)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
+	// 创建SSH客户端配置
+	sshConfig, err := s.createSSHConfig(*asset, *credential, *user)
+	if err != nil {
+		return nil, nil, nil, nil, nil, fmt.Errorf("failed to create SSH config: %w", err)
+	}
+
+	// 建立SSH连接
+	address := fmt.Sprintf("%s:%d", asset.Address, asset.Port)
+	log.Printf("Attempting to connect to SSH server at %s", address)
+	clientConn, gatewayChain, err := s.gateways.DialSSH(asset, sshConfig)
+	if err != nil {
+		log.Printf("Failed to connect to SSH server at %s: %v", address, err)
+		return nil, nil, nil, nil, nil, fmt.Errorf("failed to connect to SSH server: %w", err)
+	}
+	if len(gatewayChain) > 0 {
+		log.Printf("Successfully connected to SSH server at %s via %s", address, gatewayChain)
+	} else {
+		log.Printf("Successfully connected to SSH server at %s", address)
+	}
+
+	return asset, credential, user, clientConn, gatewayChain, nil
+}
+
+// authorizeAsset 校验资产、凭证及用户授权和访问策略，返回会话所需的资产、凭证和用户
+func (s *SSHService) authorizeAsset(userID uint, request *SSHSessionRequest) (*models.Asset, *models.Credential, *models.User, error) {
 	// 获取资产信息
 	var asset models.Asset
 	if err := s.db.Where("id = ?", request.AssetID).First(&asset).Error; err != nil {
-		return nil, fmt.Errorf("asset not found: %w", err)
+		return nil, nil, nil, fmt.Errorf("asset not found: %w", err)
+	}
+
+	// 会话协议必须与资产协议一致：Telnet资产不能按SSH连接，SSH资产也不能按Telnet登录，
+	// SFTP文件传输使用SSH资产
+	protocol := "ssh"
+	if request.Protocol == TelnetProtocol {
+		protocol = TelnetProtocol
+	}
+	if asset.Protocol != protocol {
+		return nil, nil, nil, fmt.Errorf("%w: asset %s uses protocol %s, cannot open %s session", utils.ErrInvalidParam, asset.Name, asset.Protocol, protocol)
 	}
 
 	// 获取凭证信息并验证与资产的关联关系
 	var credential models.Credential
 	if err := s.db.Where("id = ?", request.CredentialID).First(&credential).Error; err != nil {
-		return nil, fmt.Errorf("credential not found: %w", err)
+		return nil, nil, nil, fmt.Errorf("credential not found: %w", err)
 	}
 
 	// 验证凭证与资产的关联关系
 	var count int64
 	if err := s.db.Table("asset_credentials").Where("asset_id = ? AND credential_id = ?", request.AssetID, request.CredentialID).Count(&count).Error; err != nil {
-		return nil, fmt.Errorf("failed to verify asset-credential relationship: %w", err)
+		return nil, nil, nil, fmt.Errorf("failed to verify asset-credential relationship: %w", err)
 	}
 	if count == 0 {
-		return nil, fmt.Errorf("credential is not associated with the asset")
+		return nil, nil, nil, fmt.Errorf("credential is not associated with the asset")
+	}
+
+	// 验证用户是否被授权使用该凭证访问资产
+	if err := s.permissionService.CheckAssetAccess(userID, request.AssetID, request.CredentialID); err != nil {
+		return nil, nil, nil, err
 	}
 
 	// 获取用户信息
 	var user models.User
 	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
-		return nil, fmt.Errorf("user not found: %w", err)
+		return nil, nil, nil, fmt.Errorf("user not found: %w", err)
 	}
 
-	// 创建SSH客户端配置
-	sshConfig, err := s.createSSHConfig(credential)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create SSH config: %w", err)
+	// 校验访问策略是否允许当前时间和来源地址建立会话
+	if err := s.accessPolicy.CheckSession(&user, request.AssetID, request.ClientIP); err != nil {
+		return nil, nil, nil, err
 	}
 
-	// 建立SSH连接
-	address := fmt.Sprintf("%s:%d", asset.Address, asset.Port)
-	log.Printf("Attempting to connect to SSH server at %s", address)
-	clientConn, err := ssh.Dial("tcp", address, sshConfig)
+	return &asset, &credential, &user, nil
+}
+
+// CreateSession 创建SSH会话，协议为telnet时创建Telnet会话
+func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SSHSessionResponse, error) {
+	if request.Protocol == TelnetProtocol {
+		return s.createTelnetSession(userID, request)
+	}
+
+	asset, credential, user, clientConn, gatewayChain, err := s.connectAsset(userID, request)
 	if err != nil {
-		log.Printf("Failed to connect to SSH server at %s: %v", address, err)
-		return nil, fmt.Errorf("failed to connect to SSH server: %w", err)
+		return nil, err
 	}
-	log.Printf("Successfully connected to SSH server at %s", address)
 
 	// 创建会话
 	sessionConn, err := clientConn.NewSession()
@@ -220,8 +283,14 @@ func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SS
 		return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
 	}
 
+	// 客户端IP，未提供时使用本机地址
+	clientIP := request.ClientIP
+	if clientIP == "" {
+		clientIP = "127.0.0.1"
+	}
+
 	// 创建会话资源管理器
-	resources, ctx := s.resourceManager.CreateSession(sessionID)
+	resources, _ := s.resourceManager.CreateSession(sessionID)
 	
 	// 创建会话对象
 	session := &SSHSession{
@@ -229,6 +298,9 @@ func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SS
 		UserID:       userID,
 		AssetID:      request.AssetID,
 		CredentialID: request.CredentialID,
+		Protocol:     "ssh",
+		ClientIP:     clientIP,
+		GatewayChain: gatewayChain,
 		ClientConn:   clientConn,
 		SessionConn:  sessionConn,
 		StdoutPipe:   stdout,
@@ -268,7 +340,19 @@ func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SS
 	// shell会在连接建立后自动显示提示符
 	log.Printf("SSH shell started for session %s, no initialization commands sent", sessionID)
 
-	// 启动会话监控goroutine，检测SSH会话自然结束
+	return s.registerSession(session, asset, credential, user, width, height, sessionConn.Wait), nil
+}
+
+// registerSession 登记已建立连接的交互式会话：监控会话结束、保存到内存和Redis、
+// 启动录制并写入会话记录与审计日志，SSH与Telnet会话共用
+func (s *SSHService) registerSession(session *SSHSession, asset *models.Asset, credential *models.Credential, user *models.User, width, height int, wait func() error) *SSHSessionResponse {
+	sessionID := session.ID
+	userID := session.UserID
+	resources := session.resources
+	ctx := resources.Context()
+	protocolName := strings.ToUpper(session.Protocol)
+
+	// 启动会话监控goroutine，检测会话自然结束
 	resources.AddCloseFunc("session-monitor", func() error {
 		// 这个函数会在资源清理时被调用，确保goroutine退出
 		return nil
@@ -277,28 +361,28 @@ func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SS
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("SSH session monitor panic for %s: %v", sessionID, r)
+				log.Printf("%s session monitor panic for %s: %v", protocolName, sessionID, r)
 			}
 		}()
 		
 		// 创建一个channel来监听会话结束
 		done := make(chan error, 1)
 		go func() {
-			done <- sessionConn.Wait()
+			done <- wait()
 		}()
 		
 		select {
 		case <-ctx.Done():
 			// Context被取消，会话正在被清理
-			log.Printf("SSH session %s monitor stopped due to context cancellation", sessionID)
+			log.Printf("%s session %s monitor stopped due to context cancellation", protocolName, sessionID)
 			return
 		case err := <-done:
-			// SSH会话结束
+			// 会话结束
 			if err != nil {
-				log.Printf("SSH session %s ended with error: %v", sessionID, err)
-				s.CloseSessionWithReason(sessionID, "SSH会话异常结束")
+				log.Printf("%s session %s ended with error: %v", protocolName, sessionID, err)
+				s.CloseSessionWithReason(sessionID, protocolName+"会话异常结束")
 			} else {
-				log.Printf("SSH session %s ended normally (user exit/logout)", sessionID)
+				log.Printf("%s session %s ended normally (user exit/logout)", protocolName, sessionID)
 				s.CloseSessionWithReason(sessionID, "用户正常退出")
 			}
 		}
@@ -315,11 +399,11 @@ func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SS
 			SessionID:    sessionID,
 			UserID:       userID,
 			Username:     user.Username,
-			AssetID:      request.AssetID,
+			AssetID:      asset.ID,
 			AssetName:    asset.Name,
 			AssetAddress: fmt.Sprintf("%s:%d", asset.Address, asset.Port),
-			CredentialID: request.CredentialID,
-			Protocol:     "ssh",
+			CredentialID: credential.ID,
+			Protocol:     session.Protocol,
 			TTL:          config.GlobalConfig.Session.Timeout,
 		}
 		if err := s.redisSession.CreateSession(redisData); err != nil {
@@ -330,13 +414,13 @@ func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SS
 	// 🎬 启动会话录制（如果启用）
 	if s.recordingService != nil {
 		logrus.WithField("session_id", sessionID).Info("准备启动会话录制")
-		if recorder, err := s.recordingService.StartRecording(sessionID, userID, request.AssetID, width, height); err != nil {
+		if recorder, err := s.recordingService.StartRecording(sessionID, userID, asset.ID, width, height); err != nil {
 			logrus.WithError(err).WithField("session_id", sessionID).Error("启动会话录制失败")
 		} else {
 			logrus.WithFields(logrus.Fields{
 				"session_id": sessionID,
 				"user_id":    userID,
-				"asset_id":   request.AssetID,
+				"asset_id":   asset.ID,
 			}).Info("会话录制已启动")
 			
 			// 将录制器存储到会话中以便后续使用
@@ -356,12 +440,11 @@ func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SS
 		case <-ctx.Done():
 			return
 		default:
-			s.recordSessionToDB(session, asset, credential)
+			s.recordSessionToDB(session, *asset, *credential)
 		}
 	}()
 
 	// 记录会话开始到审计日志（统一使用审计服务）
-	clientIP := "127.0.0.1" // 这里需要从上下文中获取真实IP
 	resources.AddCloseFunc("record-session-start", func() error {
 		// 这个函数会在资源清理时被调用
 		return nil
@@ -379,16 +462,17 @@ func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SS
 				asset.Name,
 				fmt.Sprintf("%s:%d", asset.Address, asset.Port),
 				credential.ID,
-				request.Protocol,
-				clientIP,
+				session.Protocol,
+				session.ClientIP,
+				session.GatewayChain,
 			)
 		}
 	}()
 
 	// 更新操作审计记录的SessionID和ResourceID（补充中间件记录）
 	// 中间件已经记录了操作日志，这里需要更新完整的会话标识信息
-	resourceInfo := fmt.Sprintf("SSH连接到 %s (%s:%d) 使用凭证 %s", 
-		asset.Name, asset.Address, asset.Port, credential.Username)
+	resourceInfo := fmt.Sprintf("%s连接到 %s (%s:%d) 使用凭证 %s", 
+		protocolName, asset.Name, asset.Address, asset.Port, credential.Username)
 	resources.AddCloseFunc("update-operation-log", func() error {
 		return nil
 	})
@@ -408,6 +492,79 @@ func (s *SSHService) CreateSession(userID uint, request *SSHSessionRequest) (*SS
 		}
 	}()
 
+	return &SSHSessionResponse{
+		ID:         sessionID,
+		Status:     "active",
+		AssetName:  asset.Name,
+		AssetAddr:  fmt.Sprintf("%s:%d", asset.Address, asset.Port),
+		Username:   credential.Username,
+		CreatedAt:  session.CreatedAt,
+		LastActive: session.LastActive,
+	}
+}
+
+// CreateFileSession 创建SFTP文件传输会话
+// 与交互式会话共用授权校验、会话登记和审计，但不申请PTY和Shell，
+// 调用方在返回的会话连接上自行打开sftp子系统
+func (s *SSHService) CreateFileSession(userID uint, request *SSHSessionRequest) (*SSHSessionResponse, error) {
+	asset, credential, user, clientConn, gatewayChain, err := s.connectAsset(userID, request)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID := s.generateSessionID()
+	clientIP := request.ClientIP
+	if clientIP == "" {
+		clientIP = "127.0.0.1"
+	}
+
+	resources, _ := s.resourceManager.CreateSession(sessionID)
+	session := &SSHSession{
+		ID:           sessionID,
+		UserID:       userID,
+		AssetID:      asset.ID,
+		CredentialID: credential.ID,
+		Protocol:     "sftp",
+		ClientIP:     clientIP,
+		GatewayChain: gatewayChain,
+		ClientConn:   clientConn,
+		Status:       "active",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		LastActive:   time.Now(),
+		Commands:     make([]SSHCommand, 0),
+		resources:    resources,
+	}
+	resources.AddCloseFunc("ssh-client", func() error {
+		return clientConn.Close()
+	})
+
+	s.sessionsMu.Lock()
+	s.sessions[sessionID] = session
+	s.sessionsMu.Unlock()
+
+	if s.redisSession != nil {
+		redisData := &RedisSessionData{
+			SessionID:    sessionID,
+			UserID:       userID,
+			Username:     user.Username,
+			AssetID:      asset.ID,
+			AssetName:    asset.Name,
+			AssetAddress: fmt.Sprintf("%s:%d", asset.Address, asset.Port),
+			CredentialID: credential.ID,
+			Protocol:     "sftp",
+			TTL:          config.GlobalConfig.Session.Timeout,
+		}
+		if err := s.redisSession.CreateSession(redisData); err != nil {
+			logrus.WithError(err).Error("Failed to store session in Redis")
+		}
+	}
+
+	// 同步写入会话记录，文件传输日志需要关联该记录
+	if err := s.recordSessionToDB(session, *asset, *credential); err != nil {
+		logrus.WithError(err).WithField("session_id", sessionID).Error("Failed to record sftp session")
+	}
+
 	return &SSHSessionResponse{
 		ID:         sessionID,
 		Status:     "active",
@@ -580,6 +737,33 @@ func (s *SSHService) CloseSessionWithReason(sessionID string, reason string) err
 	return nil
 }
 
+// CloseUserAssetSessions 关闭用户在指定资产上的全部会话，assetIDs 为空时关闭该用户的全部会话，返回关闭的会话数
+func (s *SSHService) CloseUserAssetSessions(userID uint, assetIDs []uint, reason string) int {
+	targets := make(map[uint]bool, len(assetIDs))
+	for _, id := range assetIDs {
+		targets[id] = true
+	}
+
+	s.sessionsMu.RLock()
+	var sessionIDs []string
+	for id, session := range s.sessions {
+		if session.UserID == userID && (len(targets) == 0 || targets[session.AssetID]) {
+			sessionIDs = append(sessionIDs, id)
+		}
+	}
+	s.sessionsMu.RUnlock()
+
+	closed := 0
+	for _, id := range sessionIDs {
+		if err := s.CloseSessionWithReason(id, reason); err != nil {
+			logrus.WithError(err).WithField("session_id", id).Warn("关闭会话失败")
+			continue
+		}
+		closed++
+	}
+	return closed
+}
+
 // cleanupSessionFromAllSources 统一清理所有数据源中的会话
 func (s *SSHService) cleanupSessionFromAllSources(sessionID string) {
 	// 清理资源管理器
@@ -848,7 +1032,7 @@ func (s *SSHService) WriteToSession(sessionID string, data []byte) error {
 	session.mu.Lock()
 	defer session.mu.Unlock()
 
-	if session.SessionConn == nil {
+	if session.SessionConn == nil && session.telnet == nil {
 		return fmt.Errorf("session connection is closed")
 	}
 
@@ -893,7 +1077,7 @@ func (s *SSHService) ReadFromSession(sessionID string) (io.Reader, error) {
 	session.mu.RLock()
 	defer session.mu.RUnlock()
 
-	if session.SessionConn == nil {
+	if session.SessionConn == nil && session.telnet == nil {
 		return nil, fmt.Errorf("session connection is closed")
 	}
 
@@ -914,6 +1098,11 @@ func (s *SSHService) ResizeSession(sessionID string, width, height int) error {
 	session.mu.Lock()
 	defer session.mu.Unlock()
 
+	// Telnet会话通过NAWS选项通知窗口大小
+	if session.telnet != nil {
+		return session.telnet.Resize(width, height)
+	}
+
 	if session.SessionConn == nil {
 		return fmt.Errorf("session connection is closed")
 	}
@@ -957,12 +1146,64 @@ func (s *SSHService) RecordCommand(sessionID, command, output string, exitCode i
 	return nil
 }
 
+// CheckCommand 按命令过滤规则检查会话中即将执行的命令
+// 被拦截的命令会记录到审计日志，返回命令是否允许执行
+func (s *SSHService) CheckCommand(sessionID string, command string) bool {
+	session, err := s.GetSession(sessionID)
+	if err != nil {
+		return false
+	}
+
+	account := "unknown"
+	var credential models.Credential
+	if err := s.db.Select("username").Where("id = ?", session.CredentialID).First(&credential).Error; err == nil {
+		account = credential.Username
+	}
+
+	commandMatcherService := NewCommandMatcherService(s.db, NewCommandFilterService(s.db))
+	matchResult, err := commandMatcherService.MatchCommand(&models.CommandMatchRequest{
+		Command: command,
+		UserID:  session.UserID,
+		AssetID: session.AssetID,
+		Account: account,
+	})
+	if err != nil {
+		log.Printf("Command match error: %v", err)
+	}
+
+	if err == nil && (!matchResult.Matched || matchResult.Action == "allow") {
+		return true
+	}
+
+	// 命令被拦截 - 只记录被阻断的命令到审计日志
+	action := "deny"
+	if matchResult != nil && matchResult.Matched && matchResult.Action != "" {
+		action = matchResult.Action
+	}
+	go s.RecordCommand(
+		sessionID,
+		command,
+		"Command blocked by filter rule", // output 记录阻断原因
+		1,                                // exitCode 设为1表示失败
+		action,
+		time.Now(),
+		nil,
+	)
+	log.Printf("[AUDIT] Recorded blocked command: session=%s, command=%s, action=%s", sessionID, command, action)
+
+	return false
+}
+
 // createSSHConfig 创建SSH客户端配置
-func (s *SSHService) createSSHConfig(credential models.Credential) (*ssh.ClientConfig, error) {
+func (s *SSHService) createSSHConfig(asset models.Asset, credential models.Credential, user models.User) (*ssh.ClientConfig, error) {
 	config := &ssh.ClientConfig{
-		User:            credential.Username,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 注意：生产环境需要验证主机密钥
-		Timeout:         30 * time.Second, // ✅ 修复：设置合理的连接超时时间
+		User:    credential.Username,
+		Timeout: 30 * time.Second, // ✅ 修复：设置合理的连接超时时间
+	}
+
+	// 校验资产主机密钥，防止中间人攻击
+	if err := s.hostKeys.ConfigureClient(config, asset.ID); err != nil {
+		return nil, err
 	}
 
 	if credential.Type == "password" {
@@ -973,12 +1214,24 @@ func (s *SSHService) createSSHConfig(credential models.Credential) (*ssh.ClientC
 		}
 		config.Auth = append(config.Auth, ssh.Password(password))
 	} else if credential.Type == "key" {
-		// 解析私钥
-		signer, err := ssh.ParsePrivateKey([]byte(credential.PrivateKey))
+		// 解密并解析私钥
+		privateKey, err := utils.DecryptPrivateKey(credential.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey([]byte(privateKey))
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse private key: %w", err)
 		}
 		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+	} else if credential.Type == utils.CredentialTypeCert {
+		// 由堡垒机CA签发仅用于本次连接的短期证书
+		keyID := fmt.Sprintf("bastion:%s asset=%d credential=%d", user.Username, asset.ID, credential.ID)
+		signer, err := s.certAuthority.NewCertSigner(credential.Username, keyID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to issue ssh certificate: %w", err)
+		}
+		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
 	}
 
 	return config, nil
@@ -999,8 +1252,9 @@ func (s *SSHService) recordSessionToDB(session *SSHSession, asset models.Asset,
 		AssetName:    asset.Name,
 		AssetAddress: fmt.Sprintf("%s:%d", asset.Address, asset.Port),
 		CredentialID: session.CredentialID,
-		Protocol:     "ssh",
-		IP:           "127.0.0.1", // 这里需要从上下文中获取真实 IP
+		Protocol:     session.Protocol,
+		IP:           session.ClientIP,
+		GatewayChain: session.GatewayChain,
 		Status:       "active",
 		StartTime:    session.CreatedAt,
 		IsTerminated: nil, // 设置为 nil 表示未被终止
@@ -1048,6 +1302,10 @@ func (session *SSHSession) Close() {
 			session.ClientConn.Close()
 			session.ClientConn = nil
 		}
+
+		if session.telnet != nil {
+			session.telnet.Close()
+		}
 	}
 }
 
@@ -1056,7 +1314,7 @@ func (session *SSHSession) IsActive() bool {
 	session.mu.RLock()
 	defer session.mu.RUnlock()
 
-	if session.Status != "active" || session.SessionConn == nil {
+	if session.Status != "active" || (session.SessionConn == nil && session.Protocol == "ssh") {
 		return false
 	}
 
@@ -1066,7 +1324,13 @@ func (session *SSHSession) IsActive() bool {
 
 // IsConnectionAlive 检查SSH连接是否真实存活
 func (session *SSHSession) IsConnectionAlive() bool {
-	if session.ClientConn == nil || session.SessionConn == nil {
+	// Telnet会话发送NOP检测连接
+	if session.telnet != nil {
+		return session.telnet.Alive()
+	}
+
+	// SFTP会话没有Shell会话，仅检查底层连接
+	if session.ClientConn == nil || (session.SessionConn == nil && session.Protocol != "sftp") {
 		return false
 	}
 
@@ -1209,30 +1473,9 @@ func (session *SSHSession) UpdateActivity() {
 	session.UpdatedAt = time.Now()
 }
 
-// GenerateSSHKeyPair 生成SSH密钥对
-func (s *SSHService) GenerateSSHKeyPair() (string, string, error) {
-	// 生成RSA私钥
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to generate private key: %w", err)
-	}
-
-	// 编码私钥
-	privateKeyPEM := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	}
-	privateKeyStr := string(pem.EncodeToMemory(privateKeyPEM))
-
-	// 生成公钥
-	publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to generate public key: %w", err)
-	}
-
-	publicKeyStr := string(ssh.MarshalAuthorizedKey(publicKey))
-
-	return privateKeyStr, publicKeyStr, nil
+// GenerateSSHKeyPair 生成SSH密钥对，支持 rsa 和 ed25519
+func (s *SSHService) GenerateSSHKeyPair(keyType string) (string, string, error) {
+	return utils.GenerateSSHKeyPair(keyType, "")
 }
 
 // CleanupInactiveSessions 清理不活跃的会话
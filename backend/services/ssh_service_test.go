@@ -0,0 +1,62 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newCountingListener 在回环地址上监听并统计收到的连接数，用于确认会话没有连接资产
+func newCountingListener(t *testing.T) (int, *int32) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	var accepted int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&accepted, 1)
+			conn.Close()
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, &accepted
+}
+
+func TestCreateSessionRejectsProtocolMismatch(t *testing.T) {
+	setupTestConfig(t)
+	db := newTestDB(t, &models.Asset{}, &models.Credential{}, &models.AssetCredential{})
+	service := &SSHService{db: db}
+
+	port, accepted := newCountingListener(t)
+	credential := &models.Credential{Name: "root", Type: utils.CredentialTypePassword, Username: "root", Password: "secret"}
+	require.NoError(t, db.Create(credential).Error)
+	sshAsset := &models.Asset{Name: "web-01", Address: "127.0.0.1", Port: port, Protocol: "ssh", Credentials: []models.Credential{*credential}}
+	require.NoError(t, db.Create(sshAsset).Error)
+	telnetAsset := &models.Asset{Name: "switch-01", Address: "127.0.0.1", Port: port, Protocol: TelnetProtocol, Credentials: []models.Credential{*credential}}
+	require.NoError(t, db.Create(telnetAsset).Error)
+
+	// SSH资产不能按Telnet登录
+	_, err := service.CreateSession(1, &SSHSessionRequest{AssetID: sshAsset.ID, CredentialID: credential.ID, Protocol: TelnetProtocol})
+	require.ErrorIs(t, err, utils.ErrInvalidParam)
+	require.ErrorContains(t, err, "asset web-01 uses protocol ssh, cannot open telnet session")
+
+	// Telnet资产不能建立SSH终端或SFTP会话
+	_, err = service.CreateSession(1, &SSHSessionRequest{AssetID: telnetAsset.ID, CredentialID: credential.ID, Protocol: "ssh"})
+	require.ErrorIs(t, err, utils.ErrInvalidParam)
+	require.ErrorContains(t, err, "asset switch-01 uses protocol telnet, cannot open ssh session")
+	_, err = service.CreateFileSession(1, &SSHSessionRequest{AssetID: telnetAsset.ID, CredentialID: credential.ID, Protocol: "ssh"})
+	require.ErrorIs(t, err, utils.ErrInvalidParam)
+
+	time.Sleep(50 * time.Millisecond)
+	require.Zero(t, atomic.LoadInt32(accepted))
+}
@@ -0,0 +1,584 @@
+package services
+
+import (
+	"bastion/config"
+	"bastion/utils"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// TelnetProtocol Telnet终端会话的协议名
+const TelnetProtocol = "telnet"
+
+// Telnet 命令与选项（RFC 854、RFC 857、RFC 858、RFC 1073、RFC 1091）
+const (
+	telnetSE   byte = 240
+	telnetNOP  byte = 241
+	telnetSB   byte = 250
+	telnetWILL byte = 251
+	telnetWONT byte = 252
+	telnetDO   byte = 253
+	telnetDONT byte = 254
+	telnetIAC  byte = 255
+
+	telnetOptBinary byte = 0
+	telnetOptEcho   byte = 1
+	telnetOptSGA    byte = 3
+	telnetOptTTYPE  byte = 24
+	telnetOptNAWS   byte = 31
+
+	telnetTTYPEIs   byte = 0
+	telnetTTYPESend byte = 1
+)
+
+const (
+	telnetDialTimeout          = 30 * time.Second
+	telnetMaxSubnegotiation    = 1024
+	defaultTelnetTerminalType  = "XTERM"
+	defaultTelnetLoginTimeout  = 15
+	defaultTelnetUserPrompt    = `(?i)(login|username|user name)\s*:\s*$`
+	defaultTelnetPassPrompt    = `(?i)password\s*:\s*$`
+	defaultTelnetFailure       = `(?i)(incorrect|invalid|failed|denied|bad password)`
+	defaultTelnetShellPrompt   = `[>#$%\]]\s*$`
+	telnetMaxLoginScreenLength = 64 * 1024
+)
+
+// telnetLocalOptions 本端同意启用的选项，telnetRemoteOptions 同意对端启用的选项
+var (
+	telnetLocalOptions  = map[byte]bool{telnetOptBinary: true, telnetOptSGA: true, telnetOptTTYPE: true, telnetOptNAWS: true}
+	telnetRemoteOptions = map[byte]bool{telnetOptBinary: true, telnetOptEcho: true, telnetOptSGA: true}
+)
+
+// telnetConn Telnet客户端连接
+// 读取时剥离选项协商并自动应答，写入时转义IAC并按NVT规则发送回车，
+// 作为会话的输出流和输入流使用
+type telnetConn struct {
+	conn         net.Conn
+	reader       *bufio.Reader
+	terminalType string
+
+	mu       sync.Mutex // 保护选项状态、窗口大小和写入
+	local    map[byte]bool
+	remote   map[byte]bool
+	willSent map[byte]bool // 本端主动发出、等待对端应答的 WILL
+	doSent   map[byte]bool // 本端主动发出、等待对端应答的 DO
+	width    int
+	height   int
+	pending  []byte // 登录阶段读取后留给终端显示的输出
+	lastCR   bool
+	done     chan struct{}
+	doneOnce sync.Once
+	err      error
+}
+
+// telnetPrompts 自动登录时匹配的提示符
+type telnetPrompts struct {
+	username *regexp.Regexp
+	password *regexp.Regexp
+	failure  *regexp.Regexp
+	shell    *regexp.Regexp
+}
+
+// newTelnetConn 在已建立的连接上开始Telnet选项协商
+func newTelnetConn(conn net.Conn, terminalType string, width, height int) (*telnetConn, error) {
+	t := &telnetConn{
+		conn:         conn,
+		reader:       bufio.NewReader(conn),
+		terminalType: terminalType,
+		local:        make(map[byte]bool),
+		remote:       make(map[byte]bool),
+		willSent:     map[byte]bool{telnetOptTTYPE: true, telnetOptNAWS: true},
+		doSent:       map[byte]bool{telnetOptSGA: true},
+		width:        width,
+		height:       height,
+		done:         make(chan struct{}),
+	}
+	// 主动提供终端类型和窗口大小，并请求对端关闭逐行模式
+	offer := []byte{
+		telnetIAC, telnetWILL, telnetOptTTYPE,
+		telnetIAC, telnetWILL, telnetOptNAWS,
+		telnetIAC, telnetDO, telnetOptSGA,
+	}
+	if _, err := conn.Write(offer); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Read 读取终端输出，选项协商在读取过程中处理
+func (t *telnetConn) Read(p []byte) (int, error) {
+	if len(t.pending) > 0 {
+		n := copy(p, t.pending)
+		t.pending = t.pending[n:]
+		return n, nil
+	}
+
+	n := 0
+	for n < len(p) {
+		// 已有数据时不再等待新的网络数据
+		if n > 0 && t.reader.Buffered() == 0 {
+			break
+		}
+		b, err := t.reader.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, t.readFailed(err)
+		}
+		switch {
+		case b == telnetIAC:
+			literal, err := t.readCommand()
+			if err != nil {
+				if n > 0 {
+					return n, nil
+				}
+				return 0, t.readFailed(err)
+			}
+			t.lastCR = false
+			if literal {
+				p[n] = telnetIAC
+				n++
+			}
+		case b == 0 && t.lastCR:
+			// CR NUL 表示单独的回车
+			t.lastCR = false
+		default:
+			t.lastCR = b == '\r'
+			p[n] = b
+			n++
+		}
+	}
+	return n, nil
+}
+
+// readFailed 连接读取失败时结束会话，登录阶段的读超时除外
+func (t *telnetConn) readFailed(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return err
+	}
+	t.finish(err)
+	return err
+}
+
+// readCommand 处理IAC之后的命令，返回是否为转义的数据字节255
+func (t *telnetConn) readCommand() (bool, error) {
+	cmd, err := t.reader.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	switch cmd {
+	case telnetIAC:
+		return true, nil
+	case telnetDO, telnetDONT, telnetWILL, telnetWONT:
+		option, err := t.reader.ReadByte()
+		if err != nil {
+			return false, err
+		}
+		return false, t.negotiate(cmd, option)
+	case telnetSB:
+		return false, t.readSubnegotiation()
+	}
+	// NOP、GA 等其他命令无需处理
+	return false, nil
+}
+
+// negotiate 应答对端的选项请求，已处于请求状态时不再应答以免协商循环
+func (t *telnetConn) negotiate(cmd, option byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch cmd {
+	case telnetDO:
+		if !telnetLocalOptions[option] {
+			return t.writeLocked([]byte{telnetIAC, telnetWONT, option})
+		}
+		requested := t.willSent[option]
+		delete(t.willSent, option)
+		if t.local[option] {
+			return nil
+		}
+		t.local[option] = true
+		if !requested {
+			if err := t.writeLocked([]byte{telnetIAC, telnetWILL, option}); err != nil {
+				return err
+			}
+		}
+		if option == telnetOptNAWS {
+			return t.writeLocked(t.windowSize())
+		}
+	case telnetDONT:
+		requested := t.willSent[option]
+		delete(t.willSent, option)
+		if t.local[option] {
+			t.local[option] = false
+			return t.writeLocked([]byte{telnetIAC, telnetWONT, option})
+		}
+		if !requested {
+			return t.writeLocked([]byte{telnetIAC, telnetWONT, option})
+		}
+	case telnetWILL:
+		if !telnetRemoteOptions[option] {
+			return t.writeLocked([]byte{telnetIAC, telnetDONT, option})
+		}
+		requested := t.doSent[option]
+		delete(t.doSent, option)
+		if t.remote[option] {
+			return nil
+		}
+		t.remote[option] = true
+		if !requested {
+			return t.writeLocked([]byte{telnetIAC, telnetDO, option})
+		}
+	case telnetWONT:
+		delete(t.doSent, option)
+		if t.remote[option] {
+			t.remote[option] = false
+			return t.writeLocked([]byte{telnetIAC, telnetDONT, option})
+		}
+	}
+	return nil
+}
+
+// readSubnegotiation 读取 IAC SB ... IAC SE，应答终端类型查询
+func (t *telnetConn) readSubnegotiation() error {
+	var data []byte
+	for {
+		b, err := t.reader.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == telnetIAC {
+			next, err := t.reader.ReadByte()
+			if err != nil {
+				return err
+			}
+			if next == telnetSE {
+				break
+			}
+			if next != telnetIAC {
+				// 不规范的子协商，按结束处理
+				break
+			}
+		}
+		if len(data) < telnetMaxSubnegotiation {
+			data = append(data, b)
+		}
+	}
+
+	if len(data) < 2 || data[0] != telnetOptTTYPE || data[1] != telnetTTYPESend {
+		return nil
+	}
+	reply := []byte{telnetIAC, telnetSB, telnetOptTTYPE, telnetTTYPEIs}
+	reply = append(reply, t.terminalType...)
+	reply = append(reply, telnetIAC, telnetSE)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.writeLocked(reply)
+}
+
+// Write 写入用户输入，转义IAC，未协商二进制传输时单独的回车按 CR NUL 发送
+func (t *telnetConn) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	binary := t.local[telnetOptBinary]
+	data := make([]byte, 0, len(p)+8)
+	for i, b := range p {
+		switch {
+		case b == telnetIAC:
+			data = append(data, telnetIAC, telnetIAC)
+		case b == '\r' && !binary && (i+1 >= len(p) || p[i+1] != '\n'):
+			data = append(data, '\r', 0)
+		default:
+			data = append(data, b)
+		}
+	}
+	if err := t.writeLocked(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Resize 调整窗口大小，对端已启用NAWS时立即通知
+func (t *telnetConn) Resize(width, height int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.width = width
+	t.height = height
+	if !t.local[telnetOptNAWS] {
+		return nil
+	}
+	return t.writeLocked(t.windowSize())
+}
+
+// windowSize NAWS子协商报文，宽高中的255需要转义
+func (t *telnetConn) windowSize() []byte {
+	data := []byte{telnetIAC, telnetSB, telnetOptNAWS}
+	for _, v := range []int{t.width, t.height} {
+		if v < 0 {
+			v = 0
+		}
+		if v > 0xffff {
+			v = 0xffff
+		}
+		for _, b := range []byte{byte(v >> 8), byte(v)} {
+			data = append(data, b)
+			if b == telnetIAC {
+				data = append(data, telnetIAC)
+			}
+		}
+	}
+	return append(data, telnetIAC, telnetSE)
+}
+
+// writeLocked 写入原始数据，调用方需持有 t.mu
+func (t *telnetConn) writeLocked(data []byte) error {
+	_, err := t.conn.Write(data)
+	return err
+}
+
+// Alive 发送NOP检测连接是否存活
+func (t *telnetConn) Alive() bool {
+	select {
+	case <-t.done:
+		return false
+	default:
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.writeLocked([]byte{telnetIAC, telnetNOP}) == nil
+}
+
+// Wait 等待连接结束，对端正常断开时返回nil
+func (t *telnetConn) Wait() error {
+	<-t.done
+	return t.err
+}
+
+// Close 关闭连接
+func (t *telnetConn) Close() error {
+	t.finish(nil)
+	return t.conn.Close()
+}
+
+// finish 标记连接结束
+func (t *telnetConn) finish(err error) {
+	t.doneOnce.Do(func() {
+		if err != nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, io.EOF) {
+			t.err = err
+		}
+		close(t.done)
+	})
+}
+
+// login 按提示符自动输入用户名和密码
+// 仅要求密码的设备直接输入密码，出现命令提示符即视为登录完成，
+// 输入密码后的输出保留给终端显示
+func (t *telnetConn) login(username, password string, prompts *telnetPrompts, timeout time.Duration) error {
+	if err := t.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	defer t.conn.SetReadDeadline(time.Time{})
+
+	var screen []byte
+	sentUsername, sentPassword := false, false
+	buf := make([]byte, 4096)
+	for {
+		n, err := t.Read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				// 输入密码后有输出但未识别到提示符，按登录成功处理
+				if sentPassword && len(bytes.TrimSpace(screen)) > 0 {
+					break
+				}
+				return fmt.Errorf("telnet login timed out waiting for %s prompt", telnetExpectedPrompt(sentUsername, sentPassword))
+			}
+			return fmt.Errorf("telnet connection closed during login: %w", err)
+		}
+		screen = append(screen, buf[:n]...)
+		if len(screen) > telnetMaxLoginScreenLength {
+			screen = screen[len(screen)-telnetMaxLoginScreenLength:]
+		}
+
+		line := telnetLastLine(screen)
+		switch {
+		case (sentUsername || sentPassword) && prompts.failure.Match(screen):
+			return fmt.Errorf("telnet login failed: %s", bytes.TrimSpace(screen))
+		case prompts.password.Match(line):
+			if sentPassword {
+				return fmt.Errorf("telnet login failed: password rejected")
+			}
+			if _, err := t.Write([]byte(password + "\r")); err != nil {
+				return err
+			}
+			sentPassword = true
+			screen = nil
+		case prompts.username.Match(line):
+			if sentUsername {
+				return fmt.Errorf("telnet login failed: credential rejected")
+			}
+			if _, err := t.Write([]byte(username + "\r")); err != nil {
+				return err
+			}
+			sentUsername = true
+			screen = nil
+		case prompts.shell.Match(line):
+			t.pending = screen
+			return nil
+		}
+	}
+	t.pending = screen
+	return nil
+}
+
+// telnetLastLine 屏幕输出的最后一行（不含行尾的回车换行之外的内容）
+func telnetLastLine(screen []byte) []byte {
+	if i := bytes.LastIndexByte(screen, '\n'); i >= 0 {
+		screen = screen[i+1:]
+	}
+	return bytes.TrimLeft(screen, "\r\x00")
+}
+
+// telnetExpectedPrompt 登录超时时等待的提示符，用于错误信息
+func telnetExpectedPrompt(sentUsername, sentPassword bool) string {
+	switch {
+	case sentPassword:
+		return "shell"
+	case sentUsername:
+		return "password"
+	}
+	return "login"
+}
+
+// newTelnetPrompts 按配置编译登录提示符，未配置时使用默认值
+func newTelnetPrompts(cfg config.TelnetConfig) (*telnetPrompts, error) {
+	compile := func(name, pattern, fallback string) (*regexp.Regexp, error) {
+		if pattern == "" {
+			pattern = fallback
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid telnet %s pattern: %w", name, err)
+		}
+		return re, nil
+	}
+
+	var prompts telnetPrompts
+	var err error
+	if prompts.username, err = compile("username prompt", cfg.UsernamePrompt, defaultTelnetUserPrompt); err != nil {
+		return nil, err
+	}
+	if prompts.password, err = compile("password prompt", cfg.PasswordPrompt, defaultTelnetPassPrompt); err != nil {
+		return nil, err
+	}
+	if prompts.failure, err = compile("failure", cfg.FailurePattern, defaultTelnetFailure); err != nil {
+		return nil, err
+	}
+	if prompts.shell, err = compile("shell prompt", cfg.ShellPrompt, defaultTelnetShellPrompt); err != nil {
+		return nil, err
+	}
+	return &prompts, nil
+}
+
+// createTelnetSession 创建Telnet会话
+// 与SSH会话共用授权校验、录制、命令过滤和在线监控，凭证需为密码类型，
+// 连接后按配置的提示符自动登录
+func (s *SSHService) createTelnetSession(userID uint, request *SSHSessionRequest) (*SSHSessionResponse, error) {
+	asset, credential, user, err := s.authorizeAsset(userID, request)
+	if err != nil {
+		return nil, err
+	}
+	if credential.Type != utils.CredentialTypePassword {
+		return nil, fmt.Errorf("%w: telnet sessions require a password credential", utils.ErrInvalidParam)
+	}
+	password, err := utils.DecryptPassword(credential.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt password: %w", err)
+	}
+
+	cfg := config.GlobalConfig.Telnet
+	prompts, err := newTelnetPrompts(cfg)
+	if err != nil {
+		return nil, err
+	}
+	terminalType := cfg.TerminalType
+	if terminalType == "" {
+		terminalType = defaultTelnetTerminalType
+	}
+	loginTimeout := cfg.LoginTimeout
+	if loginTimeout <= 0 {
+		loginTimeout = defaultTelnetLoginTimeout
+	}
+
+	width := request.Width
+	height := request.Height
+	if width <= 0 {
+		width = 80
+	}
+	if height <= 0 {
+		height = 24
+	}
+
+	// 建立Telnet连接，资产配置了网关时经网关链路连接
+	address := fmt.Sprintf("%s:%d", asset.Address, asset.Port)
+	log.Printf("Attempting to connect to telnet server at %s", address)
+	conn, gatewayChain, err := s.gateways.Dial(asset, address, telnetDialTimeout)
+	if err != nil {
+		log.Printf("Failed to connect to telnet server at %s: %v", address, err)
+		return nil, fmt.Errorf("failed to connect to telnet server: %w", err)
+	}
+	telnet, err := newTelnetConn(conn, terminalType, width, height)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to negotiate telnet options: %w", err)
+	}
+	if err := telnet.login(credential.Username, password, prompts, time.Duration(loginTimeout)*time.Second); err != nil {
+		telnet.Close()
+		log.Printf("Telnet login to %s failed: %v", address, err)
+		return nil, err
+	}
+	log.Printf("Successfully logged in to telnet server at %s", address)
+
+	sessionID := s.generateSessionID()
+	clientIP := request.ClientIP
+	if clientIP == "" {
+		clientIP = "127.0.0.1"
+	}
+
+	resources, _ := s.resourceManager.CreateSession(sessionID)
+	session := &SSHSession{
+		ID:           sessionID,
+		UserID:       userID,
+		AssetID:      asset.ID,
+		CredentialID: credential.ID,
+		Protocol:     TelnetProtocol,
+		ClientIP:     clientIP,
+		GatewayChain: gatewayChain,
+		StdoutPipe:   telnet,
+		StdinPipe:    telnet,
+		Status:       "active",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		LastActive:   time.Now(),
+		Commands:     make([]SSHCommand, 0),
+		telnet:       telnet,
+		resources:    resources,
+	}
+	resources.AddCloseFunc("telnet-conn", telnet.Close)
+
+	return s.registerSession(session, asset, credential, user, width, height, telnet.Wait), nil
+}
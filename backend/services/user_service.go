@@ -5,18 +5,24 @@ import (
 	"bastion/utils"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
 // UserService 用户服务
 type UserService struct {
-	db *gorm.DB
+	db             *gorm.DB
+	passwordPolicy *PasswordPolicyService
 }
 
 // NewUserService 创建用户服务实例
 func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{db: db}
+	return &UserService{
+		db:             db,
+		passwordPolicy: NewPasswordPolicyService(db),
+	}
 }
 
 // CreateUser 创建用户
@@ -36,6 +42,11 @@ func (s *UserService) CreateUser(request *models.UserCreateRequest) (*models.Use
 		return nil, errors.New("some roles not found")
 	}
 
+	// 按密码策略校验密码
+	if err := s.passwordPolicy.Validate(&models.User{Username: request.Username}, request.Password); err != nil {
+		return nil, err
+	}
+
 	// 哈希密码
 	hashedPassword, err := utils.HashPassword(request.Password)
 	if err != nil {
@@ -43,12 +54,14 @@ func (s *UserService) CreateUser(request *models.UserCreateRequest) (*models.Use
 	}
 
 	// 创建用户
+	now := time.Now()
 	user := models.User{
-		Username: request.Username,
-		Password: hashedPassword,
-		Email:    request.Email,
-		Phone:    request.Phone,
-		Status:   1, // 默认启用
+		Username:          request.Username,
+		Password:          hashedPassword,
+		Email:             request.Email,
+		Phone:             request.Phone,
+		Status:            1, // 默认启用
+		PasswordChangedAt: &now,
 	}
 
 	// 开始事务
@@ -77,6 +90,12 @@ func (s *UserService) CreateUser(request *models.UserCreateRequest) (*models.Use
 		}
 	}
 
+	// 记录初始密码，禁止之后改回
+	if err := s.passwordPolicy.RecordPassword(tx, user.ID, hashedPassword); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
@@ -202,6 +221,14 @@ func (s *UserService) UpdateUser(userID uint, request *models.UserUpdateRequest)
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	// 禁用用户时立即注销其全部登录
+	if request.Status != nil && *request.Status == 0 && user.Status != 0 {
+		if _, err := utils.RevokeUserLoginSessions(userID); err != nil {
+			return nil, err
+		}
+		closeDisabledUserSessions(userID, "用户已被禁用")
+	}
+
 	// 重新加载用户信息
 	if err := s.db.Preload("Roles").Where("id = ?", userID).First(&user).Error; err != nil {
 		return nil, fmt.Errorf("failed to reload user: %w", err)
@@ -225,6 +252,12 @@ func (s *UserService) DeleteUser(userID uint) error {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 
+	// 注销已删除用户的全部登录
+	if _, err := utils.RevokeUserLoginSessions(userID); err != nil {
+		return err
+	}
+	closeDisabledUserSessions(userID, "用户已被删除")
+
 	return nil
 }
 
@@ -238,9 +271,14 @@ func (s *UserService) ResetPassword(userID uint, newPassword string) error {
 		return fmt.Errorf("failed to find user: %w", err)
 	}
 
-	// 验证新密码强度
-	if !utils.ValidatePasswordStrength(newPassword) {
-		return errors.New("password does not meet strength requirements")
+	// 外部目录用户的密码不在本地维护
+	if user.IsExternal() {
+		return fmt.Errorf("password of %s user must be reset in the directory", user.AuthSource)
+	}
+
+	// 按密码策略校验新密码
+	if err := s.passwordPolicy.Validate(&user, newPassword); err != nil {
+		return err
 	}
 
 	// 哈希新密码
@@ -249,9 +287,9 @@ func (s *UserService) ResetPassword(userID uint, newPassword string) error {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	// 更新密码
-	if err := s.db.Model(&user).Update("password", hashedPassword).Error; err != nil {
-		return fmt.Errorf("failed to update password: %w", err)
+	// 更新密码并记录历史
+	if err := s.passwordPolicy.SetPassword(user.ID, hashedPassword); err != nil {
+		return err
 	}
 
 	return nil
@@ -273,5 +311,20 @@ func (s *UserService) ToggleUserStatus(userID uint) error {
 		return fmt.Errorf("failed to update user status: %w", err)
 	}
 
+	// 禁用用户时立即注销其全部登录
+	if newStatus == 0 {
+		if _, err := utils.RevokeUserLoginSessions(userID); err != nil {
+			return err
+		}
+		closeDisabledUserSessions(userID, "用户已被禁用")
+	}
+
 	return nil
 }
+
+// closeDisabledUserSessions 断开被禁用或删除的用户的全部会话，会话只在建立时校验用户状态
+func closeDisabledUserSessions(userID uint, reason string) {
+	if _, err := closeUserAssetSessions(userID, nil, reason); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Error("关闭用户会话失败")
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bastion/models"
+	"bastion/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+// UserSSHKeyService 用户SSH公钥服务
+type UserSSHKeyService struct {
+	db *gorm.DB
+}
+
+// NewUserSSHKeyService 创建用户SSH公钥服务实例
+func NewUserSSHKeyService(db *gorm.DB) *UserSSHKeyService {
+	return &UserSSHKeyService{db: db}
+}
+
+// GetKeys 获取用户的公钥列表
+func (s *UserSSHKeyService) GetKeys(userID uint) ([]*models.UserSSHKeyResponse, error) {
+	var keys []models.UserSSHKey
+	if err := s.db.Where("user_id = ?", userID).Order("id ASC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to get ssh keys: %w", err)
+	}
+
+	responses := make([]*models.UserSSHKeyResponse, len(keys))
+	for i := range keys {
+		responses[i] = keys[i].ToResponse()
+	}
+	return responses, nil
+}
+
+// AddKey 为用户添加公钥
+func (s *UserSSHKeyService) AddKey(userID uint, req *models.UserSSHKeyCreateRequest) (*models.UserSSHKeyResponse, error) {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(req.PublicKey)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid public key", utils.ErrInvalidParam)
+	}
+
+	fingerprint := ssh.FingerprintSHA256(publicKey)
+	var count int64
+	if err := s.db.Model(&models.UserSSHKey{}).Where("fingerprint = ?", fingerprint).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check ssh key: %w", err)
+	}
+	if count > 0 {
+		return nil, errors.New("ssh key already exists")
+	}
+
+	// 统一保存为不含注释的authorized_keys格式
+	key := &models.UserSSHKey{
+		UserID:      userID,
+		Name:        req.Name,
+		KeyType:     publicKey.Type(),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+		Fingerprint: fingerprint,
+	}
+	if err := s.db.Create(key).Error; err != nil {
+		return nil, fmt.Errorf("failed to create ssh key: %w", err)
+	}
+
+	return key.ToResponse(), nil
+}
+
+// DeleteKey 删除用户的公钥
+func (s *UserSSHKeyService) DeleteKey(userID, keyID uint) error {
+	result := s.db.Where("id = ? AND user_id = ?", keyID, userID).Delete(&models.UserSSHKey{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete ssh key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return utils.ErrNotFound
+	}
+	return nil
+}
+
+// FindUserByPublicKey 根据用户名和公钥查找用户，用于SSH网关公钥认证
+func (s *UserSSHKeyService) FindUserByPublicKey(username string, publicKey ssh.PublicKey) (*models.User, *models.UserSSHKey, error) {
+	var key models.UserSSHKey
+	err := s.db.Joins("JOIN users ON users.id = user_ssh_keys.user_id AND users.deleted_at IS NULL").
+		Where("users.username = ? AND user_ssh_keys.fingerprint = ?", username, ssh.FingerprintSHA256(publicKey)).
+		First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, utils.ErrNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to find ssh key: %w", err)
+	}
+
+	var user models.User
+	if err := s.db.Preload("Roles.Permissions").Where("id = ?", key.UserID).First(&user).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	return &user, &key, nil
+}
+
+// TouchKey 更新公钥最后使用时间
+func (s *UserSSHKeyService) TouchKey(keyID uint) {
+	now := time.Now()
+	s.db.Model(&models.UserSSHKey{}).Where("id = ?", keyID).Update("last_used_at", &now)
+}
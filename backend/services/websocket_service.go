@@ -480,6 +480,34 @@ func (ws *WebSocketService) GetManager() *ConnectionManager {
 	return ws.manager
 }
 
+// BroadcastToMonitorClients 广播消息给所有监控客户端（不包含SSH终端客户端）
+func (ws *WebSocketService) BroadcastToMonitorClients(message WSMessage) {
+	if ws.manager == nil {
+		return
+	}
+
+	ws.manager.Mutex.RLock()
+	defer ws.manager.Mutex.RUnlock()
+
+	// 序列化消息
+	data, err := json.Marshal(message)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to marshal monitor message")
+		return
+	}
+
+	// 遍历所有客户端，发送给监控权限的客户端
+	for _, client := range ws.manager.Clients {
+		if client.Role != "ssh_terminal" {
+			select {
+			case client.Send <- data:
+			default:
+				logrus.WithField("client_id", client.ID).Debug("Monitor client send buffer full, skipping")
+			}
+		}
+	}
+}
+
 // 全局WebSocket服务实例
 var GlobalWebSocketService *WebSocketService
 var GlobalSessionTimeoutService *SessionTimeoutService
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"bastion/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	apiTokenPrefix        = "bst_"      // 个人API令牌前缀，用于与JWT区分
+	apiTokenDisplayLength = 12          // 列表中展示的令牌前缀长度
+	apiTokenTouchInterval = time.Minute // 最后使用信息的最小更新间隔，避免每次请求都写库
+)
+
+// IsAPIToken 检查是否为个人API令牌
+func IsAPIToken(tokenString string) bool {
+	return strings.HasPrefix(tokenString, apiTokenPrefix)
+}
+
+// GenerateAPIToken 生成个人API令牌，返回明文令牌和用于展示的前缀
+func GenerateAPIToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(buf)
+	return token, token[:apiTokenDisplayLength], nil
+}
+
+// HashAPIToken 计算令牌摘要，数据库中只保存摘要
+func HashAPIToken(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetUserByAPIToken 验证个人API令牌并加载用户，用户权限被限制在令牌的权限范围内
+func GetUserByAPIToken(tokenString, clientIP string) (*models.User, *models.APIToken, error) {
+	var token models.APIToken
+	if err := GetDB().Where("token_hash = ?", HashAPIToken(tokenString)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("invalid api token")
+		}
+		return nil, nil, err
+	}
+
+	if token.RevokedAt != nil {
+		return nil, nil, errors.New("api token revoked")
+	}
+	if !token.IsUsable() {
+		return nil, nil, errors.New("api token expired")
+	}
+
+	var user models.User
+	if err := GetDB().Preload("Roles.Permissions").Where("id = ?", token.UserID).First(&user).Error; err != nil {
+		return nil, nil, errors.New("user not found")
+	}
+	if !user.IsActive() {
+		return nil, nil, errors.New("user is inactive")
+	}
+
+	user.RestrictPermissions(token.PermissionList())
+	touchAPIToken(&token, clientIP)
+
+	return &user, &token, nil
+}
+
+// touchAPIToken 记录令牌最后使用时间和IP
+func touchAPIToken(token *models.APIToken, clientIP string) {
+	now := time.Now()
+	if token.LastUsedAt != nil && token.LastUsedIP == clientIP && now.Sub(*token.LastUsedAt) < apiTokenTouchInterval {
+		return
+	}
+
+	GetDB().Model(&models.APIToken{}).Where("id = ?", token.ID).Updates(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": clientIP,
+	})
+	token.LastUsedAt = &now
+	token.LastUsedIP = clientIP
+}
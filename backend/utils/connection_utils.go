@@ -51,6 +51,13 @@ type ConnectionConfig struct {
 	ConnType    string
 	Timeout     time.Duration
 	Database    string // 数据库名称（用于数据库连接）
+
+	// SSH主机密钥校验，未设置时拒绝连接
+	HostKeyCallback   ssh.HostKeyCallback
+	HostKeyAlgorithms []string
+
+	// Dial 自定义拨号，如经网关链路连接；未设置时直接建立TCP连接
+	Dial func(network, address string) (net.Conn, error)
 }
 
 // ConnectionUtils 连接工具类
@@ -96,11 +103,16 @@ func (cu *ConnectionUtils) TestSSHConnection(config *ConnectionConfig) error {
 		return err
 	}
 
+	if config.HostKeyCallback == nil {
+		return fmt.Errorf("未配置SSH主机密钥校验")
+	}
+
 	// 创建SSH客户端配置
 	sshConfig := &ssh.ClientConfig{
-		User:            config.Username,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(), // 测试时忽略主机密钥验证
-		Timeout:         config.Timeout,
+		User:              config.Username,
+		HostKeyCallback:   config.HostKeyCallback,
+		HostKeyAlgorithms: config.HostKeyAlgorithms,
+		Timeout:           config.Timeout,
 	}
 
 	// 根据凭证类型配置认证方法
@@ -122,7 +134,7 @@ func (cu *ConnectionUtils) TestSSHConnection(config *ConnectionConfig) error {
 
 	// 连接SSH服务器
 	address := cu.FormatAddress(config.Host, config.Port)
-	client, err := ssh.Dial("tcp", address, sshConfig)
+	client, err := cu.dialSSH(config, address, sshConfig)
 	if err != nil {
 		return fmt.Errorf("SSH连接失败 %s: %v", address, err)
 	}
@@ -148,6 +160,24 @@ func (cu *ConnectionUtils) TestSSHConnection(config *ConnectionConfig) error {
 	return nil
 }
 
+// dialSSH 建立SSH连接，配置了自定义拨号时在其返回的连接上握手
+func (cu *ConnectionUtils) dialSSH(config *ConnectionConfig, address string, sshConfig *ssh.ClientConfig) (*ssh.Client, error) {
+	if config.Dial == nil {
+		return ssh.Dial("tcp", address, sshConfig)
+	}
+
+	conn, err := config.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, address, sshConfig)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
 // TestDatabaseConnection 测试数据库连接
 func (cu *ConnectionUtils) TestDatabaseConnection(config *ConnectionConfig) error {
 	if err := cu.validateConnectionConfig(config, config.ConnType); err != nil {
@@ -1,17 +1,15 @@
 package utils
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"io"
 	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh"
 )
 
 // 凭证类型常量
@@ -22,26 +20,12 @@ const (
 )
 
 // 凭证工具类 - 统一处理凭证加密解密和验证
-type CredentialUtils struct {
-	encryptionKey []byte
-}
+// 加密解密通过全局密钥后端完成，与 EncryptPassword/DecryptPassword 一致
+type CredentialUtils struct{}
 
 // NewCredentialUtils 创建新的凭证工具实例
-func NewCredentialUtils(key string) *CredentialUtils {
-	// 使用固定长度的密钥，确保AES-256加密
-	keyBytes := []byte(key)
-	if len(keyBytes) < 32 {
-		// 填充到32字节
-		padding := make([]byte, 32-len(keyBytes))
-		keyBytes = append(keyBytes, padding...)
-	} else if len(keyBytes) > 32 {
-		// 截取前32字节
-		keyBytes = keyBytes[:32]
-	}
-	
-	return &CredentialUtils{
-		encryptionKey: keyBytes,
-	}
+func NewCredentialUtils() *CredentialUtils {
+	return &CredentialUtils{}
 }
 
 // EncryptCredential 加密凭证信息
@@ -50,28 +34,11 @@ func (cu *CredentialUtils) EncryptCredential(plaintext string) (string, error) {
 		return "", errors.New("明文凭证不能为空")
 	}
 
-	block, err := aes.NewCipher(cu.encryptionKey)
+	encrypted, err := EncryptSecret(plaintext)
 	if err != nil {
-		return "", fmt.Errorf("创建AES加密器失败: %v", err)
-	}
-
-	// 使用GCM模式，提供认证加密
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("创建GCM失败: %v", err)
-	}
-
-	// 生成随机nonce
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", fmt.Errorf("生成nonce失败: %v", err)
+		return "", fmt.Errorf("加密失败: %w", err)
 	}
-
-	// 加密数据
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	
-	// 返回base64编码的密文
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return encrypted, nil
 }
 
 // DecryptCredential 解密凭证信息
@@ -80,37 +47,11 @@ func (cu *CredentialUtils) DecryptCredential(encrypted string) (string, error) {
 		return "", errors.New("加密凭证不能为空")
 	}
 
-	// base64解码
-	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
-	if err != nil {
-		return "", fmt.Errorf("base64解码失败: %v", err)
-	}
-
-	block, err := aes.NewCipher(cu.encryptionKey)
+	plaintext, err := DecryptSecret(encrypted)
 	if err != nil {
-		return "", fmt.Errorf("创建AES解密器失败: %v", err)
+		return "", fmt.Errorf("解密失败: %w", err)
 	}
-
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", fmt.Errorf("创建GCM失败: %v", err)
-	}
-
-	if len(ciphertext) < gcm.NonceSize() {
-		return "", errors.New("密文长度不足")
-	}
-
-	// 提取nonce和密文
-	nonce := ciphertext[:gcm.NonceSize()]
-	ciphertext = ciphertext[gcm.NonceSize():]
-
-	// 解密数据
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return "", fmt.Errorf("解密失败: %v", err)
-	}
-
-	return string(plaintext), nil
+	return plaintext, nil
 }
 
 // ValidateCredentialType 验证凭证类型是否有效
@@ -203,21 +144,44 @@ func (cu *CredentialUtils) ParseSSHPrivateKey(keyData string) (*rsa.PrivateKey,
 	}
 }
 
-// MaskSensitiveData 掩码敏感数据用于日志记录
+// MaskSensitiveData 掩码敏感数据，只给出长度区间，不保留任何原文字符
+// 用于日志记录和密码凭证的提示
 func (cu *CredentialUtils) MaskSensitiveData(data string) string {
-	if len(data) <= 4 {
-		return "****"
+	length := utf8.RuneCountInString(data)
+	switch {
+	case length < 8:
+		return "****(<8)"
+	case length < 12:
+		return "****(8-11)"
+	case length < 16:
+		return "****(12-15)"
+	default:
+		return "****(16+)"
 	}
-	
-	if len(data) <= 8 {
-		return data[:2] + "****"
+}
+
+// SecretHint 生成凭证的非敏感提示：密钥凭证为公钥的SHA256指纹，密码凭证为密码长度区间
+// 参数为明文，返回值可以保存并在响应中展示；证书凭证或无法解析的加密私钥返回空字符串
+func (cu *CredentialUtils) SecretHint(credType, password, privateKey string) string {
+	switch credType {
+	case CredentialTypePassword:
+		if password == "" {
+			return ""
+		}
+		return cu.MaskSensitiveData(password)
+	case CredentialTypeKey:
+		signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+		if err != nil {
+			return ""
+		}
+		return ssh.FingerprintSHA256(signer.PublicKey())
+	default:
+		return ""
 	}
-	
-	return data[:2] + "****" + data[len(data)-2:]
 }
 
-// 全局凭证工具实例，使用默认密钥
-var DefaultCredentialUtils = NewCredentialUtils("bastion-default-key-2024")
+// 全局凭证工具实例
+var DefaultCredentialUtils = NewCredentialUtils()
 
 // 便捷函数，使用默认实例
 func ValidateCredType(credType string) bool {
@@ -226,4 +190,8 @@ func ValidateCredType(credType string) bool {
 
 func ValidatePrivateKeyFormat(keyData string) error {
 	return DefaultCredentialUtils.ValidatePrivateKey(keyData)
+}
+
+func CredentialSecretHint(credType, password, privateKey string) string {
+	return DefaultCredentialUtils.SecretHint(credType, password, privateKey)
 }
\ No newline at end of file
@@ -5,7 +5,10 @@ import (
 	"crypto/rand"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,25 +21,93 @@ func ResetRequestBody(req *http.Request, body []byte) io.ReadCloser {
 	return io.NopCloser(bytes.NewBuffer(body))
 }
 
+// trustedProxies 可信反向代理的网段，由 SetTrustedProxies 设置
+var (
+	trustedProxies   []*net.IPNet
+	trustedProxiesMu sync.RWMutex
+)
+
+// SetTrustedProxies 设置可信反向代理，支持单个IP和CIDR网段，传空表示不信任任何代理
+func SetTrustedProxies(proxies []string) error {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy: %s", proxy)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			proxy = fmt.Sprintf("%s/%d", proxy, bits)
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy: %s", proxy)
+		}
+		networks = append(networks, network)
+	}
+
+	trustedProxiesMu.Lock()
+	trustedProxies = networks
+	trustedProxiesMu.Unlock()
+	return nil
+}
+
+// isTrustedProxy 判断地址是否为可信代理
+func isTrustedProxy(ip net.IP) bool {
+	trustedProxiesMu.RLock()
+	defer trustedProxiesMu.RUnlock()
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetClientIP 获取客户端真实IP地址
+// 仅当请求直接来自可信代理时才采用 X-Forwarded-For/X-Real-IP：X-Forwarded-For
+// 从右向左跳过可信代理，取第一个不可信的地址；其余情况使用TCP连接的来源地址，
+// 客户端自行携带的转发头不会被采用
 func GetClientIP(req *http.Request) string {
-	// 检查 X-Forwarded-For 头
-	if xff := req.Header.Get("X-Forwarded-For"); xff != "" {
-		return xff
+	remote := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
 	}
-
-	// 检查 X-Real-IP 头
-	if xri := req.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+	remoteIP := net.ParseIP(remote)
+	if remoteIP == nil || !isTrustedProxy(remoteIP) {
+		return remote
 	}
 
-	// 检查 CF-Connecting-IP 头 (Cloudflare)
-	if cfip := req.Header.Get("CF-Connecting-IP"); cfip != "" {
-		return cfip
+	// 多个 X-Forwarded-For 头按出现顺序拼接，最右侧为最近一跳
+	var hops []string
+	for _, value := range req.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			// 无法解析的地址之前的内容不可信
+			return remote
+		}
+		if !isTrustedProxy(ip) || i == 0 {
+			return ip.String()
+		}
 	}
 
-	// 使用 RemoteAddr
-	return req.RemoteAddr
+	if xri := strings.TrimSpace(req.Header.Get("X-Real-IP")); xri != "" {
+		if ip := net.ParseIP(xri); ip != nil {
+			return ip.String()
+		}
+	}
+	return remote
 }
 
 // GetUserAgent 获取用户代理字符串
@@ -10,36 +10,71 @@ import (
 	"github.com/google/uuid"
 )
 
+// Token用途，带用途的token只能用于对应流程，不能访问业务接口
+const (
+	TokenPurposeMFA            = "mfa"             // 密码校验通过、等待二次验证的预认证令牌
+	TokenPurposePasswordChange = "password_change" // 密码已过期，只能用于修改密码
+)
+
+// blacklistKeyPrefix 已注销token的Redis键前缀，后接jti
+const blacklistKeyPrefix = "blacklist:"
+
 // Claims JWT自定义声明
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	Purpose  string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // TokenResponse Token响应结构
 type TokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   int    `json:"expires_in"`
+	AccessToken            string `json:"access_token"`
+	TokenType              string `json:"token_type"`
+	ExpiresIn              int    `json:"expires_in"`
+	PasswordChangeRequired bool   `json:"password_change_required,omitempty"` // 密码已过期，令牌只能用于修改密码
 }
 
 // GenerateToken 生成JWT token
 func GenerateToken(user *models.User) (*TokenResponse, error) {
+	return generateToken(user, "", config.GlobalConfig.JWT.Expire, "")
+}
+
+// GeneratePurposeToken 生成指定用途的短期token
+func GeneratePurposeToken(user *models.User, purpose string, expire int) (*TokenResponse, error) {
+	if purpose == "" {
+		return nil, errors.New("token purpose is required")
+	}
+	return generateToken(user, purpose, expire, "")
+}
+
+// generateToken 生成并签名token，id为空时生成新的jti
+func generateToken(user *models.User, purpose string, expire int, id string) (*TokenResponse, error) {
+	token, _, err := signToken(user, purpose, expire, id)
+	return token, err
+}
+
+// signToken 生成并签名token，同时返回token中的声明
+func signToken(user *models.User, purpose string, expire int, id string) (*TokenResponse, *Claims, error) {
+	if id == "" {
+		id = uuid.New().String()
+	}
+
 	// 设置过期时间
-	expireTime := time.Now().Add(time.Duration(config.GlobalConfig.JWT.Expire) * time.Second)
+	expireTime := time.Now().Add(time.Duration(expire) * time.Second)
 
 	// 创建Claims
 	claims := &Claims{
 		UserID:   user.ID,
 		Username: user.Username,
+		Purpose:  purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expireTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    config.GlobalConfig.JWT.Issuer,
 			Subject:   user.Username,
-			ID:        uuid.New().String(),
+			ID:        id,
 		},
 	}
 
@@ -47,14 +82,15 @@ func GenerateToken(user *models.User) (*TokenResponse, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(config.GlobalConfig.JWT.Secret))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	return &TokenResponse{
-		AccessToken: tokenString,
-		TokenType:   "Bearer",
-		ExpiresIn:   config.GlobalConfig.JWT.Expire,
-	}, nil
+		AccessToken:            tokenString,
+		TokenType:              "Bearer",
+		ExpiresIn:              expire,
+		PasswordChangeRequired: purpose == TokenPurposePasswordChange,
+	}, claims, nil
 }
 
 // ParseToken 解析JWT token
@@ -80,6 +116,12 @@ func ParseToken(tokenString string) (*Claims, error) {
 
 // ValidateToken 验证JWT token
 func ValidateToken(tokenString string) (*Claims, error) {
+	return ValidateTokenFor(tokenString)
+}
+
+// ValidateTokenFor 验证访问令牌，同时接受指定用途的token
+// 用于少数允许受限令牌访问的接口，例如密码过期后修改密码
+func ValidateTokenFor(tokenString string, allowedPurposes ...string) (*Claims, error) {
 	claims, err := ParseToken(tokenString)
 	if err != nil {
 		return nil, err
@@ -90,6 +132,39 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, errors.New("token expired")
 	}
 
+	// 带用途的token不能作为访问令牌
+	if claims.Purpose != "" && !containsPurpose(allowedPurposes, claims.Purpose) {
+		return nil, errors.New("token is not an access token")
+	}
+
+	return claims, nil
+}
+
+// containsPurpose 检查token用途是否在允许列表中
+func containsPurpose(purposes []string, purpose string) bool {
+	for _, p := range purposes {
+		if p == purpose {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePurposeToken 验证指定用途的token
+func ValidatePurposeToken(tokenString, purpose string) (*Claims, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.ExpiresAt.Time.Before(time.Now()) {
+		return nil, errors.New("token expired")
+	}
+
+	if claims.Purpose != purpose {
+		return nil, errors.New("invalid token purpose")
+	}
+
 	return claims, nil
 }
 
@@ -100,6 +175,23 @@ func RefreshToken(tokenString string) (*TokenResponse, error) {
 		return nil, err
 	}
 
+	// 带用途的token不能刷新为访问令牌
+	if claims.Purpose != "" {
+		return nil, errors.New("token is not an access token")
+	}
+
+	// 已退出或被注销的登录会话不能刷新
+	if IsTokenBlacklisted(tokenString) {
+		return nil, ErrLoginSessionRevoked
+	}
+	revoked, err := isLoginSessionRevoked(GetRedis().Context(), GetRedis(), claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrLoginSessionRevoked
+	}
+
 	// 检查是否可以刷新（例如：token在1小时内过期才能刷新）
 	if time.Until(claims.ExpiresAt.Time) > time.Hour {
 		return nil, errors.New("token does not need refresh")
@@ -116,8 +208,8 @@ func RefreshToken(tokenString string) (*TokenResponse, error) {
 		return nil, errors.New("user is inactive")
 	}
 
-	// 生成新的token
-	return GenerateToken(&user)
+	// 生成新的token，沿用原jti使刷新前后属于同一个登录会话
+	return generateToken(&user, "", config.GlobalConfig.JWT.Expire, claims.ID)
 }
 
 // GetUserFromToken 从token中获取用户信息
@@ -127,6 +219,11 @@ func GetUserFromToken(tokenString string) (*models.User, error) {
 		return nil, err
 	}
 
+	return GetUserByClaims(claims)
+}
+
+// GetUserByClaims 加载已验证token对应的用户
+func GetUserByClaims(claims *Claims) (*models.User, error) {
 	var user models.User
 	if err := GetDB().Preload("Roles.Permissions").Where("id = ?", claims.UserID).First(&user).Error; err != nil {
 		return nil, errors.New("user not found")
@@ -153,9 +250,13 @@ func BlacklistToken(tokenString string) error {
 		return nil // token已过期，无需加入黑名单
 	}
 
-	// 将token ID存入Redis黑名单
-	key := "blacklist:" + claims.ID
-	return GetRedis().Set(GetRedis().Context(), key, tokenString, expireTime).Err()
+	// 将token ID存入Redis黑名单，同时结束对应的登录会话
+	rdb := GetRedis()
+	if err := rdb.Set(rdb.Context(), blacklistKeyPrefix+claims.ID, tokenString, expireTime).Err(); err != nil {
+		return err
+	}
+	removeLoginSession(rdb.Context(), rdb, claims)
+	return nil
 }
 
 // IsTokenBlacklisted 检查token是否在黑名单中
@@ -165,7 +266,7 @@ func IsTokenBlacklisted(tokenString string) bool {
 		return true
 	}
 
-	key := "blacklist:" + claims.ID
+	key := blacklistKeyPrefix + claims.ID
 	exists, err := GetRedis().Exists(GetRedis().Context(), key).Result()
 	return err == nil && exists > 0
 }
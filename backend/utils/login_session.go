@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	loginSessionKeyPrefix        = "login:session:"        // 单个登录会话
+	loginSessionIndexKeyPrefix   = "login:sessions:"       // 用户的登录会话索引，score为签发时间
+	loginSessionRevokedKeyPrefix = "login:revoked_before:" // 早于该时间签发的令牌全部失效
+	loginSessionTouchInterval    = time.Minute             // 最后活动时间的最小更新间隔
+)
+
+// ErrLoginSessionRevoked 登录会话已被注销
+var ErrLoginSessionRevoked = errors.New("login session has been revoked")
+
+// GenerateLoginToken 签发访问令牌并登记为登录会话，登记后按 security.loginSession.maxConcurrent
+// 踢出该用户最早的会话。会话无法登记（如Redis不可用）时不签发令牌，
+// 否则未登记的令牌不受并发登录上限约束
+func GenerateLoginToken(user *models.User, clientIP, userAgent string) (*TokenResponse, error) {
+	token, claims, err := signToken(user, "", config.GlobalConfig.JWT.Expire, "")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	rdb := GetRedis()
+	session := newLoginSession(claims, clientIP, userAgent)
+	// 令牌中的签发时间只精确到秒，登记精确的签发时间，同一秒内的多次登录也按先后踢出
+	session.IssuedAt = session.LastSeenAt
+	if err := registerLoginSession(ctx, rdb, session); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// TrackLoginSession 记录访问令牌的使用情况，更新登录会话的最后活动时间。
+// 登录会话在签发令牌时登记，会话记录缺失的令牌（如升级前签发的令牌）在首次使用时补登记。
+// Redis不可用时返回错误，无法确认会话是否已被注销的令牌不能继续使用
+func TrackLoginSession(claims *Claims, clientIP, userAgent string) error {
+	ctx := context.Background()
+	rdb := GetRedis()
+	now := time.Now()
+
+	revoked, err := isLoginSessionRevoked(ctx, rdb, claims)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrLoginSessionRevoked
+	}
+
+	session, err := getLoginSession(ctx, rdb, claims.ID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		session = newLoginSession(claims, clientIP, userAgent)
+		if time.Until(session.ExpiresAt) <= 0 {
+			return nil
+		}
+		return registerLoginSession(ctx, rdb, session)
+	}
+
+	// 刷新后的令牌沿用原jti，有效期随之延长
+	if claims.ExpiresAt.Time.After(session.ExpiresAt) {
+		session.ExpiresAt = claims.ExpiresAt.Time
+		session.LastSeenAt = now
+		session.IP = clientIP
+		if err := saveLoginSession(ctx, rdb, session, time.Until(session.ExpiresAt)); err != nil {
+			return fmt.Errorf("failed to save login session: %w", err)
+		}
+		rdb.Expire(ctx, loginSessionIndexKey(claims.UserID), time.Until(session.ExpiresAt))
+	} else if now.Sub(session.LastSeenAt) >= loginSessionTouchInterval {
+		session.LastSeenAt = now
+		session.IP = clientIP
+		if err := saveLoginSession(ctx, rdb, session, redis.KeepTTL); err != nil {
+			return fmt.Errorf("failed to save login session: %w", err)
+		}
+	}
+	return nil
+}
+
+// newLoginSession 根据访问令牌的声明创建登录会话
+func newLoginSession(claims *Claims, clientIP, userAgent string) *models.LoginSession {
+	now := time.Now()
+	session := &models.LoginSession{
+		ID:         claims.ID,
+		UserID:     claims.UserID,
+		Username:   claims.Username,
+		IP:         clientIP,
+		UserAgent:  userAgent,
+		IssuedAt:   now,
+		LastSeenAt: now,
+		ExpiresAt:  claims.ExpiresAt.Time,
+	}
+	if claims.IssuedAt != nil {
+		session.IssuedAt = claims.IssuedAt.Time
+	}
+	return session
+}
+
+// registerLoginSession 保存登录会话并加入用户的会话索引，再按并发登录上限踢出最早的会话。
+// 任一步骤失败时撤回本次登记并返回错误
+func registerLoginSession(ctx context.Context, rdb *redis.Client, session *models.LoginSession) error {
+	if err := saveLoginSession(ctx, rdb, session, time.Until(session.ExpiresAt)); err != nil {
+		return fmt.Errorf("failed to register login session: %w", err)
+	}
+
+	indexKey := loginSessionIndexKey(session.UserID)
+	err := rdb.ZAdd(ctx, indexKey, &redis.Z{Score: float64(session.IssuedAt.Unix()), Member: session.ID}).Err()
+	if err == nil {
+		rdb.Expire(ctx, indexKey, time.Duration(config.GlobalConfig.JWT.Expire)*time.Second)
+		err = enforceLoginSessionLimit(ctx, rdb, session.UserID, session.ID)
+	}
+	if err != nil {
+		rdb.Del(ctx, loginSessionKeyPrefix+session.ID)
+		rdb.ZRem(ctx, indexKey, session.ID)
+		return fmt.Errorf("failed to register login session: %w", err)
+	}
+	return nil
+}
+
+// ListLoginSessions 获取用户当前有效的登录会话，按签发时间倒序
+func ListLoginSessions(userID uint) ([]*models.LoginSession, error) {
+	ctx := context.Background()
+	sessions, err := loadLoginSessions(ctx, GetRedis(), userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].IssuedAt.After(sessions[j].IssuedAt)
+	})
+	return sessions, nil
+}
+
+// RevokeLoginSession 注销用户的指定登录会话，对应的访问令牌立即失效
+func RevokeLoginSession(userID uint, sessionID string) (*models.LoginSession, error) {
+	ctx := context.Background()
+	rdb := GetRedis()
+
+	session, err := getLoginSession(ctx, rdb, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.UserID != userID {
+		return nil, ErrNotFound
+	}
+
+	if err := revokeLoginSession(ctx, rdb, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// RevokeUserLoginSessions 注销用户的全部登录会话，包括尚未使用过的令牌
+// 用于禁用、删除用户或管理员强制下线，返回注销的会话数
+func RevokeUserLoginSessions(userID uint) (int, error) {
+	ctx := context.Background()
+	rdb := GetRedis()
+
+	// 令牌的签发时间精度为秒，取下一秒使当前秒内签发的令牌同样失效
+	expire := time.Duration(config.GlobalConfig.JWT.Expire) * time.Second
+	revokedBefore := time.Now().Unix() + 1
+	if err := rdb.Set(ctx, loginSessionRevokedKey(userID), revokedBefore, expire).Err(); err != nil {
+		return 0, fmt.Errorf("failed to revoke login sessions: %w", err)
+	}
+
+	sessions, err := loadLoginSessions(ctx, rdb, userID)
+	if err != nil {
+		return 0, err
+	}
+	for _, session := range sessions {
+		if err := revokeLoginSession(ctx, rdb, session); err != nil {
+			return 0, err
+		}
+	}
+	return len(sessions), nil
+}
+
+// isLoginSessionRevoked 检查令牌是否在用户全部会话被注销之前签发
+func isLoginSessionRevoked(ctx context.Context, rdb *redis.Client, claims *Claims) (bool, error) {
+	revokedBefore, err := rdb.Get(ctx, loginSessionRevokedKey(claims.UserID)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check login session revocation: %w", err)
+	}
+	return claims.IssuedAt != nil && claims.IssuedAt.Unix() < revokedBefore, nil
+}
+
+// removeLoginSession 删除令牌对应的登录会话记录，用于主动退出登录
+func removeLoginSession(ctx context.Context, rdb *redis.Client, claims *Claims) {
+	rdb.Del(ctx, loginSessionKeyPrefix+claims.ID)
+	rdb.ZRem(ctx, loginSessionIndexKey(claims.UserID), claims.ID)
+}
+
+// revokeLoginSession 将会话对应的令牌加入黑名单并删除会话记录
+func revokeLoginSession(ctx context.Context, rdb *redis.Client, session *models.LoginSession) error {
+	if ttl := time.Until(session.ExpiresAt); ttl > 0 {
+		if err := rdb.Set(ctx, blacklistKeyPrefix+session.ID, session.Username, ttl).Err(); err != nil {
+			return fmt.Errorf("failed to revoke login session: %w", err)
+		}
+	}
+	rdb.Del(ctx, loginSessionKeyPrefix+session.ID)
+	rdb.ZRem(ctx, loginSessionIndexKey(session.UserID), session.ID)
+	return nil
+}
+
+// enforceLoginSessionLimit 超过并发登录上限时注销最早签发的会话，当前会话不会被踢出
+func enforceLoginSessionLimit(ctx context.Context, rdb *redis.Client, userID uint, currentID string) error {
+	limit := config.GlobalConfig.Security.LoginSession.MaxConcurrent
+	if limit <= 0 {
+		return nil
+	}
+
+	sessions, err := loadLoginSessions(ctx, rdb, userID)
+	if err != nil {
+		return err
+	}
+	if len(sessions) <= limit {
+		return nil
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].IssuedAt.Before(sessions[j].IssuedAt)
+	})
+
+	excess := len(sessions) - limit
+	for _, session := range sessions {
+		if excess == 0 {
+			break
+		}
+		if session.ID == currentID {
+			continue
+		}
+		if err := revokeLoginSession(ctx, rdb, session); err != nil {
+			return err
+		}
+		logrus.WithFields(logrus.Fields{
+			"user_id":    userID,
+			"session_id": session.ID,
+		}).Info("超出并发登录上限，已注销最早的登录会话")
+		excess--
+	}
+	return nil
+}
+
+// loadLoginSessions 读取用户索引中的全部会话，顺带清理已过期的索引项
+func loadLoginSessions(ctx context.Context, rdb *redis.Client, userID uint) ([]*models.LoginSession, error) {
+	indexKey := loginSessionIndexKey(userID)
+	ids, err := rdb.ZRange(ctx, indexKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login sessions: %w", err)
+	}
+
+	sessions := make([]*models.LoginSession, 0, len(ids))
+	for _, id := range ids {
+		session, err := getLoginSession(ctx, rdb, id)
+		if err != nil {
+			return nil, err
+		}
+		if session == nil {
+			rdb.ZRem(ctx, indexKey, id)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// getLoginSession 读取登录会话，不存在时返回nil
+func getLoginSession(ctx context.Context, rdb *redis.Client, sessionID string) (*models.LoginSession, error) {
+	data, err := rdb.Get(ctx, loginSessionKeyPrefix+sessionID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login session: %w", err)
+	}
+
+	var session models.LoginSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode login session: %w", err)
+	}
+	return &session, nil
+}
+
+// saveLoginSession 保存登录会话
+func saveLoginSession(ctx context.Context, rdb *redis.Client, session *models.LoginSession, ttl time.Duration) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return rdb.Set(ctx, loginSessionKeyPrefix+session.ID, data, ttl).Err()
+}
+
+func loginSessionIndexKey(userID uint) string {
+	return loginSessionIndexKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+}
+
+func loginSessionRevokedKey(userID uint) string {
+	return loginSessionRevokedKeyPrefix + strconv.FormatUint(uint64(userID), 10)
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"bastion/config"
+	"bastion/models"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+// setupLoginSessionRedis 使用模拟Redis和指定的并发登录上限初始化配置
+func setupLoginSessionRedis(t *testing.T, maxConcurrent int) *miniredis.Miniredis {
+	t.Helper()
+	config.GlobalConfig = &config.Config{}
+	config.GlobalConfig.JWT = config.JWTConfig{Secret: "login-session-secret", Expire: 3600, Issuer: "bastion-test"}
+	config.GlobalConfig.Security.LoginSession.MaxConcurrent = maxConcurrent
+
+	server := miniredis.RunT(t)
+	Redis = redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { Redis.Close() })
+	return server
+}
+
+func TestGenerateLoginTokenEnforcesLimitBeforeFirstUse(t *testing.T) {
+	setupLoginSessionRedis(t, 2)
+	user := &models.User{ID: 7, Username: "alice"}
+
+	var tokens []*TokenResponse
+	for i := 0; i < 3; i++ {
+		token, err := GenerateLoginToken(user, "192.0.2.10", "test-agent")
+		require.NoError(t, err)
+		tokens = append(tokens, token)
+	}
+
+	// 签发即登记，未使用过的令牌同样计入上限，最早的令牌被注销
+	sessions, err := ListLoginSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, "192.0.2.10", sessions[0].IP)
+	require.Equal(t, "test-agent", sessions[0].UserAgent)
+	require.True(t, IsTokenBlacklisted(tokens[0].AccessToken))
+	require.False(t, IsTokenBlacklisted(tokens[1].AccessToken))
+	require.False(t, IsTokenBlacklisted(tokens[2].AccessToken))
+
+	claims, err := ParseToken(tokens[2].AccessToken)
+	require.NoError(t, err)
+	require.NoError(t, TrackLoginSession(claims, "192.0.2.11", "test-agent"))
+	sessions, err = ListLoginSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+}
+
+func TestGenerateLoginTokenFailsWhenRedisUnavailable(t *testing.T) {
+	server := setupLoginSessionRedis(t, 2)
+	server.Close()
+
+	token, err := GenerateLoginToken(&models.User{ID: 7, Username: "alice"}, "192.0.2.10", "test-agent")
+	require.Error(t, err)
+	require.Nil(t, token)
+}
+
+func TestTrackLoginSession(t *testing.T) {
+	server := setupLoginSessionRedis(t, 1)
+	user := &models.User{ID: 7, Username: "alice"}
+
+	// 升级前签发、没有会话记录的令牌在首次使用时补登记
+	legacy, err := GenerateToken(user)
+	require.NoError(t, err)
+	claims, err := ParseToken(legacy.AccessToken)
+	require.NoError(t, err)
+	require.NoError(t, TrackLoginSession(claims, "192.0.2.10", "test-agent"))
+	sessions, err := ListLoginSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	require.Equal(t, claims.ID, sessions[0].ID)
+
+	// 新的登录超出上限时踢出补登记的会话
+	_, err = GenerateLoginToken(user, "192.0.2.11", "test-agent")
+	require.NoError(t, err)
+	require.True(t, IsTokenBlacklisted(legacy.AccessToken))
+
+	// Redis不可用时无法确认会话状态，不放行请求
+	server.Close()
+	err = TrackLoginSession(claims, "192.0.2.10", "test-agent")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrLoginSessionRevoked)
+}
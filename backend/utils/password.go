@@ -1,18 +1,14 @@
 package utils
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
-	"crypto/rand"
 	"encoding/base64"
-	"errors"
-	"io"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-// 加密密钥，实际项目中应该从配置文件读取
-var encryptionKey = []byte("bastion-key-32-chars-long-000000")
+// legacyEncryptionKey 引入密钥后端之前使用的固定密钥，仅用于解密历史数据，重新加密后不再使用
+var legacyEncryptionKey = []byte("bastion-key-32-chars-long-000000")
 
 // HashPassword 对密码进行哈希处理
 func HashPassword(password string) (string, error) {
@@ -49,35 +45,12 @@ func ValidatePasswordStrength(password string) bool {
 	return hasLetter && hasDigit
 }
 
-// EncryptPassword 加密密码（用于凭证存储）
+// EncryptPassword 加密密码（用于凭证存储），通过密钥后端进行信封加密
 func EncryptPassword(password string) (string, error) {
 	if password == "" {
 		return "", nil
 	}
-
-	// 创建AES密码块
-	block, err := aes.NewCipher(encryptionKey)
-	if err != nil {
-		return "", err
-	}
-
-	// 创建GCM模式
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return "", err
-	}
-
-	// 生成随机nonce
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
-	}
-
-	// 加密数据
-	ciphertext := gcm.Seal(nonce, nonce, []byte(password), nil)
-
-	// 返回base64编码的结果
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return EncryptSecret(password)
 }
 
 // DecryptPassword 解密密码（用于凭证使用）
@@ -85,39 +58,27 @@ func DecryptPassword(encryptedPassword string) (string, error) {
 	if encryptedPassword == "" {
 		return "", nil
 	}
+	return DecryptSecret(encryptedPassword)
+}
 
-	// 解码base64
-	ciphertext, err := base64.StdEncoding.DecodeString(encryptedPassword)
-	if err != nil {
-		return "", err
-	}
-
-	// 创建AES密码块
-	block, err := aes.NewCipher(encryptionKey)
-	if err != nil {
-		return "", err
+// DecryptPrivateKey 解密凭证私钥，兼容加密存储前保存的明文PEM私钥
+func DecryptPrivateKey(privateKey string) (string, error) {
+	if strings.HasPrefix(strings.TrimSpace(privateKey), "-----BEGIN") {
+		return privateKey, nil
 	}
+	return DecryptPassword(privateKey)
+}
 
-	// 创建GCM模式
-	gcm, err := cipher.NewGCM(block)
+// decryptLegacySecret 解密引入密钥后端之前使用固定密钥加密的数据
+func decryptLegacySecret(encrypted string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
 	if err != nil {
 		return "", err
 	}
 
-	// 获取nonce大小
-	nonceSize := gcm.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return "", errors.New("ciphertext too short")
-	}
-
-	// 分离nonce和密文
-	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-
-	// 解密数据
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := openAESGCM(legacyEncryptionKey, ciphertext)
 	if err != nil {
 		return "", err
 	}
-
 	return string(plaintext), nil
 }
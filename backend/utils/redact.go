@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RedactedValue 敏感字段脱敏后的占位值
+const RedactedValue = "******"
+
+// sensitiveFieldNames 需要脱敏的字段名（小写），另外名称中包含 password、secret、private_key 的字段也会脱敏
+var sensitiveFieldNames = map[string]bool{
+	"token":          true,
+	"access_token":   true,
+	"refresh_token":  true,
+	"passphrase":     true,
+	"recovery_code":  true,
+	"recovery_codes": true,
+}
+
+// IsSensitiveField 判断JSON字段名是否为敏感字段
+func IsSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	return sensitiveFieldNames[name] ||
+		strings.Contains(name, "password") ||
+		strings.Contains(name, "secret") ||
+		strings.Contains(name, "private_key")
+}
+
+// RedactSecrets 递归替换JSON对象中敏感字段的字符串值，数字、布尔等非字符串值（如 password_length）保持不变
+func RedactSecrets(data interface{}) interface{} {
+	switch value := data.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			if IsSensitiveField(key) {
+				redacted[key] = redactValue(item)
+				continue
+			}
+			redacted[key] = RedactSecrets(item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(value))
+		for i, item := range value {
+			redacted[i] = RedactSecrets(item)
+		}
+		return redacted
+	default:
+		return data
+	}
+}
+
+// RedactJSON 对JSON数据脱敏，无法解析时原样返回
+func RedactJSON(data []byte) []byte {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return data
+	}
+	redacted, err := json.Marshal(RedactSecrets(value))
+	if err != nil {
+		return data
+	}
+	return redacted
+}
+
+// redactValue 替换敏感字段的值，空字符串保留以便区分是否设置，对象整体替换
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return v
+		}
+		return RedactedValue
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactValue(item)
+		}
+		return redacted
+	case map[string]interface{}:
+		return RedactedValue
+	default:
+		return value
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -124,4 +125,25 @@ func RespondWithInternalError(c *gin.Context, message string) {
 // 用于资源冲突的场景，如重复创建等
 func RespondWithConflict(c *gin.Context, message string) {
 	RespondWithError(c, http.StatusConflict, message)
-}
\ No newline at end of file
+}
+
+// RespondWithServiceError 按服务层错误类型返回对应的错误响应
+// resource 为资源名称，用于资源不存在的提示；为空时直接返回错误信息
+func RespondWithServiceError(c *gin.Context, err error, resource string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if resource == "" {
+			RespondWithError(c, http.StatusNotFound, err.Error())
+			return
+		}
+		RespondWithNotFound(c, resource)
+	case errors.Is(err, ErrInvalidParam):
+		RespondWithValidationError(c, err.Error())
+	case errors.Is(err, ErrPermissionDenied):
+		RespondWithForbidden(c, err.Error())
+	case errors.Is(err, ErrDuplicate):
+		RespondWithConflict(c, err.Error())
+	default:
+		RespondWithInternalError(c, err.Error())
+	}
+}
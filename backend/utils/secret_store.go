@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"bastion/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 密钥后端类型
+const (
+	SecretBackendFile  = "file"
+	SecretBackendVault = "vault"
+)
+
+// secretEnvelopePrefix 信封加密密文前缀，格式：
+// enc1.<后端>.<主密钥版本>.<被主密钥包装的数据密钥>.<base64(nonce+密文)>
+// 不带前缀的密文是旧版本使用固定密钥加密的数据
+const secretEnvelopePrefix = "enc1"
+
+// ErrSecretStoreNotInitialized 密钥后端未初始化
+var ErrSecretStoreNotInitialized = errors.New("secret store is not initialized")
+
+// SecretStore 主密钥后端
+// 每条记录使用独立的随机数据密钥加密，数据密钥由主密钥包装后与密文一起保存（信封加密），
+// 主密钥不离开后端。轮换主密钥后旧版本仍可解包，直到重新加密完成后停用。
+type SecretStore interface {
+	// Name 后端名称，写入密文用于校验
+	Name() string
+	// WrapKey 使用当前主密钥包装数据密钥，返回包装结果和主密钥版本
+	WrapKey(dataKey []byte) (string, int, error)
+	// UnwrapKey 使用指定版本的主密钥解包数据密钥
+	UnwrapKey(wrapped string, version int) ([]byte, error)
+	// CurrentVersion 当前主密钥版本
+	CurrentVersion() (int, error)
+	// Rotate 生成新的主密钥版本，返回新版本号
+	Rotate() (int, error)
+	// RetireVersionsBelow 停用低于指定版本的主密钥，之后这些版本包装的数据无法解密
+	RetireVersionsBelow(version int) error
+}
+
+var (
+	secretStore   SecretStore
+	secretStoreMu sync.RWMutex
+)
+
+// InitSecretStore 根据配置初始化密钥后端
+func InitSecretStore() error {
+	cfg := config.GlobalConfig.SecretStore
+
+	var store SecretStore
+	var err error
+	switch cfg.Backend {
+	case SecretBackendFile, "":
+		store, err = NewFileSecretStore(cfg.KeyFile)
+	case SecretBackendVault:
+		store, err = NewVaultSecretStore(cfg.Vault)
+	default:
+		return fmt.Errorf("unsupported secret store backend: %s", cfg.Backend)
+	}
+	if err != nil {
+		return err
+	}
+
+	SetSecretStore(store)
+	return nil
+}
+
+// SetSecretStore 设置全局密钥后端
+func SetSecretStore(store SecretStore) {
+	secretStoreMu.Lock()
+	defer secretStoreMu.Unlock()
+	secretStore = store
+}
+
+// GetSecretStore 获取全局密钥后端
+func GetSecretStore() (SecretStore, error) {
+	secretStoreMu.RLock()
+	defer secretStoreMu.RUnlock()
+	if secretStore == nil {
+		return nil, ErrSecretStoreNotInitialized
+	}
+	return secretStore, nil
+}
+
+// EncryptSecret 使用信封加密保存敏感数据
+func EncryptSecret(plaintext string) (string, error) {
+	store, err := GetSecretStore()
+	if err != nil {
+		return "", err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	sealed, err := sealAESGCM(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrapped, version, err := store.WrapKey(dataKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	return strings.Join([]string{
+		secretEnvelopePrefix,
+		store.Name(),
+		strconv.Itoa(version),
+		base64.RawURLEncoding.EncodeToString([]byte(wrapped)),
+		base64.StdEncoding.EncodeToString(sealed),
+	}, "."), nil
+}
+
+// DecryptSecret 解密敏感数据，兼容旧版本固定密钥加密的密文
+func DecryptSecret(ciphertext string) (string, error) {
+	envelope, ok, err := parseSecretEnvelope(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return decryptLegacySecret(ciphertext)
+	}
+
+	store, err := GetSecretStore()
+	if err != nil {
+		return "", err
+	}
+	if envelope.backend != store.Name() {
+		return "", fmt.Errorf("secret was encrypted by %s backend, current backend is %s", envelope.backend, store.Name())
+	}
+	dataKey, err := store.UnwrapKey(envelope.wrapped, envelope.version)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	plaintext, err := openAESGCM(dataKey, envelope.sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// SecretNeedsReencrypt 判断密文是否需要用当前主密钥重新加密（旧格式或旧版本主密钥）
+func SecretNeedsReencrypt(ciphertext string) (bool, error) {
+	if ciphertext == "" {
+		return false, nil
+	}
+	envelope, ok, err := parseSecretEnvelope(ciphertext)
+	if err != nil || !ok {
+		return true, err
+	}
+
+	store, err := GetSecretStore()
+	if err != nil {
+		return false, err
+	}
+	current, err := store.CurrentVersion()
+	if err != nil {
+		return false, err
+	}
+	return envelope.backend != store.Name() || envelope.version < current, nil
+}
+
+// SecretKeyVersion 返回密文使用的主密钥版本，旧格式密文返回0
+func SecretKeyVersion(ciphertext string) int {
+	envelope, ok, err := parseSecretEnvelope(ciphertext)
+	if err != nil || !ok {
+		return 0
+	}
+	return envelope.version
+}
+
+// IsEncryptedSecret 判断数据是否为信封加密的密文
+func IsEncryptedSecret(value string) bool {
+	return strings.HasPrefix(value, secretEnvelopePrefix+".")
+}
+
+// secretEnvelope 解析后的信封密文
+type secretEnvelope struct {
+	backend string
+	version int
+	wrapped string
+	sealed  []byte
+}
+
+// parseSecretEnvelope 解析信封密文，不是信封格式时返回 ok=false
+func parseSecretEnvelope(ciphertext string) (*secretEnvelope, bool, error) {
+	if !IsEncryptedSecret(ciphertext) {
+		return nil, false, nil
+	}
+
+	parts := strings.Split(ciphertext, ".")
+	if len(parts) != 5 {
+		return nil, true, errors.New("invalid secret envelope")
+	}
+	version, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid secret key version: %w", err)
+	}
+	wrapped, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid wrapped data key: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid secret ciphertext: %w", err)
+	}
+
+	return &secretEnvelope{
+		backend: parts[1],
+		version: version,
+		wrapped: string(wrapped),
+		sealed:  sealed,
+	}, true, nil
+}
+
+// sealAESGCM AES-256-GCM加密，返回 nonce+密文
+func sealAESGCM(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// openAESGCM 解密 sealAESGCM 的结果
+func openAESGCM(key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+}
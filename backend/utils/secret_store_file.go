@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileSecretStore 本地主密钥文件后端
+// 主密钥文件保存所有未停用的主密钥版本，权限为0600。其它进程（如重新加密命令）轮换主密钥后，
+// 文件修改时间变化会触发重新加载，运行中的服务随即使用新版本加密。
+type FileSecretStore struct {
+	path    string
+	mu      sync.Mutex
+	keys    masterKeyFile
+	modTime time.Time
+	size    int64
+}
+
+// masterKeyFile 主密钥文件内容
+type masterKeyFile struct {
+	CurrentVersion int         `json:"current_version"`
+	Keys           []masterKey `json:"keys"`
+}
+
+// masterKey 单个版本的主密钥
+type masterKey struct {
+	Version   int       `json:"version"`
+	Key       string    `json:"key"` // base64编码的32字节AES密钥
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewFileSecretStore 创建本地主密钥文件后端，文件不存在时生成第一个版本
+func NewFileSecretStore(path string) (*FileSecretStore, error) {
+	if path == "" {
+		return nil, errors.New("secret store key file is not configured")
+	}
+
+	store := &FileSecretStore{path: path}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create key file directory: %w", err)
+		}
+		store.keys = masterKeyFile{}
+		if _, err := store.addVersion(); err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+
+	if err := store.reload(true); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Name 后端名称
+func (s *FileSecretStore) Name() string {
+	return SecretBackendFile
+}
+
+// WrapKey 使用当前主密钥包装数据密钥
+func (s *FileSecretStore) WrapKey(dataKey []byte) (string, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(false); err != nil {
+		return "", 0, err
+	}
+	key, err := s.key(s.keys.CurrentVersion)
+	if err != nil {
+		return "", 0, err
+	}
+	sealed, err := sealAESGCM(key, dataKey)
+	if err != nil {
+		return "", 0, err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), s.keys.CurrentVersion, nil
+}
+
+// UnwrapKey 使用指定版本的主密钥解包数据密钥
+func (s *FileSecretStore) UnwrapKey(wrapped string, version int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(false); err != nil {
+		return nil, err
+	}
+	key, err := s.key(version)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped data key: %w", err)
+	}
+	return openAESGCM(key, sealed)
+}
+
+// CurrentVersion 当前主密钥版本
+func (s *FileSecretStore) CurrentVersion() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(false); err != nil {
+		return 0, err
+	}
+	return s.keys.CurrentVersion, nil
+}
+
+// Rotate 生成新的主密钥版本
+func (s *FileSecretStore) Rotate() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(false); err != nil {
+		return 0, err
+	}
+	return s.addVersion()
+}
+
+// RetireVersionsBelow 从主密钥文件中删除低于指定版本的主密钥
+func (s *FileSecretStore) RetireVersionsBelow(version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.reload(false); err != nil {
+		return err
+	}
+	if version > s.keys.CurrentVersion {
+		return fmt.Errorf("cannot retire the current master key version %d", s.keys.CurrentVersion)
+	}
+
+	keys := s.keys
+	keys.Keys = nil
+	for _, key := range s.keys.Keys {
+		if key.Version >= version {
+			keys.Keys = append(keys.Keys, key)
+		}
+	}
+	return s.save(keys)
+}
+
+// addVersion 生成新版本主密钥并设为当前版本，调用方需持有锁
+func (s *FileSecretStore) addVersion() (int, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return 0, fmt.Errorf("failed to generate master key: %w", err)
+	}
+
+	keys := s.keys
+	keys.CurrentVersion++
+	keys.Keys = append(append([]masterKey{}, s.keys.Keys...), masterKey{
+		Version:   keys.CurrentVersion,
+		Key:       base64.StdEncoding.EncodeToString(key),
+		CreatedAt: time.Now(),
+	})
+	if err := s.save(keys); err != nil {
+		return 0, err
+	}
+	return keys.CurrentVersion, nil
+}
+
+// key 获取指定版本的主密钥，调用方需持有锁
+func (s *FileSecretStore) key(version int) ([]byte, error) {
+	for _, key := range s.keys.Keys {
+		if key.Version == version {
+			return base64.StdEncoding.DecodeString(key.Key)
+		}
+	}
+	return nil, fmt.Errorf("master key version %d not found or retired", version)
+}
+
+// reload 主密钥文件被修改后重新加载，调用方需持有锁
+func (s *FileSecretStore) reload(force bool) error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat key file: %w", err)
+	}
+	if !force && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+	var keys masterKeyFile
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("failed to parse key file: %w", err)
+	}
+	if keys.CurrentVersion == 0 || len(keys.Keys) == 0 {
+		return errors.New("key file does not contain any master key")
+	}
+
+	s.keys = keys
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// save 先写临时文件再替换，避免写入中断损坏主密钥文件，调用方需持有锁
+func (s *FileSecretStore) save(keys masterKeyFile) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace key file: %w", err)
+	}
+
+	s.keys = keys
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime, s.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"bastion/config"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VaultSecretStore Vault Transit 引擎后端
+// 数据密钥通过 transit/encrypt、transit/decrypt 包装和解包，主密钥保存在Vault中，版本号由Vault维护。
+type VaultSecretStore struct {
+	address    string
+	token      string
+	namespace  string
+	mount      string
+	keyName    string
+	httpClient *http.Client
+}
+
+// NewVaultSecretStore 创建Vault Transit后端
+func NewVaultSecretStore(cfg config.VaultSecretConfig) (*VaultSecretStore, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("vault address is not configured")
+	}
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if token == "" {
+		return nil, errors.New("vault token is not configured")
+	}
+	mount := strings.Trim(cfg.Mount, "/")
+	if mount == "" {
+		mount = "transit"
+	}
+	keyName := cfg.KeyName
+	if keyName == "" {
+		keyName = "bastion"
+	}
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &VaultSecretStore{
+		address:    strings.TrimRight(cfg.Address, "/"),
+		token:      token,
+		namespace:  cfg.Namespace,
+		mount:      mount,
+		keyName:    keyName,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name 后端名称
+func (s *VaultSecretStore) Name() string {
+	return SecretBackendVault
+}
+
+// WrapKey 使用Transit密钥的最新版本加密数据密钥
+func (s *VaultSecretStore) WrapKey(dataKey []byte) (string, int, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	body := map[string]interface{}{"plaintext": base64.StdEncoding.EncodeToString(dataKey)}
+	if err := s.do(http.MethodPost, "encrypt/"+s.keyName, body, &resp); err != nil {
+		return "", 0, err
+	}
+
+	version, err := vaultCiphertextVersion(resp.Data.Ciphertext)
+	if err != nil {
+		return "", 0, err
+	}
+	return resp.Data.Ciphertext, version, nil
+}
+
+// UnwrapKey 解密数据密钥，Vault根据密文中的版本选择主密钥
+func (s *VaultSecretStore) UnwrapKey(wrapped string, version int) ([]byte, error) {
+	if actual, err := vaultCiphertextVersion(wrapped); err != nil || actual != version {
+		return nil, errors.New("wrapped data key does not match key version")
+	}
+
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := s.do(http.MethodPost, "decrypt/"+s.keyName, map[string]interface{}{"ciphertext": wrapped}, &resp); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+// CurrentVersion 读取Transit密钥的最新版本
+func (s *VaultSecretStore) CurrentVersion() (int, error) {
+	var resp struct {
+		Data struct {
+			LatestVersion int `json:"latest_version"`
+		} `json:"data"`
+	}
+	if err := s.do(http.MethodGet, "keys/"+s.keyName, nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Data.LatestVersion, nil
+}
+
+// Rotate 轮换Transit密钥
+func (s *VaultSecretStore) Rotate() (int, error) {
+	if err := s.do(http.MethodPost, "keys/"+s.keyName+"/rotate", nil, nil); err != nil {
+		return 0, err
+	}
+	return s.CurrentVersion()
+}
+
+// RetireVersionsBelow 设置 min_decryption_version，Vault拒绝解密更早版本加密的数据
+func (s *VaultSecretStore) RetireVersionsBelow(version int) error {
+	return s.do(http.MethodPost, "keys/"+s.keyName+"/config", map[string]interface{}{"min_decryption_version": version}, nil)
+}
+
+// do 调用Transit API
+func (s *VaultSecretStore) do(method, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/v1/%s/%s", s.address, s.mount, path), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	if s.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", s.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read vault response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(data, &vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return fmt.Errorf("vault returned %d: %s", resp.StatusCode, strings.Join(vaultErr.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned %d", resp.StatusCode)
+	}
+
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to parse vault response: %w", err)
+	}
+	return nil
+}
+
+// vaultCiphertextVersion 解析 vault:v<版本>:<密文> 格式中的版本号
+func vaultCiphertextVersion(ciphertext string) (int, error) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return 0, errors.New("invalid vault ciphertext")
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(parts[1], "v"))
+	if err != nil {
+		return 0, errors.New("invalid vault ciphertext version")
+	}
+	return version, nil
+}
@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"bastion/config"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testVaultToken     = "s.test-vault-token"
+	testVaultNamespace = "bastion-ns"
+	testVaultMount     = "secrets-transit"
+	testVaultKeyName   = "credentials"
+)
+
+// testVault 模拟Vault Transit引擎：按版本保存主密钥，支持加解密、轮换和最低解密版本
+type testVault struct {
+	server *httptest.Server
+
+	mu                   sync.Mutex
+	keys                 [][]byte // 下标0对应版本1
+	minDecryptionVersion int
+	decryptRequests      int
+}
+
+// newTestVault 启动Vault模拟服务，初始主密钥版本为1
+func newTestVault(t *testing.T) *testVault {
+	t.Helper()
+	vault := &testVault{minDecryptionVersion: 1}
+	vault.rotate()
+	vault.server = httptest.NewServer(http.HandlerFunc(vault.handle))
+	t.Cleanup(vault.server.Close)
+	return vault
+}
+
+// config 指向模拟服务的后端配置
+func (v *testVault) config() config.VaultSecretConfig {
+	return config.VaultSecretConfig{
+		Address:   v.server.URL + "/",
+		Token:     testVaultToken,
+		Namespace: testVaultNamespace,
+		Mount:     "/" + testVaultMount + "/",
+		KeyName:   testVaultKeyName,
+		Timeout:   2,
+	}
+}
+
+// decryptCount 收到的解密请求数
+func (v *testVault) decryptCount() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.decryptRequests
+}
+
+func (v *testVault) rotate() {
+	key := make([]byte, 32)
+	rand.Read(key)
+	v.keys = append(v.keys, key)
+}
+
+func (v *testVault) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != testVaultToken || r.Header.Get("X-Vault-Namespace") != testVaultNamespace {
+		writeVaultError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+
+	var body map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+	value := func(name string) string {
+		str, _ := body[name].(string)
+		return str
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	prefix := "/v1/" + testVaultMount + "/"
+	switch path := strings.TrimPrefix(r.URL.Path, prefix); {
+	case !strings.HasPrefix(r.URL.Path, prefix):
+		writeVaultError(w, http.StatusNotFound, "no handler for route")
+	case r.Method == http.MethodPost && path == "encrypt/"+testVaultKeyName:
+		plaintext, err := base64.StdEncoding.DecodeString(value("plaintext"))
+		if err != nil {
+			writeVaultError(w, http.StatusBadRequest, "failed to decode plaintext")
+			return
+		}
+		version := len(v.keys)
+		sealed, err := sealAESGCM(v.keys[version-1], plaintext)
+		if err != nil {
+			writeVaultError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeVaultData(w, map[string]interface{}{
+			"ciphertext": fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(sealed)),
+		})
+	case r.Method == http.MethodPost && path == "decrypt/"+testVaultKeyName:
+		v.decryptRequests++
+		parts := strings.SplitN(value("ciphertext"), ":", 3)
+		if len(parts) != 3 {
+			writeVaultError(w, http.StatusBadRequest, "invalid ciphertext")
+			return
+		}
+		version, err := strconv.Atoi(strings.TrimPrefix(parts[1], "v"))
+		if err != nil || version < 1 || version > len(v.keys) {
+			writeVaultError(w, http.StatusBadRequest, "invalid ciphertext version")
+			return
+		}
+		if version < v.minDecryptionVersion {
+			writeVaultError(w, http.StatusBadRequest, "ciphertext or signature version is disallowed by policy (too old)")
+			return
+		}
+		sealed, _ := base64.StdEncoding.DecodeString(parts[2])
+		plaintext, err := openAESGCM(v.keys[version-1], sealed)
+		if err != nil {
+			writeVaultError(w, http.StatusBadRequest, "cipher: message authentication failed")
+			return
+		}
+		writeVaultData(w, map[string]interface{}{"plaintext": base64.StdEncoding.EncodeToString(plaintext)})
+	case r.Method == http.MethodGet && path == "keys/"+testVaultKeyName:
+		writeVaultData(w, map[string]interface{}{
+			"latest_version":         len(v.keys),
+			"min_decryption_version": v.minDecryptionVersion,
+		})
+	case r.Method == http.MethodPost && path == "keys/"+testVaultKeyName+"/rotate":
+		v.rotate()
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && path == "keys/"+testVaultKeyName+"/config":
+		if version, ok := body["min_decryption_version"].(float64); ok {
+			v.minDecryptionVersion = int(version)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeVaultError(w, http.StatusNotFound, "no handler for route")
+	}
+}
+
+func writeVaultData(w http.ResponseWriter, data map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeVaultError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{message}})
+}
+
+// setupVaultSecretStore 使用Vault后端初始化全局密钥后端
+func setupVaultSecretStore(t *testing.T, vault *testVault) {
+	t.Helper()
+	config.GlobalConfig = &config.Config{}
+	config.GlobalConfig.SecretStore = config.SecretStoreConfig{Backend: SecretBackendVault, Vault: vault.config()}
+	require.NoError(t, InitSecretStore())
+	t.Cleanup(func() { SetSecretStore(nil) })
+}
+
+func TestVaultSecretStoreEnvelopeRoundTrip(t *testing.T) {
+	vault := newTestVault(t)
+	setupVaultSecretStore(t, vault)
+
+	ciphertext, err := EncryptSecret("db-password-Zx81")
+	require.NoError(t, err)
+	require.True(t, IsEncryptedSecret(ciphertext))
+	require.True(t, strings.HasPrefix(ciphertext, "enc1.vault.1."), ciphertext)
+	require.NotContains(t, ciphertext, "db-password-Zx81")
+
+	// 数据密钥由Vault包装，密文中只有Vault返回的包装结果
+	envelope, ok, err := parseSecretEnvelope(ciphertext)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(envelope.wrapped, "vault:v1:"), envelope.wrapped)
+
+	plaintext, err := DecryptSecret(ciphertext)
+	require.NoError(t, err)
+	require.Equal(t, "db-password-Zx81", plaintext)
+	require.Equal(t, 1, vault.decryptCount())
+}
+
+func TestVaultSecretStoreRotationAndRetirement(t *testing.T) {
+	vault := newTestVault(t)
+	setupVaultSecretStore(t, vault)
+	store, err := GetSecretStore()
+	require.NoError(t, err)
+
+	oldCiphertext, err := EncryptSecret("before-rotation")
+	require.NoError(t, err)
+
+	version, err := store.Rotate()
+	require.NoError(t, err)
+	require.Equal(t, 2, version)
+
+	needs, err := SecretNeedsReencrypt(oldCiphertext)
+	require.NoError(t, err)
+	require.True(t, needs)
+
+	newCiphertext, err := EncryptSecret("after-rotation")
+	require.NoError(t, err)
+	require.Equal(t, 2, SecretKeyVersion(newCiphertext))
+	needs, err = SecretNeedsReencrypt(newCiphertext)
+	require.NoError(t, err)
+	require.False(t, needs)
+
+	// 停用前旧版本仍可解密
+	plaintext, err := DecryptSecret(oldCiphertext)
+	require.NoError(t, err)
+	require.Equal(t, "before-rotation", plaintext)
+
+	require.NoError(t, store.RetireVersionsBelow(2))
+	_, err = DecryptSecret(oldCiphertext)
+	require.ErrorContains(t, err, "disallowed by policy")
+
+	plaintext, err = DecryptSecret(newCiphertext)
+	require.NoError(t, err)
+	require.Equal(t, "after-rotation", plaintext)
+}
+
+func TestVaultSecretStoreRejectsTamperedKeyVersion(t *testing.T) {
+	vault := newTestVault(t)
+	setupVaultSecretStore(t, vault)
+
+	ciphertext, err := EncryptSecret("secret")
+	require.NoError(t, err)
+
+	// 信封中的版本号与Vault密文中的版本不一致时不发送解密请求
+	parts := strings.Split(ciphertext, ".")
+	parts[2] = "7"
+	_, err = DecryptSecret(strings.Join(parts, "."))
+	require.ErrorContains(t, err, "does not match key version")
+	require.Zero(t, vault.decryptCount())
+}
+
+func TestVaultSecretStoreRejectsOtherBackendCiphertext(t *testing.T) {
+	config.GlobalConfig = &config.Config{}
+	config.GlobalConfig.SecretStore.KeyFile = filepath.Join(t.TempDir(), "master.key")
+	require.NoError(t, InitSecretStore())
+	fileCiphertext, err := EncryptSecret("secret")
+	require.NoError(t, err)
+
+	vault := newTestVault(t)
+	setupVaultSecretStore(t, vault)
+
+	_, err = DecryptSecret(fileCiphertext)
+	require.ErrorContains(t, err, "secret was encrypted by file backend")
+	needs, err := SecretNeedsReencrypt(fileCiphertext)
+	require.NoError(t, err)
+	require.True(t, needs)
+}
+
+func TestVaultSecretStoreSurfacesVaultErrors(t *testing.T) {
+	vault := newTestVault(t)
+
+	cfg := vault.config()
+	cfg.Token = "wrong-token"
+	store, err := NewVaultSecretStore(cfg)
+	require.NoError(t, err)
+	_, _, err = store.WrapKey(make([]byte, 32))
+	require.ErrorContains(t, err, "vault returned 403: permission denied")
+
+	cfg = vault.config()
+	cfg.KeyName = "missing"
+	store, err = NewVaultSecretStore(cfg)
+	require.NoError(t, err)
+	_, err = store.CurrentVersion()
+	require.ErrorContains(t, err, "vault returned 404")
+
+	unreachable := vault.config()
+	vault.server.Close()
+	store, err = NewVaultSecretStore(unreachable)
+	require.NoError(t, err)
+	_, _, err = store.WrapKey(make([]byte, 32))
+	require.ErrorContains(t, err, "vault request failed")
+}
+
+func TestNewVaultSecretStoreConfig(t *testing.T) {
+	_, err := NewVaultSecretStore(config.VaultSecretConfig{Token: testVaultToken})
+	require.ErrorContains(t, err, "vault address is not configured")
+
+	t.Setenv("VAULT_TOKEN", "")
+	_, err = NewVaultSecretStore(config.VaultSecretConfig{Address: "http://127.0.0.1:8200"})
+	require.ErrorContains(t, err, "vault token is not configured")
+
+	// 未配置令牌时读取环境变量，挂载路径和密钥名称使用默认值
+	t.Setenv("VAULT_TOKEN", "env-token")
+	store, err := NewVaultSecretStore(config.VaultSecretConfig{Address: "http://127.0.0.1:8200/"})
+	require.NoError(t, err)
+	require.Equal(t, "env-token", store.token)
+	require.Equal(t, "transit", store.mount)
+	require.Equal(t, "bastion", store.keyName)
+	require.Equal(t, "http://127.0.0.1:8200", store.address)
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH密钥类型
+const (
+	SSHKeyTypeRSA     = "rsa"
+	SSHKeyTypeED25519 = "ed25519"
+)
+
+// rsaKeyBits 生成RSA密钥的长度
+const rsaKeyBits = 2048
+
+// GenerateSSHKeyPair 生成SSH密钥对，返回PEM格式私钥和authorized_keys格式公钥
+// 私钥使用PKCS1（RSA）或PKCS8（Ed25519）编码，comment 非空时追加到公钥末尾
+func GenerateSSHKeyPair(keyType, comment string) (string, string, error) {
+	var block *pem.Block
+	var publicKey ssh.PublicKey
+
+	switch keyType {
+	case SSHKeyTypeRSA, "":
+		privateKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to generate private key: %w", err)
+		}
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}
+		publicKey, err = ssh.NewPublicKey(&privateKey.PublicKey)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to generate public key: %w", err)
+		}
+	case SSHKeyTypeED25519:
+		pub, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to generate private key: %w", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to encode private key: %w", err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+		publicKey, err = ssh.NewPublicKey(pub)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to generate public key: %w", err)
+		}
+	default:
+		return "", "", fmt.Errorf("%w: unsupported key type %s", ErrInvalidParam, keyType)
+	}
+
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))
+	if comment != "" {
+		authorizedKey += " " + comment
+	}
+	return string(pem.EncodeToMemory(block)), authorizedKey + "\n", nil
+}
+
+// SSHKeyType 返回公钥对应的密钥类型，Ed25519以外的密钥都按RSA处理
+func SSHKeyType(publicKey ssh.PublicKey) string {
+	if publicKey.Type() == ssh.KeyAlgoED25519 {
+		return SSHKeyTypeED25519
+	}
+	return SSHKeyTypeRSA
+}